@@ -3,16 +3,28 @@
 // It loads runtime configuration, initializes a structured JSON logger,
 // constructs the application from a database DSN, and starts the server.
 // On initialization failure the process logs the error and exits.
+//
+// Administrative tasks run as subcommands instead of starting the server:
+//
+//	gophkeeper-server gc [-dry-run] [server flags]
+//
+// collects blob garbage once and prints what was (or, with -dry-run, would
+// be) deleted.
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"log/slog"
 	"os"
 
+	"github.com/dmitrijs2005/gophkeeper/internal/flagx"
 	"github.com/dmitrijs2005/gophkeeper/internal/logging"
 	"github.com/dmitrijs2005/gophkeeper/internal/server"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/config"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/services"
 )
 
 // main wires configuration, logging, and the server application, then runs it.
@@ -21,7 +33,8 @@ import (
 //  1. Load configuration (see internal/server/config).
 //  2. Create a JSON slog logger and wrap it with the project's logging facade.
 //  3. Build the server application using the configured DSN.
-//  4. Run the application until it stops or fails.
+//  4. Run the application until it stops or fails, or run the requested
+//     subcommand.
 //
 // Any construction error is logged; the process then exits with a non-zero code.
 func main() {
@@ -36,5 +49,46 @@ func main() {
 		return
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "gc" {
+		if err := runGC(app, os.Args[2:]); err != nil {
+			log.Printf("%v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	app.Run()
 }
+
+// runGC runs the gc subcommand: one blob garbage collection whose report is
+// printed to stdout.
+func runGC(app *server.App, args []string) error {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only report the garbage, delete nothing")
+	if err := fs.Parse(flagx.FilterArgs(args, []string{"-dry-run"})); err != nil {
+		return err
+	}
+
+	r, err := app.CollectGarbage(context.Background(), *dryRun)
+	if r != nil {
+		printGarbageReport(r, *dryRun)
+	}
+	return err
+}
+
+// printGarbageReport prints every garbage object with the reason it is
+// garbage, followed by the totals.
+func printGarbageReport(r *services.GarbageReport, dryRun bool) {
+	for _, o := range r.Unreferenced {
+		fmt.Printf("%s\tunreferenced\t%d\n", o.Key, o.Size)
+	}
+	for _, o := range r.Tombstoned {
+		fmt.Printf("%s\ttombstoned\t%d\n", o.Key, o.Size)
+	}
+	garbage := len(r.Unreferenced) + len(r.Tombstoned)
+	if dryRun {
+		fmt.Printf("scanned %d objects, %d garbage (%d bytes), dry run: nothing deleted\n", r.Scanned, garbage, r.Size())
+		return
+	}
+	fmt.Printf("scanned %d objects, %d garbage (%d bytes), %d deleted\n", r.Scanned, garbage, r.Size(), r.Deleted)
+}
@@ -23,6 +23,11 @@ func printBuildParam(w io.Writer, name string, value string) {
 	fmt.Fprintf(w, "%s: %s\n", name, value)
 }
 
+// Version returns the build version, or "" for builds without one.
+func Version() string {
+	return buildVersion
+}
+
 // PrintBuildData prints the build version, build date, and build commit
 // to the provided writer. This is useful for logging or displaying version info.
 func PrintBuildData(w io.Writer) {
@@ -80,3 +80,11 @@ func Test_PrintBuildData_CustomValues(t *testing.T) {
 
 	require.Equal(t, want, buf.String())
 }
+
+func TestVersion(t *testing.T) {
+	old := buildVersion
+	t.Cleanup(func() { buildVersion = old })
+
+	buildVersion = "1.2.3"
+	assert.Equal(t, "1.2.3", Version())
+}
@@ -49,9 +49,9 @@ type App struct {
 	// via the server API.
 	entryService services.EntryService
 
-	// masterKey is set upon successful login and remains nil otherwise.
+	// vaultKey is set upon successful login and remains nil otherwise.
 	// It should be wiped on logout.
-	masterKey []byte
+	vaultKey []byte
 
 	// userName is the authenticated user's identifier.
 	userName string
@@ -66,7 +66,7 @@ type App struct {
 // NewApp constructs an App from the given config.
 //
 // It initializes the local SQLite database, creates an API client pointing to
-// the configured server endpoint, and wires the authentication and entry
+// the configured server endpoint over the configured TLS, and wires the authentication and entry
 // services around them.
 //
 // The returned App is ready to Run. On initialization failure an error is
@@ -80,7 +80,11 @@ func NewApp(c *config.Config) (*App, error) {
 		return nil, err
 	}
 
-	apiClient, err := client.NewGophKeeperClientService(c.ServerEndpointAddr)
+	tlsConfig, err := c.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	apiClient, err := client.NewGophKeeperClientService(c.ServerEndpointAddr, tlsConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -115,9 +119,9 @@ func (a *App) Run(ctx context.Context) {
 	a.Root(ctx)
 }
 
-// isLoggedIn reports whether a masterKey is present (i.e., the user is logged in).
+// isLoggedIn reports whether a vaultKey is present (i.e., the user is logged in).
 func (a *App) isLoggedIn() bool {
-	return a.masterKey != nil
+	return a.vaultKey != nil
 }
 
 // StartOnlineStatusWatcher periodically probes server reachability and updates
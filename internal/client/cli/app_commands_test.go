@@ -2,16 +2,23 @@ package cli
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/client/models"
 	"github.com/dmitrijs2005/gophkeeper/internal/client/services"
+	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
 	"github.com/stretchr/testify/require"
 )
 
@@ -37,7 +44,7 @@ func newTestApp(es services.EntryService, sc *bufio.Reader, mk []byte) *App {
 	return &App{
 		entryService: es,
 		reader:       sc,
-		masterKey:    mk,
+		vaultKey:     mk,
 	}
 }
 
@@ -60,6 +67,12 @@ type fakeES struct {
 	getOut *models.Envelope
 	getErr error
 
+	// Update
+	updID   string
+	updEnv  models.Envelope
+	updFile *models.File
+	updErr  error
+
 	// Delete
 	delID  string
 	delErr error
@@ -77,24 +90,57 @@ type fakeES struct {
 	getFileID  string
 	getFile    *models.File
 	getFileErr error
+
+	// History / Restore
+	history        []models.ViewRevision
+	historyErr     error
+	restoredID     string
+	restoreVersion int64
+
+	// Trash
+	trash     []models.ViewOverview
+	undeleted string
+	purgedID  string
+	purgeErr  error
+
+	// MigrateFileKeys
+	migrateMK  []byte
+	migrateErr error
+
+	// ResealEntries
+	resealMK []byte
+
+	// Conflicts
+	conflicts        []models.Conflict
+	conflictsErr     error
+	resolvedID       string
+	resolvedEnv      models.Envelope
+	resolvedDeleted  bool
+	resolveCallCount int
 }
 
 func (f *fakeES) Sync(ctx context.Context) error { f.syncCalled = true; return f.syncErr }
-func (f *fakeES) List(ctx context.Context, masterKey []byte) ([]models.ViewOverview, error) {
-	f.listMK = masterKey
+func (f *fakeES) List(ctx context.Context, vaultKey []byte) ([]models.ViewOverview, error) {
+	f.listMK = vaultKey
 	return f.listOut, f.listErr
 }
-func (f *fakeES) Add(ctx context.Context, env models.Envelope, file *models.File, masterKey []byte) error {
+func (f *fakeES) Add(ctx context.Context, env models.Envelope, file *models.File, vaultKey []byte) error {
 	f.addCount++
 	f.addEnv = env
 	f.addFile = file
-	f.addMK = masterKey
+	f.addMK = vaultKey
 	return f.addErr
 }
+func (f *fakeES) Update(ctx context.Context, id string, env models.Envelope, file *models.File, vaultKey []byte) error {
+	f.updID = id
+	f.updEnv = env
+	f.updFile = file
+	return f.updErr
+}
 func (f *fakeES) DeleteByID(ctx context.Context, id string) error { f.delID = id; return f.delErr }
-func (f *fakeES) Get(ctx context.Context, id string, masterKey []byte) (*models.Envelope, error) {
+func (f *fakeES) Get(ctx context.Context, id string, vaultKey []byte) (*models.Envelope, error) {
 	f.getID = id
-	f.getMK = masterKey
+	f.getMK = vaultKey
 	return f.getOut, f.getErr
 }
 func (f *fakeES) GetPresignedGetUrl(ctx context.Context, id string) (string, error) {
@@ -109,6 +155,44 @@ func (f *fakeES) GetFile(ctx context.Context, id string) (*models.File, error) {
 	return f.getFile, f.getFileErr
 }
 
+func (f *fakeES) History(ctx context.Context, id string, vaultKey []byte) ([]models.ViewRevision, error) {
+	return f.history, f.historyErr
+}
+func (f *fakeES) Restore(ctx context.Context, id string, version int64, vaultKey []byte) error {
+	f.restoredID = id
+	f.restoreVersion = version
+	return nil
+}
+func (f *fakeES) ListTrash(ctx context.Context, vaultKey []byte) ([]models.ViewOverview, error) {
+	return f.trash, nil
+}
+func (f *fakeES) Undelete(ctx context.Context, id string) error {
+	f.undeleted = id
+	return nil
+}
+func (f *fakeES) Purge(ctx context.Context, id string) error {
+	f.purgedID = id
+	return f.purgeErr
+}
+func (f *fakeES) MigrateFileKeys(ctx context.Context, vaultKey []byte) (int, error) {
+	f.migrateMK = vaultKey
+	return 0, f.migrateErr
+}
+func (f *fakeES) ResealEntries(ctx context.Context, vaultKey []byte) (int, error) {
+	f.resealMK = vaultKey
+	return 0, nil
+}
+func (f *fakeES) ListConflicts(ctx context.Context, vaultKey []byte) ([]models.Conflict, error) {
+	return f.conflicts, f.conflictsErr
+}
+func (f *fakeES) ResolveConflict(ctx context.Context, id string, env models.Envelope, deleted bool, vaultKey []byte) error {
+	f.resolveCallCount++
+	f.resolvedID = id
+	f.resolvedEnv = env
+	f.resolvedDeleted = deleted
+	return nil
+}
+
 // ------------ tests ------------
 
 func TestAddNote_EnvelopeIsPassed(t *testing.T) {
@@ -127,7 +211,7 @@ func TestAddNote_EnvelopeIsPassed(t *testing.T) {
 		t.Fatalf("Add not called exactly once, got %d", es.addCount)
 	}
 	if len(es.addMK) == 0 {
-		t.Fatalf("masterKey not propagated")
+		t.Fatalf("vaultKey not propagated")
 	}
 	if es.addEnv.Type != models.EntryTypeNote {
 		t.Fatalf("Envelope.Type: want TypeNote, got %v", es.addEnv.Type)
@@ -183,6 +267,8 @@ func TestAddCreditCard_EnvelopeIsPassed(t *testing.T) {
 }
 
 func TestAddFile_PassesFileAndEnvelope(t *testing.T) {
+	t.Chdir(t.TempDir())
+
 	es := &fakeES{}
 
 	dir := t.TempDir()
@@ -221,7 +307,7 @@ func TestList_OK(t *testing.T) {
 		t.Fatalf("List err: %v", err)
 	}
 	if len(es.listMK) == 0 {
-		t.Fatalf("masterKey not passed to List")
+		t.Fatalf("vaultKey not passed to List")
 	}
 }
 
@@ -253,6 +339,101 @@ func TestShow_Note_And_File(t *testing.T) {
 
 }
 
+func TestShow_FileUnwrapsKey(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	src := filepath.Join(t.TempDir(), "doc.txt")
+	require.NoError(t, os.WriteFile(src, []byte("secret"), 0o600))
+	var ct bytes.Buffer
+	key, nonce, err := cryptox.EncryptFile(&ct, src, cryptox.EntryAAD("f1", cryptox.FieldFile))
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(ct.Bytes())
+	}))
+	defer srv.Close()
+
+	mk := make([]byte, 32)
+	wrapped, err := cryptox.WrapKey(key, mk)
+	require.NoError(t, err)
+
+	es := &fakeES{
+		getOut: &models.Envelope{
+			Type:    models.EntryTypeBinaryFile,
+			Title:   "Doc",
+			Details: mustJSON(t, models.BinaryFile{Path: src}),
+		},
+		getURL:  srv.URL,
+		getFile: &models.File{EncryptedFileKey: wrapped, Nonce: nonce},
+	}
+	app := newTestApp(es, readerFromLines("f1"), mk)
+
+	require.NoError(t, app.Show(context.Background()))
+	got, err := os.ReadFile(filepath.Join("download", "doc.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "secret", string(got))
+	// the partial download is gone
+	parts, err := filepath.Glob(filepath.Join("download", ".*.part"))
+	require.NoError(t, err)
+	require.Empty(t, parts)
+
+	// a key wrapped under another vault key is rejected
+	app.vaultKey = make([]byte, 32)
+	app.vaultKey[0] = 1
+	app.reader = readerFromLines("f1")
+	require.Error(t, app.Show(context.Background()))
+}
+
+func TestShow_FileChecksDigest(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	src := filepath.Join(t.TempDir(), "doc.txt")
+	require.NoError(t, os.WriteFile(src, []byte("secret"), 0o600))
+	var ct bytes.Buffer
+	key, nonce, err := cryptox.EncryptFile(&ct, src, cryptox.EntryAAD("f1", cryptox.FieldFile))
+	require.NoError(t, err)
+	digest, err := cryptox.FileDigest(bytes.NewReader(ct.Bytes()))
+	require.NoError(t, err)
+
+	mk := make([]byte, 32)
+	wrapped, err := cryptox.WrapKey(key, mk)
+	require.NoError(t, err)
+
+	served := ct.Bytes()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(served))
+	}))
+	defer srv.Close()
+
+	es := &fakeES{
+		getOut: &models.Envelope{
+			Type:    models.EntryTypeBinaryFile,
+			Title:   "Doc",
+			Details: mustJSON(t, models.BinaryFile{Path: src}),
+		},
+		getURL:  srv.URL,
+		getFile: &models.File{EncryptedFileKey: wrapped, Nonce: nonce, Digest: digest},
+	}
+	app := newTestApp(es, readerFromLines("f1"), mk)
+
+	// a download resumes from the partial ciphertext left by an earlier one
+	require.NoError(t, os.MkdirAll("download", 0o700))
+	partial := filepath.Join("download", fmt.Sprintf(".f1-%x.part", nonce))
+	require.NoError(t, os.WriteFile(partial, ct.Bytes()[:10], 0o600))
+	require.NoError(t, app.Show(context.Background()))
+	got, err := os.ReadFile(filepath.Join("download", "doc.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "secret", string(got))
+
+	// a damaged blob is reported as corrupt before decryption, and dropped
+	served = append([]byte{}, ct.Bytes()...)
+	served[len(served)-1] ^= 1
+	app.reader = readerFromLines("f1")
+	require.ErrorIs(t, app.Show(context.Background()), cryptox.ErrCorrupt)
+	_, err = os.Stat(partial)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
 func TestDelete_And_Sync_OK(t *testing.T) {
 	es := &fakeES{}
 	app := newTestApp(es, readerFromLines("777"), []byte("mk"))
@@ -270,6 +451,9 @@ func TestDelete_And_Sync_OK(t *testing.T) {
 	if !es.syncCalled {
 		t.Fatalf("Sync not called")
 	}
+	if string(es.resealMK) != "mk" {
+		t.Fatalf("entries not re-sealed with the vault key before sync")
+	}
 }
 
 func TestShow_ErrorPropagates(t *testing.T) {
@@ -279,3 +463,163 @@ func TestShow_ErrorPropagates(t *testing.T) {
 		t.Fatalf("want error from Get to propagate")
 	}
 }
+
+func TestConflicts_MergePicksFieldsPerSide(t *testing.T) {
+	local, _ := models.Wrap(models.EntryTypeLogin, "mail", nil, models.Login{Username: "bob", Password: "local-pw", URL: "a"})
+	remote, _ := models.Wrap(models.EntryTypeLogin, "mail", nil, models.Login{Username: "bob", Password: "remote-pw", URL: "b"})
+	es := &fakeES{conflicts: []models.Conflict{{Id: "e1", Local: &local, Remote: &remote, RemoteVersion: 3}}}
+
+	// invalid choice is asked again; then merge: password from remote, URL from local
+	app := newTestApp(es, readerFromLines("x", "m", "r", "l"), nil)
+	require.NoError(t, app.Conflicts(context.Background()))
+
+	require.Equal(t, 1, es.resolveCallCount)
+	require.Equal(t, "e1", es.resolvedID)
+	require.False(t, es.resolvedDeleted)
+	x, err := es.resolvedEnv.Unwrap()
+	require.NoError(t, err)
+	require.Equal(t, models.Login{Username: "bob", Password: "remote-pw", URL: "a"}, x)
+}
+
+func TestConflicts_RemoteTombstoneAndSkip(t *testing.T) {
+	note, _ := models.Wrap(models.EntryTypeNote, "n", nil, models.Note{Text: "local"})
+	gone, _ := models.Wrap(models.EntryTypeNote, "n", nil, models.Note{Text: "remote"})
+	es := &fakeES{conflicts: []models.Conflict{
+		{Id: "e1", Local: &note, Remote: &gone, RemoteDeleted: true},
+		{Id: "e2", Local: &note, Remote: &gone},
+	}}
+
+	// merge is not offered for a deleted copy, so "m" is asked again
+	app := newTestApp(es, readerFromLines("m", "r", "s"), nil)
+	require.NoError(t, app.Conflicts(context.Background()))
+
+	require.Equal(t, 1, es.resolveCallCount)
+	require.Equal(t, "e1", es.resolvedID)
+	require.True(t, es.resolvedDeleted)
+}
+
+func TestConflicts_ListErrorPropagates(t *testing.T) {
+	es := &fakeES{conflictsErr: errors.New("boom")}
+	app := newTestApp(es, readerFromLines(), nil)
+	require.Error(t, app.Conflicts(context.Background()))
+}
+
+func TestEdit_Login_KeepsDefaultsAndEditsMetadata(t *testing.T) {
+	env, _ := models.Wrap(models.EntryTypeLogin, "mail",
+		[]models.Metadata{{Name: "site", Value: "a"}, {Name: "tag", Value: "x"}},
+		models.Login{Username: "bob", Password: "old", URL: "u"})
+	es := &fakeES{getOut: &env}
+
+	r := readerFromLines(
+		"",         // title: keep
+		"",         // username: keep
+		"new",      // password
+		"",         // URL: keep
+		"site=b",   // first metadata line replaced
+		"-",        // second metadata line removed
+		"owner=me", // additional metadata
+		"",
+	)
+	app := newTestApp(es, r, []byte("mk"))
+	require.NoError(t, app.Edit(context.Background(), "e1"))
+
+	require.Equal(t, "e1", es.getID)
+	require.Equal(t, "e1", es.updID)
+	require.Nil(t, es.updFile)
+	require.Equal(t, "mail", es.updEnv.Title)
+	require.Equal(t, []models.Metadata{{Name: "site", Value: "b"}, {Name: "owner", Value: "me"}}, es.updEnv.Metadata)
+	x, err := es.updEnv.Unwrap()
+	require.NoError(t, err)
+	require.Equal(t, models.Login{Username: "bob", Password: "new", URL: "u"}, x)
+}
+
+func TestEdit_PromptsForIDAndKeepsNote(t *testing.T) {
+	env, _ := models.Wrap(models.EntryTypeNote, "n", nil, models.Note{Text: "body"})
+	es := &fakeES{getOut: &env}
+
+	r := readerFromLines("e2", "renamed", "", "", "")
+	app := newTestApp(es, r, []byte("mk"))
+	require.NoError(t, app.Edit(context.Background(), ""))
+
+	require.Equal(t, "e2", es.updID)
+	require.Equal(t, "renamed", es.updEnv.Title)
+	x, err := es.updEnv.Unwrap()
+	require.NoError(t, err)
+	require.Equal(t, models.Note{Text: "body"}, x)
+}
+
+func TestEdit_BinaryFile_ReplacesAttachment(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	env, _ := models.Wrap(models.EntryTypeBinaryFile, "doc", nil, models.BinaryFile{Path: "/old.bin"})
+	es := &fakeES{getOut: &env}
+
+	dir := t.TempDir()
+	fp := filepath.Join(dir, "new.bin")
+	require.NoError(t, os.WriteFile(fp, []byte{1, 2, 3}, 0o600))
+
+	r := readerFromLines("", fp, "")
+	app := newTestApp(es, r, []byte("mk"))
+	require.NoError(t, app.Edit(context.Background(), "e3"))
+
+	require.NotNil(t, es.updFile)
+	require.NotEmpty(t, es.updFile.LocalPath)
+	x, err := es.updEnv.Unwrap()
+	require.NoError(t, err)
+	require.Equal(t, models.BinaryFile{Path: fp}, x)
+}
+
+func TestEdit_GetErrorPropagates(t *testing.T) {
+	es := &fakeES{getErr: errors.New("nope")}
+	app := newTestApp(es, readerFromLines(), []byte("mk"))
+	require.Error(t, app.Edit(context.Background(), "missing"))
+	require.Empty(t, es.updID)
+}
+
+func TestHistory_PrintsRevisions(t *testing.T) {
+	v2, _ := models.Wrap(models.EntryTypeLogin, "mail", nil, models.Login{Password: "new"})
+	v1, _ := models.Wrap(models.EntryTypeLogin, "mail", nil, models.Login{Password: "old"})
+	es := &fakeES{history: []models.ViewRevision{
+		{Version: 2, Envelope: &v2},
+		{Version: 1, Envelope: &v1},
+	}}
+	app := newTestApp(es, readerFromLines(), nil)
+	require.NoError(t, app.History(context.Background(), "e1"))
+
+	es.historyErr = errors.New("offline")
+	require.Error(t, app.History(context.Background(), "e1"))
+}
+
+func TestRestore_ParsesVersion(t *testing.T) {
+	es := &fakeES{}
+	app := newTestApp(es, readerFromLines(), nil)
+
+	require.NoError(t, app.Restore(context.Background(), "e1", "3"))
+	require.Equal(t, "e1", es.restoredID)
+	require.Equal(t, int64(3), es.restoreVersion)
+
+	// a leading "v" as printed by history is accepted
+	require.NoError(t, app.Restore(context.Background(), "e2", "v4"))
+	require.Equal(t, "e2", es.restoredID)
+	require.Equal(t, int64(4), es.restoreVersion)
+
+	require.Error(t, app.Restore(context.Background(), "e1", "latest"))
+}
+
+func TestTrash_RestoreAndPurge(t *testing.T) {
+	es := &fakeES{trash: []models.ViewOverview{{Id: "d1", Type: "note", Title: "old"}}}
+	app := newTestApp(es, readerFromLines("d2"), nil)
+
+	require.NoError(t, app.Trash(context.Background()))
+
+	// without a version, restore takes the entry out of the trash
+	require.NoError(t, app.Restore(context.Background(), "d1", ""))
+	require.Equal(t, "d1", es.undeleted)
+	require.Empty(t, es.restoredID)
+
+	require.NoError(t, app.Purge(context.Background(), ""))
+	require.Equal(t, "d2", es.purgedID)
+
+	es.purgeErr = errors.New("not synced")
+	require.Error(t, app.Purge(context.Background(), "d1"))
+}
@@ -9,14 +9,14 @@ import (
 func TestIsLoggedIn_NilMasterKey(t *testing.T) {
 	app := &App{}
 	if app.isLoggedIn() {
-		t.Fatalf("expected isLoggedIn() == false when masterKey is nil")
+		t.Fatalf("expected isLoggedIn() == false when vaultKey is nil")
 	}
 }
 
 func TestIsLoggedIn_NonNilMasterKey(t *testing.T) {
-	app := &App{masterKey: []byte{1, 2, 3}}
+	app := &App{vaultKey: []byte{1, 2, 3}}
 	if !app.isLoggedIn() {
-		t.Fatalf("expected isLoggedIn() == true when masterKey is not nil")
+		t.Fatalf("expected isLoggedIn() == true when vaultKey is not nil")
 	}
 }
 
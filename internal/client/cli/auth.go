@@ -1,20 +1,28 @@
 package cli
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/client/client"
 	"github.com/dmitrijs2005/gophkeeper/internal/common"
 )
 
-// getSimpleText and getPassword are indirections used to facilitate testing.
-// They point to interactive input helpers and can be swapped in tests.
+// getSimpleText, getPassword and getPasswordWithPrompt are indirections used
+// to facilitate testing. They point to interactive input helpers and can be
+// swapped in tests.
 var getSimpleText = GetSimpleText
 var getPassword = GetPassword
+var getPasswordWithPrompt = GetPasswordWithPrompt
+
+// errPasswordMismatch is returned by Passwd when the new password is not
+// repeated correctly.
+var errPasswordMismatch = errors.New("passwords do not match")
 
 // Register prompts the user for an email and password and attempts to create
 // a new account via the AuthService.
@@ -44,9 +52,10 @@ func (a *App) Register(ctx context.Context) error {
 
 // Login prompts the user for credentials and tries to authenticate.
 //
-// The method first attempts an online login. If the server is unavailable
+// The method first attempts an online login, which also prompts for a TOTP
+// or recovery code if the account has two-factor authentication. If the server is unavailable
 // (errors.Is(err, client.ErrUnavailable)), it falls back to offline login.
-// On success it sets a.masterKey and updates connectivity Mode:
+// On success it sets a.vaultKey and updates connectivity Mode:
 //   - ModeOnline if online login succeeds,
 //   - ModeOffline if offline login succeeds,
 //   - ModeDisabled if both fail.
@@ -55,27 +64,27 @@ func (a *App) Register(ctx context.Context) error {
 // underlying auth calls is returned; note that a nil error does not
 // necessarily imply ModeOnline—inspect App.Mode for the final state.
 func (a *App) Login(ctx context.Context) error {
-	userName, err := GetSimpleText(a.reader, "Enter email", os.Stdout)
+	userName, err := getSimpleText(a.reader, "Enter email", os.Stdout)
 	if err != nil {
 		return err
 	}
 
-	password, err := GetPassword(os.Stdout)
+	password, err := getPassword(os.Stdout)
 	if err != nil {
 		return err
 	}
 	defer common.WipeByteArray(password)
 
 	var (
-		masterKey []byte
-		mode      Mode
+		vaultKey []byte
+		mode     Mode
 	)
 
-	masterKey, err = a.authService.OnlineLogin(ctx, userName, password)
+	vaultKey, err = a.authService.OnlineLogin(ctx, userName, password, a.promptSecondFactor)
 	if err != nil {
 		if errors.Is(err, client.ErrUnavailable) {
 			log.Printf("Server unavailable, trying offline login...")
-			masterKey, err = a.authService.OfflineLogin(ctx, userName, password)
+			vaultKey, err = a.authService.OfflineLogin(ctx, userName, password)
 			if err != nil {
 				log.Printf("Offline login unsuccessfull: %s", err.Error())
 				mode = ModeDisabled
@@ -84,24 +93,219 @@ func (a *App) Login(ctx context.Context) error {
 				mode = ModeOffline
 			}
 		} else {
-			log.Printf("Login unsuccessfull: %s", err.Error())
+			var rateLimited *client.RateLimitError
+			if errors.As(err, &rateLimited) && rateLimited.RetryAfter > 0 {
+				log.Printf("Too many login attempts, try again in %s", rateLimited.RetryAfter)
+			} else {
+				log.Printf("Login unsuccessfull: %s", err.Error())
+			}
 		}
 	} else {
 		log.Printf("Login successfull")
 		mode = ModeOnline
+		a.migrateFileKeys(ctx, vaultKey)
 	}
 
-	a.masterKey = masterKey
+	a.vaultKey = vaultKey
 	a.setMode(mode)
 	return nil
 }
 
-// Logout clears locally cached offline data and removes the in-memory
-// masterKey. It returns any error from the AuthService cleanup.
+// promptSecondFactor asks for the code of an account with two-factor
+// authentication during an online login.
+func (a *App) promptSecondFactor(ctx context.Context) (string, error) {
+	return getSimpleText(a.reader, "Enter the 6-digit code from your authenticator app (or a recovery code)", os.Stdout)
+}
+
+// TwoFactor enables two-factor authentication of the account: it prints a
+// new TOTP secret and its otpauth URI for an authenticator app, asks for a
+// code from the app to confirm it and prints the one-time recovery codes.
+// Running it again replaces the secret and the recovery codes, after asking
+// for a current code of the old secret or a recovery code; until the new
+// secret is confirmed the old one keeps working. Offline logins stay
+// password-only.
+func (a *App) TwoFactor(ctx context.Context) error {
+	if err := a.twoFactor(ctx); err != nil {
+		if errors.Is(err, client.ErrInvalidCode) {
+			log.Printf("Invalid code, run 2fa again to start over")
+		} else {
+			log.Printf("error: %v", err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (a *App) twoFactor(ctx context.Context) error {
+	secret, uri, err := a.authService.Enable2FA(ctx, "")
+	if errors.Is(err, client.ErrSecondFactorRequired) {
+		var current string
+		current, err = getSimpleText(a.reader, "Two-factor authentication is enabled. Enter a current code from your authenticator app (or a recovery code)", os.Stdout)
+		if err != nil {
+			return err
+		}
+		secret, uri, err = a.authService.Enable2FA(ctx, current)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("Add this account to your authenticator app with the URI")
+	fmt.Println("  " + uri)
+	fmt.Println("or enter the secret manually:")
+	fmt.Println("  " + secret)
+
+	code, err := getSimpleText(a.reader, "Enter the 6-digit code from the app to confirm", os.Stdout)
+	if err != nil {
+		return err
+	}
+	codes, err := a.authService.Confirm2FA(ctx, code)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Two-factor authentication enabled. Keep these recovery codes in a safe")
+	fmt.Println("place; each of them replaces a code from the app once:")
+	for _, c := range codes {
+		fmt.Println("  " + c)
+	}
+	return nil
+}
+
+// Passwd changes the password of the logged-in account. It prompts for the
+// current password and twice for the new one, and then lets the AuthService
+// re-wrap the vault key under the new password, or replace it if it was
+// derived from the old password. The session stays unlocked with the
+// returned vault key; other devices have to log in with the new password.
+// Passwords are securely wiped before returning.
+func (a *App) Passwd(ctx context.Context) error {
+	if err := a.passwd(ctx); err != nil {
+		log.Printf("error: %v", err)
+		return err
+	}
+	fmt.Println("Password changed. Other devices have to log in again.")
+	return nil
+}
+
+func (a *App) passwd(ctx context.Context) error {
+	oldPassword, err := getPasswordWithPrompt("Current password", os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer common.WipeByteArray(oldPassword)
+
+	newPassword, err := getPasswordWithPrompt("New password", os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer common.WipeByteArray(newPassword)
+
+	repeated, err := getPasswordWithPrompt("Repeat new password", os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer common.WipeByteArray(repeated)
+
+	if !bytes.Equal(newPassword, repeated) {
+		return errPasswordMismatch
+	}
+	vaultKey, err := a.authService.ChangePassword(ctx, oldPassword, newPassword)
+	if err != nil {
+		return err
+	}
+	a.vaultKey = vaultKey
+	return nil
+}
+
+// Sessions prints the devices logged into the account, most recently used
+// first: the session ID to revoke it with, the device, the address and time
+// it was last used from, and a marker on this device's session.
+func (a *App) Sessions(ctx context.Context) error {
+	sessions, err := a.authService.ListSessions(ctx)
+	if err != nil {
+		log.Printf("error: %v", err)
+		return err
+	}
+	for _, s := range sessions {
+		line := fmt.Sprintf("%s  %s (%s, %s)  %s  last used %s",
+			s.ID, orDash(s.DeviceName), orDash(s.Platform), orDash(s.ClientVersion),
+			orDash(s.IP), s.LastUsedAt.Local().Format(time.DateTime))
+		if s.Current {
+			line += "  (this device)"
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// Revoke logs a device out by its session ID, as printed by Sessions, or
+// every other device if id is "others". The device's access tokens stop
+// working right away, not only when they expire.
+//
+// The ID is prompted for when id is empty.
+func (a *App) Revoke(ctx context.Context, id string) error {
+	if err := a.revoke(ctx, id); err != nil {
+		log.Printf("error: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (a *App) revoke(ctx context.Context, id string) error {
+	var err error
+	if id == "" {
+		id, err = getSimpleText(a.reader, "Enter session id (or others)", os.Stdout)
+		if err != nil {
+			return err
+		}
+	}
+
+	if id == "others" {
+		n, err := a.authService.RevokeOtherSessions(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Logged out %d other device(s)\n", n)
+		return nil
+	}
+	if err := a.authService.RevokeSession(ctx, id); err != nil {
+		return err
+	}
+	fmt.Println("Session revoked")
+	return nil
+}
+
+// orDash returns s, or "-" for values a device did not report.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// migrateFileKeys wraps file keys stored by older versions in plaintext.
+// Failures are only logged; the migration is retried on the next login.
+func (a *App) migrateFileKeys(ctx context.Context, vaultKey []byte) {
+	n, err := a.entryService.MigrateFileKeys(ctx, vaultKey)
+	if err != nil {
+		log.Printf("error migrating file keys: %v", err)
+	}
+	if n > 0 {
+		log.Printf("Wrapped %d file key(s) with the vault key", n)
+	}
+}
+
+// Logout ends this device's session on the server, clears locally cached
+// offline data and removes the in-memory vaultKey. Failing to reach the
+// server (e.g. in offline mode) does not stop the local logout; the session
+// then stays listed until it expires or is revoked from another device. It
+// returns any error from the AuthService cleanup.
 func (a *App) Logout(ctx context.Context) error {
+	if err := a.authService.Logout(ctx); err != nil {
+		log.Printf("could not log out on the server: %v", err)
+	}
 	if err := a.authService.ClearOfflineData(ctx); err != nil {
 		return err
 	}
-	a.masterKey = nil
+	a.vaultKey = nil
 	return nil
 }
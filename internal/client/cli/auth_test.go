@@ -2,10 +2,19 @@ package cli
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"log"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/client/client"
+	"github.com/dmitrijs2005/gophkeeper/internal/client/models"
+	"github.com/dmitrijs2005/gophkeeper/internal/client/services"
 )
 
 func stubPassword1(t *testing.T, pw []byte) func() {
@@ -15,6 +24,37 @@ func stubPassword1(t *testing.T, pw []byte) func() {
 	return func() { getPassword = orig }
 }
 
+// stubPasswordPrompts answers password prompts with the given passwords in
+// order.
+func stubPasswordPrompts(t *testing.T, passwords ...string) {
+	t.Helper()
+	orig := getPasswordWithPrompt
+	getPasswordWithPrompt = func(_ string, _ io.Writer) ([]byte, error) {
+		if len(passwords) == 0 {
+			return nil, io.EOF
+		}
+		pw := []byte(passwords[0])
+		passwords = passwords[1:]
+		return pw, nil
+	}
+	t.Cleanup(func() { getPasswordWithPrompt = orig })
+}
+
+// stubTextAnswers answers text prompts with the given lines in order.
+func stubTextAnswers(t *testing.T, lines ...string) {
+	t.Helper()
+	orig := getSimpleText
+	getSimpleText = func(_ *bufio.Reader, _ string, _ io.Writer) (string, error) {
+		if len(lines) == 0 {
+			return "", io.EOF
+		}
+		line := lines[0]
+		lines = lines[1:]
+		return line, nil
+	}
+	t.Cleanup(func() { getSimpleText = orig })
+}
+
 func stubInputs(t *testing.T, username string, password []byte) func() {
 	t.Helper()
 	origST, origGP := getSimpleText, getPassword
@@ -37,6 +77,10 @@ type fakeAuth struct {
 	onlinePass []byte
 	onlineMK   []byte
 	onlineErr  error
+	// secondFactor, if set, is asked for a code like by an account with
+	// two-factor authentication
+	secondFactor bool
+	onlineCode   string
 
 	// OfflineLogin
 	offlineUser string
@@ -47,14 +91,44 @@ type fakeAuth struct {
 	// ClearOfflineData
 	clearCalled bool
 	clearErr    error
+
+	// ChangePassword
+	changeOld []byte
+	changeNew []byte
+	changeVK  []byte
+	changeErr error
+
+	// sessions
+	sessions      []*models.Session
+	revoked       string
+	revokedOthers bool
+	sessionErr    error
+
+	// Logout
+	loggedOut bool
+	logoutErr error
+
+	// 2FA
+	currentCode   string // if set, Enable2FA requires it
+	enableCodes   []string
+	confirmCode   string
+	recoveryCodes []string
+	twoFactorErr  error
 }
 
 func (f *fakeAuth) Register(_ context.Context, user string, pass []byte) error {
 	f.regUser, f.regPass = user, append([]byte(nil), pass...)
 	return f.regErr
 }
-func (f *fakeAuth) OnlineLogin(_ context.Context, user string, pass []byte) ([]byte, error) {
+func (f *fakeAuth) OnlineLogin(ctx context.Context, user string, pass []byte, secondFactor services.SecondFactorPrompt) ([]byte, error) {
 	f.onlineUser, f.onlinePass = user, append([]byte(nil), pass...)
+	if f.secondFactor {
+		code, err := secondFactor(ctx)
+		if err != nil {
+			return nil, err
+		}
+		f.onlineCode = code
+	}
 	return f.onlineMK, f.onlineErr
 }
 func (f *fakeAuth) OfflineLogin(_ context.Context, user string, pass []byte) ([]byte, error) {
@@ -65,6 +139,39 @@ func (f *fakeAuth) ClearOfflineData(context.Context) error {
 	f.clearCalled = true
 	return f.clearErr
 }
+func (f *fakeAuth) ChangePassword(_ context.Context, oldPassword, newPassword []byte) ([]byte, error) {
+	f.changeOld, f.changeNew = append([]byte(nil), oldPassword...), append([]byte(nil), newPassword...)
+	return f.changeVK, f.changeErr
+}
+func (f *fakeAuth) ListSessions(context.Context) ([]*models.Session, error) {
+	return f.sessions, f.sessionErr
+}
+func (f *fakeAuth) RevokeSession(_ context.Context, id string) error {
+	f.revoked = id
+	return f.sessionErr
+}
+func (f *fakeAuth) RevokeOtherSessions(context.Context) (int64, error) {
+	f.revokedOthers = true
+	return 1, f.sessionErr
+}
+func (f *fakeAuth) Logout(context.Context) error {
+	f.loggedOut = true
+	return f.logoutErr
+}
+func (f *fakeAuth) Enable2FA(_ context.Context, code string) (string, string, error) {
+	f.enableCodes = append(f.enableCodes, code)
+	if f.currentCode != "" && code != f.currentCode {
+		if code == "" {
+			return "", "", client.ErrSecondFactorRequired
+		}
+		return "", "", client.ErrInvalidCode
+	}
+	return "SECRET", "otpauth://totp/GophKeeper:alice", nil
+}
+func (f *fakeAuth) Confirm2FA(_ context.Context, code string) ([]string, error) {
+	f.confirmCode = code
+	return f.recoveryCodes, f.twoFactorErr
+}
 func (f *fakeAuth) Close(ctx context.Context) error { return nil }
 func (f *fakeAuth) Ping(ctx context.Context) error  { return nil }
 
@@ -88,15 +195,29 @@ func TestRegister_Success(t *testing.T) {
 
 func TestLogout(t *testing.T) {
 	f := &fakeAuth{}
-	a := &App{authService: f, masterKey: []byte("something")}
+	a := &App{authService: f, vaultKey: []byte("something")}
 	if err := a.Logout(context.Background()); err != nil {
 		t.Fatalf("Logout err: %v", err)
 	}
+	if !f.loggedOut {
+		t.Fatalf("server session not ended")
+	}
 	if !f.clearCalled {
 		t.Fatalf("ClearOfflineData not called")
 	}
-	if a.masterKey != nil {
-		t.Fatalf("masterKey not cleared")
+	if a.vaultKey != nil {
+		t.Fatalf("vaultKey not cleared")
+	}
+}
+
+func TestLogout_OfflineStillClearsLocalData(t *testing.T) {
+	f := &fakeAuth{logoutErr: client.ErrUnavailable}
+	a := &App{authService: f, vaultKey: []byte("something")}
+	if err := a.Logout(context.Background()); err != nil {
+		t.Fatalf("Logout err: %v", err)
+	}
+	if !f.clearCalled || a.vaultKey != nil {
+		t.Fatalf("local data not cleared")
 	}
 }
 
@@ -107,3 +228,163 @@ func TestLogout_ErrorPropagates(t *testing.T) {
 		t.Fatalf("want error from ClearOfflineData")
 	}
 }
+
+func TestLogin_OnlineMigratesFileKeys(t *testing.T) {
+	f := &fakeAuth{onlineMK: []byte("mk")}
+	es := &fakeES{migrateErr: errors.New("offline")}
+	a := &App{authService: f, entryService: es}
+
+	restore := stubInputs(t, "alice@example.org", []byte("secret"))
+	defer restore()
+
+	// a failed migration does not fail the login
+	if err := a.Login(context.Background()); err != nil {
+		t.Fatalf("Login err: %v", err)
+	}
+	if string(es.migrateMK) != "mk" {
+		t.Fatalf("MigrateFileKeys not called with vault key: %q", es.migrateMK)
+	}
+	if string(a.vaultKey) != "mk" {
+		t.Fatalf("vaultKey not set")
+	}
+}
+
+func TestLogin_RateLimitedShowsWait(t *testing.T) {
+	f := &fakeAuth{onlineErr: fmt.Errorf("login error: %w", &client.RateLimitError{RetryAfter: 42 * time.Second})}
+	a := &App{authService: f}
+
+	restore := stubInputs(t, "alice@example.org", []byte("secret"))
+	defer restore()
+
+	var buf bytes.Buffer
+	old := log.Default().Writer()
+	defer log.SetOutput(old)
+	log.SetOutput(&buf)
+
+	if err := a.Login(context.Background()); err != nil {
+		t.Fatalf("Login err: %v", err)
+	}
+	if got := buf.String(); !strings.Contains(got, "try again in 42s") || f.offlineUser != "" {
+		t.Fatalf("log %q, offline login tried: %v", got, f.offlineUser != "")
+	}
+	if a.vaultKey != nil {
+		t.Fatalf("vaultKey set")
+	}
+}
+
+func TestLogin_AsksForSecondFactor(t *testing.T) {
+	f := &fakeAuth{onlineMK: []byte("mk"), secondFactor: true}
+	a := &App{authService: f, entryService: &fakeES{}}
+
+	stubTextAnswers(t, "alice@example.org", "123456")
+	defer stubPassword1(t, []byte("secret"))()
+
+	if err := a.Login(context.Background()); err != nil {
+		t.Fatalf("Login err: %v", err)
+	}
+	if f.onlineCode != "123456" || string(a.vaultKey) != "mk" {
+		t.Fatalf("code %q, vaultKey %q", f.onlineCode, a.vaultKey)
+	}
+}
+
+func TestTwoFactor(t *testing.T) {
+	f := &fakeAuth{recoveryCodes: []string{"AAAA-BBBB"}}
+	a := &App{authService: f}
+
+	stubTextAnswers(t, "123456", "654321")
+	if err := a.TwoFactor(context.Background()); err != nil || f.confirmCode != "123456" {
+		t.Fatalf("TwoFactor: %v, code %q", err, f.confirmCode)
+	}
+
+	f.twoFactorErr = client.ErrInvalidCode
+	if err := a.TwoFactor(context.Background()); !errors.Is(err, client.ErrInvalidCode) {
+		t.Fatalf("TwoFactor: want ErrInvalidCode, got %v", err)
+	}
+}
+
+func TestTwoFactor_ReplaceAsksForCurrentCode(t *testing.T) {
+	f := &fakeAuth{recoveryCodes: []string{"AAAA-BBBB"}, currentCode: "111111"}
+	a := &App{authService: f}
+
+	stubTextAnswers(t, "111111", "123456")
+	if err := a.TwoFactor(context.Background()); err != nil || f.confirmCode != "123456" {
+		t.Fatalf("TwoFactor: %v, code %q", err, f.confirmCode)
+	}
+	if len(f.enableCodes) != 2 || f.enableCodes[0] != "" || f.enableCodes[1] != "111111" {
+		t.Fatalf("Enable2FA codes: %q", f.enableCodes)
+	}
+
+	// a wrong current code stops before anything is enrolled
+	f.enableCodes, f.confirmCode = nil, ""
+	stubTextAnswers(t, "000000", "123456")
+	if err := a.TwoFactor(context.Background()); !errors.Is(err, client.ErrInvalidCode) || f.confirmCode != "" {
+		t.Fatalf("TwoFactor: want ErrInvalidCode, got %v, code %q", err, f.confirmCode)
+	}
+}
+
+func TestPasswd_Success(t *testing.T) {
+	f := &fakeAuth{changeVK: []byte("new-vk")}
+	a := &App{authService: f, vaultKey: []byte("vk")}
+	stubPasswordPrompts(t, "old", "new", "new")
+
+	if err := a.Passwd(context.Background()); err != nil {
+		t.Fatalf("Passwd err: %v", err)
+	}
+	if string(f.changeOld) != "old" || string(f.changeNew) != "new" {
+		t.Fatalf("ChangePassword got %q -> %q", f.changeOld, f.changeNew)
+	}
+	if string(a.vaultKey) != "new-vk" {
+		t.Fatalf("session must stay unlocked with the returned vault key, got %q", a.vaultKey)
+	}
+}
+
+func TestPasswd_MismatchAndServiceError(t *testing.T) {
+	f := &fakeAuth{}
+	a := &App{authService: f}
+
+	stubPasswordPrompts(t, "old", "new", "typo")
+	if err := a.Passwd(context.Background()); !errors.Is(err, errPasswordMismatch) {
+		t.Fatalf("want errPasswordMismatch, got %v", err)
+	}
+	if f.changeNew != nil {
+		t.Fatalf("ChangePassword must not be called on mismatch")
+	}
+
+	f.changeErr = errors.New("unauthorized")
+	stubPasswordPrompts(t, "old", "new", "new")
+	if err := a.Passwd(context.Background()); err == nil {
+		t.Fatalf("want error from ChangePassword")
+	}
+}
+
+func TestSessionsAndRevoke(t *testing.T) {
+	f := &fakeAuth{sessions: []*models.Session{
+		{ID: "s1", DeviceName: "laptop", Platform: "linux/amd64", IP: "10.0.0.1", LastUsedAt: time.Now(), Current: true},
+		{ID: "s2"},
+	}}
+	a := &App{authService: f}
+
+	if err := a.Sessions(context.Background()); err != nil {
+		t.Fatalf("Sessions err: %v", err)
+	}
+	if err := a.Revoke(context.Background(), "s2"); err != nil || f.revoked != "s2" {
+		t.Fatalf("Revoke: %v, revoked %q", err, f.revoked)
+	}
+	if err := a.Revoke(context.Background(), "others"); err != nil || !f.revokedOthers {
+		t.Fatalf("Revoke others: %v", err)
+	}
+
+	restore := stubInputs(t, "s3", nil)
+	defer restore()
+	if err := a.Revoke(context.Background(), ""); err != nil || f.revoked != "s3" {
+		t.Fatalf("Revoke with prompt: %v, revoked %q", err, f.revoked)
+	}
+
+	f.sessionErr = client.ErrNotFound
+	if err := a.Sessions(context.Background()); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("Sessions: want error, got %v", err)
+	}
+	if err := a.Revoke(context.Background(), "s9"); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("Revoke: want ErrNotFound, got %v", err)
+	}
+}
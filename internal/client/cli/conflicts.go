@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/client/models"
+)
+
+// Conflicts walks through the entries whose local and server copies diverged
+// during sync. For each one it prints the differing fields and lets the user
+// keep the local copy, take the server copy, or merge them field by field.
+// The outcome is stored as a pending change and pushed on the next sync;
+// skipped conflicts stay unresolved.
+func (a *App) Conflicts(ctx context.Context) error {
+	conflicts, err := a.entryService.ListConflicts(ctx, a.vaultKey)
+	if err != nil {
+		log.Printf("error: %v", err)
+		return err
+	}
+	if len(conflicts) == 0 {
+		fmt.Println("No conflicts")
+		return nil
+	}
+	for _, c := range conflicts {
+		if err := a.resolveConflict(ctx, c); err != nil {
+			log.Printf("error: %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveConflict shows a single conflict and applies the user's choice.
+func (a *App) resolveConflict(ctx context.Context, c models.Conflict) error {
+	diffs, err := models.DiffEnvelopes(*c.Local, *c.Remote)
+	if err != nil {
+		return fmt.Errorf("diff %s: %w", c.Id, err)
+	}
+
+	fmt.Printf("Conflict %s (server version %d)\n", c.Id, c.RemoteVersion)
+	if c.LocalDeleted || c.RemoteDeleted {
+		fmt.Printf("  deleted: local=%v server=%v\n", c.LocalDeleted, c.RemoteDeleted)
+	}
+	for _, d := range diffs {
+		fmt.Printf("  %s: local=%s server=%s\n", d.Name, fieldValue(d.Local, d.HasLocal), fieldValue(d.Remote, d.HasRemote))
+	}
+
+	mergeable := len(diffs) > 0 && !c.LocalDeleted && !c.RemoteDeleted && models.Mergeable(*c.Local, *c.Remote)
+	prompt := "Keep (l)ocal, take (r)emote or (s)kip"
+	if mergeable {
+		prompt = "Keep (l)ocal, take (r)emote, (m)erge or (s)kip"
+	}
+
+	for {
+		choice, err := GetSimpleText(a.reader, prompt, os.Stdout)
+		if err != nil {
+			return err
+		}
+		switch {
+		case choice == "l":
+			return a.entryService.ResolveConflict(ctx, c.Id, *c.Local, c.LocalDeleted, a.vaultKey)
+		case choice == "r":
+			return a.entryService.ResolveConflict(ctx, c.Id, *c.Remote, c.RemoteDeleted, a.vaultKey)
+		case choice == "m" && mergeable:
+			merged, err := a.mergeConflict(c, diffs)
+			if err != nil {
+				return err
+			}
+			return a.entryService.ResolveConflict(ctx, c.Id, merged, false, a.vaultKey)
+		case choice == "s":
+			return nil
+		}
+	}
+}
+
+// mergeConflict asks which side to take for every differing field and merges
+// the two copies accordingly.
+func (a *App) mergeConflict(c models.Conflict, diffs []models.FieldDiff) (models.Envelope, error) {
+	takeRemote := make(map[string]bool, len(diffs))
+	for _, d := range diffs {
+		prompt := fmt.Sprintf("%s: (l)ocal %s or (r)emote %s", d.Name, fieldValue(d.Local, d.HasLocal), fieldValue(d.Remote, d.HasRemote))
+		for {
+			choice, err := GetSimpleText(a.reader, prompt, os.Stdout)
+			if err != nil {
+				return models.Envelope{}, err
+			}
+			if choice == "l" || choice == "r" {
+				takeRemote[d.Name] = choice == "r"
+				break
+			}
+		}
+	}
+	return models.MergeEnvelopes(*c.Local, *c.Remote, takeRemote)
+}
+
+// fieldValue formats one side of a FieldDiff for display.
+func fieldValue(v string, ok bool) string {
+	if !ok {
+		return "<none>"
+	}
+	return fmt.Sprintf("%q", v)
+}
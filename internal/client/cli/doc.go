@@ -5,10 +5,17 @@
 // start a background connectivity watcher, and execute user commands.
 //
 // Key features:
-//   - Login / Logout (online with offline fallback)
+//   - Login / Logout (online with offline fallback); an online login also
+//     wraps file keys left in plaintext by older versions
+//   - Change the account password (passwd)
+//   - List the devices logged into the account and log them out (sessions,
+//     revoke)
+//   - Enable TOTP two-factor authentication for online logins (2fa)
 //   - Add entries: notes, logins, credit cards, files
-//   - List / Show entries
-//   - Sync with the server
+//   - List / Show / Edit entries
+//   - Browse an entry's version history and restore old versions
+//   - Trash: list deleted entries, restore them or purge them locally
+//   - Sync with the server and resolve conflicting entries
 //
 // The REPL is started via App.Root(ctx), which blocks until the user exits.
 // See App, StartOnlineStatusWatcher, and runREPL for details.
@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/client/models"
+)
+
+// Edit changes an existing entry in place. Every field, metadata included, is
+// prompted again with its current value as the default, so pressing Enter
+// keeps it. The result is re-encrypted and saved under the same ID as a
+// pending update. For binary files the user may attach a new file, which is
+// staged for upload like in AddFile.
+//
+// The ID is prompted for when id is empty.
+func (a *App) Edit(ctx context.Context, id string) error {
+	if err := a.editEntry(ctx, id); err != nil {
+		log.Printf("error: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (a *App) editEntry(ctx context.Context, id string) error {
+	var err error
+	if id == "" {
+		id, err = GetSimpleText(a.reader, "Enter record id to edit", os.Stdout)
+		if err != nil {
+			return err
+		}
+	}
+
+	envelope, err := a.entryService.Get(ctx, id, a.vaultKey)
+	if err != nil {
+		return err
+	}
+	x, err := envelope.Unwrap()
+	if err != nil {
+		return err
+	}
+
+	title, err := GetTextWithDefault(a.reader, "Enter title", envelope.Title, os.Stdout)
+	if err != nil {
+		return fmt.Errorf("get title: %w", err)
+	}
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return fmt.Errorf("title is required")
+	}
+
+	var (
+		payload models.TypedEntry
+		file    *models.File
+	)
+	switch item := x.(type) {
+	case models.Note:
+		payload, err = a.editNoteDetails(item)
+	case models.Login:
+		payload, err = a.editLoginDetails(item)
+	case models.CreditCard:
+		payload, err = a.editCreditCardDetails(item)
+	case models.BinaryFile:
+		payload, file, err = a.editFileDetails(ctx, id, item)
+	default:
+		return fmt.Errorf("editing %s entries is not supported", envelope.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	metadata, err := a.editMetadata(envelope.Metadata)
+	if err != nil {
+		return err
+	}
+
+	updated, err := models.Wrap(payload.GetType(), title, metadata, payload)
+	if err != nil {
+		return err
+	}
+	return a.entryService.Update(ctx, id, updated, file, a.vaultKey)
+}
+
+// editNoteDetails shows the current note text and reads a replacement; an
+// empty input keeps the current text.
+func (a *App) editNoteDetails(item models.Note) (models.TypedEntry, error) {
+	fmt.Printf("Current note text:\n%s\n", item.Text)
+	text, err := GetMultiline(a.reader, "Enter new note text (empty to keep):", os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	if text != "" {
+		item.Text = text
+	}
+	return &item, nil
+}
+
+// editLoginDetails re-prompts the login credentials.
+func (a *App) editLoginDetails(item models.Login) (models.TypedEntry, error) {
+	var err error
+	if item.Username, err = GetTextWithDefault(a.reader, "Enter username", item.Username, os.Stdout); err != nil {
+		return nil, err
+	}
+	if item.Password, err = GetTextWithDefault(a.reader, "Enter password", item.Password, os.Stdout); err != nil {
+		return nil, err
+	}
+	if item.URL, err = GetTextWithDefault(a.reader, "Enter URL", item.URL, os.Stdout); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// editCreditCardDetails re-prompts the card details.
+func (a *App) editCreditCardDetails(item models.CreditCard) (models.TypedEntry, error) {
+	var err error
+	if item.Number, err = GetTextWithDefault(a.reader, "Enter card number", item.Number, os.Stdout); err != nil {
+		return nil, err
+	}
+	if item.Expiration, err = GetTextWithDefault(a.reader, "Enter expiration", item.Expiration, os.Stdout); err != nil {
+		return nil, err
+	}
+	if item.CVV, err = GetTextWithDefault(a.reader, "Enter CVV", item.CVV, os.Stdout); err != nil {
+		return nil, err
+	}
+	if item.Holder, err = GetTextWithDefault(a.reader, "Enter holder", item.Holder, os.Stdout); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// editFileDetails offers to replace the attached file of entry id. A new
+// path is materialized into a staged upload; an empty input keeps the
+// current file.
+func (a *App) editFileDetails(ctx context.Context, id string, item models.BinaryFile) (models.TypedEntry, *models.File, error) {
+	path, err := GetSimpleText(a.reader, fmt.Sprintf("Enter new file path to replace %s (empty to keep)", item.Path), os.Stdout)
+	if err != nil {
+		return nil, nil, err
+	}
+	if path == "" {
+		return &item, nil, nil
+	}
+
+	item.Path = path
+	item.Unbound = false
+	file, err := item.Materialize(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("materialize: %w", err)
+	}
+	return &item, file, nil
+}
+
+// editMetadata re-prompts every metadata line as "name=value" (Enter keeps
+// it, "-" removes it) and then reads additional lines like InputEnvelope.
+func (a *App) editMetadata(current []models.Metadata) ([]models.Metadata, error) {
+	lines := make([]string, 0, len(current))
+	for _, md := range current {
+		line, err := GetTextWithDefault(a.reader, "Edit metadata (- to remove)", md.Name+"="+md.Value, os.Stdout)
+		if err != nil {
+			return nil, err
+		}
+		if line != "-" {
+			lines = append(lines, line)
+		}
+	}
+
+	more, err := GetMetadata(a.reader)
+	if err != nil {
+		return nil, err
+	}
+	return models.MetadataFromString(append(lines, more...))
+}
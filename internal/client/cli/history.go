@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/client/models"
+)
+
+// History prints the versions the server keeps for an entry, newest first:
+// the version number, when it was stored, its title, and which fields changed
+// compared to the previous version. Field values are not printed.
+//
+// The ID is prompted for when id is empty.
+func (a *App) History(ctx context.Context, id string) error {
+	if err := a.history(ctx, id); err != nil {
+		log.Printf("error: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (a *App) history(ctx context.Context, id string) error {
+	var err error
+	if id == "" {
+		id, err = GetSimpleText(a.reader, "Enter record id", os.Stdout)
+		if err != nil {
+			return err
+		}
+	}
+
+	revs, err := a.entryService.History(ctx, id, a.vaultKey)
+	if err != nil {
+		return err
+	}
+	if len(revs) == 0 {
+		fmt.Println("No history")
+		return nil
+	}
+
+	for i, r := range revs {
+		line := fmt.Sprintf("v%d  %s  %s", r.Version, r.CreatedAt.Local().Format(time.DateTime), r.Envelope.Title)
+		if r.Deleted {
+			line += "  (deleted)"
+		}
+		fmt.Println(line)
+
+		if i+1 < len(revs) {
+			diffs, err := models.DiffEnvelopes(*revs[i+1].Envelope, *r.Envelope)
+			if err != nil {
+				return err
+			}
+			names := make([]string, 0, len(diffs))
+			for _, d := range diffs {
+				names = append(names, d.Name)
+			}
+			if len(names) > 0 {
+				fmt.Printf("    changed: %s\n", strings.Join(names, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// Restore brings back a deleted entry from the trash or, when rev is given,
+// an old version of an entry as listed by History. Either way the result is
+// saved as a new pending change and pushed on the next sync.
+//
+// The ID is prompted for when empty.
+func (a *App) Restore(ctx context.Context, id string, rev string) error {
+	if err := a.restore(ctx, id, rev); err != nil {
+		log.Printf("error: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (a *App) restore(ctx context.Context, id string, rev string) error {
+	var err error
+	if id == "" {
+		id, err = GetSimpleText(a.reader, "Enter record id", os.Stdout)
+		if err != nil {
+			return err
+		}
+	}
+	if rev == "" {
+		if err := a.entryService.Undelete(ctx, id); err != nil {
+			return err
+		}
+		fmt.Println("Restored from trash, run 'sync' to publish it")
+		return nil
+	}
+
+	version, err := strconv.ParseInt(strings.TrimPrefix(rev, "v"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid version %q", rev)
+	}
+	if err := a.entryService.Restore(ctx, id, version, a.vaultKey); err != nil {
+		return err
+	}
+	fmt.Printf("Restored v%d, run 'sync' to publish it\n", version)
+	return nil
+}
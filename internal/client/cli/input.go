@@ -37,13 +37,32 @@ func GetSimpleText(reader *bufio.Reader, prompt string, w io.Writer) (string, er
 	return strings.TrimSpace(line), nil
 }
 
+// GetTextWithDefault works like GetSimpleText but shows the current value in
+// brackets and returns it unchanged when the user enters an empty line.
+func GetTextWithDefault(reader *bufio.Reader, prompt, def string, w io.Writer) (string, error) {
+	line, err := GetSimpleText(reader, fmt.Sprintf("%s [%s]", prompt, def), w)
+	if err != nil {
+		return "", err
+	}
+	if line == "" {
+		return def, nil
+	}
+	return line, nil
+}
+
 // GetPassword prints a password prompt to w and reads a password
 // from the user's terminal without echo. A newline is printed after
 // the read to keep the UI tidy.
 //
 // The returned byte slice should be wiped by the caller when no longer needed.
 func GetPassword(w io.Writer) ([]byte, error) {
-	if _, err := fmt.Fprint(w, "Enter password: "); err != nil {
+	return GetPasswordWithPrompt("Enter password", w)
+}
+
+// GetPasswordWithPrompt works like GetPassword but prints the given prompt,
+// e.g. to tell the current password from a new one.
+func GetPasswordWithPrompt(prompt string, w io.Writer) ([]byte, error) {
+	if _, err := fmt.Fprint(w, prompt+": "); err != nil {
 		return nil, err
 	}
 	pw, err := readPassword(int(os.Stdin.Fd()))
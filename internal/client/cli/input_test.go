@@ -28,6 +28,20 @@ func TestGetSimpleTextEOF(t *testing.T) {
 	}
 }
 
+func TestGetTextWithDefault(t *testing.T) {
+	in := bufio.NewReader(strings.NewReader("\nnew\n"))
+	var out bytes.Buffer
+
+	got, err := GetTextWithDefault(in, "Name?", "old", &out)
+	require.NoError(t, err)
+	require.Equal(t, "old", got)
+	require.Contains(t, out.String(), "Name? [old]")
+
+	got, err = GetTextWithDefault(in, "Name?", "old", &out)
+	require.NoError(t, err)
+	require.Equal(t, "new", got)
+}
+
 func TestGetMultiline_DoubleEnter(t *testing.T) {
 	in := bufio.NewReader(strings.NewReader("a\nb\n\n\n"))
 	var out bytes.Buffer
@@ -54,6 +68,21 @@ func TestGetPassword_Error(t *testing.T) {
 	}
 }
 
+func TestGetPasswordWithPrompt(t *testing.T) {
+	old := readPassword
+	defer func() { readPassword = old }()
+	readPassword = func(int) ([]byte, error) { return []byte("pw"), nil }
+
+	var out bytes.Buffer
+	got, err := GetPasswordWithPrompt("New password", &out)
+	if err != nil || string(got) != "pw" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	if !strings.HasPrefix(out.String(), "New password: ") {
+		t.Fatalf("unexpected prompt %q", out.String())
+	}
+}
+
 func rdr(s string) *bufio.Reader {
 	return bufio.NewReader(strings.NewReader(s))
 }
@@ -3,7 +3,9 @@ package cli
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -13,8 +15,50 @@ import (
 	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
 	"github.com/dmitrijs2005/gophkeeper/internal/filex"
 	"github.com/dmitrijs2005/gophkeeper/internal/netx"
+	"github.com/google/uuid"
 )
 
+// downloadFile fetches the ciphertext of the file of entry id from url into
+// a partial file in dir, resuming an earlier interrupted download of the same
+// ciphertext, checks it against the digest recorded at upload time and
+// decrypts it to outputFile. A corrupt download (cryptox.ErrCorrupt) is
+// discarded, so that the next attempt starts over. An unbound ciphertext is
+// accepted only if allowUnbound is set (see models.BinaryFile).
+func downloadFile(url string, id string, fd *models.File, fileKey []byte, allowUnbound bool, dir string, outputFile string) error {
+	partial := filepath.Join(dir, fmt.Sprintf(".%s-%x.part", id, fd.Nonce))
+	if err := netx.DownloadToFile(url, partial); err != nil {
+		return err
+	}
+
+	err := decryptDownload(partial, id, fd, fileKey, allowUnbound, outputFile)
+	if err == nil || errors.Is(err, cryptox.ErrCorrupt) {
+		_ = os.Remove(partial)
+	}
+	return err
+}
+
+// decryptDownload verifies the downloaded ciphertext at path, if a digest
+// was recorded for it, and decrypts it to outputFile.
+func decryptDownload(path string, id string, fd *models.File, fileKey []byte, allowUnbound bool, outputFile string) error {
+	encrypted, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer encrypted.Close()
+
+	// files uploaded by older clients have no digest and are only checked
+	// by decryption
+	if len(fd.Digest) > 0 {
+		if err := cryptox.VerifyDigest(encrypted, fd.Digest); err != nil {
+			return err
+		}
+		if _, err := encrypted.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	return cryptox.DecryptFileTo(outputFile, encrypted, fileKey, fd.Nonce, cryptox.EntryAAD(id, cryptox.FieldFile), allowUnbound)
+}
+
 // addEntry is a small workflow helper that:
 //  1. prompts for the common "envelope" fields (title, metadata) and the
 //     concrete entry payload via addEntryDetails,
@@ -29,7 +73,7 @@ func (a *App) addEntry(ctx context.Context, addEntryDetails func(context.Context
 		log.Printf("error: %v", err)
 		return err
 	}
-	if err := a.entryService.Add(ctx, item, file, a.masterKey); err != nil {
+	if err := a.entryService.Add(ctx, item, file, a.vaultKey); err != nil {
 		log.Printf("error: %v", err)
 		return err
 	}
@@ -114,7 +158,8 @@ func (a *App) addFileDetails(ctx context.Context) (models.TypedEntry, error) {
 
 // InputEnvelope gathers the common envelope data (title, metadata) and obtains
 // a typed payload via 'rest'. If the payload implements models.Materializer,
-// it is materialized into a *models.File (e.g., for binary uploads).
+// it is materialized into a *models.File (e.g., for binary uploads) bound to
+// a new entry id, which entryService.Add adopts for the entry.
 //
 // Returns the constructed envelope, an optional *models.File, and an error.
 func (a *App) InputEnvelope(
@@ -143,7 +188,7 @@ func (a *App) InputEnvelope(
 
 	var file *models.File
 	if m, ok := payload.(models.Materializer); ok {
-		file, err = m.Materialize(ctx)
+		file, err = m.Materialize(ctx, uuid.NewString())
 		if err != nil {
 			return zero, nil, fmt.Errorf("materialize: %w", err)
 		}
@@ -169,9 +214,9 @@ func (a *App) InputEnvelope(
 }
 
 // List prints a short textual representation for each stored entry.
-// Decryption uses the in-memory master key.
+// Decryption uses the in-memory vault key.
 func (a *App) List(ctx context.Context) error {
-	s, err := a.entryService.List(ctx, a.masterKey)
+	s, err := a.entryService.List(ctx, a.vaultKey)
 	if err != nil {
 		return err
 	}
@@ -181,9 +226,35 @@ func (a *App) List(ctx context.Context) error {
 	return nil
 }
 
-// Sync triggers a two-way synchronization with the backend (if applicable).
+// Sync triggers a two-way synchronization with the backend (if applicable)
+// and points the user to the conflicts command when entries diverged.
+// Entries sealed by older versions are re-sealed first, so that they are
+// pushed bound to their ids.
 func (a *App) Sync(ctx context.Context) error {
-	return a.entryService.Sync(ctx)
+	a.resealEntries(ctx)
+	if err := a.entryService.Sync(ctx); err != nil {
+		return err
+	}
+	conflicts, err := a.entryService.ListConflicts(ctx, a.vaultKey)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		fmt.Printf("%d conflicting entries, run 'conflicts' to resolve them\n", len(conflicts))
+	}
+	return nil
+}
+
+// resealEntries binds entries sealed by older versions to their ids.
+// Failures are only logged; the entries are re-sealed on the next sync.
+func (a *App) resealEntries(ctx context.Context) {
+	n, err := a.entryService.ResealEntries(ctx, a.vaultKey)
+	if err != nil {
+		log.Printf("error resealing entries: %v", err)
+	}
+	if n > 0 {
+		log.Printf("Re-sealed %d entries bound to their ids", n)
+	}
 }
 
 // Delete removes an entry by its identifier, prompting the user for the ID.
@@ -212,7 +283,7 @@ func (a *App) Show(ctx context.Context) error {
 		return err
 	}
 
-	envelope, err := a.entryService.Get(ctx, id, a.masterKey)
+	envelope, err := a.entryService.Get(ctx, id, a.vaultKey)
 	if err != nil {
 		return err
 	}
@@ -246,11 +317,6 @@ func (a *App) Show(ctx context.Context) error {
 			return err
 		}
 
-		encrypted, err := netx.DownloadFromS3PresignedURL(url)
-		if err != nil {
-			return err
-		}
-
 		fd, err := a.entryService.GetFile(ctx, id)
 		if err != nil {
 			return err
@@ -261,15 +327,21 @@ func (a *App) Show(ctx context.Context) error {
 			return err
 		}
 
-		ef := &cryptox.EncryptedFile{
-			Cyphertext: encrypted,
-			Key:        fd.EncryptedFileKey,
-			Nonce:      fd.Nonce,
+		// Keys stored before key wrapping are raw until the next online
+		// login migrates them.
+		fileKey := fd.EncryptedFileKey
+		if cryptox.IsWrappedKey(fileKey) {
+			fileKey, err = cryptox.UnwrapKey(fileKey, a.vaultKey)
+			if err != nil {
+				return err
+			}
 		}
 
 		outputFile := filepath.Join(dir, filepath.Base(item.Path))
-
-		if err := cryptox.DecryptFileTo(outputFile, ef); err != nil {
+		if err := downloadFile(url, id, fd, fileKey, item.Unbound, dir, outputFile); err != nil {
+			if errors.Is(err, cryptox.ErrCorrupt) {
+				log.Printf("The downloaded file is corrupt: %v", err)
+			}
 			return err
 		}
 		log.Printf("File saved to: %s", outputFile)
@@ -22,7 +22,18 @@ type execIface interface {
 	AddFile(ctx context.Context) error
 	AddCreditCard(ctx context.Context) error
 	Show(ctx context.Context) error
+	Edit(ctx context.Context, id string) error
+	History(ctx context.Context, id string) error
+	Restore(ctx context.Context, id string, rev string) error
+	Trash(ctx context.Context) error
+	Purge(ctx context.Context, id string) error
+	Delete(ctx context.Context) error
+	Conflicts(ctx context.Context) error
 	Sync(ctx context.Context) error
+	Passwd(ctx context.Context) error
+	Sessions(ctx context.Context) error
+	Revoke(ctx context.Context, id string) error
+	TwoFactor(ctx context.Context) error
 	Logout(ctx context.Context) error
 }
 
@@ -51,7 +62,18 @@ type execIface interface {
 //	  - addcard        — add a credit card
 //	  - list       	   — list entries
 //	  - show           — show a single entry (interactive ID prompt)
+//	  - edit <id>      — edit an entry, keeping current values by default
+//	  - history <id>   — list the stored versions of an entry
+//	  - restore <id> [rev] — bring back a deleted entry, or an old version
+//	  - delete         — delete a single entry (interactive ID prompt)
+//	  - trash          — list deleted entries
+//	  - purge <id>     — permanently remove a deleted entry from this device
 //	  - sync           — synchronize with the server
+//	  - conflicts      — resolve entries changed both locally and on the server
+//	  - passwd         — change the account password
+//	  - sessions       — list the devices logged into the account
+//	  - revoke <id>|others — log a device, or every other device, out
+//	  - 2fa            — enable two-factor authentication for online logins
 //	  - logout         — log out
 //	  - exit | quit    — leave the program
 //
@@ -73,7 +95,7 @@ func runREPL(ctx context.Context, a execIface, statusFn func() string, scanner *
 		switch cmd {
 		case "help":
 			if a.isLoggedIn() {
-				printlnFn("Available commands: (l)ist, addnote, addlogin, addfile, addcard, show, sync, logout, exit")
+				printlnFn("Available commands: (l)ist, addnote, addlogin, addfile, addcard, show, edit, history, restore, delete, trash, purge, sync, conflicts, passwd, sessions, revoke, 2fa, logout, exit")
 			} else {
 				printlnFn("Available commands: register, login, exit")
 			}
@@ -99,12 +121,45 @@ func runREPL(ctx context.Context, a execIface, statusFn func() string, scanner *
 		case "show":
 			_ = a.Show(ctx)
 
+		case "edit":
+			_ = a.Edit(ctx, arg(parts, 1))
+
+		case "history":
+			_ = a.History(ctx, arg(parts, 1))
+
+		case "restore":
+			_ = a.Restore(ctx, arg(parts, 1), arg(parts, 2))
+
+		case "delete":
+			_ = a.Delete(ctx)
+
+		case "trash":
+			_ = a.Trash(ctx)
+
+		case "purge":
+			_ = a.Purge(ctx, arg(parts, 1))
+
 		case "l", "list":
 			_ = a.List(ctx)
 
 		case "sync":
 			_ = a.Sync(ctx)
 
+		case "conflicts":
+			_ = a.Conflicts(ctx)
+
+		case "passwd":
+			_ = a.Passwd(ctx)
+
+		case "sessions":
+			_ = a.Sessions(ctx)
+
+		case "revoke":
+			_ = a.Revoke(ctx, arg(parts, 1))
+
+		case "2fa":
+			_ = a.TwoFactor(ctx)
+
 		case "logout":
 			_ = a.Logout(ctx)
 
@@ -117,3 +172,11 @@ func runREPL(ctx context.Context, a execIface, statusFn func() string, scanner *
 		}
 	}
 }
+
+// arg returns the i-th token of a command line, or "" if it is missing.
+func arg(parts []string, i int) string {
+	if i < len(parts) {
+		return parts[i]
+	}
+	return ""
+}
@@ -45,7 +45,51 @@ func (f *fakeExec) Show(ctx context.Context) error {
 	f.calls = append(f.calls, "show")
 	return nil
 }
+func (f *fakeExec) Edit(ctx context.Context, id string) error {
+	f.calls = append(f.calls, "edit "+id)
+	return nil
+}
+func (f *fakeExec) History(ctx context.Context, id string) error {
+	f.calls = append(f.calls, "history "+id)
+	return nil
+}
+func (f *fakeExec) Restore(ctx context.Context, id string, rev string) error {
+	f.calls = append(f.calls, "restore "+id+" "+rev)
+	return nil
+}
+func (f *fakeExec) Trash(ctx context.Context) error {
+	f.calls = append(f.calls, "trash")
+	return nil
+}
+func (f *fakeExec) Purge(ctx context.Context, id string) error {
+	f.calls = append(f.calls, "purge "+id)
+	return nil
+}
+func (f *fakeExec) Delete(ctx context.Context) error {
+	f.calls = append(f.calls, "delete")
+	return nil
+}
+func (f *fakeExec) Conflicts(ctx context.Context) error {
+	f.calls = append(f.calls, "conflicts")
+	return nil
+}
 func (f *fakeExec) Sync(ctx context.Context) error { f.calls = append(f.calls, "sync"); return nil }
+func (f *fakeExec) Passwd(ctx context.Context) error {
+	f.calls = append(f.calls, "passwd")
+	return nil
+}
+func (f *fakeExec) Sessions(ctx context.Context) error {
+	f.calls = append(f.calls, "sessions")
+	return nil
+}
+func (f *fakeExec) Revoke(ctx context.Context, id string) error {
+	f.calls = append(f.calls, "revoke "+id)
+	return nil
+}
+func (f *fakeExec) TwoFactor(ctx context.Context) error {
+	f.calls = append(f.calls, "2fa")
+	return nil
+}
 func (f *fakeExec) Logout(ctx context.Context) error {
 	f.calls = append(f.calls, "logout")
 	f.loggedIn = false
@@ -64,7 +108,20 @@ func TestRunREPL_LoginFlowAndCommands(t *testing.T) {
 		"addnote",
 		"list",
 		"show 123",
+		"edit 7",
+		"history 7",
+		"restore 7 3",
+		"delete",
+		"trash",
+		"restore 7",
+		"purge 7",
 		"sync",
+		"conflicts",
+		"passwd",
+		"sessions",
+		"revoke s2",
+		"revoke others",
+		"2fa",
 		"get 42",
 		"foobar",
 		"exit",
@@ -75,7 +132,7 @@ func TestRunREPL_LoginFlowAndCommands(t *testing.T) {
 
 	runREPL(context.Background(), exec, func() string { return "status" }, sc)
 
-	wantOrder := []string{"login", "addnote", "list", "show", "sync"}
+	wantOrder := []string{"login", "addnote", "list", "show", "edit 7", "history 7", "restore 7 3", "delete", "trash", "restore 7 ", "purge 7", "sync", "conflicts", "passwd", "sessions", "revoke s2", "revoke others", "2fa"}
 	if len(exec.calls) < len(wantOrder) {
 		t.Fatalf("few calls: %+v", exec.calls)
 	}
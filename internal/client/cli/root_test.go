@@ -47,17 +47,30 @@ type fakeExec1 struct {
 	logged bool
 }
 
-func (f *fakeExec1) isLoggedIn() bool                    { return f.logged }
-func (f *fakeExec1) Register(context.Context) error      { return nil }
-func (f *fakeExec1) Login(context.Context) error         { f.logged = true; return nil }
-func (f *fakeExec1) AddNote(context.Context) error       { return nil }
-func (f *fakeExec1) List(context.Context) error          { return nil }
-func (f *fakeExec1) AddLogin(context.Context) error      { return nil }
-func (f *fakeExec1) AddFile(context.Context) error       { return nil }
-func (f *fakeExec1) AddCreditCard(context.Context) error { return nil }
-func (f *fakeExec1) Show(context.Context) error          { return nil }
-func (f *fakeExec1) Sync(context.Context) error          { return nil }
-func (f *fakeExec1) Logout(context.Context) error        { f.logged = false; return nil }
+func (f *fakeExec1) isLoggedIn() bool                      { return f.logged }
+func (f *fakeExec1) Register(context.Context) error        { return nil }
+func (f *fakeExec1) Login(context.Context) error           { f.logged = true; return nil }
+func (f *fakeExec1) AddNote(context.Context) error         { return nil }
+func (f *fakeExec1) List(context.Context) error            { return nil }
+func (f *fakeExec1) AddLogin(context.Context) error        { return nil }
+func (f *fakeExec1) AddFile(context.Context) error         { return nil }
+func (f *fakeExec1) AddCreditCard(context.Context) error   { return nil }
+func (f *fakeExec1) Show(context.Context) error            { return nil }
+func (f *fakeExec1) Edit(context.Context, string) error    { return nil }
+func (f *fakeExec1) History(context.Context, string) error { return nil }
+func (f *fakeExec1) Restore(context.Context, string, string) error {
+	return nil
+}
+func (f *fakeExec1) Trash(context.Context) error          { return nil }
+func (f *fakeExec1) Purge(context.Context, string) error  { return nil }
+func (f *fakeExec1) Delete(context.Context) error         { return nil }
+func (f *fakeExec1) Conflicts(context.Context) error      { return nil }
+func (f *fakeExec1) Sync(context.Context) error           { return nil }
+func (f *fakeExec1) Passwd(context.Context) error         { return nil }
+func (f *fakeExec1) Sessions(context.Context) error       { return nil }
+func (f *fakeExec1) Revoke(context.Context, string) error { return nil }
+func (f *fakeExec1) TwoFactor(context.Context) error      { return nil }
+func (f *fakeExec1) Logout(context.Context) error         { f.logged = false; return nil }
 
 func TestRunREPL_HelpThenQuit(t *testing.T) {
 	silencePrintln(t)
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Trash lists deleted entries. They can be brought back with "restore <id>"
+// or removed from this device with "purge <id>" until the server purges them
+// for good.
+func (a *App) Trash(ctx context.Context) error {
+	s, err := a.entryService.ListTrash(ctx, a.vaultKey)
+	if err != nil {
+		log.Printf("error: %v", err)
+		return err
+	}
+	if len(s) == 0 {
+		fmt.Println("Trash is empty")
+		return nil
+	}
+	for _, item := range s {
+		fmt.Println(item)
+	}
+	return nil
+}
+
+// Purge permanently removes a deleted entry from the local vault. The
+// deletion must have been synced first.
+//
+// The ID is prompted for when empty.
+func (a *App) Purge(ctx context.Context, id string) error {
+	var err error
+	if id == "" {
+		id, err = GetSimpleText(a.reader, "Enter record id to purge", os.Stdout)
+		if err != nil {
+			return err
+		}
+	}
+	if err := a.entryService.Purge(ctx, id); err != nil {
+		log.Printf("error: %v", err)
+		return err
+	}
+	return nil
+}
@@ -15,26 +15,99 @@ type Client interface {
 	// Close releases any underlying resources (connections, goroutines, etc.).
 	Close() error
 
-	// Register creates a new account using a username, salt, and derived key.
-	// The salt/key are typically produced client-side (e.g., from a password KDF).
-	Register(ctx context.Context, username string, salt []byte, key []byte) error
+	// Register creates a new account from credentials produced client-side
+	// from the password.
+	Register(ctx context.Context, username string, creds models.Credentials) error
 
-	// GetSalt returns the server-stored salt for the given username,
-	// used to derive the authentication key locally.
-	GetSalt(ctx context.Context, username string) ([]byte, error)
+	// GetSalt returns the server-stored salt and KDF parameters for the
+	// given username, used to derive the authentication key locally, and
+	// whether the account predates the key hierarchy or has to upgrade its
+	// KDF parameters.
+	GetSalt(ctx context.Context, username string) (*models.SaltInfo, error)
 
-	// Login authenticates with the server using the derived key.
-	// Subsequent calls may refresh tokens/credentials as needed.
-	Login(ctx context.Context, username string, key []byte) error
+	// LoginStart begins an SRP login with the client's public value and
+	// returns the login session id and the server's public value. Accounts
+	// that still have a legacy verifier get a session that LoginFinish
+	// rejects like a wrong password; they have to be upgraded with Login.
+	LoginStart(ctx context.Context, username string, clientPublic []byte) (sessionID string, serverPublic []byte, err error)
+
+	// LoginFinish completes an SRP login with the client proof and returns
+	// the server proof and the account's wrapped vault key (empty for
+	// legacy accounts). Tokens are cached for subsequent calls. For accounts
+	// with two-factor authentication it returns a twoFactorChallenge
+	// instead of the key, and the login is completed with Verify2FA.
+	LoginFinish(ctx context.Context, sessionID string, clientProof []byte) (serverProof []byte, wrappedVaultKey []byte, twoFactorChallenge string, err error)
+
+	// Verify2FA completes a login with a TOTP or recovery code for the
+	// challenge returned by LoginFinish and returns the account's wrapped
+	// vault key. Tokens are cached for subsequent calls. A wrong code fails
+	// with ErrUnauthorized.
+	Verify2FA(ctx context.Context, challengeID string, code string) (wrappedVaultKey []byte, err error)
+
+	// Enable2FA starts a TOTP enrollment of the logged-in account and
+	// returns the secret in base32 and its otpauth URI. Logging in requires
+	// a code once the enrollment is confirmed with Confirm2FA. An account
+	// that has a second factor already replaces it only given code, a
+	// current TOTP or recovery code of it: without one Enable2FA fails with
+	// ErrSecondFactorRequired, with a wrong one with ErrInvalidCode.
+	Enable2FA(ctx context.Context, code string) (secret string, uri string, err error)
+
+	// Confirm2FA completes the enrollment with a code of the new secret and
+	// returns one-time recovery codes. A wrong code fails with
+	// ErrInvalidCode.
+	Confirm2FA(ctx context.Context, code string) (recoveryCodes []string, err error)
+
+	// Login authenticates a legacy account with its old verifier and
+	// replaces that verifier with srpVerifier and wrappedVaultKey on the
+	// server. Tokens are cached for subsequent calls.
+	Login(ctx context.Context, username string, legacyVerifier []byte, srpVerifier []byte, wrappedVaultKey []byte) error
+
+	// UpgradeKeys moves the logged-in legacy account to the key hierarchy
+	// by storing a new SRP verifier and the wrapped vault key.
+	UpgradeKeys(ctx context.Context, srpVerifier []byte, wrappedVaultKey []byte) error
+
+	// ChangePassword replaces the logged-in account's credentials. The
+	// current password is proven with the client proof for a session started
+	// by LoginStart; the server proof is returned. The server revokes all
+	// sessions of the account, the new tokens it returns are cached for
+	// subsequent calls. If creds wrap a new vault key, rekey is the account's
+	// content re-encrypted under it, which the server stores together with
+	// creds and returns the new version of every entry for; ErrConflict
+	// means the account changed since rekey was pulled.
+	ChangePassword(ctx context.Context, sessionID string, clientProof []byte, creds models.Credentials, rekey *models.VaultRekey) (serverProof []byte, versions map[string]int64, err error)
+
+	// UpgradeKDF replaces the logged-in account's credentials by ones
+	// re-derived from the same password with the KDF parameters the server
+	// asked for in GetSalt. The password is proven like in ChangePassword;
+	// tokens stay valid.
+	UpgradeKDF(ctx context.Context, sessionID string, clientProof []byte, creds models.Credentials) (serverProof []byte, err error)
+
+	// ListSessions returns the devices logged into the account, most
+	// recently used first, with this client's session marked as Current.
+	ListSessions(ctx context.Context) ([]*models.Session, error)
+
+	// RevokeSession logs the device of session id out; its tokens stop
+	// working. It returns ErrNotFound if the account has no such session.
+	RevokeSession(ctx context.Context, id string) error
+
+	// RevokeAllOtherSessions logs every device but this one out and returns
+	// how many were logged out.
+	RevokeAllOtherSessions(ctx context.Context) (int64, error)
+
+	// Logout ends this device's session on the server and forgets its
+	// tokens. It does nothing if the client never logged in online.
+	Logout(ctx context.Context) error
 
 	// Ping performs a lightweight reachability/liveness probe.
 	Ping(ctx context.Context) error
 
 	// Sync performs bidirectional synchronization.
-	//   - entries/files: local changes to push
+	//   - entries/files: local changes to push; Entry.Version is the base
+	//     version each change was made against
 	//   - maxVersion:    caller's latest known version
 	// It returns:
-	//   - newOrUpdatedEntriesFromServer
+	//   - newOrUpdatedEntriesFromServer (accepted pushes with new versions)
+	//   - conflicts (server copies of pushes rejected as stale)
 	//   - deletedEntriesFromServer (if represented as tombstones)
 	//   - newOrUpdatedFilesFromServer
 	//   - pendingUploads (server requests client to upload these)
@@ -45,6 +118,7 @@ type Client interface {
 		maxVersion int64,
 	) (
 		updatedEntries []*models.Entry,
+		conflicts []*models.Entry,
 		deletedEntries []*models.Entry,
 		updatedFiles []*models.File,
 		pendingUploads []*models.FileUploadTask,
@@ -59,4 +133,39 @@ type Client interface {
 	// GetPresignedGetURL returns a temporary, signed URL for downloading
 	// an encrypted file associated with entryID.
 	GetPresignedGetURL(ctx context.Context, entryID string) (string, error)
+
+	// ListRevisions returns the stored versions of entryID, newest first.
+	// Entry.Version identifies a revision and Entry.UpdatedAt is the time it
+	// was stored.
+	ListRevisions(ctx context.Context, entryID string) ([]*models.Entry, error)
+
+	// GetRevision returns a single stored version of entryID. It returns
+	// ErrNotFound if the server has no such revision.
+	GetRevision(ctx context.Context, entryID string, version int64) (*models.Entry, error)
+
+	// UpdateFileKey replaces the wrapped key of the file attached to
+	// entryID. It returns ErrNotFound if the server has no such file.
+	UpdateFileKey(ctx context.Context, entryID string, fileKey []byte) error
+
+	// StartMultipartUpload starts a multipart upload of the pending file of
+	// entryID, or returns the one already in progress. It returns
+	// ErrNotFound if the server has no pending file for entryID.
+	StartMultipartUpload(ctx context.Context, entryID string) (string, error)
+
+	// PresignUploadParts returns temporary, signed URLs to upload the given
+	// parts of a multipart upload, in the same order. It returns
+	// ErrNotFound if the upload is no longer in progress.
+	PresignUploadParts(ctx context.Context, entryID string, uploadID string, partNumbers []int32) ([]string, error)
+
+	// CompleteMultipartUpload assembles the uploaded parts into the file of
+	// entryID. It returns ErrNotFound if the upload is no longer in progress.
+	CompleteMultipartUpload(ctx context.Context, entryID string, uploadID string, parts []models.UploadedPart) error
+
+	// AbortMultipartUpload discards a multipart upload and its parts.
+	AbortMultipartUpload(ctx context.Context, entryID string, uploadID string) error
+
+	// RequestUploadURL returns a fresh temporary, signed URL to upload the
+	// pending file of entryID that an earlier Sync registered. It returns
+	// ErrNotFound if the server has no pending file for entryID.
+	RequestUploadURL(ctx context.Context, entryID string) (string, error)
 }
@@ -4,18 +4,27 @@
 //
 // The package provides:
 //  1. A transport-agnostic API contract (see the Client interface) to talk
-//     to the GophKeeper backend: Register/GetSalt/Login, Ping, Sync,
-//     MarkUploaded, and presigned URL helpers.
+//     to the GophKeeper backend: Register/GetSalt, the SRP LoginStart/
+//     LoginFinish exchange (plus the legacy Login and UpgradeKeys used to
+//     upgrade old accounts), the TOTP second factor (Enable2FA, Confirm2FA
+//     and Verify2FA), ChangePassword, UpgradeKDF, Ping, Sync,
+//     MarkUploaded, presigned URL helpers (including fresh upload URLs for
+//     files registered by an earlier sync), multipart uploads, entry
+//     revision history, listing and revoking the devices logged into the
+//     account (sessions), and Logout.
 //  2. A concrete gRPC implementation (see GRPCClient) that manages a
 //     connection, injects an access token via an interceptor, transparently
-//     refreshes expired tokens, and maps gRPC status codes to sentinel errors.
+//     refreshes expired tokens, describes the device at login, and maps gRPC
+//     status codes to sentinel errors.
 //  3. Local persistence bootstrap utilities (InitDatabase, RunMigrations) for
 //     the CLI, wiring an SQLite database and applying embedded goose migrations.
 //
 // # Error Handling
 //
 // Common conditions are exposed as sentinel errors that callers can match with
-// errors.Is: ErrUnavailable, ErrUnauthorized, ErrLocalDataNotAvailable.
+// errors.Is: ErrUnavailable, ErrUnauthorized, ErrNotFound, ErrResyncRequired,
+// ErrInvalidCode, ErrRateLimited, ErrLocalDataNotAvailable. Throttled calls
+// fail with a *RateLimitError that carries the wait the server asks for.
 //
 // Concurrency & Contexts
 //
@@ -27,5 +36,5 @@
 //   - Interface:  Client
 //   - gRPC impl:  GRPCClient
 //   - DB helpers: InitDatabase, RunMigrations
-//   - Errors:     ErrUnavailable, ErrUnauthorized, ErrLocalDataNotAvailable
+//   - Errors:     ErrUnavailable, ErrUnauthorized, ErrNotFound, ErrResyncRequired, ErrInvalidCode, ErrRateLimited, ErrLocalDataNotAvailable
 package client
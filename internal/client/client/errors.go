@@ -1,6 +1,10 @@
 package client
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 // ErrUnavailable indicates that the server (or network) is unreachable.
 // Callers may choose to fall back to offline flows when this error occurs.
@@ -13,3 +17,43 @@ var ErrUnauthorized = errors.New("unauthorized")
 // ErrLocalDataNotAvailable indicates that required local state (e.g., cached
 // entries or keys for offline mode) is missing or unreadable.
 var ErrLocalDataNotAvailable = errors.New("local data unavailable")
+
+// ErrNotFound indicates that the server has no such resource for the caller,
+// e.g. an unknown entry revision.
+var ErrNotFound = errors.New("not found")
+
+// ErrResyncRequired indicates that the server purged deletions this device
+// has not pulled yet; the next Sync has to start from version 0.
+var ErrResyncRequired = errors.New("full resync required")
+
+// ErrConflict indicates that the server rejected a change prepared from
+// data that has changed on the server since; it can be prepared again.
+var ErrConflict = errors.New("conflict")
+
+// ErrInvalidCode indicates that the server rejected a two-factor code while
+// enrolling.
+var ErrInvalidCode = errors.New("invalid code")
+
+// ErrSecondFactorRequired indicates that the account has two-factor
+// authentication and the call has to be repeated with a current code.
+var ErrSecondFactorRequired = errors.New("second factor required")
+
+// ErrRateLimited indicates that the server throttles the caller after too
+// many authentication attempts; the error is a *RateLimitError.
+var ErrRateLimited = errors.New("too many attempts")
+
+// RateLimitError is returned for calls the server throttled, with how long
+// it asks to wait before retrying (0 if it did not say). It matches
+// ErrRateLimited with errors.Is.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter <= 0 {
+		return ErrRateLimited.Error()
+	}
+	return fmt.Sprintf("%s, try again in %s", ErrRateLimited, e.RetryAfter)
+}
+
+func (e *RateLimitError) Unwrap() error { return ErrRateLimited }
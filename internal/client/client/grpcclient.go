@@ -2,14 +2,22 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"os"
+	"runtime"
+	"strconv"
 	"time"
 
+	"github.com/dmitrijs2005/gophkeeper/internal/buildinfo"
 	"github.com/dmitrijs2005/gophkeeper/internal/client/models"
 	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
 	pb "github.com/dmitrijs2005/gophkeeper/internal/proto"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
@@ -20,12 +28,30 @@ import (
 // into outgoing requests via a unary interceptor.
 type GRPCClient struct {
 	endpointURL  string
+	tlsConfig    *tls.Config
 	conn         *grpc.ClientConn
 	client       pb.GophKeeperServiceClient
 	accessToken  string
 	refreshToken string
 }
 
+// hostname is a test seam for the device name reported at login.
+var hostname = os.Hostname
+
+// deviceInfo describes this client to the server, which lists it among the
+// account's sessions.
+func deviceInfo() *pb.DeviceInfo {
+	name, err := hostname()
+	if err != nil {
+		name = ""
+	}
+	return &pb.DeviceInfo{
+		Name:          name,
+		Platform:      runtime.GOOS + "/" + runtime.GOARCH,
+		ClientVersion: buildinfo.Version(),
+	}
+}
+
 // withAccessToken returns a child context that carries the provided access token
 // in the gRPC outgoing metadata under common.AccessTokenHeaderName.
 func withAccessToken(ctx context.Context, token string) context.Context {
@@ -39,9 +65,22 @@ func withAccessToken(ctx context.Context, token string) context.Context {
 	return metadata.NewOutgoingContext(ctx, md)
 }
 
+// rateLimitError returns the *RateLimitError of a throttled call with the
+// wait from its common.RetryAfterTrailerName trailer.
+func rateLimitError(trailer metadata.MD) *RateLimitError {
+	e := &RateLimitError{}
+	if v := trailer.Get(common.RetryAfterTrailerName); len(v) > 0 {
+		if seconds, err := strconv.Atoi(v[0]); err == nil && seconds > 0 {
+			e.RetryAfter = time.Duration(seconds) * time.Second
+		}
+	}
+	return e
+}
+
 // accessTokenInterceptor injects the current access token and, on receiving
 // an Unauthenticated error with an "expired" message, attempts a refresh and
-// retries the original RPC once with the new token.
+// retries the original RPC once with the new token. Throttled calls
+// (ResourceExhausted) fail with a *RateLimitError.
 func (s *GRPCClient) accessTokenInterceptor(
 	ctx context.Context,
 	method string,
@@ -52,6 +91,8 @@ func (s *GRPCClient) accessTokenInterceptor(
 ) error {
 	ctx = withAccessToken(ctx, s.accessToken)
 
+	var trailer metadata.MD
+	opts = append(opts, grpc.Trailer(&trailer))
 	err := invoker(ctx, method, req, reply, cc, opts...)
 	if err == nil {
 		return nil
@@ -61,6 +102,9 @@ func (s *GRPCClient) accessTokenInterceptor(
 	if !ok {
 		return err
 	}
+	if st.Code() == codes.ResourceExhausted {
+		return rateLimitError(trailer)
+	}
 	if st.Code() != codes.Unauthenticated {
 		return err
 	}
@@ -80,25 +124,36 @@ func (s *GRPCClient) accessTokenInterceptor(
 	s.refreshToken = refreshTokenResponse.RefreshToken
 
 	ctx = withAccessToken(ctx, s.accessToken)
-	return invoker(ctx, method, req, reply, cc, opts...)
+	trailer = nil
+	err = invoker(ctx, method, req, reply, cc, opts...)
+	if status.Code(err) == codes.ResourceExhausted {
+		return rateLimitError(trailer)
+	}
+	return err
 }
 
 // NewGophKeeperClientService constructs a GRPCClient for the given endpoint URL
-// and initializes the gRPC connection and service stub.
-func NewGophKeeperClientService(endpointURL string) (*GRPCClient, error) {
-	c := &GRPCClient{endpointURL: endpointURL}
+// and TLS configuration and initializes the gRPC connection and service stub.
+// A nil tlsConfig connects without TLS.
+func NewGophKeeperClientService(endpointURL string, tlsConfig *tls.Config) (*GRPCClient, error) {
+	c := &GRPCClient{endpointURL: endpointURL, tlsConfig: tlsConfig}
 	if err := c.InitGRPCClient(); err != nil {
 		return nil, err
 	}
 	return c, nil
 }
 
-// InitGRPCClient dials the server with insecure credentials (dev use) and
-// installs the access-token interceptor. It also creates the typed service client.
+// InitGRPCClient dials the server over TLS, or with insecure credentials (dev
+// use) when no TLS configuration is set, and installs the access-token
+// interceptor. It also creates the typed service client.
 func (s *GRPCClient) InitGRPCClient() error {
+	creds := insecure.NewCredentials()
+	if s.tlsConfig != nil {
+		creds = credentials.NewTLS(s.tlsConfig)
+	}
 	conn, err := grpc.NewClient(
 		s.endpointURL,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(creds),
 		grpc.WithUnaryInterceptor(s.accessTokenInterceptor),
 	)
 	if err != nil {
@@ -109,18 +164,27 @@ func (s *GRPCClient) InitGRPCClient() error {
 	return nil
 }
 
-// Register creates a new user account by sending username, salt, and verifier key.
-func (s *GRPCClient) Register(ctx context.Context, userName string, salt []byte, key []byte) error {
-	req := &pb.RegisterUserRequest{Username: userName, Salt: salt, Verifier: key}
+// Register creates a new user account by sending username and credentials.
+func (s *GRPCClient) Register(ctx context.Context, userName string, creds models.Credentials) error {
+	req := &pb.RegisterUserRequest{
+		Username:        userName,
+		Salt:            creds.Salt,
+		Verifier:        creds.SRPVerifier,
+		WrappedVaultKey: creds.WrappedVaultKey,
+		Kdf:             kdfToPB(creds.KDF),
+	}
 	if _, err := s.client.RegisterUser(ctx, req); err != nil {
 		return s.mapError(err)
 	}
 	return nil
 }
 
-// GetSalt fetches the server-stored salt for the given username.
-// A 12s timeout is applied to the request context.
-func (s *GRPCClient) GetSalt(ctx context.Context, userName string) ([]byte, error) {
+// GetSalt fetches the server-stored salt and KDF parameters for the given
+// username, whether the account predates the key hierarchy and the KDF
+// parameters to upgrade it to, if any. Servers that do not send KDF
+// parameters use the legacy ones. A 12s timeout is applied to the request
+// context.
+func (s *GRPCClient) GetSalt(ctx context.Context, userName string) (*models.SaltInfo, error) {
 	ctx, cancel := context.WithTimeout(ctx, 12*time.Second)
 	defer cancel()
 
@@ -129,13 +193,95 @@ func (s *GRPCClient) GetSalt(ctx context.Context, userName string) ([]byte, erro
 	if err != nil {
 		return nil, s.mapError(err)
 	}
-	return resp.Salt, nil
+	info := &models.SaltInfo{Salt: resp.Salt, KDF: cryptox.LegacyKDFParams, LegacyKeys: resp.LegacyKeys}
+	if resp.Kdf != nil {
+		info.KDF = kdfFromPB(resp.Kdf)
+	}
+	if resp.KdfUpgrade != nil {
+		upgrade := kdfFromPB(resp.KdfUpgrade)
+		info.KDFUpgrade = &upgrade
+	}
+	return info, nil
+}
+
+// LoginStart sends the client's SRP public value and returns the login
+// session id and the server's public value.
+func (s *GRPCClient) LoginStart(ctx context.Context, userName string, clientPublic []byte) (string, []byte, error) {
+	req := &pb.LoginStartRequest{Username: userName, ClientPublic: clientPublic}
+	resp, err := s.client.LoginStart(ctx, req)
+	if err != nil {
+		return "", nil, s.mapError(err)
+	}
+	return resp.SessionId, resp.ServerPublic, nil
+}
+
+// LoginFinish sends the client's SRP proof, caching returned access/refresh
+// tokens on success, and returns the server proof and wrapped vault key, or
+// the two-factor challenge of accounts that need a second factor.
+func (s *GRPCClient) LoginFinish(ctx context.Context, sessionID string, clientProof []byte) ([]byte, []byte, string, error) {
+	req := &pb.LoginFinishRequest{SessionId: sessionID, ClientProof: clientProof, Device: deviceInfo()}
+	resp, err := s.client.LoginFinish(ctx, req)
+	if err != nil {
+		return nil, nil, "", s.mapError(err)
+	}
+	if resp.TwoFactorChallenge != "" {
+		return resp.ServerProof, nil, resp.TwoFactorChallenge, nil
+	}
+	s.accessToken = resp.AccessToken
+	s.refreshToken = resp.RefreshToken
+	return resp.ServerProof, resp.WrappedVaultKey, "", nil
+}
+
+// Verify2FA answers a two-factor challenge with a TOTP or recovery code,
+// caching returned access/refresh tokens on success, and returns the wrapped
+// vault key.
+func (s *GRPCClient) Verify2FA(ctx context.Context, challengeID string, code string) ([]byte, error) {
+	req := &pb.Verify2FARequest{ChallengeId: challengeID, Code: code, Device: deviceInfo()}
+	resp, err := s.client.Verify2FA(ctx, req)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	s.accessToken = resp.AccessToken
+	s.refreshToken = resp.RefreshToken
+	return resp.WrappedVaultKey, nil
+}
+
+// Enable2FA starts a TOTP enrollment of the account and returns the secret
+// and its otpauth URI. An account with two-factor authentication fails with
+// ErrSecondFactorRequired without a code and ErrInvalidCode for a wrong one.
+func (s *GRPCClient) Enable2FA(ctx context.Context, code string) (string, string, error) {
+	resp, err := s.client.Enable2FA(ctx, &pb.Enable2FARequest{Code: code})
+	if err != nil {
+		st, _ := status.FromError(err)
+		switch {
+		case st.Code() == codes.FailedPrecondition:
+			return "", "", ErrSecondFactorRequired
+		case st.Code() == codes.Unauthenticated && st.Message() == common.ErrInvalidCode.Error():
+			return "", "", ErrInvalidCode
+		}
+		return "", "", s.mapError(err)
+	}
+	return resp.Secret, resp.OtpauthUri, nil
+}
+
+// Confirm2FA completes the TOTP enrollment with a code of the new secret and
+// returns the recovery codes. A wrong code fails with ErrInvalidCode.
+func (s *GRPCClient) Confirm2FA(ctx context.Context, code string) ([]string, error) {
+	resp, err := s.client.Confirm2FA(ctx, &pb.Confirm2FARequest{Code: code})
+	if err != nil {
+		if status.Code(err) == codes.InvalidArgument {
+			return nil, ErrInvalidCode
+		}
+		return nil, s.mapError(err)
+	}
+	return resp.RecoveryCodes, nil
 }
 
-// Login authenticates with the server using the local verifier candidate,
-// caching returned access/refresh tokens on success.
-func (s *GRPCClient) Login(ctx context.Context, userName string, key []byte) error {
-	req := &pb.LoginRequest{Username: userName, VerifierCandidate: key}
+// Login authenticates a legacy account with its old verifier candidate and
+// uploads the SRP verifier and wrapped vault key replacing it, caching
+// returned access/refresh tokens on success.
+func (s *GRPCClient) Login(ctx context.Context, userName string, legacyVerifier []byte, srpVerifier []byte, wrappedVaultKey []byte) error {
+	req := &pb.LoginRequest{Username: userName, VerifierCandidate: legacyVerifier, SrpVerifier: srpVerifier, WrappedVaultKey: wrappedVaultKey, Device: deviceInfo()}
 	resp, err := s.client.Login(ctx, req)
 	if err != nil {
 		return s.mapError(err)
@@ -145,6 +291,136 @@ func (s *GRPCClient) Login(ctx context.Context, userName string, key []byte) err
 	return nil
 }
 
+// UpgradeKeys stores a new SRP verifier and the wrapped vault key of the
+// logged-in legacy account.
+func (s *GRPCClient) UpgradeKeys(ctx context.Context, srpVerifier []byte, wrappedVaultKey []byte) error {
+	req := &pb.UpgradeKeysRequest{SrpVerifier: srpVerifier, WrappedVaultKey: wrappedVaultKey}
+	if _, err := s.client.UpgradeKeys(ctx, req); err != nil {
+		return s.mapError(err)
+	}
+	return nil
+}
+
+// ChangePassword sends the proof of the current password together with the
+// new credentials and, if the vault key is replaced, the re-encrypted
+// content, caching the returned access/refresh tokens on success. It
+// returns the server proof and the new versions of re-encrypted entries.
+func (s *GRPCClient) ChangePassword(ctx context.Context, sessionID string, clientProof []byte, creds models.Credentials, rekey *models.VaultRekey) ([]byte, map[string]int64, error) {
+	req := &pb.ChangePasswordRequest{
+		SessionId:       sessionID,
+		ClientProof:     clientProof,
+		Salt:            creds.Salt,
+		SrpVerifier:     creds.SRPVerifier,
+		WrappedVaultKey: creds.WrappedVaultKey,
+		Kdf:             kdfToPB(creds.KDF),
+		Device:          deviceInfo(),
+	}
+	if rekey != nil {
+		req.Rekey = true
+		for _, e := range rekey.Entries {
+			req.Entries = append(req.Entries, &pb.Entry{
+				Id:            e.Id,
+				Overview:      e.Overview,
+				NonceOverview: e.NonceOverview,
+				Details:       e.Details,
+				NonceDetails:  e.NonceDetails,
+				Deleted:       e.Deleted,
+				BaseVersion:   e.Version,
+			})
+		}
+		for _, f := range rekey.Files {
+			req.Files = append(req.Files, &pb.File{
+				EntryId: f.EntryID,
+				FileKey: f.EncryptedFileKey,
+				Nonce:   f.Nonce,
+			})
+		}
+	}
+	resp, err := s.client.ChangePassword(ctx, req)
+	if err != nil {
+		return nil, nil, s.mapError(err)
+	}
+	s.accessToken = resp.AccessToken
+	s.refreshToken = resp.RefreshToken
+	return resp.ServerProof, resp.Versions, nil
+}
+
+// UpgradeKDF sends the proof of the current password together with the
+// credentials re-derived with stronger KDF parameters and returns the server
+// proof.
+func (s *GRPCClient) UpgradeKDF(ctx context.Context, sessionID string, clientProof []byte, creds models.Credentials) ([]byte, error) {
+	req := &pb.UpgradeKDFRequest{
+		SessionId:       sessionID,
+		ClientProof:     clientProof,
+		Salt:            creds.Salt,
+		Kdf:             kdfToPB(creds.KDF),
+		SrpVerifier:     creds.SRPVerifier,
+		WrappedVaultKey: creds.WrappedVaultKey,
+	}
+	resp, err := s.client.UpgradeKDF(ctx, req)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	return resp.ServerProof, nil
+}
+
+// ListSessions fetches the devices logged into the account, most recently
+// used first.
+func (s *GRPCClient) ListSessions(ctx context.Context) ([]*models.Session, error) {
+	res, err := s.client.ListSessions(ctx, &pb.ListSessionsRequest{})
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	sessions := make([]*models.Session, 0, len(res.Sessions))
+	for _, r := range res.Sessions {
+		sessions = append(sessions, &models.Session{
+			ID:            r.Id,
+			DeviceName:    r.Device.GetName(),
+			Platform:      r.Device.GetPlatform(),
+			ClientVersion: r.Device.GetClientVersion(),
+			IP:            r.Ip,
+			CreatedAt:     time.Unix(r.CreatedAt, 0).UTC(),
+			LastUsedAt:    time.Unix(r.LastUsedAt, 0).UTC(),
+			Expires:       time.Unix(r.ExpiresAt, 0).UTC(),
+			Current:       r.Current,
+		})
+	}
+	return sessions, nil
+}
+
+// RevokeSession logs the device of session id out.
+func (s *GRPCClient) RevokeSession(ctx context.Context, id string) error {
+	if _, err := s.client.RevokeSession(ctx, &pb.RevokeSessionRequest{SessionId: id}); err != nil {
+		return s.mapError(err)
+	}
+	return nil
+}
+
+// RevokeAllOtherSessions logs every other device out and returns how many
+// were logged out.
+func (s *GRPCClient) RevokeAllOtherSessions(ctx context.Context) (int64, error) {
+	res, err := s.client.RevokeAllOtherSessions(ctx, &pb.RevokeAllOtherSessionsRequest{})
+	if err != nil {
+		return 0, s.mapError(err)
+	}
+	return res.Revoked, nil
+}
+
+// Logout ends this device's session on the server, so that its refresh
+// token can no longer be used, and forgets the cached tokens even if the
+// call fails. Without tokens, i.e. after an offline login, it does nothing.
+func (s *GRPCClient) Logout(ctx context.Context) error {
+	if s.accessToken == "" && s.refreshToken == "" {
+		return nil
+	}
+	_, err := s.client.Logout(ctx, &pb.LogoutRequest{})
+	s.accessToken, s.refreshToken = "", ""
+	if err != nil {
+		return s.mapError(err)
+	}
+	return nil
+}
+
 // Close closes the underlying gRPC connection.
 func (s *GRPCClient) Close() error {
 	return s.conn.Close()
@@ -164,8 +440,9 @@ func (s *GRPCClient) Ping(ctx context.Context) error {
 }
 
 // Sync performs bidirectional synchronization of entries/files with the server.
-// It converts local models to protobuf messages, calls the RPC, and maps the
-// response back to local models along with the new global version and any file
+// It converts local models to protobuf messages (sending each entry's local
+// version as its base version), calls the RPC, and maps the response back to
+// local models along with conflicts, the new global version and any file
 // upload tasks the client should fulfill.
 func (s *GRPCClient) Sync(
 	ctx context.Context,
@@ -174,6 +451,7 @@ func (s *GRPCClient) Sync(
 	maxVersion int64,
 ) (
 	processedEntries []*models.Entry,
+	conflicts []*models.Entry,
 	newEntries []*models.Entry,
 	newFiles []*models.File,
 	uploadTasks []*models.FileUploadTask,
@@ -191,6 +469,7 @@ func (s *GRPCClient) Sync(
 			NonceDetails:  e.NonceDetails,
 			Deleted:       e.Deleted,
 			IsFile:        e.IsFile,
+			BaseVersion:   e.Version,
 		})
 	}
 
@@ -200,13 +479,14 @@ func (s *GRPCClient) Sync(
 			EntryId: f.EntryID,
 			FileKey: f.EncryptedFileKey,
 			Nonce:   f.Nonce,
+			Digest:  f.Digest,
 		})
 	}
 
 	req := &pb.SyncRequest{Entries: reqEntries, Files: reqFiles, MaxVersion: maxVersion}
 	resp, callErr := s.client.Sync(ctx, req)
 	if callErr != nil {
-		return nil, nil, nil, nil, 0, s.mapError(callErr)
+		return nil, nil, nil, nil, nil, 0, s.mapError(callErr)
 	}
 
 	v := resp.GlobalMaxVersion
@@ -224,6 +504,19 @@ func (s *GRPCClient) Sync(
 		})
 	}
 
+	var ce []*models.Entry
+	for _, e := range resp.Conflicts {
+		ce = append(ce, &models.Entry{
+			Id:            e.Id,
+			Version:       e.Version,
+			Deleted:       e.Deleted,
+			Overview:      e.Overview,
+			NonceOverview: e.NonceOverview,
+			Details:       e.Details,
+			NonceDetails:  e.NonceDetails,
+		})
+	}
+
 	var ne []*models.Entry
 	for _, e := range resp.NewEntries {
 		ne = append(ne, &models.Entry{
@@ -243,6 +536,7 @@ func (s *GRPCClient) Sync(
 			EntryID:          f.EntryId,
 			EncryptedFileKey: f.FileKey,
 			Nonce:            f.Nonce,
+			Digest:           f.Digest,
 			UploadStatus:     "completed",
 		})
 	}
@@ -255,21 +549,32 @@ func (s *GRPCClient) Sync(
 		})
 	}
 
-	return pe, ne, nf, ut, v, nil
+	return pe, ce, ne, nf, ut, v, nil
 }
 
 // mapError converts gRPC status errors to package-level sentinel errors
-// (ErrUnauthorized, ErrUnavailable) or wraps the original error otherwise.
+// (ErrUnauthorized, ErrUnavailable, ErrNotFound) or wraps the original error
+// otherwise. A *RateLimitError is passed through.
 func (s *GRPCClient) mapError(err error) error {
 	if err == nil {
 		return nil
 	}
+	var rl *RateLimitError
+	if errors.As(err, &rl) {
+		return rl
+	}
 	st, _ := status.FromError(err)
 	switch st.Code() {
 	case codes.Unauthenticated, codes.PermissionDenied:
 		return ErrUnauthorized
 	case codes.Unavailable, codes.DeadlineExceeded:
 		return ErrUnavailable
+	case codes.NotFound:
+		return ErrNotFound
+	case codes.FailedPrecondition:
+		return ErrResyncRequired
+	case codes.Aborted:
+		return ErrConflict
 	default:
 		return fmt.Errorf("rpc error: %w", err)
 	}
@@ -285,6 +590,72 @@ func (s *GRPCClient) MarkUploaded(ctx context.Context, entryID string) error {
 	return nil
 }
 
+// UpdateFileKey replaces the wrapped key of the file attached to entryID.
+func (s *GRPCClient) UpdateFileKey(ctx context.Context, entryID string, fileKey []byte) error {
+	req := &pb.UpdateFileKeyRequest{EntryId: entryID, FileKey: fileKey}
+	if _, err := s.client.UpdateFileKey(ctx, req); err != nil {
+		return s.mapError(err)
+	}
+	return nil
+}
+
+// StartMultipartUpload starts, or resumes, a multipart upload of the file
+// attached to entryID and returns its upload ID.
+func (s *GRPCClient) StartMultipartUpload(ctx context.Context, entryID string) (string, error) {
+	req := &pb.StartMultipartUploadRequest{EntryId: entryID}
+	res, err := s.client.StartMultipartUpload(ctx, req)
+	if err != nil {
+		return "", s.mapError(err)
+	}
+	return res.UploadId, nil
+}
+
+// PresignUploadParts requests temporary signed URLs for uploading the given
+// parts of a multipart upload.
+func (s *GRPCClient) PresignUploadParts(ctx context.Context, entryID string, uploadID string, partNumbers []int32) ([]string, error) {
+	req := &pb.PresignUploadPartsRequest{EntryId: entryID, UploadId: uploadID, PartNumbers: partNumbers}
+	res, err := s.client.PresignUploadParts(ctx, req)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	if len(res.Urls) != len(partNumbers) {
+		return nil, fmt.Errorf("got %d part urls for %d parts", len(res.Urls), len(partNumbers))
+	}
+	return res.Urls, nil
+}
+
+// CompleteMultipartUpload asks the server to assemble the uploaded parts.
+func (s *GRPCClient) CompleteMultipartUpload(ctx context.Context, entryID string, uploadID string, parts []models.UploadedPart) error {
+	req := &pb.CompleteMultipartUploadRequest{EntryId: entryID, UploadId: uploadID}
+	for _, p := range parts {
+		req.Parts = append(req.Parts, &pb.UploadedPart{PartNumber: p.PartNumber, Etag: p.ETag})
+	}
+	if _, err := s.client.CompleteMultipartUpload(ctx, req); err != nil {
+		return s.mapError(err)
+	}
+	return nil
+}
+
+// AbortMultipartUpload asks the server to discard a multipart upload.
+func (s *GRPCClient) AbortMultipartUpload(ctx context.Context, entryID string, uploadID string) error {
+	req := &pb.AbortMultipartUploadRequest{EntryId: entryID, UploadId: uploadID}
+	if _, err := s.client.AbortMultipartUpload(ctx, req); err != nil {
+		return s.mapError(err)
+	}
+	return nil
+}
+
+// RequestUploadURL requests a fresh temporary signed URL for uploading the
+// pending file attached to entryID.
+func (s *GRPCClient) RequestUploadURL(ctx context.Context, entryID string) (string, error) {
+	req := &pb.RequestUploadURLRequest{EntryId: entryID}
+	res, err := s.client.RequestUploadURL(ctx, req)
+	if err != nil {
+		return "", s.mapError(err)
+	}
+	return res.Url, nil
+}
+
 // GetPresignedGetURL requests a temporary signed URL for downloading the
 // encrypted file associated with entryID.
 func (s *GRPCClient) GetPresignedGetURL(ctx context.Context, entryID string) (string, error) {
@@ -295,3 +666,54 @@ func (s *GRPCClient) GetPresignedGetURL(ctx context.Context, entryID string) (st
 	}
 	return res.Url, nil
 }
+
+// ListRevisions fetches the stored versions of entryID, newest first.
+func (s *GRPCClient) ListRevisions(ctx context.Context, entryID string) ([]*models.Entry, error) {
+	res, err := s.client.ListRevisions(ctx, &pb.ListRevisionsRequest{EntryId: entryID})
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	revs := make([]*models.Entry, 0, len(res.Revisions))
+	for _, r := range res.Revisions {
+		revs = append(revs, revisionFromPB(r))
+	}
+	return revs, nil
+}
+
+// GetRevision fetches a single stored version of entryID.
+func (s *GRPCClient) GetRevision(ctx context.Context, entryID string, version int64) (*models.Entry, error) {
+	res, err := s.client.GetRevision(ctx, &pb.GetRevisionRequest{EntryId: entryID, Version: version})
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	return revisionFromPB(res.Revision), nil
+}
+
+// revisionFromPB maps a protobuf revision to a local entry snapshot.
+func revisionFromPB(r *pb.Revision) *models.Entry {
+	return &models.Entry{
+		Id:            r.EntryId,
+		Version:       r.Version,
+		Deleted:       r.Deleted,
+		Overview:      r.Overview,
+		NonceOverview: r.NonceOverview,
+		Details:       r.Details,
+		NonceDetails:  r.NonceDetails,
+		UpdatedAt:     time.Unix(r.CreatedAt, 0).UTC(),
+	}
+}
+
+// kdfToPB maps KDF parameters to their protobuf form.
+func kdfToPB(p cryptox.KDFParams) *pb.KDFParams {
+	return &pb.KDFParams{Algorithm: p.Algorithm, Time: p.Time, Memory: p.Memory, Threads: uint32(p.Threads)}
+}
+
+// kdfFromPB maps protobuf KDF parameters back. Out of range thread counts
+// are mapped to 0, which fails validation when keys are derived.
+func kdfFromPB(p *pb.KDFParams) cryptox.KDFParams {
+	threads := uint8(p.Threads)
+	if p.Threads > 255 {
+		threads = 0
+	}
+	return cryptox.KDFParams{Algorithm: p.Algorithm, Time: p.Time, Memory: p.Memory, Threads: threads}
+}
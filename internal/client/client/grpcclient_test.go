@@ -3,10 +3,13 @@ package client
 import (
 	"context"
 	"errors"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/client/models"
 	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
 	pb "github.com/dmitrijs2005/gophkeeper/internal/proto"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
@@ -25,10 +28,24 @@ type fakePB struct {
 	lastPingReq         *pb.PingRequest
 	lastGetSaltReq      *pb.GetSaltRequest
 	lastLoginReq        *pb.LoginRequest
+	lastLoginStartReq   *pb.LoginStartRequest
+	lastLoginFinishReq  *pb.LoginFinishRequest
+	lastUpgradeKeysReq  *pb.UpgradeKeysRequest
+	lastChangePassReq   *pb.ChangePasswordRequest
+	lastUpgradeKDFReq   *pb.UpgradeKDFRequest
 	lastRegisterReq     *pb.RegisterUserRequest
 	lastSyncReq         *pb.SyncRequest
 	lastMarkUploadedReq *pb.MarkUploadedRequest
 	lastGetURLReq       *pb.GetPresignedGetUrlRequest
+	lastListRevsReq     *pb.ListRevisionsRequest
+	lastGetRevReq       *pb.GetRevisionRequest
+	lastUpdateKeyReq    *pb.UpdateFileKeyRequest
+	lastPresignPartsReq *pb.PresignUploadPartsRequest
+	lastCompleteReq     *pb.CompleteMultipartUploadRequest
+	lastRevokeReq       *pb.RevokeSessionRequest
+	lastVerify2FAReq    *pb.Verify2FARequest
+	lastConfirm2FAReq   *pb.Confirm2FARequest
+	lastEnable2FAReq    *pb.Enable2FARequest
 
 	// outputs preset
 	refreshTokenResp *pb.RefreshTokenResponse
@@ -43,6 +60,20 @@ type fakePB struct {
 	loginResp *pb.LoginResponse
 	loginErr  error
 
+	loginStartResp  *pb.LoginStartResponse
+	loginFinishResp *pb.LoginFinishResponse
+	upgradeKeysErr  error
+
+	changePassResp *pb.ChangePasswordResponse
+	changePassErr  error
+
+	upgradeKDFResp *pb.UpgradeKDFResponse
+	upgradeKDFErr  error
+
+	presignPartsResp *pb.PresignUploadPartsResponse
+	multipartErr     error
+	uploadURLErr     error
+
 	registerErr error
 
 	syncResp *pb.SyncResponse
@@ -52,6 +83,24 @@ type fakePB struct {
 
 	getURLResp *pb.GetPresignedGetUrlResponse
 	getURLErr  error
+
+	listRevsResp *pb.ListRevisionsResponse
+	getRevResp   *pb.GetRevisionResponse
+	revErr       error
+
+	updateKeyErr error
+
+	sessionsResp  *pb.ListSessionsResponse
+	revokedOthers int64
+	sessionErr    error
+
+	logoutCalls int
+	logoutErr   error
+
+	verify2FAResp  *pb.Verify2FAResponse
+	enable2FAResp  *pb.Enable2FAResponse
+	confirm2FAResp *pb.Confirm2FAResponse
+	twoFactorErr   error
 }
 
 func (f *fakePB) RefreshToken(ctx context.Context, in *pb.RefreshTokenRequest, opts ...grpc.CallOption) (*pb.RefreshTokenResponse, error) {
@@ -70,6 +119,26 @@ func (f *fakePB) Login(ctx context.Context, in *pb.LoginRequest, opts ...grpc.Ca
 	f.lastLoginReq = in
 	return f.loginResp, f.loginErr
 }
+func (f *fakePB) LoginStart(ctx context.Context, in *pb.LoginStartRequest, opts ...grpc.CallOption) (*pb.LoginStartResponse, error) {
+	f.lastLoginStartReq = in
+	return f.loginStartResp, f.loginErr
+}
+func (f *fakePB) LoginFinish(ctx context.Context, in *pb.LoginFinishRequest, opts ...grpc.CallOption) (*pb.LoginFinishResponse, error) {
+	f.lastLoginFinishReq = in
+	return f.loginFinishResp, f.loginErr
+}
+func (f *fakePB) ChangePassword(ctx context.Context, in *pb.ChangePasswordRequest, opts ...grpc.CallOption) (*pb.ChangePasswordResponse, error) {
+	f.lastChangePassReq = in
+	return f.changePassResp, f.changePassErr
+}
+func (f *fakePB) UpgradeKDF(ctx context.Context, in *pb.UpgradeKDFRequest, opts ...grpc.CallOption) (*pb.UpgradeKDFResponse, error) {
+	f.lastUpgradeKDFReq = in
+	return f.upgradeKDFResp, f.upgradeKDFErr
+}
+func (f *fakePB) UpgradeKeys(ctx context.Context, in *pb.UpgradeKeysRequest, opts ...grpc.CallOption) (*pb.UpgradeKeysResponse, error) {
+	f.lastUpgradeKeysReq = in
+	return &pb.UpgradeKeysResponse{}, f.upgradeKeysErr
+}
 func (f *fakePB) RegisterUser(ctx context.Context, in *pb.RegisterUserRequest, opts ...grpc.CallOption) (*pb.RegisterUserResponse, error) {
 	f.lastRegisterReq = in
 	return &pb.RegisterUserResponse{}, f.registerErr
@@ -87,6 +156,65 @@ func (f *fakePB) GetPresignedGetUrl(ctx context.Context, in *pb.GetPresignedGetU
 	return f.getURLResp, f.getURLErr
 }
 
+func (f *fakePB) ListRevisions(ctx context.Context, in *pb.ListRevisionsRequest, opts ...grpc.CallOption) (*pb.ListRevisionsResponse, error) {
+	f.lastListRevsReq = in
+	return f.listRevsResp, f.revErr
+}
+func (f *fakePB) GetRevision(ctx context.Context, in *pb.GetRevisionRequest, opts ...grpc.CallOption) (*pb.GetRevisionResponse, error) {
+	f.lastGetRevReq = in
+	return f.getRevResp, f.revErr
+}
+
+func (f *fakePB) UpdateFileKey(ctx context.Context, in *pb.UpdateFileKeyRequest, opts ...grpc.CallOption) (*pb.UpdateFileKeyResponse, error) {
+	f.lastUpdateKeyReq = in
+	return &pb.UpdateFileKeyResponse{}, f.updateKeyErr
+}
+
+func (f *fakePB) StartMultipartUpload(ctx context.Context, in *pb.StartMultipartUploadRequest, opts ...grpc.CallOption) (*pb.StartMultipartUploadResponse, error) {
+	return &pb.StartMultipartUploadResponse{UploadId: "up-" + in.EntryId}, f.multipartErr
+}
+func (f *fakePB) PresignUploadParts(ctx context.Context, in *pb.PresignUploadPartsRequest, opts ...grpc.CallOption) (*pb.PresignUploadPartsResponse, error) {
+	f.lastPresignPartsReq = in
+	return f.presignPartsResp, f.multipartErr
+}
+func (f *fakePB) CompleteMultipartUpload(ctx context.Context, in *pb.CompleteMultipartUploadRequest, opts ...grpc.CallOption) (*pb.CompleteMultipartUploadResponse, error) {
+	f.lastCompleteReq = in
+	return &pb.CompleteMultipartUploadResponse{}, f.multipartErr
+}
+func (f *fakePB) AbortMultipartUpload(ctx context.Context, in *pb.AbortMultipartUploadRequest, opts ...grpc.CallOption) (*pb.AbortMultipartUploadResponse, error) {
+	return &pb.AbortMultipartUploadResponse{}, f.multipartErr
+}
+func (f *fakePB) RequestUploadURL(ctx context.Context, in *pb.RequestUploadURLRequest, opts ...grpc.CallOption) (*pb.RequestUploadURLResponse, error) {
+	return &pb.RequestUploadURLResponse{Url: "put-" + in.EntryId}, f.uploadURLErr
+}
+func (f *fakePB) ListSessions(ctx context.Context, in *pb.ListSessionsRequest, opts ...grpc.CallOption) (*pb.ListSessionsResponse, error) {
+	return f.sessionsResp, f.sessionErr
+}
+func (f *fakePB) RevokeSession(ctx context.Context, in *pb.RevokeSessionRequest, opts ...grpc.CallOption) (*pb.RevokeSessionResponse, error) {
+	f.lastRevokeReq = in
+	return &pb.RevokeSessionResponse{}, f.sessionErr
+}
+func (f *fakePB) RevokeAllOtherSessions(ctx context.Context, in *pb.RevokeAllOtherSessionsRequest, opts ...grpc.CallOption) (*pb.RevokeAllOtherSessionsResponse, error) {
+	return &pb.RevokeAllOtherSessionsResponse{Revoked: f.revokedOthers}, f.sessionErr
+}
+
+func (f *fakePB) Logout(ctx context.Context, in *pb.LogoutRequest, opts ...grpc.CallOption) (*pb.LogoutResponse, error) {
+	f.logoutCalls++
+	return &pb.LogoutResponse{}, f.logoutErr
+}
+func (f *fakePB) Verify2FA(ctx context.Context, in *pb.Verify2FARequest, opts ...grpc.CallOption) (*pb.Verify2FAResponse, error) {
+	f.lastVerify2FAReq = in
+	return f.verify2FAResp, f.twoFactorErr
+}
+func (f *fakePB) Enable2FA(ctx context.Context, in *pb.Enable2FARequest, opts ...grpc.CallOption) (*pb.Enable2FAResponse, error) {
+	f.lastEnable2FAReq = in
+	return f.enable2FAResp, f.twoFactorErr
+}
+func (f *fakePB) Confirm2FA(ctx context.Context, in *pb.Confirm2FARequest, opts ...grpc.CallOption) (*pb.Confirm2FAResponse, error) {
+	f.lastConfirm2FAReq = in
+	return f.confirm2FAResp, f.twoFactorErr
+}
+
 /*************
  * accessTokenInterceptor tests
  *************/
@@ -158,6 +286,31 @@ func TestInterceptor_UnauthenticatedButDifferentMessage_NoRefresh(t *testing.T)
 	require.Error(t, err)
 }
 
+func TestInterceptor_RateLimited(t *testing.T) {
+	c := &GRPCClient{accessToken: "X"}
+	retryAfter := "42"
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		for _, o := range opts {
+			if tr, ok := o.(grpc.TrailerCallOption); ok && retryAfter != "" {
+				*tr.TrailerAddr = metadata.Pairs(common.RetryAfterTrailerName, retryAfter)
+			}
+		}
+		return status.Error(codes.ResourceExhausted, "too many attempts")
+	}
+
+	err := c.accessTokenInterceptor(context.Background(), "/svc/Method", nil, nil, nil, invoker)
+	var rl *RateLimitError
+	require.ErrorAs(t, err, &rl)
+	require.Equal(t, 42*time.Second, rl.RetryAfter)
+	require.ErrorIs(t, c.mapError(err), ErrRateLimited)
+
+	retryAfter = ""
+	err = c.accessTokenInterceptor(context.Background(), "/svc/Method", nil, nil, nil, invoker)
+	require.ErrorAs(t, err, &rl)
+	require.Zero(t, rl.RetryAfter)
+	require.EqualError(t, err, "too many attempts")
+}
+
 /*************
  * mapError tests
  *************/
@@ -169,6 +322,8 @@ func TestMapError(t *testing.T) {
 	require.Equal(t, ErrUnauthorized, c.mapError(status.Error(codes.PermissionDenied, "x")))
 	require.Equal(t, ErrUnavailable, c.mapError(status.Error(codes.Unavailable, "x")))
 	require.Equal(t, ErrUnavailable, c.mapError(status.Error(codes.DeadlineExceeded, "x")))
+	require.Equal(t, ErrNotFound, c.mapError(status.Error(codes.NotFound, "x")))
+	require.Equal(t, ErrResyncRequired, c.mapError(status.Error(codes.FailedPrecondition, "x")))
 	e := errors.New("plain")
 	require.ErrorContains(t, c.mapError(e), "rpc error:")
 }
@@ -200,12 +355,25 @@ func TestPing_MapsRPCError(t *testing.T) {
  *************/
 
 func TestGetSalt_Success(t *testing.T) {
-	f := &fakePB{getSaltResp: &pb.GetSaltResponse{Salt: []byte{1, 2, 3}}}
+	f := &fakePB{getSaltResp: &pb.GetSaltResponse{Salt: []byte{1, 2, 3}, LegacyKeys: true}}
 	c := &GRPCClient{client: f}
-	salt, err := c.GetSalt(context.Background(), "u")
+	info, err := c.GetSalt(context.Background(), "u")
 	require.NoError(t, err)
-	require.Equal(t, []byte{1, 2, 3}, salt)
+	require.Equal(t, []byte{1, 2, 3}, info.Salt)
+	require.True(t, info.LegacyKeys)
+	require.Equal(t, cryptox.LegacyKDFParams, info.KDF, "servers without KDF parameters use the legacy ones")
+	require.Nil(t, info.KDFUpgrade)
 	require.Equal(t, "u", f.lastGetSaltReq.Username)
+
+	f.getSaltResp = &pb.GetSaltResponse{
+		Salt:       []byte{1},
+		Kdf:        &pb.KDFParams{Algorithm: "argon2id", Time: 1, Memory: 65536, Threads: 4},
+		KdfUpgrade: &pb.KDFParams{Algorithm: "argon2id", Time: 3, Memory: 65536, Threads: 4},
+	}
+	info, err = c.GetSalt(context.Background(), "u")
+	require.NoError(t, err)
+	require.Equal(t, cryptox.LegacyKDFParams, info.KDF)
+	require.Equal(t, &cryptox.DefaultKDFParams, info.KDFUpgrade)
 }
 
 func TestGetSalt_MapsError(t *testing.T) {
@@ -218,21 +386,221 @@ func TestGetSalt_MapsError(t *testing.T) {
 func TestLogin_SetsTokens(t *testing.T) {
 	f := &fakePB{loginResp: &pb.LoginResponse{AccessToken: "A", RefreshToken: "R"}}
 	c := &GRPCClient{client: f}
-	require.NoError(t, c.Login(context.Background(), "u", []byte{9}))
+	require.NoError(t, c.Login(context.Background(), "u", []byte{9}, []byte{7}, []byte{5}))
 	require.Equal(t, "A", c.accessToken)
 	require.Equal(t, "R", c.refreshToken)
 	require.Equal(t, "u", f.lastLoginReq.Username)
 	require.Equal(t, []byte{9}, f.lastLoginReq.VerifierCandidate)
+	require.Equal(t, []byte{7}, f.lastLoginReq.SrpVerifier)
+	require.Equal(t, []byte{5}, f.lastLoginReq.WrappedVaultKey)
+	require.NotNil(t, f.lastLoginReq.Device)
+}
+
+func TestLoginStartFinish_SetsTokens(t *testing.T) {
+	f := &fakePB{
+		loginStartResp:  &pb.LoginStartResponse{SessionId: "s1", ServerPublic: []byte{2}},
+		loginFinishResp: &pb.LoginFinishResponse{ServerProof: []byte{4}, AccessToken: "A", RefreshToken: "R", WrappedVaultKey: []byte{6}},
+	}
+	c := &GRPCClient{client: f}
+
+	id, pub, err := c.LoginStart(context.Background(), "u", []byte{1})
+	require.NoError(t, err)
+	require.Equal(t, "s1", id)
+	require.Equal(t, []byte{2}, pub)
+	require.Equal(t, "u", f.lastLoginStartReq.Username)
+	require.Equal(t, []byte{1}, f.lastLoginStartReq.ClientPublic)
+
+	proof, wrapped, challenge, err := c.LoginFinish(context.Background(), "s1", []byte{3})
+	require.NoError(t, err)
+	require.Equal(t, []byte{4}, proof)
+	require.Equal(t, []byte{6}, wrapped)
+	require.Empty(t, challenge)
+	require.Equal(t, "A", c.accessToken)
+	require.Equal(t, "R", c.refreshToken)
+	require.Equal(t, "s1", f.lastLoginFinishReq.SessionId)
+	require.Equal(t, []byte{3}, f.lastLoginFinishReq.ClientProof)
+	require.Equal(t, runtime.GOOS+"/"+runtime.GOARCH, f.lastLoginFinishReq.Device.GetPlatform())
+}
+
+func TestDeviceInfo(t *testing.T) {
+	old := hostname
+	t.Cleanup(func() { hostname = old })
+
+	hostname = func() (string, error) { return "laptop", nil }
+	d := deviceInfo()
+	require.Equal(t, "laptop", d.Name)
+	require.Equal(t, runtime.GOOS+"/"+runtime.GOARCH, d.Platform)
+
+	hostname = func() (string, error) { return "", errors.New("no hostname") }
+	require.Empty(t, deviceInfo().Name)
+}
+
+func TestLoginFinish_MapsError(t *testing.T) {
+	f := &fakePB{loginErr: status.Error(codes.Unauthenticated, "no")}
+	c := &GRPCClient{client: f}
+	_, _, _, err := c.LoginFinish(context.Background(), "s1", []byte{3})
+	require.ErrorIs(t, err, ErrUnauthorized)
+	require.Empty(t, c.accessToken)
+}
+
+func TestLoginFinish_TwoFactor(t *testing.T) {
+	f := &fakePB{
+		loginFinishResp: &pb.LoginFinishResponse{ServerProof: []byte{4}, TwoFactorChallenge: "c1"},
+		verify2FAResp:   &pb.Verify2FAResponse{AccessToken: "A", RefreshToken: "R", WrappedVaultKey: []byte{6}},
+	}
+	c := &GRPCClient{client: f}
+
+	proof, wrapped, challenge, err := c.LoginFinish(context.Background(), "s1", []byte{3})
+	require.NoError(t, err)
+	require.Equal(t, []byte{4}, proof)
+	require.Nil(t, wrapped)
+	require.Equal(t, "c1", challenge)
+	require.Empty(t, c.accessToken)
+
+	wrapped, err = c.Verify2FA(context.Background(), "c1", "123456")
+	require.NoError(t, err)
+	require.Equal(t, []byte{6}, wrapped)
+	require.Equal(t, "A", c.accessToken)
+	require.Equal(t, "R", c.refreshToken)
+	require.Equal(t, "c1", f.lastVerify2FAReq.ChallengeId)
+	require.Equal(t, "123456", f.lastVerify2FAReq.Code)
+	require.NotNil(t, f.lastVerify2FAReq.Device)
+
+	f.twoFactorErr = status.Error(codes.Unauthenticated, "unauthorized")
+	_, err = c.Verify2FA(context.Background(), "c1", "000000")
+	require.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestEnableConfirm2FA(t *testing.T) {
+	f := &fakePB{
+		enable2FAResp:  &pb.Enable2FAResponse{Secret: "SECRET", OtpauthUri: "otpauth://totp/x"},
+		confirm2FAResp: &pb.Confirm2FAResponse{RecoveryCodes: []string{"AAAA-BBBB"}},
+	}
+	c := &GRPCClient{client: f}
+
+	secret, uri, err := c.Enable2FA(context.Background(), "654321")
+	require.NoError(t, err)
+	require.Equal(t, "SECRET", secret)
+	require.Equal(t, "otpauth://totp/x", uri)
+	require.Equal(t, "654321", f.lastEnable2FAReq.Code)
+
+	recovery, err := c.Confirm2FA(context.Background(), "123456")
+	require.NoError(t, err)
+	require.Equal(t, []string{"AAAA-BBBB"}, recovery)
+	require.Equal(t, "123456", f.lastConfirm2FAReq.Code)
+
+	f.twoFactorErr = status.Error(codes.InvalidArgument, "invalid code")
+	_, err = c.Confirm2FA(context.Background(), "000000")
+	require.ErrorIs(t, err, ErrInvalidCode)
+	f.twoFactorErr = status.Error(codes.Unavailable, "down")
+	_, err = c.Confirm2FA(context.Background(), "000000")
+	require.ErrorIs(t, err, ErrUnavailable)
+
+	// replacing a second factor takes a current code of it
+	f.twoFactorErr = status.Error(codes.FailedPrecondition, "second factor required")
+	_, _, err = c.Enable2FA(context.Background(), "")
+	require.ErrorIs(t, err, ErrSecondFactorRequired)
+	f.twoFactorErr = status.Error(codes.Unauthenticated, "invalid code")
+	_, _, err = c.Enable2FA(context.Background(), "000000")
+	require.ErrorIs(t, err, ErrInvalidCode)
+	f.twoFactorErr = status.Error(codes.Unauthenticated, "session revoked")
+	_, _, err = c.Enable2FA(context.Background(), "000000")
+	require.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestUpgradeKeys_MapsReqAndError(t *testing.T) {
+	f := &fakePB{}
+	c := &GRPCClient{client: f}
+	require.NoError(t, c.UpgradeKeys(context.Background(), []byte{1}, []byte{2}))
+	require.Equal(t, []byte{1}, f.lastUpgradeKeysReq.SrpVerifier)
+	require.Equal(t, []byte{2}, f.lastUpgradeKeysReq.WrappedVaultKey)
+
+	f.upgradeKeysErr = status.Error(codes.PermissionDenied, "no")
+	require.ErrorIs(t, c.UpgradeKeys(context.Background(), nil, nil), ErrUnauthorized)
+}
+
+func TestChangePassword_SetsTokensAndMapsError(t *testing.T) {
+	f := &fakePB{changePassResp: &pb.ChangePasswordResponse{ServerProof: []byte{4}, AccessToken: "A", RefreshToken: "R"}}
+	c := &GRPCClient{client: f, accessToken: "old", refreshToken: "old"}
+
+	creds := models.Credentials{Salt: []byte{2}, KDF: cryptox.DefaultKDFParams, SRPVerifier: []byte{3}, WrappedVaultKey: []byte{5}}
+	proof, versions, err := c.ChangePassword(context.Background(), "s1", []byte{1}, creds, nil)
+	require.NoError(t, err)
+	require.Equal(t, []byte{4}, proof)
+	require.Empty(t, versions)
+	require.False(t, f.lastChangePassReq.Rekey)
+	require.Equal(t, "A", c.accessToken)
+	require.Equal(t, "R", c.refreshToken)
+	require.Equal(t, "s1", f.lastChangePassReq.SessionId)
+	require.Equal(t, []byte{1}, f.lastChangePassReq.ClientProof)
+	require.Equal(t, []byte{2}, f.lastChangePassReq.Salt)
+	require.Equal(t, []byte{3}, f.lastChangePassReq.SrpVerifier)
+	require.Equal(t, []byte{5}, f.lastChangePassReq.WrappedVaultKey)
+	require.Equal(t, uint32(3), f.lastChangePassReq.Kdf.Time)
+	require.NotNil(t, f.lastChangePassReq.Device)
+
+	f.changePassErr = status.Error(codes.Unauthenticated, "unauthorized")
+	_, _, err = c.ChangePassword(context.Background(), "s1", nil, models.Credentials{}, nil)
+	require.ErrorIs(t, err, ErrUnauthorized)
+	require.Equal(t, "A", c.accessToken)
+}
+
+func TestChangePassword_SendsRekeyAndReturnsVersions(t *testing.T) {
+	f := &fakePB{changePassResp: &pb.ChangePasswordResponse{ServerProof: []byte{4}, Versions: map[string]int64{"e1": 9}}}
+	c := &GRPCClient{client: f}
+
+	rekey := &models.VaultRekey{
+		Entries: []*models.Entry{{Id: "e1", Version: 3, Details: []byte{7}, Deleted: true}},
+		Files:   []*models.File{{EntryID: "e1", EncryptedFileKey: []byte{8}, Nonce: []byte{9}}},
+	}
+	_, versions, err := c.ChangePassword(context.Background(), "s1", []byte{1}, models.Credentials{}, rekey)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"e1": 9}, versions)
+	req := f.lastChangePassReq
+	require.True(t, req.Rekey)
+	require.Len(t, req.Entries, 1)
+	require.Equal(t, int64(3), req.Entries[0].BaseVersion)
+	require.Equal(t, []byte{7}, req.Entries[0].Details)
+	require.True(t, req.Entries[0].Deleted)
+	require.Len(t, req.Files, 1)
+	require.Equal(t, []byte{8}, req.Files[0].FileKey)
+	require.Equal(t, []byte{9}, req.Files[0].Nonce)
+
+	f.changePassErr = status.Error(codes.Aborted, "vault changed")
+	_, _, err = c.ChangePassword(context.Background(), "s1", []byte{1}, models.Credentials{}, rekey)
+	require.ErrorIs(t, err, ErrConflict)
+}
+
+func TestUpgradeKDF_SendsCredentialsAndMapsError(t *testing.T) {
+	f := &fakePB{upgradeKDFResp: &pb.UpgradeKDFResponse{ServerProof: []byte{4}}}
+	c := &GRPCClient{client: f, accessToken: "A"}
+
+	creds := models.Credentials{Salt: []byte{2}, KDF: cryptox.DefaultKDFParams, SRPVerifier: []byte{3}, WrappedVaultKey: []byte{5}}
+	proof, err := c.UpgradeKDF(context.Background(), "s1", []byte{1}, creds)
+	require.NoError(t, err)
+	require.Equal(t, []byte{4}, proof)
+	require.Equal(t, "s1", f.lastUpgradeKDFReq.SessionId)
+	require.Equal(t, []byte{1}, f.lastUpgradeKDFReq.ClientProof)
+	require.Equal(t, []byte{2}, f.lastUpgradeKDFReq.Salt)
+	require.Equal(t, []byte{3}, f.lastUpgradeKDFReq.SrpVerifier)
+	require.Equal(t, []byte{5}, f.lastUpgradeKDFReq.WrappedVaultKey)
+	require.Equal(t, &pb.KDFParams{Algorithm: "argon2id", Time: 3, Memory: 65536, Threads: 4}, f.lastUpgradeKDFReq.Kdf)
+
+	f.upgradeKDFErr = status.Error(codes.PermissionDenied, "permission denied")
+	_, err = c.UpgradeKDF(context.Background(), "s1", nil, models.Credentials{})
+	require.ErrorIs(t, err, ErrUnauthorized)
 }
 
 func TestRegister_MapsError(t *testing.T) {
 	f := &fakePB{registerErr: status.Error(codes.PermissionDenied, "no")}
 	c := &GRPCClient{client: f}
-	err := c.Register(context.Background(), "u", []byte{1}, []byte{2})
+	err := c.Register(context.Background(), "u", models.Credentials{Salt: []byte{1}, KDF: cryptox.DefaultKDFParams, SRPVerifier: []byte{2}, WrappedVaultKey: []byte{3}})
 	require.ErrorIs(t, err, ErrUnauthorized)
 	require.Equal(t, "u", f.lastRegisterReq.Username)
 	require.Equal(t, []byte{1}, f.lastRegisterReq.Salt)
 	require.Equal(t, []byte{2}, f.lastRegisterReq.Verifier)
+	require.Equal(t, []byte{3}, f.lastRegisterReq.WrappedVaultKey)
+	require.Equal(t, uint32(65536), f.lastRegisterReq.Kdf.Memory)
 }
 
 /*************
@@ -252,6 +620,9 @@ func TestSync_MapsReqAndResp(t *testing.T) {
 			ProcessedEntries: []*pb.Entry{
 				{Id: "e1", Version: 2, Deleted: false, Overview: []byte("ov2"), NonceOverview: []byte("no2"), Details: []byte("d2"), NonceDetails: []byte("nd2")},
 			},
+			Conflicts: []*pb.Entry{
+				{Id: "e4", Version: 9, Overview: []byte("ovC"), NonceOverview: []byte("noC"), Details: []byte("dC"), NonceDetails: []byte("ndC")},
+			},
 			NewEntries: []*pb.Entry{
 				{Id: "e2", Version: 1, Deleted: false, Overview: []byte("ovN"), NonceOverview: []byte("noN"), Details: []byte("dN"), NonceDetails: []byte("ndN")},
 			},
@@ -266,7 +637,7 @@ func TestSync_MapsReqAndResp(t *testing.T) {
 	}
 	c := &GRPCClient{client: f}
 
-	pe, ne, nf, ut, v, err := c.Sync(context.Background(), entries, files, 7)
+	pe, ce, ne, nf, ut, v, err := c.Sync(context.Background(), entries, files, 7)
 	require.NoError(t, err)
 	require.EqualValues(t, 42, v)
 
@@ -274,6 +645,7 @@ func TestSync_MapsReqAndResp(t *testing.T) {
 	require.Len(t, f.lastSyncReq.Entries, 1)
 	require.Equal(t, "e1", f.lastSyncReq.Entries[0].Id)
 	require.Equal(t, []byte("ov1"), f.lastSyncReq.Entries[0].Overview)
+	require.Equal(t, int64(1), f.lastSyncReq.Entries[0].BaseVersion)
 	require.Len(t, f.lastSyncReq.Files, 1)
 	require.Equal(t, "e1", f.lastSyncReq.Files[0].EntryId)
 	require.Equal(t, []byte("fk"), f.lastSyncReq.Files[0].FileKey)
@@ -281,6 +653,9 @@ func TestSync_MapsReqAndResp(t *testing.T) {
 	require.Len(t, pe, 1)
 	require.Equal(t, "e1", pe[0].Id)
 	require.Equal(t, []byte("ov2"), pe[0].Overview)
+	require.Len(t, ce, 1)
+	require.Equal(t, "e4", ce[0].Id)
+	require.Equal(t, int64(9), ce[0].Version)
 	require.Len(t, ne, 1)
 	require.Equal(t, "e2", ne[0].Id)
 	require.Len(t, nf, 1)
@@ -294,7 +669,7 @@ func TestSync_MapsReqAndResp(t *testing.T) {
 func TestSync_MapsError(t *testing.T) {
 	f := &fakePB{syncErr: status.Error(codes.Unavailable, "x")}
 	c := &GRPCClient{client: f}
-	_, _, _, _, _, err := c.Sync(context.Background(), nil, nil, 0)
+	_, _, _, _, _, _, err := c.Sync(context.Background(), nil, nil, 0)
 	require.ErrorIs(t, err, ErrUnavailable)
 }
 
@@ -330,3 +705,150 @@ func TestGetPresignedGetURL_MapsError(t *testing.T) {
 	_, err := c.GetPresignedGetURL(context.Background(), "e1")
 	require.ErrorIs(t, err, ErrUnavailable)
 }
+
+func TestListRevisions_MapsResp(t *testing.T) {
+	f := &fakePB{listRevsResp: &pb.ListRevisionsResponse{Revisions: []*pb.Revision{
+		{EntryId: "e1", Version: 4, Details: []byte("d"), NonceDetails: []byte("n"), CreatedAt: 1700000000},
+		{EntryId: "e1", Version: 2, Deleted: true},
+	}}}
+	c := &GRPCClient{client: f}
+
+	revs, err := c.ListRevisions(context.Background(), "e1")
+	require.NoError(t, err)
+	require.Equal(t, "e1", f.lastListRevsReq.EntryId)
+	require.Len(t, revs, 2)
+	require.Equal(t, int64(4), revs[0].Version)
+	require.Equal(t, []byte("d"), revs[0].Details)
+	require.Equal(t, int64(1700000000), revs[0].UpdatedAt.Unix())
+	require.True(t, revs[1].Deleted)
+}
+
+func TestGetRevision_MapsReqAndError(t *testing.T) {
+	f := &fakePB{getRevResp: &pb.GetRevisionResponse{Revision: &pb.Revision{EntryId: "e1", Version: 2}}}
+	c := &GRPCClient{client: f}
+
+	rev, err := c.GetRevision(context.Background(), "e1", 2)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), f.lastGetRevReq.Version)
+	require.Equal(t, int64(2), rev.Version)
+
+	f.revErr = status.Error(codes.NotFound, "x")
+	_, err = c.GetRevision(context.Background(), "e1", 9)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestUpdateFileKey_MapsReqAndError(t *testing.T) {
+	f := &fakePB{}
+	c := &GRPCClient{client: f}
+
+	require.NoError(t, c.UpdateFileKey(context.Background(), "e1", []byte("wk")))
+	require.Equal(t, "e1", f.lastUpdateKeyReq.EntryId)
+	require.Equal(t, []byte("wk"), f.lastUpdateKeyReq.FileKey)
+
+	f.updateKeyErr = status.Error(codes.NotFound, "x")
+	require.ErrorIs(t, c.UpdateFileKey(context.Background(), "e1", nil), ErrNotFound)
+}
+
+func TestMultipartUpload_MapsReqAndError(t *testing.T) {
+	f := &fakePB{presignPartsResp: &pb.PresignUploadPartsResponse{Urls: []string{"u1", "u2"}}}
+	c := &GRPCClient{client: f}
+	ctx := context.Background()
+
+	id, err := c.StartMultipartUpload(ctx, "e1")
+	require.NoError(t, err)
+	require.Equal(t, "up-e1", id)
+
+	urls, err := c.PresignUploadParts(ctx, "e1", id, []int32{1, 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{"u1", "u2"}, urls)
+	require.Equal(t, []int32{1, 2}, f.lastPresignPartsReq.PartNumbers)
+
+	// every requested part needs a URL
+	_, err = c.PresignUploadParts(ctx, "e1", id, []int32{1, 2, 3})
+	require.Error(t, err)
+
+	require.NoError(t, c.CompleteMultipartUpload(ctx, "e1", id, []models.UploadedPart{{PartNumber: 1, ETag: "a"}}))
+	require.Equal(t, "up-e1", f.lastCompleteReq.UploadId)
+	require.Equal(t, int32(1), f.lastCompleteReq.Parts[0].PartNumber)
+	require.Equal(t, "a", f.lastCompleteReq.Parts[0].Etag)
+
+	f.multipartErr = status.Error(codes.NotFound, "x")
+	_, err = c.StartMultipartUpload(ctx, "e1")
+	require.ErrorIs(t, err, ErrNotFound)
+	require.ErrorIs(t, c.AbortMultipartUpload(ctx, "e1", id), ErrNotFound)
+}
+
+func TestRequestUploadURL_MapsReqAndError(t *testing.T) {
+	f := &fakePB{}
+	c := &GRPCClient{client: f}
+
+	url, err := c.RequestUploadURL(context.Background(), "e1")
+	require.NoError(t, err)
+	require.Equal(t, "put-e1", url)
+
+	f.uploadURLErr = status.Error(codes.NotFound, "x")
+	_, err = c.RequestUploadURL(context.Background(), "e1")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSessions_MapReqRespAndError(t *testing.T) {
+	f := &fakePB{
+		sessionsResp: &pb.ListSessionsResponse{Sessions: []*pb.Session{{
+			Id:         "s1",
+			Device:     &pb.DeviceInfo{Name: "laptop", Platform: "linux/amd64", ClientVersion: "1.2.0"},
+			Ip:         "10.0.0.1",
+			CreatedAt:  1700000000,
+			LastUsedAt: 1700003600,
+			ExpiresAt:  1700007200,
+			Current:    true,
+		}}},
+		revokedOthers: 2,
+	}
+	c := &GRPCClient{client: f}
+
+	sessions, err := c.ListSessions(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []*models.Session{{
+		ID:            "s1",
+		DeviceName:    "laptop",
+		Platform:      "linux/amd64",
+		ClientVersion: "1.2.0",
+		IP:            "10.0.0.1",
+		CreatedAt:     time.Unix(1700000000, 0).UTC(),
+		LastUsedAt:    time.Unix(1700003600, 0).UTC(),
+		Expires:       time.Unix(1700007200, 0).UTC(),
+		Current:       true,
+	}}, sessions)
+
+	require.NoError(t, c.RevokeSession(context.Background(), "s2"))
+	require.Equal(t, "s2", f.lastRevokeReq.SessionId)
+	n, err := c.RevokeAllOtherSessions(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+
+	f.sessionErr = status.Error(codes.NotFound, "x")
+	require.ErrorIs(t, c.RevokeSession(context.Background(), "s2"), ErrNotFound)
+	f.sessionErr = status.Error(codes.Unauthenticated, "session revoked")
+	_, err = c.ListSessions(context.Background())
+	require.ErrorIs(t, err, ErrUnauthorized)
+	_, err = c.RevokeAllOtherSessions(context.Background())
+	require.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestLogout_ForgetsTokensAndMapsError(t *testing.T) {
+	f := &fakePB{}
+	c := &GRPCClient{client: f, accessToken: "A1", refreshToken: "R1"}
+	require.NoError(t, c.Logout(context.Background()))
+	require.Equal(t, 1, f.logoutCalls)
+	require.Empty(t, c.accessToken)
+	require.Empty(t, c.refreshToken)
+
+	// nothing to end after an offline login
+	require.NoError(t, c.Logout(context.Background()))
+	require.Equal(t, 1, f.logoutCalls)
+
+	f.logoutErr = status.Error(codes.Unavailable, "down")
+	c.accessToken, c.refreshToken = "A1", "R1"
+	require.ErrorIs(t, c.Logout(context.Background()), ErrUnavailable)
+	require.Empty(t, c.refreshToken)
+}
@@ -1,17 +1,34 @@
 package config
 
-import "time"
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/tlsx"
+)
 
 // Config holds runtime settings for the GophKeeper CLI.
 //
 // Fields:
 //   - ServerEndpointAddr: host:port of the backend gRPC endpoint.
 //   - OnlineCheckInterval: how often the client probes server reachability.
+//   - CAFile: PEM bundle of CAs trusted for the server certificate; the
+//     system roots when empty.
+//   - CertPins: base64 SHA-256 digests of public keys, one of which the
+//     server's certificate chain must contain (see tlsx.Pin).
+//   - ClientCertFile / ClientKeyFile: optional certificate presented to
+//     servers that require mutual TLS.
+//   - Insecure: connect without TLS, for development only.
 //
 // Units: OnlineCheckInterval is a time.Duration (e.g., 3*time.Second).
 type Config struct {
 	ServerEndpointAddr  string
 	OnlineCheckInterval time.Duration
+	CAFile              string
+	CertPins            []string
+	ClientCertFile      string
+	ClientKeyFile       string
+	Insecure            bool
 }
 
 // LoadDefaults populates c with sensible defaults.
@@ -20,6 +37,15 @@ func (c *Config) LoadDefaults() {
 	c.OnlineCheckInterval = 3 * time.Second
 }
 
+// TLSConfig returns the TLS configuration of the server connection, or nil
+// with Insecure.
+func (c *Config) TLSConfig() (*tls.Config, error) {
+	if c.Insecure {
+		return nil, nil
+	}
+	return tlsx.ClientConfig(c.CAFile, c.CertPins, c.ClientCertFile, c.ClientKeyFile)
+}
+
 // LoadConfig constructs a Config, applies defaults, then overlays values from
 // JSON (if present) and command-line flags (if present). Later sources take
 // precedence over earlier ones.
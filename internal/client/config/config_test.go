@@ -14,6 +14,7 @@ func TestLoadDefaults(t *testing.T) {
 
 	assert.Equal(t, "127.0.0.1:50051", c.ServerEndpointAddr)
 	assert.Equal(t, 3*time.Second, c.OnlineCheckInterval)
+	assert.False(t, c.Insecure)
 }
 
 func TestLoadConfig_UsesDefaultsBeforeParsing(t *testing.T) {
@@ -23,3 +24,19 @@ func TestLoadConfig_UsesDefaultsBeforeParsing(t *testing.T) {
 	assert.Equal(t, "127.0.0.1:50051", cfg.ServerEndpointAddr)
 	assert.Equal(t, 3*time.Second, cfg.OnlineCheckInterval)
 }
+
+func TestTLSConfig(t *testing.T) {
+	c := &Config{Insecure: true, CAFile: "missing.pem"}
+	tc, err := c.TLSConfig()
+	require.NoError(t, err)
+	assert.Nil(t, tc, "insecure connections have no TLS config")
+
+	c.Insecure = false
+	_, err = c.TLSConfig()
+	assert.Error(t, err, "a missing CA bundle must fail")
+
+	c.CAFile = ""
+	tc, err = c.TLSConfig()
+	require.NoError(t, err)
+	assert.NotNil(t, tc)
+}
@@ -10,6 +10,10 @@
 //
 //	-a string   address:port of the backend gRPC endpoint
 //	-i int      online status check interval (seconds)
+//	-ca string  PEM CA bundle trusted for the server certificate
+//	-pin string comma-separated SHA-256 public key pins of the server
+//	-cert string / -key string  client certificate and key for mutual TLS
+//	-insecure   connect without TLS (development only)
 //
 // # JSON schema
 //
@@ -18,12 +22,18 @@
 //
 //	{
 //	  "server_endpoint_addr": "127.0.0.1:50051",
-//	  "online_check_interval": "3s"
+//	  "online_check_interval": "3s",
+//	  "ca_file": "ca.pem",
+//	  "cert_pins": ["base64 SHA-256 of the server's public key"],
+//	  "client_cert_file": "client.pem",
+//	  "client_key_file": "client-key.pem",
+//	  "insecure": false
 //	}
 //
 // Primary API
 //
-//   - type Config                     — holds ServerEndpointAddr and OnlineCheckInterval
+//   - type Config                     — holds the endpoint, check interval and TLS settings
+//   - func (*Config) TLSConfig()      — builds the TLS configuration, nil when insecure
 //   - func LoadConfig() *Config       — builds Config by applying defaults, JSON, then flags
 //   - func (*Config) LoadDefaults()   — sets sensible defaults
 //
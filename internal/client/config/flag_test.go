@@ -22,6 +22,12 @@ func TestParseFlags(t *testing.T) {
 	}{
 		{name: "Test1 OK", args: []string{"cmd", "-a", "127.0.0.1:9090", "-i", "10"}, expectPanic: false,
 			expected: &Config{ServerEndpointAddr: "127.0.0.1:9090", OnlineCheckInterval: 10 * time.Second}},
+		{name: "Test3 TLS", args: []string{"cmd", "-a", "keeper:443", "-i", "3", "-ca", "ca.pem", "-pin", "pin1,pin2",
+			"-cert", "client.pem", "-key", "client-key.pem"}, expectPanic: false,
+			expected: &Config{ServerEndpointAddr: "keeper:443", OnlineCheckInterval: 3 * time.Second, CAFile: "ca.pem",
+				CertPins: []string{"pin1", "pin2"}, ClientCertFile: "client.pem", ClientKeyFile: "client-key.pem"}},
+		{name: "Test4 insecure", args: []string{"cmd", "-i", "3", "-insecure"}, expectPanic: false,
+			expected: &Config{OnlineCheckInterval: 3 * time.Second, Insecure: true}},
 		{name: "Test2 incorrect check interval", args: []string{"cmd", "-a", "127.0.0.1:9090", "-i", "abc"}, expectPanic: true, expected: &Config{}},
 	}
 
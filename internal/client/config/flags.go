@@ -3,6 +3,7 @@ package config
 import (
 	"flag"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/flagx"
@@ -14,21 +15,35 @@ import (
 //
 //	-a string   address and port of the backend server (default from Config)
 //	-i int      online check interval in seconds (default from Config)
+//	-ca string      PEM CA bundle trusted for the server certificate
+//	-pin string     comma-separated public key pins of the server certificate
+//	-cert string    PEM client certificate for mutual TLS
+//	-key string     PEM private key of the client certificate
+//	-insecure       connect without TLS (development only)
 //
 // Note: The function filters os.Args to only include the flags it knows about,
 // using flagx.FilterArgs, to avoid interference with other components.
 func parseFlags(cfg *Config) {
 	// Filter args to include only those handled here.
-	args := flagx.FilterArgs(os.Args[1:], []string{"-a", "-i"})
+	args := flagx.FilterArgs(os.Args[1:], []string{"-a", "-i", "-ca", "-pin", "-cert", "-key", "-insecure"})
 
 	fs := flag.NewFlagSet("main", flag.ContinueOnError)
 
 	fs.StringVar(&cfg.ServerEndpointAddr, "a", cfg.ServerEndpointAddr, "address and port to access server")
 	onlineCheckInterval := fs.Int("i", int(cfg.OnlineCheckInterval.Seconds()), "online check interval (in seconds)")
+	fs.StringVar(&cfg.CAFile, "ca", cfg.CAFile, "PEM CA bundle trusted for the server certificate")
+	pins := fs.String("pin", strings.Join(cfg.CertPins, ","), "comma-separated SHA-256 public key pins of the server certificate")
+	fs.StringVar(&cfg.ClientCertFile, "cert", cfg.ClientCertFile, "PEM client certificate for mutual TLS")
+	fs.StringVar(&cfg.ClientKeyFile, "key", cfg.ClientKeyFile, "PEM private key of the client certificate")
+	fs.BoolVar(&cfg.Insecure, "insecure", cfg.Insecure, "connect without TLS (development only)")
 
 	if err := fs.Parse(args); err != nil {
 		panic(err)
 	}
 
 	cfg.OnlineCheckInterval = time.Duration(*onlineCheckInterval) * time.Second
+	cfg.CertPins = nil
+	if *pins != "" {
+		cfg.CertPins = strings.Split(*pins, ",")
+	}
 }
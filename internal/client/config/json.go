@@ -16,6 +16,11 @@ import (
 type JsonConfig struct {
 	ServerEndpointAddr  string         `json:"server_endpoint_addr"`
 	OnlineCheckInterval timex.Duration `json:"online_check_interval"`
+	CAFile              string         `json:"ca_file"`
+	CertPins            []string       `json:"cert_pins"`
+	ClientCertFile      string         `json:"client_cert_file"`
+	ClientKeyFile       string         `json:"client_key_file"`
+	Insecure            bool           `json:"insecure"`
 }
 
 // parseJson overlays Config with values loaded from a JSON file.
@@ -32,6 +37,7 @@ type JsonConfig struct {
 // Populated fields:
 //   - ServerEndpointAddr
 //   - OnlineCheckInterval
+//   - CAFile, CertPins, ClientCertFile, ClientKeyFile, Insecure
 //
 // Intended usage is: defaults -> parseJson -> parseFlags, where later stages
 // override earlier ones.
@@ -54,4 +60,9 @@ func parseJson(cfg *Config) {
 
 	cfg.ServerEndpointAddr = jc.ServerEndpointAddr
 	cfg.OnlineCheckInterval = time.Duration(jc.OnlineCheckInterval.Duration)
+	cfg.CAFile = jc.CAFile
+	cfg.CertPins = jc.CertPins
+	cfg.ClientCertFile = jc.ClientCertFile
+	cfg.ClientKeyFile = jc.ClientKeyFile
+	cfg.Insecure = jc.Insecure
 }
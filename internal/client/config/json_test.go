@@ -34,6 +34,11 @@ func Test_parseJson_SourcesAndPrecedence(t *testing.T) {
 	pathFlag := writeTempJSON(t, dir, "flag.json", map[string]any{
 		"server_endpoint_addr":  "www.example:9000",
 		"online_check_interval": "10s",
+		"ca_file":               "ca.pem",
+		"cert_pins":             []string{"pin1"},
+		"client_cert_file":      "client.pem",
+		"client_key_file":       "client-key.pem",
+		"insecure":              true,
 	})
 
 	t.Run("loads from flags", func(t *testing.T) {
@@ -44,6 +49,11 @@ func Test_parseJson_SourcesAndPrecedence(t *testing.T) {
 
 		assert.Equal(t, "www.example:9000", cfg.ServerEndpointAddr)
 		assert.Equal(t, 10*time.Second, cfg.OnlineCheckInterval)
+		assert.Equal(t, "ca.pem", cfg.CAFile)
+		assert.Equal(t, []string{"pin1"}, cfg.CertPins)
+		assert.Equal(t, "client.pem", cfg.ClientCertFile)
+		assert.Equal(t, "client-key.pem", cfg.ClientKeyFile)
+		assert.True(t, cfg.Insecure)
 	})
 
 	t.Run("no CONFIG and no flags → no changes", func(t *testing.T) {
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Server copies of entries whose local edits were rejected as stale
+CREATE TABLE IF NOT EXISTS conflicts (
+    entry_id        TEXT PRIMARY KEY,
+    version         BIGINT NOT NULL,
+    deleted         INTEGER NOT NULL DEFAULT 0,
+
+    overview        BLOB NOT NULL,
+    nonce_overview  BLOB NOT NULL,
+
+    details         BLOB NOT NULL,
+    nonce_details   BLOB NOT NULL,
+
+    created_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS conflicts;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Multipart upload in progress and the parts already stored, as a JSON list
+-- of {"part": n, "etag": "..."}
+ALTER TABLE files ADD COLUMN upload_id TEXT;
+ALTER TABLE files ADD COLUMN upload_parts TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE files DROP COLUMN upload_parts;
+ALTER TABLE files DROP COLUMN upload_id;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- SHA-256 of the file's ciphertext, checked before a download is decrypted
+ALTER TABLE files ADD COLUMN digest BLOB;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE files DROP COLUMN digest;
+-- +goose StatementEnd
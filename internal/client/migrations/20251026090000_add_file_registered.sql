@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Whether the server has a row for the pending file, i.e. the file only needs
+-- to be uploaded rather than pushed by the next sync
+ALTER TABLE files ADD COLUMN registered INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE files DROP COLUMN registered;
+-- +goose StatementEnd
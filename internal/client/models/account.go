@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
+)
+
+// SaltInfo is what the server tells a client about an account before login.
+type SaltInfo struct {
+	Salt []byte
+	// KDF are the parameters the account's master key is derived with; for
+	// unknown usernames they are the server's policy for new accounts.
+	KDF cryptox.KDFParams
+	// KDFUpgrade is the server's policy when KDF is below it; the client
+	// re-derives its keys with it after logging in. Nil otherwise.
+	KDFUpgrade *cryptox.KDFParams
+	// LegacyKeys is set for accounts that predate the auth key / vault key
+	// hierarchy.
+	LegacyKeys bool
+}
+
+// Credentials are the password-derived values the server stores for an
+// account: the salt and KDF parameters of the master key, the SRP verifier
+// over the auth key and the vault key wrapped with the key-encryption key.
+type Credentials struct {
+	Salt            []byte
+	KDF             cryptox.KDFParams
+	SRPVerifier     []byte
+	WrappedVaultKey []byte
+}
+
+// VaultRekey is the content of an account re-encrypted under a new vault
+// key, sent together with the Credentials wrapping that key: every entry
+// the server has, tombstones included, with Version set to the version it
+// was re-encrypted from, and the key of every live file, identified by the
+// file's Nonce.
+type VaultRekey struct {
+	Entries []*Entry
+	Files   []*File
+}
+
+// Session is a device logged into the account, as listed by the server.
+type Session struct {
+	ID            string
+	DeviceName    string
+	Platform      string
+	ClientVersion string
+	// IP is the address the session was last used from.
+	IP         string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	Expires    time.Time
+	// Current marks the session of this client.
+	Current bool
+}
@@ -0,0 +1,203 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Conflict pairs the local and server copies of an entry that diverged
+// during sync. Deleted copies are tombstones; their envelope is still set.
+type Conflict struct {
+	Id string
+
+	Local        *Envelope
+	LocalDeleted bool
+
+	Remote        *Envelope
+	RemoteDeleted bool
+	// RemoteVersion is the server version a resolution is based on.
+	RemoteVersion int64
+}
+
+// FieldDiff is one differing field of two envelopes. Name is "title",
+// "metadata.<name>" or the JSON name of a typed field (e.g., "password").
+// Missing values are reported as empty strings with the Has flag unset.
+type FieldDiff struct {
+	Name      string
+	Local     string
+	HasLocal  bool
+	Remote    string
+	HasRemote bool
+}
+
+const (
+	fieldTitle          = "title"
+	fieldMetadataPrefix = "metadata."
+)
+
+// Mergeable reports whether the envelopes can be merged field by field,
+// i.e. both hold the same entry type.
+func Mergeable(local, remote Envelope) bool {
+	return local.Type == remote.Type
+}
+
+// DiffEnvelopes compares title, metadata and the typed fields (decoded via
+// Unwrap) of two envelopes and returns the fields whose values differ, in a
+// stable order: title, metadata, then typed fields.
+func DiffEnvelopes(local, remote Envelope) ([]FieldDiff, error) {
+	var diffs []FieldDiff
+
+	if local.Title != remote.Title {
+		diffs = append(diffs, FieldDiff{Name: fieldTitle, Local: local.Title, HasLocal: true, Remote: remote.Title, HasRemote: true})
+	}
+
+	lmd, rmd := metadataMap(local.Metadata), metadataMap(remote.Metadata)
+	for _, name := range unionKeys(metadataNames(local.Metadata), metadataNames(remote.Metadata)) {
+		lv, lok := lmd[name]
+		rv, rok := rmd[name]
+		if lok != rok || lv != rv {
+			diffs = append(diffs, FieldDiff{Name: fieldMetadataPrefix + name, Local: lv, HasLocal: lok, Remote: rv, HasRemote: rok})
+		}
+	}
+
+	lf, lnames, err := typedFields(local)
+	if err != nil {
+		return nil, fmt.Errorf("local: %w", err)
+	}
+	rf, rnames, err := typedFields(remote)
+	if err != nil {
+		return nil, fmt.Errorf("remote: %w", err)
+	}
+	if local.Type != remote.Type {
+		diffs = append(diffs, FieldDiff{Name: "type", Local: string(local.Type), HasLocal: true, Remote: string(remote.Type), HasRemote: true})
+	}
+	for _, name := range unionKeys(lnames, rnames) {
+		lv, lok := lf[name]
+		rv, rok := rf[name]
+		if lok != rok || lv != rv {
+			diffs = append(diffs, FieldDiff{Name: name, Local: lv, HasLocal: lok, Remote: rv, HasRemote: rok})
+		}
+	}
+	return diffs, nil
+}
+
+// MergeEnvelopes builds an envelope from local, taking the value of every
+// field named in takeRemote (see FieldDiff.Name) from remote instead. A field
+// taken from a side that lacks it is dropped. Both envelopes must be
+// Mergeable.
+func MergeEnvelopes(local, remote Envelope, takeRemote map[string]bool) (Envelope, error) {
+	if !Mergeable(local, remote) {
+		return Envelope{}, fmt.Errorf("cannot merge %s with %s", local.Type, remote.Type)
+	}
+
+	out := Envelope{Type: local.Type, Title: local.Title}
+	if takeRemote[fieldTitle] {
+		out.Title = remote.Title
+	}
+
+	lmd, rmd := metadataMap(local.Metadata), metadataMap(remote.Metadata)
+	for _, name := range unionKeys(metadataNames(local.Metadata), metadataNames(remote.Metadata)) {
+		src := lmd
+		if takeRemote[fieldMetadataPrefix+name] {
+			src = rmd
+		}
+		if v, ok := src[name]; ok {
+			out.Metadata = append(out.Metadata, Metadata{Name: name, Value: v})
+		}
+	}
+
+	var ld, rd map[string]json.RawMessage
+	if err := json.Unmarshal(local.Details, &ld); err != nil {
+		return Envelope{}, fmt.Errorf("local details: %w", err)
+	}
+	if err := json.Unmarshal(remote.Details, &rd); err != nil {
+		return Envelope{}, fmt.Errorf("remote details: %w", err)
+	}
+	if ld == nil {
+		ld = map[string]json.RawMessage{}
+	}
+	for name, take := range takeRemote {
+		if !take || name == fieldTitle || strings.HasPrefix(name, fieldMetadataPrefix) {
+			continue
+		}
+		if v, ok := rd[name]; ok {
+			ld[name] = v
+		} else {
+			delete(ld, name)
+		}
+	}
+	details, err := json.Marshal(ld)
+	if err != nil {
+		return Envelope{}, err
+	}
+	out.Details = details
+	return out, nil
+}
+
+// typedFields decodes the envelope via Unwrap and returns its fields as
+// strings keyed by JSON name, plus the names in declaration order.
+func typedFields(e Envelope) (map[string]string, []string, error) {
+	x, err := e.Unwrap()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fields := map[string]string{}
+	var names []string
+
+	if m, ok := x.(map[string]any); ok {
+		for k, v := range m {
+			fields[k] = fmt.Sprint(v)
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return fields, names, nil
+	}
+
+	v := reflect.ValueOf(x)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("json")
+		if name == "" || name == "-" {
+			name = t.Field(i).Name
+		}
+		fields[name] = fmt.Sprint(v.Field(i).Interface())
+		names = append(names, name)
+	}
+	return fields, names, nil
+}
+
+func metadataMap(md []Metadata) map[string]string {
+	m := make(map[string]string, len(md))
+	for _, item := range md {
+		if _, seen := m[item.Name]; !seen {
+			m[item.Name] = item.Value
+		}
+	}
+	return m
+}
+
+func metadataNames(md []Metadata) []string {
+	names := make([]string, 0, len(md))
+	for _, item := range md {
+		names = append(names, item.Name)
+	}
+	return names
+}
+
+// unionKeys returns the distinct keys of a followed by those only in b,
+// preserving order.
+func unionKeys(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var out []string
+	for _, k := range append(append([]string{}, a...), b...) {
+		if !seen[k] {
+			seen[k] = true
+			out = append(out, k)
+		}
+	}
+	return out
+}
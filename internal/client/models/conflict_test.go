@@ -0,0 +1,65 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffEnvelopes_TitleMetadataAndTypedFields(t *testing.T) {
+	local, err := Wrap(EntryTypeLogin, "mail", []Metadata{{Name: "site", Value: "a"}, {Name: "tag", Value: "x"}},
+		Login{Username: "bob", Password: "p1", URL: "u"})
+	require.NoError(t, err)
+	remote, err := Wrap(EntryTypeLogin, "Mail", []Metadata{{Name: "site", Value: "b"}, {Name: "owner", Value: "me"}},
+		Login{Username: "bob", Password: "p2", URL: "u"})
+	require.NoError(t, err)
+
+	diffs, err := DiffEnvelopes(local, remote)
+	require.NoError(t, err)
+	require.Equal(t, []FieldDiff{
+		{Name: "title", Local: "mail", HasLocal: true, Remote: "Mail", HasRemote: true},
+		{Name: "metadata.site", Local: "a", HasLocal: true, Remote: "b", HasRemote: true},
+		{Name: "metadata.tag", Local: "x", HasLocal: true},
+		{Name: "metadata.owner", Remote: "me", HasRemote: true},
+		{Name: "password", Local: "p1", HasLocal: true, Remote: "p2", HasRemote: true},
+	}, diffs)
+
+	same, err := DiffEnvelopes(local, local)
+	require.NoError(t, err)
+	require.Empty(t, same)
+}
+
+func TestDiffEnvelopes_DifferentTypes(t *testing.T) {
+	note, _ := Wrap(EntryTypeNote, "t", nil, Note{Text: "x"})
+	card, _ := Wrap(EntryTypeCreditCard, "t", nil, CreditCard{Number: "1"})
+
+	diffs, err := DiffEnvelopes(note, card)
+	require.NoError(t, err)
+	require.Equal(t, "type", diffs[0].Name)
+	require.False(t, Mergeable(note, card))
+
+	_, err = MergeEnvelopes(note, card, nil)
+	require.Error(t, err)
+}
+
+func TestMergeEnvelopes_TakesSelectedFieldsFromRemote(t *testing.T) {
+	local, _ := Wrap(EntryTypeLogin, "mail", []Metadata{{Name: "site", Value: "a"}, {Name: "tag", Value: "x"}},
+		Login{Username: "bob", Password: "p1", URL: "u1"})
+	remote, _ := Wrap(EntryTypeLogin, "Mail", []Metadata{{Name: "site", Value: "b"}, {Name: "owner", Value: "me"}},
+		Login{Username: "bob", Password: "p2", URL: "u2"})
+
+	merged, err := MergeEnvelopes(local, remote, map[string]bool{
+		"title":          true,
+		"metadata.tag":   true, // missing on remote: dropped
+		"metadata.owner": true,
+		"password":       true,
+		"url":            false,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Mail", merged.Title)
+	require.Equal(t, []Metadata{{Name: "site", Value: "a"}, {Name: "owner", Value: "me"}}, merged.Metadata)
+
+	x, err := merged.Unwrap()
+	require.NoError(t, err)
+	require.Equal(t, Login{Username: "bob", Password: "p2", URL: "u1"}, x)
+}
@@ -15,6 +15,9 @@ type Entry struct {
 	// Deleted marks the entry as a tombstone (kept for conflict-free sync).
 	Deleted bool
 
+	// Pending reports local changes that have not been pushed yet.
+	Pending bool
+
 	// Overview contains encrypted, short summary bytes (human preview).
 	Overview []byte
 	// NonceOverview is the AEAD nonce for Overview.
@@ -30,4 +33,8 @@ type Entry struct {
 
 	// IsFile indicates that this entry represents a binary file payload.
 	IsFile bool
+
+	// LocalRevision is a local-only counter bumped on every local write; it lets
+	// Sync tell whether an entry changed while a push was in flight.
+	LocalRevision int64
 }
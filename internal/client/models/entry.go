@@ -2,9 +2,11 @@ package models
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -132,34 +134,38 @@ type CreditCard struct {
 func (x CreditCard) GetType() EntryType { return EntryTypeCreditCard }
 
 // BinaryFile references a local file path to be encrypted and uploaded.
+//
+// Unbound marks an attachment encrypted before files were bound to their
+// entry (see cryptox.EntryAAD). Only such an attachment may be decrypted
+// without its associated data; replacing it clears the flag.
 type BinaryFile struct {
-	Path string `json:"path"`
+	Path    string `json:"path"`
+	Unbound bool   `json:"unbound,omitempty"`
 }
 
 func (f BinaryFile) GetType() EntryType { return EntryTypeBinaryFile }
 
 // Materializer turns a typed value into a temporary encrypted file blob
-// ready for upload (plus the per-file key/nonce).
+// ready for upload (plus the per-file key/nonce). The blob is bound to the
+// entry it is attached to.
 type Materializer interface {
-	Materialize(ctx context.Context) (*File, error)
+	Materialize(ctx context.Context, entryID string) (*File, error)
 }
 
-// Materialize encrypts the file at BinaryFile.Path, writes its ciphertext to
-// a unique file under the local "preupload" directory, and returns a *File
-// containing the encrypted bytes' key/nonce and the temporary path.
+// Materialize encrypts the file at BinaryFile.Path, streams its ciphertext
+// to a unique file under the local "preupload" directory, and returns a *File
+// containing the encrypted bytes' key/nonce, the SHA-256 digest of the
+// ciphertext and the temporary path. The
+// ciphertext is bound to entryID, which is stored as File.EntryID. The
+// plaintext is never read into memory as a whole.
 //
 // Returned File.LocalPath points to the ciphertext, not the plaintext.
-func (f BinaryFile) Materialize(ctx context.Context) (*File, error) {
+func (f BinaryFile) Materialize(ctx context.Context, entryID string) (*File, error) {
 	dir, err := filex.EnsureSubdDir("preupload")
 	if err != nil {
 		return nil, fmt.Errorf("error creating dir: %w", err)
 	}
 
-	ef, err := cryptox.EncryptFile(f.Path)
-	if err != nil {
-		return nil, fmt.Errorf("error encrypting file: %w", err)
-	}
-
 	fn := uuid.New().String()
 	localPath := filepath.Join(dir, fn)
 
@@ -169,13 +175,18 @@ func (f BinaryFile) Materialize(ctx context.Context) (*File, error) {
 	}
 	defer file.Close()
 
-	if _, err = file.Write(ef.Cyphertext); err != nil {
-		return nil, fmt.Errorf("error writing temporary file: %w", err)
+	digest := sha256.New()
+	key, nonce, err := cryptox.EncryptFile(io.MultiWriter(file, digest), f.Path, cryptox.EntryAAD(entryID, cryptox.FieldFile))
+	if err != nil {
+		_ = os.Remove(localPath)
+		return nil, fmt.Errorf("error encrypting file: %w", err)
 	}
 
 	return &File{
-		EncryptedFileKey: ef.Key,
-		Nonce:            ef.Nonce,
+		EntryID:          entryID,
+		EncryptedFileKey: key,
+		Nonce:            nonce,
+		Digest:           digest.Sum(nil),
 		LocalPath:        localPath,
 	}, nil
 }
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
 	"github.com/stretchr/testify/require"
 )
 
@@ -102,8 +103,9 @@ func TestBinaryFile_Materialize_CreatesEncryptedTempFile(t *testing.T) {
 	require.NoError(t, os.WriteFile(srcPath, []byte("secret-data"), 0o600))
 
 	bf := BinaryFile{Path: srcPath}
-	f, err := bf.Materialize(context.Background())
+	f, err := bf.Materialize(context.Background(), "e1")
 	require.NoError(t, err)
+	require.Equal(t, "e1", f.EntryID)
 
 	require.NotNil(t, f.EncryptedFileKey)
 	require.NotNil(t, f.Nonce)
@@ -114,5 +116,11 @@ func TestBinaryFile_Materialize_CreatesEncryptedTempFile(t *testing.T) {
 	require.False(t, st.IsDir())
 	require.Greater(t, st.Size(), int64(0))
 
+	// the digest covers the ciphertext
+	ct, err := os.Open(f.LocalPath)
+	require.NoError(t, err)
+	defer ct.Close()
+	require.NoError(t, cryptox.VerifyDigest(ct, f.Digest))
+
 	require.Contains(t, f.LocalPath, string(filepath.Separator)+"preupload"+string(filepath.Separator))
 }
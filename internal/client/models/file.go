@@ -9,6 +9,13 @@ type FileUploadTask struct {
 	URL string
 }
 
+// UploadedPart is a finished part of a multipart upload: its 1-based number
+// and the ETag storage returned for it.
+type UploadedPart struct {
+	PartNumber int32  `json:"part"`
+	ETag       string `json:"etag"`
+}
+
 // File stores metadata for a file associated with an entry.
 //
 // EncryptedFileKey and Nonce are the per-file encryption materials (client-side
@@ -18,14 +25,29 @@ type FileUploadTask struct {
 type File struct {
 	// EntryID links this file to its parent entry.
 	EntryID string
-	// EncryptedFileKey is the (encrypted) symmetric key for the file contents.
+	// EncryptedFileKey is the symmetric key for the file contents, wrapped
+	// with the vault key (see cryptox.WrapKey). Materialize returns the raw
+	// key; it is wrapped before being stored. Rows written by older clients
+	// may still hold a raw key until they are migrated.
 	EncryptedFileKey []byte
 	// Nonce is the AEAD nonce used for file content encryption.
 	Nonce []byte
+	// Digest is the SHA-256 of the ciphertext, recorded when the file is
+	// encrypted and checked before a download is decrypted. Files encrypted
+	// by older clients have none.
+	Digest []byte
 	// LocalPath is a path to a locally stored ciphertext (temporary/staging).
 	LocalPath string
 	// UploadStatus indicates client-side upload progress, e.g. "pending"/"completed".
 	UploadStatus string
+	// UploadID is the multipart upload in progress for the file, if any.
+	UploadID string
+	// UploadedParts are the parts of that upload that are already stored.
+	UploadedParts []UploadedPart
+	// Registered is set once the server has a row for the pending file, so
+	// that later syncs only ask for a fresh upload URL instead of pushing it
+	// again.
+	Registered bool
 	// Deleted marks the file as a tombstone for synchronization/GC.
 	Deleted bool
 }
@@ -1,7 +1,17 @@
 package models
 
+import "time"
+
 type ViewOverview struct {
 	Id    string
 	Type  string
 	Title string
 }
+
+// ViewRevision is a decrypted server-side revision of an entry.
+type ViewRevision struct {
+	Version   int64
+	Deleted   bool
+	CreatedAt time.Time
+	Envelope  *Envelope
+}
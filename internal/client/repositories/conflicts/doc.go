@@ -0,0 +1,27 @@
+// Package conflicts provides the client-side persistence layer for sync
+// conflicts.
+//
+// # Overview
+//
+// A conflict is recorded when the server rejects a local edit because it was
+// made against a stale version, or when a newer server version arrives while
+// the entry still has unsynchronized local changes. The local copy stays in
+// the entries table (pending); the server copy is kept here, as an encrypted
+// Entry, until the user resolves the conflict. A SQLite-backed implementation
+// (SQLiteRepository) persists data via a dbx.DBTX (*sql.DB or *sql.Tx).
+//
+// Key Types
+//
+//   - type Repository        — contract used by higher-level services
+//   - type SQLiteRepository  — SQLite implementation over dbx.DBTX
+//
+// Typical Usage
+//
+//	repo := conflicts.NewSQLiteRepository(db)
+//	_ = repo.Save(ctx, serverCopy)
+//	all, _ := repo.GetAll(ctx)
+//	remote, _ := repo.GetByEntryID(ctx, entryID)
+//	_ = repo.DeleteByEntryID(ctx, entryID)
+//
+// See also: internal/client/models.Entry for field semantics.
+package conflicts
@@ -0,0 +1,24 @@
+package conflicts
+
+import (
+	"context"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/client/models"
+)
+
+// Repository stores the server side of unresolved sync conflicts, keyed by
+// entry ID. Implementations are typically backed by a local SQLite database.
+type Repository interface {
+	// Save records (or refreshes) the server copy of a conflicting entry.
+	Save(ctx context.Context, remote *models.Entry) error
+
+	// GetAll returns the server copies of all unresolved conflicts.
+	GetAll(ctx context.Context) ([]*models.Entry, error)
+
+	// GetByEntryID returns the server copy for the given entry ID.
+	GetByEntryID(ctx context.Context, id string) (*models.Entry, error)
+
+	// DeleteByEntryID removes the conflict for the given entry ID once it is
+	// resolved. Deleting a missing conflict is not an error.
+	DeleteByEntryID(ctx context.Context, id string) error
+}
@@ -0,0 +1,85 @@
+package conflicts
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/client/models"
+	"github.com/dmitrijs2005/gophkeeper/internal/dbx"
+)
+
+// SQLiteRepository implements Repository over a dbx.DBTX (*sql.DB or *sql.Tx).
+type SQLiteRepository struct {
+	db dbx.DBTX
+}
+
+// NewSQLiteRepository constructs a repository bound to the given DBTX.
+func NewSQLiteRepository(db dbx.DBTX) *SQLiteRepository {
+	return &SQLiteRepository{db: db}
+}
+
+// Save upserts the server copy of a conflicting entry by entry_id. A newer
+// server copy replaces the one recorded earlier.
+func (r *SQLiteRepository) Save(ctx context.Context, e *models.Entry) error {
+	query := ` INSERT INTO conflicts (entry_id, version, deleted, overview, nonce_overview, details, nonce_details)
+			values (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(entry_id) DO UPDATE SET version = excluded.version,
+				deleted = excluded.deleted,
+				overview = excluded.overview, 
+				nonce_overview = excluded.nonce_overview, 
+				details = excluded.details, 
+				nonce_details = excluded.nonce_details
+			WHERE conflicts.version <= excluded.version
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		e.Id, e.Version, e.Deleted, e.Overview, e.NonceOverview, e.Details, e.NonceDetails)
+	if err != nil {
+		return fmt.Errorf("failed to save conflict: %w", err)
+	}
+	return nil
+}
+
+// GetAll returns the server copies of all unresolved conflicts, oldest first.
+func (r *SQLiteRepository) GetAll(ctx context.Context) ([]*models.Entry, error) {
+	query := `select entry_id, version, deleted, overview, nonce_overview, details, nonce_details
+			from conflicts order by created_at, entry_id`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select conflicts: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*models.Entry
+	for rows.Next() {
+		e := &models.Entry{}
+		if err := rows.Scan(&e.Id, &e.Version, &e.Deleted, &e.Overview, &e.NonceOverview, &e.Details, &e.NonceDetails); err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetByEntryID returns the server copy recorded for entry id.
+func (r *SQLiteRepository) GetByEntryID(ctx context.Context, id string) (*models.Entry, error) {
+	query := `select entry_id, version, deleted, overview, nonce_overview, details, nonce_details
+			from conflicts where entry_id=?`
+	e := &models.Entry{}
+	err := r.db.QueryRowContext(ctx, query, id).
+		Scan(&e.Id, &e.Version, &e.Deleted, &e.Overview, &e.NonceOverview, &e.Details, &e.NonceDetails)
+	if err != nil {
+		return nil, fmt.Errorf("query row scan failed: %w", err)
+	}
+	return e, nil
+}
+
+// DeleteByEntryID removes the conflict recorded for entry id, if any.
+func (r *SQLiteRepository) DeleteByEntryID(ctx context.Context, id string) error {
+	if _, err := r.db.ExecContext(ctx, `delete from conflicts where entry_id=?`, id); err != nil {
+		return fmt.Errorf("failed to delete conflict: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package conflicts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/client/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "modernc.org/sqlite"
+)
+
+func setupDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err = db.Exec(`
+CREATE TABLE conflicts (
+  entry_id TEXT PRIMARY KEY,
+  version BIGINT NOT NULL,
+  deleted INTEGER NOT NULL DEFAULT 0,
+  overview BLOB NOT NULL,
+  nonce_overview BLOB NOT NULL,
+  details BLOB NOT NULL,
+  nonce_details BLOB NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`)
+	require.NoError(t, err)
+	return db
+}
+
+func remote(id string, v int64, details string) *models.Entry {
+	return &models.Entry{
+		Id: id, Version: v,
+		Overview: []byte("o"), NonceOverview: []byte("no"),
+		Details: []byte(details), NonceDetails: []byte("nd"),
+	}
+}
+
+func TestSave_InsertRefreshAndKeepNewest(t *testing.T) {
+	db := setupDB(t)
+	r := NewSQLiteRepository(db)
+	ctx := context.Background()
+
+	require.NoError(t, r.Save(ctx, remote("e1", 3, "v3")))
+	require.NoError(t, r.Save(ctx, remote("e1", 5, "v5")))
+	require.NoError(t, r.Save(ctx, remote("e1", 4, "v4"))) // older copy is ignored
+
+	got, err := r.GetByEntryID(ctx, "e1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), got.Version)
+	assert.Equal(t, []byte("v5"), got.Details)
+}
+
+func TestGetAll_AndDelete(t *testing.T) {
+	db := setupDB(t)
+	r := NewSQLiteRepository(db)
+	ctx := context.Background()
+
+	require.NoError(t, r.Save(ctx, remote("a", 1, "x")))
+	require.NoError(t, r.Save(ctx, remote("b", 2, "y")))
+
+	all, err := r.GetAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, "a", all[0].Id)
+	assert.Equal(t, "b", all[1].Id)
+
+	require.NoError(t, r.DeleteByEntryID(ctx, "a"))
+	require.NoError(t, r.DeleteByEntryID(ctx, "a")) // missing is fine
+
+	_, err = r.GetByEntryID(ctx, "a")
+	require.True(t, errors.Is(err, sql.ErrNoRows))
+
+	all, err = r.GetAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+}
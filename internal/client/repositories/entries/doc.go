@@ -9,7 +9,14 @@
 // # Data Model
 //
 // Each entry stores encrypted fields (overview/details + nonces), a soft-delete
-// flag (deleted), and may be marked pending for synchronization. Implementations
+// flag (deleted), the server version it is based on, and may be marked pending
+// for synchronization. Local writes (CreateOrUpdate, DeleteByID) set pending and
+// bump a local revision; MarkSynced clears pending only if that revision has not
+// moved since the push, and ApplyRemote stores server copies as non-pending.
+// Rebase stores a resolved conflict as a pending write on a new base version.
+// Tombstones form the trash: GetAllDeleted lists them, Undelete brings one back
+// as a pending write and Purge removes a synchronized entry for good.
+// Implementations
 // typically return only overview fields for listings and full details for
 // single-item reads.
 //
@@ -32,6 +39,7 @@
 //	one, _ := repo.GetByID(ctx, id)
 //	_ = repo.DeleteByID(ctx, id)
 //	pend, _ := repo.GetAllPending(ctx)
+//	_ = repo.MarkSynced(ctx, id, serverVersion, pend[0].LocalRevision)
 //
 // See also: internal/client/models for the Entry structure and encryption fields.
 package entries
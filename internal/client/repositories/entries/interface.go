@@ -10,8 +10,26 @@ import (
 // Implementations are typically backed by a local SQLite database.
 type Repository interface {
 	// CreateOrUpdate inserts a new entry or updates an existing one by Id.
+	// It records a local change: the entry becomes pending for sync.
 	CreateOrUpdate(ctx context.Context, entry *models.Entry) error
 
+	// ApplyRemote stores an entry received from the server without marking it
+	// pending. Entries with unsynchronized local changes are not overwritten;
+	// conflict is true when the remote copy is newer than their base version.
+	ApplyRemote(ctx context.Context, entry *models.Entry) (conflict bool, err error)
+
+	// MarkSynced records the server version of an entry accepted by the server
+	// and clears its pending flag unless it changed after localRevision.
+	MarkSynced(ctx context.Context, id string, version int64, localRevision int64) error
+
+	// Rebase stores the resolution of a sync conflict as a pending local change
+	// based on the given server version (entry.Version).
+	Rebase(ctx context.Context, entry *models.Entry) error
+
+	// Reencrypt replaces the ciphertexts of an entry, leaving its version,
+	// pending flag and local revision alone.
+	Reencrypt(ctx context.Context, entry *models.Entry) error
+
 	// GetAll returns all entries, including deleted ones if the implementation
 	// uses tombstones for synchronization.
 	GetAll(ctx context.Context) ([]models.Entry, error)
@@ -19,9 +37,27 @@ type Repository interface {
 	// DeleteByID marks an entry as deleted or removes it (implementation-defined).
 	DeleteByID(ctx context.Context, id string) error
 
+	// GetAllDeleted returns the overviews of all tombstones (the trash).
+	GetAllDeleted(ctx context.Context) ([]models.Entry, error)
+
+	// Undelete brings a tombstone back as a pending local change.
+	Undelete(ctx context.Context, id string) error
+
+	// Purge permanently removes a synchronized entry from the local store.
+	// Entries with unsynchronized changes are never purged.
+	Purge(ctx context.Context, id string) error
+
+	// GetSyncedIDs returns the ids of all entries without local changes,
+	// tombstones included.
+	GetSyncedIDs(ctx context.Context) ([]string, error)
+
 	// GetByID returns an entry by its identifier.
 	GetByID(ctx context.Context, id string) (*models.Entry, error)
 
+	// GetByIDIncludingDeleted returns an entry by its identifier even if it is
+	// a tombstone, together with its sync state.
+	GetByIDIncludingDeleted(ctx context.Context, id string) (*models.Entry, error)
+
 	// GetAllPending returns entries that have local changes not yet synchronized
 	// with the server (e.g., new/updated/deleted since last sync).
 	GetAllPending(ctx context.Context) ([]*models.Entry, error)
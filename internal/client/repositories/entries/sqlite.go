@@ -20,24 +20,120 @@ func NewSQLiteRepository(db dbx.DBTX) *SQLiteRepository {
 	return &SQLiteRepository{db: db}
 }
 
-// CreateOrUpdate upserts an entry by id. On conflict, selected columns are updated.
+// CreateOrUpdate upserts a locally written entry by id. The row is flagged
+// pending and its local revision is bumped so that an in-flight Sync does not
+// clear the pending flag of an entry edited after it was pushed. The stored
+// version is left untouched on update: it is the server version the local
+// copy is based on.
 func (r *SQLiteRepository) CreateOrUpdate(ctx context.Context, e *models.Entry) error {
-	query := ` INSERT INTO entries (id, overview, nonce_overview, details, nonce_details, deleted)
-			values (?, ?, ?, ?, ?, ?)
+	query := ` INSERT INTO entries (id, version, overview, nonce_overview, details, nonce_details, deleted, pending, local_revision)
+			values (?, ?, ?, ?, ?, ?, ?, 1, 1)
 			ON CONFLICT(id) DO UPDATE SET overview = excluded.overview, 
 				nonce_overview = excluded.nonce_overview, 
 				details = excluded.details, 
 				nonce_details = excluded.nonce_details,
-				deleted = excluded.deleted
+				deleted = excluded.deleted,
+				pending = 1,
+				local_revision = entries.local_revision + 1
 	`
 	_, err := r.db.ExecContext(ctx, query,
-		e.Id, e.Overview, e.NonceOverview, e.Details, e.NonceDetails, e.Deleted)
+		e.Id, e.Version, e.Overview, e.NonceOverview, e.Details, e.NonceDetails, e.Deleted)
 	if err != nil {
 		return fmt.Errorf("failed to upsert entry: %w", err)
 	}
 	return nil
 }
 
+// ApplyRemote stores an entry pulled from the server. Remote entries are never
+// marked pending. A local row with unsynchronized changes, or one already at
+// the same or a newer version, is left as is; conflict reports whether the
+// remote copy is newer than the base of such unsynchronized changes.
+func (r *SQLiteRepository) ApplyRemote(ctx context.Context, e *models.Entry) (conflict bool, err error) {
+	var (
+		pending bool
+		version int64
+	)
+	err = r.db.QueryRowContext(ctx, `select pending, version from entries where id=?`, e.Id).Scan(&pending, &version)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return false, fmt.Errorf("failed to select entry: %w", err)
+	case pending:
+		return e.Version > version, nil
+	}
+
+	query := ` INSERT INTO entries (id, version, overview, nonce_overview, details, nonce_details, deleted, pending)
+			values (?, ?, ?, ?, ?, ?, ?, 0)
+			ON CONFLICT(id) DO UPDATE SET version = excluded.version,
+				overview = excluded.overview, 
+				nonce_overview = excluded.nonce_overview, 
+				details = excluded.details, 
+				nonce_details = excluded.nonce_details,
+				deleted = excluded.deleted
+			WHERE entries.pending = 0 AND entries.version < excluded.version
+	`
+	_, err = r.db.ExecContext(ctx, query,
+		e.Id, e.Version, e.Overview, e.NonceOverview, e.Details, e.NonceDetails, e.Deleted)
+	if err != nil {
+		return false, fmt.Errorf("failed to apply remote entry: %w", err)
+	}
+	return false, nil
+}
+
+// MarkSynced stamps the server-assigned version on an entry accepted by the
+// server. The pending flag is cleared only if the entry has not been written
+// locally since localRevision was read; otherwise it stays pending.
+func (r *SQLiteRepository) MarkSynced(ctx context.Context, id string, version int64, localRevision int64) error {
+	query := `update entries set version=?,
+			pending = case when local_revision=? then 0 else pending end
+			where id=?`
+	if _, err := r.db.ExecContext(ctx, query, version, localRevision, id); err != nil {
+		return fmt.Errorf("failed to mark entry synced: %w", err)
+	}
+	return nil
+}
+
+// Rebase overwrites a local entry with the outcome of a conflict resolution.
+// Unlike CreateOrUpdate it moves the base version to e.Version, the server
+// version the resolution was made against, so that the next push is not
+// rejected as stale. The row stays pending and its local revision is bumped.
+func (r *SQLiteRepository) Rebase(ctx context.Context, e *models.Entry) error {
+	query := `update entries set version=?, overview=?, nonce_overview=?, details=?, nonce_details=?,
+			deleted=?, pending=1, local_revision=local_revision+1
+			where id=?`
+	res, err := r.db.ExecContext(ctx, query,
+		e.Version, e.Overview, e.NonceOverview, e.Details, e.NonceDetails, e.Deleted, e.Id)
+	if err != nil {
+		return fmt.Errorf("failed to rebase entry: %w", err)
+	}
+	ra, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if ra != 1 {
+		return fmt.Errorf("wrong rows affected count: %d", ra)
+	}
+	return nil
+}
+
+// Reencrypt stores new ciphertexts of the same content for an existing
+// entry, e.g. after the vault key changed; the sync state stays as is.
+func (r *SQLiteRepository) Reencrypt(ctx context.Context, e *models.Entry) error {
+	query := `update entries set overview=?, nonce_overview=?, details=?, nonce_details=? where id=?`
+	res, err := r.db.ExecContext(ctx, query, e.Overview, e.NonceOverview, e.Details, e.NonceDetails, e.Id)
+	if err != nil {
+		return fmt.Errorf("failed to re-encrypt entry: %w", err)
+	}
+	ra, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if ra != 1 {
+		return fmt.Errorf("wrong rows affected count: %d", ra)
+	}
+	return nil
+}
+
 // GetAll lists all non-deleted entries, returning only overview fields.
 func (r *SQLiteRepository) GetAll(ctx context.Context) ([]models.Entry, error) {
 	query := `select id, overview, nonce_overview from entries where deleted=0`
@@ -63,7 +159,7 @@ func (r *SQLiteRepository) GetAll(ctx context.Context) ([]models.Entry, error) {
 
 // DeleteByID marks an entry as deleted (soft delete). It expects exactly one row to be affected.
 func (r *SQLiteRepository) DeleteByID(ctx context.Context, id string) error {
-	query := `update entries set deleted=1 where id=? and deleted=0`
+	query := `update entries set deleted=1, pending=1, local_revision=local_revision+1 where id=? and deleted=0`
 	res, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete entry: %w", err)
@@ -78,6 +174,88 @@ func (r *SQLiteRepository) DeleteByID(ctx context.Context, id string) error {
 	return nil
 }
 
+// GetAllDeleted lists tombstones, returning only overview fields.
+func (r *SQLiteRepository) GetAllDeleted(ctx context.Context) ([]models.Entry, error) {
+	query := `select id, overview, nonce_overview from entries where deleted=1`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select deleted entries: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.Entry
+	for rows.Next() {
+		item := models.Entry{Deleted: true}
+		if err := rows.Scan(&item.Id, &item.Overview, &item.NonceOverview); err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Undelete clears the deleted flag of a tombstone and records it as a local
+// change (pending, local revision bumped). It expects exactly one row to be
+// affected.
+func (r *SQLiteRepository) Undelete(ctx context.Context, id string) error {
+	query := `update entries set deleted=0, pending=1, local_revision=local_revision+1 where id=? and deleted=1`
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to undelete entry: %w", err)
+	}
+	ra, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if ra != 1 {
+		return fmt.Errorf("wrong rows affected count: %d", ra)
+	}
+	return nil
+}
+
+// Purge hard-deletes a non-pending entry. It expects exactly one row to be
+// affected.
+func (r *SQLiteRepository) Purge(ctx context.Context, id string) error {
+	query := `delete from entries where id=? and pending=0`
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to purge entry: %w", err)
+	}
+	ra, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if ra != 1 {
+		return fmt.Errorf("wrong rows affected count: %d", ra)
+	}
+	return nil
+}
+
+// GetSyncedIDs returns the ids of entries with pending=0, tombstones included.
+func (r *SQLiteRepository) GetSyncedIDs(ctx context.Context) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `select id from entries where pending=0`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select entries: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
 // GetByID returns details for a single non-deleted entry.
 func (r *SQLiteRepository) GetByID(ctx context.Context, id string) (*models.Entry, error) {
 	query := `select details, nonce_details from entries where deleted=0 and id=?`
@@ -90,9 +268,23 @@ func (r *SQLiteRepository) GetByID(ctx context.Context, id string) (*models.Entr
 	return e, nil
 }
 
-// GetAllPending returns entries flagged as pending=1 (awaiting sync).
+// GetByIDIncludingDeleted returns a single entry by id, tombstones included,
+// together with its base version, deleted and pending flags.
+func (r *SQLiteRepository) GetByIDIncludingDeleted(ctx context.Context, id string) (*models.Entry, error) {
+	query := `select id, version, deleted, pending, details, nonce_details from entries where id=?`
+	row := r.db.QueryRowContext(ctx, query, id)
+
+	e := &models.Entry{}
+	if err := row.Scan(&e.Id, &e.Version, &e.Deleted, &e.Pending, &e.Details, &e.NonceDetails); err != nil {
+		return nil, fmt.Errorf("query row scan failed: %w", err)
+	}
+	return e, nil
+}
+
+// GetAllPending returns entries flagged as pending=1 (awaiting sync), including
+// tombstones, together with their base version and local revision.
 func (r *SQLiteRepository) GetAllPending(ctx context.Context) ([]*models.Entry, error) {
-	query := `select id, overview, nonce_overview, details, nonce_details from entries where pending=1`
+	query := `select id, version, deleted, overview, nonce_overview, details, nonce_details, local_revision from entries where pending=1`
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return nil, err
@@ -102,7 +294,7 @@ func (r *SQLiteRepository) GetAllPending(ctx context.Context) ([]*models.Entry,
 	var pending []*models.Entry
 	for rows.Next() {
 		entry := &models.Entry{}
-		if err := rows.Scan(&entry.Id, &entry.Overview, &entry.NonceOverview, &entry.Details, &entry.NonceDetails); err != nil {
+		if err := rows.Scan(&entry.Id, &entry.Version, &entry.Deleted, &entry.Overview, &entry.NonceOverview, &entry.Details, &entry.NonceDetails, &entry.LocalRevision); err != nil {
 			return nil, err
 		}
 		pending = append(pending, entry)
@@ -21,12 +21,14 @@ func setupDB(t *testing.T) *sql.DB {
 	_, err = db.Exec(`
 CREATE TABLE entries (
   id TEXT PRIMARY KEY,
+  version BIGINT NOT NULL DEFAULT 0,
   overview BLOB NOT NULL,
   nonce_overview BLOB NOT NULL,
   details BLOB NOT NULL,
   nonce_details BLOB NOT NULL,
   deleted INTEGER NOT NULL DEFAULT 0,
-  pending INTEGER NOT NULL DEFAULT 0
+  pending INTEGER NOT NULL DEFAULT 0,
+  local_revision INTEGER NOT NULL DEFAULT 0
 );
 `)
 	require.NoError(t, err)
@@ -117,6 +119,10 @@ func TestDeleteByID_SuccessAndNotFound(t *testing.T) {
 
 	require.NoError(t, r.DeleteByID(ctx, "x"))
 
+	var pending int
+	require.NoError(t, db.QueryRow(`SELECT pending FROM entries WHERE id='x'`).Scan(&pending))
+	assert.Equal(t, 1, pending) // the tombstone has to reach the server
+
 	err = r.DeleteByID(ctx, "x")
 	require.Error(t, err)
 }
@@ -153,7 +159,8 @@ func TestGetAllPending_ReturnsOnlyPending(t *testing.T) {
 	_, err := db.Exec(`INSERT INTO entries(id, overview, nonce_overview, details, nonce_details, deleted, pending) VALUES
 	  ('p1', x'01', x'02', x'03', x'04', 0, 1),
 	  ('p2', x'05', x'06', x'07', x'08', 0, 1),
-	  ('n1', x'09', x'0A', x'0B', x'0C', 0, 0)
+	  ('n1', x'09', x'0A', x'0B', x'0C', 0, 0),
+	  ('t1', x'0D', x'0E', x'0F', x'10', 1, 1)
 	`)
 	require.NoError(t, err)
 
@@ -164,10 +171,211 @@ func TestGetAllPending_ReturnsOnlyPending(t *testing.T) {
 	ids := make(map[string]struct{})
 	for _, e := range got {
 		ids[e.Id] = struct{}{}
+		assert.Equal(t, e.Id == "t1", e.Deleted)
 		require.NotNil(t, e.Overview)
 		require.NotNil(t, e.NonceOverview)
 		require.NotNil(t, e.Details)
 		require.NotNil(t, e.NonceDetails)
 	}
-	assert.Equal(t, map[string]struct{}{"p1": {}, "p2": {}}, ids)
+	assert.Equal(t, map[string]struct{}{"p1": {}, "p2": {}, "t1": {}}, ids)
+}
+
+func TestCreateOrUpdate_MarksPendingAndBumpsRevision(t *testing.T) {
+	db := setupDB(t)
+	r := NewSQLiteRepository(db)
+	ctx := context.Background()
+
+	_, err := db.Exec(`INSERT INTO entries(id, version, overview, nonce_overview, details, nonce_details, pending, local_revision)
+	                   VALUES ('e', 4, x'01', x'02', x'03', x'04', 0, 2)`)
+	require.NoError(t, err)
+
+	e := &models.Entry{Id: "e", Overview: []byte("o"), NonceOverview: []byte("n"), Details: []byte("d"), NonceDetails: []byte("nd")}
+	require.NoError(t, r.CreateOrUpdate(ctx, e))
+
+	var version, pending, rev int64
+	require.NoError(t, db.QueryRow(`SELECT version, pending, local_revision FROM entries WHERE id='e'`).Scan(&version, &pending, &rev))
+	assert.Equal(t, int64(4), version) // base version is kept
+	assert.Equal(t, int64(1), pending)
+	assert.Equal(t, int64(3), rev)
+}
+
+func TestMarkSynced_ClearsPendingOnlyForSameRevision(t *testing.T) {
+	db := setupDB(t)
+	r := NewSQLiteRepository(db)
+	ctx := context.Background()
+
+	_, err := db.Exec(`INSERT INTO entries(id, overview, nonce_overview, details, nonce_details, pending, local_revision) VALUES
+	  ('same', x'01', x'02', x'03', x'04', 1, 1),
+	  ('edited', x'01', x'02', x'03', x'04', 1, 2)
+	`)
+	require.NoError(t, err)
+
+	require.NoError(t, r.MarkSynced(ctx, "same", 5, 1))
+	require.NoError(t, r.MarkSynced(ctx, "edited", 6, 1))
+
+	var version, pending int64
+	require.NoError(t, db.QueryRow(`SELECT version, pending FROM entries WHERE id='same'`).Scan(&version, &pending))
+	assert.Equal(t, int64(5), version)
+	assert.Equal(t, int64(0), pending)
+
+	require.NoError(t, db.QueryRow(`SELECT version, pending FROM entries WHERE id='edited'`).Scan(&version, &pending))
+	assert.Equal(t, int64(6), version)
+	assert.Equal(t, int64(1), pending)
+}
+
+func TestApplyRemote_NeverPendingKeepsLocalChangesAndReportsConflicts(t *testing.T) {
+	db := setupDB(t)
+	r := NewSQLiteRepository(db)
+	ctx := context.Background()
+
+	_, err := db.Exec(`INSERT INTO entries(id, version, overview, nonce_overview, details, nonce_details, pending) VALUES
+	  ('local', 1, x'01', x'01', x'01', x'01', 1),
+	  ('clean', 1, x'01', x'01', x'01', x'01', 0)
+	`)
+	require.NoError(t, err)
+
+	remote := func(id string, v int64) *models.Entry {
+		return &models.Entry{Id: id, Version: v, Overview: []byte("r"), NonceOverview: []byte("r"), Details: []byte("r"), NonceDetails: []byte("r")}
+	}
+	conflict, err := r.ApplyRemote(ctx, remote("new", 3))
+	require.NoError(t, err)
+	assert.False(t, conflict)
+	conflict, err = r.ApplyRemote(ctx, remote("local", 3))
+	require.NoError(t, err)
+	assert.True(t, conflict) // newer than the base of the local change
+	conflict, err = r.ApplyRemote(ctx, remote("local", 1))
+	require.NoError(t, err)
+	assert.False(t, conflict) // the local change is based on this version
+	conflict, err = r.ApplyRemote(ctx, remote("clean", 3))
+	require.NoError(t, err)
+	assert.False(t, conflict)
+
+	var version, pending int64
+	var ov []byte
+	require.NoError(t, db.QueryRow(`SELECT version, pending FROM entries WHERE id='new'`).Scan(&version, &pending))
+	assert.Equal(t, int64(3), version)
+	assert.Equal(t, int64(0), pending)
+
+	require.NoError(t, db.QueryRow(`SELECT version, pending, overview FROM entries WHERE id='local'`).Scan(&version, &pending, &ov))
+	assert.Equal(t, int64(1), version)
+	assert.Equal(t, int64(1), pending)
+	assert.Equal(t, []byte{0x01}, ov)
+
+	require.NoError(t, db.QueryRow(`SELECT version, overview FROM entries WHERE id='clean'`).Scan(&version, &ov))
+	assert.Equal(t, int64(3), version)
+	assert.Equal(t, []byte("r"), ov)
+
+	// an older copy never overwrites a newer one
+	_, err = r.ApplyRemote(ctx, remote("clean", 2))
+	require.NoError(t, err)
+	require.NoError(t, db.QueryRow(`SELECT version FROM entries WHERE id='clean'`).Scan(&version))
+	assert.Equal(t, int64(3), version)
+}
+
+func TestRebase_MovesBaseVersionAndKeepsPending(t *testing.T) {
+	db := setupDB(t)
+	r := NewSQLiteRepository(db)
+	ctx := context.Background()
+
+	_, err := db.Exec(`INSERT INTO entries(id, version, overview, nonce_overview, details, nonce_details, deleted, pending, local_revision)
+	                   VALUES ('e', 2, x'01', x'02', x'03', x'04', 1, 1, 3)`)
+	require.NoError(t, err)
+
+	e := &models.Entry{Id: "e", Version: 7, Overview: []byte("o"), NonceOverview: []byte("n"), Details: []byte("d"), NonceDetails: []byte("nd")}
+	require.NoError(t, r.Rebase(ctx, e))
+
+	var version, deleted, pending, rev int64
+	var d []byte
+	require.NoError(t, db.QueryRow(`SELECT version, deleted, pending, local_revision, details FROM entries WHERE id='e'`).
+		Scan(&version, &deleted, &pending, &rev, &d))
+	assert.Equal(t, int64(7), version)
+	assert.Equal(t, int64(0), deleted)
+	assert.Equal(t, int64(1), pending)
+	assert.Equal(t, int64(4), rev)
+	assert.Equal(t, []byte("d"), d)
+
+	assert.Error(t, r.Rebase(ctx, &models.Entry{Id: "missing"}))
+}
+
+func TestReencrypt_KeepsSyncState(t *testing.T) {
+	db := setupDB(t)
+	r := NewSQLiteRepository(db)
+	ctx := context.Background()
+
+	_, err := db.Exec(`INSERT INTO entries(id, version, overview, nonce_overview, details, nonce_details, deleted, pending, local_revision)
+	                   VALUES ('e', 2, x'01', x'02', x'03', x'04', 1, 1, 3)`)
+	require.NoError(t, err)
+
+	e := &models.Entry{Id: "e", Version: 7, Overview: []byte("o"), NonceOverview: []byte("n"), Details: []byte("d"), NonceDetails: []byte("nd")}
+	require.NoError(t, r.Reencrypt(ctx, e))
+
+	var version, deleted, pending, rev int64
+	var o, d []byte
+	require.NoError(t, db.QueryRow(`SELECT version, deleted, pending, local_revision, overview, details FROM entries WHERE id='e'`).
+		Scan(&version, &deleted, &pending, &rev, &o, &d))
+	assert.Equal(t, int64(2), version)
+	assert.Equal(t, int64(1), deleted)
+	assert.Equal(t, int64(1), pending)
+	assert.Equal(t, int64(3), rev)
+	assert.Equal(t, []byte("o"), o)
+	assert.Equal(t, []byte("d"), d)
+
+	assert.Error(t, r.Reencrypt(ctx, &models.Entry{Id: "missing"}))
+}
+
+func TestGetByIDIncludingDeleted_ReturnsTombstones(t *testing.T) {
+	db := setupDB(t)
+	r := NewSQLiteRepository(db)
+	ctx := context.Background()
+
+	_, err := db.Exec(`INSERT INTO entries(id, version, overview, nonce_overview, details, nonce_details, deleted)
+	                   VALUES ('gone', 5, x'01', x'02', x'03', x'04', 1)`)
+	require.NoError(t, err)
+
+	e, err := r.GetByIDIncludingDeleted(ctx, "gone")
+	require.NoError(t, err)
+	assert.Equal(t, "gone", e.Id)
+	assert.Equal(t, int64(5), e.Version)
+	assert.True(t, e.Deleted)
+	assert.Equal(t, []byte{0x03}, e.Details)
+
+	_, err = r.GetByIDIncludingDeleted(ctx, "missing")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestTrash_ListUndeleteAndPurge(t *testing.T) {
+	db := setupDB(t)
+	r := NewSQLiteRepository(db)
+	ctx := context.Background()
+
+	_, err := db.Exec(`INSERT INTO entries(id, version, overview, nonce_overview, details, nonce_details, deleted, pending)
+	                   VALUES ('live', 1, x'01', x'02', x'03', x'04', 0, 0),
+	                          ('gone', 2, x'11', x'12', x'13', x'14', 1, 0),
+	                          ('fresh', 3, x'21', x'22', x'23', x'24', 1, 1)`)
+	require.NoError(t, err)
+
+	trash, err := r.GetAllDeleted(ctx)
+	require.NoError(t, err)
+	require.Len(t, trash, 2)
+	assert.ElementsMatch(t, []string{"gone", "fresh"}, []string{trash[0].Id, trash[1].Id})
+
+	synced, err := r.GetSyncedIDs(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"live", "gone"}, synced)
+
+	// an unpushed tombstone cannot be purged
+	assert.Error(t, r.Purge(ctx, "fresh"))
+	require.NoError(t, r.Purge(ctx, "gone"))
+	_, err = r.GetByIDIncludingDeleted(ctx, "gone")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	require.NoError(t, r.Undelete(ctx, "fresh"))
+	e, err := r.GetByIDIncludingDeleted(ctx, "fresh")
+	require.NoError(t, err)
+	assert.False(t, e.Deleted)
+	assert.True(t, e.Pending)
+	assert.Equal(t, int64(3), e.Version)
+
+	// only tombstones can be undeleted
+	assert.Error(t, r.Undelete(ctx, "live"))
 }
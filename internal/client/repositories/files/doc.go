@@ -19,7 +19,10 @@
 //	_ = repo.CreateOrUpdate(ctx, file)
 //	f, _ := repo.GetByEntryID(ctx, entryID)
 //	pend, _ := repo.GetAllPendingUpload(ctx)
+//	_ = repo.SetRegistered(ctx, entryID, true)
+//	ids, _ := repo.GetAllAwaitingUpload(ctx)
 //	_ = repo.MarkUploaded(ctx, entryID)
+//	_ = repo.UpdateKey(ctx, entryID, wrappedKey)
 //
 // See also: internal/client/models.File for field semantics.
 package files
@@ -15,14 +15,44 @@ type Repository interface {
 	// DeleteByEntryID removes (or marks deleted) the file record linked to the entry.
 	DeleteByEntryID(ctx context.Context, id string) error
 
+	// Undelete clears the deleted flag of the file linked to the entry, if any.
+	Undelete(ctx context.Context, id string) error
+
+	// Purge permanently removes the file record linked to the entry, if any.
+	Purge(ctx context.Context, id string) error
+
 	// GetByEntryID returns the file record for a given entry ID.
 	GetByEntryID(ctx context.Context, id string) (*models.File, error)
 
+	// GetAll returns every file record, including deleted ones.
+	GetAll(ctx context.Context) ([]*models.File, error)
+
+	// UpdateKey replaces the stored key of the file linked to the entry.
+	UpdateKey(ctx context.Context, id string, key []byte) error
+
 	// GetAllPendingUpload returns files that are staged locally and still need
-	// to be uploaded to remote storage (e.g., UploadStatus="pending").
+	// to be uploaded to remote storage (e.g., UploadStatus="pending") but have
+	// not been registered with the server yet.
 	GetAllPendingUpload(ctx context.Context) ([]*models.File, error)
 
+	// GetAllAwaitingUpload returns the entry ids of registered files whose
+	// staged ciphertext still needs to be uploaded.
+	GetAllAwaitingUpload(ctx context.Context) ([]string, error)
+
+	// SetRegistered records whether the server has registered the file of
+	// the entry.
+	SetRegistered(ctx context.Context, id string, registered bool) error
+
 	// MarkUploaded marks the file for the given entry as uploaded (e.g., set
 	// UploadStatus="completed" and clear any temporary local path if desired).
 	MarkUploaded(ctx context.Context, id string) error
+
+	// SetUploadID records the multipart upload in progress for the file of
+	// the entry and forgets any parts recorded for another upload. An empty
+	// uploadID clears the upload state.
+	SetUploadID(ctx context.Context, id string, uploadID string) error
+
+	// AddUploadedPart records a finished part of the multipart upload of the
+	// file of the entry.
+	AddUploadedPart(ctx context.Context, id string, part models.UploadedPart) error
 }
@@ -5,6 +5,7 @@ package files
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/client/models"
@@ -22,18 +23,23 @@ func NewSQLiteRepository(db dbx.DBTX) *SQLiteRepository {
 }
 
 // CreateOrUpdate upserts a file record by entry_id.
-// On conflict, all tracked columns are updated.
+// On conflict, all tracked columns are updated; the multipart upload state is
+// kept only while the file content (identified by its nonce) stays the same.
 func (r *SQLiteRepository) CreateOrUpdate(ctx context.Context, e *models.File) error {
-	query := ` INSERT INTO files (entry_id, encrypted_file_key, nonce, local_path, upload_status, deleted)
-			values (?, ?, ?, ?, ?, ?)
+	query := ` INSERT INTO files (entry_id, encrypted_file_key, nonce, digest, local_path, upload_status, deleted, registered)
+			values (?, ?, ?, ?, ?, ?, ?, ?)
 			ON CONFLICT(entry_id) DO UPDATE SET entry_id = excluded.entry_id, 
 				encrypted_file_key = excluded.encrypted_file_key, 
 				nonce = excluded.nonce, 
+				digest = excluded.digest,
 				local_path = excluded.local_path,
 				upload_status = excluded.upload_status,
-				deleted = excluded.deleted
+				deleted = excluded.deleted,
+				registered = excluded.registered,
+				upload_id = CASE WHEN files.nonce = excluded.nonce THEN files.upload_id END,
+				upload_parts = CASE WHEN files.nonce = excluded.nonce THEN files.upload_parts END
 	`
-	if _, err := r.db.ExecContext(ctx, query, e.EntryID, e.EncryptedFileKey, e.Nonce, e.LocalPath, e.UploadStatus, e.Deleted); err != nil {
+	if _, err := r.db.ExecContext(ctx, query, e.EntryID, e.EncryptedFileKey, e.Nonce, e.Digest, e.LocalPath, e.UploadStatus, e.Deleted, e.Registered); err != nil {
 		return fmt.Errorf("failed to upsert file: %w", err)
 	}
 	return nil
@@ -57,21 +63,48 @@ func (r *SQLiteRepository) DeleteByEntryID(ctx context.Context, id string) error
 	return nil
 }
 
-// GetByEntryID returns a file record for the given entry id.
+// Undelete clears the soft-delete flag of the file record linked to id.
+// Entries without a deleted file are left alone.
+func (r *SQLiteRepository) Undelete(ctx context.Context, id string) error {
+	query := `update files set deleted=0 where entry_id=? and deleted=1`
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to undelete file: %w", err)
+	}
+	return nil
+}
+
+// Purge deletes the file record linked to id. Entries without a file are
+// not an error.
+func (r *SQLiteRepository) Purge(ctx context.Context, id string) error {
+	if _, err := r.db.ExecContext(ctx, `delete from files where entry_id=?`, id); err != nil {
+		return fmt.Errorf("failed to purge file: %w", err)
+	}
+	return nil
+}
+
+// GetByEntryID returns a file record for the given entry id, including the
+// state of its upload.
 func (r *SQLiteRepository) GetByEntryID(ctx context.Context, id string) (*models.File, error) {
-	query := `select entry_id, encrypted_file_key, nonce, local_path, upload_status, deleted from files where entry_id=?`
+	query := `select entry_id, encrypted_file_key, nonce, digest, local_path, upload_status, deleted, registered,
+			coalesce(upload_id, ''), coalesce(upload_parts, '[]') from files where entry_id=?`
 	row := r.db.QueryRowContext(ctx, query, id)
 
 	e := &models.File{}
-	if err := row.Scan(&e.EntryID, &e.EncryptedFileKey, &e.Nonce, &e.LocalPath, &e.UploadStatus, &e.Deleted); err != nil {
+	var parts string
+	if err := row.Scan(&e.EntryID, &e.EncryptedFileKey, &e.Nonce, &e.Digest, &e.LocalPath, &e.UploadStatus, &e.Deleted, &e.Registered,
+		&e.UploadID, &parts); err != nil {
 		return nil, fmt.Errorf("query row scan failed: %w", err)
 	}
+	if err := json.Unmarshal([]byte(parts), &e.UploadedParts); err != nil {
+		return nil, fmt.Errorf("failed to decode uploaded parts: %w", err)
+	}
 	return e, nil
 }
 
-// GetAllPendingUpload returns files whose upload_status indicates a pending upload.
-func (r *SQLiteRepository) GetAllPendingUpload(ctx context.Context) ([]*models.File, error) {
-	query := `select entry_id, encrypted_file_key, nonce from files where upload_status='pending'`
+// GetAll returns every file record (without local paths), including deleted
+// ones.
+func (r *SQLiteRepository) GetAll(ctx context.Context) ([]*models.File, error) {
+	query := `select entry_id, encrypted_file_key, nonce, upload_status, deleted from files`
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("error selecting files: %w", err)
@@ -81,7 +114,7 @@ func (r *SQLiteRepository) GetAllPendingUpload(ctx context.Context) ([]*models.F
 	var result []*models.File
 	for rows.Next() {
 		item := &models.File{}
-		if err := rows.Scan(&item.EntryID, &item.EncryptedFileKey, &item.Nonce); err != nil {
+		if err := rows.Scan(&item.EntryID, &item.EncryptedFileKey, &item.Nonce, &item.UploadStatus, &item.Deleted); err != nil {
 			return nil, err
 		}
 		result = append(result, item)
@@ -92,10 +125,95 @@ func (r *SQLiteRepository) GetAllPendingUpload(ctx context.Context) ([]*models.F
 	return result, nil
 }
 
-// MarkUploaded sets upload_status='completed' for the file of the given entry id.
+// UpdateKey sets encrypted_file_key for the file of the given entry id.
 // Exactly one row must be affected.
+func (r *SQLiteRepository) UpdateKey(ctx context.Context, id string, key []byte) error {
+	query := `update files set encrypted_file_key=? where entry_id=?`
+	res, err := r.db.ExecContext(ctx, query, key, id)
+	if err != nil {
+		return fmt.Errorf("failed to update file key: %w", err)
+	}
+	ra, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if ra != 1 {
+		return fmt.Errorf("unexpected rows affected: %d", ra)
+	}
+	return nil
+}
+
+// GetAllPendingUpload returns non-deleted files whose upload_status indicates a
+// pending upload and that the server does not know about yet.
+func (r *SQLiteRepository) GetAllPendingUpload(ctx context.Context) ([]*models.File, error) {
+	query := `select entry_id, encrypted_file_key, nonce, digest from files where upload_status='pending' and deleted=0 and registered=0`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting files: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*models.File
+	for rows.Next() {
+		item := &models.File{}
+		if err := rows.Scan(&item.EntryID, &item.EncryptedFileKey, &item.Nonce, &item.Digest); err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetAllAwaitingUpload returns the entry ids of non-deleted files that the
+// server has registered but that still wait for their staged ciphertext to be
+// uploaded.
+func (r *SQLiteRepository) GetAllAwaitingUpload(ctx context.Context) ([]string, error) {
+	query := `select entry_id from files where upload_status='pending' and deleted=0 and registered=1 and local_path<>''`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting files: %w", err)
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		result = append(result, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetRegistered records whether the server has registered the file of the
+// given entry id. Exactly one row must be affected.
+func (r *SQLiteRepository) SetRegistered(ctx context.Context, id string, registered bool) error {
+	query := `update files set registered=? where entry_id=?`
+	res, err := r.db.ExecContext(ctx, query, registered, id)
+	if err != nil {
+		return fmt.Errorf("failed to set registered: %w", err)
+	}
+	ra, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if ra != 1 {
+		return fmt.Errorf("unexpected rows affected: %d", ra)
+	}
+	return nil
+}
+
+// MarkUploaded sets upload_status='completed' for the file of the given entry id
+// and clears its multipart upload state. Exactly one row must be affected.
 func (r *SQLiteRepository) MarkUploaded(ctx context.Context, id string) error {
-	query := `update files set upload_status='completed' where entry_id=?`
+	query := `update files set upload_status='completed', upload_id=NULL, upload_parts=NULL where entry_id=?`
 	res, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("failed to mark uploaded: %w", err)
@@ -109,3 +227,45 @@ func (r *SQLiteRepository) MarkUploaded(ctx context.Context, id string) error {
 	}
 	return nil
 }
+
+// SetUploadID sets upload_id for the file of the given entry id and resets
+// its recorded parts; an empty uploadID clears both. Exactly one row must be
+// affected.
+func (r *SQLiteRepository) SetUploadID(ctx context.Context, id string, uploadID string) error {
+	query := `update files set upload_id=NULLIF(?, ''), upload_parts=NULL where entry_id=?`
+	res, err := r.db.ExecContext(ctx, query, uploadID, id)
+	if err != nil {
+		return fmt.Errorf("failed to set upload id: %w", err)
+	}
+	ra, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if ra != 1 {
+		return fmt.Errorf("unexpected rows affected: %d", ra)
+	}
+	return nil
+}
+
+// AddUploadedPart appends part to the upload_parts list of the file of the
+// given entry id. The list is updated in place, so that parts finishing
+// concurrently are all kept. Exactly one row must be affected.
+func (r *SQLiteRepository) AddUploadedPart(ctx context.Context, id string, part models.UploadedPart) error {
+	p, err := json.Marshal(part)
+	if err != nil {
+		return err
+	}
+	query := `update files set upload_parts=json_insert(coalesce(upload_parts, '[]'), '$[#]', json(?)) where entry_id=?`
+	res, err := r.db.ExecContext(ctx, query, string(p), id)
+	if err != nil {
+		return fmt.Errorf("failed to record uploaded part: %w", err)
+	}
+	ra, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if ra != 1 {
+		return fmt.Errorf("unexpected rows affected: %d", ra)
+	}
+	return nil
+}
@@ -23,9 +23,13 @@ CREATE TABLE files (
   entry_id TEXT PRIMARY KEY,
   encrypted_file_key BLOB NOT NULL,
   nonce BLOB NOT NULL,
+  digest BLOB,
   local_path TEXT NOT NULL,
   upload_status TEXT NOT NULL,
-  deleted INTEGER NOT NULL DEFAULT 0
+  deleted INTEGER NOT NULL DEFAULT 0,
+  registered INTEGER NOT NULL DEFAULT 0,
+  upload_id TEXT,
+  upload_parts TEXT
 );
 `)
 	require.NoError(t, err)
@@ -118,7 +122,7 @@ func TestGetByEntryID_SuccessAndNotFound(t *testing.T) {
 	require.Error(t, err)
 }
 
-func TestGetAllPendingUpload_OnlyPending(t *testing.T) {
+func TestGetAllPendingUpload_OnlyPendingNotDeleted(t *testing.T) {
 	db := setupDB(t)
 	ctx := context.Background()
 
@@ -140,7 +144,49 @@ func TestGetAllPendingUpload_OnlyPending(t *testing.T) {
 		require.NotNil(t, f.EncryptedFileKey)
 		require.NotNil(t, f.Nonce)
 	}
-	assert.Equal(t, map[string]struct{}{"p1": {}, "p2": {}, "d1": {}}, ids)
+	assert.Equal(t, map[string]struct{}{"p1": {}, "p2": {}}, ids)
+}
+
+func TestRegisteredFiles(t *testing.T) {
+	db := setupDB(t)
+	ctx := context.Background()
+
+	_, err := db.Exec(`INSERT INTO files(entry_id, encrypted_file_key, nonce, local_path, upload_status, deleted, registered) VALUES
+	  ('new', x'01', x'11', '/tmp/new', 'pending', 0, 0),
+	  ('reg', x'02', x'12', '/tmp/reg', 'pending', 0, 1),
+	  ('remote', x'03', x'13', '', 'pending', 0, 1),
+	  ('done', x'04', x'14', '/tmp/done', 'completed', 0, 1),
+	  ('gone', x'05', x'15', '/tmp/gone', 'pending', 1, 1)
+	`)
+	require.NoError(t, err)
+
+	r := NewSQLiteRepository(db)
+	pending, err := r.GetAllPendingUpload(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "new", pending[0].EntryID)
+
+	// files staged on another device cannot be uploaded from here
+	awaiting, err := r.GetAllAwaitingUpload(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reg"}, awaiting)
+
+	require.NoError(t, r.SetRegistered(ctx, "new", true))
+	require.NoError(t, r.SetRegistered(ctx, "reg", false))
+	awaiting, err = r.GetAllAwaitingUpload(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"new"}, awaiting)
+	got, err := r.GetByEntryID(ctx, "new")
+	require.NoError(t, err)
+	assert.True(t, got.Registered)
+
+	// staging new content makes the file pending a push again
+	require.NoError(t, r.CreateOrUpdate(ctx, &models.File{EntryID: "new", EncryptedFileKey: []byte("k"), Nonce: []byte("n"), LocalPath: "/tmp/new2", UploadStatus: "pending"}))
+	pending, err = r.GetAllPendingUpload(ctx)
+	require.NoError(t, err)
+	assert.Len(t, pending, 2)
+
+	require.Error(t, r.SetRegistered(ctx, "absent", true))
 }
 
 func TestMarkUploaded_SuccessAndNotFound(t *testing.T) {
@@ -163,3 +209,101 @@ func TestMarkUploaded_SuccessAndNotFound(t *testing.T) {
 	err = r.MarkUploaded(ctx, "absent")
 	require.Error(t, err)
 }
+
+func TestMultipartUploadState(t *testing.T) {
+	db := setupDB(t)
+	r := NewSQLiteRepository(db)
+	ctx := context.Background()
+
+	f := &models.File{EntryID: "e1", EncryptedFileKey: []byte("k"), Nonce: []byte("n1"), LocalPath: "/tmp/e1", UploadStatus: "pending"}
+	require.NoError(t, r.CreateOrUpdate(ctx, f))
+
+	require.NoError(t, r.SetUploadID(ctx, "e1", "up1"))
+	require.NoError(t, r.AddUploadedPart(ctx, "e1", models.UploadedPart{PartNumber: 2, ETag: `"b"`}))
+	require.NoError(t, r.AddUploadedPart(ctx, "e1", models.UploadedPart{PartNumber: 1, ETag: `"a"`}))
+
+	got, err := r.GetByEntryID(ctx, "e1")
+	require.NoError(t, err)
+	assert.Equal(t, "up1", got.UploadID)
+	assert.Equal(t, []models.UploadedPart{{PartNumber: 2, ETag: `"b"`}, {PartNumber: 1, ETag: `"a"`}}, got.UploadedParts)
+
+	// re-staging the same content keeps the upload, new content drops it
+	require.NoError(t, r.CreateOrUpdate(ctx, f))
+	got, err = r.GetByEntryID(ctx, "e1")
+	require.NoError(t, err)
+	assert.Equal(t, "up1", got.UploadID)
+	assert.Len(t, got.UploadedParts, 2)
+
+	f.Nonce = []byte("n2")
+	require.NoError(t, r.CreateOrUpdate(ctx, f))
+	got, err = r.GetByEntryID(ctx, "e1")
+	require.NoError(t, err)
+	assert.Empty(t, got.UploadID)
+	assert.Empty(t, got.UploadedParts)
+
+	// a new upload forgets the parts of the previous one
+	require.NoError(t, r.SetUploadID(ctx, "e1", "up2"))
+	require.NoError(t, r.AddUploadedPart(ctx, "e1", models.UploadedPart{PartNumber: 1, ETag: `"x"`}))
+	require.NoError(t, r.SetUploadID(ctx, "e1", "up3"))
+	got, err = r.GetByEntryID(ctx, "e1")
+	require.NoError(t, err)
+	assert.Equal(t, "up3", got.UploadID)
+	assert.Empty(t, got.UploadedParts)
+
+	require.NoError(t, r.AddUploadedPart(ctx, "e1", models.UploadedPart{PartNumber: 1, ETag: `"y"`}))
+	require.NoError(t, r.MarkUploaded(ctx, "e1"))
+	got, err = r.GetByEntryID(ctx, "e1")
+	require.NoError(t, err)
+	assert.Empty(t, got.UploadID)
+	assert.Empty(t, got.UploadedParts)
+
+	require.Error(t, r.SetUploadID(ctx, "absent", "up"))
+	require.Error(t, r.AddUploadedPart(ctx, "absent", models.UploadedPart{PartNumber: 1}))
+}
+
+func TestUndeleteAndPurge(t *testing.T) {
+	db := setupDB(t)
+	ctx := context.Background()
+
+	_, err := db.Exec(`INSERT INTO files(entry_id, encrypted_file_key, nonce, local_path, upload_status, deleted)
+	                   VALUES ('x', x'01', x'02', '', 'completed', 1)`)
+	require.NoError(t, err)
+
+	r := NewSQLiteRepository(db)
+
+	require.NoError(t, r.Undelete(ctx, "x"))
+	f, err := r.GetByEntryID(ctx, "x")
+	require.NoError(t, err)
+	assert.False(t, f.Deleted)
+
+	// entries without a file are fine
+	require.NoError(t, r.Undelete(ctx, "nofile"))
+	require.NoError(t, r.Purge(ctx, "nofile"))
+
+	require.NoError(t, r.Purge(ctx, "x"))
+	_, err = r.GetByEntryID(ctx, "x")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestGetAllAndUpdateKey(t *testing.T) {
+	db := setupDB(t)
+	ctx := context.Background()
+
+	_, err := db.Exec(`INSERT INTO files(entry_id, encrypted_file_key, nonce, local_path, upload_status, deleted)
+	                   VALUES ('a', x'01', x'02', '', 'completed', 0), ('b', x'03', x'04', '', 'pending', 1)`)
+	require.NoError(t, err)
+
+	r := NewSQLiteRepository(db)
+
+	all, err := r.GetAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+
+	require.NoError(t, r.UpdateKey(ctx, "b", []byte{0x09}))
+	f, err := r.GetByEntryID(ctx, "b")
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x09}, f.EncryptedFileKey)
+	assert.True(t, f.Deleted)
+
+	require.Error(t, r.UpdateKey(ctx, "absent", []byte{0x09}))
+}
@@ -4,13 +4,17 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"crypto/subtle"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/client/client"
+	"github.com/dmitrijs2005/gophkeeper/internal/client/models"
 	"github.com/dmitrijs2005/gophkeeper/internal/client/repositories/metadata"
 	"github.com/dmitrijs2005/gophkeeper/internal/common"
 	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
@@ -20,9 +24,22 @@ import (
 // AuthService defines authentication operations for the CLI.
 //
 // Contract:
-//   - OnlineLogin: authenticate against the server and persist offline auth data.
-//   - OfflineLogin: derive and verify credentials against locally cached data.
+//   - OnlineLogin: authenticate against the server, asking for a second
+//     factor if the account has one, persist offline auth data and return
+//     the vault key entries are encrypted under.
+//   - OfflineLogin: derive and verify credentials against locally cached data
+//     and return the vault key.
 //   - Register: create a new user on the server.
+//   - ChangePassword: replace the password of the account logged in on this
+//     device and return the vault key, which is replaced by a random one if
+//     it was derived from the old password.
+//   - ListSessions: list the devices logged into the account.
+//   - RevokeSession: log one device out.
+//   - RevokeOtherSessions: log every device but this one out.
+//   - Logout: end this device's session on the server.
+//   - Enable2FA: start a TOTP enrollment of the account, given a current
+//     code if it replaces a second factor.
+//   - Confirm2FA: complete the enrollment and get recovery codes.
 //   - Ping: check server liveness.
 //   - Close: release underlying client resources.
 //   - ClearOfflineData: wipe locally cached auth metadata.
@@ -30,13 +47,27 @@ import (
 // All methods must honor context cancellation/timeouts.
 type AuthService interface {
 	OfflineLogin(ctx context.Context, username string, password []byte) ([]byte, error)
-	OnlineLogin(ctx context.Context, username string, password []byte) ([]byte, error)
+	OnlineLogin(ctx context.Context, username string, password []byte, secondFactor SecondFactorPrompt) ([]byte, error)
 	Register(ctx context.Context, username string, password []byte) error
+	ChangePassword(ctx context.Context, oldPassword, newPassword []byte) ([]byte, error)
+	ListSessions(ctx context.Context) ([]*models.Session, error)
+	RevokeSession(ctx context.Context, id string) error
+	RevokeOtherSessions(ctx context.Context) (int64, error)
+	Logout(ctx context.Context) error
+	Enable2FA(ctx context.Context, code string) (secret string, uri string, err error)
+	Confirm2FA(ctx context.Context, code string) ([]string, error)
 	Ping(ctx context.Context) error
 	Close(ctx context.Context) error
 	ClearOfflineData(ctx context.Context) error
 }
 
+// SecondFactorPrompt asks the user for a TOTP or recovery code when the
+// account has two-factor authentication.
+type SecondFactorPrompt func(ctx context.Context) (string, error)
+
+// secondFactorTries is how many codes OnlineLogin asks for before giving up.
+const secondFactorTries = 3
+
 // authService is the concrete AuthService backed by a remote Client
 // and a local SQL database for offline metadata.
 type authService struct {
@@ -53,10 +84,14 @@ func (a *authService) getMetadataRepo() metadata.Repository {
 	return metadata.NewSQLiteRepository(a.db)
 }
 
-// OfflineLogin derives a master key from (password,salt) stored locally
-// and verifies it against the locally cached verifier. Returns the master key
-// on success. If local data is missing, returns client.ErrLocalDataNotAvailable;
-// if verification fails, returns client.ErrUnauthorized.
+// OfflineLogin derives a master key from the password and the salt and KDF
+// parameters stored locally, verifies its auth key against the locally cached verifier and unwraps the
+// cached vault key with its key-encryption key. Returns the vault key on
+// success. Data cached before the key hierarchy holds no vault key; the
+// master key itself is verified and returned then, which is the vault key of
+// such accounts. If local data is missing, returns
+// client.ErrLocalDataNotAvailable; if verification fails, returns
+// client.ErrUnauthorized.
 func (a *authService) OfflineLogin(ctx context.Context, username string, password []byte) ([]byte, error) {
 	metadataRepo := a.getMetadataRepo()
 
@@ -82,70 +117,425 @@ func (a *authService) OfflineLogin(ctx context.Context, username string, passwor
 			return nil, client.ErrLocalDataNotAvailable
 		}
 	}
+	savedVaultKey, err := metadataRepo.Get(ctx, "vault_key")
+	if err != nil {
+		return nil, client.ErrLocalDataNotAvailable
+	}
+	savedKDF, err := metadataRepo.Get(ctx, "kdf")
+	if err != nil {
+		return nil, client.ErrLocalDataNotAvailable
+	}
+	kdf, err := parseKDF(savedKDF)
+	if err != nil {
+		return nil, client.ErrLocalDataNotAvailable
+	}
 
-	masterKeyCandidate := cryptox.DeriveMasterKey(password, savedSalt)
-	verifierCandidate := cryptox.MakeVerifier(masterKeyCandidate)
+	masterKeyCandidate, err := cryptox.DeriveMasterKey(password, savedSalt, kdf)
+	if err != nil {
+		return nil, client.ErrLocalDataNotAvailable
+	}
+	if savedVaultKey == nil {
+		if subtle.ConstantTimeCompare(savedVerifier, cryptox.MakeVerifier(masterKeyCandidate)) == 0 {
+			return nil, client.ErrUnauthorized
+		}
+		return masterKeyCandidate, nil
+	}
 
+	verifierCandidate := cryptox.MakeVerifier(cryptox.DeriveAuthKey(masterKeyCandidate))
 	if subtle.ConstantTimeCompare(savedVerifier, verifierCandidate) == 0 {
 		return nil, client.ErrUnauthorized
 	}
-	return masterKeyCandidate, nil
+	vaultKey, err := cryptox.UnwrapKey(savedVaultKey, cryptox.DeriveKEK(masterKeyCandidate))
+	if err != nil {
+		return nil, client.ErrUnauthorized
+	}
+	return vaultKey, nil
 }
 
-// OnlineLogin authenticates against the server, saves offline metadata
-// (username, salt, verifier), and returns the derived master key.
-func (a *authService) OnlineLogin(ctx context.Context, userName string, password []byte) ([]byte, error) {
-	salt, err := a.client.GetSalt(ctx, userName)
+// OnlineLogin authenticates against the server with SRP over the auth key,
+// unwraps the vault key with the key-encryption key, saves offline metadata
+// (username, salt, KDF parameters, verifier, wrapped vault key), and returns
+// the vault key. Accounts that predate SRP or the key hierarchy are upgraded
+// on the way. Their master key is their vault key, which anyone with the
+// password could derive again after a password change; it is replaced by a
+// random one right away (see rekeyVault), and on later logins while that
+// fails. Accounts whose KDF parameters are below the server's policy are
+// re-keyed with it after logging in (see upgradeKDF), which the vault key
+// replacement does on the way. For accounts with
+// two-factor authentication, secondFactor is asked for a code once the
+// password is accepted (see srpLogin). A server that cannot prove knowledge
+// of the SRP verifier is rejected with client.ErrUnauthorized.
+func (a *authService) OnlineLogin(ctx context.Context, userName string, password []byte, secondFactor SecondFactorPrompt) ([]byte, error) {
+	info, err := a.client.GetSalt(ctx, userName)
 	if err != nil {
 		return nil, fmt.Errorf("get salt error: %w", err)
 	}
+	salt, kdf := info.Salt, info.KDF
 
-	masterKeyCandidate := cryptox.DeriveMasterKey(password, salt)
-	verifierCandidate := cryptox.MakeVerifier(masterKeyCandidate)
+	masterKey, err := cryptox.DeriveMasterKey(password, salt, kdf)
+	if err != nil {
+		return nil, fmt.Errorf("derive master key: %w", err)
+	}
+	authKey := cryptox.DeriveAuthKey(masterKey)
+	kek := cryptox.DeriveKEK(masterKey)
 
-	if err := a.client.Login(ctx, userName, verifierCandidate); err != nil {
-		return nil, fmt.Errorf("login error: %w", err)
+	var vaultKey, wrappedVaultKey []byte
+	if info.LegacyKeys {
+		vaultKey = masterKey
+		if wrappedVaultKey, err = cryptox.WrapKey(vaultKey, kek); err != nil {
+			return nil, err
+		}
+		if err := a.upgradeLogin(ctx, info, userName, masterKey, authKey, wrappedVaultKey, secondFactor); err != nil {
+			return nil, fmt.Errorf("login error: %w", err)
+		}
+	} else {
+		wrapped, err := a.srpLogin(ctx, userName, salt, authKey, secondFactor)
+		if err != nil {
+			return nil, fmt.Errorf("login error: %w", err)
+		}
+		if vaultKey, err = cryptox.UnwrapKey(wrapped, kek); err != nil {
+			return nil, fmt.Errorf("unwrap vault key: %w", err)
+		}
+		wrappedVaultKey = wrapped
 	}
 
-	if err := a.saveOfflineData(ctx, userName, salt, verifierCandidate); err != nil {
+	creds := models.Credentials{Salt: salt, KDF: kdf, WrappedVaultKey: wrappedVaultKey}
+	if bytes.Equal(vaultKey, masterKey) {
+		policy := kdf
+		if info.KDFUpgrade != nil {
+			policy = *info.KDFUpgrade
+		}
+		newKey, err := a.rekeyVault(ctx, userName, password, policy, salt, authKey, vaultKey)
+		if err == nil {
+			return newKey, nil
+		}
+		// Retried on the next login, so the KDF upgrade waits: it would
+		// wrap the vault key under another master key and hide that it is
+		// password-derived.
+		log.Printf("vault key replacement failed: %v", err)
+	} else if info.KDFUpgrade != nil {
+		// A failed upgrade is retried on the next login.
+		upgraded, upgradedAuthKey, err := a.upgradeKDF(ctx, userName, password, vaultKey, salt, authKey, *info.KDFUpgrade)
+		if err != nil {
+			log.Printf("kdf upgrade failed: %v", err)
+		} else {
+			creds, authKey = upgraded, upgradedAuthKey
+		}
+	}
+
+	if err := a.saveOfflineData(ctx, userName, creds, cryptox.MakeVerifier(authKey)); err != nil {
 		return nil, fmt.Errorf("offline data saving error: %w", err)
 	}
-	return masterKeyCandidate, nil
+	return vaultKey, nil
 }
 
-// saveOfflineData persists minimal auth metadata required for offline login:
-// username, salt, and verifier, in a single transaction.
-func (a *authService) saveOfflineData(ctx context.Context, userName string, salt []byte, varifier []byte) error {
-	metadataRepo := a.getMetadataRepo()
+// upgradeKDF re-derives the keys of a logged-in account from password with
+// the KDF parameters kdf and a fresh salt, wraps vaultKey under the new
+// key-encryption key and replaces the account's credentials with the result,
+// proving the password with the current salt and auth key. It returns the new
+// credentials and auth key.
+func (a *authService) upgradeKDF(ctx context.Context, userName string, password, vaultKey, salt, authKey []byte, kdf cryptox.KDFParams) (models.Credentials, []byte, error) {
+	creds, newAuthKey, err := newCredentials(userName, password, vaultKey, kdf)
+	if err != nil {
+		return models.Credentials{}, nil, err
+	}
+	srp, sessionID, proof, err := a.srpProve(ctx, userName, salt, authKey)
+	if err != nil {
+		return models.Credentials{}, nil, err
+	}
+	serverProof, err := a.client.UpgradeKDF(ctx, sessionID, proof, creds)
+	if err != nil {
+		return models.Credentials{}, nil, err
+	}
+	if err := srp.VerifyServer(serverProof); err != nil {
+		return models.Credentials{}, nil, client.ErrUnauthorized
+	}
+	return creds, newAuthKey, nil
+}
 
-	return dbx.WithTx(ctx, a.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
-		if err := metadataRepo.Set(ctx, "username", []byte(userName)); err != nil {
-			return err
-		}
-		if err := metadataRepo.Set(ctx, "salt", salt); err != nil {
-			return err
+// upgradeLogin logs in an account that predates the key hierarchy with its
+// old credentials and replaces them with an SRP verifier over the auth key
+// and the wrapped vault key: SRP accounts log in with the master key and
+// call UpgradeKeys, accounts still on the legacy verifier do both in Login.
+// The server does not tell the latter apart before the password is checked,
+// so Login is tried once the SRP login is rejected, and only for accounts
+// that GetSalt reported with legacy keys: for any other account a rejected
+// SRP login is a wrong password, and a second failing login would count
+// twice towards the lockout.
+func (a *authService) upgradeLogin(ctx context.Context, info *models.SaltInfo, userName string, masterKey, authKey, wrappedVaultKey []byte, secondFactor SecondFactorPrompt) error {
+	srpVerifier := cryptox.SRPVerifier(userName, info.Salt, authKey)
+
+	_, err := a.srpLogin(ctx, userName, info.Salt, masterKey, secondFactor)
+	if errors.Is(err, client.ErrUnauthorized) && info.LegacyKeys {
+		return a.client.Login(ctx, userName, cryptox.MakeVerifier(masterKey), srpVerifier, wrappedVaultKey)
+	}
+	if err != nil {
+		return err
+	}
+	return a.client.UpgradeKeys(ctx, srpVerifier, wrappedVaultKey)
+}
+
+// srpLogin runs the LoginStart/LoginFinish exchange with the given key as
+// SRP password and verifies the server proof. It returns the wrapped vault
+// key sent by the server, or client.ErrUnauthorized if the server rejects
+// the proof, which accounts that still have a legacy verifier always get.
+// If the server asks for a second factor, the login is completed with codes
+// from secondFactor, which may be retyped up to secondFactorTries times.
+func (a *authService) srpLogin(ctx context.Context, userName string, salt, key []byte, secondFactor SecondFactorPrompt) ([]byte, error) {
+	srp, sessionID, proof, err := a.srpProve(ctx, userName, salt, key)
+	if err != nil {
+		return nil, err
+	}
+	serverProof, wrappedVaultKey, challenge, err := a.client.LoginFinish(ctx, sessionID, proof)
+	if err != nil {
+		return nil, err
+	}
+	if err := srp.VerifyServer(serverProof); err != nil {
+		return nil, client.ErrUnauthorized
+	}
+	if challenge == "" {
+		return wrappedVaultKey, nil
+	}
+
+	if secondFactor == nil {
+		return nil, client.ErrUnauthorized
+	}
+	for try := 1; ; try++ {
+		code, err := secondFactor(ctx)
+		if err != nil {
+			return nil, err
 		}
-		if err := metadataRepo.Set(ctx, "verifier", varifier); err != nil {
-			return err
+		wrappedVaultKey, err = a.client.Verify2FA(ctx, challenge, code)
+		if err == nil || !errors.Is(err, client.ErrUnauthorized) || try == secondFactorTries {
+			return wrappedVaultKey, err
 		}
-		return nil
+		log.Printf("Invalid code, try again")
+	}
+}
+
+// srpProve runs LoginStart with the given key as SRP password and computes
+// the client proof for the session, to be sent with LoginFinish,
+// ChangePassword or UpgradeKDF.
+func (a *authService) srpProve(ctx context.Context, userName string, salt, key []byte) (srp *cryptox.SRPClient, sessionID string, proof []byte, err error) {
+	srp, err = cryptox.NewSRPClient(userName, salt, key)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	sessionID, serverPublic, err := a.client.LoginStart(ctx, userName, srp.PublicKey())
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if proof, err = srp.Proof(serverPublic); err != nil {
+		return nil, "", nil, client.ErrUnauthorized
+	}
+	return srp, sessionID, proof, nil
+}
+
+// newCredentials derives the keys of userName from password with the KDF
+// parameters kdf and a fresh salt and wraps vaultKey under the new
+// key-encryption key. It returns the credentials for the server and the new
+// auth key.
+func newCredentials(userName string, password, vaultKey []byte, kdf cryptox.KDFParams) (models.Credentials, []byte, error) {
+	salt := common.GenerateRandByteArray(32)
+	masterKey, err := cryptox.DeriveMasterKey(password, salt, kdf)
+	if err != nil {
+		return models.Credentials{}, nil, err
+	}
+	authKey := cryptox.DeriveAuthKey(masterKey)
+	wrappedVaultKey, err := cryptox.WrapKey(vaultKey, cryptox.DeriveKEK(masterKey))
+	if err != nil {
+		return models.Credentials{}, nil, err
+	}
+	return models.Credentials{
+		Salt:            salt,
+		KDF:             kdf,
+		SRPVerifier:     cryptox.SRPVerifier(userName, salt, authKey),
+		WrappedVaultKey: wrappedVaultKey,
+	}, authKey, nil
+}
+
+// parseKDF decodes the KDF parameters cached in the "kdf" metadata key. Data
+// cached before they were stored used the legacy parameters.
+func parseKDF(raw []byte) (cryptox.KDFParams, error) {
+	if raw == nil {
+		return cryptox.LegacyKDFParams, nil
+	}
+	var p cryptox.KDFParams
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return cryptox.KDFParams{}, err
+	}
+	return p, nil
+}
+
+// saveOfflineData persists minimal auth metadata required for offline login:
+// username, salt, KDF parameters, verifier, and wrapped vault key, in a
+// single transaction.
+func (a *authService) saveOfflineData(ctx context.Context, userName string, creds models.Credentials, varifier []byte) error {
+	return dbx.WithTx(ctx, a.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+		return writeOfflineData(ctx, metadata.NewSQLiteRepository(tx), userName, creds, varifier)
 	})
 }
 
-// Register creates a new account on the server. It generates a random salt,
-// derives a master key from the provided password, computes a verifier,
-// and sends salt/verifier to the server.
+// writeOfflineData stores the offline login metadata of saveOfflineData
+// with metadataRepo.
+func writeOfflineData(ctx context.Context, metadataRepo metadata.Repository, userName string, creds models.Credentials, varifier []byte) error {
+	kdf, err := json.Marshal(creds.KDF)
+	if err != nil {
+		return err
+	}
+	if err := metadataRepo.Set(ctx, "username", []byte(userName)); err != nil {
+		return err
+	}
+	if err := metadataRepo.Set(ctx, "salt", creds.Salt); err != nil {
+		return err
+	}
+	if err := metadataRepo.Set(ctx, "kdf", kdf); err != nil {
+		return err
+	}
+	if err := metadataRepo.Set(ctx, "verifier", varifier); err != nil {
+		return err
+	}
+	return metadataRepo.Set(ctx, "vault_key", creds.WrappedVaultKey)
+}
+
+// Enable2FA starts a TOTP enrollment of the account logged in on this device
+// and returns the secret in base32 and its otpauth URI, to be added to an
+// authenticator app. If the account has a second factor already, code has to
+// be a current TOTP or recovery code of it (see client.Client.Enable2FA).
+func (a *authService) Enable2FA(ctx context.Context, code string) (string, string, error) {
+	return a.client.Enable2FA(ctx, code)
+}
+
+// Confirm2FA completes the enrollment with a code from the authenticator app
+// and returns the one-time recovery codes. Online logins ask for a code from
+// then on; offline logins do not, as they only unlock data already on this
+// device. Returns client.ErrInvalidCode for a wrong code.
+func (a *authService) Confirm2FA(ctx context.Context, code string) ([]string, error) {
+	return a.client.Confirm2FA(ctx, code)
+}
+
+// Register creates a new account on the server. It generates a random salt
+// and vault key, derives the master key from the provided password with the
+// server's KDF policy (as returned by GetSalt for an unknown username) and
+// from it the auth key and key-encryption key, and sends the salt, KDF
+// parameters, the SRP verifier over the auth key and the wrapped vault key to
+// the server.
 func (a *authService) Register(ctx context.Context, username string, password []byte) error {
-	salt := common.GenerateRandByteArray(32)
-	key := cryptox.DeriveMasterKey(password, salt)
-	verifier := cryptox.MakeVerifier(key)
+	info, err := a.client.GetSalt(ctx, username)
+	if err != nil {
+		return fmt.Errorf("get salt error: %w", err)
+	}
+
+	vaultKey := cryptox.NewVaultKey()
+	defer common.WipeByteArray(vaultKey)
+	creds, _, err := newCredentials(username, password, vaultKey, info.KDF)
+	if err != nil {
+		return err
+	}
 
-	if err := a.client.Register(ctx, username, salt, verifier); err != nil {
+	if err := a.client.Register(ctx, username, creds); err != nil {
 		return err
 	}
 	return nil
 }
 
+// ChangePassword changes the password of the account whose offline data is
+// cached on this device and returns the vault key. The vault key is
+// unwrapped from the cache with the key-encryption key of oldPassword, which
+// also checks oldPassword locally, and wrapped again under a key-encryption
+// key derived from newPassword and a fresh salt, with the server's KDF policy
+// if the account is below it. Entries and file keys are encrypted under the
+// vault key, so none of them has to be re-encrypted, unless the vault key is
+// the master key of oldPassword (an account from before vault keys whose
+// replacement at login failed): then the vault is re-keyed with a random
+// key as in rekeyVault, so that oldPassword no longer decrypts anything.
+//
+// The server is sent the new credentials together with an SRP proof of
+// oldPassword; it revokes the refresh tokens of all devices, which have to
+// log in with newPassword again. On success the offline data is replaced.
+// Returns client.ErrLocalDataNotAvailable if no vault key is cached (an
+// online login caches it) and client.ErrUnauthorized if oldPassword is wrong.
+func (a *authService) ChangePassword(ctx context.Context, oldPassword, newPassword []byte) ([]byte, error) {
+	metadataRepo := a.getMetadataRepo()
+
+	userName, err := metadataRepo.Get(ctx, "username")
+	if err != nil {
+		return nil, client.ErrLocalDataNotAvailable
+	}
+	savedVaultKey, err := metadataRepo.Get(ctx, "vault_key")
+	if err != nil || userName == nil || savedVaultKey == nil {
+		return nil, client.ErrLocalDataNotAvailable
+	}
+
+	info, err := a.client.GetSalt(ctx, string(userName))
+	if err != nil {
+		return nil, fmt.Errorf("get salt error: %w", err)
+	}
+	if info.LegacyKeys {
+		return nil, client.ErrUnauthorized
+	}
+	masterKey, err := cryptox.DeriveMasterKey(oldPassword, info.Salt, info.KDF)
+	if err != nil {
+		return nil, fmt.Errorf("derive master key: %w", err)
+	}
+	vaultKey, err := cryptox.UnwrapKey(savedVaultKey, cryptox.DeriveKEK(masterKey))
+	if err != nil {
+		return nil, client.ErrUnauthorized
+	}
+
+	kdf := info.KDF
+	if info.KDFUpgrade != nil {
+		kdf = *info.KDFUpgrade
+	}
+	if bytes.Equal(vaultKey, masterKey) {
+		defer common.WipeByteArray(vaultKey)
+		return a.rekeyVault(ctx, string(userName), newPassword, kdf, info.Salt, cryptox.DeriveAuthKey(masterKey), vaultKey)
+	}
+
+	creds, newAuthKey, err := newCredentials(string(userName), newPassword, vaultKey, kdf)
+	if err != nil {
+		return nil, err
+	}
+
+	srp, sessionID, proof, err := a.srpProve(ctx, string(userName), info.Salt, cryptox.DeriveAuthKey(masterKey))
+	if err != nil {
+		return nil, err
+	}
+	serverProof, _, err := a.client.ChangePassword(ctx, sessionID, proof, creds, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := srp.VerifyServer(serverProof); err != nil {
+		return nil, client.ErrUnauthorized
+	}
+
+	if err := a.saveOfflineData(ctx, string(userName), creds, cryptox.MakeVerifier(newAuthKey)); err != nil {
+		return nil, fmt.Errorf("offline data saving error: %w", err)
+	}
+	return vaultKey, nil
+}
+
+// ListSessions proxies the session listing to the underlying client.
+func (a *authService) ListSessions(ctx context.Context) ([]*models.Session, error) {
+	return a.client.ListSessions(ctx)
+}
+
+// RevokeSession proxies a session revocation to the underlying client.
+func (a *authService) RevokeSession(ctx context.Context, id string) error {
+	return a.client.RevokeSession(ctx, id)
+}
+
+// RevokeOtherSessions proxies the revocation of every other session to the
+// underlying client.
+func (a *authService) RevokeOtherSessions(ctx context.Context) (int64, error) {
+	return a.client.RevokeAllOtherSessions(ctx)
+}
+
+// Logout proxies the end of this device's session to the underlying client.
+func (a *authService) Logout(ctx context.Context) error {
+	return a.client.Logout(ctx)
+}
+
 // Ping proxies a liveness check to the underlying client.
 func (a *authService) Ping(ctx context.Context) error {
 	return a.client.Ping(ctx)
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"errors"
@@ -9,6 +10,8 @@ import (
 
 	"github.com/dmitrijs2005/gophkeeper/internal/client/client"
 	"github.com/dmitrijs2005/gophkeeper/internal/client/models"
+	"github.com/dmitrijs2005/gophkeeper/internal/client/repositories/entries"
+	"github.com/dmitrijs2005/gophkeeper/internal/client/repositories/files"
 	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
 	"github.com/stretchr/testify/require"
 
@@ -39,6 +42,14 @@ func insertMeta(t *testing.T, db *sql.DB, k string, v []byte) {
 	require.NoError(t, err)
 }
 
+// deriveMasterKey is cryptox.DeriveMasterKey for valid parameters.
+func deriveMasterKey(t *testing.T, password, salt []byte, p cryptox.KDFParams) []byte {
+	t.Helper()
+	mk, err := cryptox.DeriveMasterKey(password, salt, p)
+	require.NoError(t, err)
+	return mk
+}
+
 func getMeta(t *testing.T, db *sql.DB, k string) []byte {
 	t.Helper()
 	var v []byte
@@ -57,6 +68,23 @@ type fakeClient struct {
 	GetSaltErr error
 
 	LoginErr error
+	// SRPVerifier, if set, makes LoginStart/LoginFinish act as an SRP server
+	// for that verifier. LegacyVerifier, if set, is the verifier of an
+	// account that predates SRP: LoginFinish rejects every login and Login
+	// accepts it.
+	SRPVerifier    []byte
+	LegacyVerifier []byte
+	BadServerProof bool
+	// LegacyKeys and WrappedVaultKey are what GetSalt and LoginFinish report.
+	LegacyKeys      bool
+	WrappedVaultKey []byte
+	// KDF (legacy parameters if unset) and KDFUpgrade are what GetSalt
+	// reports as the account's parameters and the policy to upgrade to.
+	KDF        cryptox.KDFParams
+	KDFUpgrade *cryptox.KDFParams
+
+	srpServer    *cryptox.SRPServer
+	clientPublic []byte
 
 	PingErr error
 
@@ -65,42 +93,249 @@ type fakeClient struct {
 	GetPresignedGetURLRet string
 	GetPresignedGetURLErr error
 
+	Revisions   []*models.Entry
+	RevisionErr error
+
+	UpdatedKeys  map[string][]byte
+	UpdateKeyErr error
+
 	LastRegisterUser string
 	LastRegisterSalt []byte
 	LastRegisterKey  []byte
+	LastRegisterVK   []byte
+	LastRegisterKDF  cryptox.KDFParams
 
 	LastGetSaltUser string
 
-	LastLoginUser string
-	LastLoginKey  []byte
+	LastLoginUser        string
+	LoginStartCalls      int
+	LastLoginKey         []byte
+	LastLoginSRPVerifier []byte
+	LastLoginVK          []byte
+
+	UpgradedSRPVerifier []byte
+	UpgradedVK          []byte
+
+	ChangePasswordErr error
+	// ServerEntries and ServerFiles are the account's content that Sync
+	// returns, up to ServerVersion; a ChangePassword with a re-key replaces
+	// it, unless RekeyConflicts is set, which makes that many of them fail.
+	ServerEntries  []*models.Entry
+	ServerFiles    []*models.File
+	ServerVersion  int64
+	RekeyConflicts int
+	LastRekey      *models.VaultRekey
+
+	UpgradeKDFErr   error
+	UpgradeKDFCalls int
+
+	Sessions       []*models.Session
+	SessionErr     error
+	RevokedSession string
+	RevokedOthers  int64
+	LoggedOut      bool
+
+	// TwoFactorCode, if set, makes LoginFinish ask for this code.
+	TwoFactorCode  string
+	Verify2FACalls int
+	Confirm2FAErr  error
+	Enable2FACode  string
 }
 
 func (f *fakeClient) Close() error { return f.CloseErr }
 
-func (f *fakeClient) Register(ctx context.Context, username string, salt []byte, key []byte) error {
+func (f *fakeClient) Register(ctx context.Context, username string, creds models.Credentials) error {
 	f.LastRegisterUser = username
-	f.LastRegisterSalt = append([]byte(nil), salt...)
-	f.LastRegisterKey = append([]byte(nil), key...)
+	f.LastRegisterSalt = append([]byte(nil), creds.Salt...)
+	f.LastRegisterKey = append([]byte(nil), creds.SRPVerifier...)
+	f.LastRegisterVK = append([]byte(nil), creds.WrappedVaultKey...)
+	f.LastRegisterKDF = creds.KDF
 	return f.RegisterErr
 }
 
-func (f *fakeClient) GetSalt(ctx context.Context, username string) ([]byte, error) {
+func (f *fakeClient) GetSalt(ctx context.Context, username string) (*models.SaltInfo, error) {
 	f.LastGetSaltUser = username
-	return append([]byte(nil), f.GetSaltRet...), f.GetSaltErr
+	if f.GetSaltErr != nil {
+		return nil, f.GetSaltErr
+	}
+	kdf := f.KDF
+	if kdf == (cryptox.KDFParams{}) {
+		kdf = cryptox.LegacyKDFParams
+	}
+	return &models.SaltInfo{
+		Salt:       append([]byte(nil), f.GetSaltRet...),
+		KDF:        kdf,
+		KDFUpgrade: f.KDFUpgrade,
+		LegacyKeys: f.LegacyKeys,
+	}, nil
 }
 
-func (f *fakeClient) Login(ctx context.Context, username string, key []byte) error {
+func (f *fakeClient) Login(ctx context.Context, username string, key []byte, srpVerifier []byte, wrappedVaultKey []byte) error {
 	f.LastLoginUser = username
 	f.LastLoginKey = append([]byte(nil), key...)
-	return f.LoginErr
+	f.LastLoginSRPVerifier = append([]byte(nil), srpVerifier...)
+	f.LastLoginVK = append([]byte(nil), wrappedVaultKey...)
+	if f.LoginErr != nil {
+		return f.LoginErr
+	}
+	if f.LegacyVerifier == nil || !bytes.Equal(key, f.LegacyVerifier) {
+		return client.ErrUnauthorized
+	}
+	f.upgrade(srpVerifier, wrappedVaultKey)
+	return nil
+}
+
+func (f *fakeClient) UpgradeKeys(ctx context.Context, srpVerifier []byte, wrappedVaultKey []byte) error {
+	f.UpgradedSRPVerifier = append([]byte(nil), srpVerifier...)
+	f.UpgradedVK = append([]byte(nil), wrappedVaultKey...)
+	f.upgrade(srpVerifier, wrappedVaultKey)
+	return nil
+}
+
+// upgrade stores the keys of an upgraded account, so that later logins are
+// served with them.
+func (f *fakeClient) upgrade(srpVerifier []byte, wrappedVaultKey []byte) {
+	f.SRPVerifier, f.WrappedVaultKey = srpVerifier, wrappedVaultKey
+	f.LegacyKeys, f.LegacyVerifier = false, nil
+}
+
+func (f *fakeClient) LoginStart(ctx context.Context, username string, clientPublic []byte) (string, []byte, error) {
+	f.LastLoginUser = username
+	f.LoginStartCalls++
+	if f.LoginErr != nil {
+		return "", nil, f.LoginErr
+	}
+	if f.LegacyVerifier != nil {
+		// a session that cannot be finished, like the server's
+		srv, err := cryptox.NewSRPServer(username, f.GetSaltRet, cryptox.SRPVerifier(username, f.GetSaltRet, cryptox.NewVaultKey()))
+		if err != nil {
+			return "", nil, err
+		}
+		f.srpServer, f.clientPublic = srv, clientPublic
+		return "s0", srv.PublicKey(), nil
+	}
+	srv, err := cryptox.NewSRPServer(username, f.GetSaltRet, f.SRPVerifier)
+	if err != nil {
+		return "", nil, err
+	}
+	f.srpServer, f.clientPublic = srv, clientPublic
+	return "s1", srv.PublicKey(), nil
+}
+
+func (f *fakeClient) LoginFinish(ctx context.Context, sessionID string, clientProof []byte) ([]byte, []byte, string, error) {
+	m2, err := f.srpServer.VerifyClient(f.clientPublic, clientProof)
+	if err != nil {
+		return nil, nil, "", client.ErrUnauthorized
+	}
+	if f.BadServerProof {
+		m2[0] ^= 1
+	}
+	if f.TwoFactorCode != "" {
+		return m2, nil, "c1", nil
+	}
+	return m2, f.WrappedVaultKey, "", nil
+}
+
+func (f *fakeClient) Verify2FA(ctx context.Context, challengeID string, code string) ([]byte, error) {
+	f.Verify2FACalls++
+	if challengeID != "c1" || code != f.TwoFactorCode {
+		return nil, client.ErrUnauthorized
+	}
+	return f.WrappedVaultKey, nil
+}
+
+func (f *fakeClient) Enable2FA(ctx context.Context, code string) (string, string, error) {
+	f.Enable2FACode = code
+	return "SECRET", "otpauth://totp/x", nil
+}
+
+func (f *fakeClient) Confirm2FA(ctx context.Context, code string) ([]string, error) {
+	if f.Confirm2FAErr != nil {
+		return nil, f.Confirm2FAErr
+	}
+	return []string{"AAAA-BBBB"}, nil
+}
+
+// ChangePassword checks the proof like LoginFinish and then stores the new
+// credentials, so that later logins are served with them, and the
+// re-encrypted content, if any, under new versions.
+func (f *fakeClient) ChangePassword(ctx context.Context, sessionID string, clientProof []byte, creds models.Credentials, rekey *models.VaultRekey) ([]byte, map[string]int64, error) {
+	if f.ChangePasswordErr != nil {
+		return nil, nil, f.ChangePasswordErr
+	}
+	if rekey != nil && f.RekeyConflicts > 0 {
+		f.RekeyConflicts--
+		return nil, nil, client.ErrConflict
+	}
+	m2, err := f.swapCredentials(clientProof, creds)
+	if err != nil || rekey == nil {
+		return m2, nil, err
+	}
+
+	f.LastRekey = rekey
+	versions := make(map[string]int64, len(rekey.Entries))
+	f.ServerEntries = nil
+	for _, e := range rekey.Entries {
+		f.ServerVersion++
+		stored := *e
+		stored.Version = f.ServerVersion
+		f.ServerEntries = append(f.ServerEntries, &stored)
+		versions[e.Id] = f.ServerVersion
+	}
+	for _, sf := range f.ServerFiles {
+		for _, rf := range rekey.Files {
+			if rf.EntryID == sf.EntryID {
+				sf.EncryptedFileKey = rf.EncryptedFileKey
+			}
+		}
+	}
+	return m2, versions, nil
+}
+
+// UpgradeKDF checks the proof like LoginFinish and then stores the new
+// credentials.
+func (f *fakeClient) UpgradeKDF(ctx context.Context, sessionID string, clientProof []byte, creds models.Credentials) ([]byte, error) {
+	f.UpgradeKDFCalls++
+	if f.UpgradeKDFErr != nil {
+		return nil, f.UpgradeKDFErr
+	}
+	return f.swapCredentials(clientProof, creds)
+}
+
+func (f *fakeClient) swapCredentials(clientProof []byte, creds models.Credentials) ([]byte, error) {
+	m2, err := f.srpServer.VerifyClient(f.clientPublic, clientProof)
+	if err != nil {
+		return nil, client.ErrUnauthorized
+	}
+	f.GetSaltRet, f.SRPVerifier, f.WrappedVaultKey = creds.Salt, creds.SRPVerifier, creds.WrappedVaultKey
+	f.KDF, f.KDFUpgrade = creds.KDF, nil
+	return m2, nil
+}
+
+// newAccount returns a fakeClient serving an account with current keys for
+// user/pass and the account's vault key.
+func newAccount(t *testing.T, user, pass string, salt []byte) (*fakeClient, []byte) {
+	t.Helper()
+	mk := deriveMasterKey(t, []byte(pass), salt, cryptox.LegacyKDFParams)
+	vk := cryptox.NewVaultKey()
+	wrapped, err := cryptox.WrapKey(vk, cryptox.DeriveKEK(mk))
+	require.NoError(t, err)
+	return &fakeClient{
+		GetSaltRet:      salt,
+		SRPVerifier:     cryptox.SRPVerifier(user, salt, cryptox.DeriveAuthKey(mk)),
+		WrappedVaultKey: wrapped,
+	}, vk
 }
 
 func (f *fakeClient) Ping(ctx context.Context) error { return f.PingErr }
 
 func (f *fakeClient) Sync(ctx context.Context, entries []*models.Entry, files []*models.File, maxVersion int64) (
-	processed []*models.Entry, newEntries []*models.Entry, newFiles []*models.File, uploadTasks []*models.FileUploadTask, globalMax int64, err error,
+	processed []*models.Entry, conflicts []*models.Entry, newEntries []*models.Entry, newFiles []*models.File, uploadTasks []*models.FileUploadTask, globalMax int64, err error,
 ) {
-	return nil, nil, nil, nil, 0, f.SyncErr
+	if f.SyncErr != nil {
+		return nil, nil, nil, nil, nil, 0, f.SyncErr
+	}
+	return nil, nil, f.ServerEntries, f.ServerFiles, nil, f.ServerVersion, nil
 }
 
 func (f *fakeClient) MarkUploaded(ctx context.Context, entryID string) error {
@@ -111,6 +346,71 @@ func (f *fakeClient) GetPresignedGetURL(ctx context.Context, entryID string) (st
 	return f.GetPresignedGetURLRet, f.GetPresignedGetURLErr
 }
 
+func (f *fakeClient) ListRevisions(ctx context.Context, entryID string) ([]*models.Entry, error) {
+	return f.Revisions, f.RevisionErr
+}
+
+func (f *fakeClient) UpdateFileKey(ctx context.Context, entryID string, fileKey []byte) error {
+	if f.UpdateKeyErr != nil {
+		return f.UpdateKeyErr
+	}
+	if f.UpdatedKeys == nil {
+		f.UpdatedKeys = map[string][]byte{}
+	}
+	f.UpdatedKeys[entryID] = fileKey
+	return nil
+}
+
+func (f *fakeClient) StartMultipartUpload(ctx context.Context, entryID string) (string, error) {
+	return "", client.ErrNotFound
+}
+
+func (f *fakeClient) PresignUploadParts(ctx context.Context, entryID string, uploadID string, partNumbers []int32) ([]string, error) {
+	return nil, client.ErrNotFound
+}
+
+func (f *fakeClient) CompleteMultipartUpload(ctx context.Context, entryID string, uploadID string, parts []models.UploadedPart) error {
+	return client.ErrNotFound
+}
+
+func (f *fakeClient) AbortMultipartUpload(ctx context.Context, entryID string, uploadID string) error {
+	return client.ErrNotFound
+}
+
+func (f *fakeClient) RequestUploadURL(ctx context.Context, entryID string) (string, error) {
+	return "", client.ErrNotFound
+}
+
+func (f *fakeClient) ListSessions(ctx context.Context) ([]*models.Session, error) {
+	return f.Sessions, f.SessionErr
+}
+
+func (f *fakeClient) RevokeSession(ctx context.Context, id string) error {
+	f.RevokedSession = id
+	return f.SessionErr
+}
+
+func (f *fakeClient) RevokeAllOtherSessions(ctx context.Context) (int64, error) {
+	return f.RevokedOthers, f.SessionErr
+}
+
+func (f *fakeClient) Logout(ctx context.Context) error {
+	f.LoggedOut = true
+	return f.SessionErr
+}
+
+func (f *fakeClient) GetRevision(ctx context.Context, entryID string, version int64) (*models.Entry, error) {
+	if f.RevisionErr != nil {
+		return nil, f.RevisionErr
+	}
+	for _, r := range f.Revisions {
+		if r.Version == version {
+			return r, nil
+		}
+	}
+	return nil, client.ErrNotFound
+}
+
 // ---- TESTS ----
 
 func TestOfflineLogin_NoLocalData_CurrentBehaviorUnauthorized(t *testing.T) {
@@ -140,7 +440,7 @@ func TestOfflineLogin_WrongPassword_Unauthorized(t *testing.T) {
 	db := setupDB(t)
 
 	salt := []byte("salty")
-	mk := cryptox.DeriveMasterKey([]byte("correct"), salt)
+	mk := deriveMasterKey(t, []byte("correct"), salt, cryptox.LegacyKDFParams)
 	ver := cryptox.MakeVerifier(mk)
 
 	insertMeta(t, db, "username", []byte("user"))
@@ -154,11 +454,11 @@ func TestOfflineLogin_WrongPassword_Unauthorized(t *testing.T) {
 	require.ErrorIs(t, err, client.ErrUnauthorized)
 }
 
-func TestOfflineLogin_Success_ReturnsMasterKey(t *testing.T) {
+func TestOfflineLogin_LegacyCache_ReturnsMasterKey(t *testing.T) {
 	db := setupDB(t)
 
 	salt := []byte("salty")
-	mk := cryptox.DeriveMasterKey([]byte("pass"), salt)
+	mk := deriveMasterKey(t, []byte("pass"), salt, cryptox.LegacyKDFParams)
 	ver := cryptox.MakeVerifier(mk)
 
 	insertMeta(t, db, "username", []byte("user"))
@@ -178,7 +478,7 @@ func TestOnlineLogin_GetSaltError_Wrapped(t *testing.T) {
 	fc := &fakeClient{GetSaltErr: errors.New("network down")}
 	svc := NewAuthService(fc, db)
 
-	_, err := svc.OnlineLogin(context.Background(), "u", []byte("p"))
+	_, err := svc.OnlineLogin(context.Background(), "u", []byte("p"), nil)
 	require.Error(t, err)
 	require.True(t, strings.HasPrefix(err.Error(), "get salt error:"))
 }
@@ -189,29 +489,470 @@ func TestOnlineLogin_LoginError_Wrapped(t *testing.T) {
 	fc := &fakeClient{GetSaltRet: salt, LoginErr: errors.New("bad creds")}
 	svc := NewAuthService(fc, db)
 
-	_, err := svc.OnlineLogin(context.Background(), "u", []byte("p"))
+	_, err := svc.OnlineLogin(context.Background(), "u", []byte("p"), nil)
 	require.Error(t, err)
 	require.True(t, strings.HasPrefix(err.Error(), "login error:"))
 }
 
-func TestOnlineLogin_Success_SavesOfflineDataAndReturnsMasterKey(t *testing.T) {
+func TestOnlineLogin_Success_SavesOfflineDataAndReturnsVaultKey(t *testing.T) {
 	db := setupDB(t)
-	fc := &fakeClient{GetSaltRet: []byte("salt")}
+	salt := []byte("salt")
+	fc, vk := newAccount(t, "user", "pass", salt)
 	svc := NewAuthService(fc, db)
 
-	got, err := svc.OnlineLogin(context.Background(), "user", []byte("pass"))
+	got, err := svc.OnlineLogin(context.Background(), "user", []byte("pass"), nil)
 	require.NoError(t, err)
+	require.Equal(t, vk, got)
 
+	mk := deriveMasterKey(t, []byte("pass"), salt, cryptox.LegacyKDFParams)
 	require.Equal(t, []byte("user"), getMeta(t, db, "username"))
 	require.Equal(t, []byte("salt"), getMeta(t, db, "salt"))
-	savedVerifier := getMeta(t, db, "verifier")
-	require.NotEmpty(t, savedVerifier)
-
-	expected := cryptox.DeriveMasterKey([]byte("pass"), []byte("salt"))
-	require.Equal(t, expected, got)
+	require.Equal(t, cryptox.MakeVerifier(cryptox.DeriveAuthKey(mk)), getMeta(t, db, "verifier"))
+	require.Equal(t, fc.WrappedVaultKey, getMeta(t, db, "vault_key"))
 
 	require.Equal(t, "user", fc.LastLoginUser)
-	require.Equal(t, savedVerifier, fc.LastLoginKey)
+	require.Nil(t, fc.LastLoginKey, "legacy Login must not be used for SRP accounts")
+	require.Nil(t, fc.UpgradedVK, "current accounts must not be upgraded")
+
+	// the cached data is enough to recover the vault key offline
+	off, err := svc.OfflineLogin(context.Background(), "user", []byte("pass"))
+	require.NoError(t, err)
+	require.Equal(t, vk, off)
+	_, err = svc.OfflineLogin(context.Background(), "user", []byte("wrong"))
+	require.ErrorIs(t, err, client.ErrUnauthorized)
+}
+
+func TestOnlineLogin_WrongPassword_Unauthorized(t *testing.T) {
+	db := setupDB(t)
+	fc, _ := newAccount(t, "user", "pass", []byte("salt"))
+	svc := NewAuthService(fc, db)
+
+	_, err := svc.OnlineLogin(context.Background(), "user", []byte("wrong"), nil)
+	require.ErrorIs(t, err, client.ErrUnauthorized)
+	// one failed login, which the server's lockout counts once
+	require.Equal(t, 1, fc.LoginStartCalls)
+	require.Nil(t, fc.LastLoginKey, "the legacy Login must not be tried")
+}
+
+func TestUpgradeLogin_FallsBackOnlyForLegacyKeys(t *testing.T) {
+	db := setupDB(t)
+	salt := []byte("salt")
+	mk := deriveMasterKey(t, []byte("pass"), salt, cryptox.LegacyKDFParams)
+	fc := &fakeClient{GetSaltRet: salt, SRPVerifier: cryptox.SRPVerifier("user", salt, mk)}
+	svc := NewAuthService(fc, db).(*authService)
+
+	wrong := deriveMasterKey(t, []byte("wrong"), salt, cryptox.LegacyKDFParams)
+	info := &models.SaltInfo{Salt: salt, KDF: cryptox.LegacyKDFParams}
+	err := svc.upgradeLogin(context.Background(), info, "user", wrong, cryptox.DeriveAuthKey(wrong), []byte("wvk"), nil)
+	require.ErrorIs(t, err, client.ErrUnauthorized)
+	require.Nil(t, fc.LastLoginKey, "the legacy Login must not be tried")
+
+	info.LegacyKeys = true
+	err = svc.upgradeLogin(context.Background(), info, "user", wrong, cryptox.DeriveAuthKey(wrong), []byte("wvk"), nil)
+	require.ErrorIs(t, err, client.ErrUnauthorized)
+	require.NotNil(t, fc.LastLoginKey, "Login must be tried")
+}
+
+func TestOnlineLogin_RejectsServerWithoutVerifier(t *testing.T) {
+	db := setupDB(t)
+	fc, _ := newAccount(t, "user", "pass", []byte("salt"))
+	fc.BadServerProof = true
+	svc := NewAuthService(fc, db)
+
+	_, err := svc.OnlineLogin(context.Background(), "user", []byte("pass"), nil)
+	require.ErrorIs(t, err, client.ErrUnauthorized)
+}
+
+func TestOnlineLogin_SecondFactor(t *testing.T) {
+	db := setupDB(t)
+	fc, vk := newAccount(t, "user", "pass", []byte("salt"))
+	fc.TwoFactorCode = "123456"
+	svc := NewAuthService(fc, db)
+
+	// a mistyped code can be retyped
+	codes := []string{"111111", "123456"}
+	prompt := func(ctx context.Context) (string, error) {
+		code := codes[0]
+		codes = codes[1:]
+		return code, nil
+	}
+	got, err := svc.OnlineLogin(context.Background(), "user", []byte("pass"), prompt)
+	require.NoError(t, err)
+	require.Equal(t, vk, got)
+	require.Equal(t, 2, fc.Verify2FACalls)
+	require.Equal(t, fc.WrappedVaultKey, getMeta(t, db, "vault_key"))
+
+	fc.Verify2FACalls = 0
+	wrong := func(ctx context.Context) (string, error) { return "000000", nil }
+	_, err = svc.OnlineLogin(context.Background(), "user", []byte("pass"), wrong)
+	require.ErrorIs(t, err, client.ErrUnauthorized)
+	require.Equal(t, secondFactorTries, fc.Verify2FACalls)
+
+	// without a way to ask for a code the login fails
+	_, err = svc.OnlineLogin(context.Background(), "user", []byte("pass"), nil)
+	require.ErrorIs(t, err, client.ErrUnauthorized)
+
+	// a server that does not know the password is not sent a code
+	fc.BadServerProof = true
+	asked := false
+	_, err = svc.OnlineLogin(context.Background(), "user", []byte("pass"), func(ctx context.Context) (string, error) {
+		asked = true
+		return "123456", nil
+	})
+	require.ErrorIs(t, err, client.ErrUnauthorized)
+	require.False(t, asked)
+}
+
+func TestOnlineLogin_UpgradesLegacyVerifierAccount(t *testing.T) {
+	db := setupDBEntry(t)
+	salt := []byte("salt")
+	mk := deriveMasterKey(t, []byte("pass"), salt, cryptox.LegacyKDFParams)
+	fc := &fakeClient{GetSaltRet: salt, LegacyKeys: true, LegacyVerifier: cryptox.MakeVerifier(mk)}
+	svc := NewAuthService(fc, db)
+
+	vk, err := svc.OnlineLogin(context.Background(), "user", []byte("pass"), nil)
+	require.NoError(t, err)
+
+	// the account is upgraded with its master key as vault key ...
+	require.Equal(t, cryptox.MakeVerifier(mk), fc.LastLoginKey)
+	require.Equal(t, cryptox.SRPVerifier("user", salt, cryptox.DeriveAuthKey(mk)), fc.LastLoginSRPVerifier)
+	unwrapped, err := cryptox.UnwrapKey(fc.LastLoginVK, cryptox.DeriveKEK(mk))
+	require.NoError(t, err)
+	require.Equal(t, mk, unwrapped)
+
+	// ... which is replaced by a random one right away
+	require.NotEqual(t, mk, vk)
+	require.NotNil(t, fc.LastRekey)
+	got, err := svc.OfflineLogin(context.Background(), "user", []byte("pass"))
+	require.NoError(t, err)
+	require.Equal(t, vk, got)
+}
+
+func TestOnlineLogin_LegacyVerifierWrongPassword(t *testing.T) {
+	db := setupDBEntry(t)
+	salt := []byte("salt")
+	verifier := cryptox.MakeVerifier(deriveMasterKey(t, []byte("pass"), salt, cryptox.LegacyKDFParams))
+	fc := &fakeClient{GetSaltRet: salt, LegacyKeys: true, LegacyVerifier: verifier}
+	svc := NewAuthService(fc, db)
+
+	// the SRP login is rejected like for any account, and Login decides
+	_, err := svc.OnlineLogin(context.Background(), "user", []byte("wrong"), nil)
+	require.ErrorIs(t, err, client.ErrUnauthorized)
+	require.Equal(t, 1, fc.LoginStartCalls)
+	require.NotNil(t, fc.LastLoginKey, "Login must be tried")
+	require.Equal(t, verifier, fc.LegacyVerifier, "the account must not be upgraded")
+}
+
+func TestOnlineLogin_UpgradesLegacySRPAccount(t *testing.T) {
+	db := setupDBEntry(t)
+	salt := []byte("salt")
+	mk := deriveMasterKey(t, []byte("pass"), salt, cryptox.LegacyKDFParams)
+	fc := &fakeClient{GetSaltRet: salt, LegacyKeys: true, SRPVerifier: cryptox.SRPVerifier("user", salt, mk)}
+	svc := NewAuthService(fc, db)
+
+	vk, err := svc.OnlineLogin(context.Background(), "user", []byte("pass"), nil)
+	require.NoError(t, err)
+
+	require.Nil(t, fc.LastLoginKey, "an SRP account is not sent its legacy verifier")
+	require.Equal(t, cryptox.SRPVerifier("user", salt, cryptox.DeriveAuthKey(mk)), fc.UpgradedSRPVerifier)
+	unwrapped, err := cryptox.UnwrapKey(fc.UpgradedVK, cryptox.DeriveKEK(mk))
+	require.NoError(t, err)
+	require.Equal(t, mk, unwrapped)
+
+	require.NotEqual(t, mk, vk)
+	require.Equal(t, fc.WrappedVaultKey, getMeta(t, db, "vault_key"))
+	got, err := svc.OnlineLogin(context.Background(), "user", []byte("pass"), nil)
+	require.NoError(t, err)
+	require.Equal(t, vk, got)
+}
+
+// legacyAccount returns a fakeClient serving an account that predates the
+// key hierarchy, with a note and a tombstone sealed under its master key and
+// a file attached to the note, and the master key.
+func legacyAccount(t *testing.T, user, pass string, salt []byte) (*fakeClient, []byte) {
+	t.Helper()
+	mk := deriveMasterKey(t, []byte(pass), salt, cryptox.LegacyKDFParams)
+	fc := &fakeClient{GetSaltRet: salt, LegacyKeys: true, SRPVerifier: cryptox.SRPVerifier(user, salt, mk), ServerVersion: 2}
+
+	env, err := models.Wrap(models.EntryTypeNote, "note", nil, models.Note{Text: "secret"})
+	require.NoError(t, err)
+	for i, id := range []string{"e1", "e2"} {
+		e, err := sealEntry(id, env, mk)
+		require.NoError(t, err)
+		e.Version = int64(i + 1)
+		e.Deleted = id == "e2"
+		fc.ServerEntries = append(fc.ServerEntries, e)
+	}
+	fileKey, err := cryptox.WrapKey([]byte("0123456789abcdef0123456789abcdef"), mk)
+	require.NoError(t, err)
+	fc.ServerFiles = []*models.File{{EntryID: "e1", EncryptedFileKey: fileKey, Nonce: []byte("n1")}}
+	return fc, mk
+}
+
+func TestOnlineLogin_ReplacesPasswordDerivedVaultKey(t *testing.T) {
+	db := setupDBEntry(t)
+	ctx := context.Background()
+	fc, mk := legacyAccount(t, "user", "pass", []byte("salt"))
+
+	// this device has the note synchronized with its file, and a new entry
+	entryRepo := entries.NewSQLiteRepository(db)
+	synced := *fc.ServerEntries[0]
+	_, err := entryRepo.ApplyRemote(ctx, &synced)
+	require.NoError(t, err)
+	env, err := models.Wrap(models.EntryTypeNote, "draft", nil, models.Note{Text: "draft"})
+	require.NoError(t, err)
+	draft, err := sealEntry("e3", env, mk)
+	require.NoError(t, err)
+	require.NoError(t, entryRepo.CreateOrUpdate(ctx, draft))
+	require.NoError(t, files.NewSQLiteRepository(db).CreateOrUpdate(ctx, &models.File{
+		EntryID: "e1", EncryptedFileKey: fc.ServerFiles[0].EncryptedFileKey, Nonce: []byte("n1"), UploadStatus: "completed",
+	}))
+
+	svc := NewAuthService(fc, db)
+	vk, err := svc.OnlineLogin(ctx, "user", []byte("pass"), nil)
+	require.NoError(t, err)
+	require.NotEqual(t, mk, vk)
+
+	// the server has everything under the new key only, under new versions
+	require.Len(t, fc.LastRekey.Entries, 2)
+	for _, e := range fc.ServerEntries {
+		_, _, err := openEnvelope(e.Details, e.NonceDetails, vk, e.Id, false)
+		require.NoError(t, err)
+		_, _, err = openEnvelope(e.Details, e.NonceDetails, mk, e.Id, true)
+		require.Error(t, err)
+		require.Greater(t, e.Version, int64(2))
+	}
+	require.True(t, fc.ServerEntries[1].Deleted)
+	raw, err := cryptox.UnwrapKey(fc.ServerFiles[0].EncryptedFileKey, vk)
+	require.NoError(t, err)
+	require.Equal(t, []byte("0123456789abcdef0123456789abcdef"), raw)
+
+	// and so has this device, which keeps its local change
+	entrySvc := NewEntryService(fc, db)
+	note, err := entrySvc.Get(ctx, "e1", vk)
+	require.NoError(t, err)
+	require.Equal(t, "note", note.Title)
+	stored, err := entryRepo.GetByIDIncludingDeleted(ctx, "e1")
+	require.NoError(t, err)
+	require.Equal(t, fc.ServerEntries[0].Version, stored.Version)
+	require.False(t, stored.Pending)
+	got, err := entrySvc.Get(ctx, "e3", vk)
+	require.NoError(t, err)
+	require.Equal(t, "draft", got.Title)
+	stored, err = entryRepo.GetByIDIncludingDeleted(ctx, "e3")
+	require.NoError(t, err)
+	require.True(t, stored.Pending)
+	file, err := entrySvc.GetFile(ctx, "e1")
+	require.NoError(t, err)
+	raw, err = cryptox.UnwrapKey(file.EncryptedFileKey, vk)
+	require.NoError(t, err)
+	require.Equal(t, []byte("0123456789abcdef0123456789abcdef"), raw)
+
+	offline, err := svc.OfflineLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+	require.Equal(t, vk, offline)
+}
+
+func TestOnlineLogin_RetriesVaultKeyReplacement(t *testing.T) {
+	db := setupDBEntry(t)
+	ctx := context.Background()
+	fc, mk := legacyAccount(t, "user", "pass", []byte("salt"))
+	policy := cryptox.DefaultKDFParams
+	fc.KDFUpgrade = &policy
+	fc.RekeyConflicts = rekeyTries
+	svc := NewAuthService(fc, db)
+
+	// the account keeps working with its master key meanwhile, and the KDF
+	// upgrade waits, as it would hide that the vault key is the master key
+	vk, err := svc.OnlineLogin(ctx, "user", []byte("pass"), nil)
+	require.NoError(t, err)
+	require.Equal(t, mk, vk)
+	require.Nil(t, fc.LastRekey)
+	require.Zero(t, fc.UpgradeKDFCalls)
+
+	vk, err = svc.OnlineLogin(ctx, "user", []byte("pass"), nil)
+	require.NoError(t, err)
+	require.NotEqual(t, mk, vk)
+	require.NotNil(t, fc.LastRekey)
+	require.Equal(t, cryptox.DefaultKDFParams, fc.KDF)
+	require.Zero(t, fc.UpgradeKDFCalls)
+}
+
+func TestOnlineLogin_RetriesRekeyOnConflict(t *testing.T) {
+	db := setupDBEntry(t)
+	fc, mk := legacyAccount(t, "user", "pass", []byte("salt"))
+	fc.RekeyConflicts = rekeyTries - 1
+	svc := NewAuthService(fc, db)
+
+	vk, err := svc.OnlineLogin(context.Background(), "user", []byte("pass"), nil)
+	require.NoError(t, err)
+	require.NotEqual(t, mk, vk)
+	require.Zero(t, fc.RekeyConflicts)
+}
+
+func TestChangePassword_RewrapsVaultKey(t *testing.T) {
+	db := setupDB(t)
+	fc, vk := newAccount(t, "user", "old", []byte("salt"))
+	svc := NewAuthService(fc, db)
+
+	_, err := svc.OnlineLogin(context.Background(), "user", []byte("old"), nil)
+	require.NoError(t, err)
+
+	got, err := svc.ChangePassword(context.Background(), []byte("old"), []byte("new"))
+	require.NoError(t, err)
+	require.Equal(t, vk, got)
+	require.Nil(t, fc.LastRekey, "a random vault key is only re-wrapped")
+	require.NotEqual(t, []byte("salt"), fc.GetSaltRet, "a fresh salt must be used")
+	require.Equal(t, fc.GetSaltRet, getMeta(t, db, "salt"))
+	require.Equal(t, fc.WrappedVaultKey, getMeta(t, db, "vault_key"))
+
+	// the same vault key opens with the new password, online and offline
+	got, err = svc.OfflineLogin(context.Background(), "user", []byte("new"))
+	require.NoError(t, err)
+	require.Equal(t, vk, got)
+	_, err = svc.OfflineLogin(context.Background(), "user", []byte("old"))
+	require.ErrorIs(t, err, client.ErrUnauthorized)
+
+	got, err = svc.OnlineLogin(context.Background(), "user", []byte("new"), nil)
+	require.NoError(t, err)
+	require.Equal(t, vk, got)
+	_, err = svc.OnlineLogin(context.Background(), "user", []byte("old"), nil)
+	require.ErrorIs(t, err, client.ErrUnauthorized)
+}
+
+func TestChangePassword_ReplacesPasswordDerivedVaultKey(t *testing.T) {
+	db := setupDBEntry(t)
+	ctx := context.Background()
+	fc, mk := legacyAccount(t, "user", "old", []byte("salt"))
+	svc := NewAuthService(fc, db)
+
+	// the replacement at login failed, so the vault key is still the master
+	// key of the old password
+	fc.RekeyConflicts = rekeyTries
+	vk, err := svc.OnlineLogin(ctx, "user", []byte("old"), nil)
+	require.NoError(t, err)
+	require.Equal(t, mk, vk)
+	require.Nil(t, fc.LastRekey)
+
+	vk, err = svc.ChangePassword(ctx, []byte("old"), []byte("new"))
+	require.NoError(t, err)
+	require.NotEqual(t, mk, vk)
+	require.NotNil(t, fc.LastRekey)
+
+	// the old password decrypts neither the entries nor the file keys
+	for _, e := range fc.ServerEntries {
+		_, _, err := openEnvelope(e.Details, e.NonceDetails, mk, e.Id, true)
+		require.Error(t, err, "entry %s", e.Id)
+		_, _, err = openEnvelope(e.Details, e.NonceDetails, vk, e.Id, false)
+		require.NoError(t, err, "entry %s", e.Id)
+	}
+	_, err = cryptox.UnwrapKey(fc.ServerFiles[0].EncryptedFileKey, mk)
+	require.Error(t, err)
+	_, err = cryptox.UnwrapKey(fc.ServerFiles[0].EncryptedFileKey, vk)
+	require.NoError(t, err)
+
+	// nor does it unlock the vault key, online or offline
+	_, err = svc.OfflineLogin(ctx, "user", []byte("old"))
+	require.ErrorIs(t, err, client.ErrUnauthorized)
+	got, err := svc.OfflineLogin(ctx, "user", []byte("new"))
+	require.NoError(t, err)
+	require.Equal(t, vk, got)
+	_, err = svc.OnlineLogin(ctx, "user", []byte("old"), nil)
+	require.ErrorIs(t, err, client.ErrUnauthorized)
+	got, err = svc.OnlineLogin(ctx, "user", []byte("new"), nil)
+	require.NoError(t, err)
+	require.Equal(t, vk, got)
+}
+
+func TestChangePassword_UsesKDFPolicy(t *testing.T) {
+	db := setupDB(t)
+	fc, vk := newAccount(t, "user", "old", []byte("salt"))
+	svc := NewAuthService(fc, db)
+
+	_, err := svc.OnlineLogin(context.Background(), "user", []byte("old"), nil)
+	require.NoError(t, err)
+
+	// a policy raised since the login applies to the new password
+	policy := cryptox.DefaultKDFParams
+	fc.KDFUpgrade = &policy
+	_, err = svc.ChangePassword(context.Background(), []byte("old"), []byte("new"))
+	require.NoError(t, err)
+	require.Equal(t, cryptox.DefaultKDFParams, fc.KDF)
+
+	got, err := svc.OfflineLogin(context.Background(), "user", []byte("new"))
+	require.NoError(t, err)
+	require.Equal(t, vk, got)
+}
+
+func TestOnlineLogin_UpgradesWeakKDF(t *testing.T) {
+	db := setupDB(t)
+	fc, vk := newAccount(t, "user", "pass", []byte("salt"))
+	policy := cryptox.DefaultKDFParams
+	fc.KDFUpgrade = &policy
+	fc.UpgradeKDFErr = client.ErrUnavailable
+	svc := NewAuthService(fc, db)
+
+	// a failed upgrade does not fail the login and is retried next time
+	got, err := svc.OnlineLogin(context.Background(), "user", []byte("pass"), nil)
+	require.NoError(t, err)
+	require.Equal(t, vk, got)
+	require.Equal(t, 1, fc.UpgradeKDFCalls)
+	require.Equal(t, []byte("salt"), getMeta(t, db, "salt"))
+
+	fc.UpgradeKDFErr = nil
+	got, err = svc.OnlineLogin(context.Background(), "user", []byte("pass"), nil)
+	require.NoError(t, err)
+	require.Equal(t, vk, got)
+	require.Equal(t, 2, fc.UpgradeKDFCalls)
+	require.Equal(t, cryptox.DefaultKDFParams, fc.KDF)
+	require.NotEqual(t, []byte("salt"), fc.GetSaltRet, "a fresh salt must be used")
+	require.Equal(t, fc.GetSaltRet, getMeta(t, db, "salt"))
+	require.JSONEq(t, `{"algorithm":"argon2id","time":3,"memory":65536,"threads":4}`, string(getMeta(t, db, "kdf")))
+
+	// the vault key opens with the upgraded parameters, online and offline
+	got, err = svc.OfflineLogin(context.Background(), "user", []byte("pass"))
+	require.NoError(t, err)
+	require.Equal(t, vk, got)
+	got, err = svc.OnlineLogin(context.Background(), "user", []byte("pass"), nil)
+	require.NoError(t, err)
+	require.Equal(t, vk, got)
+	require.Equal(t, 2, fc.UpgradeKDFCalls, "upgraded accounts are not upgraded again")
+}
+
+func TestOfflineLogin_BadCachedKDF(t *testing.T) {
+	db := setupDB(t)
+	insertMeta(t, db, "username", []byte("user"))
+	insertMeta(t, db, "salt", []byte("salt"))
+	insertMeta(t, db, "verifier", []byte("v"))
+	insertMeta(t, db, "kdf", []byte(`{"algorithm":"scrypt","time":1,"memory":1024,"threads":1}`))
+	svc := NewAuthService(&fakeClient{}, db)
+
+	_, err := svc.OfflineLogin(context.Background(), "user", []byte("pass"))
+	require.ErrorIs(t, err, client.ErrLocalDataNotAvailable)
+}
+
+func TestChangePassword_WrongPasswordOrNoLocalData(t *testing.T) {
+	db := setupDB(t)
+	fc, _ := newAccount(t, "user", "old", []byte("salt"))
+	svc := NewAuthService(fc, db)
+
+	_, err := svc.ChangePassword(context.Background(), []byte("old"), []byte("new"))
+	require.ErrorIs(t, err, client.ErrLocalDataNotAvailable)
+
+	_, err = svc.OnlineLogin(context.Background(), "user", []byte("old"), nil)
+	require.NoError(t, err)
+	verifier := fc.SRPVerifier
+
+	_, err = svc.ChangePassword(context.Background(), []byte("wrong"), []byte("new"))
+	require.ErrorIs(t, err, client.ErrUnauthorized)
+	require.Equal(t, verifier, fc.SRPVerifier, "credentials must not change")
+
+	// a server failure leaves the cached credentials alone
+	fc.ChangePasswordErr = client.ErrUnavailable
+	_, err = svc.ChangePassword(context.Background(), []byte("old"), []byte("new"))
+	require.ErrorIs(t, err, client.ErrUnavailable)
+	_, err = svc.OfflineLogin(context.Background(), "user", []byte("old"))
+	require.NoError(t, err)
 }
 
 func TestRegister_DelegatesToClient(t *testing.T) {
@@ -223,8 +964,15 @@ func TestRegister_DelegatesToClient(t *testing.T) {
 	require.NoError(t, err)
 
 	require.Equal(t, "u", fc.LastRegisterUser)
+	require.Equal(t, "u", fc.LastGetSaltUser, "the KDF policy comes from GetSalt")
+	require.Equal(t, cryptox.LegacyKDFParams, fc.LastRegisterKDF)
 	require.NotEmpty(t, fc.LastRegisterSalt)
-	require.NotEmpty(t, fc.LastRegisterKey)
+	key := deriveMasterKey(t, []byte("p"), fc.LastRegisterSalt, cryptox.LegacyKDFParams)
+	require.Equal(t, cryptox.SRPVerifier("u", fc.LastRegisterSalt, cryptox.DeriveAuthKey(key)), fc.LastRegisterKey)
+	vk, err := cryptox.UnwrapKey(fc.LastRegisterVK, cryptox.DeriveKEK(key))
+	require.NoError(t, err)
+	require.Len(t, vk, 32)
+	require.NotEqual(t, key, vk, "new accounts get a random vault key")
 }
 
 func TestPing_Close_ClearOfflineData_Delegations(t *testing.T) {
@@ -244,6 +992,49 @@ func TestPing_Close_ClearOfflineData_Delegations(t *testing.T) {
 	require.Equal(t, 0, n)
 }
 
+func TestSessions_Delegations(t *testing.T) {
+	db := setupDB(t)
+	fc := &fakeClient{Sessions: []*models.Session{{ID: "s1", Current: true}}, RevokedOthers: 2}
+	svc := NewAuthService(fc, db)
+
+	sessions, err := svc.ListSessions(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, fc.Sessions, sessions)
+
+	require.NoError(t, svc.RevokeSession(context.Background(), "s2"))
+	require.Equal(t, "s2", fc.RevokedSession)
+
+	n, err := svc.RevokeOtherSessions(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+
+	require.NoError(t, svc.Logout(context.Background()))
+	require.True(t, fc.LoggedOut)
+
+	fc.SessionErr = client.ErrNotFound
+	require.ErrorIs(t, svc.RevokeSession(context.Background(), "s3"), client.ErrNotFound)
+}
+
+func TestTwoFactor_Delegations(t *testing.T) {
+	db := setupDB(t)
+	fc := &fakeClient{}
+	svc := NewAuthService(fc, db)
+
+	secret, uri, err := svc.Enable2FA(context.Background(), "654321")
+	require.NoError(t, err)
+	require.Equal(t, "SECRET", secret)
+	require.Equal(t, "otpauth://totp/x", uri)
+	require.Equal(t, "654321", fc.Enable2FACode)
+
+	codes, err := svc.Confirm2FA(context.Background(), "123456")
+	require.NoError(t, err)
+	require.Equal(t, []string{"AAAA-BBBB"}, codes)
+
+	fc.Confirm2FAErr = client.ErrInvalidCode
+	_, err = svc.Confirm2FA(context.Background(), "000000")
+	require.ErrorIs(t, err, client.ErrInvalidCode)
+}
+
 func TestRegister_ErrorFromClient(t *testing.T) {
 	db := setupDB(t)
 	fc := &fakeClient{RegisterErr: errors.New("dup")}
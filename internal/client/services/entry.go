@@ -7,14 +7,19 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/client/client"
 	"github.com/dmitrijs2005/gophkeeper/internal/client/models"
+	"github.com/dmitrijs2005/gophkeeper/internal/client/repositories/conflicts"
 	"github.com/dmitrijs2005/gophkeeper/internal/client/repositories/entries"
 	"github.com/dmitrijs2005/gophkeeper/internal/client/repositories/files"
 	"github.com/dmitrijs2005/gophkeeper/internal/client/repositories/metadata"
@@ -30,23 +35,61 @@ type EntryService interface {
 	// Sync performs a bidirectional synchronization with the backend.
 	Sync(ctx context.Context) error
 
-	// List returns decrypted overviews for display using the provided master key.
-	List(ctx context.Context, masterKey []byte) ([]models.ViewOverview, error)
+	// List returns decrypted overviews for display using the provided vault key.
+	List(ctx context.Context, vaultKey []byte) ([]models.ViewOverview, error)
 
 	// Add encrypts and stores an envelope (and optional staged file) locally.
-	Add(ctx context.Context, envelope models.Envelope, file *models.File, masterKey []byte) error
+	Add(ctx context.Context, envelope models.Envelope, file *models.File, vaultKey []byte) error
+
+	// Update re-encrypts an edited envelope under an existing entry id and
+	// optionally replaces its attached file.
+	Update(ctx context.Context, id string, envelope models.Envelope, file *models.File, vaultKey []byte) error
 
 	// DeleteByID marks an entry as deleted (implementation-defined).
 	DeleteByID(ctx context.Context, id string) error
 
+	// ListTrash returns decrypted overviews of deleted entries.
+	ListTrash(ctx context.Context, vaultKey []byte) ([]models.ViewOverview, error)
+
+	// Undelete brings a deleted entry (and its file) back from the trash.
+	Undelete(ctx context.Context, id string) error
+
+	// Purge permanently removes a deleted entry from the local store.
+	Purge(ctx context.Context, id string) error
+
 	// Get returns and decrypts a single entry envelope by id.
-	Get(ctx context.Context, id string, masterKey []byte) (*models.Envelope, error)
+	Get(ctx context.Context, id string, vaultKey []byte) (*models.Envelope, error)
 
 	// GetPresignedGetUrl requests a presigned URL for downloading a file.
 	GetPresignedGetUrl(ctx context.Context, id string) (string, error)
 
 	// GetFile loads file metadata for an entry.
 	GetFile(ctx context.Context, id string) (*models.File, error)
+
+	// History returns the decrypted server-side revisions of an entry, newest
+	// first.
+	History(ctx context.Context, id string, vaultKey []byte) ([]models.ViewRevision, error)
+
+	// Restore queues an old revision of an entry as a new pending write.
+	Restore(ctx context.Context, id string, version int64, vaultKey []byte) error
+
+	// ListConflicts returns the decrypted local and server copies of every
+	// entry with an unresolved sync conflict.
+	ListConflicts(ctx context.Context, vaultKey []byte) ([]models.Conflict, error)
+
+	// MigrateFileKeys wraps file keys still stored in plaintext with the
+	// vault key, locally and on the server, and returns how many it wrapped.
+	MigrateFileKeys(ctx context.Context, vaultKey []byte) (int, error)
+
+	// ResealEntries re-encrypts entries whose ciphertexts are not yet bound
+	// to their entry id, queues them for sync and returns how many it
+	// re-sealed.
+	ResealEntries(ctx context.Context, vaultKey []byte) (int, error)
+
+	// ResolveConflict replaces the local copy of a conflicting entry with
+	// envelope (or a tombstone if deleted is set) and queues it for sync on
+	// top of the server copy.
+	ResolveConflict(ctx context.Context, id string, envelope models.Envelope, deleted bool, vaultKey []byte) error
 }
 
 // entryService is the concrete EntryService backed by repositories and a Client.
@@ -69,27 +112,25 @@ func (s *entryService) getEntryRepo(db dbx.DBTX) entries.Repository {
 func (s *entryService) getFileRepo(db dbx.DBTX) files.Repository {
 	return files.NewSQLiteRepository(db)
 }
+func (s *entryService) getConflictRepo(db dbx.DBTX) conflicts.Repository {
+	return conflicts.NewSQLiteRepository(db)
+}
 
-// Add encrypts the envelope overview and details with masterKey, creates a new
-// local Entry (with a generated id), and optionally stores file metadata as a
-// pending upload in the same transaction.
-func (s *entryService) Add(ctx context.Context, envelope models.Envelope, file *models.File, masterKey []byte) error {
-	overview := envelope.Overview()
-	oCipherText, oNonce, err := cryptox.EncryptEntry(overview, masterKey)
-	if err != nil {
-		return fmt.Errorf("encryption error1: %w", err)
+// Add encrypts the envelope overview and details with vaultKey, creates a new
+// local Entry, and optionally stores file metadata as a pending upload in the
+// same transaction. The entry takes the id the file was materialized for, or
+// a generated one if there is no file.
+func (s *entryService) Add(ctx context.Context, envelope models.Envelope, file *models.File, vaultKey []byte) error {
+	id := uuid.NewString()
+	if file != nil && file.EntryID != "" {
+		id = file.EntryID
 	}
-	cipherText, nonce, err := cryptox.EncryptEntry(envelope, masterKey)
+	e, err := sealEntry(id, envelope, vaultKey)
 	if err != nil {
-		return fmt.Errorf("encryption error2: %w", err)
+		return err
 	}
-
-	e := &models.Entry{
-		Id:            uuid.NewString(),
-		Overview:      oCipherText,
-		NonceOverview: oNonce,
-		Details:       cipherText,
-		NonceDetails:  nonce,
+	if err := wrapFileKey(file, vaultKey); err != nil {
+		return err
 	}
 
 	err = dbx.WithTx(ctx, s.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
@@ -114,18 +155,108 @@ func (s *entryService) Add(ctx context.Context, envelope models.Envelope, file *
 	return nil
 }
 
+// sealEntry encrypts the overview and details of envelope with vaultKey,
+// each bound to the entry id and field (see cryptox.EntryAAD), and returns
+// them as an Entry with that id.
+func sealEntry(id string, envelope models.Envelope, vaultKey []byte) (*models.Entry, error) {
+	oCipherText, oNonce, err := cryptox.EncryptEntry(envelope.Overview(), vaultKey, cryptox.EntryAAD(id, cryptox.FieldOverview))
+	if err != nil {
+		return nil, fmt.Errorf("encryption error1: %w", err)
+	}
+	cipherText, nonce, err := cryptox.EncryptEntry(envelope, vaultKey, cryptox.EntryAAD(id, cryptox.FieldDetails))
+	if err != nil {
+		return nil, fmt.Errorf("encryption error2: %w", err)
+	}
+	return &models.Entry{
+		Id:            id,
+		Overview:      oCipherText,
+		NonceOverview: oNonce,
+		Details:       cipherText,
+		NonceDetails:  nonce,
+	}, nil
+}
+
+// wrapFileKey replaces the raw per-file key of a staged file with its wrapped
+// form, so that only the vault key holder can decrypt the file. A nil file
+// is left alone.
+func wrapFileKey(file *models.File, vaultKey []byte) error {
+	if file == nil {
+		return nil
+	}
+	wrapped, err := cryptox.WrapKey(file.EncryptedFileKey, vaultKey)
+	if err != nil {
+		return fmt.Errorf("error wrapping file key: %w", err)
+	}
+	file.EncryptedFileKey = wrapped
+	return nil
+}
+
+// Update encrypts the edited envelope with vaultKey and stores it under the
+// existing entry id as a pending local change; the entry keeps its base
+// version. A non-nil file replaces the entry's attachment and is staged as a
+// pending upload in the same transaction. Deleted entries cannot be updated.
+func (s *entryService) Update(ctx context.Context, id string, envelope models.Envelope, file *models.File, vaultKey []byte) error {
+	e, err := sealEntry(id, envelope, vaultKey)
+	if err != nil {
+		return err
+	}
+	if err := wrapFileKey(file, vaultKey); err != nil {
+		return err
+	}
+
+	// staged ciphertext of a replaced attachment that was never uploaded
+	var stale string
+	err = dbx.WithTx(ctx, s.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+		entryRepo := s.getEntryRepo(tx)
+		if _, err := entryRepo.GetByID(ctx, id); err != nil {
+			return err
+		}
+		if err := entryRepo.CreateOrUpdate(ctx, e); err != nil {
+			return err
+		}
+		if file == nil {
+			return nil
+		}
+
+		fileRepo := s.getFileRepo(tx)
+		old, err := fileRepo.GetByEntryID(ctx, id)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+		case err != nil:
+			return err
+		case old.UploadStatus == "pending":
+			stale = old.LocalPath
+		}
+		file.EntryID = id
+		file.Deleted = false
+		file.UploadStatus = "pending"
+		return fileRepo.CreateOrUpdate(ctx, file)
+	})
+	if err != nil {
+		return fmt.Errorf("error updating entry: %w", err)
+	}
+	if stale != "" {
+		_ = os.Remove(stale)
+	}
+	return nil
+}
+
 // List enumerates non-deleted entries and decrypts their Overview structures.
-func (s *entryService) List(ctx context.Context, masterKey []byte) ([]models.ViewOverview, error) {
+func (s *entryService) List(ctx context.Context, vaultKey []byte) ([]models.ViewOverview, error) {
 	entryRepo := s.getEntryRepo(s.db)
 	rows, err := entryRepo.GetAll(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error: %w", err)
 	}
+	sealed, err := s.sealedVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	result := make([]models.ViewOverview, 0, len(rows))
 	for _, row := range rows {
 		var x models.Overview
-		if err := cryptox.DecryptEntry(row.Overview, row.NonceOverview, masterKey, &x); err != nil {
+		if _, err := cryptox.DecryptEntry(row.Overview, row.NonceOverview, vaultKey, cryptox.EntryAAD(row.Id, cryptox.FieldOverview), sealed == 0, &x); err != nil {
 			log.Printf("error decryption entry: %v", err)
 		}
 		result = append(result, models.ViewOverview{Id: row.Id, Type: string(x.Type), Title: x.Title})
@@ -133,30 +264,406 @@ func (s *entryService) List(ctx context.Context, masterKey []byte) ([]models.Vie
 	return result, nil
 }
 
-// DeleteByID soft-deletes an entry.
+// DeleteByID soft-deletes an entry and tombstones its file, if any. The
+// tombstone stays pending until it has been pushed to the server.
 func (s *entryService) DeleteByID(ctx context.Context, id string) error {
-	if err := s.getEntryRepo(s.db).DeleteByID(ctx, id); err != nil {
+	err := dbx.WithTx(ctx, s.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+		if err := s.getEntryRepo(tx).DeleteByID(ctx, id); err != nil {
+			return err
+		}
+		return s.deleteFile(ctx, s.getFileRepo(tx), id)
+	})
+	if err != nil {
 		return fmt.Errorf("error deleting entry: %w", err)
 	}
 	return nil
 }
 
-// Get fetches and decrypts a single entry envelope using masterKey.
-func (s *entryService) Get(ctx context.Context, id string, masterKey []byte) (*models.Envelope, error) {
+// deleteFile tombstones the file attached to entry id. Entries without a
+// file, or whose file is already deleted, are left alone.
+func (s *entryService) deleteFile(ctx context.Context, fileRepo files.Repository, id string) error {
+	f, err := fileRepo.GetByEntryID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if f.Deleted {
+		return nil
+	}
+	return fileRepo.DeleteByEntryID(ctx, id)
+}
+
+// ListTrash enumerates tombstones and decrypts their Overview structures.
+func (s *entryService) ListTrash(ctx context.Context, vaultKey []byte) ([]models.ViewOverview, error) {
+	rows, err := s.getEntryRepo(s.db).GetAllDeleted(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error: %w", err)
+	}
+	sealed, err := s.sealedVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.ViewOverview, 0, len(rows))
+	for _, row := range rows {
+		var x models.Overview
+		if _, err := cryptox.DecryptEntry(row.Overview, row.NonceOverview, vaultKey, cryptox.EntryAAD(row.Id, cryptox.FieldOverview), sealed == 0, &x); err != nil {
+			log.Printf("error decryption entry: %v", err)
+		}
+		result = append(result, models.ViewOverview{Id: row.Id, Type: string(x.Type), Title: x.Title})
+	}
+	return result, nil
+}
+
+// Undelete clears the tombstone of entry id and of its file, if any. The
+// entry becomes pending and is pushed as a live entry on the next Sync; the
+// server then revives the file for other devices.
+func (s *entryService) Undelete(ctx context.Context, id string) error {
+	err := dbx.WithTx(ctx, s.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+		if err := s.getEntryRepo(tx).Undelete(ctx, id); err != nil {
+			return err
+		}
+		return s.getFileRepo(tx).Undelete(ctx, id)
+	})
+	if err != nil {
+		return fmt.Errorf("error restoring entry: %w", err)
+	}
+	return nil
+}
+
+// Purge permanently removes a deleted entry and its file record from the
+// local store, along with any staged ciphertext. Only tombstones already
+// pushed to the server can be purged; the server drops its copy once the
+// retention period passes.
+func (s *entryService) Purge(ctx context.Context, id string) error {
+	var staged string
+	err := dbx.WithTx(ctx, s.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+		entryRepo := s.getEntryRepo(tx)
+		e, err := entryRepo.GetByIDIncludingDeleted(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !e.Deleted {
+			return fmt.Errorf("entry %s is not in the trash", id)
+		}
+		if e.Pending {
+			return fmt.Errorf("deletion of %s is not synced yet", id)
+		}
+		if staged, err = s.purgeEntry(ctx, tx, id); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error purging entry: %w", err)
+	}
+	if staged != "" {
+		_ = os.Remove(staged)
+	}
+	return nil
+}
+
+// purgeEntry hard-deletes a synchronized entry and its file record and
+// returns the path of the file's staged ciphertext, if any.
+func (s *entryService) purgeEntry(ctx context.Context, tx dbx.DBTX, id string) (string, error) {
+	fileRepo := s.getFileRepo(tx)
+	var staged string
+	f, err := fileRepo.GetByEntryID(ctx, id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return "", err
+	default:
+		staged = f.LocalPath
+	}
+	if err := fileRepo.Purge(ctx, id); err != nil {
+		return "", err
+	}
+	if err := s.getEntryRepo(tx).Purge(ctx, id); err != nil {
+		return "", err
+	}
+	return staged, nil
+}
+
+// Get fetches and decrypts a single entry envelope using vaultKey.
+func (s *entryService) Get(ctx context.Context, id string, vaultKey []byte) (*models.Envelope, error) {
 	entry, err := s.getEntryRepo(s.db).GetByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("error retrieving entry: %w", err)
 	}
-	var envelope *models.Envelope
-	if err := cryptox.DecryptEntry(entry.Details, entry.NonceDetails, masterKey, &envelope); err != nil {
+	sealed, err := s.sealedVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	envelope, _, err := openEnvelope(entry.Details, entry.NonceDetails, vaultKey, id, sealed == 0)
+	if err != nil {
 		return nil, fmt.Errorf("error decrypting entry: %w", err)
 	}
-	return envelope, nil
+	return &envelope, nil
+}
+
+// sealedVersionKey is the metadata key of the server version at which all
+// entries of the account were found bound to their ids (see ResealEntries).
+// From then on unbound ciphertexts are rejected, except for revisions up to
+// that version, which may predate the binding.
+const sealedVersionKey = "sealed_version"
+
+// sealedVersion returns the version stored under sealedVersionKey, or 0 while
+// the entries have not all been re-sealed.
+func (s *entryService) sealedVersion(ctx context.Context) (int64, error) {
+	return readVersion(ctx, s.getMetadataRepo(s.db), sealedVersionKey)
+}
+
+// readVersion returns the server version stored in the local metadata under
+// key, or 0 if there is none.
+func readVersion(ctx context.Context, metadataRepo metadata.Repository, key string) (int64, error) {
+	value, err := metadataRepo.Get(ctx, key)
+	if err != nil {
+		return 0, fmt.Errorf("error retrieving %s: %w", key, err)
+	}
+	sValue := string(bytes.TrimSpace(value))
+	if sValue == "" {
+		return 0, nil
+	}
+	version, err := strconv.ParseInt(sValue, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", key, err)
+	}
+	return version, nil
+}
+
+// openEnvelope decrypts the details of entry id, accepting an unbound
+// ciphertext only if allowLegacy is set. The attachment of an unbound entry
+// predates the binding too, so it is marked as such (see models.BinaryFile).
+func openEnvelope(details, nonce, vaultKey []byte, id string, allowLegacy bool) (envelope models.Envelope, legacy bool, err error) {
+	legacy, err = cryptox.DecryptEntry(details, nonce, vaultKey, cryptox.EntryAAD(id, cryptox.FieldDetails), allowLegacy, &envelope)
+	if err != nil || !legacy || envelope.Type != models.EntryTypeBinaryFile {
+		return envelope, legacy, err
+	}
+	var file models.BinaryFile
+	if err := json.Unmarshal(envelope.Details, &file); err != nil {
+		return envelope, legacy, err
+	}
+	file.Unbound = true
+	envelope.Details, err = json.Marshal(file)
+	return envelope, legacy, err
+}
+
+// Staged files larger than one part are uploaded to storage in parts, so that
+// a network error only repeats the parts that did not make it and an upload
+// interrupted by a crash or restart resumes where it stopped. The part size
+// depends only on the file size, so a resumed upload splits the file the same
+// way.
+var multipartPartSize int64 = 8 << 20 // S3 requires at least 5 MiB
+
+const (
+	// maxUploadParts is the most parts S3 accepts in one upload.
+	maxUploadParts = 10000
+	// partPresignBatch is how many part URLs are requested at once. Presigned
+	// URLs expire, so they are fetched shortly before use.
+	partPresignBatch = 32
+	// partUploadConcurrency bounds the parallel part uploads of one file.
+	partUploadConcurrency = 4
+	// uploadAttempts is how often an upload is tried within one sync.
+	uploadAttempts = 3
+)
+
+// uploadRetryDelay is the pause before the first retry of a failed upload; it
+// doubles with every further retry.
+var uploadRetryDelay = time.Second
+
+// partSize returns the part size of a multipart upload of size bytes.
+func partSize(size int64) int64 {
+	return max(multipartPartSize, (size+maxUploadParts-1)/maxUploadParts)
+}
+
+// uploadFile streams the staged ciphertext of file to storage: with a single
+// PUT to url if it fits into one part, in parts otherwise.
+func (s *entryService) uploadFile(ctx context.Context, fileRepo files.Repository, url string, file *models.File) error {
+	f, err := os.Open(file.LocalPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if st.Size() <= multipartPartSize {
+		return netx.UploadToS3PresignedURL(url, f, st.Size())
+	}
+	return s.uploadParts(ctx, fileRepo, file, f, st.Size())
+}
+
+// uploadParts uploads the size bytes of src as a multipart upload of file.
+// Every finished part is recorded in the local files table, and parts
+// recorded for the upload the server still has in progress are skipped.
+func (s *entryService) uploadParts(ctx context.Context, fileRepo files.Repository, file *models.File, src io.ReaderAt, size int64) error {
+	id := file.EntryID
+	ps := partSize(size)
+	count := int32((size + ps - 1) / ps)
+
+	uploadID, err := s.client.StartMultipartUpload(ctx, id)
+	if err != nil {
+		return s.forgetUpload(ctx, fileRepo, id, err)
+	}
+	done := make(map[int32]string, count)
+	if uploadID == file.UploadID {
+		for _, p := range file.UploadedParts {
+			done[p.PartNumber] = p.ETag
+		}
+	} else if err := fileRepo.SetUploadID(ctx, id, uploadID); err != nil {
+		return err
+	}
+
+	var missing []int32
+	for n := int32(1); n <= count; n++ {
+		if _, ok := done[n]; !ok {
+			missing = append(missing, n)
+		}
+	}
+
+	var mu sync.Mutex
+	for len(missing) > 0 {
+		batch := missing[:min(partPresignBatch, len(missing))]
+		missing = missing[len(batch):]
+
+		urls, err := s.client.PresignUploadParts(ctx, id, uploadID, batch)
+		if err != nil {
+			return s.forgetUpload(ctx, fileRepo, id, err)
+		}
+
+		// finished parts are recorded even if a sibling fails
+		var grp errgroup.Group
+		grp.SetLimit(partUploadConcurrency)
+		for i, n := range batch {
+			grp.Go(func() error {
+				off := int64(n-1) * ps
+				n64 := min(ps, size-off)
+				etag, err := netx.UploadPartToS3PresignedURL(urls[i], io.NewSectionReader(src, off, n64), n64)
+				if err != nil {
+					return fmt.Errorf("part %d: %w", n, err)
+				}
+				if err := fileRepo.AddUploadedPart(ctx, id, models.UploadedPart{PartNumber: n, ETag: etag}); err != nil {
+					return err
+				}
+				mu.Lock()
+				done[n] = etag
+				mu.Unlock()
+				return nil
+			})
+		}
+		if err := grp.Wait(); err != nil {
+			return err
+		}
+	}
+
+	parts := make([]models.UploadedPart, 0, count)
+	for n := int32(1); n <= count; n++ {
+		parts = append(parts, models.UploadedPart{PartNumber: n, ETag: done[n]})
+	}
+	if err := s.client.CompleteMultipartUpload(ctx, id, uploadID, parts); err != nil {
+		return s.forgetUpload(ctx, fileRepo, id, err)
+	}
+	return nil
+}
+
+// forgetUpload returns err, first dropping the local state of the multipart
+// upload of entry id if the server no longer has it in progress, so that the
+// next sync starts the upload over.
+func (s *entryService) forgetUpload(ctx context.Context, fileRepo files.Repository, id string, err error) error {
+	if errors.Is(err, client.ErrNotFound) {
+		if cerr := fileRepo.SetUploadID(ctx, id, ""); cerr != nil {
+			return errors.Join(err, cerr)
+		}
+	}
+	return err
+}
+
+// uploadWithRetry uploads file starting with url and retries a failed upload
+// with exponential backoff. A retry asks the server for a fresh URL, since
+// the previous one may have expired, and reloads the file, whose multipart
+// upload state the failed attempt may have advanced.
+func (s *entryService) uploadWithRetry(ctx context.Context, fileRepo files.Repository, url string, file *models.File) error {
+	delay := uploadRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := s.uploadFile(ctx, fileRepo, url, file)
+		if err == nil || attempt == uploadAttempts || !retryableUpload(err) {
+			return err
+		}
+		log.Printf("upload of %s failed, retrying in %v: %v", file.EntryID, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(delay):
+		}
+		delay *= 2
+
+		if url, err = s.client.RequestUploadURL(ctx, file.EntryID); err != nil {
+			return err
+		}
+		if file, err = fileRepo.GetByEntryID(ctx, file.EntryID); err != nil {
+			return err
+		}
+	}
+}
+
+// retryableUpload reports whether a failed upload may succeed when tried
+// again: storage and network errors are retried, while a missing staged file,
+// a rejected session, a file the server no longer expects or a cancelled
+// sync are not.
+func retryableUpload(err error) bool {
+	return !errors.Is(err, os.ErrNotExist) &&
+		!errors.Is(err, client.ErrUnauthorized) &&
+		!errors.Is(err, client.ErrNotFound) &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded)
+}
+
+// uploadTasksForRegistered returns upload tasks, with fresh URLs, for the
+// files that earlier syncs registered with the server but that were never
+// uploaded, e.g. because the client went offline, crashed or failed the
+// upload, or its URL expired. Files that already have a task in tasks or
+// whose entry is held back by a conflict are skipped. A file the server no
+// longer expects is pushed again by the next sync.
+func (s *entryService) uploadTasksForRegistered(ctx context.Context, fileRepo files.Repository, tasks []*models.FileUploadTask, held map[string]bool) ([]*models.FileUploadTask, error) {
+	ids, err := fileRepo.GetAllAwaitingUpload(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving files: %w", err)
+	}
+	scheduled := make(map[string]bool, len(tasks))
+	for _, t := range tasks {
+		scheduled[t.EntryID] = true
+	}
+	for _, id := range ids {
+		if scheduled[id] || held[id] {
+			continue
+		}
+		url, err := s.client.RequestUploadURL(ctx, id)
+		if errors.Is(err, client.ErrNotFound) {
+			if err := fileRepo.SetRegistered(ctx, id, false); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, &models.FileUploadTask{EntryID: id, URL: url})
+	}
+	return tasks, nil
 }
 
 // uploadPendingFiles uploads staged ciphertexts to the server using presigned
 // URLs, marks them uploaded both locally and remotely, and removes temp files.
-// Uploads are done concurrently with a small semaphore for backpressure.
+// Uploads are done concurrently with a small semaphore for backpressure, and
+// every file is streamed from disk. Large files are uploaded in resumable
+// parts (see uploadFile), and failed uploads are retried (see
+// uploadWithRetry). A file the server no longer expects is pushed again by
+// the next sync.
 func (s *entryService) uploadPendingFiles(ctx context.Context, uploadTasks []*models.FileUploadTask) error {
 	fileRepo := s.getFileRepo(s.db)
 	grp, ctx := errgroup.WithContext(ctx)
@@ -180,11 +687,12 @@ func (s *entryService) uploadPendingFiles(ctx context.Context, uploadTasks []*mo
 				return err
 			}
 
-			data, err := os.ReadFile(file.LocalPath)
-			if err != nil {
-				return err
-			}
-			if err := netx.UploadToS3PresignedURL(task.URL, data); err != nil {
+			if err := s.uploadWithRetry(ctx, fileRepo, task.URL, file); err != nil {
+				if errors.Is(err, client.ErrNotFound) {
+					if rerr := fileRepo.SetRegistered(ctx, task.EntryID, false); rerr != nil {
+						return errors.Join(err, rerr)
+					}
+				}
 				return err
 			}
 			if err := fileRepo.MarkUploaded(ctx, task.EntryID); err != nil {
@@ -207,81 +715,471 @@ func (s *entryService) uploadPendingFiles(ctx context.Context, uploadTasks []*mo
 // Sync reconciles local pending changes with the server, uploads any staged
 // files when requested, and applies server changes in a transaction.
 //
+// Conflicts are never resolved here. When the server rejects a push as stale,
+// or a newer server copy arrives for an entry with unsynchronized changes,
+// the local copy stays pending and the server copy is recorded in the
+// conflicts table. Such entries (and their files) are held back from later
+// pushes until the user resolves the conflict.
+//
+// If the server has purged deletions this device never pulled, it demands a
+// full resync: the pending changes are pushed again against version 0 and
+// every synchronized local entry the server no longer returns is purged.
+//
 // Flow:
 //  1. Read current_version from metadata (defaults to 0).
-//  2. Collect pending entries/files.
-//  3. Call client.Sync(entries, files, currentVersion).
-//  4. Upload files for any returned upload tasks.
-//  5. In a TX, persist processed/new entries and files, and update current_version.
+//  2. Collect pending entries/files, skipping unresolved conflicts.
+//  3. Call client.Sync(entries, files, currentVersion), retrying from version
+//     0 if a full resync is required.
+//  4. In a TX, drop entries missing after a full resync, apply server changes (never pending), record conflicts, stamp
+//     server versions on processed entries and clear their pending flag unless
+//     they were edited meanwhile, store new files, and update current_version.
+//  5. Upload files for any returned upload tasks, and for files registered
+//     by earlier syncs that are still waiting for their upload.
+//
+// A file accepted by the server is marked registered and is not pushed again;
+// later syncs ask for a fresh upload URL instead (see
+// uploadTasksForRegistered) until it is uploaded.
 func (s *entryService) Sync(ctx context.Context) error {
 	metadataRepo := s.getMetadataRepo(s.db)
 	entryRepo := s.getEntryRepo(s.db)
 	fileRepo := s.getFileRepo(s.db)
 
-	value, err := metadataRepo.Get(ctx, "current_version")
+	currentVersion, err := readVersion(ctx, metadataRepo, "current_version")
 	if err != nil {
-		return fmt.Errorf("error retrieving current version: %w", err)
+		return err
 	}
 
-	sValue := string(bytes.TrimSpace(value))
-	var currentVersion int64
-	if sValue == "" {
-		currentVersion = 0
-	} else {
-		currentVersion, err = strconv.ParseInt(sValue, 10, 64)
-		if err != nil {
-			return fmt.Errorf("parse current_version: %w", err)
-		}
+	unresolved, err := s.getConflictRepo(s.db).GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("error retrieving conflicts: %w", err)
+	}
+	held := make(map[string]bool, len(unresolved))
+	for _, c := range unresolved {
+		held[c.Id] = true
 	}
 
-	entries, err := entryRepo.GetAllPending(ctx)
+	pending, err := entryRepo.GetAllPending(ctx)
 	if err != nil {
 		return fmt.Errorf("error retrieving entries: %w", err)
 	}
-	files, err := fileRepo.GetAllPendingUpload(ctx)
+	pendingFiles, err := fileRepo.GetAllPendingUpload(ctx)
 	if err != nil {
 		return fmt.Errorf("error retrieving files: %w", err)
 	}
 
-	processedEntries, newEntries, newFiles, uploadTasks, max_version, err := s.client.Sync(ctx, entries, files, currentVersion)
+	var entries []*models.Entry
+	for _, e := range pending {
+		if !held[e.Id] {
+			entries = append(entries, e)
+		}
+	}
+	var files []*models.File
+	for _, f := range pendingFiles {
+		if !held[f.EntryID] {
+			files = append(files, f)
+		}
+	}
+
+	// Remember which local revision was pushed so that entries edited while
+	// the sync is in flight stay pending, and likewise which file content.
+	revisions := make(map[string]int64, len(entries))
+	for _, e := range entries {
+		revisions[e.Id] = e.LocalRevision
+	}
+	nonces := make(map[string][]byte, len(files))
+	for _, f := range files {
+		nonces[f.EntryID] = f.Nonce
+	}
+
+	processedEntries, conflicted, newEntries, newFiles, uploadTasks, max_version, err := s.client.Sync(ctx, entries, files, currentVersion)
+	fullResync := errors.Is(err, client.ErrResyncRequired)
+	if fullResync {
+		currentVersion = 0
+		processedEntries, conflicted, newEntries, newFiles, uploadTasks, max_version, err = s.client.Sync(ctx, entries, files, currentVersion)
+	}
 	if err != nil {
 		return fmt.Errorf("error client sync: %w", err)
 	}
 
-	if err := s.uploadPendingFiles(ctx, uploadTasks); err != nil {
-		return fmt.Errorf("error uploading files: %w", err)
-	}
+	// staged ciphertexts of entries dropped by a full resync
+	var staged []string
+	// upload tasks for files that are still the pushed ones
+	var tasks []*models.FileUploadTask
 
 	if err := dbx.WithTx(ctx, s.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
 		metadataRepoTx := s.getMetadataRepo(tx)
 		entryRepoTx := s.getEntryRepo(tx)
 		fileRepoTx := s.getFileRepo(tx)
+		conflictRepoTx := s.getConflictRepo(tx)
 
+		if max_version < currentVersion {
+			max_version = currentVersion
+		}
 		if err := metadataRepoTx.Set(ctx, "current_version", fmt.Appendf(nil, "%v", max_version)); err != nil {
 			return err
 		}
-		for _, e := range processedEntries {
-			if err := entryRepoTx.CreateOrUpdate(ctx, e); err != nil {
+		if fullResync {
+			onServer := make(map[string]bool, len(newEntries))
+			for _, e := range newEntries {
+				onServer[e.Id] = true
+			}
+			synced, err := entryRepoTx.GetSyncedIDs(ctx)
+			if err != nil {
+				return err
+			}
+			for _, id := range synced {
+				if onServer[id] {
+					continue
+				}
+				path, err := s.purgeEntry(ctx, tx, id)
+				if err != nil {
+					return err
+				}
+				if path != "" {
+					staged = append(staged, path)
+				}
+			}
+		}
+		for _, e := range conflicted {
+			if err := conflictRepoTx.Save(ctx, e); err != nil {
 				return err
 			}
 		}
 		for _, e := range newEntries {
-			if err := entryRepoTx.CreateOrUpdate(ctx, e); err != nil {
+			conflict, err := entryRepoTx.ApplyRemote(ctx, e)
+			if err != nil {
+				return err
+			}
+			if conflict {
+				if err := conflictRepoTx.Save(ctx, e); err != nil {
+					return err
+				}
+				continue
+			}
+			if e.Deleted {
+				if err := s.deleteFile(ctx, fileRepoTx, e.Id); err != nil {
+					return err
+				}
+			}
+		}
+		for _, e := range processedEntries {
+			rev, ok := revisions[e.Id]
+			if !ok {
+				continue
+			}
+			if err := entryRepoTx.MarkSynced(ctx, e.Id, e.Version, rev); err != nil {
 				return err
 			}
 		}
 		for _, f := range newFiles {
+			f.Registered = true
 			if err := fileRepoTx.CreateOrUpdate(ctx, f); err != nil {
 				return err
 			}
 		}
+		for _, t := range uploadTasks {
+			f, err := fileRepoTx.GetByEntryID(ctx, t.EntryID)
+			if err != nil {
+				return err
+			}
+			// a file replaced while the sync was in flight is pushed
+			// again next time
+			if !bytes.Equal(f.Nonce, nonces[t.EntryID]) {
+				continue
+			}
+			if err := fileRepoTx.SetRegistered(ctx, t.EntryID, true); err != nil {
+				return err
+			}
+			tasks = append(tasks, t)
+		}
 		return nil
 	}); err != nil {
 		return fmt.Errorf("error tx: %w", err)
 	}
+	for _, path := range staged {
+		_ = os.Remove(path)
+	}
+
+	tasks, err = s.uploadTasksForRegistered(ctx, fileRepo, tasks, held)
+	if err != nil {
+		return fmt.Errorf("error requesting upload urls: %w", err)
+	}
+	if err := s.uploadPendingFiles(ctx, tasks); err != nil {
+		return fmt.Errorf("error uploading files: %w", err)
+	}
 	return nil
 }
 
+// History fetches the revisions the server stored for entry id and decrypts
+// their envelopes using vaultKey. Revisions are returned newest first.
+func (s *entryService) History(ctx context.Context, id string, vaultKey []byte) ([]models.ViewRevision, error) {
+	revs, err := s.client.ListRevisions(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error listing revisions: %w", err)
+	}
+
+	sealed, err := s.sealedVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.ViewRevision, 0, len(revs))
+	for _, r := range revs {
+		v := models.ViewRevision{Version: r.Version, Deleted: r.Deleted, CreatedAt: r.UpdatedAt}
+		envelope, _, err := openEnvelope(r.Details, r.NonceDetails, vaultKey, id, sealed == 0 || r.Version <= sealed)
+		if err != nil {
+			return nil, fmt.Errorf("error decrypting revision %d: %w", r.Version, err)
+		}
+		v.Envelope = &envelope
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+// Restore fetches revision version of entry id from the server, re-encrypts
+// its envelope and stores it as a pending local write on top of the entry's
+// current base version, undeleting the entry and its file if needed. The next
+// Sync pushes it as a new version. Attached files are not versioned and keep
+// their current content.
+func (s *entryService) Restore(ctx context.Context, id string, version int64, vaultKey []byte) error {
+	rev, err := s.client.GetRevision(ctx, id, version)
+	if err != nil {
+		return fmt.Errorf("error getting revision: %w", err)
+	}
+	if rev.Deleted {
+		return fmt.Errorf("revision %d is a deletion", version)
+	}
+
+	sealed, err := s.sealedVersion(ctx)
+	if err != nil {
+		return err
+	}
+	envelope, _, err := openEnvelope(rev.Details, rev.NonceDetails, vaultKey, id, sealed == 0 || version <= sealed)
+	if err != nil {
+		return fmt.Errorf("error decrypting revision: %w", err)
+	}
+	e, err := sealEntry(id, envelope, vaultKey)
+	if err != nil {
+		return err
+	}
+	err = dbx.WithTx(ctx, s.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+		entryRepo := s.getEntryRepo(tx)
+		if _, err := entryRepo.GetByIDIncludingDeleted(ctx, id); err != nil {
+			return err
+		}
+		if err := entryRepo.CreateOrUpdate(ctx, e); err != nil {
+			return err
+		}
+		return s.getFileRepo(tx).Undelete(ctx, id)
+	})
+	if err != nil {
+		return fmt.Errorf("error restoring entry: %w", err)
+	}
+	return nil
+}
+
+// ListConflicts decrypts both copies of every unresolved conflict, oldest
+// first. Tombstones are included: a deleted copy still carries its last
+// envelope.
+func (s *entryService) ListConflicts(ctx context.Context, vaultKey []byte) ([]models.Conflict, error) {
+	remotes, err := s.getConflictRepo(s.db).GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving conflicts: %w", err)
+	}
+
+	sealed, err := s.sealedVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entryRepo := s.getEntryRepo(s.db)
+	result := make([]models.Conflict, 0, len(remotes))
+	for _, remote := range remotes {
+		local, err := entryRepo.GetByIDIncludingDeleted(ctx, remote.Id)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving entry %s: %w", remote.Id, err)
+		}
+
+		c := models.Conflict{
+			Id:            remote.Id,
+			LocalDeleted:  local.Deleted,
+			RemoteDeleted: remote.Deleted,
+			RemoteVersion: remote.Version,
+		}
+		localEnvelope, _, err := openEnvelope(local.Details, local.NonceDetails, vaultKey, remote.Id, sealed == 0)
+		if err != nil {
+			return nil, fmt.Errorf("error decrypting entry %s: %w", remote.Id, err)
+		}
+		remoteEnvelope, _, err := openEnvelope(remote.Details, remote.NonceDetails, vaultKey, remote.Id, sealed == 0)
+		if err != nil {
+			return nil, fmt.Errorf("error decrypting server copy of %s: %w", remote.Id, err)
+		}
+		c.Local, c.Remote = &localEnvelope, &remoteEnvelope
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+// ResolveConflict encrypts the chosen envelope and, in one transaction, stores
+// it as a pending local write based on the recorded server version, tombstones
+// the entry's file if the result is deleted, and drops the conflict so the
+// entry is pushed on the next Sync.
+func (s *entryService) ResolveConflict(ctx context.Context, id string, envelope models.Envelope, deleted bool, vaultKey []byte) error {
+	e, err := sealEntry(id, envelope, vaultKey)
+	if err != nil {
+		return err
+	}
+
+	err = dbx.WithTx(ctx, s.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+		conflictRepo := s.getConflictRepo(tx)
+		remote, err := conflictRepo.GetByEntryID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		e.Version = remote.Version
+		e.Deleted = deleted
+		if err := s.getEntryRepo(tx).Rebase(ctx, e); err != nil {
+			return err
+		}
+		if deleted {
+			if err := s.deleteFile(ctx, s.getFileRepo(tx), id); err != nil {
+				return err
+			}
+		}
+		return conflictRepo.DeleteByEntryID(ctx, id)
+	})
+	if err != nil {
+		return fmt.Errorf("error resolving conflict: %w", err)
+	}
+	return nil
+}
+
+// MigrateFileKeys wraps every file key that predates key wrapping with
+// vaultKey. Keys of uploaded files are replaced on the server first, so that
+// the plaintext key is not left behind there; files the server no longer has
+// are only updated locally. Files still pending upload need no server update,
+// since the wrapped key is pushed with them on the next sync.
+func (s *entryService) MigrateFileKeys(ctx context.Context, vaultKey []byte) (int, error) {
+	fileRepo := s.getFileRepo(s.db)
+	all, err := fileRepo.GetAll(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error listing files: %w", err)
+	}
+
+	migrated := 0
+	for _, f := range all {
+		if cryptox.IsWrappedKey(f.EncryptedFileKey) {
+			continue
+		}
+		wrapped, err := cryptox.WrapKey(f.EncryptedFileKey, vaultKey)
+		if err != nil {
+			return migrated, fmt.Errorf("error wrapping key of %s: %w", f.EntryID, err)
+		}
+		if f.UploadStatus == "completed" && !f.Deleted {
+			if err := s.client.UpdateFileKey(ctx, f.EntryID, wrapped); err != nil && !errors.Is(err, client.ErrNotFound) {
+				return migrated, fmt.Errorf("error updating key of %s: %w", f.EntryID, err)
+			}
+		}
+		if err := fileRepo.UpdateKey(ctx, f.EntryID, wrapped); err != nil {
+			return migrated, fmt.Errorf("error updating key of %s: %w", f.EntryID, err)
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+// ResealEntries re-encrypts every entry, tombstones included, whose overview
+// or details were sealed before ciphertexts were bound to their entry id, and
+// stores it as a pending local write so that the server copy is replaced on
+// the next Sync. Entries with an unresolved conflict are skipped, since
+// resolving them re-seals them anyway; entries that cannot be decrypted are
+// logged and skipped, as in List.
+//
+// Once a synced account has no such entries left, the current version is
+// recorded as its sealed version: from then on unbound ciphertexts are
+// rejected (see sealedVersionKey) and nothing is re-sealed any more, so that a
+// server cannot slip an unbound ciphertext in to have it re-sealed.
+func (s *entryService) ResealEntries(ctx context.Context, vaultKey []byte) (int, error) {
+	sealed, err := s.sealedVersion(ctx)
+	if err != nil || sealed > 0 {
+		return 0, err
+	}
+	unresolved, err := s.getConflictRepo(s.db).GetAll(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error retrieving conflicts: %w", err)
+	}
+	held := make(map[string]bool, len(unresolved))
+	for _, c := range unresolved {
+		held[c.Id] = true
+	}
+
+	resealed := 0
+	err = dbx.WithTx(ctx, s.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+		entryRepo := s.getEntryRepo(tx)
+		live, err := entryRepo.GetAll(ctx)
+		if err != nil {
+			return err
+		}
+		deleted, err := entryRepo.GetAllDeleted(ctx)
+		if err != nil {
+			return err
+		}
+		// whether every entry is known to be bound
+		complete := len(held) == 0
+		for _, row := range append(live, deleted...) {
+			if held[row.Id] {
+				continue
+			}
+			var overview models.Overview
+			oLegacy, err := cryptox.DecryptEntry(row.Overview, row.NonceOverview, vaultKey, cryptox.EntryAAD(row.Id, cryptox.FieldOverview), true, &overview)
+			if err != nil {
+				log.Printf("error decryption entry: %v", err)
+				complete = false
+				continue
+			}
+			stored, err := entryRepo.GetByIDIncludingDeleted(ctx, row.Id)
+			if err != nil {
+				return err
+			}
+			envelope, dLegacy, err := openEnvelope(stored.Details, stored.NonceDetails, vaultKey, row.Id, true)
+			if err != nil {
+				log.Printf("error decryption entry: %v", err)
+				complete = false
+				continue
+			}
+			if !oLegacy && !dLegacy {
+				continue
+			}
+
+			e, err := sealEntry(row.Id, envelope, vaultKey)
+			if err != nil {
+				return err
+			}
+			e.Deleted = stored.Deleted
+			if err := entryRepo.CreateOrUpdate(ctx, e); err != nil {
+				return err
+			}
+			resealed++
+		}
+		if !complete || resealed > 0 {
+			return nil
+		}
+		// The version of an account that has never synced says nothing
+		// about the entries still to be pulled.
+		metadataRepo := s.getMetadataRepo(tx)
+		current, err := readVersion(ctx, metadataRepo, "current_version")
+		if err != nil || current == 0 {
+			return err
+		}
+		return metadataRepo.Set(ctx, sealedVersionKey, fmt.Appendf(nil, "%v", current))
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error resealing entries: %w", err)
+	}
+	return resealed, nil
+}
+
 // GetPresignedGetUrl fetches a presigned GET URL for the entry's file.
 func (s *entryService) GetPresignedGetUrl(ctx context.Context, id string) (string, error) {
 	url, err := s.client.GetPresignedGetURL(ctx, id)
@@ -5,16 +5,20 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/client/client"
 	"github.com/dmitrijs2005/gophkeeper/internal/client/models"
+	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
 	"github.com/stretchr/testify/require"
 
 	_ "modernc.org/sqlite"
@@ -31,21 +35,38 @@ func setupDBEntry(t *testing.T) *sql.DB {
 	_, err = db.Exec(`
 CREATE TABLE IF NOT EXISTS entries (
   id TEXT PRIMARY KEY,
+  version BIGINT NOT NULL DEFAULT 0,
   overview BLOB NOT NULL,
   nonce_overview BLOB NOT NULL,
   details BLOB NOT NULL,
   nonce_details BLOB NOT NULL,
   deleted INTEGER NOT NULL DEFAULT 0,
-  pending INTEGER NOT NULL DEFAULT 0
+  pending INTEGER NOT NULL DEFAULT 0,
+  local_revision INTEGER NOT NULL DEFAULT 0
 );
 
 CREATE TABLE IF NOT EXISTS files (
   entry_id TEXT PRIMARY KEY,
   encrypted_file_key BLOB NOT NULL,
   nonce BLOB NOT NULL,
+  digest BLOB,
   local_path TEXT NOT NULL,
   upload_status TEXT NOT NULL,
-  deleted INTEGER NOT NULL DEFAULT 0
+  deleted INTEGER NOT NULL DEFAULT 0,
+  registered INTEGER NOT NULL DEFAULT 0,
+  upload_id TEXT,
+  upload_parts TEXT
+);
+
+CREATE TABLE IF NOT EXISTS conflicts (
+  entry_id TEXT PRIMARY KEY,
+  version BIGINT NOT NULL,
+  deleted INTEGER NOT NULL DEFAULT 0,
+  overview BLOB NOT NULL,
+  nonce_overview BLOB NOT NULL,
+  details BLOB NOT NULL,
+  nonce_details BLOB NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
 CREATE TABLE IF NOT EXISTS metadata (
@@ -62,20 +83,74 @@ type fakeClientEntry struct {
 
 	// presets
 	SyncProcessed   []*models.Entry
+	SyncConflicts   []*models.Entry
 	SyncNewEntries  []*models.Entry
 	SyncNewFiles    []*models.File
 	SyncUploadTasks []*models.FileUploadTask
 	SyncMaxVersion  int64
 	SyncErr         error
+	OnSync          func()
+	SyncPushed      []*models.Entry
+	SyncPushedFiles []*models.File
+
+	// SyncFirstErr, if set, fails the first Sync call only.
+	SyncFirstErr error
+	SyncFrom     []int64
 
 	GetURL string
 	URLerr error
 
 	MarkUploadedIDs []string
+
+	// fresh upload URLs
+	UploadURL     string
+	UploadURLErr  error
+	RequestedURLs []string
+
+	// multipart uploads
+	UploadID       string
+	StartedUploads int
+	PartURL        string
+	CompletedParts []models.UploadedPart
+	LostUpload     bool
 }
 
-func (f *fakeClientEntry) Sync(ctx context.Context, entries []*models.Entry, files []*models.File, maxVersion int64) ([]*models.Entry, []*models.Entry, []*models.File, []*models.FileUploadTask, int64, error) {
-	return f.SyncProcessed, f.SyncNewEntries, f.SyncNewFiles, f.SyncUploadTasks, f.SyncMaxVersion, f.SyncErr
+func (f *fakeClientEntry) StartMultipartUpload(ctx context.Context, entryID string) (string, error) {
+	f.StartedUploads++
+	return f.UploadID, nil
+}
+func (f *fakeClientEntry) PresignUploadParts(ctx context.Context, entryID string, uploadID string, partNumbers []int32) ([]string, error) {
+	if uploadID != f.UploadID || f.LostUpload {
+		return nil, client.ErrNotFound
+	}
+	var urls []string
+	for _, n := range partNumbers {
+		urls = append(urls, fmt.Sprintf("%s?partNumber=%d", f.PartURL, n))
+	}
+	return urls, nil
+}
+func (f *fakeClientEntry) RequestUploadURL(ctx context.Context, entryID string) (string, error) {
+	f.RequestedURLs = append(f.RequestedURLs, entryID)
+	return f.UploadURL, f.UploadURLErr
+}
+func (f *fakeClientEntry) CompleteMultipartUpload(ctx context.Context, entryID string, uploadID string, parts []models.UploadedPart) error {
+	f.CompletedParts = parts
+	return nil
+}
+
+func (f *fakeClientEntry) Sync(ctx context.Context, entries []*models.Entry, files []*models.File, maxVersion int64) ([]*models.Entry, []*models.Entry, []*models.Entry, []*models.File, []*models.FileUploadTask, int64, error) {
+	f.SyncPushed = entries
+	f.SyncPushedFiles = files
+	f.SyncFrom = append(f.SyncFrom, maxVersion)
+	if f.SyncFirstErr != nil {
+		err := f.SyncFirstErr
+		f.SyncFirstErr = nil
+		return nil, nil, nil, nil, nil, 0, err
+	}
+	if f.OnSync != nil {
+		f.OnSync()
+	}
+	return f.SyncProcessed, f.SyncConflicts, f.SyncNewEntries, f.SyncNewFiles, f.SyncUploadTasks, f.SyncMaxVersion, f.SyncErr
 }
 func (f *fakeClientEntry) GetPresignedGetURL(ctx context.Context, entryID string) (string, error) {
 	return f.GetURL, f.URLerr
@@ -84,11 +159,23 @@ func (f *fakeClientEntry) MarkUploaded(ctx context.Context, entryID string) erro
 	f.MarkUploadedIDs = append(f.MarkUploadedIDs, entryID)
 	return nil
 }
-func (f *fakeClientEntry) Ping(context.Context) error                                { return nil }
-func (f *fakeClientEntry) Close() error                                              { return nil }
-func (f *fakeClientEntry) Register(ctx context.Context, u string, s, k []byte) error { return nil }
-func (f *fakeClientEntry) GetSalt(ctx context.Context, u string) ([]byte, error)     { return nil, nil }
-func (f *fakeClientEntry) Login(ctx context.Context, u string, k []byte) error       { return nil }
+func (f *fakeClientEntry) Ping(context.Context) error { return nil }
+func (f *fakeClientEntry) Close() error               { return nil }
+func (f *fakeClientEntry) Register(ctx context.Context, u string, c models.Credentials) error {
+	return nil
+}
+func (f *fakeClientEntry) GetSalt(ctx context.Context, u string) (*models.SaltInfo, error) {
+	return &models.SaltInfo{}, nil
+}
+func (f *fakeClientEntry) Login(ctx context.Context, u string, k, v, w []byte) error { return nil }
+
+// fastRetries makes failed uploads retry without waiting.
+func fastRetries(t *testing.T) {
+	t.Helper()
+	orig := uploadRetryDelay
+	uploadRetryDelay = time.Millisecond
+	t.Cleanup(func() { uploadRetryDelay = orig })
+}
 
 func oneRow[T any](t *testing.T, db *sql.DB, q string, args ...any) T {
 	t.Helper()
@@ -131,7 +218,7 @@ func TestAdd_WithFile_InsertsFilePending(t *testing.T) {
 	require.NoError(t, err)
 
 	file := &models.File{
-		EncryptedFileKey: []byte("k"),
+		EncryptedFileKey: make([]byte, 32),
 		Nonce:            []byte("n"),
 		LocalPath:        "/tmp/pre-encrypted.bin",
 	}
@@ -146,6 +233,62 @@ func TestAdd_WithFile_InsertsFilePending(t *testing.T) {
 	require.NoError(t, db.QueryRow(`SELECT upload_status, deleted FROM files WHERE entry_id=?`, entryID).Scan(&status, &deleted))
 	require.Equal(t, "pending", status)
 	require.Equal(t, 0, deleted)
+
+	// the per-file key is stored wrapped with the master key
+	stored := oneRow[[]byte](t, db, `SELECT encrypted_file_key FROM files WHERE entry_id=?`, entryID)
+	require.True(t, cryptox.IsWrappedKey(stored))
+	fileKey, err := cryptox.UnwrapKey(stored, key)
+	require.NoError(t, err)
+	require.Equal(t, make([]byte, 32), fileKey)
+}
+
+func TestUpdate_KeepsIDAndBaseVersionAndReplacesFile(t *testing.T) {
+	db := setupDBEntry(t)
+	svc := NewEntryService(&fakeClient{}, db)
+	ctx := context.Background()
+	key := make([]byte, 32)
+
+	_, err := db.Exec(`INSERT INTO entries(id, version, overview, nonce_overview, details, nonce_details, pending, local_revision)
+	                   VALUES ('e', 3, x'01', x'01', x'01', x'01', 0, 1)`)
+	require.NoError(t, err)
+	stale := filepath.Join(t.TempDir(), "stale.bin")
+	require.NoError(t, os.WriteFile(stale, []byte("x"), 0o600))
+	_, err = db.Exec(`INSERT INTO files(entry_id, encrypted_file_key, nonce, local_path, upload_status) VALUES ('e', x'01', x'01', ?, 'pending')`, stale)
+	require.NoError(t, err)
+
+	env, _ := models.Wrap(models.EntryTypeBinaryFile, "doc", nil, models.BinaryFile{Path: "/new.bin"})
+	file := &models.File{EncryptedFileKey: make([]byte, 32), Nonce: []byte("n2"), LocalPath: "/tmp/new-cipher"}
+	require.NoError(t, svc.Update(ctx, "e", env, file, key))
+
+	require.Equal(t, 1, oneRow[int](t, db, `SELECT COUNT(*) FROM entries`))
+	require.Equal(t, int64(3), oneRow[int64](t, db, `SELECT version FROM entries WHERE id='e'`))
+	require.Equal(t, 1, oneRow[int](t, db, `SELECT pending FROM entries WHERE id='e'`))
+	require.Equal(t, "/tmp/new-cipher", oneRow[string](t, db, `SELECT local_path FROM files WHERE entry_id='e'`))
+	require.Equal(t, "pending", oneRow[string](t, db, `SELECT upload_status FROM files WHERE entry_id='e'`))
+
+	got, err := svc.Get(ctx, "e", key)
+	require.NoError(t, err)
+	require.Equal(t, "doc", got.Title)
+
+	// the ciphertext staged for the replaced attachment is removed
+	_, err = os.Stat(stale)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestUpdate_MissingOrDeletedEntry(t *testing.T) {
+	db := setupDBEntry(t)
+	svc := NewEntryService(&fakeClient{}, db)
+	ctx := context.Background()
+	key := make([]byte, 32)
+
+	_, err := db.Exec(`INSERT INTO entries(id, overview, nonce_overview, details, nonce_details, deleted)
+	                   VALUES ('gone', x'01', x'01', x'01', x'01', 1)`)
+	require.NoError(t, err)
+
+	env, _ := models.Wrap(models.EntryTypeNote, "n", nil, models.Note{Text: "t"})
+	require.Error(t, svc.Update(ctx, "gone", env, nil, key))
+	require.Error(t, svc.Update(ctx, "missing", env, nil, key))
+	require.Equal(t, 1, oneRow[int](t, db, `SELECT COUNT(*) FROM entries`))
 }
 
 func TestList_DecryptsOverview(t *testing.T) {
@@ -184,6 +327,48 @@ func TestDeleteByID_SetsDeleted(t *testing.T) {
 	require.Equal(t, 1, del)
 }
 
+func TestDeleteByID_TombstonesFile(t *testing.T) {
+	db := setupDBEntry(t)
+	svc := NewEntryService(&fakeClient{}, db)
+
+	key := make([]byte, 32)
+	env, _ := models.Wrap(models.EntryTypeBinaryFile, "Doc", nil, models.BinaryFile{Path: "/ignored"})
+	file := &models.File{EncryptedFileKey: make([]byte, 32), Nonce: []byte("n"), LocalPath: "/tmp/x"}
+	require.NoError(t, svc.Add(context.Background(), env, file, key))
+	id := oneRow[string](t, db, `SELECT id FROM entries LIMIT 1`)
+
+	require.NoError(t, svc.DeleteByID(context.Background(), id))
+
+	require.Equal(t, 1, oneRow[int](t, db, `SELECT deleted FROM files WHERE entry_id=?`, id))
+	require.Equal(t, 1, oneRow[int](t, db, `SELECT pending FROM entries WHERE id=?`, id))
+}
+
+func TestSync_PushesTombstonesAndAppliesRemoteOnes(t *testing.T) {
+	db := setupDBEntry(t)
+	_, err := db.Exec(`INSERT INTO entries(id, version, overview, nonce_overview, details, nonce_details, deleted, pending, local_revision) VALUES
+	  ('mine', 2, x'01', x'01', x'01', x'01', 1, 1, 3),
+	  ('theirs', 1, x'01', x'01', x'01', x'01', 0, 0, 0)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO files(entry_id, encrypted_file_key, nonce, local_path, upload_status, deleted)
+	                  VALUES ('theirs', x'01', x'02', '', 'completed', 0)`)
+	require.NoError(t, err)
+
+	fc := &fakeClientEntry{
+		SyncProcessed:  []*models.Entry{{Id: "mine", Version: 5, Deleted: true}},
+		SyncNewEntries: []*models.Entry{{Id: "theirs", Version: 4, Deleted: true, Overview: []byte{1}, NonceOverview: []byte{1}, Details: []byte{1}, NonceDetails: []byte{1}}},
+		SyncMaxVersion: 5,
+	}
+	svc := NewEntryService(fc, db)
+
+	require.NoError(t, svc.Sync(context.Background()))
+
+	require.Len(t, fc.SyncPushed, 1)
+	require.True(t, fc.SyncPushed[0].Deleted)
+	require.Equal(t, 0, oneRow[int](t, db, `SELECT pending FROM entries WHERE id='mine'`))
+	require.Equal(t, 1, oneRow[int](t, db, `SELECT deleted FROM entries WHERE id='theirs'`))
+	require.Equal(t, 1, oneRow[int](t, db, `SELECT deleted FROM files WHERE entry_id='theirs'`))
+}
+
 func TestGet_ReturnsDecryptedEnvelope(t *testing.T) {
 	db := setupDBEntry(t)
 	fc := &fakeClient{}
@@ -210,12 +395,16 @@ func TestGet_ReturnsDecryptedEnvelope(t *testing.T) {
 
 func TestSync_UpsertsAndUpdatesVersion_NoUploads(t *testing.T) {
 	db := setupDBEntry(t)
+	_, err := db.Exec(`INSERT INTO entries(id, overview, nonce_overview, details, nonce_details, pending, local_revision)
+	                   VALUES ('p1', x'01', x'02', x'03', x'04', 1, 1)`)
+	require.NoError(t, err)
+
 	fc := &fakeClientEntry{
 		SyncProcessed: []*models.Entry{
-			{Id: "p1", Overview: []byte("ov"), NonceOverview: []byte("no"), Details: []byte("d"), NonceDetails: []byte("nd")},
+			{Id: "p1", Version: 6, Overview: []byte("ov"), NonceOverview: []byte("no"), Details: []byte("d"), NonceDetails: []byte("nd")},
 		},
 		SyncNewEntries: []*models.Entry{
-			{Id: "n1", Overview: []byte("ovN"), NonceOverview: []byte("noN"), Details: []byte("dN"), NonceDetails: []byte("ndN")},
+			{Id: "n1", Version: 7, Overview: []byte("ovN"), NonceOverview: []byte("noN"), Details: []byte("dN"), NonceDetails: []byte("ndN")},
 		},
 		SyncNewFiles: []*models.File{
 			{EntryID: "n1", EncryptedFileKey: []byte("fk"), Nonce: []byte("fn"), LocalPath: "/tmp/a", UploadStatus: "completed"},
@@ -234,10 +423,259 @@ func TestSync_UpsertsAndUpdatesVersion_NoUploads(t *testing.T) {
 	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM entries WHERE id IN ('p1','n1')`).Scan(&cnt))
 	require.Equal(t, 2, cnt)
 
+	require.Equal(t, int64(6), oneRow[int64](t, db, `SELECT version FROM entries WHERE id='p1'`))
+	require.Equal(t, int64(7), oneRow[int64](t, db, `SELECT version FROM entries WHERE id='n1'`))
+	require.Equal(t, 0, oneRow[int](t, db, `SELECT COUNT(*) FROM entries WHERE pending=1`))
+
 	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM files WHERE entry_id='n1'`).Scan(&cnt))
 	require.Equal(t, 1, cnt)
 }
 
+func TestSync_EntryEditedDuringSync_StaysPending(t *testing.T) {
+	db := setupDBEntry(t)
+	svc := NewEntryService(nil, db)
+
+	key := make([]byte, 32)
+	env, err := models.Wrap(models.EntryTypeNote, "T", nil, models.Note{Text: "v1"})
+	require.NoError(t, err)
+	require.NoError(t, svc.Add(context.Background(), env, nil, key))
+	id := oneRow[string](t, db, `SELECT id FROM entries LIMIT 1`)
+
+	fc := &fakeClientEntry{
+		SyncProcessed:  []*models.Entry{{Id: id, Version: 3}},
+		SyncMaxVersion: 3,
+		OnSync: func() {
+			_, err := db.Exec(`UPDATE entries SET local_revision = local_revision + 1 WHERE id=?`, id)
+			require.NoError(t, err)
+		},
+	}
+	svc = NewEntryService(fc, db)
+
+	require.NoError(t, svc.Sync(context.Background()))
+
+	require.Equal(t, 1, oneRow[int](t, db, `SELECT pending FROM entries WHERE id=?`, id))
+	require.Equal(t, int64(3), oneRow[int64](t, db, `SELECT version FROM entries WHERE id=?`, id))
+}
+
+func TestSync_ConflictsKeepBothCopies(t *testing.T) {
+	db := setupDBEntry(t)
+	_, err := db.Exec(`INSERT INTO entries(id, version, overview, nonce_overview, details, nonce_details, pending, local_revision) VALUES
+	  ('stale', 2, x'01', x'01', x'01', x'01', 1, 1),
+	  ('edited', 3, x'02', x'02', x'02', x'02', 1, 1)`)
+	require.NoError(t, err)
+
+	remote := func(id string, v int64) *models.Entry {
+		return &models.Entry{Id: id, Version: v, Overview: []byte("r"), NonceOverview: []byte("r"), Details: []byte("r"), NonceDetails: []byte("r")}
+	}
+	fc := &fakeClientEntry{
+		// the push of 'stale' is rejected by the server
+		SyncConflicts: []*models.Entry{remote("stale", 5)},
+		// 'edited' changed on the server after the local edit was pushed
+		SyncNewEntries: []*models.Entry{remote("edited", 6)},
+		SyncMaxVersion: 6,
+	}
+	svc := NewEntryService(fc, db)
+	require.NoError(t, svc.Sync(context.Background()))
+
+	// local copies are untouched and still pending
+	require.Equal(t, 2, oneRow[int](t, db, `SELECT COUNT(*) FROM entries WHERE pending=1 AND details IN (x'01', x'02')`))
+	// server copies are kept as conflicts
+	require.Equal(t, int64(5), oneRow[int64](t, db, `SELECT version FROM conflicts WHERE entry_id='stale'`))
+	require.Equal(t, int64(6), oneRow[int64](t, db, `SELECT version FROM conflicts WHERE entry_id='edited'`))
+
+	// unresolved conflicts are not pushed again
+	fc.SyncConflicts, fc.SyncNewEntries = nil, nil
+	require.NoError(t, svc.Sync(context.Background()))
+	require.Empty(t, fc.SyncPushed)
+}
+
+func TestListAndResolveConflict(t *testing.T) {
+	db := setupDBEntry(t)
+	svc := NewEntryService(&fakeClient{}, db)
+	ctx := context.Background()
+	key := make([]byte, 32)
+
+	local, _ := models.Wrap(models.EntryTypeLogin, "mail", nil, models.Login{Username: "bob", Password: "old"})
+	require.NoError(t, svc.Add(ctx, local, nil, key))
+	var id string
+	require.NoError(t, db.QueryRow(`SELECT id FROM entries LIMIT 1`).Scan(&id))
+
+	remote, _ := models.Wrap(models.EntryTypeLogin, "mail", nil, models.Login{Username: "bob", Password: "new"})
+	details, nonce, err := cryptox.EncryptEntry(remote, key, cryptox.EntryAAD(id, cryptox.FieldDetails))
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO conflicts(entry_id, version, overview, nonce_overview, details, nonce_details) VALUES (?, 5, x'00', x'00', ?, ?)`,
+		id, details, nonce)
+	require.NoError(t, err)
+
+	cs, err := svc.ListConflicts(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, cs, 1)
+	require.Equal(t, id, cs[0].Id)
+	require.Equal(t, int64(5), cs[0].RemoteVersion)
+	require.Equal(t, local.Details, cs[0].Local.Details)
+	require.Equal(t, remote.Details, cs[0].Remote.Details)
+
+	require.NoError(t, svc.ResolveConflict(ctx, id, *cs[0].Remote, false, key))
+
+	// the resolution is a pending write on top of the server copy
+	require.Equal(t, int64(5), oneRow[int64](t, db, `SELECT version FROM entries WHERE id=?`, id))
+	require.Equal(t, 1, oneRow[int](t, db, `SELECT pending FROM entries WHERE id=?`, id))
+	require.Equal(t, 0, oneRow[int](t, db, `SELECT COUNT(*) FROM conflicts`))
+
+	got, err := svc.Get(ctx, id, key)
+	require.NoError(t, err)
+	require.Equal(t, remote.Details, got.Details)
+
+	// a resolved conflict cannot be resolved twice
+	require.Error(t, svc.ResolveConflict(ctx, id, local, false, key))
+}
+
+func TestResolveConflict_DeletedTombstonesEntryAndFile(t *testing.T) {
+	db := setupDBEntry(t)
+	svc := NewEntryService(&fakeClient{}, db)
+	ctx := context.Background()
+	key := make([]byte, 32)
+
+	_, err := db.Exec(`INSERT INTO entries(id, version, overview, nonce_overview, details, nonce_details, pending, local_revision)
+	                   VALUES ('e', 1, x'01', x'01', x'01', x'01', 1, 1)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO files(entry_id, encrypted_file_key, nonce, local_path, upload_status) VALUES ('e', x'01', x'01', '', 'completed')`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO conflicts(entry_id, version, deleted, overview, nonce_overview, details, nonce_details) VALUES ('e', 4, 1, x'00', x'00', x'00', x'00')`)
+	require.NoError(t, err)
+
+	env, _ := models.Wrap(models.EntryTypeNote, "n", nil, models.Note{Text: "t"})
+	require.NoError(t, svc.ResolveConflict(ctx, "e", env, true, key))
+
+	require.Equal(t, 1, oneRow[int](t, db, `SELECT deleted FROM entries WHERE id='e'`))
+	require.Equal(t, int64(4), oneRow[int64](t, db, `SELECT version FROM entries WHERE id='e'`))
+	require.Equal(t, 1, oneRow[int](t, db, `SELECT deleted FROM files WHERE entry_id='e'`))
+}
+
+func TestHistoryAndRestore(t *testing.T) {
+	db := setupDBEntry(t)
+	ctx := context.Background()
+	key := make([]byte, 32)
+
+	seal := func(v int64, deleted bool, pw string) *models.Entry {
+		env, _ := models.Wrap(models.EntryTypeLogin, "mail", nil, models.Login{Username: "bob", Password: pw})
+		details, nonce, err := cryptox.EncryptEntry(env, key, cryptox.EntryAAD("e", cryptox.FieldDetails))
+		require.NoError(t, err)
+		return &models.Entry{Id: "e", Version: v, Deleted: deleted, Details: details, NonceDetails: nonce}
+	}
+	fc := &fakeClient{Revisions: []*models.Entry{seal(7, true, "new"), seal(5, false, "new"), seal(2, false, "old")}}
+	svc := NewEntryService(fc, db)
+
+	_, err := db.Exec(`INSERT INTO entries(id, version, overview, nonce_overview, details, nonce_details, deleted, pending, local_revision)
+	                   VALUES ('e', 7, x'01', x'01', x'01', x'01', 1, 0, 1)`)
+	require.NoError(t, err)
+
+	revs, err := svc.History(ctx, "e", key)
+	require.NoError(t, err)
+	require.Len(t, revs, 3)
+	require.Equal(t, int64(7), revs[0].Version)
+	require.True(t, revs[0].Deleted)
+	x, err := revs[2].Envelope.Unwrap()
+	require.NoError(t, err)
+	require.Equal(t, "old", x.(models.Login).Password)
+
+	require.NoError(t, svc.Restore(ctx, "e", 2, key))
+
+	// the old revision is a pending, undeleted write on top of the current base
+	require.Equal(t, int64(7), oneRow[int64](t, db, `SELECT version FROM entries WHERE id='e'`))
+	require.Equal(t, 0, oneRow[int](t, db, `SELECT deleted FROM entries WHERE id='e'`))
+	require.Equal(t, 1, oneRow[int](t, db, `SELECT pending FROM entries WHERE id='e'`))
+	got, err := svc.Get(ctx, "e", key)
+	require.NoError(t, err)
+	x, err = got.Unwrap()
+	require.NoError(t, err)
+	require.Equal(t, "old", x.(models.Login).Password)
+
+	require.Error(t, svc.Restore(ctx, "e", 7, key)) // a deletion
+	require.ErrorIs(t, svc.Restore(ctx, "e", 3, key), client.ErrNotFound)
+	require.Error(t, svc.Restore(ctx, "unknown", 5, key))
+}
+
+func TestTrash_ListUndeleteAndPurge(t *testing.T) {
+	db := setupDBEntry(t)
+	ctx := context.Background()
+	svc := NewEntryService(&fakeClient{}, db)
+
+	key := make([]byte, 32)
+	staged := filepath.Join(t.TempDir(), "staged.bin")
+	require.NoError(t, os.WriteFile(staged, []byte("ct"), 0o600))
+	env, _ := models.Wrap(models.EntryTypeBinaryFile, "Doc", nil, models.BinaryFile{Path: "/ignored"})
+	file := &models.File{EncryptedFileKey: make([]byte, 32), Nonce: []byte("n"), LocalPath: staged}
+	require.NoError(t, svc.Add(ctx, env, file, key))
+	id := oneRow[string](t, db, `SELECT id FROM entries LIMIT 1`)
+	require.NoError(t, svc.DeleteByID(ctx, id))
+
+	trash, err := svc.ListTrash(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	require.Equal(t, "Doc", trash[0].Title)
+
+	// restoring brings back the entry and its file
+	require.NoError(t, svc.Undelete(ctx, id))
+	require.Equal(t, 0, oneRow[int](t, db, `SELECT deleted FROM entries WHERE id=?`, id))
+	require.Equal(t, 0, oneRow[int](t, db, `SELECT deleted FROM files WHERE entry_id=?`, id))
+	require.Error(t, svc.Purge(ctx, id)) // not in the trash
+
+	require.NoError(t, svc.DeleteByID(ctx, id))
+	require.Error(t, svc.Purge(ctx, id)) // deletion not pushed yet
+
+	_, err = db.Exec(`UPDATE entries SET pending=0 WHERE id=?`, id)
+	require.NoError(t, err)
+	require.NoError(t, svc.Purge(ctx, id))
+	require.Equal(t, 0, oneRow[int](t, db, `SELECT count(*) FROM entries`))
+	require.Equal(t, 0, oneRow[int](t, db, `SELECT count(*) FROM files`))
+	_, err = os.Stat(staged)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestSync_FullResyncDropsEntriesGoneFromServer(t *testing.T) {
+	db := setupDBEntry(t)
+	_, err := db.Exec(`INSERT INTO entries(id, version, overview, nonce_overview, details, nonce_details, deleted, pending, local_revision) VALUES
+	  ('kept', 3, x'01', x'01', x'01', x'01', 0, 0, 0),
+	  ('purged', 4, x'01', x'01', x'01', x'01', 0, 0, 0),
+	  ('mine', 0, x'01', x'01', x'01', x'01', 0, 1, 1)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO files(entry_id, encrypted_file_key, nonce, local_path, upload_status, deleted)
+	                  VALUES ('purged', x'01', x'02', '', 'completed', 0)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO metadata(key, value) VALUES ('current_version', '4')`)
+	require.NoError(t, err)
+
+	fc := &fakeClientEntry{
+		SyncFirstErr:   client.ErrResyncRequired,
+		SyncProcessed:  []*models.Entry{{Id: "mine", Version: 9}},
+		SyncNewEntries: []*models.Entry{{Id: "kept", Version: 3, Overview: []byte{1}, NonceOverview: []byte{1}, Details: []byte{1}, NonceDetails: []byte{1}}},
+		SyncMaxVersion: 9,
+	}
+	svc := NewEntryService(fc, db)
+
+	require.NoError(t, svc.Sync(context.Background()))
+
+	require.Equal(t, []int64{4, 0}, fc.SyncFrom)
+	require.Len(t, fc.SyncPushed, 1)
+	require.Equal(t, 0, oneRow[int](t, db, `SELECT count(*) FROM entries WHERE id='purged'`))
+	require.Equal(t, 0, oneRow[int](t, db, `SELECT count(*) FROM files WHERE entry_id='purged'`))
+	require.Equal(t, 1, oneRow[int](t, db, `SELECT count(*) FROM entries WHERE id='kept'`))
+	require.Equal(t, 0, oneRow[int](t, db, `SELECT pending FROM entries WHERE id='mine'`))
+	require.Equal(t, "9", oneRow[string](t, db, `SELECT value FROM metadata WHERE key='current_version'`))
+}
+
+func TestSync_KeepsCurrentVersionWhenServerReportsLower(t *testing.T) {
+	db := setupDBEntry(t)
+	_, err := db.Exec(`INSERT INTO metadata(key,value) VALUES ('current_version','12')`)
+	require.NoError(t, err)
+
+	svc := NewEntryService(&fakeClientEntry{SyncMaxVersion: 0}, db)
+	require.NoError(t, svc.Sync(context.Background()))
+
+	require.Equal(t, "12", oneRow[string](t, db, `SELECT value FROM metadata WHERE key='current_version'`))
+}
+
 func TestGetPresignedGetUrl_DelegatesToClient(t *testing.T) {
 	db := setupDBEntry(t)
 	fc := &fakeClientEntry{GetURL: "https://dl"}
@@ -317,6 +755,7 @@ func TestSync_UploadsPendingFiles_Success(t *testing.T) {
 
 func TestSync_UploadsPendingFiles_ErrorFromServer(t *testing.T) {
 	db := setupDBEntry(t)
+	fastRetries(t)
 
 	tmp := t.TempDir()
 	local := makeTempFile(t, tmp, "data.bin", "HELLO")
@@ -332,11 +771,197 @@ func TestSync_UploadsPendingFiles_ErrorFromServer(t *testing.T) {
 	fc := &fakeClientEntry{
 		SyncUploadTasks: []*models.FileUploadTask{{EntryID: "e2", URL: srv.URL}},
 		SyncMaxVersion:  2,
+		UploadURL:       srv.URL,
 	}
 	svc := NewEntryService(fc, db)
 
 	err = svc.Sync(context.Background())
 	require.Error(t, err)
+	// every retry asks for a fresh URL
+	require.Equal(t, []string{"e2", "e2"}, fc.RequestedURLs)
+	// the file stays registered, so the next sync only retries the upload
+	require.Equal(t, 1, oneRow[int](t, db, `SELECT registered FROM files WHERE entry_id='e2'`))
+	require.Equal(t, "pending", oneRow[string](t, db, `SELECT upload_status FROM files WHERE entry_id='e2'`))
+}
+
+func TestSync_RetriesUploadWithFreshURL(t *testing.T) {
+	db := setupDBEntry(t)
+	fastRetries(t)
+
+	local := makeTempFile(t, t.TempDir(), "data.bin", "HELLO")
+	_, err := db.Exec(`INSERT INTO files(entry_id, encrypted_file_key, nonce, local_path, upload_status, deleted)
+	                   VALUES ('e1', x'AA', x'BB', ?, 'pending', 0)`, local)
+	require.NoError(t, err)
+
+	var received []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/expired" {
+			http.Error(w, "Request has expired", http.StatusForbidden)
+			return
+		}
+		received = body
+	}))
+	t.Cleanup(srv.Close)
+
+	fc := &fakeClientEntry{
+		SyncUploadTasks: []*models.FileUploadTask{{EntryID: "e1", URL: srv.URL + "/expired"}},
+		UploadURL:       srv.URL + "/fresh",
+	}
+	require.NoError(t, NewEntryService(fc, db).Sync(context.Background()))
+	require.Equal(t, []string{"e1"}, fc.RequestedURLs)
+	require.Equal(t, "HELLO", string(received))
+	require.Equal(t, []string{"e1"}, fc.MarkUploadedIDs)
+}
+
+func TestSync_UploadsFilesRegisteredEarlier(t *testing.T) {
+	db := setupDBEntry(t)
+
+	local := makeTempFile(t, t.TempDir(), "data.bin", "HELLO")
+	// e1 was registered by a sync whose upload never happened, e2 is gone
+	// from the server
+	_, err := db.Exec(`INSERT INTO files(entry_id, encrypted_file_key, nonce, local_path, upload_status, deleted, registered) VALUES
+	                   ('e1', x'AA', x'BB', ?, 'pending', 0, 1)`, local)
+	require.NoError(t, err)
+
+	var received []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+	}))
+	t.Cleanup(srv.Close)
+
+	fc := &fakeClientEntry{UploadURL: srv.URL}
+	require.NoError(t, NewEntryService(fc, db).Sync(context.Background()))
+	require.Empty(t, fc.SyncPushedFiles)
+	require.Equal(t, []string{"e1"}, fc.RequestedURLs)
+	require.Equal(t, "HELLO", string(received))
+	require.Equal(t, []string{"e1"}, fc.MarkUploadedIDs)
+
+	// a registered file the server no longer expects is pushed again
+	_, err = db.Exec(`INSERT INTO files(entry_id, encrypted_file_key, nonce, local_path, upload_status, deleted, registered) VALUES
+	                   ('e2', x'AA', x'BC', ?, 'pending', 0, 1)`, local)
+	require.NoError(t, err)
+	fc = &fakeClientEntry{UploadURLErr: client.ErrNotFound}
+	require.NoError(t, NewEntryService(fc, db).Sync(context.Background()))
+	require.Equal(t, 0, oneRow[int](t, db, `SELECT registered FROM files WHERE entry_id='e2'`))
+
+	require.NoError(t, NewEntryService(fc, db).Sync(context.Background()))
+	require.Len(t, fc.SyncPushedFiles, 1)
+	require.Equal(t, "e2", fc.SyncPushedFiles[0].EntryID)
+}
+
+func TestSync_ResumesMultipartUpload(t *testing.T) {
+	db := setupDBEntry(t)
+	fastRetries(t)
+
+	orig := multipartPartSize
+	multipartPartSize = 1024
+	t.Cleanup(func() { multipartPartSize = orig })
+
+	content := bytes.Repeat([]byte("0123456789"), 350) // four parts
+	local := filepath.Join(t.TempDir(), "data.bin")
+	require.NoError(t, os.WriteFile(local, content, 0o600))
+	_, err := db.Exec(`INSERT INTO files(entry_id, encrypted_file_key, nonce, local_path, upload_status, deleted)
+	                   VALUES ('e1', x'AA', x'BB', ?, 'pending', 0)`, local)
+	require.NoError(t, err)
+
+	var (
+		mu       sync.Mutex
+		uploaded []string
+		stored   = map[string][]byte{}
+		failPart = "3"
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := r.URL.Query().Get("partNumber")
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		uploaded = append(uploaded, n)
+		if n == failPart {
+			http.Error(w, "connection reset", http.StatusBadGateway)
+			return
+		}
+		stored[n] = body
+		w.Header().Set("ETag", `"etag-`+n+`"`)
+	}))
+	t.Cleanup(srv.Close)
+
+	fc := &fakeClientEntry{
+		SyncUploadTasks: []*models.FileUploadTask{{EntryID: "e1", URL: srv.URL}},
+		SyncMaxVersion:  1,
+		UploadID:        "up1",
+		PartURL:         srv.URL,
+		UploadURL:       srv.URL,
+	}
+	svc := NewEntryService(fc, db)
+
+	// a failed part fails the sync but keeps the finished ones
+	require.Error(t, svc.Sync(context.Background()))
+	require.Equal(t, "up1", oneRow[string](t, db, `SELECT upload_id FROM files WHERE entry_id='e1'`))
+	require.Equal(t, 3, oneRow[int](t, db, `SELECT json_array_length(upload_parts) FROM files WHERE entry_id='e1'`))
+	require.Empty(t, fc.MarkUploadedIDs)
+
+	// the next sync, e.g. after a restart, uploads only the missing part
+	mu.Lock()
+	uploaded, failPart = nil, ""
+	mu.Unlock()
+	require.NoError(t, NewEntryService(fc, db).Sync(context.Background()))
+	require.Equal(t, []string{"3"}, uploaded)
+	require.Equal(t, uploadAttempts+1, fc.StartedUploads)
+
+	require.Len(t, fc.CompletedParts, 4)
+	var assembled []byte
+	for i, p := range fc.CompletedParts {
+		n := fmt.Sprint(i + 1)
+		require.Equal(t, models.UploadedPart{PartNumber: int32(i + 1), ETag: `"etag-` + n + `"`}, p)
+		assembled = append(assembled, stored[n]...)
+	}
+	require.Equal(t, content, assembled)
+
+	require.Equal(t, []string{"e1"}, fc.MarkUploadedIDs)
+	require.Equal(t, "completed", oneRow[string](t, db, `SELECT upload_status FROM files WHERE entry_id='e1'`))
+	require.Equal(t, "", oneRow[string](t, db, `SELECT coalesce(upload_id, '') FROM files WHERE entry_id='e1'`))
+}
+
+func TestSync_MultipartUploadStartsOverWhenServerDropsIt(t *testing.T) {
+	db := setupDBEntry(t)
+
+	orig := multipartPartSize
+	multipartPartSize = 1024
+	t.Cleanup(func() { multipartPartSize = orig })
+
+	local := filepath.Join(t.TempDir(), "data.bin")
+	require.NoError(t, os.WriteFile(local, make([]byte, 2048), 0o600))
+	// a part of an upload the server no longer has
+	_, err := db.Exec(`INSERT INTO files(entry_id, encrypted_file_key, nonce, local_path, upload_status, deleted, upload_id, upload_parts)
+	                   VALUES ('e1', x'AA', x'BB', ?, 'pending', 0, 'old', '[{"part":1,"etag":"x"}]')`, local)
+	require.NoError(t, err)
+
+	var uploaded []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		uploaded = append(uploaded, r.URL.Query().Get("partNumber"))
+		w.Header().Set("ETag", `"e"`)
+	}))
+	t.Cleanup(srv.Close)
+
+	fc := &fakeClientEntry{
+		SyncUploadTasks: []*models.FileUploadTask{{EntryID: "e1", URL: srv.URL}},
+		UploadID:        "new",
+		PartURL:         srv.URL,
+		LostUpload:      true,
+	}
+	svc := NewEntryService(fc, db)
+
+	// the upload vanishes mid-way: its local state is dropped
+	require.ErrorIs(t, svc.Sync(context.Background()), client.ErrNotFound)
+	require.Equal(t, "", oneRow[string](t, db, `SELECT coalesce(upload_id, '') FROM files WHERE entry_id='e1'`))
+
+	fc.LostUpload = false
+	require.NoError(t, svc.Sync(context.Background()))
+	require.ElementsMatch(t, []string{"1", "2"}, uploaded)
+	require.Len(t, fc.CompletedParts, 2)
+	require.Equal(t, []string{"e1"}, fc.MarkUploadedIDs)
 }
 
 func TestSync_ParseCurrentVersionError(t *testing.T) {
@@ -411,3 +1036,198 @@ func TestDeleteByID_NotFoundError(t *testing.T) {
 	err := svc.DeleteByID(context.Background(), "absent")
 	require.Error(t, err)
 }
+
+func TestMigrateFileKeys_WrapsLegacyKeys(t *testing.T) {
+	db := setupDBEntry(t)
+	fc := &fakeClient{}
+	svc := NewEntryService(fc, db)
+	ctx := context.Background()
+	key := bytes.Repeat([]byte{3}, 32)
+	raw := bytes.Repeat([]byte{9}, 32)
+
+	done, err := cryptox.WrapKey(raw, key)
+	require.NoError(t, err)
+	for _, f := range []struct {
+		id, status string
+		key        []byte
+		deleted    int
+	}{
+		{"up", "completed", raw, 0},
+		{"pend", "pending", raw, 0},
+		{"gone", "completed", raw, 1},
+		{"done", "completed", done, 0},
+	} {
+		_, err := db.Exec(`INSERT INTO files(entry_id, encrypted_file_key, nonce, local_path, upload_status, deleted) VALUES (?, ?, x'01', '', ?, ?)`,
+			f.id, f.key, f.status, f.deleted)
+		require.NoError(t, err)
+	}
+
+	n, err := svc.MigrateFileKeys(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	// only the live uploaded file needs its server copy replaced
+	require.Len(t, fc.UpdatedKeys, 1)
+	require.Equal(t, oneRow[[]byte](t, db, `SELECT encrypted_file_key FROM files WHERE entry_id='up'`), fc.UpdatedKeys["up"])
+
+	for _, id := range []string{"up", "pend", "gone", "done"} {
+		got, err := cryptox.UnwrapKey(oneRow[[]byte](t, db, `SELECT encrypted_file_key FROM files WHERE entry_id=?`, id), key)
+		require.NoError(t, err, id)
+		require.Equal(t, raw, got, id)
+	}
+
+	// a second run has nothing left to do
+	n, err = svc.MigrateFileKeys(ctx, key)
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestMigrateFileKeys_KeepsLocalKeyWhenServerFails(t *testing.T) {
+	db := setupDBEntry(t)
+	svc := NewEntryService(&fakeClient{UpdateKeyErr: client.ErrUnavailable}, db)
+	ctx := context.Background()
+	raw := bytes.Repeat([]byte{9}, 32)
+
+	_, err := db.Exec(`INSERT INTO files(entry_id, encrypted_file_key, nonce, local_path, upload_status) VALUES ('up', ?, x'01', '', 'completed')`, raw)
+	require.NoError(t, err)
+
+	_, err = svc.MigrateFileKeys(ctx, make([]byte, 32))
+	require.ErrorIs(t, err, client.ErrUnavailable)
+	require.Equal(t, raw, oneRow[[]byte](t, db, `SELECT encrypted_file_key FROM files WHERE entry_id='up'`))
+}
+
+func TestEntryCiphertextIsBoundToEntryID(t *testing.T) {
+	db := setupDBEntry(t)
+	svc := NewEntryService(&fakeClient{}, db)
+	ctx := context.Background()
+	key := make([]byte, 32)
+
+	for _, pw := range []string{"a", "b"} {
+		env, _ := models.Wrap(models.EntryTypeLogin, "mail", nil, models.Login{Password: pw})
+		require.NoError(t, svc.Add(ctx, env, nil, key))
+	}
+	var a, b string
+	require.NoError(t, db.QueryRow(`SELECT id FROM entries ORDER BY id LIMIT 1`).Scan(&a))
+	require.NoError(t, db.QueryRow(`SELECT id FROM entries WHERE id<>?`, a).Scan(&b))
+
+	// a server moving the details of one entry into another is detected
+	_, err := db.Exec(`UPDATE entries SET (details, nonce_details) = (SELECT details, nonce_details FROM entries WHERE id=?) WHERE id=?`, a, b)
+	require.NoError(t, err)
+	_, err = svc.Get(ctx, b, key)
+	require.Error(t, err)
+
+	// and so is an overview passed off as details
+	_, err = db.Exec(`UPDATE entries SET details=overview, nonce_details=nonce_overview WHERE id=?`, a)
+	require.NoError(t, err)
+	_, err = svc.Get(ctx, a, key)
+	require.Error(t, err)
+}
+
+func TestResealEntries_BindsLegacyCiphertexts(t *testing.T) {
+	db := setupDBEntry(t)
+	svc := NewEntryService(&fakeClient{}, db)
+	ctx := context.Background()
+	key := make([]byte, 32)
+
+	env, _ := models.Wrap(models.EntryTypeNote, "old", nil, models.Note{Text: "t"})
+	overview, oNonce, err := cryptox.EncryptEntry(env.Overview(), key, nil)
+	require.NoError(t, err)
+	details, nonce, err := cryptox.EncryptEntry(env, key, nil)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO entries(id, version, overview, nonce_overview, details, nonce_details, pending, local_revision)
+	                   VALUES ('legacy', 3, ?, ?, ?, ?, 0, 1)`, overview, oNonce, details, nonce)
+	require.NoError(t, err)
+	require.NoError(t, svc.Add(ctx, env, nil, key))
+
+	// unbound ciphertexts are still readable
+	got, err := svc.Get(ctx, "legacy", key)
+	require.NoError(t, err)
+	require.Equal(t, env.Details, got.Details)
+
+	n, err := svc.ResealEntries(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	// the entry is queued for sync, bound to its id, on its old base version
+	require.Equal(t, 1, oneRow[int](t, db, `SELECT pending FROM entries WHERE id='legacy'`))
+	require.Equal(t, int64(3), oneRow[int64](t, db, `SELECT version FROM entries WHERE id='legacy'`))
+	var e models.Entry
+	require.NoError(t, db.QueryRow(`SELECT overview, nonce_overview, details, nonce_details FROM entries WHERE id='legacy'`).
+		Scan(&e.Overview, &e.NonceOverview, &e.Details, &e.NonceDetails))
+	var o models.Overview
+	legacy, err := cryptox.DecryptEntry(e.Overview, e.NonceOverview, key, cryptox.EntryAAD("legacy", cryptox.FieldOverview), false, &o)
+	require.NoError(t, err)
+	require.False(t, legacy)
+	var d models.Envelope
+	legacy, err = cryptox.DecryptEntry(e.Details, e.NonceDetails, key, cryptox.EntryAAD("legacy", cryptox.FieldDetails), false, &d)
+	require.NoError(t, err)
+	require.False(t, legacy)
+
+	n, err = svc.ResealEntries(ctx, key)
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestResealEntries_RejectsUnboundCiphertextsOnceSealed(t *testing.T) {
+	db := setupDBEntry(t)
+	fc := &fakeClient{}
+	svc := NewEntryService(fc, db)
+	ctx := context.Background()
+	key := make([]byte, 32)
+
+	env, _ := models.Wrap(models.EntryTypeBinaryFile, "scan", nil, models.BinaryFile{Path: "scan.pdf"})
+	overview, oNonce, err := cryptox.EncryptEntry(env.Overview(), key, nil)
+	require.NoError(t, err)
+	details, nonce, err := cryptox.EncryptEntry(env, key, nil)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO entries(id, version, overview, nonce_overview, details, nonce_details, pending, local_revision)
+	                   VALUES ('legacy', 3, ?, ?, ?, ?, 0, 1)`, overview, oNonce, details, nonce)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO metadata(key, value) VALUES ('current_version', '5')`)
+	require.NoError(t, err)
+
+	// the attachment of an unbound entry predates the binding too
+	got, err := svc.Get(ctx, "legacy", key)
+	require.NoError(t, err)
+	x, err := got.Unwrap()
+	require.NoError(t, err)
+	require.True(t, x.(models.BinaryFile).Unbound)
+
+	n, err := svc.ResealEntries(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Zero(t, oneRow[int](t, db, `SELECT COUNT(*) FROM metadata WHERE key='sealed_version'`))
+
+	// nothing left to re-seal: the account is sealed at its current version
+	n, err = svc.ResealEntries(ctx, key)
+	require.NoError(t, err)
+	require.Zero(t, n)
+	require.Equal(t, "5", oneRow[string](t, db, `SELECT value FROM metadata WHERE key='sealed_version'`))
+
+	// the flag is bound to the entry now
+	got, err = svc.Get(ctx, "legacy", key)
+	require.NoError(t, err)
+	x, err = got.Unwrap()
+	require.NoError(t, err)
+	require.True(t, x.(models.BinaryFile).Unbound)
+
+	// a server swapping the unbound ciphertext back in is rejected, and it is
+	// not re-sealed either
+	_, err = db.Exec(`UPDATE entries SET overview=?, nonce_overview=?, details=?, nonce_details=? WHERE id='legacy'`,
+		overview, oNonce, details, nonce)
+	require.NoError(t, err)
+	_, err = svc.Get(ctx, "legacy", key)
+	require.Error(t, err)
+	n, err = svc.ResealEntries(ctx, key)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	// unbound revisions are accepted only up to the sealed version
+	fc.Revisions = []*models.Entry{{Id: "legacy", Version: 3, Details: details, NonceDetails: nonce}}
+	_, err = svc.History(ctx, "legacy", key)
+	require.NoError(t, err)
+	fc.Revisions = []*models.Entry{{Id: "legacy", Version: 6, Details: details, NonceDetails: nonce}}
+	_, err = svc.History(ctx, "legacy", key)
+	require.Error(t, err)
+	require.Error(t, svc.Restore(ctx, "legacy", 6, key))
+}
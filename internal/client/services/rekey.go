@@ -0,0 +1,287 @@
+package services
+
+// This file implements the replacement of an account's vault key: the
+// content on the server and in the local store is re-encrypted under a new
+// random key, which is stored together with new credentials.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/client/client"
+	"github.com/dmitrijs2005/gophkeeper/internal/client/models"
+	"github.com/dmitrijs2005/gophkeeper/internal/client/repositories/conflicts"
+	"github.com/dmitrijs2005/gophkeeper/internal/client/repositories/entries"
+	"github.com/dmitrijs2005/gophkeeper/internal/client/repositories/files"
+	"github.com/dmitrijs2005/gophkeeper/internal/client/repositories/metadata"
+	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
+	"github.com/dmitrijs2005/gophkeeper/internal/dbx"
+)
+
+// rekeyTries is how many times rekeyVault pulls the account again when it
+// changed on the server while being re-encrypted.
+const rekeyTries = 3
+
+// rekeyVault replaces the vault key oldKey of the logged-in account userName
+// with a random one and returns it. The entries and file keys on the server
+// are pulled, re-encrypted under the new key and sent with ChangePassword
+// together with credentials derived from password with kdf, so that the
+// server swaps both at once; the current password is proven with salt and
+// authKey. The local store is re-encrypted up front, so that anything this
+// device cannot decrypt stops the re-key before the server has it, and is
+// stored together with the new offline data once the server has accepted
+// the swap (see storeRekey).
+//
+// The server rejects content that changed since it was pulled, e.g. by a
+// sync of another device; it is pulled again up to rekeyTries times before
+// client.ErrConflict is returned.
+func (a *authService) rekeyVault(ctx context.Context, userName string, password []byte, kdf cryptox.KDFParams, salt, authKey, oldKey []byte) ([]byte, error) {
+	sealed, err := readVersion(ctx, a.getMetadataRepo(), sealedVersionKey)
+	if err != nil {
+		return nil, err
+	}
+	allowLegacy := sealed == 0
+
+	newKey := cryptox.NewVaultKey()
+	creds, newAuthKey, err := newCredentials(userName, password, newKey, kdf)
+	if err != nil {
+		return nil, err
+	}
+	local, err := a.prepareLocalRekey(ctx, oldKey, newKey, allowLegacy)
+	if err != nil {
+		return nil, err
+	}
+
+	for try := 1; ; try++ {
+		rekey, err := a.pullRekey(ctx, oldKey, newKey, allowLegacy)
+		if err != nil {
+			return nil, err
+		}
+		srp, sessionID, proof, err := a.srpProve(ctx, userName, salt, authKey)
+		if err != nil {
+			return nil, err
+		}
+		serverProof, versions, err := a.client.ChangePassword(ctx, sessionID, proof, creds, rekey)
+		if errors.Is(err, client.ErrConflict) && try < rekeyTries {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := srp.VerifyServer(serverProof); err != nil {
+			return nil, client.ErrUnauthorized
+		}
+
+		if err := a.storeRekey(ctx, userName, creds, cryptox.MakeVerifier(newAuthKey), local, rekey, versions); err != nil {
+			return nil, fmt.Errorf("offline data saving error: %w", err)
+		}
+		return newKey, nil
+	}
+}
+
+// pullRekey fetches every entry, tombstones included, and every live file of
+// the account and re-encrypts them from oldKey under newKey. Entries keep
+// the server version they were pulled at as their base.
+func (a *authService) pullRekey(ctx context.Context, oldKey, newKey []byte, allowLegacy bool) (*models.VaultRekey, error) {
+	_, _, remoteEntries, remoteFiles, _, _, err := a.client.Sync(ctx, nil, nil, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error client sync: %w", err)
+	}
+
+	rekey := &models.VaultRekey{}
+	for _, e := range remoteEntries {
+		r, err := resealEntry(e, oldKey, newKey, allowLegacy)
+		if err != nil {
+			return nil, err
+		}
+		rekey.Entries = append(rekey.Entries, r)
+	}
+	for _, f := range remoteFiles {
+		key, err := rewrapFileKey(f.EncryptedFileKey, oldKey, newKey)
+		if err != nil {
+			return nil, fmt.Errorf("error re-wrapping key of %s: %w", f.EntryID, err)
+		}
+		rekey.Files = append(rekey.Files, &models.File{EntryID: f.EntryID, EncryptedFileKey: key, Nonce: f.Nonce})
+	}
+	return rekey, nil
+}
+
+// localRekey is the local store re-encrypted under a new vault key: every
+// entry with its sync state, every conflict copy and every file key.
+type localRekey struct {
+	entries   []*models.Entry
+	conflicts []*models.Entry
+	fileKeys  map[string][]byte
+}
+
+// prepareLocalRekey re-encrypts the local store from oldKey under newKey in
+// memory.
+func (a *authService) prepareLocalRekey(ctx context.Context, oldKey, newKey []byte, allowLegacy bool) (*localRekey, error) {
+	entryRepo := entries.NewSQLiteRepository(a.db)
+	local := &localRekey{fileKeys: make(map[string][]byte)}
+
+	live, err := entryRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := entryRepo.GetAllDeleted(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range append(live, deleted...) {
+		stored, err := entryRepo.GetByIDIncludingDeleted(ctx, row.Id)
+		if err != nil {
+			return nil, err
+		}
+		e, err := resealEntry(stored, oldKey, newKey, allowLegacy)
+		if err != nil {
+			return nil, err
+		}
+		local.entries = append(local.entries, e)
+	}
+
+	unresolved, err := conflicts.NewSQLiteRepository(a.db).GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range unresolved {
+		e, err := resealEntry(c, oldKey, newKey, allowLegacy)
+		if err != nil {
+			return nil, err
+		}
+		local.conflicts = append(local.conflicts, e)
+	}
+
+	localFiles, err := files.NewSQLiteRepository(a.db).GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range localFiles {
+		key, err := rewrapFileKey(f.EncryptedFileKey, oldKey, newKey)
+		if err != nil {
+			return nil, fmt.Errorf("error re-wrapping key of %s: %w", f.EntryID, err)
+		}
+		local.fileKeys[f.EntryID] = key
+	}
+	return local, nil
+}
+
+// storeRekey stores the local store re-encrypted by prepareLocalRekey once
+// the server has stored rekey, whose entries got the given versions, and
+// caches creds and verifier as offline data, all in one transaction:
+//   - synchronized entries take the server's re-encrypted copy;
+//   - local changes are rebased on the new version if they were based on
+//     the re-encrypted copy, and otherwise conflict with it like in Sync;
+//   - entries the server does not have, conflict copies and file keys are
+//     replaced in place.
+//
+// All entries are bound to their ids afterwards, so the last version is
+// recorded as the sealed version (see sealedVersionKey).
+func (a *authService) storeRekey(ctx context.Context, userName string, creds models.Credentials, verifier []byte, local *localRekey, rekey *models.VaultRekey, versions map[string]int64) error {
+	// the server's re-encrypted copies with their new versions, and the
+	// versions they were pulled at
+	rekeyed := make(map[string]*models.Entry, len(rekey.Entries))
+	bases := make(map[string]int64, len(rekey.Entries))
+	var sealed int64
+	for _, e := range rekey.Entries {
+		v, ok := versions[e.Id]
+		if !ok {
+			return fmt.Errorf("no version for entry %s", e.Id)
+		}
+		remote := *e
+		remote.Version = v
+		rekeyed[e.Id] = &remote
+		bases[e.Id] = e.Version
+		sealed = max(sealed, v)
+	}
+
+	return dbx.WithTx(ctx, a.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+		entryRepo := entries.NewSQLiteRepository(tx)
+		conflictRepo := conflicts.NewSQLiteRepository(tx)
+		fileRepo := files.NewSQLiteRepository(tx)
+		metadataRepo := metadata.NewSQLiteRepository(tx)
+
+		for _, e := range local.entries {
+			remote, onServer := rekeyed[e.Id]
+			var err error
+			switch {
+			case onServer && !e.Pending:
+				continue
+			case onServer && e.Version == bases[e.Id]:
+				e.Version = remote.Version
+				err = entryRepo.Rebase(ctx, e)
+			default:
+				err = entryRepo.Reencrypt(ctx, e)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		for _, c := range local.conflicts {
+			if _, onServer := rekeyed[c.Id]; onServer {
+				continue
+			}
+			if err := conflictRepo.Save(ctx, c); err != nil {
+				return err
+			}
+		}
+		for _, remote := range rekeyed {
+			conflict, err := entryRepo.ApplyRemote(ctx, remote)
+			if err != nil {
+				return err
+			}
+			if conflict {
+				err = conflictRepo.Save(ctx, remote)
+			} else {
+				err = conflictRepo.DeleteByEntryID(ctx, remote.Id)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		for id, key := range local.fileKeys {
+			if err := fileRepo.UpdateKey(ctx, id, key); err != nil {
+				return err
+			}
+		}
+
+		if sealed > 0 {
+			if err := metadataRepo.Set(ctx, sealedVersionKey, fmt.Appendf(nil, "%v", sealed)); err != nil {
+				return err
+			}
+		}
+		return writeOfflineData(ctx, metadataRepo, userName, creds, verifier)
+	})
+}
+
+// resealEntry decrypts the details of e with oldKey, accepting an unbound
+// ciphertext only if allowLegacy is set, and seals its content under newKey
+// bound to its id. The result keeps the sync state of e.
+func resealEntry(e *models.Entry, oldKey, newKey []byte, allowLegacy bool) (*models.Entry, error) {
+	envelope, _, err := openEnvelope(e.Details, e.NonceDetails, oldKey, e.Id, allowLegacy)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting entry %s: %w", e.Id, err)
+	}
+	r, err := sealEntry(e.Id, envelope, newKey)
+	if err != nil {
+		return nil, err
+	}
+	r.Version = e.Version
+	r.Deleted = e.Deleted
+	r.Pending = e.Pending
+	return r, nil
+}
+
+// rewrapFileKey unwraps a file key with oldKey, or takes it as is if it
+// predates key wrapping, and wraps it with newKey.
+func rewrapFileKey(key, oldKey, newKey []byte) ([]byte, error) {
+	if cryptox.IsWrappedKey(key) {
+		raw, err := cryptox.UnwrapKey(key, oldKey)
+		if err != nil {
+			return nil, err
+		}
+		key = raw
+	}
+	return cryptox.WrapKey(key, newKey)
+}
@@ -5,3 +5,7 @@ package common
 // AccessTokenHeaderName is the gRPC/HTTP metadata key used to carry the
 // access token on outbound requests.
 const AccessTokenHeaderName = "access_token"
+
+// RetryAfterTrailerName is the gRPC trailer in which a throttled call
+// (codes.ResourceExhausted) carries the seconds to wait before retrying.
+const RetryAfterTrailerName = "retry-after"
@@ -12,8 +12,13 @@ var (
 	// Service-level errors (generic/internal flow control).
 	ErrorInternal      = errors.New("internal error")
 	ErrorUnauthorized  = errors.New("unauthorized")
+	ErrorForbidden     = errors.New("forbidden")
 	ErrVersionConflict = errors.New("version conflict")
 
+	// ErrResyncRequired is returned by Sync when tombstones the client has not
+	// seen yet were already purged; the client must sync from scratch.
+	ErrResyncRequired = errors.New("full resync required")
+
 	// Validation / item-specific errors.
 	ErrorIncorrectMetadata = errors.New("incorrect metadata")
 
@@ -23,4 +28,16 @@ var (
 	// Token lifecycle errors.
 	ErrTokenExpired        = errors.New("token expired")
 	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	// ErrRefreshTokenReused is returned for a refresh token presented again
+	// after it was rotated; its session is revoked as the token has leaked.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+	// ErrSessionRevoked is returned for access tokens of a session that was
+	// revoked (or has expired) before the token itself.
+	ErrSessionRevoked = errors.New("session revoked")
+	// ErrSecondFactorRequired is returned for changes of an account with
+	// two-factor authentication that were not confirmed with a current code.
+	ErrSecondFactorRequired = errors.New("second factor required")
+	// ErrInvalidCode is returned for a wrong code of the second factor of
+	// such a change.
+	ErrInvalidCode = errors.New("invalid code")
 )
@@ -3,24 +3,140 @@ package cryptox
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hkdf"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/json"
 	"errors"
-	"os"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/common"
 	"golang.org/x/crypto/argon2"
 )
 
+// MakeVerifier returns SHA-256 of the given key. The server no longer
+// accepts it except to upgrade a legacy account to SRP; the client still
+// caches it, over the auth key, to check the password during offline login.
 func MakeVerifier(masterKey []byte) []byte {
 	hash := sha256.Sum256(masterKey)
 	return hash[:]
 }
 
-func DeriveMasterKey(password []byte, salt []byte) []byte {
-	x := argon2.IDKey(password, salt, 1, 64*1024, 4, 32)
-	return x
+// KDFArgon2id names Argon2id, the only supported password KDF.
+const KDFArgon2id = "argon2id"
+
+// KDFParams are the password KDF settings of an account. They are stored
+// next to its salt, so that the cost can be raised for new and upgraded
+// accounts without breaking existing ones.
+type KDFParams struct {
+	Algorithm string `json:"algorithm"`
+	Time      uint32 `json:"time"`
+	// Memory is the memory cost in KiB.
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+}
+
+var (
+	// LegacyKDFParams are the parameters of accounts created before they
+	// were stored per account.
+	LegacyKDFParams = KDFParams{Algorithm: KDFArgon2id, Time: 1, Memory: 64 * 1024, Threads: 4}
+	// DefaultKDFParams are the default parameters of new accounts, the
+	// second recommended Argon2id option of RFC 9106.
+	DefaultKDFParams = KDFParams{Algorithm: KDFArgon2id, Time: 3, Memory: 64 * 1024, Threads: 4}
+)
+
+// Bounds of acceptable KDF parameters. The upper bounds keep a server from
+// making its clients derive keys with unbounded cost.
+const (
+	maxKDFTime   = 64
+	maxKDFMemory = 4 * 1024 * 1024
+)
+
+// ErrUnsupportedKDF is returned for unknown KDF algorithms and out of range
+// parameters.
+var ErrUnsupportedKDF = errors.New("unsupported kdf parameters")
+
+// Validate checks that p names a supported algorithm with usable costs.
+func (p KDFParams) Validate() error {
+	if p.Algorithm != KDFArgon2id ||
+		p.Time < 1 || p.Time > maxKDFTime ||
+		p.Threads < 1 ||
+		p.Memory < 8*uint32(p.Threads) || p.Memory > maxKDFMemory {
+		return ErrUnsupportedKDF
+	}
+	return nil
+}
+
+// Below reports whether p is weaker than policy: another algorithm, or a
+// lower time or memory cost. Parallelism does not add to an attacker's cost
+// and is not compared.
+func (p KDFParams) Below(policy KDFParams) bool {
+	return p.Algorithm != policy.Algorithm || p.Time < policy.Time || p.Memory < policy.Memory
+}
+
+// DeriveMasterKey derives the 32-byte master key from the password and salt
+// with the given KDF parameters. Returns ErrUnsupportedKDF for invalid
+// parameters.
+func DeriveMasterKey(password []byte, salt []byte, p KDFParams) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return argon2.IDKey(password, salt, p.Time, p.Memory, p.Threads, 32), nil
+}
+
+// HKDF info strings separating the sub-keys of the master key.
+const (
+	authKeyInfo = "gophkeeper auth key v1"
+	kekInfo     = "gophkeeper key-encryption key v1"
+)
+
+// DeriveAuthKey derives the login key from the master key. It is the only
+// key the SRP exchange and the offline verifier are computed from.
+func DeriveAuthKey(masterKey []byte) []byte {
+	return deriveSubKey(masterKey, authKeyInfo)
+}
+
+// DeriveKEK derives the key-encryption key that wraps the vault key from the
+// master key.
+func DeriveKEK(masterKey []byte) []byte {
+	return deriveSubKey(masterKey, kekInfo)
+}
+
+// NewVaultKey returns a random 32-byte vault key. Entries and file keys are
+// encrypted under it; it is stored only wrapped with the KEK (see WrapKey),
+// so that a password change only has to re-wrap it.
+func NewVaultKey() []byte {
+	return common.GenerateRandByteArray(32)
+}
+
+func deriveSubKey(masterKey []byte, info string) []byte {
+	key, err := hkdf.Key(sha256.New, masterKey, nil, info, 32)
+	if err != nil {
+		// hkdf.Key only fails for output lengths above 255 hash blocks.
+		panic(err)
+	}
+	return key
+}
+
+// Fields of an entry that are encrypted separately and bound into the
+// associated data of their ciphertexts (see EntryAAD).
+const (
+	FieldOverview = "overview"
+	FieldDetails  = "details"
+	// FieldFile names the attached file blob.
+	FieldFile = "file"
+)
+
+// aadVersion is the format version every associated data starts with. A new
+// ciphertext layout must use a new version, so that old ciphertexts cannot
+// be passed off as new ones.
+const aadVersion = "gophkeeper aad v1"
+
+// EntryAAD returns the AES-GCM associated data that binds a ciphertext to
+// the entry id and field it belongs to. Without it the server could move the
+// details of one entry into another, or swap an overview for a details blob,
+// and the client would decrypt them without complaint.
+func EntryAAD(entryID, field string) []byte {
+	return []byte(aadVersion + "\x00" + entryID + "\x00" + field)
 }
 
 // EncryptEntry serializes the given entry to JSON and encrypts it using AES-GCM.
@@ -32,6 +148,7 @@ func DeriveMasterKey(password []byte, salt []byte) []byte {
 // Parameters:
 //   - entry: any Go value that can be marshaled to JSON.
 //   - key: the AES encryption key.
+//   - aad: the associated data the ciphertext is bound to, see EntryAAD.
 //
 // Returns:
 //   - ciphertext: the encrypted JSON data.
@@ -52,14 +169,14 @@ func DeriveMasterKey(password []byte, salt []byte) []byte {
 //
 //	user := User{ID: 1, Name: "Alice"}
 //
-//	ciphertext, nonce, err := EncryptEntry(user, key)
+//	ciphertext, nonce, err := EncryptEntry(user, key, EntryAAD("id", FieldDetails))
 //	if err != nil {
 //	    log.Fatal(err)
 //	}
 //
 //	fmt.Printf("Encrypted data: %x\n", ciphertext)
 //	fmt.Printf("Nonce: %x\n", nonce)
-func EncryptEntry(entry any, key []byte) (ciphertext, nonce []byte, err error) {
+func EncryptEntry(entry any, key, aad []byte) (ciphertext, nonce []byte, err error) {
 
 	// serializing JSON
 	plaintext, err := json.Marshal(entry)
@@ -85,7 +202,7 @@ func EncryptEntry(entry any, key []byte) (ciphertext, nonce []byte, err error) {
 	}
 
 	// encrypting
-	ciphertext = aesgcm.Seal(nil, nonce, plaintext, nil)
+	ciphertext = aesgcm.Seal(nil, nonce, plaintext, aad)
 
 	return ciphertext, nonce, nil
 }
@@ -96,13 +213,22 @@ func EncryptEntry(entry any, key []byte) (ciphertext, nonce []byte, err error) {
 // The key must be the same AES key that was used to encrypt the data,
 // and the nonce must be the same 12-byte nonce generated during encryption.
 //
+// Ciphertexts written before entries were bound to their identity carry no
+// associated data. They are accepted, with legacy set, only if allowLegacy
+// is set, so that the caller can re-seal them under aad. Once an entry has
+// been re-sealed, the caller must clear allowLegacy: an unbound ciphertext
+// can be passed off as any entry.
+//
 // Parameters:
 //   - ciphertext: the encrypted data produced by EncryptEntry.
 //   - nonce: the 12-byte nonce generated during encryption.
 //   - key: the AES encryption key (must be 16, 24, or 32 bytes).
+//   - aad: the associated data passed to EncryptEntry.
+//   - allowLegacy: whether a ciphertext without associated data is accepted.
 //   - v: a pointer to the Go value into which the decrypted JSON will be unmarshaled.
 //
 // Returns:
+//   - legacy: true if the ciphertext is not bound to any associated data.
 //   - error: non-nil if decryption or JSON unmarshaling fails.
 //
 // Example:
@@ -114,47 +240,62 @@ func EncryptEntry(entry any, key []byte) (ciphertext, nonce []byte, err error) {
 //
 //	// Assume ciphertext, nonce, and key were obtained from EncryptEntry
 //	var user User
-//	err := DecryptEntry(ciphertext, nonce, key, &user)
+//	_, err := DecryptEntry(ciphertext, nonce, key, EntryAAD("id", FieldDetails), false, &user)
 //	if err != nil {
 //	    log.Fatal(err)
 //	}
 //
 //	fmt.Printf("Decrypted user: %+v\n", user)
-func DecryptEntry(ciphertext, nonce, key []byte, v any) error {
+func DecryptEntry(ciphertext, nonce, key, aad []byte, allowLegacy bool, v any) (legacy bool, err error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
-		return err
+		return false, err
 	}
 	aesgcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	plaintext, err := aesgcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, legacy, err := openWithAAD(aesgcm, nonce, ciphertext, aad, allowLegacy)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return json.Unmarshal(plaintext, v)
+	return legacy, json.Unmarshal(plaintext, v)
 }
 
-type EncryptedFile struct {
-	Cyphertext []byte
-	Key        []byte
-	Nonce      []byte
+// openWithAAD opens ciphertext bound to aad and, if allowLegacy is set,
+// falls back to the unbound legacy format, reporting which one matched.
+func openWithAAD(aesgcm cipher.AEAD, nonce, ciphertext, aad []byte, allowLegacy bool) (plaintext []byte, legacy bool, err error) {
+	plaintext, err = aesgcm.Open(nil, nonce, ciphertext, aad)
+	if err == nil || !allowLegacy {
+		return plaintext, false, err
+	}
+	if legacyPlaintext, legacyErr := aesgcm.Open(nil, nonce, ciphertext, nil); legacyErr == nil {
+		return legacyPlaintext, true, nil
+	}
+	return nil, false, err
 }
 
-func EncryptFile(path string) (*EncryptedFile, error) {
-	// reading the file
-	plaintext, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
+// wrappedKeyVersion is the first byte of every key sealed by WrapKey.
+const wrappedKeyVersion byte = 1
 
-	key := common.GenerateRandByteArray(32)
+// wrappedKeySize is the length of a wrapped 32-byte key:
+// version || 12-byte nonce || sealed key || 16-byte GCM tag.
+const wrappedKeySize = 1 + 12 + 32 + 16
 
-	// creating AES-GCM
-	block, err := aes.NewCipher(key)
+// ErrInvalidWrappedKey is returned by UnwrapKey for input that was not
+// produced by WrapKey.
+var ErrInvalidWrappedKey = errors.New("invalid wrapped key")
+
+// WrapKey seals a 32-byte key (a per-file key or the vault key) with the
+// key-encryption key kek using AES-GCM, so that it can be stored and synced
+// without revealing what it protects. The result is self-contained (version, nonce, ciphertext).
+func WrapKey(key, kek []byte) ([]byte, error) {
+	if len(key) != 32 {
+		return nil, errors.New("invalid key length: expected 32 bytes")
+	}
+	block, err := aes.NewCipher(kek)
 	if err != nil {
 		return nil, err
 	}
@@ -163,21 +304,18 @@ func EncryptFile(path string) (*EncryptedFile, error) {
 		return nil, err
 	}
 
-	nonce := common.GenerateRandByteArray(aesgcm.NonceSize())
-
-	ciphertext := aesgcm.Seal(nil, nonce, plaintext, nil)
-
-	return &EncryptedFile{Cyphertext: ciphertext, Key: key, Nonce: nonce}, nil
+	out := make([]byte, 1, wrappedKeySize)
+	out[0] = wrappedKeyVersion
+	out = append(out, common.GenerateRandByteArray(aesgcm.NonceSize())...)
+	return aesgcm.Seal(out, out[1:], key, nil), nil
 }
 
-func DecryptFile(ef *EncryptedFile) ([]byte, error) {
-	if ef == nil {
-		return nil, errors.New("nil EncryptedFile")
+// UnwrapKey opens a key sealed by WrapKey with the same kek.
+func UnwrapKey(wrapped, kek []byte) ([]byte, error) {
+	if !IsWrappedKey(wrapped) {
+		return nil, ErrInvalidWrappedKey
 	}
-	if len(ef.Key) != 32 {
-		return nil, errors.New("invalid key length: expected 32 bytes")
-	}
-	block, err := aes.NewCipher(ef.Key)
+	block, err := aes.NewCipher(kek)
 	if err != nil {
 		return nil, err
 	}
@@ -185,21 +323,12 @@ func DecryptFile(ef *EncryptedFile) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	if len(ef.Nonce) != aesgcm.NonceSize() {
-		return nil, errors.New("invalid nonce length")
-	}
-
-	plaintext, err := aesgcm.Open(nil, ef.Nonce, ef.Cyphertext, nil)
-	if err != nil {
-		return nil, err
-	}
-	return plaintext, nil
+	nonce := wrapped[1 : 1+aesgcm.NonceSize()]
+	return aesgcm.Open(nil, nonce, wrapped[1+aesgcm.NonceSize():], nil)
 }
 
-func DecryptFileTo(outPath string, ef *EncryptedFile) error {
-	pt, err := DecryptFile(ef)
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(outPath, pt, 0o660)
+// IsWrappedKey reports whether b looks like the output of WrapKey rather
+// than a raw (legacy, unwrapped) 32-byte file key.
+func IsWrappedKey(b []byte) bool {
+	return len(b) == wrappedKeySize && b[0] == wrappedKeyVersion
 }
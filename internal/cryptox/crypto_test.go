@@ -3,6 +3,7 @@ package cryptox
 import (
 	"bytes"
 	"encoding/hex"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
@@ -28,8 +29,14 @@ func must[T any](t *testing.T, v T, err error) T {
 func TestDeriveMasterKey_DeterministicAndLength(t *testing.T) {
 	pass := []byte("secret")
 	salt := []byte("salty-salt")
-	k1 := DeriveMasterKey(pass, salt)
-	k2 := DeriveMasterKey(pass, salt)
+	k1, err := DeriveMasterKey(pass, salt, LegacyKDFParams)
+	if err != nil {
+		t.Fatalf("DeriveMasterKey: %v", err)
+	}
+	k2, err := DeriveMasterKey(pass, salt, LegacyKDFParams)
+	if err != nil {
+		t.Fatalf("DeriveMasterKey: %v", err)
+	}
 
 	if !bytes.Equal(k1, k2) {
 		t.Fatalf("expected deterministic output for same inputs")
@@ -39,6 +46,54 @@ func TestDeriveMasterKey_DeterministicAndLength(t *testing.T) {
 	}
 }
 
+func TestDeriveMasterKey_UsesParams(t *testing.T) {
+	pass, salt := []byte("secret"), []byte("salty-salt")
+	legacy, err := DeriveMasterKey(pass, salt, LegacyKDFParams)
+	if err != nil {
+		t.Fatalf("DeriveMasterKey: %v", err)
+	}
+	current, err := DeriveMasterKey(pass, salt, DefaultKDFParams)
+	if err != nil {
+		t.Fatalf("DeriveMasterKey: %v", err)
+	}
+	if bytes.Equal(legacy, current) {
+		t.Fatalf("different parameters must give different keys")
+	}
+
+	bad := []KDFParams{
+		{Algorithm: "scrypt", Time: 1, Memory: 64 * 1024, Threads: 4},
+		{Algorithm: KDFArgon2id, Time: 0, Memory: 64 * 1024, Threads: 4},
+		{Algorithm: KDFArgon2id, Time: 1, Memory: 16, Threads: 4},
+		{Algorithm: KDFArgon2id, Time: 1, Memory: 64 * 1024, Threads: 0},
+		{Algorithm: KDFArgon2id, Time: 1, Memory: maxKDFMemory + 1, Threads: 4},
+	}
+	for _, p := range bad {
+		if _, err := DeriveMasterKey(pass, salt, p); err != ErrUnsupportedKDF {
+			t.Fatalf("%+v: want ErrUnsupportedKDF, got %v", p, err)
+		}
+	}
+}
+
+func TestKDFParams_Below(t *testing.T) {
+	if !LegacyKDFParams.Below(DefaultKDFParams) {
+		t.Fatalf("legacy parameters must be below the default policy")
+	}
+	if DefaultKDFParams.Below(DefaultKDFParams) {
+		t.Fatalf("parameters are not below themselves")
+	}
+	stronger := DefaultKDFParams
+	stronger.Memory *= 2
+	stronger.Threads = 1
+	if stronger.Below(DefaultKDFParams) {
+		t.Fatalf("more memory and fewer threads is not below policy")
+	}
+	other := DefaultKDFParams
+	other.Algorithm = "scrypt"
+	if !other.Below(DefaultKDFParams) {
+		t.Fatalf("another algorithm is below policy")
+	}
+}
+
 func TestMakeVerifier_ChangesWhenKeyChanges(t *testing.T) {
 	k1 := bytes.Repeat([]byte{1}, 32)
 	k2 := bytes.Repeat([]byte{2}, 32)
@@ -52,13 +107,50 @@ func TestMakeVerifier_ChangesWhenKeyChanges(t *testing.T) {
 	}
 }
 
+// ---------- DeriveAuthKey / DeriveKEK / NewVaultKey ----------
+
+func TestSubKeys_IndependentAndDeterministic(t *testing.T) {
+	mk := bytes.Repeat([]byte{7}, 32)
+
+	auth, kek := DeriveAuthKey(mk), DeriveKEK(mk)
+	if len(auth) != 32 || len(kek) != 32 {
+		t.Fatalf("unexpected sub-key lengths %d/%d", len(auth), len(kek))
+	}
+	if bytes.Equal(auth, kek) || bytes.Equal(auth, mk) || bytes.Equal(kek, mk) {
+		t.Fatalf("sub-keys must differ from each other and from the master key")
+	}
+	if !bytes.Equal(auth, DeriveAuthKey(mk)) || !bytes.Equal(kek, DeriveKEK(mk)) {
+		t.Fatalf("expected deterministic sub-keys")
+	}
+}
+
+func TestVaultKey_WrapsUnderKEK(t *testing.T) {
+	vk := NewVaultKey()
+	if len(vk) != 32 || bytes.Equal(vk, NewVaultKey()) {
+		t.Fatalf("vault keys must be random 32-byte keys")
+	}
+
+	kek := DeriveKEK(bytes.Repeat([]byte{7}, 32))
+	wrapped, err := WrapKey(vk, kek)
+	if err != nil {
+		t.Fatalf("WrapKey: %v", err)
+	}
+	got, err := UnwrapKey(wrapped, kek)
+	if err != nil || !bytes.Equal(got, vk) {
+		t.Fatalf("vault key round trip mismatch: %v", err)
+	}
+	if _, err := UnwrapKey(wrapped, DeriveAuthKey(bytes.Repeat([]byte{7}, 32))); err == nil {
+		t.Fatalf("the auth key must not open the vault key")
+	}
+}
+
 // ---------- EncryptEntry / DecryptEntry ----------
 
 func TestEncryptDecryptEntry_Success(t *testing.T) {
 	key := bytes.Repeat([]byte{7}, 32)
 	in := sample{ID: 42, Name: "Alice"}
 
-	ct, nonce, err := EncryptEntry(in, key)
+	ct, nonce, err := EncryptEntry(in, key, EntryAAD("e1", FieldDetails))
 	if err != nil {
 		t.Fatalf("EncryptEntry error: %v", err)
 	}
@@ -66,9 +158,13 @@ func TestEncryptDecryptEntry_Success(t *testing.T) {
 		t.Fatalf("expected GCM nonce size 12, got %d", len(nonce))
 	}
 	var out sample
-	if err := DecryptEntry(ct, nonce, key, &out); err != nil {
+	legacy, err := DecryptEntry(ct, nonce, key, EntryAAD("e1", FieldDetails), false, &out)
+	if err != nil {
 		t.Fatalf("DecryptEntry error: %v", err)
 	}
+	if legacy {
+		t.Fatalf("bound ciphertext reported as legacy")
+	}
 	if out != in {
 		t.Fatalf("roundtrip mismatch: got %+v, want %+v", out, in)
 	}
@@ -76,7 +172,7 @@ func TestEncryptDecryptEntry_Success(t *testing.T) {
 
 func TestEncryptEntry_InvalidKeyLength(t *testing.T) {
 	key := []byte("tooshort") // 8 bytes
-	_, _, err := EncryptEntry(sample{}, key)
+	_, _, err := EncryptEntry(sample{}, key, nil)
 	if err == nil {
 		t.Fatalf("expected error for invalid AES key length")
 	}
@@ -85,12 +181,12 @@ func TestEncryptEntry_InvalidKeyLength(t *testing.T) {
 func TestDecryptEntry_WrongKeyReturnsError(t *testing.T) {
 	key := bytes.Repeat([]byte{3}, 32)
 	badKey := bytes.Repeat([]byte{4}, 32)
-	ct, nonce, err := EncryptEntry(sample{ID: 1}, key)
+	ct, nonce, err := EncryptEntry(sample{ID: 1}, key, EntryAAD("e1", FieldDetails))
 	if err != nil {
 		t.Fatalf("EncryptEntry err: %v", err)
 	}
 	var out sample
-	err = DecryptEntry(ct, nonce, badKey, &out)
+	_, err = DecryptEntry(ct, nonce, badKey, EntryAAD("e1", FieldDetails), false, &out)
 	if err == nil {
 		t.Fatalf("expected auth error with wrong key")
 	}
@@ -98,18 +194,55 @@ func TestDecryptEntry_WrongKeyReturnsError(t *testing.T) {
 
 func TestDecryptEntry_TamperedCiphertextReturnsError(t *testing.T) {
 	key := bytes.Repeat([]byte{5}, 32)
-	ct, nonce, err := EncryptEntry(sample{Name: "X"}, key)
+	ct, nonce, err := EncryptEntry(sample{Name: "X"}, key, EntryAAD("e1", FieldDetails))
 	if err != nil {
 		t.Fatalf("EncryptEntry err: %v", err)
 	}
 	ct[0] ^= 0xFF
 	var out sample
-	if err := DecryptEntry(ct, nonce, key, &out); err == nil {
+	if _, err := DecryptEntry(ct, nonce, key, EntryAAD("e1", FieldDetails), false, &out); err == nil {
 		t.Fatalf("expected error for tampered ciphertext")
 	}
 }
 
-// ---------- EncryptFile / DecryptFile / DecryptFileTo ----------
+func TestDecryptEntry_RejectsOtherEntryOrField(t *testing.T) {
+	key := bytes.Repeat([]byte{5}, 32)
+	ct, nonce, err := EncryptEntry(sample{Name: "X"}, key, EntryAAD("e1", FieldDetails))
+	if err != nil {
+		t.Fatalf("EncryptEntry err: %v", err)
+	}
+	var out sample
+	if _, err := DecryptEntry(ct, nonce, key, EntryAAD("e2", FieldDetails), false, &out); err == nil {
+		t.Fatalf("details of e1 accepted as details of e2")
+	}
+	if _, err := DecryptEntry(ct, nonce, key, EntryAAD("e1", FieldOverview), false, &out); err == nil {
+		t.Fatalf("details accepted as overview")
+	}
+}
+
+func TestDecryptEntry_ReportsLegacyCiphertext(t *testing.T) {
+	key := bytes.Repeat([]byte{5}, 32)
+	in := sample{ID: 7, Name: "old"}
+	ct, nonce, err := EncryptEntry(in, key, nil)
+	if err != nil {
+		t.Fatalf("EncryptEntry err: %v", err)
+	}
+	var out sample
+	legacy, err := DecryptEntry(ct, nonce, key, EntryAAD("e1", FieldDetails), true, &out)
+	if err != nil {
+		t.Fatalf("DecryptEntry err: %v", err)
+	}
+	if !legacy || out != in {
+		t.Fatalf("legacy=%v out=%+v, want legacy copy of %+v", legacy, out, in)
+	}
+
+	// once the entry is re-sealed, an unbound ciphertext is rejected
+	if _, err := DecryptEntry(ct, nonce, key, EntryAAD("e1", FieldDetails), false, &out); err == nil {
+		t.Fatalf("legacy ciphertext accepted without allowLegacy")
+	}
+}
+
+// ---------- EncryptFile / DecryptFileTo ----------
 
 func TestEncryptDecryptFile_FullRoundtrip(t *testing.T) {
 	dir := t.TempDir()
@@ -119,27 +252,20 @@ func TestEncryptDecryptFile_FullRoundtrip(t *testing.T) {
 		t.Fatalf("write src: %v", err)
 	}
 
-	ef, err := EncryptFile(src)
+	var ct bytes.Buffer
+	key, nonce, err := EncryptFile(&ct, src, EntryAAD("e1", FieldFile))
 	if err != nil {
 		t.Fatalf("EncryptFile err: %v", err)
 	}
-	if len(ef.Key) != 32 {
-		t.Fatalf("expected file key length 32, got %d", len(ef.Key))
+	if len(key) != 32 {
+		t.Fatalf("expected file key length 32, got %d", len(key))
 	}
-	if len(ef.Nonce) == 0 || len(ef.Cyphertext) == 0 {
+	if len(nonce) == 0 || ct.Len() == 0 {
 		t.Fatalf("nonce/ciphertext should be non-empty")
 	}
 
-	got, err := DecryptFile(ef)
-	if err != nil {
-		t.Fatalf("DecryptFile err: %v", err)
-	}
-	if !bytes.Equal(got, want) {
-		t.Fatalf("file roundtrip mismatch: got %q, want %q", string(got), string(want))
-	}
-
 	dst := filepath.Join(dir, "out.txt")
-	if err := DecryptFileTo(dst, ef); err != nil {
+	if err := DecryptFileTo(dst, bytes.NewReader(ct.Bytes()), key, nonce, EntryAAD("e1", FieldFile), false); err != nil {
 		t.Fatalf("DecryptFileTo err: %v", err)
 	}
 	read, err := os.ReadFile(dst)
@@ -152,24 +278,18 @@ func TestEncryptDecryptFile_FullRoundtrip(t *testing.T) {
 }
 
 func TestEncryptFile_MissingPathReturnsError(t *testing.T) {
-	_, err := EncryptFile("no/such/file.bin")
+	_, _, err := EncryptFile(io.Discard, "no/such/file.bin", nil)
 	if err == nil {
 		t.Fatalf("expected error for missing file")
 	}
 }
 
 func TestDecryptFile_Errors(t *testing.T) {
-	// nil ef
-	if _, err := DecryptFile(nil); err == nil {
-		t.Fatalf("expected error for nil EncryptedFile")
-	}
 	// invalid key length
-	ef := &EncryptedFile{Cyphertext: []byte{1}, Key: []byte("short"), Nonce: []byte{1, 2, 3}}
-	if _, err := DecryptFile(ef); err == nil {
+	if _, err := NewDecryptWriter(io.Discard, []byte("short"), []byte{1, 2, 3}, nil, false); err == nil {
 		t.Fatalf("expected error for invalid key length")
 	}
-	ef = &EncryptedFile{Cyphertext: []byte{1}, Key: bytes.Repeat([]byte{9}, 32), Nonce: []byte{1, 2, 3}}
-	if _, err := DecryptFile(ef); err == nil {
+	if _, err := NewDecryptWriter(io.Discard, bytes.Repeat([]byte{9}, 32), []byte{1, 2, 3}, nil, false); err == nil {
 		t.Fatalf("expected error for invalid nonce length")
 	}
 }
@@ -181,22 +301,38 @@ func TestDecryptFile_TamperedReturnsError(t *testing.T) {
 	if err := os.WriteFile(src, orig, 0o600); err != nil {
 		t.Fatalf("write src: %v", err)
 	}
-	ef, err := EncryptFile(src)
+	var ct bytes.Buffer
+	key, nonce, err := EncryptFile(&ct, src, EntryAAD("e1", FieldFile))
 	if err != nil {
 		t.Fatalf("EncryptFile err: %v", err)
 	}
-	ef.Cyphertext[0] ^= 0xAA
-	if _, err := DecryptFile(ef); err == nil {
+	ct.Bytes()[0] ^= 0xAA
+	dst := filepath.Join(dir, "out.bin")
+	if err := DecryptFileTo(dst, &ct, key, nonce, EntryAAD("e1", FieldFile), false); err == nil {
 		t.Fatalf("expected error for tampered ciphertext")
 	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Fatalf("partial output left behind: %v", err)
+	}
+}
+
+func TestDecryptFile_BoundToEntry(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(src, []byte("payload"), 0o600); err != nil {
+		t.Fatalf("write src: %v", err)
+	}
+	var ct bytes.Buffer
+	key, nonce, err := EncryptFile(&ct, src, EntryAAD("e1", FieldFile))
+	if err != nil {
+		t.Fatalf("EncryptFile err: %v", err)
+	}
+	if err := DecryptFileTo(filepath.Join(t.TempDir(), "x"), &ct, key, nonce, EntryAAD("e2", FieldFile), false); err == nil {
+		t.Fatalf("blob of e1 accepted for e2")
+	}
 }
 
 func TestDecryptFileTo_PropagatesError(t *testing.T) {
-	err := DecryptFileTo(filepath.Join(t.TempDir(), "x"), &EncryptedFile{
-		Cyphertext: []byte{1},
-		Key:        []byte("bad"),
-		Nonce:      []byte{1},
-	})
+	err := DecryptFileTo(filepath.Join(t.TempDir(), "x"), bytes.NewReader([]byte{1}), []byte("bad"), []byte{1}, nil, false)
 	if err == nil {
 		t.Fatalf("expected error")
 	}
@@ -209,10 +345,51 @@ func TestDecryptFileTo_PropagatesError(t *testing.T) {
 
 func TestHexDocExampleSanity(t *testing.T) {
 	key := bytes.Repeat([]byte{1}, 32)
-	ct, nonce, err := EncryptEntry(sample{ID: 1}, key)
+	ct, nonce, err := EncryptEntry(sample{ID: 1}, key, nil)
 	if err != nil {
 		t.Fatalf("EncryptEntry err: %v", err)
 	}
 	_ = hex.EncodeToString(ct)
 	_ = hex.EncodeToString(nonce)
 }
+
+func TestWrapUnwrapKey(t *testing.T) {
+	kek := bytes.Repeat([]byte{7}, 32)
+	key := bytes.Repeat([]byte{1}, 32)
+
+	wrapped, err := WrapKey(key, kek)
+	if err != nil {
+		t.Fatalf("WrapKey: %v", err)
+	}
+	if !IsWrappedKey(wrapped) || IsWrappedKey(key) {
+		t.Fatalf("IsWrappedKey misclassified keys")
+	}
+	if bytes.Contains(wrapped, key) {
+		t.Fatalf("wrapped key leaks the raw key")
+	}
+	again, err := WrapKey(key, kek)
+	if err != nil {
+		t.Fatalf("WrapKey: %v", err)
+	}
+	if bytes.Equal(wrapped, again) {
+		t.Fatalf("wrapping must use a fresh nonce")
+	}
+
+	got, err := UnwrapKey(wrapped, kek)
+	if err != nil {
+		t.Fatalf("UnwrapKey: %v", err)
+	}
+	if !bytes.Equal(got, key) {
+		t.Fatalf("roundtrip mismatch")
+	}
+
+	if _, err := UnwrapKey(wrapped, bytes.Repeat([]byte{8}, 32)); err == nil {
+		t.Fatalf("expected error for wrong kek")
+	}
+	if _, err := UnwrapKey(key, kek); err != ErrInvalidWrappedKey {
+		t.Fatalf("expected ErrInvalidWrappedKey, got %v", err)
+	}
+	if _, err := WrapKey([]byte("short"), kek); err == nil {
+		t.Fatalf("expected error for short key")
+	}
+}
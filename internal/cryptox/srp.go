@@ -0,0 +1,255 @@
+package cryptox
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"math/big"
+)
+
+// SRP-6a (RFC 2945 / RFC 5054) over the 2048-bit group of RFC 5054 with
+// SHA-256. The client's password input is the Argon2 master key, so the
+// server only ever stores the verifier g^x and never sees a value that can be
+// replayed as a login credential.
+
+// srpGroupHex is the 2048-bit safe prime N of RFC 5054, appendix A.
+const srpGroupHex = "" +
+	"AC6BDB41324A9A9BF166DE5E1389582FAF72B6651987EE07FC3192943DB56050" +
+	"A37329CBB4A099ED8193E0757767A13DD52312AB4B03310DCD7F48A9DA04FD50" +
+	"E8083969EDB767B0CF6095179A163AB3661A05FBD5FAAAE82918A9962F0B93B8" +
+	"55F97993EC975EEAA80D740ADBF4FF747359D041D5C33EA71D281E446B14773B" +
+	"CA97B43A23FB801676BD207A436C6481F1D2B9078717461A5B9D32E688F87748" +
+	"544523B524B0D57D5EA77A2775D2ECFA032CFBDBF52FB3786160279004E57AE6" +
+	"AF874E7303CE53299CCC041C7BC308D82A5698F3A8D0C38271AE35F8E9DBFBB6" +
+	"94B5C803D89F7AE435DE236D525F54759B65E372FCD68EF20FA7111F9E4AFF73"
+
+var (
+	srpN, _ = new(big.Int).SetString(srpGroupHex, 16)
+	srpG    = big.NewInt(2)
+	// srpLen is the byte length of N and of every padded value.
+	srpLen = (srpN.BitLen() + 7) / 8
+	// srpK is the multiplier k = H(N | PAD(g)).
+	srpK = new(big.Int).SetBytes(srpHash(srpN.Bytes(), srpPad(srpG)))
+)
+
+// ErrSRPAuthFailed is returned when a peer's SRP values or proof are invalid.
+var ErrSRPAuthFailed = errors.New("srp authentication failed")
+
+// srpHash returns SHA-256 over the concatenation of parts.
+func srpHash(parts ...[]byte) []byte {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+// srpPad left-pads x with zeros to the byte length of N.
+func srpPad(x *big.Int) []byte {
+	return x.FillBytes(make([]byte, srpLen))
+}
+
+// srpX computes the private value x = H(salt | H(username ":" masterKey)).
+func srpX(username string, salt, masterKey []byte) *big.Int {
+	inner := srpHash([]byte(username), []byte(":"), masterKey)
+	return new(big.Int).SetBytes(srpHash(salt, inner))
+}
+
+// srpU computes the scrambling parameter u = H(PAD(A) | PAD(B)).
+func srpU(A, B *big.Int) *big.Int {
+	return new(big.Int).SetBytes(srpHash(srpPad(A), srpPad(B)))
+}
+
+// srpClientProof computes M1 = H(H(N) xor H(g) | H(username) | salt | A | B | K).
+func srpClientProof(username string, salt []byte, A, B *big.Int, K []byte) []byte {
+	hn := srpHash(srpN.Bytes())
+	hg := srpHash(srpG.Bytes())
+	for i := range hn {
+		hn[i] ^= hg[i]
+	}
+	return srpHash(hn, srpHash([]byte(username)), salt, srpPad(A), srpPad(B), K)
+}
+
+// srpServerProof computes M2 = H(A | M1 | K).
+func srpServerProof(A *big.Int, M1, K []byte) []byte {
+	return srpHash(srpPad(A), M1, K)
+}
+
+// srpRandom returns a random exponent of 256 bits.
+func srpRandom() (*big.Int, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// srpPublic parses a peer's public value. It must be padded to the length
+// of N and lie in [1, N-1]; anything else is rejected before any arithmetic,
+// as a larger value would not fit srpPad.
+func srpPublic(b []byte) (*big.Int, bool) {
+	if len(b) != srpLen {
+		return nil, false
+	}
+	x := new(big.Int).SetBytes(b)
+	if x.Sign() == 0 || x.Cmp(srpN) >= 0 {
+		return nil, false
+	}
+	return x, true
+}
+
+// ValidSRPPublicKey reports whether b is a well-formed SRP public value, so
+// that a server can refuse a login before storing it.
+func ValidSRPPublicKey(b []byte) bool {
+	_, ok := srpPublic(b)
+	return ok
+}
+
+// SRPVerifier returns the verifier v = g^x mod N the server stores for the
+// account instead of any password-equivalent value.
+func SRPVerifier(username string, salt, masterKey []byte) []byte {
+	x := srpX(username, salt, masterKey)
+	return srpPad(new(big.Int).Exp(srpG, x, srpN))
+}
+
+// SRPClient holds the client side of one SRP-6a login.
+type SRPClient struct {
+	username string
+	salt     []byte
+	x        *big.Int
+	a        *big.Int
+	A        *big.Int
+	// M1 and K are set by Proof.
+	m1 []byte
+	k  []byte
+}
+
+// NewSRPClient starts a login for username with the given salt and master
+// key and generates the client's ephemeral key pair.
+func NewSRPClient(username string, salt, masterKey []byte) (*SRPClient, error) {
+	a, err := srpRandom()
+	if err != nil {
+		return nil, err
+	}
+	return &SRPClient{
+		username: username,
+		salt:     salt,
+		x:        srpX(username, salt, masterKey),
+		a:        a,
+		A:        new(big.Int).Exp(srpG, a, srpN),
+	}, nil
+}
+
+// PublicKey returns the client's public value A, sent with LoginStart.
+func (c *SRPClient) PublicKey() []byte {
+	return srpPad(c.A)
+}
+
+// Proof derives the session key from the server's public value B and
+// returns the client proof M1, sent with LoginFinish.
+func (c *SRPClient) Proof(serverPublic []byte) ([]byte, error) {
+	B, ok := srpPublic(serverPublic)
+	if !ok {
+		return nil, ErrSRPAuthFailed
+	}
+	u := srpU(c.A, B)
+	if u.Sign() == 0 {
+		return nil, ErrSRPAuthFailed
+	}
+
+	// S = (B - k*g^x) ^ (a + u*x) mod N
+	kgx := new(big.Int).Mul(srpK, new(big.Int).Exp(srpG, c.x, srpN))
+	base := new(big.Int).Sub(B, kgx)
+	base.Mod(base, srpN)
+	exp := new(big.Int).Add(c.a, new(big.Int).Mul(u, c.x))
+	S := new(big.Int).Exp(base, exp, srpN)
+
+	c.k = srpHash(srpPad(S))
+	c.m1 = srpClientProof(c.username, c.salt, c.A, B, c.k)
+	return c.m1, nil
+}
+
+// VerifyServer checks the server proof M2 returned by LoginFinish, proving
+// that the server knows the verifier. Proof must be called first.
+func (c *SRPClient) VerifyServer(serverProof []byte) error {
+	if c.m1 == nil {
+		return ErrSRPAuthFailed
+	}
+	want := srpServerProof(c.A, c.m1, c.k)
+	if subtle.ConstantTimeCompare(want, serverProof) != 1 {
+		return ErrSRPAuthFailed
+	}
+	return nil
+}
+
+// SRPServer holds the server side of one SRP-6a login. Its state between
+// LoginStart and LoginFinish is the secret returned by Secret, which must be
+// kept server-side and used at most once.
+type SRPServer struct {
+	username string
+	salt     []byte
+	v        *big.Int
+	b        *big.Int
+	B        *big.Int
+}
+
+// NewSRPServer starts a login against the stored salt and verifier and
+// generates the server's ephemeral key pair.
+func NewSRPServer(username string, salt, verifier []byte) (*SRPServer, error) {
+	b, err := srpRandom()
+	if err != nil {
+		return nil, err
+	}
+	return RestoreSRPServer(username, salt, verifier, srpPad(b)), nil
+}
+
+// RestoreSRPServer rebuilds the server side of a login from the secret of
+// an SRPServer created by NewSRPServer.
+func RestoreSRPServer(username string, salt, verifier, secret []byte) *SRPServer {
+	v := new(big.Int).SetBytes(verifier)
+	b := new(big.Int).SetBytes(secret)
+
+	// B = (k*v + g^b) mod N
+	B := new(big.Int).Mul(srpK, v)
+	B.Add(B, new(big.Int).Exp(srpG, b, srpN))
+	B.Mod(B, srpN)
+
+	return &SRPServer{username: username, salt: salt, v: v, b: b, B: B}
+}
+
+// PublicKey returns the server's public value B, sent back by LoginStart.
+func (s *SRPServer) PublicKey() []byte {
+	return srpPad(s.B)
+}
+
+// Secret returns the server's ephemeral private value b.
+func (s *SRPServer) Secret() []byte {
+	return srpPad(s.b)
+}
+
+// VerifyClient checks the client's public value A and proof M1 and, if the
+// client knows the password, returns the server proof M2. It returns
+// ErrSRPAuthFailed otherwise.
+func (s *SRPServer) VerifyClient(clientPublic, clientProof []byte) ([]byte, error) {
+	A, ok := srpPublic(clientPublic)
+	if !ok {
+		return nil, ErrSRPAuthFailed
+	}
+	u := srpU(A, s.B)
+	if u.Sign() == 0 {
+		return nil, ErrSRPAuthFailed
+	}
+
+	// S = (A * v^u) ^ b mod N
+	base := new(big.Int).Mul(A, new(big.Int).Exp(s.v, u, srpN))
+	base.Mod(base, srpN)
+	S := new(big.Int).Exp(base, s.b, srpN)
+
+	K := srpHash(srpPad(S))
+	want := srpClientProof(s.username, s.salt, A, s.B, K)
+	if subtle.ConstantTimeCompare(want, clientProof) != 1 {
+		return nil, ErrSRPAuthFailed
+	}
+	return srpServerProof(A, clientProof, K), nil
+}
@@ -0,0 +1,135 @@
+package cryptox
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestSRPGroup_IsSafePrime(t *testing.T) {
+	if srpN.BitLen() != 2048 || !srpN.ProbablyPrime(32) {
+		t.Fatalf("N is not a 2048-bit prime")
+	}
+	q := new(big.Int).Rsh(srpN, 1)
+	if !q.ProbablyPrime(32) {
+		t.Fatalf("(N-1)/2 is not prime")
+	}
+}
+
+func srpLogin(t *testing.T, clientKey, serverKey []byte) ([]byte, error) {
+	t.Helper()
+	salt := []byte("salt")
+	v := SRPVerifier("alice", salt, serverKey)
+
+	c, err := NewSRPClient("alice", salt, clientKey)
+	if err != nil {
+		t.Fatalf("NewSRPClient: %v", err)
+	}
+	s, err := NewSRPServer("alice", salt, v)
+	if err != nil {
+		t.Fatalf("NewSRPServer: %v", err)
+	}
+	// LoginFinish is served from the stored secret only.
+	s = RestoreSRPServer("alice", salt, v, s.Secret())
+
+	m1, err := c.Proof(s.PublicKey())
+	if err != nil {
+		t.Fatalf("Proof: %v", err)
+	}
+	m2, err := s.VerifyClient(c.PublicKey(), m1)
+	if err != nil {
+		return nil, err
+	}
+	return m2, c.VerifyServer(m2)
+}
+
+func TestSRP_RoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	if _, err := srpLogin(t, key, key); err != nil {
+		t.Fatalf("login with the right key failed: %v", err)
+	}
+}
+
+func TestSRP_WrongPasswordRejected(t *testing.T) {
+	_, err := srpLogin(t, bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32))
+	if !errors.Is(err, ErrSRPAuthFailed) {
+		t.Fatalf("want ErrSRPAuthFailed, got %v", err)
+	}
+}
+
+func TestSRP_RejectsZeroPublicValues(t *testing.T) {
+	salt := []byte("salt")
+	key := bytes.Repeat([]byte{1}, 32)
+
+	s, err := NewSRPServer("alice", salt, SRPVerifier("alice", salt, key))
+	if err != nil {
+		t.Fatalf("NewSRPServer: %v", err)
+	}
+	if _, err := s.VerifyClient(srpPad(srpN), []byte("m1")); !errors.Is(err, ErrSRPAuthFailed) {
+		t.Fatalf("A = N accepted: %v", err)
+	}
+
+	c, err := NewSRPClient("alice", salt, key)
+	if err != nil {
+		t.Fatalf("NewSRPClient: %v", err)
+	}
+	if _, err := c.Proof(make([]byte, 256)); !errors.Is(err, ErrSRPAuthFailed) {
+		t.Fatalf("B = 0 accepted: %v", err)
+	}
+	if err := c.VerifyServer([]byte("m2")); !errors.Is(err, ErrSRPAuthFailed) {
+		t.Fatalf("server proof accepted before Proof: %v", err)
+	}
+}
+
+func TestSRP_RejectsMalformedPublicValues(t *testing.T) {
+	salt := []byte("salt")
+	key := bytes.Repeat([]byte{1}, 32)
+	oversized := append([]byte{1}, make([]byte, 256)...)
+
+	s, err := NewSRPServer("alice", salt, SRPVerifier("alice", salt, key))
+	if err != nil {
+		t.Fatalf("NewSRPServer: %v", err)
+	}
+	c, err := NewSRPClient("alice", salt, key)
+	if err != nil {
+		t.Fatalf("NewSRPClient: %v", err)
+	}
+	for name, v := range map[string][]byte{
+		"oversized": oversized,
+		"short":     []byte{2},
+		"N + 1":     new(big.Int).Add(srpN, big.NewInt(1)).FillBytes(make([]byte, 256)),
+	} {
+		if ValidSRPPublicKey(v) {
+			t.Fatalf("%s: reported valid", name)
+		}
+		if _, err := s.VerifyClient(v, []byte("m1")); !errors.Is(err, ErrSRPAuthFailed) {
+			t.Fatalf("%s A accepted: %v", name, err)
+		}
+		if _, err := c.Proof(v); !errors.Is(err, ErrSRPAuthFailed) {
+			t.Fatalf("%s B accepted: %v", name, err)
+		}
+	}
+	if !ValidSRPPublicKey(c.PublicKey()) || !ValidSRPPublicKey(s.PublicKey()) {
+		t.Fatalf("genuine public values reported invalid")
+	}
+}
+
+func TestSRP_ServerProofBindsSession(t *testing.T) {
+	salt := []byte("salt")
+	key := bytes.Repeat([]byte{1}, 32)
+	v := SRPVerifier("alice", salt, key)
+
+	c, _ := NewSRPClient("alice", salt, key)
+	s1, _ := NewSRPServer("alice", salt, v)
+	s2, _ := NewSRPServer("alice", salt, v)
+
+	m1, err := c.Proof(s1.PublicKey())
+	if err != nil {
+		t.Fatalf("Proof: %v", err)
+	}
+	// a proof for one server session does not verify against another
+	if _, err := s2.VerifyClient(c.PublicKey(), m1); !errors.Is(err, ErrSRPAuthFailed) {
+		t.Fatalf("proof replayed across sessions: %v", err)
+	}
+}
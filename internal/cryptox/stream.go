@@ -0,0 +1,355 @@
+package cryptox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/common"
+)
+
+// Files are encrypted as a stream of chunks, so that neither encryption nor
+// decryption has to hold a whole file in memory. Following the STREAM
+// construction, every chunk of up to streamChunkSize plaintext bytes is
+// sealed with AES-GCM under the per-file key and the nonce
+//
+//	prefix || uint32 chunk index || last-chunk flag
+//
+// where the 7-byte prefix is random per file. Reordered, dropped or appended
+// chunks and a stream cut off at a chunk boundary all fail authentication.
+// Every chunk is also bound to the associated data of the file (see
+// EntryAAD).
+
+const (
+	// streamChunkSize is the plaintext size of every chunk but the last.
+	streamChunkSize = 64 * 1024
+	// streamNonceVersion is the first byte of the nonce of a streamed file.
+	streamNonceVersion byte = 1
+	// streamPrefixSize is the length of the random per-file nonce prefix.
+	streamPrefixSize = 7
+	// streamNonceSize is the length of the stored nonce of a streamed file:
+	// version || prefix. Files encrypted before streaming was introduced
+	// have a 12-byte GCM nonce instead.
+	streamNonceSize = 1 + streamPrefixSize
+)
+
+// ErrStreamTooLong is returned when a stream exceeds 2^32 chunks.
+var ErrStreamTooLong = errors.New("encrypted stream too long")
+
+// ErrCorrupt is returned for an encrypted file whose ciphertext does not
+// match its recorded digest or fails authentication: it was damaged, cut
+// short or tampered with, or was not encrypted under the given key.
+var ErrCorrupt = errors.New("encrypted file is corrupt")
+
+// isStreamNonce reports whether nonce belongs to a streamed file rather than
+// to a legacy file sealed in one piece.
+func isStreamNonce(nonce []byte) bool {
+	return len(nonce) == streamNonceSize && nonce[0] == streamNonceVersion
+}
+
+// streamCipher derives the per-chunk nonces of one stream.
+type streamCipher struct {
+	aead  cipher.AEAD
+	aad   []byte
+	nonce [12]byte
+	index uint64
+}
+
+func newStreamCipher(key, nonce, aad []byte) (*streamCipher, error) {
+	aead, err := newFileAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if !isStreamNonce(nonce) {
+		return nil, errors.New("invalid nonce length")
+	}
+	c := &streamCipher{aead: aead, aad: aad}
+	copy(c.nonce[:], nonce[1:])
+	return c, nil
+}
+
+// next returns the nonce of the next chunk.
+func (c *streamCipher) next(last bool) ([]byte, error) {
+	if c.index > 0xFFFFFFFF {
+		return nil, ErrStreamTooLong
+	}
+	binary.BigEndian.PutUint32(c.nonce[streamPrefixSize:], uint32(c.index))
+	c.nonce[11] = 0
+	if last {
+		c.nonce[11] = 1
+	}
+	c.index++
+	return c.nonce[:], nil
+}
+
+func newFileAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, errors.New("invalid key length: expected 32 bytes")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptWriter seals the plaintext written to it chunk by chunk into dst.
+type encryptWriter struct {
+	dst io.Writer
+	c   *streamCipher
+	buf []byte
+	out []byte
+	err error
+}
+
+// NewEncryptWriter returns a writer that encrypts everything written to it
+// under key into dst, bound to aad, and the nonce to store with the file.
+// Close must be called to write the final chunk; it does not close dst.
+func NewEncryptWriter(dst io.Writer, key, aad []byte) (io.WriteCloser, []byte, error) {
+	nonce := append([]byte{streamNonceVersion}, common.GenerateRandByteArray(streamPrefixSize)...)
+	c, err := newStreamCipher(key, nonce, aad)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &encryptWriter{dst: dst, c: c, buf: make([]byte, 0, streamChunkSize)}, nonce, nil
+}
+
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := 0
+	for len(p) > 0 {
+		// a full chunk is sealed only once more data follows, since the
+		// last chunk must carry the last-chunk flag
+		if len(w.buf) == streamChunkSize {
+			if w.err = w.seal(false); w.err != nil {
+				return n, w.err
+			}
+		}
+		k := copy(w.buf[len(w.buf):streamChunkSize], p)
+		w.buf = w.buf[:len(w.buf)+k]
+		p = p[k:]
+		n += k
+	}
+	return n, nil
+}
+
+// Close seals the buffered remainder as the last chunk.
+func (w *encryptWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.err = w.seal(true); w.err != nil {
+		return w.err
+	}
+	w.err = errors.New("write to closed encrypt writer")
+	return nil
+}
+
+func (w *encryptWriter) seal(last bool) error {
+	nonce, err := w.c.next(last)
+	if err != nil {
+		return err
+	}
+	w.out = w.c.aead.Seal(w.out[:0], nonce, w.buf, w.c.aad)
+	w.buf = w.buf[:0]
+	_, err = w.dst.Write(w.out)
+	return err
+}
+
+// decryptWriter opens the ciphertext written to it chunk by chunk into dst.
+type decryptWriter struct {
+	dst io.Writer
+	c   *streamCipher
+	buf []byte
+	out []byte
+	err error
+
+	// legacy files are sealed in one piece and opened on Close
+	legacy      cipher.AEAD
+	legacyNonce []byte
+	aad         []byte
+	unbound     bool
+}
+
+// NewDecryptWriter returns a writer that decrypts a file written to it by
+// NewEncryptWriter with the same key, nonce and aad, and writes the
+// plaintext to dst. Chunks that fail authentication are reported as
+// ErrCorrupt, and Close reports a truncated stream; it does not close dst.
+//
+// Chunks are written to dst as soon as they authenticate, so dst may receive
+// the start of a file that later turns out to be corrupt. Use DecryptFileTo
+// to get all or nothing.
+//
+// Files encrypted in one piece before streaming was introduced are accepted
+// too; they are buffered whole. Such a file that is not bound to aad is
+// accepted only if allowUnbound is set (see DecryptEntry).
+func NewDecryptWriter(dst io.Writer, key, nonce, aad []byte, allowUnbound bool) (io.WriteCloser, error) {
+	if !isStreamNonce(nonce) {
+		aead, err := newFileAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(nonce) != aead.NonceSize() {
+			return nil, errors.New("invalid nonce length")
+		}
+		return &decryptWriter{dst: dst, legacy: aead, legacyNonce: nonce, aad: aad, unbound: allowUnbound}, nil
+	}
+	c, err := newStreamCipher(key, nonce, aad)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptWriter{dst: dst, c: c, buf: make([]byte, 0, streamChunkSize+c.aead.Overhead())}, nil
+}
+
+func (w *decryptWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.legacy != nil {
+		w.buf = append(w.buf, p...)
+		return len(p), nil
+	}
+	n := 0
+	for len(p) > 0 {
+		if len(w.buf) == cap(w.buf) {
+			if w.err = w.open(false); w.err != nil {
+				return n, w.err
+			}
+		}
+		k := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+k]
+		p = p[k:]
+		n += k
+	}
+	return n, nil
+}
+
+// Close opens the buffered remainder as the last chunk. A stream that ends
+// early fails here with ErrCorrupt, since its final chunk lacks the
+// last-chunk flag.
+func (w *decryptWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.legacy != nil {
+		plaintext, _, err := openWithAAD(w.legacy, w.legacyNonce, w.buf, w.aad, w.unbound)
+		if err != nil {
+			w.err = fmt.Errorf("%w: %v", ErrCorrupt, err)
+			return w.err
+		}
+		w.buf = nil
+		_, w.err = w.dst.Write(plaintext)
+	} else {
+		w.err = w.open(true)
+	}
+	if w.err != nil {
+		return w.err
+	}
+	w.err = errors.New("write to closed decrypt writer")
+	return nil
+}
+
+func (w *decryptWriter) open(last bool) error {
+	index := w.c.index
+	nonce, err := w.c.next(last)
+	if err != nil {
+		return err
+	}
+	w.out, err = w.c.aead.Open(w.out[:0], nonce, w.buf, w.c.aad)
+	if err != nil {
+		return fmt.Errorf("%w: chunk %d: %v", ErrCorrupt, index, err)
+	}
+	w.buf = w.buf[:0]
+	_, err = w.dst.Write(w.out)
+	return err
+}
+
+// EncryptFile encrypts the file at path into dst under a new random per-file
+// key, bound to aad (normally EntryAAD(entryID, FieldFile)), and returns the
+// key and nonce. The file is streamed, not read into memory.
+func EncryptFile(dst io.Writer, path string, aad []byte) (key, nonce []byte, err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer src.Close()
+
+	key = common.GenerateRandByteArray(32)
+	w, nonce, err := NewEncryptWriter(dst, key, aad)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		return nil, nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, nil, err
+	}
+	return key, nonce, nil
+}
+
+// FileDigest returns the SHA-256 digest of everything read from r. It is
+// recorded for every encrypted file, so that a download can be checked
+// before it is decrypted.
+func FileDigest(r io.Reader) ([]byte, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// VerifyDigest reads the ciphertext from r and returns ErrCorrupt unless its
+// SHA-256 digest is digest.
+func VerifyDigest(r io.Reader, digest []byte) error {
+	got, err := FileDigest(r)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(got, digest) {
+		return fmt.Errorf("%w: ciphertext digest mismatch", ErrCorrupt)
+	}
+	return nil
+}
+
+// DecryptFileTo decrypts the encrypted file read from src into outPath. The
+// plaintext goes to a temporary file next to outPath that replaces it only
+// once the whole file has authenticated, so a corrupt or truncated download
+// never leaves a partial file behind. allowUnbound is passed on to
+// NewDecryptWriter.
+func DecryptFileTo(outPath string, src io.Reader, key, nonce, aad []byte, allowUnbound bool) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(outPath), "."+filepath.Base(outPath)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	w, err := NewDecryptWriter(tmp, key, nonce, aad, allowUnbound)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(w, src); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), outPath)
+}
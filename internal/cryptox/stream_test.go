@@ -0,0 +1,137 @@
+package cryptox
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"io"
+	"testing"
+)
+
+// writeInPieces writes p in pieces of at most n bytes.
+func writeInPieces(t *testing.T, w io.Writer, p []byte, n int) {
+	t.Helper()
+	for len(p) > 0 {
+		k := min(n, len(p))
+		if _, err := w.Write(p[:k]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		p = p[k:]
+	}
+}
+
+func encryptStream(t *testing.T, key, plaintext, aad []byte) (ciphertext, nonce []byte) {
+	t.Helper()
+	var ct bytes.Buffer
+	w, nonce, err := NewEncryptWriter(&ct, key, aad)
+	if err != nil {
+		t.Fatalf("NewEncryptWriter: %v", err)
+	}
+	writeInPieces(t, w, plaintext, 1000)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return ct.Bytes(), nonce
+}
+
+func decryptStream(key, ciphertext, nonce, aad []byte, allowUnbound bool) ([]byte, error) {
+	var pt bytes.Buffer
+	w, err := NewDecryptWriter(&pt, key, nonce, aad, allowUnbound)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(w, bytes.NewReader(ciphertext)); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return pt.Bytes(), nil
+}
+
+func TestStream_RoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{3}, 32)
+	aad := EntryAAD("e1", FieldFile)
+	for _, size := range []int{0, 1, streamChunkSize - 1, streamChunkSize, streamChunkSize + 1, 3*streamChunkSize + 17} {
+		plaintext := make([]byte, size)
+		for i := range plaintext {
+			plaintext[i] = byte(i % 251)
+		}
+		ct, nonce := encryptStream(t, key, plaintext, aad)
+
+		// an empty file is one empty last chunk
+		chunks := max(1, (size+streamChunkSize-1)/streamChunkSize)
+		if want := size + chunks*16; len(ct) != want {
+			t.Fatalf("size %d: ciphertext length %d, want %d", size, len(ct), want)
+		}
+
+		got, err := decryptStream(key, ct, nonce, aad, false)
+		if err != nil {
+			t.Fatalf("size %d: decrypt: %v", size, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Fatalf("size %d: roundtrip mismatch", size)
+		}
+	}
+}
+
+func TestStream_DetectsTruncationAndReordering(t *testing.T) {
+	key := bytes.Repeat([]byte{3}, 32)
+	plaintext := bytes.Repeat([]byte{0x42}, 3*streamChunkSize+100)
+	ct, nonce := encryptStream(t, key, plaintext, nil)
+	sealed := streamChunkSize + 16
+
+	cases := map[string][]byte{
+		"cut at chunk boundary": ct[:2*sealed],
+		"last chunk dropped":    ct[:3*sealed],
+		"cut inside a chunk":    ct[:sealed+10],
+		"empty":                 nil,
+		"chunks swapped":        append(append(append([]byte{}, ct[sealed:2*sealed]...), ct[:sealed]...), ct[2*sealed:]...),
+		"data appended":         append(append([]byte{}, ct...), ct[:sealed]...),
+	}
+	for name, c := range cases {
+		if _, err := decryptStream(key, c, nonce, nil, false); !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("%s: want ErrCorrupt, got %v", name, err)
+		}
+	}
+}
+
+func TestStream_ReadsLegacyFiles(t *testing.T) {
+	key := bytes.Repeat([]byte{3}, 32)
+	block, _ := aes.NewCipher(key)
+	aead, _ := cipher.NewGCM(block)
+	nonce := bytes.Repeat([]byte{1}, aead.NonceSize())
+
+	for _, aad := range [][]byte{nil, EntryAAD("e1", FieldFile)} {
+		ct := aead.Seal(nil, nonce, []byte("old file"), aad)
+		got, err := decryptStream(key, ct, nonce, EntryAAD("e1", FieldFile), true)
+		if err != nil || string(got) != "old file" {
+			t.Fatalf("legacy file: got %q, %v", got, err)
+		}
+	}
+
+	// an unbound file is rejected unless allowed, a bound one is not
+	ct := aead.Seal(nil, nonce, []byte("old file"), nil)
+	if _, err := decryptStream(key, ct, nonce, EntryAAD("e1", FieldFile), false); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("unbound file: want ErrCorrupt, got %v", err)
+	}
+	ct = aead.Seal(nil, nonce, []byte("old file"), EntryAAD("e1", FieldFile))
+	if got, err := decryptStream(key, ct, nonce, EntryAAD("e1", FieldFile), false); err != nil || string(got) != "old file" {
+		t.Fatalf("bound legacy file: got %q, %v", got, err)
+	}
+}
+
+func TestVerifyDigest(t *testing.T) {
+	ct := []byte("ciphertext")
+	digest, err := FileDigest(bytes.NewReader(ct))
+	if err != nil {
+		t.Fatalf("FileDigest: %v", err)
+	}
+	if err := VerifyDigest(bytes.NewReader(ct), digest); err != nil {
+		t.Fatalf("matching digest rejected: %v", err)
+	}
+	if err := VerifyDigest(bytes.NewReader(ct[:5]), digest); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("want ErrCorrupt, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package cryptox
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/common"
+)
+
+// TOTP (RFC 6238) with the parameters every authenticator app supports:
+// HMAC-SHA1, 6 digits and a 30-second step.
+
+const (
+	totpDigits = 6
+	totpPeriod = 30 * time.Second
+	// totpSkew is how many steps a code may be off, for clock drift and
+	// codes typed right before the step ends.
+	totpSkew = 1
+	// totpSecretSize is the secret size recommended by RFC 4226.
+	totpSecretSize = 20
+)
+
+// totpEncoding is the unpadded base32 of otpauth URIs.
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret returns a random TOTP secret.
+func NewTOTPSecret() []byte {
+	return common.GenerateRandByteArray(totpSecretSize)
+}
+
+// EncodeTOTPSecret returns secret in the base32 form authenticator apps
+// accept for manual entry.
+func EncodeTOTPSecret(secret []byte) string {
+	return totpEncoding.EncodeToString(secret)
+}
+
+// TOTPURI returns the otpauth URI of secret for account, which authenticator
+// apps import, usually from a QR code.
+func TOTPURI(issuer, account string, secret []byte) string {
+	q := url.Values{}
+	q.Set("secret", EncodeTOTPSecret(secret))
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(int(totpPeriod.Seconds())))
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// TOTPStep returns the time step t falls into.
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(totpPeriod.Seconds())
+}
+
+// TOTPCode returns the code of secret for the given time step.
+func TOTPCode(secret []byte, step int64) string {
+	mac := hmac.New(sha1.New, secret)
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for range totpDigits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, bin%mod)
+}
+
+// VerifyTOTP checks a code of secret at time t, allowing for a step of
+// clock drift either way, and returns the step it belongs to. Callers should
+// reject steps at or before the last accepted one, so that a code cannot be
+// replayed.
+func VerifyTOTP(secret []byte, code string, t time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	now := TOTPStep(t)
+	for step := now - totpSkew; step <= now+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(TOTPCode(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// recoveryCodeSize is the entropy of a recovery code, in bytes.
+const recoveryCodeSize = 10
+
+// NewRecoveryCode returns a random one-time recovery code, formatted in
+// groups of four characters for typing.
+func NewRecoveryCode() string {
+	s := totpEncoding.EncodeToString(common.GenerateRandByteArray(recoveryCodeSize))
+	var groups []string
+	for len(s) > 4 {
+		groups = append(groups, s[:4])
+		s = s[4:]
+	}
+	return strings.Join(append(groups, s), "-")
+}
+
+// HashRecoveryCode returns the hash a recovery code is stored as. Case,
+// dashes and spaces are ignored. Recovery codes are random, so a fast hash
+// is enough.
+func HashRecoveryCode(code string) []byte {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return sum[:]
+}
@@ -0,0 +1,84 @@
+package cryptox
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 secret of the RFC 6238 test vectors.
+var rfc6238Secret = []byte("12345678901234567890")
+
+func TestTOTPCode_RFC6238(t *testing.T) {
+	// the last six digits of the 8-digit codes of RFC 6238, appendix B
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		if got := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(unix, 0))); got != want {
+			t.Fatalf("code at %d: got %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	step := TOTPStep(now)
+
+	if got, ok := VerifyTOTP(rfc6238Secret, " 081804 ", now); !ok || got != step {
+		t.Fatalf("current code: %d, %v", got, ok)
+	}
+	// a step of drift either way
+	if got, ok := VerifyTOTP(rfc6238Secret, TOTPCode(rfc6238Secret, step-1), now); !ok || got != step-1 {
+		t.Fatalf("previous code: %d, %v", got, ok)
+	}
+	if _, ok := VerifyTOTP(rfc6238Secret, TOTPCode(rfc6238Secret, step+1), now); !ok {
+		t.Fatalf("next code rejected")
+	}
+	if _, ok := VerifyTOTP(rfc6238Secret, TOTPCode(rfc6238Secret, step-2), now); ok {
+		t.Fatalf("stale code accepted")
+	}
+	for _, code := range []string{"", "81804", "0818040", "000000"} {
+		if _, ok := VerifyTOTP(rfc6238Secret, code, now); ok {
+			t.Fatalf("code %q accepted", code)
+		}
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	secret := NewTOTPSecret()
+	if len(secret) != totpSecretSize || bytes.Equal(secret, NewTOTPSecret()) {
+		t.Fatalf("secret: %x", secret)
+	}
+
+	u, err := url.Parse(TOTPURI("GophKeeper", "alice@example.org", rfc6238Secret))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/GophKeeper:alice@example.org" {
+		t.Fatalf("uri: %s", u)
+	}
+	q := u.Query()
+	if q.Get("secret") != "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" || q.Get("issuer") != "GophKeeper" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Fatalf("query: %v", q)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	code := NewRecoveryCode()
+	if len(code) != 19 || strings.Count(code, "-") != 3 || code == NewRecoveryCode() {
+		t.Fatalf("code: %q", code)
+	}
+	want := HashRecoveryCode(code)
+	typed := strings.ToLower(strings.ReplaceAll(code, "-", " "))
+	if !bytes.Equal(HashRecoveryCode(typed), want) {
+		t.Fatalf("typed code hashes differently")
+	}
+	if bytes.Equal(HashRecoveryCode(NewRecoveryCode()), want) {
+		t.Fatalf("codes collide")
+	}
+}
@@ -1,43 +1,90 @@
 // Package netx contains small HTTP helpers for interacting with presigned S3 URLs.
 // It provides thin wrappers to upload and download binary blobs using standard
-// net/http without bringing in an AWS SDK dependency.
+// net/http without bringing in an AWS SDK dependency. Bodies are streamed, so
+// blobs of any size are transferred in constant memory, and downloads to a
+// file resume after a dropped connection using HTTP Range requests.
 package netx
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// UploadToS3PresignedURL uploads raw bytes to a presigned S3 URL using HTTP PUT.
-// The request sets Content-Type to "application/octet-stream". A non-200 status
-// is treated as an error, and the response body (if any) is included for context.
-func UploadToS3PresignedURL(url string, file []byte) error {
-	req, err := http.NewRequest("PUT", url, bytes.NewReader(file))
+// downloadAttempts bounds how many times in a row DownloadToFile tries a
+// transfer that makes no progress.
+const downloadAttempts = 5
+
+// retryDelay is the pause before DownloadToFile resumes a dropped transfer.
+var retryDelay = time.Second
+
+// errPermanent marks download failures that a retry cannot fix, e.g. an
+// expired URL.
+var errPermanent = errors.New("permanent download failure")
+
+// UploadToS3PresignedURL streams size bytes from body to a presigned S3 URL
+// using HTTP PUT. The request sets Content-Type to "application/octet-stream"
+// and an explicit Content-Length, since presigned PUTs do not accept chunked
+// transfer encoding. A non-200 status is treated as an error, and the response
+// body (if any) is included for context.
+func UploadToS3PresignedURL(url string, body io.Reader, size int64) error {
+	_, err := put(url, body, size)
+	return err
+}
+
+// UploadPartToS3PresignedURL streams size bytes from body to a presigned URL
+// of a multipart upload part, like UploadToS3PresignedURL, and returns the
+// ETag that S3 assigned to the part. The ETag is needed to complete the
+// upload.
+func UploadPartToS3PresignedURL(url string, body io.Reader, size int64) (string, error) {
+	resp, err := put(url, body, size)
 	if err != nil {
-		return err
+		return "", err
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		return "", fmt.Errorf("upload of part returned no ETag")
+	}
+	return etag, nil
+}
+
+// put performs a presigned PUT of size bytes from body. The response body is
+// drained and closed; only the headers remain usable.
+func put(url string, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequest("PUT", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
 	}
 	req.Header.Set("Content-Type", "application/octet-stream")
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		b, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("upload failed: %s; body: %s", resp.Status, string(b))
+		return nil, fmt.Errorf("upload failed: %s; body: %s", resp.Status, string(b))
 	}
-	return nil
+	return resp, nil
 }
 
-// DownloadFromS3PresignedURL downloads bytes from a presigned S3 URL using HTTP GET.
-// It accepts "application/octet-stream" and returns the full body for 200/206
-// responses. Any other status is returned as an error with the response body text.
-func DownloadFromS3PresignedURL(url string) ([]byte, error) {
+// DownloadFromS3PresignedURL downloads a blob from a presigned S3 URL using
+// HTTP GET. It accepts "application/octet-stream" and, for 200/206 responses,
+// returns the response body for the caller to stream and close. Any other
+// status is returned as an error with the response body text.
+func DownloadFromS3PresignedURL(url string) (io.ReadCloser, error) {
 	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
@@ -49,17 +96,131 @@ func DownloadFromS3PresignedURL(url string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	switch resp.StatusCode {
 	case http.StatusOK, http.StatusPartialContent:
-		data, err := io.ReadAll(resp.Body)
+		return resp.Body, nil
+	default:
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("download failed: %s; body: %s", resp.Status, string(b))
+	}
+}
+
+// DownloadToFile downloads the blob behind a presigned URL into the file at
+// path. The file is taken to hold the start of the blob already, so an
+// earlier interrupted download is resumed from its last byte with an HTTP
+// Range request; a transfer that breaks off during the call is resumed the
+// same way. A server that ignores the Range header sends the whole blob,
+// which then replaces the file. Status codes other than 200, 206 and 416 are
+// not retried.
+//
+// Bytes are only appended as they arrive, so the caller has to check the
+// result, e.g. against a digest, before trusting it.
+func DownloadToFile(url, path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	failures := 0
+	for {
+		offset, err := f.Seek(0, io.SeekEnd)
 		if err != nil {
-			return nil, fmt.Errorf("read body failed: %w", err)
+			return err
+		}
+		n, err := downloadFrom(url, f, offset)
+		if err == nil {
+			return f.Close()
+		}
+		if errors.Is(err, errPermanent) {
+			return err
+		}
+		if n > 0 {
+			failures = 0
+		}
+		failures++
+		if failures >= downloadAttempts {
+			return fmt.Errorf("download failed after %d attempts: %w", failures, err)
+		}
+		time.Sleep(retryDelay)
+	}
+}
+
+// downloadFrom requests the blob from offset on and writes it to f, which is
+// positioned at offset. It returns how many bytes it wrote.
+func downloadFrom(url string, f *os.File, offset int64) (int64, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errPermanent, err)
+	}
+	req.Header.Set("Accept", "application/octet-stream")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if start, _, ok := contentRange(resp.Header.Get("Content-Range")); !ok || start != offset {
+			return 0, fmt.Errorf("unexpected content range %q for offset %d", resp.Header.Get("Content-Range"), offset)
 		}
-		return data, nil
+	case http.StatusOK:
+		// the whole blob
+		if err := f.Truncate(0); err != nil {
+			return 0, err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// nothing left to read if the file already holds the whole blob
+		if _, size, ok := contentRange(resp.Header.Get("Content-Range")); ok && size == offset {
+			return 0, nil
+		}
+		if err := f.Truncate(0); err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("local file larger than the blob, starting over")
 	default:
 		b, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("download failed: %s; body: %s", resp.Status, string(b))
+		return 0, fmt.Errorf("%w: download failed: %s; body: %s", errPermanent, resp.Status, string(b))
+	}
+	return io.Copy(f, resp.Body)
+}
+
+// contentRange parses the start offset and the total size of a Content-Range
+// header ("bytes start-end/size" or "bytes */size").
+func contentRange(h string) (start, size int64, ok bool) {
+	spec, found := strings.CutPrefix(h, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	rng, total, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		size = -1
+	}
+	if rng == "*" {
+		return -1, size, err == nil
+	}
+	first, _, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
 	}
+	return start, size, true
 }
@@ -3,11 +3,16 @@ package netx
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -19,10 +24,12 @@ func TestUploadToS3PresignedURL(t *testing.T) {
 		var gotBody []byte
 		var gotCT string
 		var gotMethod string
+		var gotLength int64
 
 		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			gotMethod = r.Method
 			gotCT = r.Header.Get("Content-Type")
+			gotLength = r.ContentLength
 			body, _ := io.ReadAll(r.Body)
 			_ = r.Body.Close()
 			gotBody = body
@@ -30,7 +37,7 @@ func TestUploadToS3PresignedURL(t *testing.T) {
 		}))
 		defer ts.Close()
 
-		err := UploadToS3PresignedURL(ts.URL+"/some/presigned?X-Amz-Signature=abc", file)
+		err := UploadToS3PresignedURL(ts.URL+"/some/presigned?X-Amz-Signature=abc", bytes.NewReader(file), int64(len(file)))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -40,6 +47,9 @@ func TestUploadToS3PresignedURL(t *testing.T) {
 		if gotCT != "application/octet-stream" {
 			t.Fatalf("Content-Type = %q, want application/octet-stream", gotCT)
 		}
+		if gotLength != int64(len(file)) {
+			t.Fatalf("Content-Length = %d, want %d", gotLength, len(file))
+		}
 		if !bytes.Equal(gotBody, file) {
 			t.Fatalf("body = %q, want %q", string(gotBody), string(file))
 		}
@@ -51,7 +61,7 @@ func TestUploadToS3PresignedURL(t *testing.T) {
 		}))
 		defer ts.Close()
 
-		err := UploadToS3PresignedURL(ts.URL, file)
+		err := UploadToS3PresignedURL(ts.URL, bytes.NewReader(file), int64(len(file)))
 		if err == nil {
 			t.Fatal("expected error, got nil")
 		}
@@ -64,7 +74,7 @@ func TestUploadToS3PresignedURL(t *testing.T) {
 		ts := httptest.NewServer(http.NotFoundHandler())
 		ts.Close()
 
-		err := UploadToS3PresignedURL(ts.URL, file)
+		err := UploadToS3PresignedURL(ts.URL, bytes.NewReader(file), int64(len(file)))
 		if err == nil {
 			t.Fatal("expected error, got nil")
 		}
@@ -76,6 +86,29 @@ func TestUploadToS3PresignedURL(t *testing.T) {
 	})
 }
 
+func TestUploadPartToS3PresignedURL(t *testing.T) {
+	part := []byte("part one")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Query().Get("partNumber") == "2" {
+			w.WriteHeader(http.StatusOK) // no ETag
+			return
+		}
+		require.Equal(t, part, body)
+		w.Header().Set("ETag", `"abc"`)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	etag, err := UploadPartToS3PresignedURL(ts.URL+"?partNumber=1", bytes.NewReader(part), int64(len(part)))
+	require.NoError(t, err)
+	require.Equal(t, `"abc"`, etag)
+
+	_, err = UploadPartToS3PresignedURL(ts.URL+"?partNumber=2", bytes.NewReader(part), int64(len(part)))
+	require.ErrorContains(t, err, "no ETag")
+}
+
 type netOpErrorLike interface {
 	error
 	Timeout() bool
@@ -87,6 +120,17 @@ func isNetOpError(err error) bool {
 	return errors.As(err, &target)
 }
 
+// download reads the whole blob behind url.
+func download(t *testing.T, url string) ([]byte, error) {
+	t.Helper()
+	body, err := DownloadFromS3PresignedURL(url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
 func TestDownloadFromS3PresignedURL_OK(t *testing.T) {
 	t.Parallel()
 
@@ -100,7 +144,7 @@ func TestDownloadFromS3PresignedURL_OK(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	got, err := DownloadFromS3PresignedURL(srv.URL)
+	got, err := download(t, srv.URL)
 	require.NoError(t, err)
 	require.Equal(t, want, got)
 	require.Equal(t, "application/octet-stream", seenAccept, "Accept header must be set")
@@ -116,7 +160,7 @@ func TestDownloadFromS3PresignedURL_PartialContent(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	got, err := DownloadFromS3PresignedURL(srv.URL)
+	got, err := download(t, srv.URL)
 	require.NoError(t, err)
 	require.Equal(t, want, got)
 }
@@ -131,7 +175,7 @@ func TestDownloadFromS3PresignedURL_ErrorStatus(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	_, err := DownloadFromS3PresignedURL(srv.URL)
+	_, err := download(t, srv.URL)
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "403 Forbidden")
 	require.Contains(t, err.Error(), "AccessDenied")
@@ -143,7 +187,7 @@ func TestDownloadFromS3PresignedURL_NetworkError(t *testing.T) {
 	srv := httptest.NewServer(http.NotFoundHandler())
 	srv.Close()
 
-	_, err := DownloadFromS3PresignedURL(srv.URL)
+	_, err := download(t, srv.URL)
 	require.Error(t, err, "expected network error after server closed")
 }
 
@@ -161,7 +205,105 @@ func TestDownloadFromS3PresignedURL_LargeBody(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	got, err := DownloadFromS3PresignedURL(srv.URL)
+	got, err := download(t, srv.URL)
 	require.NoError(t, err)
 	require.Equal(t, large, got)
 }
+
+// flakyBlobServer serves blob with Range support and cuts the first drops
+// responses off after half of their body.
+func flakyBlobServer(t *testing.T, blob []byte, drops int) (*httptest.Server, *[]string) {
+	t.Helper()
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if drops == 0 {
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(blob))
+			return
+		}
+		drops--
+		start := 0
+		if rng := r.Header.Get("Range"); rng != "" {
+			_, _ = fmt.Sscanf(rng, "bytes=%d-", &start)
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(blob)-1, len(blob)))
+			w.Header().Set("Content-Length", strconv.Itoa(len(blob)-start))
+			w.WriteHeader(http.StatusPartialContent)
+		} else {
+			w.Header().Set("Content-Length", strconv.Itoa(len(blob)))
+		}
+		rest := blob[start:]
+		_, _ = w.Write(rest[:len(rest)/2])
+		// returning early leaves the body short and closes the connection
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &ranges
+}
+
+func TestDownloadToFile_ResumesDroppedTransfers(t *testing.T) {
+	orig := retryDelay
+	retryDelay = 0
+	t.Cleanup(func() { retryDelay = orig })
+
+	blob := make([]byte, 100_000)
+	for i := range blob {
+		blob[i] = byte(i % 251)
+	}
+	srv, ranges := flakyBlobServer(t, blob, 2)
+
+	path := filepath.Join(t.TempDir(), "blob.part")
+	require.NoError(t, DownloadToFile(srv.URL, path))
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, blob, got)
+	require.Equal(t, []string{"", "bytes=50000-", "bytes=75000-"}, *ranges)
+}
+
+func TestDownloadToFile_ResumesExistingFile(t *testing.T) {
+	blob := []byte("0123456789")
+	srv, ranges := flakyBlobServer(t, blob, 0)
+
+	path := filepath.Join(t.TempDir(), "blob.part")
+	require.NoError(t, os.WriteFile(path, blob[:4], 0o600))
+	require.NoError(t, DownloadToFile(srv.URL, path))
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, blob, got)
+	require.Equal(t, []string{"bytes=4-"}, *ranges)
+
+	// a complete file needs no transfer
+	require.NoError(t, DownloadToFile(srv.URL, path))
+	got, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, blob, got)
+}
+
+func TestDownloadToFile_ServerIgnoringRangeReplacesFile(t *testing.T) {
+	blob := []byte("0123456789")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(blob)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "blob.part")
+	require.NoError(t, os.WriteFile(path, []byte("0123"), 0o600))
+	require.NoError(t, DownloadToFile(srv.URL, path))
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, blob, got)
+}
+
+func TestDownloadToFile_ErrorStatusNotRetried(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	err := DownloadToFile(srv.URL, filepath.Join(t.TempDir(), "blob.part"))
+	require.ErrorContains(t, err, "403 Forbidden")
+	require.Equal(t, 1, requests)
+}
@@ -21,18 +21,94 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// KDFParams are the Argon2id parameters a master key is derived with.
+type KDFParams struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Algorithm string                 `protobuf:"bytes,1,opt,name=algorithm,proto3" json:"algorithm,omitempty"`
+	Time      uint32                 `protobuf:"varint,2,opt,name=time,proto3" json:"time,omitempty"`
+	// memory is the memory cost in KiB.
+	Memory        uint32 `protobuf:"varint,3,opt,name=memory,proto3" json:"memory,omitempty"`
+	Threads       uint32 `protobuf:"varint,4,opt,name=threads,proto3" json:"threads,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KDFParams) Reset() {
+	*x = KDFParams{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KDFParams) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KDFParams) ProtoMessage() {}
+
+func (x *KDFParams) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KDFParams.ProtoReflect.Descriptor instead.
+func (*KDFParams) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *KDFParams) GetAlgorithm() string {
+	if x != nil {
+		return x.Algorithm
+	}
+	return ""
+}
+
+func (x *KDFParams) GetTime() uint32 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *KDFParams) GetMemory() uint32 {
+	if x != nil {
+		return x.Memory
+	}
+	return 0
+}
+
+func (x *KDFParams) GetThreads() uint32 {
+	if x != nil {
+		return x.Threads
+	}
+	return 0
+}
+
 type RegisterUserRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
-	Salt          []byte                 `protobuf:"bytes,2,opt,name=salt,proto3" json:"salt,omitempty"`
-	Verifier      []byte                 `protobuf:"bytes,3,opt,name=verifier,proto3" json:"verifier,omitempty"`
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Username string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Salt     []byte                 `protobuf:"bytes,2,opt,name=salt,proto3" json:"salt,omitempty"`
+	// verifier is the SRP-6a verifier derived from the auth key.
+	Verifier []byte `protobuf:"bytes,3,opt,name=verifier,proto3" json:"verifier,omitempty"`
+	// wrapped_vault_key is the vault key wrapped with the key-encryption key.
+	WrappedVaultKey []byte `protobuf:"bytes,4,opt,name=wrapped_vault_key,json=wrappedVaultKey,proto3" json:"wrapped_vault_key,omitempty"`
+	// kdf are the parameters the master key was derived with; they must meet
+	// the server's policy (see GetSaltResponse).
+	Kdf           *KDFParams `protobuf:"bytes,5,opt,name=kdf,proto3" json:"kdf,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *RegisterUserRequest) Reset() {
 	*x = RegisterUserRequest{}
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[0]
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[1]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -44,7 +120,7 @@ func (x *RegisterUserRequest) String() string {
 func (*RegisterUserRequest) ProtoMessage() {}
 
 func (x *RegisterUserRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[0]
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[1]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -57,7 +133,7 @@ func (x *RegisterUserRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RegisterUserRequest.ProtoReflect.Descriptor instead.
 func (*RegisterUserRequest) Descriptor() ([]byte, []int) {
-	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{0}
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{1}
 }
 
 func (x *RegisterUserRequest) GetUsername() string {
@@ -81,6 +157,20 @@ func (x *RegisterUserRequest) GetVerifier() []byte {
 	return nil
 }
 
+func (x *RegisterUserRequest) GetWrappedVaultKey() []byte {
+	if x != nil {
+		return x.WrappedVaultKey
+	}
+	return nil
+}
+
+func (x *RegisterUserRequest) GetKdf() *KDFParams {
+	if x != nil {
+		return x.Kdf
+	}
+	return nil
+}
+
 type RegisterUserResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
@@ -90,7 +180,7 @@ type RegisterUserResponse struct {
 
 func (x *RegisterUserResponse) Reset() {
 	*x = RegisterUserResponse{}
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[1]
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -102,7 +192,7 @@ func (x *RegisterUserResponse) String() string {
 func (*RegisterUserResponse) ProtoMessage() {}
 
 func (x *RegisterUserResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[1]
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -115,7 +205,7 @@ func (x *RegisterUserResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RegisterUserResponse.ProtoReflect.Descriptor instead.
 func (*RegisterUserResponse) Descriptor() ([]byte, []int) {
-	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{1}
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *RegisterUserResponse) GetUsername() string {
@@ -134,7 +224,7 @@ type GetSaltRequest struct {
 
 func (x *GetSaltRequest) Reset() {
 	*x = GetSaltRequest{}
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[2]
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -146,7 +236,7 @@ func (x *GetSaltRequest) String() string {
 func (*GetSaltRequest) ProtoMessage() {}
 
 func (x *GetSaltRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[2]
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -159,7 +249,7 @@ func (x *GetSaltRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetSaltRequest.ProtoReflect.Descriptor instead.
 func (*GetSaltRequest) Descriptor() ([]byte, []int) {
-	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{2}
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *GetSaltRequest) GetUsername() string {
@@ -170,15 +260,24 @@ func (x *GetSaltRequest) GetUsername() string {
 }
 
 type GetSaltResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Salt          []byte                 `protobuf:"bytes,1,opt,name=salt,proto3" json:"salt,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Salt  []byte                 `protobuf:"bytes,1,opt,name=salt,proto3" json:"salt,omitempty"`
+	// legacy_keys is set for accounts that predate the key hierarchy: their
+	// SRP verifier is derived from the master key itself and they have no
+	// vault key yet.
+	LegacyKeys bool `protobuf:"varint,2,opt,name=legacy_keys,json=legacyKeys,proto3" json:"legacy_keys,omitempty"`
+	// kdf are the account's KDF parameters, or the policy for new accounts.
+	Kdf *KDFParams `protobuf:"bytes,3,opt,name=kdf,proto3" json:"kdf,omitempty"`
+	// kdf_upgrade is set when kdf is below the server's policy: the client
+	// should re-derive its keys with it and call UpgradeKDF after logging in.
+	KdfUpgrade    *KDFParams `protobuf:"bytes,4,opt,name=kdf_upgrade,json=kdfUpgrade,proto3" json:"kdf_upgrade,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *GetSaltResponse) Reset() {
 	*x = GetSaltResponse{}
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[3]
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -190,7 +289,7 @@ func (x *GetSaltResponse) String() string {
 func (*GetSaltResponse) ProtoMessage() {}
 
 func (x *GetSaltResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[3]
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -203,7 +302,7 @@ func (x *GetSaltResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetSaltResponse.ProtoReflect.Descriptor instead.
 func (*GetSaltResponse) Descriptor() ([]byte, []int) {
-	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{3}
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *GetSaltResponse) GetSalt() []byte {
@@ -213,17 +312,108 @@ func (x *GetSaltResponse) GetSalt() []byte {
 	return nil
 }
 
+func (x *GetSaltResponse) GetLegacyKeys() bool {
+	if x != nil {
+		return x.LegacyKeys
+	}
+	return false
+}
+
+func (x *GetSaltResponse) GetKdf() *KDFParams {
+	if x != nil {
+		return x.Kdf
+	}
+	return nil
+}
+
+func (x *GetSaltResponse) GetKdfUpgrade() *KDFParams {
+	if x != nil {
+		return x.KdfUpgrade
+	}
+	return nil
+}
+
+// DeviceInfo describes the client a login starts a session for. The server
+// adds the address the client connects from.
+type DeviceInfo struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// name is a human-readable device name, e.g. the host name.
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// platform is the client's operating system and architecture.
+	Platform      string `protobuf:"bytes,2,opt,name=platform,proto3" json:"platform,omitempty"`
+	ClientVersion string `protobuf:"bytes,3,opt,name=client_version,json=clientVersion,proto3" json:"client_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeviceInfo) Reset() {
+	*x = DeviceInfo{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeviceInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeviceInfo) ProtoMessage() {}
+
+func (x *DeviceInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeviceInfo.ProtoReflect.Descriptor instead.
+func (*DeviceInfo) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DeviceInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *DeviceInfo) GetPlatform() string {
+	if x != nil {
+		return x.Platform
+	}
+	return ""
+}
+
+func (x *DeviceInfo) GetClientVersion() string {
+	if x != nil {
+		return x.ClientVersion
+	}
+	return ""
+}
+
+// LoginRequest is the legacy login of an account that has not been upgraded
+// to SRP yet. It is accepted once and replaces the legacy verifier with
+// srp_verifier and wrapped_vault_key.
 type LoginRequest struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
 	Username          string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
 	VerifierCandidate []byte                 `protobuf:"bytes,2,opt,name=verifier_candidate,json=verifierCandidate,proto3" json:"verifier_candidate,omitempty"`
+	SrpVerifier       []byte                 `protobuf:"bytes,3,opt,name=srp_verifier,json=srpVerifier,proto3" json:"srp_verifier,omitempty"`
+	WrappedVaultKey   []byte                 `protobuf:"bytes,4,opt,name=wrapped_vault_key,json=wrappedVaultKey,proto3" json:"wrapped_vault_key,omitempty"`
+	Device            *DeviceInfo            `protobuf:"bytes,5,opt,name=device,proto3" json:"device,omitempty"`
 	unknownFields     protoimpl.UnknownFields
 	sizeCache         protoimpl.SizeCache
 }
 
 func (x *LoginRequest) Reset() {
 	*x = LoginRequest{}
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[4]
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -235,7 +425,7 @@ func (x *LoginRequest) String() string {
 func (*LoginRequest) ProtoMessage() {}
 
 func (x *LoginRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[4]
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -248,7 +438,7 @@ func (x *LoginRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LoginRequest.ProtoReflect.Descriptor instead.
 func (*LoginRequest) Descriptor() ([]byte, []int) {
-	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{4}
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *LoginRequest) GetUsername() string {
@@ -265,6 +455,27 @@ func (x *LoginRequest) GetVerifierCandidate() []byte {
 	return nil
 }
 
+func (x *LoginRequest) GetSrpVerifier() []byte {
+	if x != nil {
+		return x.SrpVerifier
+	}
+	return nil
+}
+
+func (x *LoginRequest) GetWrappedVaultKey() []byte {
+	if x != nil {
+		return x.WrappedVaultKey
+	}
+	return nil
+}
+
+func (x *LoginRequest) GetDevice() *DeviceInfo {
+	if x != nil {
+		return x.Device
+	}
+	return nil
+}
+
 type LoginResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	AccessToken   string                 `protobuf:"bytes,1,opt,name=access_token,json=accessToken,proto3" json:"access_token,omitempty"`
@@ -275,7 +486,7 @@ type LoginResponse struct {
 
 func (x *LoginResponse) Reset() {
 	*x = LoginResponse{}
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[5]
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -287,7 +498,7 @@ func (x *LoginResponse) String() string {
 func (*LoginResponse) ProtoMessage() {}
 
 func (x *LoginResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[5]
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -300,7 +511,7 @@ func (x *LoginResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LoginResponse.ProtoReflect.Descriptor instead.
 func (*LoginResponse) Descriptor() ([]byte, []int) {
-	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{5}
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *LoginResponse) GetAccessToken() string {
@@ -317,27 +528,30 @@ func (x *LoginResponse) GetRefreshToken() string {
 	return ""
 }
 
-type PingRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
+type LoginStartRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Username string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	// client_public is the client's ephemeral SRP public value A.
+	ClientPublic  []byte `protobuf:"bytes,2,opt,name=client_public,json=clientPublic,proto3" json:"client_public,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PingRequest) Reset() {
-	*x = PingRequest{}
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[6]
+func (x *LoginStartRequest) Reset() {
+	*x = LoginStartRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PingRequest) String() string {
+func (x *LoginStartRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PingRequest) ProtoMessage() {}
+func (*LoginStartRequest) ProtoMessage() {}
 
-func (x *PingRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[6]
+func (x *LoginStartRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -348,33 +562,49 @@ func (x *PingRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PingRequest.ProtoReflect.Descriptor instead.
-func (*PingRequest) Descriptor() ([]byte, []int) {
-	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{6}
+// Deprecated: Use LoginStartRequest.ProtoReflect.Descriptor instead.
+func (*LoginStartRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{8}
 }
 
-type PingResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+func (x *LoginStartRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *LoginStartRequest) GetClientPublic() []byte {
+	if x != nil {
+		return x.ClientPublic
+	}
+	return nil
+}
+
+type LoginStartResponse struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	SessionId string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// server_public is the server's ephemeral SRP public value B.
+	ServerPublic  []byte `protobuf:"bytes,2,opt,name=server_public,json=serverPublic,proto3" json:"server_public,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PingResponse) Reset() {
-	*x = PingResponse{}
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[7]
+func (x *LoginStartResponse) Reset() {
+	*x = LoginStartResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PingResponse) String() string {
+func (x *LoginStartResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PingResponse) ProtoMessage() {}
+func (*LoginStartResponse) ProtoMessage() {}
 
-func (x *PingResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[7]
+func (x *LoginStartResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -385,47 +615,50 @@ func (x *PingResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PingResponse.ProtoReflect.Descriptor instead.
-func (*PingResponse) Descriptor() ([]byte, []int) {
-	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{7}
+// Deprecated: Use LoginStartResponse.ProtoReflect.Descriptor instead.
+func (*LoginStartResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{9}
 }
 
-func (x *PingResponse) GetStatus() string {
+func (x *LoginStartResponse) GetSessionId() string {
 	if x != nil {
-		return x.Status
+		return x.SessionId
 	}
 	return ""
 }
 
-type Entry struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Version       int64                  `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
-	Overview      []byte                 `protobuf:"bytes,3,opt,name=overview,proto3" json:"overview,omitempty"`
-	NonceOverview []byte                 `protobuf:"bytes,4,opt,name=nonce_overview,json=nonceOverview,proto3" json:"nonce_overview,omitempty"`
-	Details       []byte                 `protobuf:"bytes,5,opt,name=details,proto3" json:"details,omitempty"`
-	NonceDetails  []byte                 `protobuf:"bytes,6,opt,name=nonce_details,json=nonceDetails,proto3" json:"nonce_details,omitempty"`
-	Deleted       bool                   `protobuf:"varint,7,opt,name=deleted,proto3" json:"deleted,omitempty"`
-	IsFile        bool                   `protobuf:"varint,8,opt,name=is_file,json=isFile,proto3" json:"is_file,omitempty"`
+func (x *LoginStartResponse) GetServerPublic() []byte {
+	if x != nil {
+		return x.ServerPublic
+	}
+	return nil
+}
+
+type LoginFinishRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	SessionId string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// client_proof is the client's SRP proof M1.
+	ClientProof   []byte      `protobuf:"bytes,2,opt,name=client_proof,json=clientProof,proto3" json:"client_proof,omitempty"`
+	Device        *DeviceInfo `protobuf:"bytes,3,opt,name=device,proto3" json:"device,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Entry) Reset() {
-	*x = Entry{}
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[8]
+func (x *LoginFinishRequest) Reset() {
+	*x = LoginFinishRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Entry) String() string {
+func (x *LoginFinishRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Entry) ProtoMessage() {}
+func (*LoginFinishRequest) ProtoMessage() {}
 
-func (x *Entry) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[8]
+func (x *LoginFinishRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -436,91 +669,2096 @@ func (x *Entry) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Entry.ProtoReflect.Descriptor instead.
-func (*Entry) Descriptor() ([]byte, []int) {
-	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{8}
+// Deprecated: Use LoginFinishRequest.ProtoReflect.Descriptor instead.
+func (*LoginFinishRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{10}
 }
 
-func (x *Entry) GetId() string {
+func (x *LoginFinishRequest) GetSessionId() string {
 	if x != nil {
-		return x.Id
+		return x.SessionId
 	}
 	return ""
 }
 
-func (x *Entry) GetVersion() int64 {
+func (x *LoginFinishRequest) GetClientProof() []byte {
 	if x != nil {
-		return x.Version
+		return x.ClientProof
 	}
-	return 0
+	return nil
 }
 
-func (x *Entry) GetOverview() []byte {
+func (x *LoginFinishRequest) GetDevice() *DeviceInfo {
 	if x != nil {
-		return x.Overview
+		return x.Device
 	}
 	return nil
 }
 
-func (x *Entry) GetNonceOverview() []byte {
-	if x != nil {
-		return x.NonceOverview
-	}
-	return nil
+type LoginFinishResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// server_proof is the server's SRP proof M2.
+	ServerProof  []byte `protobuf:"bytes,1,opt,name=server_proof,json=serverProof,proto3" json:"server_proof,omitempty"`
+	AccessToken  string `protobuf:"bytes,2,opt,name=access_token,json=accessToken,proto3" json:"access_token,omitempty"`
+	RefreshToken string `protobuf:"bytes,3,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"`
+	// wrapped_vault_key is empty for accounts that predate the key hierarchy.
+	WrappedVaultKey []byte `protobuf:"bytes,4,opt,name=wrapped_vault_key,json=wrappedVaultKey,proto3" json:"wrapped_vault_key,omitempty"`
+	// two_factor_challenge is set instead of the tokens and the wrapped vault
+	// key for accounts with two-factor authentication; the login is completed
+	// with Verify2FA.
+	TwoFactorChallenge string `protobuf:"bytes,5,opt,name=two_factor_challenge,json=twoFactorChallenge,proto3" json:"two_factor_challenge,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *LoginFinishResponse) Reset() {
+	*x = LoginFinishResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoginFinishResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoginFinishResponse) ProtoMessage() {}
+
+func (x *LoginFinishResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoginFinishResponse.ProtoReflect.Descriptor instead.
+func (*LoginFinishResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *LoginFinishResponse) GetServerProof() []byte {
+	if x != nil {
+		return x.ServerProof
+	}
+	return nil
+}
+
+func (x *LoginFinishResponse) GetAccessToken() string {
+	if x != nil {
+		return x.AccessToken
+	}
+	return ""
+}
+
+func (x *LoginFinishResponse) GetRefreshToken() string {
+	if x != nil {
+		return x.RefreshToken
+	}
+	return ""
+}
+
+func (x *LoginFinishResponse) GetWrappedVaultKey() []byte {
+	if x != nil {
+		return x.WrappedVaultKey
+	}
+	return nil
+}
+
+func (x *LoginFinishResponse) GetTwoFactorChallenge() string {
+	if x != nil {
+		return x.TwoFactorChallenge
+	}
+	return ""
+}
+
+// UpgradeKeysRequest moves an account that predates the key hierarchy to it.
+type UpgradeKeysRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// srp_verifier is the SRP-6a verifier derived from the auth key.
+	SrpVerifier     []byte `protobuf:"bytes,1,opt,name=srp_verifier,json=srpVerifier,proto3" json:"srp_verifier,omitempty"`
+	WrappedVaultKey []byte `protobuf:"bytes,2,opt,name=wrapped_vault_key,json=wrappedVaultKey,proto3" json:"wrapped_vault_key,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *UpgradeKeysRequest) Reset() {
+	*x = UpgradeKeysRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpgradeKeysRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpgradeKeysRequest) ProtoMessage() {}
+
+func (x *UpgradeKeysRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpgradeKeysRequest.ProtoReflect.Descriptor instead.
+func (*UpgradeKeysRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *UpgradeKeysRequest) GetSrpVerifier() []byte {
+	if x != nil {
+		return x.SrpVerifier
+	}
+	return nil
+}
+
+func (x *UpgradeKeysRequest) GetWrappedVaultKey() []byte {
+	if x != nil {
+		return x.WrappedVaultKey
+	}
+	return nil
+}
+
+type UpgradeKeysResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpgradeKeysResponse) Reset() {
+	*x = UpgradeKeysResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpgradeKeysResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpgradeKeysResponse) ProtoMessage() {}
+
+func (x *UpgradeKeysResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpgradeKeysResponse.ProtoReflect.Descriptor instead.
+func (*UpgradeKeysResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{13}
+}
+
+// ChangePasswordRequest replaces the caller's credentials. The current
+// password is proven with an SRP login started by LoginStart.
+type ChangePasswordRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	SessionId string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// client_proof is the client's SRP proof M1 for the current password.
+	ClientProof []byte `protobuf:"bytes,2,opt,name=client_proof,json=clientProof,proto3" json:"client_proof,omitempty"`
+	Salt        []byte `protobuf:"bytes,3,opt,name=salt,proto3" json:"salt,omitempty"`
+	// srp_verifier is the SRP-6a verifier derived from the new auth key.
+	SrpVerifier []byte `protobuf:"bytes,4,opt,name=srp_verifier,json=srpVerifier,proto3" json:"srp_verifier,omitempty"`
+	// wrapped_vault_key is the vault key wrapped with the new key-encryption key.
+	WrappedVaultKey []byte `protobuf:"bytes,5,opt,name=wrapped_vault_key,json=wrappedVaultKey,proto3" json:"wrapped_vault_key,omitempty"`
+	// kdf are the parameters the new master key was derived with.
+	Kdf *KDFParams `protobuf:"bytes,6,opt,name=kdf,proto3" json:"kdf,omitempty"`
+	// device is the client the new session is started for.
+	Device *DeviceInfo `protobuf:"bytes,7,opt,name=device,proto3" json:"device,omitempty"`
+	// rekey is set if wrapped_vault_key wraps a new vault key; entries and
+	// files then carry the whole account re-encrypted under it.
+	Rekey bool `protobuf:"varint,8,opt,name=rekey,proto3" json:"rekey,omitempty"`
+	// entries are all entries of the account, tombstones included, sealed
+	// under the new vault key, with base_version set to the version they were
+	// re-encrypted from.
+	Entries []*Entry `protobuf:"bytes,9,rep,name=entries,proto3" json:"entries,omitempty"`
+	// files are the keys of all live files, wrapped with the new vault key;
+	// nonce identifies the file a key belongs to.
+	Files         []*File `protobuf:"bytes,10,rep,name=files,proto3" json:"files,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChangePasswordRequest) Reset() {
+	*x = ChangePasswordRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChangePasswordRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChangePasswordRequest) ProtoMessage() {}
+
+func (x *ChangePasswordRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChangePasswordRequest.ProtoReflect.Descriptor instead.
+func (*ChangePasswordRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ChangePasswordRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *ChangePasswordRequest) GetClientProof() []byte {
+	if x != nil {
+		return x.ClientProof
+	}
+	return nil
+}
+
+func (x *ChangePasswordRequest) GetSalt() []byte {
+	if x != nil {
+		return x.Salt
+	}
+	return nil
+}
+
+func (x *ChangePasswordRequest) GetSrpVerifier() []byte {
+	if x != nil {
+		return x.SrpVerifier
+	}
+	return nil
+}
+
+func (x *ChangePasswordRequest) GetWrappedVaultKey() []byte {
+	if x != nil {
+		return x.WrappedVaultKey
+	}
+	return nil
+}
+
+func (x *ChangePasswordRequest) GetKdf() *KDFParams {
+	if x != nil {
+		return x.Kdf
+	}
+	return nil
+}
+
+func (x *ChangePasswordRequest) GetDevice() *DeviceInfo {
+	if x != nil {
+		return x.Device
+	}
+	return nil
+}
+
+func (x *ChangePasswordRequest) GetRekey() bool {
+	if x != nil {
+		return x.Rekey
+	}
+	return false
+}
+
+func (x *ChangePasswordRequest) GetEntries() []*Entry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+func (x *ChangePasswordRequest) GetFiles() []*File {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+// ChangePasswordResponse carries new tokens; all other sessions of the
+// account are revoked.
+type ChangePasswordResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// server_proof is the server's SRP proof M2.
+	ServerProof  []byte `protobuf:"bytes,1,opt,name=server_proof,json=serverProof,proto3" json:"server_proof,omitempty"`
+	AccessToken  string `protobuf:"bytes,2,opt,name=access_token,json=accessToken,proto3" json:"access_token,omitempty"`
+	RefreshToken string `protobuf:"bytes,3,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"`
+	// versions maps the id of every re-encrypted entry to the version it was
+	// stored under.
+	Versions      map[string]int64 `protobuf:"bytes,4,rep,name=versions,proto3" json:"versions,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChangePasswordResponse) Reset() {
+	*x = ChangePasswordResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChangePasswordResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChangePasswordResponse) ProtoMessage() {}
+
+func (x *ChangePasswordResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChangePasswordResponse.ProtoReflect.Descriptor instead.
+func (*ChangePasswordResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ChangePasswordResponse) GetServerProof() []byte {
+	if x != nil {
+		return x.ServerProof
+	}
+	return nil
+}
+
+func (x *ChangePasswordResponse) GetAccessToken() string {
+	if x != nil {
+		return x.AccessToken
+	}
+	return ""
+}
+
+func (x *ChangePasswordResponse) GetRefreshToken() string {
+	if x != nil {
+		return x.RefreshToken
+	}
+	return ""
+}
+
+func (x *ChangePasswordResponse) GetVersions() map[string]int64 {
+	if x != nil {
+		return x.Versions
+	}
+	return nil
+}
+
+// UpgradeKDFRequest replaces credentials derived with KDF parameters below
+// the server's policy by ones derived from the same password with kdf.
+type UpgradeKDFRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	SessionId string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// client_proof is the client's SRP proof M1 for the current credentials.
+	ClientProof     []byte     `protobuf:"bytes,2,opt,name=client_proof,json=clientProof,proto3" json:"client_proof,omitempty"`
+	Salt            []byte     `protobuf:"bytes,3,opt,name=salt,proto3" json:"salt,omitempty"`
+	Kdf             *KDFParams `protobuf:"bytes,4,opt,name=kdf,proto3" json:"kdf,omitempty"`
+	SrpVerifier     []byte     `protobuf:"bytes,5,opt,name=srp_verifier,json=srpVerifier,proto3" json:"srp_verifier,omitempty"`
+	WrappedVaultKey []byte     `protobuf:"bytes,6,opt,name=wrapped_vault_key,json=wrappedVaultKey,proto3" json:"wrapped_vault_key,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *UpgradeKDFRequest) Reset() {
+	*x = UpgradeKDFRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpgradeKDFRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpgradeKDFRequest) ProtoMessage() {}
+
+func (x *UpgradeKDFRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpgradeKDFRequest.ProtoReflect.Descriptor instead.
+func (*UpgradeKDFRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *UpgradeKDFRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *UpgradeKDFRequest) GetClientProof() []byte {
+	if x != nil {
+		return x.ClientProof
+	}
+	return nil
+}
+
+func (x *UpgradeKDFRequest) GetSalt() []byte {
+	if x != nil {
+		return x.Salt
+	}
+	return nil
+}
+
+func (x *UpgradeKDFRequest) GetKdf() *KDFParams {
+	if x != nil {
+		return x.Kdf
+	}
+	return nil
+}
+
+func (x *UpgradeKDFRequest) GetSrpVerifier() []byte {
+	if x != nil {
+		return x.SrpVerifier
+	}
+	return nil
+}
+
+func (x *UpgradeKDFRequest) GetWrappedVaultKey() []byte {
+	if x != nil {
+		return x.WrappedVaultKey
+	}
+	return nil
+}
+
+type UpgradeKDFResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// server_proof is the server's SRP proof M2.
+	ServerProof   []byte `protobuf:"bytes,1,opt,name=server_proof,json=serverProof,proto3" json:"server_proof,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpgradeKDFResponse) Reset() {
+	*x = UpgradeKDFResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpgradeKDFResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpgradeKDFResponse) ProtoMessage() {}
+
+func (x *UpgradeKDFResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpgradeKDFResponse.ProtoReflect.Descriptor instead.
+func (*UpgradeKDFResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *UpgradeKDFResponse) GetServerProof() []byte {
+	if x != nil {
+		return x.ServerProof
+	}
+	return nil
+}
+
+type PingRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PingRequest) Reset() {
+	*x = PingRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingRequest) ProtoMessage() {}
+
+func (x *PingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingRequest.ProtoReflect.Descriptor instead.
+func (*PingRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{18}
+}
+
+type PingResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PingResponse) Reset() {
+	*x = PingResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingResponse) ProtoMessage() {}
+
+func (x *PingResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingResponse.ProtoReflect.Descriptor instead.
+func (*PingResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *PingResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type Entry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Version       int64                  `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+	Overview      []byte                 `protobuf:"bytes,3,opt,name=overview,proto3" json:"overview,omitempty"`
+	NonceOverview []byte                 `protobuf:"bytes,4,opt,name=nonce_overview,json=nonceOverview,proto3" json:"nonce_overview,omitempty"`
+	Details       []byte                 `protobuf:"bytes,5,opt,name=details,proto3" json:"details,omitempty"`
+	NonceDetails  []byte                 `protobuf:"bytes,6,opt,name=nonce_details,json=nonceDetails,proto3" json:"nonce_details,omitempty"`
+	Deleted       bool                   `protobuf:"varint,7,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	IsFile        bool                   `protobuf:"varint,8,opt,name=is_file,json=isFile,proto3" json:"is_file,omitempty"`
+	// base_version is the server version a pushed entry was edited from;
+	// 0 for entries created locally.
+	BaseVersion   int64 `protobuf:"varint,9,opt,name=base_version,json=baseVersion,proto3" json:"base_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Entry) Reset() {
+	*x = Entry{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Entry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Entry) ProtoMessage() {}
+
+func (x *Entry) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Entry.ProtoReflect.Descriptor instead.
+func (*Entry) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *Entry) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Entry) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Entry) GetOverview() []byte {
+	if x != nil {
+		return x.Overview
+	}
+	return nil
+}
+
+func (x *Entry) GetNonceOverview() []byte {
+	if x != nil {
+		return x.NonceOverview
+	}
+	return nil
 }
 
 func (x *Entry) GetDetails() []byte {
 	if x != nil {
-		return x.Details
+		return x.Details
+	}
+	return nil
+}
+
+func (x *Entry) GetNonceDetails() []byte {
+	if x != nil {
+		return x.NonceDetails
+	}
+	return nil
+}
+
+func (x *Entry) GetDeleted() bool {
+	if x != nil {
+		return x.Deleted
+	}
+	return false
+}
+
+func (x *Entry) GetIsFile() bool {
+	if x != nil {
+		return x.IsFile
+	}
+	return false
+}
+
+func (x *Entry) GetBaseVersion() int64 {
+	if x != nil {
+		return x.BaseVersion
+	}
+	return 0
+}
+
+type File struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	EntryId string                 `protobuf:"bytes,1,opt,name=entry_id,json=entryId,proto3" json:"entry_id,omitempty"`
+	// file_key is the per-file AES key wrapped with the user's master key.
+	FileKey []byte `protobuf:"bytes,2,opt,name=file_key,json=fileKey,proto3" json:"file_key,omitempty"`
+	Nonce   []byte `protobuf:"bytes,3,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	// digest is the SHA-256 of the stored ciphertext, recorded when the file
+	// was encrypted. Files uploaded by older clients have none.
+	Digest        []byte `protobuf:"bytes,4,opt,name=digest,proto3" json:"digest,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *File) Reset() {
+	*x = File{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *File) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*File) ProtoMessage() {}
+
+func (x *File) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use File.ProtoReflect.Descriptor instead.
+func (*File) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *File) GetEntryId() string {
+	if x != nil {
+		return x.EntryId
+	}
+	return ""
+}
+
+func (x *File) GetFileKey() []byte {
+	if x != nil {
+		return x.FileKey
+	}
+	return nil
+}
+
+func (x *File) GetNonce() []byte {
+	if x != nil {
+		return x.Nonce
+	}
+	return nil
+}
+
+func (x *File) GetDigest() []byte {
+	if x != nil {
+		return x.Digest
+	}
+	return nil
+}
+
+type UploadTask struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EntryId       string                 `protobuf:"bytes,1,opt,name=entry_id,json=entryId,proto3" json:"entry_id,omitempty"`
+	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadTask) Reset() {
+	*x = UploadTask{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadTask) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadTask) ProtoMessage() {}
+
+func (x *UploadTask) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadTask.ProtoReflect.Descriptor instead.
+func (*UploadTask) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *UploadTask) GetEntryId() string {
+	if x != nil {
+		return x.EntryId
+	}
+	return ""
+}
+
+func (x *UploadTask) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+type SyncRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MaxVersion    int64                  `protobuf:"varint,1,opt,name=max_version,json=maxVersion,proto3" json:"max_version,omitempty"`
+	Entries       []*Entry               `protobuf:"bytes,2,rep,name=entries,proto3" json:"entries,omitempty"`
+	Files         []*File                `protobuf:"bytes,3,rep,name=files,proto3" json:"files,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncRequest) Reset() {
+	*x = SyncRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncRequest) ProtoMessage() {}
+
+func (x *SyncRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncRequest.ProtoReflect.Descriptor instead.
+func (*SyncRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *SyncRequest) GetMaxVersion() int64 {
+	if x != nil {
+		return x.MaxVersion
+	}
+	return 0
+}
+
+func (x *SyncRequest) GetEntries() []*Entry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+func (x *SyncRequest) GetFiles() []*File {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+type SyncResponse struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	GlobalMaxVersion int64                  `protobuf:"varint,1,opt,name=global_max_version,json=globalMaxVersion,proto3" json:"global_max_version,omitempty"`
+	ProcessedEntries []*Entry               `protobuf:"bytes,2,rep,name=processed_entries,json=processedEntries,proto3" json:"processed_entries,omitempty"`
+	NewEntries       []*Entry               `protobuf:"bytes,3,rep,name=new_entries,json=newEntries,proto3" json:"new_entries,omitempty"`
+	NewFiles         []*File                `protobuf:"bytes,4,rep,name=new_files,json=newFiles,proto3" json:"new_files,omitempty"`
+	UploadTasks      []*UploadTask          `protobuf:"bytes,5,rep,name=upload_tasks,json=uploadTasks,proto3" json:"upload_tasks,omitempty"`
+	// conflicts holds the current server copies of pushed entries whose
+	// base_version was stale; those pushes were not applied.
+	Conflicts     []*Entry `protobuf:"bytes,6,rep,name=conflicts,proto3" json:"conflicts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncResponse) Reset() {
+	*x = SyncResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncResponse) ProtoMessage() {}
+
+func (x *SyncResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncResponse.ProtoReflect.Descriptor instead.
+func (*SyncResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *SyncResponse) GetGlobalMaxVersion() int64 {
+	if x != nil {
+		return x.GlobalMaxVersion
+	}
+	return 0
+}
+
+func (x *SyncResponse) GetProcessedEntries() []*Entry {
+	if x != nil {
+		return x.ProcessedEntries
+	}
+	return nil
+}
+
+func (x *SyncResponse) GetNewEntries() []*Entry {
+	if x != nil {
+		return x.NewEntries
+	}
+	return nil
+}
+
+func (x *SyncResponse) GetNewFiles() []*File {
+	if x != nil {
+		return x.NewFiles
+	}
+	return nil
+}
+
+func (x *SyncResponse) GetUploadTasks() []*UploadTask {
+	if x != nil {
+		return x.UploadTasks
+	}
+	return nil
+}
+
+func (x *SyncResponse) GetConflicts() []*Entry {
+	if x != nil {
+		return x.Conflicts
+	}
+	return nil
+}
+
+type RefreshTokenRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RefreshToken  string                 `protobuf:"bytes,1,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefreshTokenRequest) Reset() {
+	*x = RefreshTokenRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefreshTokenRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefreshTokenRequest) ProtoMessage() {}
+
+func (x *RefreshTokenRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefreshTokenRequest.ProtoReflect.Descriptor instead.
+func (*RefreshTokenRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *RefreshTokenRequest) GetRefreshToken() string {
+	if x != nil {
+		return x.RefreshToken
+	}
+	return ""
+}
+
+type RefreshTokenResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AccessToken   string                 `protobuf:"bytes,1,opt,name=access_token,json=accessToken,proto3" json:"access_token,omitempty"`
+	RefreshToken  string                 `protobuf:"bytes,2,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefreshTokenResponse) Reset() {
+	*x = RefreshTokenResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefreshTokenResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefreshTokenResponse) ProtoMessage() {}
+
+func (x *RefreshTokenResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefreshTokenResponse.ProtoReflect.Descriptor instead.
+func (*RefreshTokenResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *RefreshTokenResponse) GetAccessToken() string {
+	if x != nil {
+		return x.AccessToken
+	}
+	return ""
+}
+
+func (x *RefreshTokenResponse) GetRefreshToken() string {
+	if x != nil {
+		return x.RefreshToken
+	}
+	return ""
+}
+
+type MarkUploadedRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EntryId       string                 `protobuf:"bytes,1,opt,name=entry_id,json=entryId,proto3" json:"entry_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MarkUploadedRequest) Reset() {
+	*x = MarkUploadedRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MarkUploadedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MarkUploadedRequest) ProtoMessage() {}
+
+func (x *MarkUploadedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MarkUploadedRequest.ProtoReflect.Descriptor instead.
+func (*MarkUploadedRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *MarkUploadedRequest) GetEntryId() string {
+	if x != nil {
+		return x.EntryId
+	}
+	return ""
+}
+
+type MarkUploadedResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MarkUploadedResponse) Reset() {
+	*x = MarkUploadedResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MarkUploadedResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MarkUploadedResponse) ProtoMessage() {}
+
+func (x *MarkUploadedResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MarkUploadedResponse.ProtoReflect.Descriptor instead.
+func (*MarkUploadedResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{28}
+}
+
+type GetPresignedGetUrlRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EntryId       string                 `protobuf:"bytes,1,opt,name=entry_id,json=entryId,proto3" json:"entry_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPresignedGetUrlRequest) Reset() {
+	*x = GetPresignedGetUrlRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPresignedGetUrlRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPresignedGetUrlRequest) ProtoMessage() {}
+
+func (x *GetPresignedGetUrlRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPresignedGetUrlRequest.ProtoReflect.Descriptor instead.
+func (*GetPresignedGetUrlRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *GetPresignedGetUrlRequest) GetEntryId() string {
+	if x != nil {
+		return x.EntryId
+	}
+	return ""
+}
+
+type GetPresignedGetUrlResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPresignedGetUrlResponse) Reset() {
+	*x = GetPresignedGetUrlResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPresignedGetUrlResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPresignedGetUrlResponse) ProtoMessage() {}
+
+func (x *GetPresignedGetUrlResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPresignedGetUrlResponse.ProtoReflect.Descriptor instead.
+func (*GetPresignedGetUrlResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *GetPresignedGetUrlResponse) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+// Revision is a stored version of an entry. Its ciphertext is encrypted with
+// the same key as the entry itself.
+type Revision struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EntryId       string                 `protobuf:"bytes,1,opt,name=entry_id,json=entryId,proto3" json:"entry_id,omitempty"`
+	Version       int64                  `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+	Overview      []byte                 `protobuf:"bytes,3,opt,name=overview,proto3" json:"overview,omitempty"`
+	NonceOverview []byte                 `protobuf:"bytes,4,opt,name=nonce_overview,json=nonceOverview,proto3" json:"nonce_overview,omitempty"`
+	Details       []byte                 `protobuf:"bytes,5,opt,name=details,proto3" json:"details,omitempty"`
+	NonceDetails  []byte                 `protobuf:"bytes,6,opt,name=nonce_details,json=nonceDetails,proto3" json:"nonce_details,omitempty"`
+	Deleted       bool                   `protobuf:"varint,7,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	// created_at is the Unix time (seconds) the version was stored.
+	CreatedAt     int64 `protobuf:"varint,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Revision) Reset() {
+	*x = Revision{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Revision) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Revision) ProtoMessage() {}
+
+func (x *Revision) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Revision.ProtoReflect.Descriptor instead.
+func (*Revision) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *Revision) GetEntryId() string {
+	if x != nil {
+		return x.EntryId
+	}
+	return ""
+}
+
+func (x *Revision) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Revision) GetOverview() []byte {
+	if x != nil {
+		return x.Overview
+	}
+	return nil
+}
+
+func (x *Revision) GetNonceOverview() []byte {
+	if x != nil {
+		return x.NonceOverview
+	}
+	return nil
+}
+
+func (x *Revision) GetDetails() []byte {
+	if x != nil {
+		return x.Details
+	}
+	return nil
+}
+
+func (x *Revision) GetNonceDetails() []byte {
+	if x != nil {
+		return x.NonceDetails
+	}
+	return nil
+}
+
+func (x *Revision) GetDeleted() bool {
+	if x != nil {
+		return x.Deleted
+	}
+	return false
+}
+
+func (x *Revision) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+type ListRevisionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EntryId       string                 `protobuf:"bytes,1,opt,name=entry_id,json=entryId,proto3" json:"entry_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRevisionsRequest) Reset() {
+	*x = ListRevisionsRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRevisionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRevisionsRequest) ProtoMessage() {}
+
+func (x *ListRevisionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRevisionsRequest.ProtoReflect.Descriptor instead.
+func (*ListRevisionsRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *ListRevisionsRequest) GetEntryId() string {
+	if x != nil {
+		return x.EntryId
+	}
+	return ""
+}
+
+type ListRevisionsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// revisions are ordered newest first.
+	Revisions     []*Revision `protobuf:"bytes,1,rep,name=revisions,proto3" json:"revisions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRevisionsResponse) Reset() {
+	*x = ListRevisionsResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRevisionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRevisionsResponse) ProtoMessage() {}
+
+func (x *ListRevisionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRevisionsResponse.ProtoReflect.Descriptor instead.
+func (*ListRevisionsResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *ListRevisionsResponse) GetRevisions() []*Revision {
+	if x != nil {
+		return x.Revisions
+	}
+	return nil
+}
+
+type GetRevisionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EntryId       string                 `protobuf:"bytes,1,opt,name=entry_id,json=entryId,proto3" json:"entry_id,omitempty"`
+	Version       int64                  `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRevisionRequest) Reset() {
+	*x = GetRevisionRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRevisionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRevisionRequest) ProtoMessage() {}
+
+func (x *GetRevisionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRevisionRequest.ProtoReflect.Descriptor instead.
+func (*GetRevisionRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *GetRevisionRequest) GetEntryId() string {
+	if x != nil {
+		return x.EntryId
+	}
+	return ""
+}
+
+func (x *GetRevisionRequest) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+type GetRevisionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Revision      *Revision              `protobuf:"bytes,1,opt,name=revision,proto3" json:"revision,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRevisionResponse) Reset() {
+	*x = GetRevisionResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRevisionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRevisionResponse) ProtoMessage() {}
+
+func (x *GetRevisionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRevisionResponse.ProtoReflect.Descriptor instead.
+func (*GetRevisionResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *GetRevisionResponse) GetRevision() *Revision {
+	if x != nil {
+		return x.Revision
+	}
+	return nil
+}
+
+// UpdateFileKeyRequest replaces the wrapped key of an entry's file, e.g. when
+// a client re-wraps a legacy plaintext key.
+type UpdateFileKeyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EntryId       string                 `protobuf:"bytes,1,opt,name=entry_id,json=entryId,proto3" json:"entry_id,omitempty"`
+	FileKey       []byte                 `protobuf:"bytes,2,opt,name=file_key,json=fileKey,proto3" json:"file_key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateFileKeyRequest) Reset() {
+	*x = UpdateFileKeyRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateFileKeyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateFileKeyRequest) ProtoMessage() {}
+
+func (x *UpdateFileKeyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateFileKeyRequest.ProtoReflect.Descriptor instead.
+func (*UpdateFileKeyRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *UpdateFileKeyRequest) GetEntryId() string {
+	if x != nil {
+		return x.EntryId
+	}
+	return ""
+}
+
+func (x *UpdateFileKeyRequest) GetFileKey() []byte {
+	if x != nil {
+		return x.FileKey
+	}
+	return nil
+}
+
+type UpdateFileKeyResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateFileKeyResponse) Reset() {
+	*x = UpdateFileKeyResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateFileKeyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateFileKeyResponse) ProtoMessage() {}
+
+func (x *UpdateFileKeyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateFileKeyResponse.ProtoReflect.Descriptor instead.
+func (*UpdateFileKeyResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{37}
+}
+
+// Multipart uploads of large files. The upload belongs to the pending file of
+// the entry; starting it again returns the upload already in progress.
+type StartMultipartUploadRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EntryId       string                 `protobuf:"bytes,1,opt,name=entry_id,json=entryId,proto3" json:"entry_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartMultipartUploadRequest) Reset() {
+	*x = StartMultipartUploadRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartMultipartUploadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartMultipartUploadRequest) ProtoMessage() {}
+
+func (x *StartMultipartUploadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartMultipartUploadRequest.ProtoReflect.Descriptor instead.
+func (*StartMultipartUploadRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *StartMultipartUploadRequest) GetEntryId() string {
+	if x != nil {
+		return x.EntryId
+	}
+	return ""
+}
+
+type StartMultipartUploadResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UploadId      string                 `protobuf:"bytes,1,opt,name=upload_id,json=uploadId,proto3" json:"upload_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartMultipartUploadResponse) Reset() {
+	*x = StartMultipartUploadResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartMultipartUploadResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartMultipartUploadResponse) ProtoMessage() {}
+
+func (x *StartMultipartUploadResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartMultipartUploadResponse.ProtoReflect.Descriptor instead.
+func (*StartMultipartUploadResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *StartMultipartUploadResponse) GetUploadId() string {
+	if x != nil {
+		return x.UploadId
+	}
+	return ""
+}
+
+type PresignUploadPartsRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	EntryId  string                 `protobuf:"bytes,1,opt,name=entry_id,json=entryId,proto3" json:"entry_id,omitempty"`
+	UploadId string                 `protobuf:"bytes,2,opt,name=upload_id,json=uploadId,proto3" json:"upload_id,omitempty"`
+	// part_numbers are 1-based, at most 10000.
+	PartNumbers   []int32 `protobuf:"varint,3,rep,packed,name=part_numbers,json=partNumbers,proto3" json:"part_numbers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PresignUploadPartsRequest) Reset() {
+	*x = PresignUploadPartsRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PresignUploadPartsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PresignUploadPartsRequest) ProtoMessage() {}
+
+func (x *PresignUploadPartsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PresignUploadPartsRequest.ProtoReflect.Descriptor instead.
+func (*PresignUploadPartsRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *PresignUploadPartsRequest) GetEntryId() string {
+	if x != nil {
+		return x.EntryId
+	}
+	return ""
+}
+
+func (x *PresignUploadPartsRequest) GetUploadId() string {
+	if x != nil {
+		return x.UploadId
+	}
+	return ""
+}
+
+func (x *PresignUploadPartsRequest) GetPartNumbers() []int32 {
+	if x != nil {
+		return x.PartNumbers
+	}
+	return nil
+}
+
+type PresignUploadPartsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// urls[i] is a presigned PUT URL for part_numbers[i].
+	Urls          []string `protobuf:"bytes,1,rep,name=urls,proto3" json:"urls,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PresignUploadPartsResponse) Reset() {
+	*x = PresignUploadPartsResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PresignUploadPartsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PresignUploadPartsResponse) ProtoMessage() {}
+
+func (x *PresignUploadPartsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PresignUploadPartsResponse.ProtoReflect.Descriptor instead.
+func (*PresignUploadPartsResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *PresignUploadPartsResponse) GetUrls() []string {
+	if x != nil {
+		return x.Urls
+	}
+	return nil
+}
+
+type UploadedPart struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PartNumber    int32                  `protobuf:"varint,1,opt,name=part_number,json=partNumber,proto3" json:"part_number,omitempty"`
+	Etag          string                 `protobuf:"bytes,2,opt,name=etag,proto3" json:"etag,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadedPart) Reset() {
+	*x = UploadedPart{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadedPart) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadedPart) ProtoMessage() {}
+
+func (x *UploadedPart) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadedPart.ProtoReflect.Descriptor instead.
+func (*UploadedPart) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *UploadedPart) GetPartNumber() int32 {
+	if x != nil {
+		return x.PartNumber
+	}
+	return 0
+}
+
+func (x *UploadedPart) GetEtag() string {
+	if x != nil {
+		return x.Etag
+	}
+	return ""
+}
+
+type CompleteMultipartUploadRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EntryId       string                 `protobuf:"bytes,1,opt,name=entry_id,json=entryId,proto3" json:"entry_id,omitempty"`
+	UploadId      string                 `protobuf:"bytes,2,opt,name=upload_id,json=uploadId,proto3" json:"upload_id,omitempty"`
+	Parts         []*UploadedPart        `protobuf:"bytes,3,rep,name=parts,proto3" json:"parts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompleteMultipartUploadRequest) Reset() {
+	*x = CompleteMultipartUploadRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompleteMultipartUploadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompleteMultipartUploadRequest) ProtoMessage() {}
+
+func (x *CompleteMultipartUploadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[43]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompleteMultipartUploadRequest.ProtoReflect.Descriptor instead.
+func (*CompleteMultipartUploadRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *CompleteMultipartUploadRequest) GetEntryId() string {
+	if x != nil {
+		return x.EntryId
+	}
+	return ""
+}
+
+func (x *CompleteMultipartUploadRequest) GetUploadId() string {
+	if x != nil {
+		return x.UploadId
+	}
+	return ""
+}
+
+func (x *CompleteMultipartUploadRequest) GetParts() []*UploadedPart {
+	if x != nil {
+		return x.Parts
+	}
+	return nil
+}
+
+type CompleteMultipartUploadResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompleteMultipartUploadResponse) Reset() {
+	*x = CompleteMultipartUploadResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompleteMultipartUploadResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompleteMultipartUploadResponse) ProtoMessage() {}
+
+func (x *CompleteMultipartUploadResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompleteMultipartUploadResponse.ProtoReflect.Descriptor instead.
+func (*CompleteMultipartUploadResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{44}
+}
+
+type AbortMultipartUploadRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EntryId       string                 `protobuf:"bytes,1,opt,name=entry_id,json=entryId,proto3" json:"entry_id,omitempty"`
+	UploadId      string                 `protobuf:"bytes,2,opt,name=upload_id,json=uploadId,proto3" json:"upload_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AbortMultipartUploadRequest) Reset() {
+	*x = AbortMultipartUploadRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[45]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AbortMultipartUploadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AbortMultipartUploadRequest) ProtoMessage() {}
+
+func (x *AbortMultipartUploadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[45]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return nil
+	return mi.MessageOf(x)
 }
 
-func (x *Entry) GetNonceDetails() []byte {
+// Deprecated: Use AbortMultipartUploadRequest.ProtoReflect.Descriptor instead.
+func (*AbortMultipartUploadRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *AbortMultipartUploadRequest) GetEntryId() string {
 	if x != nil {
-		return x.NonceDetails
+		return x.EntryId
 	}
-	return nil
+	return ""
 }
 
-func (x *Entry) GetDeleted() bool {
+func (x *AbortMultipartUploadRequest) GetUploadId() string {
 	if x != nil {
-		return x.Deleted
+		return x.UploadId
 	}
-	return false
+	return ""
 }
 
-func (x *Entry) GetIsFile() bool {
+type AbortMultipartUploadResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AbortMultipartUploadResponse) Reset() {
+	*x = AbortMultipartUploadResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[46]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AbortMultipartUploadResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AbortMultipartUploadResponse) ProtoMessage() {}
+
+func (x *AbortMultipartUploadResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[46]
 	if x != nil {
-		return x.IsFile
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return false
+	return mi.MessageOf(x)
 }
 
-type File struct {
+// Deprecated: Use AbortMultipartUploadResponse.ProtoReflect.Descriptor instead.
+func (*AbortMultipartUploadResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{46}
+}
+
+// RequestUploadURLRequest asks for a fresh presigned PUT URL for the pending
+// file of an entry that was registered by an earlier sync, e.g. after the URL
+// returned then has expired.
+type RequestUploadURLRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	EntryId       string                 `protobuf:"bytes,1,opt,name=entry_id,json=entryId,proto3" json:"entry_id,omitempty"`
-	FileKey       []byte                 `protobuf:"bytes,2,opt,name=file_key,json=fileKey,proto3" json:"file_key,omitempty"`
-	Nonce         []byte                 `protobuf:"bytes,3,opt,name=nonce,proto3" json:"nonce,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *File) Reset() {
-	*x = File{}
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[9]
+func (x *RequestUploadURLRequest) Reset() {
+	*x = RequestUploadURLRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[47]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *File) String() string {
+func (x *RequestUploadURLRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*File) ProtoMessage() {}
+func (*RequestUploadURLRequest) ProtoMessage() {}
 
-func (x *File) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[9]
+func (x *RequestUploadURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[47]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -531,55 +2769,342 @@ func (x *File) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use File.ProtoReflect.Descriptor instead.
-func (*File) Descriptor() ([]byte, []int) {
-	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{9}
+// Deprecated: Use RequestUploadURLRequest.ProtoReflect.Descriptor instead.
+func (*RequestUploadURLRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{47}
 }
 
-func (x *File) GetEntryId() string {
+func (x *RequestUploadURLRequest) GetEntryId() string {
 	if x != nil {
 		return x.EntryId
 	}
 	return ""
 }
 
-func (x *File) GetFileKey() []byte {
+type RequestUploadURLResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RequestUploadURLResponse) Reset() {
+	*x = RequestUploadURLResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[48]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RequestUploadURLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestUploadURLResponse) ProtoMessage() {}
+
+func (x *RequestUploadURLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[48]
 	if x != nil {
-		return x.FileKey
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestUploadURLResponse.ProtoReflect.Descriptor instead.
+func (*RequestUploadURLResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{48}
+}
+
+func (x *RequestUploadURLResponse) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+// Session is a device login of the caller's account.
+type Session struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Id     string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Device *DeviceInfo            `protobuf:"bytes,2,opt,name=device,proto3" json:"device,omitempty"`
+	// ip is the address the session was last used from.
+	Ip string `protobuf:"bytes,3,opt,name=ip,proto3" json:"ip,omitempty"`
+	// created_at, last_used_at and expires_at are Unix times (seconds).
+	CreatedAt  int64 `protobuf:"varint,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	LastUsedAt int64 `protobuf:"varint,5,opt,name=last_used_at,json=lastUsedAt,proto3" json:"last_used_at,omitempty"`
+	ExpiresAt  int64 `protobuf:"varint,6,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	// current marks the session of the calling access token.
+	Current       bool `protobuf:"varint,7,opt,name=current,proto3" json:"current,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Session) Reset() {
+	*x = Session{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[49]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Session) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Session) ProtoMessage() {}
+
+func (x *Session) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[49]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Session.ProtoReflect.Descriptor instead.
+func (*Session) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{49}
+}
+
+func (x *Session) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Session) GetDevice() *DeviceInfo {
+	if x != nil {
+		return x.Device
+	}
+	return nil
+}
+
+func (x *Session) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *Session) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+func (x *Session) GetLastUsedAt() int64 {
+	if x != nil {
+		return x.LastUsedAt
+	}
+	return 0
+}
+
+func (x *Session) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
+func (x *Session) GetCurrent() bool {
+	if x != nil {
+		return x.Current
+	}
+	return false
+}
+
+type ListSessionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSessionsRequest) Reset() {
+	*x = ListSessionsRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[50]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSessionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSessionsRequest) ProtoMessage() {}
+
+func (x *ListSessionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[50]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSessionsRequest.ProtoReflect.Descriptor instead.
+func (*ListSessionsRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{50}
+}
+
+type ListSessionsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// sessions are ordered most recently used first.
+	Sessions      []*Session `protobuf:"bytes,1,rep,name=sessions,proto3" json:"sessions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSessionsResponse) Reset() {
+	*x = ListSessionsResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[51]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSessionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSessionsResponse) ProtoMessage() {}
+
+func (x *ListSessionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[51]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSessionsResponse.ProtoReflect.Descriptor instead.
+func (*ListSessionsResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{51}
+}
+
+func (x *ListSessionsResponse) GetSessions() []*Session {
+	if x != nil {
+		return x.Sessions
 	}
 	return nil
 }
 
-func (x *File) GetNonce() []byte {
-	if x != nil {
-		return x.Nonce
-	}
-	return nil
+// RevokeSessionRequest logs a device out: its refresh token is deleted and
+// its access tokens are rejected from then on.
+type RevokeSessionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RevokeSessionRequest) Reset() {
+	*x = RevokeSessionRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[52]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RevokeSessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RevokeSessionRequest) ProtoMessage() {}
+
+func (x *RevokeSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[52]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RevokeSessionRequest.ProtoReflect.Descriptor instead.
+func (*RevokeSessionRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{52}
+}
+
+func (x *RevokeSessionRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+type RevokeSessionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RevokeSessionResponse) Reset() {
+	*x = RevokeSessionResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[53]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RevokeSessionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RevokeSessionResponse) ProtoMessage() {}
+
+func (x *RevokeSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[53]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RevokeSessionResponse.ProtoReflect.Descriptor instead.
+func (*RevokeSessionResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{53}
 }
 
-type UploadTask struct {
+type RevokeAllOtherSessionsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	EntryId       string                 `protobuf:"bytes,1,opt,name=entry_id,json=entryId,proto3" json:"entry_id,omitempty"`
-	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *UploadTask) Reset() {
-	*x = UploadTask{}
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[10]
+func (x *RevokeAllOtherSessionsRequest) Reset() {
+	*x = RevokeAllOtherSessionsRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[54]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *UploadTask) String() string {
+func (x *RevokeAllOtherSessionsRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*UploadTask) ProtoMessage() {}
+func (*RevokeAllOtherSessionsRequest) ProtoMessage() {}
 
-func (x *UploadTask) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[10]
+func (x *RevokeAllOtherSessionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[54]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -590,49 +3115,34 @@ func (x *UploadTask) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use UploadTask.ProtoReflect.Descriptor instead.
-func (*UploadTask) Descriptor() ([]byte, []int) {
-	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{10}
-}
-
-func (x *UploadTask) GetEntryId() string {
-	if x != nil {
-		return x.EntryId
-	}
-	return ""
-}
-
-func (x *UploadTask) GetUrl() string {
-	if x != nil {
-		return x.Url
-	}
-	return ""
+// Deprecated: Use RevokeAllOtherSessionsRequest.ProtoReflect.Descriptor instead.
+func (*RevokeAllOtherSessionsRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{54}
 }
 
-type SyncRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	MaxVersion    int64                  `protobuf:"varint,1,opt,name=max_version,json=maxVersion,proto3" json:"max_version,omitempty"`
-	Entries       []*Entry               `protobuf:"bytes,2,rep,name=entries,proto3" json:"entries,omitempty"`
-	Files         []*File                `protobuf:"bytes,3,rep,name=files,proto3" json:"files,omitempty"`
+type RevokeAllOtherSessionsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// revoked is the number of sessions revoked.
+	Revoked       int64 `protobuf:"varint,1,opt,name=revoked,proto3" json:"revoked,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SyncRequest) Reset() {
-	*x = SyncRequest{}
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[11]
+func (x *RevokeAllOtherSessionsResponse) Reset() {
+	*x = RevokeAllOtherSessionsResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[55]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SyncRequest) String() string {
+func (x *RevokeAllOtherSessionsResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SyncRequest) ProtoMessage() {}
+func (*RevokeAllOtherSessionsResponse) ProtoMessage() {}
 
-func (x *SyncRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[11]
+func (x *RevokeAllOtherSessionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[55]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -643,58 +3153,40 @@ func (x *SyncRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SyncRequest.ProtoReflect.Descriptor instead.
-func (*SyncRequest) Descriptor() ([]byte, []int) {
-	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{11}
+// Deprecated: Use RevokeAllOtherSessionsResponse.ProtoReflect.Descriptor instead.
+func (*RevokeAllOtherSessionsResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{55}
 }
 
-func (x *SyncRequest) GetMaxVersion() int64 {
+func (x *RevokeAllOtherSessionsResponse) GetRevoked() int64 {
 	if x != nil {
-		return x.MaxVersion
+		return x.Revoked
 	}
 	return 0
 }
 
-func (x *SyncRequest) GetEntries() []*Entry {
-	if x != nil {
-		return x.Entries
-	}
-	return nil
-}
-
-func (x *SyncRequest) GetFiles() []*File {
-	if x != nil {
-		return x.Files
-	}
-	return nil
-}
-
-type SyncResponse struct {
-	state            protoimpl.MessageState `protogen:"open.v1"`
-	GlobalMaxVersion int64                  `protobuf:"varint,1,opt,name=global_max_version,json=globalMaxVersion,proto3" json:"global_max_version,omitempty"`
-	ProcessedEntries []*Entry               `protobuf:"bytes,2,rep,name=processed_entries,json=processedEntries,proto3" json:"processed_entries,omitempty"`
-	NewEntries       []*Entry               `protobuf:"bytes,3,rep,name=new_entries,json=newEntries,proto3" json:"new_entries,omitempty"`
-	NewFiles         []*File                `protobuf:"bytes,4,rep,name=new_files,json=newFiles,proto3" json:"new_files,omitempty"`
-	UploadTasks      []*UploadTask          `protobuf:"bytes,5,rep,name=upload_tasks,json=uploadTasks,proto3" json:"upload_tasks,omitempty"`
-	unknownFields    protoimpl.UnknownFields
-	sizeCache        protoimpl.SizeCache
+// LogoutRequest ends the session of the access token it is sent with.
+type LogoutRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SyncResponse) Reset() {
-	*x = SyncResponse{}
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[12]
+func (x *LogoutRequest) Reset() {
+	*x = LogoutRequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[56]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SyncResponse) String() string {
+func (x *LogoutRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SyncResponse) ProtoMessage() {}
+func (*LogoutRequest) ProtoMessage() {}
 
-func (x *SyncResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[12]
+func (x *LogoutRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[56]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -705,68 +3197,72 @@ func (x *SyncResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SyncResponse.ProtoReflect.Descriptor instead.
-func (*SyncResponse) Descriptor() ([]byte, []int) {
-	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{12}
+// Deprecated: Use LogoutRequest.ProtoReflect.Descriptor instead.
+func (*LogoutRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{56}
 }
 
-func (x *SyncResponse) GetGlobalMaxVersion() int64 {
-	if x != nil {
-		return x.GlobalMaxVersion
-	}
-	return 0
+type LogoutResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SyncResponse) GetProcessedEntries() []*Entry {
-	if x != nil {
-		return x.ProcessedEntries
-	}
-	return nil
+func (x *LogoutResponse) Reset() {
+	*x = LogoutResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[57]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-func (x *SyncResponse) GetNewEntries() []*Entry {
-	if x != nil {
-		return x.NewEntries
-	}
-	return nil
+func (x *LogoutResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-func (x *SyncResponse) GetNewFiles() []*File {
+func (*LogoutResponse) ProtoMessage() {}
+
+func (x *LogoutResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[57]
 	if x != nil {
-		return x.NewFiles
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return nil
+	return mi.MessageOf(x)
 }
 
-func (x *SyncResponse) GetUploadTasks() []*UploadTask {
-	if x != nil {
-		return x.UploadTasks
-	}
-	return nil
+// Deprecated: Use LogoutResponse.ProtoReflect.Descriptor instead.
+func (*LogoutResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{57}
 }
 
-type RefreshTokenRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	RefreshToken  string                 `protobuf:"bytes,1,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"`
+// Enable2FARequest starts a TOTP enrollment of the caller's account.
+type Enable2FARequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// code is a current TOTP or recovery code of the second factor the
+	// account already has, which the new one replaces; empty otherwise.
+	Code          string `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *RefreshTokenRequest) Reset() {
-	*x = RefreshTokenRequest{}
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[13]
+func (x *Enable2FARequest) Reset() {
+	*x = Enable2FARequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[58]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *RefreshTokenRequest) String() string {
+func (x *Enable2FARequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*RefreshTokenRequest) ProtoMessage() {}
+func (*Enable2FARequest) ProtoMessage() {}
 
-func (x *RefreshTokenRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[13]
+func (x *Enable2FARequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[58]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -777,41 +3273,43 @@ func (x *RefreshTokenRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use RefreshTokenRequest.ProtoReflect.Descriptor instead.
-func (*RefreshTokenRequest) Descriptor() ([]byte, []int) {
-	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{13}
+// Deprecated: Use Enable2FARequest.ProtoReflect.Descriptor instead.
+func (*Enable2FARequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{58}
 }
 
-func (x *RefreshTokenRequest) GetRefreshToken() string {
+func (x *Enable2FARequest) GetCode() string {
 	if x != nil {
-		return x.RefreshToken
+		return x.Code
 	}
 	return ""
 }
 
-type RefreshTokenResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	AccessToken   string                 `protobuf:"bytes,1,opt,name=access_token,json=accessToken,proto3" json:"access_token,omitempty"`
-	RefreshToken  string                 `protobuf:"bytes,2,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"`
+type Enable2FAResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// secret is the TOTP secret in base32, for manual entry.
+	Secret string `protobuf:"bytes,1,opt,name=secret,proto3" json:"secret,omitempty"`
+	// otpauth_uri is the otpauth URI of the secret, for authenticator apps.
+	OtpauthUri    string `protobuf:"bytes,2,opt,name=otpauth_uri,json=otpauthUri,proto3" json:"otpauth_uri,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *RefreshTokenResponse) Reset() {
-	*x = RefreshTokenResponse{}
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[14]
+func (x *Enable2FAResponse) Reset() {
+	*x = Enable2FAResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[59]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *RefreshTokenResponse) String() string {
+func (x *Enable2FAResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*RefreshTokenResponse) ProtoMessage() {}
+func (*Enable2FAResponse) ProtoMessage() {}
 
-func (x *RefreshTokenResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[14]
+func (x *Enable2FAResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[59]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -822,47 +3320,48 @@ func (x *RefreshTokenResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use RefreshTokenResponse.ProtoReflect.Descriptor instead.
-func (*RefreshTokenResponse) Descriptor() ([]byte, []int) {
-	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{14}
+// Deprecated: Use Enable2FAResponse.ProtoReflect.Descriptor instead.
+func (*Enable2FAResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{59}
 }
 
-func (x *RefreshTokenResponse) GetAccessToken() string {
+func (x *Enable2FAResponse) GetSecret() string {
 	if x != nil {
-		return x.AccessToken
+		return x.Secret
 	}
 	return ""
 }
 
-func (x *RefreshTokenResponse) GetRefreshToken() string {
+func (x *Enable2FAResponse) GetOtpauthUri() string {
 	if x != nil {
-		return x.RefreshToken
+		return x.OtpauthUri
 	}
 	return ""
 }
 
-type MarkUploadedRequest struct {
+// Confirm2FARequest completes the enrollment with a code of the new secret.
+type Confirm2FARequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	EntryId       string                 `protobuf:"bytes,1,opt,name=entry_id,json=entryId,proto3" json:"entry_id,omitempty"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *MarkUploadedRequest) Reset() {
-	*x = MarkUploadedRequest{}
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[15]
+func (x *Confirm2FARequest) Reset() {
+	*x = Confirm2FARequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[60]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *MarkUploadedRequest) String() string {
+func (x *Confirm2FARequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*MarkUploadedRequest) ProtoMessage() {}
+func (*Confirm2FARequest) ProtoMessage() {}
 
-func (x *MarkUploadedRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[15]
+func (x *Confirm2FARequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[60]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -873,39 +3372,42 @@ func (x *MarkUploadedRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use MarkUploadedRequest.ProtoReflect.Descriptor instead.
-func (*MarkUploadedRequest) Descriptor() ([]byte, []int) {
-	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{15}
+// Deprecated: Use Confirm2FARequest.ProtoReflect.Descriptor instead.
+func (*Confirm2FARequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{60}
 }
 
-func (x *MarkUploadedRequest) GetEntryId() string {
+func (x *Confirm2FARequest) GetCode() string {
 	if x != nil {
-		return x.EntryId
+		return x.Code
 	}
 	return ""
 }
 
-type MarkUploadedResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
+type Confirm2FAResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// recovery_codes are one-time codes that replace a lost authenticator.
+	// They are shown only once.
+	RecoveryCodes []string `protobuf:"bytes,1,rep,name=recovery_codes,json=recoveryCodes,proto3" json:"recovery_codes,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *MarkUploadedResponse) Reset() {
-	*x = MarkUploadedResponse{}
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[16]
+func (x *Confirm2FAResponse) Reset() {
+	*x = Confirm2FAResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[61]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *MarkUploadedResponse) String() string {
+func (x *Confirm2FAResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*MarkUploadedResponse) ProtoMessage() {}
+func (*Confirm2FAResponse) ProtoMessage() {}
 
-func (x *MarkUploadedResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[16]
+func (x *Confirm2FAResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[61]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -916,33 +3418,44 @@ func (x *MarkUploadedResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use MarkUploadedResponse.ProtoReflect.Descriptor instead.
-func (*MarkUploadedResponse) Descriptor() ([]byte, []int) {
-	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{16}
+// Deprecated: Use Confirm2FAResponse.ProtoReflect.Descriptor instead.
+func (*Confirm2FAResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{61}
 }
 
-type GetPresignedGetUrlRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	EntryId       string                 `protobuf:"bytes,1,opt,name=entry_id,json=entryId,proto3" json:"entry_id,omitempty"`
+func (x *Confirm2FAResponse) GetRecoveryCodes() []string {
+	if x != nil {
+		return x.RecoveryCodes
+	}
+	return nil
+}
+
+// Verify2FARequest completes a login with a TOTP or recovery code.
+type Verify2FARequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// challenge_id is LoginFinishResponse.two_factor_challenge.
+	ChallengeId   string      `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
+	Code          string      `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	Device        *DeviceInfo `protobuf:"bytes,3,opt,name=device,proto3" json:"device,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GetPresignedGetUrlRequest) Reset() {
-	*x = GetPresignedGetUrlRequest{}
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[17]
+func (x *Verify2FARequest) Reset() {
+	*x = Verify2FARequest{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[62]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetPresignedGetUrlRequest) String() string {
+func (x *Verify2FARequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetPresignedGetUrlRequest) ProtoMessage() {}
+func (*Verify2FARequest) ProtoMessage() {}
 
-func (x *GetPresignedGetUrlRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[17]
+func (x *Verify2FARequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[62]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -953,40 +3466,56 @@ func (x *GetPresignedGetUrlRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetPresignedGetUrlRequest.ProtoReflect.Descriptor instead.
-func (*GetPresignedGetUrlRequest) Descriptor() ([]byte, []int) {
-	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{17}
+// Deprecated: Use Verify2FARequest.ProtoReflect.Descriptor instead.
+func (*Verify2FARequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{62}
 }
 
-func (x *GetPresignedGetUrlRequest) GetEntryId() string {
+func (x *Verify2FARequest) GetChallengeId() string {
 	if x != nil {
-		return x.EntryId
+		return x.ChallengeId
 	}
 	return ""
 }
 
-type GetPresignedGetUrlResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *Verify2FARequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
 }
 
-func (x *GetPresignedGetUrlResponse) Reset() {
-	*x = GetPresignedGetUrlResponse{}
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[18]
+func (x *Verify2FARequest) GetDevice() *DeviceInfo {
+	if x != nil {
+		return x.Device
+	}
+	return nil
+}
+
+type Verify2FAResponse struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	AccessToken     string                 `protobuf:"bytes,1,opt,name=access_token,json=accessToken,proto3" json:"access_token,omitempty"`
+	RefreshToken    string                 `protobuf:"bytes,2,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"`
+	WrappedVaultKey []byte                 `protobuf:"bytes,3,opt,name=wrapped_vault_key,json=wrappedVaultKey,proto3" json:"wrapped_vault_key,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Verify2FAResponse) Reset() {
+	*x = Verify2FAResponse{}
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[63]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetPresignedGetUrlResponse) String() string {
+func (x *Verify2FAResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetPresignedGetUrlResponse) ProtoMessage() {}
+func (*Verify2FAResponse) ProtoMessage() {}
 
-func (x *GetPresignedGetUrlResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[18]
+func (x *Verify2FAResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_gopfkeeper_proto_msgTypes[63]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -997,42 +3526,129 @@ func (x *GetPresignedGetUrlResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetPresignedGetUrlResponse.ProtoReflect.Descriptor instead.
-func (*GetPresignedGetUrlResponse) Descriptor() ([]byte, []int) {
-	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{18}
+// Deprecated: Use Verify2FAResponse.ProtoReflect.Descriptor instead.
+func (*Verify2FAResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_gopfkeeper_proto_rawDescGZIP(), []int{63}
 }
 
-func (x *GetPresignedGetUrlResponse) GetUrl() string {
+func (x *Verify2FAResponse) GetAccessToken() string {
 	if x != nil {
-		return x.Url
+		return x.AccessToken
+	}
+	return ""
+}
+
+func (x *Verify2FAResponse) GetRefreshToken() string {
+	if x != nil {
+		return x.RefreshToken
 	}
 	return ""
 }
 
+func (x *Verify2FAResponse) GetWrappedVaultKey() []byte {
+	if x != nil {
+		return x.WrappedVaultKey
+	}
+	return nil
+}
+
 var File_internal_proto_gopfkeeper_proto protoreflect.FileDescriptor
 
 const file_internal_proto_gopfkeeper_proto_rawDesc = "" +
 	"\n" +
-	"\x1finternal/proto/gopfkeeper.proto\x12\x12gophkeeper.service\"a\n" +
+	"\x1finternal/proto/gopfkeeper.proto\x12\x12gophkeeper.service\"o\n" +
+	"\tKDFParams\x12\x1c\n" +
+	"\talgorithm\x18\x01 \x01(\tR\talgorithm\x12\x12\n" +
+	"\x04time\x18\x02 \x01(\rR\x04time\x12\x16\n" +
+	"\x06memory\x18\x03 \x01(\rR\x06memory\x12\x18\n" +
+	"\athreads\x18\x04 \x01(\rR\athreads\"\xbe\x01\n" +
 	"\x13RegisterUserRequest\x12\x1a\n" +
 	"\busername\x18\x01 \x01(\tR\busername\x12\x12\n" +
 	"\x04salt\x18\x02 \x01(\fR\x04salt\x12\x1a\n" +
-	"\bverifier\x18\x03 \x01(\fR\bverifier\"2\n" +
+	"\bverifier\x18\x03 \x01(\fR\bverifier\x12*\n" +
+	"\x11wrapped_vault_key\x18\x04 \x01(\fR\x0fwrappedVaultKey\x12/\n" +
+	"\x03kdf\x18\x05 \x01(\v2\x1d.gophkeeper.service.KDFParamsR\x03kdf\"2\n" +
 	"\x14RegisterUserResponse\x12\x1a\n" +
 	"\busername\x18\x01 \x01(\tR\busername\",\n" +
 	"\x0eGetSaltRequest\x12\x1a\n" +
-	"\busername\x18\x01 \x01(\tR\busername\"%\n" +
+	"\busername\x18\x01 \x01(\tR\busername\"\xb7\x01\n" +
 	"\x0fGetSaltResponse\x12\x12\n" +
-	"\x04salt\x18\x01 \x01(\fR\x04salt\"Y\n" +
+	"\x04salt\x18\x01 \x01(\fR\x04salt\x12\x1f\n" +
+	"\vlegacy_keys\x18\x02 \x01(\bR\n" +
+	"legacyKeys\x12/\n" +
+	"\x03kdf\x18\x03 \x01(\v2\x1d.gophkeeper.service.KDFParamsR\x03kdf\x12>\n" +
+	"\vkdf_upgrade\x18\x04 \x01(\v2\x1d.gophkeeper.service.KDFParamsR\n" +
+	"kdfUpgrade\"c\n" +
+	"\n" +
+	"DeviceInfo\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1a\n" +
+	"\bplatform\x18\x02 \x01(\tR\bplatform\x12%\n" +
+	"\x0eclient_version\x18\x03 \x01(\tR\rclientVersion\"\xe0\x01\n" +
 	"\fLoginRequest\x12\x1a\n" +
 	"\busername\x18\x01 \x01(\tR\busername\x12-\n" +
-	"\x12verifier_candidate\x18\x02 \x01(\fR\x11verifierCandidate\"W\n" +
+	"\x12verifier_candidate\x18\x02 \x01(\fR\x11verifierCandidate\x12!\n" +
+	"\fsrp_verifier\x18\x03 \x01(\fR\vsrpVerifier\x12*\n" +
+	"\x11wrapped_vault_key\x18\x04 \x01(\fR\x0fwrappedVaultKey\x126\n" +
+	"\x06device\x18\x05 \x01(\v2\x1e.gophkeeper.service.DeviceInfoR\x06device\"W\n" +
 	"\rLoginResponse\x12!\n" +
 	"\faccess_token\x18\x01 \x01(\tR\vaccessToken\x12#\n" +
-	"\rrefresh_token\x18\x02 \x01(\tR\frefreshToken\"\r\n" +
+	"\rrefresh_token\x18\x02 \x01(\tR\frefreshToken\"T\n" +
+	"\x11LoginStartRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12#\n" +
+	"\rclient_public\x18\x02 \x01(\fR\fclientPublic\"p\n" +
+	"\x12LoginStartResponse\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12#\n" +
+	"\rserver_public\x18\x02 \x01(\fR\fserverPublicJ\x04\b\x03\x10\x04R\x10upgrade_required\"\x8e\x01\n" +
+	"\x12LoginFinishRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12!\n" +
+	"\fclient_proof\x18\x02 \x01(\fR\vclientProof\x126\n" +
+	"\x06device\x18\x03 \x01(\v2\x1e.gophkeeper.service.DeviceInfoR\x06device\"\xde\x01\n" +
+	"\x13LoginFinishResponse\x12!\n" +
+	"\fserver_proof\x18\x01 \x01(\fR\vserverProof\x12!\n" +
+	"\faccess_token\x18\x02 \x01(\tR\vaccessToken\x12#\n" +
+	"\rrefresh_token\x18\x03 \x01(\tR\frefreshToken\x12*\n" +
+	"\x11wrapped_vault_key\x18\x04 \x01(\fR\x0fwrappedVaultKey\x120\n" +
+	"\x14two_factor_challenge\x18\x05 \x01(\tR\x12twoFactorChallenge\"c\n" +
+	"\x12UpgradeKeysRequest\x12!\n" +
+	"\fsrp_verifier\x18\x01 \x01(\fR\vsrpVerifier\x12*\n" +
+	"\x11wrapped_vault_key\x18\x02 \x01(\fR\x0fwrappedVaultKey\"\x15\n" +
+	"\x13UpgradeKeysResponse\"\xa0\x03\n" +
+	"\x15ChangePasswordRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12!\n" +
+	"\fclient_proof\x18\x02 \x01(\fR\vclientProof\x12\x12\n" +
+	"\x04salt\x18\x03 \x01(\fR\x04salt\x12!\n" +
+	"\fsrp_verifier\x18\x04 \x01(\fR\vsrpVerifier\x12*\n" +
+	"\x11wrapped_vault_key\x18\x05 \x01(\fR\x0fwrappedVaultKey\x12/\n" +
+	"\x03kdf\x18\x06 \x01(\v2\x1d.gophkeeper.service.KDFParamsR\x03kdf\x126\n" +
+	"\x06device\x18\a \x01(\v2\x1e.gophkeeper.service.DeviceInfoR\x06device\x12\x14\n" +
+	"\x05rekey\x18\b \x01(\bR\x05rekey\x123\n" +
+	"\aentries\x18\t \x03(\v2\x19.gophkeeper.service.EntryR\aentries\x12.\n" +
+	"\x05files\x18\n" +
+	" \x03(\v2\x18.gophkeeper.service.FileR\x05files\"\x96\x02\n" +
+	"\x16ChangePasswordResponse\x12!\n" +
+	"\fserver_proof\x18\x01 \x01(\fR\vserverProof\x12!\n" +
+	"\faccess_token\x18\x02 \x01(\tR\vaccessToken\x12#\n" +
+	"\rrefresh_token\x18\x03 \x01(\tR\frefreshToken\x12T\n" +
+	"\bversions\x18\x04 \x03(\v28.gophkeeper.service.ChangePasswordResponse.VersionsEntryR\bversions\x1a;\n" +
+	"\rVersionsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"\xe9\x01\n" +
+	"\x11UpgradeKDFRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12!\n" +
+	"\fclient_proof\x18\x02 \x01(\fR\vclientProof\x12\x12\n" +
+	"\x04salt\x18\x03 \x01(\fR\x04salt\x12/\n" +
+	"\x03kdf\x18\x04 \x01(\v2\x1d.gophkeeper.service.KDFParamsR\x03kdf\x12!\n" +
+	"\fsrp_verifier\x18\x05 \x01(\fR\vsrpVerifier\x12*\n" +
+	"\x11wrapped_vault_key\x18\x06 \x01(\fR\x0fwrappedVaultKey\"7\n" +
+	"\x12UpgradeKDFResponse\x12!\n" +
+	"\fserver_proof\x18\x01 \x01(\fR\vserverProof\"\r\n" +
 	"\vPingRequest\"&\n" +
 	"\fPingResponse\x12\x16\n" +
-	"\x06status\x18\x01 \x01(\tR\x06status\"\xe6\x01\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\"\x89\x02\n" +
 	"\x05Entry\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
 	"\aversion\x18\x02 \x01(\x03R\aversion\x12\x1a\n" +
@@ -1041,11 +3657,13 @@ const file_internal_proto_gopfkeeper_proto_rawDesc = "" +
 	"\adetails\x18\x05 \x01(\fR\adetails\x12#\n" +
 	"\rnonce_details\x18\x06 \x01(\fR\fnonceDetails\x12\x18\n" +
 	"\adeleted\x18\a \x01(\bR\adeleted\x12\x17\n" +
-	"\ais_file\x18\b \x01(\bR\x06isFile\"R\n" +
+	"\ais_file\x18\b \x01(\bR\x06isFile\x12!\n" +
+	"\fbase_version\x18\t \x01(\x03R\vbaseVersion\"j\n" +
 	"\x04File\x12\x19\n" +
 	"\bentry_id\x18\x01 \x01(\tR\aentryId\x12\x19\n" +
 	"\bfile_key\x18\x02 \x01(\fR\afileKey\x12\x14\n" +
-	"\x05nonce\x18\x03 \x01(\fR\x05nonce\"9\n" +
+	"\x05nonce\x18\x03 \x01(\fR\x05nonce\x12\x16\n" +
+	"\x06digest\x18\x04 \x01(\fR\x06digest\"9\n" +
 	"\n" +
 	"UploadTask\x12\x19\n" +
 	"\bentry_id\x18\x01 \x01(\tR\aentryId\x12\x10\n" +
@@ -1054,14 +3672,15 @@ const file_internal_proto_gopfkeeper_proto_rawDesc = "" +
 	"\vmax_version\x18\x01 \x01(\x03R\n" +
 	"maxVersion\x123\n" +
 	"\aentries\x18\x02 \x03(\v2\x19.gophkeeper.service.EntryR\aentries\x12.\n" +
-	"\x05files\x18\x03 \x03(\v2\x18.gophkeeper.service.FileR\x05files\"\xba\x02\n" +
+	"\x05files\x18\x03 \x03(\v2\x18.gophkeeper.service.FileR\x05files\"\xf3\x02\n" +
 	"\fSyncResponse\x12,\n" +
 	"\x12global_max_version\x18\x01 \x01(\x03R\x10globalMaxVersion\x12F\n" +
 	"\x11processed_entries\x18\x02 \x03(\v2\x19.gophkeeper.service.EntryR\x10processedEntries\x12:\n" +
 	"\vnew_entries\x18\x03 \x03(\v2\x19.gophkeeper.service.EntryR\n" +
 	"newEntries\x125\n" +
 	"\tnew_files\x18\x04 \x03(\v2\x18.gophkeeper.service.FileR\bnewFiles\x12A\n" +
-	"\fupload_tasks\x18\x05 \x03(\v2\x1e.gophkeeper.service.UploadTaskR\vuploadTasks\":\n" +
+	"\fupload_tasks\x18\x05 \x03(\v2\x1e.gophkeeper.service.UploadTaskR\vuploadTasks\x127\n" +
+	"\tconflicts\x18\x06 \x03(\v2\x19.gophkeeper.service.EntryR\tconflicts\":\n" +
 	"\x13RefreshTokenRequest\x12#\n" +
 	"\rrefresh_token\x18\x01 \x01(\tR\frefreshToken\"^\n" +
 	"\x14RefreshTokenResponse\x12!\n" +
@@ -1073,16 +3692,130 @@ const file_internal_proto_gopfkeeper_proto_rawDesc = "" +
 	"\x19GetPresignedGetUrlRequest\x12\x19\n" +
 	"\bentry_id\x18\x01 \x01(\tR\aentryId\".\n" +
 	"\x1aGetPresignedGetUrlResponse\x12\x10\n" +
-	"\x03url\x18\x01 \x01(\tR\x03url2\xe9\x05\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\"\xfa\x01\n" +
+	"\bRevision\x12\x19\n" +
+	"\bentry_id\x18\x01 \x01(\tR\aentryId\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\x03R\aversion\x12\x1a\n" +
+	"\boverview\x18\x03 \x01(\fR\boverview\x12%\n" +
+	"\x0enonce_overview\x18\x04 \x01(\fR\rnonceOverview\x12\x18\n" +
+	"\adetails\x18\x05 \x01(\fR\adetails\x12#\n" +
+	"\rnonce_details\x18\x06 \x01(\fR\fnonceDetails\x12\x18\n" +
+	"\adeleted\x18\a \x01(\bR\adeleted\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\b \x01(\x03R\tcreatedAt\"1\n" +
+	"\x14ListRevisionsRequest\x12\x19\n" +
+	"\bentry_id\x18\x01 \x01(\tR\aentryId\"S\n" +
+	"\x15ListRevisionsResponse\x12:\n" +
+	"\trevisions\x18\x01 \x03(\v2\x1c.gophkeeper.service.RevisionR\trevisions\"I\n" +
+	"\x12GetRevisionRequest\x12\x19\n" +
+	"\bentry_id\x18\x01 \x01(\tR\aentryId\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\x03R\aversion\"O\n" +
+	"\x13GetRevisionResponse\x128\n" +
+	"\brevision\x18\x01 \x01(\v2\x1c.gophkeeper.service.RevisionR\brevision\"L\n" +
+	"\x14UpdateFileKeyRequest\x12\x19\n" +
+	"\bentry_id\x18\x01 \x01(\tR\aentryId\x12\x19\n" +
+	"\bfile_key\x18\x02 \x01(\fR\afileKey\"\x17\n" +
+	"\x15UpdateFileKeyResponse\"8\n" +
+	"\x1bStartMultipartUploadRequest\x12\x19\n" +
+	"\bentry_id\x18\x01 \x01(\tR\aentryId\";\n" +
+	"\x1cStartMultipartUploadResponse\x12\x1b\n" +
+	"\tupload_id\x18\x01 \x01(\tR\buploadId\"v\n" +
+	"\x19PresignUploadPartsRequest\x12\x19\n" +
+	"\bentry_id\x18\x01 \x01(\tR\aentryId\x12\x1b\n" +
+	"\tupload_id\x18\x02 \x01(\tR\buploadId\x12!\n" +
+	"\fpart_numbers\x18\x03 \x03(\x05R\vpartNumbers\"0\n" +
+	"\x1aPresignUploadPartsResponse\x12\x12\n" +
+	"\x04urls\x18\x01 \x03(\tR\x04urls\"C\n" +
+	"\fUploadedPart\x12\x1f\n" +
+	"\vpart_number\x18\x01 \x01(\x05R\n" +
+	"partNumber\x12\x12\n" +
+	"\x04etag\x18\x02 \x01(\tR\x04etag\"\x90\x01\n" +
+	"\x1eCompleteMultipartUploadRequest\x12\x19\n" +
+	"\bentry_id\x18\x01 \x01(\tR\aentryId\x12\x1b\n" +
+	"\tupload_id\x18\x02 \x01(\tR\buploadId\x126\n" +
+	"\x05parts\x18\x03 \x03(\v2 .gophkeeper.service.UploadedPartR\x05parts\"!\n" +
+	"\x1fCompleteMultipartUploadResponse\"U\n" +
+	"\x1bAbortMultipartUploadRequest\x12\x19\n" +
+	"\bentry_id\x18\x01 \x01(\tR\aentryId\x12\x1b\n" +
+	"\tupload_id\x18\x02 \x01(\tR\buploadId\"\x1e\n" +
+	"\x1cAbortMultipartUploadResponse\"4\n" +
+	"\x17RequestUploadURLRequest\x12\x19\n" +
+	"\bentry_id\x18\x01 \x01(\tR\aentryId\",\n" +
+	"\x18RequestUploadURLResponse\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\"\xdb\x01\n" +
+	"\aSession\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x126\n" +
+	"\x06device\x18\x02 \x01(\v2\x1e.gophkeeper.service.DeviceInfoR\x06device\x12\x0e\n" +
+	"\x02ip\x18\x03 \x01(\tR\x02ip\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\x03R\tcreatedAt\x12 \n" +
+	"\flast_used_at\x18\x05 \x01(\x03R\n" +
+	"lastUsedAt\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x06 \x01(\x03R\texpiresAt\x12\x18\n" +
+	"\acurrent\x18\a \x01(\bR\acurrent\"\x15\n" +
+	"\x13ListSessionsRequest\"O\n" +
+	"\x14ListSessionsResponse\x127\n" +
+	"\bsessions\x18\x01 \x03(\v2\x1b.gophkeeper.service.SessionR\bsessions\"5\n" +
+	"\x14RevokeSessionRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\"\x17\n" +
+	"\x15RevokeSessionResponse\"\x1f\n" +
+	"\x1dRevokeAllOtherSessionsRequest\":\n" +
+	"\x1eRevokeAllOtherSessionsResponse\x12\x18\n" +
+	"\arevoked\x18\x01 \x01(\x03R\arevoked\"\x0f\n" +
+	"\rLogoutRequest\"\x10\n" +
+	"\x0eLogoutResponse\"&\n" +
+	"\x10Enable2FARequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\"L\n" +
+	"\x11Enable2FAResponse\x12\x16\n" +
+	"\x06secret\x18\x01 \x01(\tR\x06secret\x12\x1f\n" +
+	"\votpauth_uri\x18\x02 \x01(\tR\n" +
+	"otpauthUri\"'\n" +
+	"\x11Confirm2FARequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\";\n" +
+	"\x12Confirm2FAResponse\x12%\n" +
+	"\x0erecovery_codes\x18\x01 \x03(\tR\rrecoveryCodes\"\x81\x01\n" +
+	"\x10Verify2FARequest\x12!\n" +
+	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\tR\x04code\x126\n" +
+	"\x06device\x18\x03 \x01(\v2\x1e.gophkeeper.service.DeviceInfoR\x06device\"\x87\x01\n" +
+	"\x11Verify2FAResponse\x12!\n" +
+	"\faccess_token\x18\x01 \x01(\tR\vaccessToken\x12#\n" +
+	"\rrefresh_token\x18\x02 \x01(\tR\frefreshToken\x12*\n" +
+	"\x11wrapped_vault_key\x18\x03 \x01(\fR\x0fwrappedVaultKey2\x83\x16\n" +
 	"\x11GophKeeperService\x12a\n" +
 	"\fRegisterUser\x12'.gophkeeper.service.RegisterUserRequest\x1a(.gophkeeper.service.RegisterUserResponse\x12R\n" +
 	"\aGetSalt\x12\".gophkeeper.service.GetSaltRequest\x1a#.gophkeeper.service.GetSaltResponse\x12L\n" +
-	"\x05Login\x12 .gophkeeper.service.LoginRequest\x1a!.gophkeeper.service.LoginResponse\x12I\n" +
+	"\x05Login\x12 .gophkeeper.service.LoginRequest\x1a!.gophkeeper.service.LoginResponse\x12[\n" +
+	"\n" +
+	"LoginStart\x12%.gophkeeper.service.LoginStartRequest\x1a&.gophkeeper.service.LoginStartResponse\x12^\n" +
+	"\vLoginFinish\x12&.gophkeeper.service.LoginFinishRequest\x1a'.gophkeeper.service.LoginFinishResponse\x12^\n" +
+	"\vUpgradeKeys\x12&.gophkeeper.service.UpgradeKeysRequest\x1a'.gophkeeper.service.UpgradeKeysResponse\x12g\n" +
+	"\x0eChangePassword\x12).gophkeeper.service.ChangePasswordRequest\x1a*.gophkeeper.service.ChangePasswordResponse\x12[\n" +
+	"\n" +
+	"UpgradeKDF\x12%.gophkeeper.service.UpgradeKDFRequest\x1a&.gophkeeper.service.UpgradeKDFResponse\x12I\n" +
 	"\x04Ping\x12\x1f.gophkeeper.service.PingRequest\x1a .gophkeeper.service.PingResponse\x12I\n" +
 	"\x04Sync\x12\x1f.gophkeeper.service.SyncRequest\x1a .gophkeeper.service.SyncResponse\x12a\n" +
 	"\fRefreshToken\x12'.gophkeeper.service.RefreshTokenRequest\x1a(.gophkeeper.service.RefreshTokenResponse\x12a\n" +
 	"\fMarkUploaded\x12'.gophkeeper.service.MarkUploadedRequest\x1a(.gophkeeper.service.MarkUploadedResponse\x12s\n" +
-	"\x12GetPresignedGetUrl\x12-.gophkeeper.service.GetPresignedGetUrlRequest\x1a..gophkeeper.service.GetPresignedGetUrlResponseB8Z6github.com/dmitrijs2005/gophkeeper/internal/grpc/protob\x06proto3"
+	"\x12GetPresignedGetUrl\x12-.gophkeeper.service.GetPresignedGetUrlRequest\x1a..gophkeeper.service.GetPresignedGetUrlResponse\x12d\n" +
+	"\rListRevisions\x12(.gophkeeper.service.ListRevisionsRequest\x1a).gophkeeper.service.ListRevisionsResponse\x12^\n" +
+	"\vGetRevision\x12&.gophkeeper.service.GetRevisionRequest\x1a'.gophkeeper.service.GetRevisionResponse\x12d\n" +
+	"\rUpdateFileKey\x12(.gophkeeper.service.UpdateFileKeyRequest\x1a).gophkeeper.service.UpdateFileKeyResponse\x12y\n" +
+	"\x14StartMultipartUpload\x12/.gophkeeper.service.StartMultipartUploadRequest\x1a0.gophkeeper.service.StartMultipartUploadResponse\x12s\n" +
+	"\x12PresignUploadParts\x12-.gophkeeper.service.PresignUploadPartsRequest\x1a..gophkeeper.service.PresignUploadPartsResponse\x12\x82\x01\n" +
+	"\x17CompleteMultipartUpload\x122.gophkeeper.service.CompleteMultipartUploadRequest\x1a3.gophkeeper.service.CompleteMultipartUploadResponse\x12y\n" +
+	"\x14AbortMultipartUpload\x12/.gophkeeper.service.AbortMultipartUploadRequest\x1a0.gophkeeper.service.AbortMultipartUploadResponse\x12m\n" +
+	"\x10RequestUploadURL\x12+.gophkeeper.service.RequestUploadURLRequest\x1a,.gophkeeper.service.RequestUploadURLResponse\x12a\n" +
+	"\fListSessions\x12'.gophkeeper.service.ListSessionsRequest\x1a(.gophkeeper.service.ListSessionsResponse\x12d\n" +
+	"\rRevokeSession\x12(.gophkeeper.service.RevokeSessionRequest\x1a).gophkeeper.service.RevokeSessionResponse\x12\x7f\n" +
+	"\x16RevokeAllOtherSessions\x121.gophkeeper.service.RevokeAllOtherSessionsRequest\x1a2.gophkeeper.service.RevokeAllOtherSessionsResponse\x12O\n" +
+	"\x06Logout\x12!.gophkeeper.service.LogoutRequest\x1a\".gophkeeper.service.LogoutResponse\x12X\n" +
+	"\tEnable2FA\x12$.gophkeeper.service.Enable2FARequest\x1a%.gophkeeper.service.Enable2FAResponse\x12[\n" +
+	"\n" +
+	"Confirm2FA\x12%.gophkeeper.service.Confirm2FARequest\x1a&.gophkeeper.service.Confirm2FAResponse\x12X\n" +
+	"\tVerify2FA\x12$.gophkeeper.service.Verify2FARequest\x1a%.gophkeeper.service.Verify2FAResponseB8Z6github.com/dmitrijs2005/gophkeeper/internal/grpc/protob\x06proto3"
 
 var (
 	file_internal_proto_gopfkeeper_proto_rawDescOnce sync.Once
@@ -1096,56 +3829,160 @@ func file_internal_proto_gopfkeeper_proto_rawDescGZIP() []byte {
 	return file_internal_proto_gopfkeeper_proto_rawDescData
 }
 
-var file_internal_proto_gopfkeeper_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_internal_proto_gopfkeeper_proto_msgTypes = make([]protoimpl.MessageInfo, 65)
 var file_internal_proto_gopfkeeper_proto_goTypes = []any{
-	(*RegisterUserRequest)(nil),        // 0: gophkeeper.service.RegisterUserRequest
-	(*RegisterUserResponse)(nil),       // 1: gophkeeper.service.RegisterUserResponse
-	(*GetSaltRequest)(nil),             // 2: gophkeeper.service.GetSaltRequest
-	(*GetSaltResponse)(nil),            // 3: gophkeeper.service.GetSaltResponse
-	(*LoginRequest)(nil),               // 4: gophkeeper.service.LoginRequest
-	(*LoginResponse)(nil),              // 5: gophkeeper.service.LoginResponse
-	(*PingRequest)(nil),                // 6: gophkeeper.service.PingRequest
-	(*PingResponse)(nil),               // 7: gophkeeper.service.PingResponse
-	(*Entry)(nil),                      // 8: gophkeeper.service.Entry
-	(*File)(nil),                       // 9: gophkeeper.service.File
-	(*UploadTask)(nil),                 // 10: gophkeeper.service.UploadTask
-	(*SyncRequest)(nil),                // 11: gophkeeper.service.SyncRequest
-	(*SyncResponse)(nil),               // 12: gophkeeper.service.SyncResponse
-	(*RefreshTokenRequest)(nil),        // 13: gophkeeper.service.RefreshTokenRequest
-	(*RefreshTokenResponse)(nil),       // 14: gophkeeper.service.RefreshTokenResponse
-	(*MarkUploadedRequest)(nil),        // 15: gophkeeper.service.MarkUploadedRequest
-	(*MarkUploadedResponse)(nil),       // 16: gophkeeper.service.MarkUploadedResponse
-	(*GetPresignedGetUrlRequest)(nil),  // 17: gophkeeper.service.GetPresignedGetUrlRequest
-	(*GetPresignedGetUrlResponse)(nil), // 18: gophkeeper.service.GetPresignedGetUrlResponse
+	(*KDFParams)(nil),                       // 0: gophkeeper.service.KDFParams
+	(*RegisterUserRequest)(nil),             // 1: gophkeeper.service.RegisterUserRequest
+	(*RegisterUserResponse)(nil),            // 2: gophkeeper.service.RegisterUserResponse
+	(*GetSaltRequest)(nil),                  // 3: gophkeeper.service.GetSaltRequest
+	(*GetSaltResponse)(nil),                 // 4: gophkeeper.service.GetSaltResponse
+	(*DeviceInfo)(nil),                      // 5: gophkeeper.service.DeviceInfo
+	(*LoginRequest)(nil),                    // 6: gophkeeper.service.LoginRequest
+	(*LoginResponse)(nil),                   // 7: gophkeeper.service.LoginResponse
+	(*LoginStartRequest)(nil),               // 8: gophkeeper.service.LoginStartRequest
+	(*LoginStartResponse)(nil),              // 9: gophkeeper.service.LoginStartResponse
+	(*LoginFinishRequest)(nil),              // 10: gophkeeper.service.LoginFinishRequest
+	(*LoginFinishResponse)(nil),             // 11: gophkeeper.service.LoginFinishResponse
+	(*UpgradeKeysRequest)(nil),              // 12: gophkeeper.service.UpgradeKeysRequest
+	(*UpgradeKeysResponse)(nil),             // 13: gophkeeper.service.UpgradeKeysResponse
+	(*ChangePasswordRequest)(nil),           // 14: gophkeeper.service.ChangePasswordRequest
+	(*ChangePasswordResponse)(nil),          // 15: gophkeeper.service.ChangePasswordResponse
+	(*UpgradeKDFRequest)(nil),               // 16: gophkeeper.service.UpgradeKDFRequest
+	(*UpgradeKDFResponse)(nil),              // 17: gophkeeper.service.UpgradeKDFResponse
+	(*PingRequest)(nil),                     // 18: gophkeeper.service.PingRequest
+	(*PingResponse)(nil),                    // 19: gophkeeper.service.PingResponse
+	(*Entry)(nil),                           // 20: gophkeeper.service.Entry
+	(*File)(nil),                            // 21: gophkeeper.service.File
+	(*UploadTask)(nil),                      // 22: gophkeeper.service.UploadTask
+	(*SyncRequest)(nil),                     // 23: gophkeeper.service.SyncRequest
+	(*SyncResponse)(nil),                    // 24: gophkeeper.service.SyncResponse
+	(*RefreshTokenRequest)(nil),             // 25: gophkeeper.service.RefreshTokenRequest
+	(*RefreshTokenResponse)(nil),            // 26: gophkeeper.service.RefreshTokenResponse
+	(*MarkUploadedRequest)(nil),             // 27: gophkeeper.service.MarkUploadedRequest
+	(*MarkUploadedResponse)(nil),            // 28: gophkeeper.service.MarkUploadedResponse
+	(*GetPresignedGetUrlRequest)(nil),       // 29: gophkeeper.service.GetPresignedGetUrlRequest
+	(*GetPresignedGetUrlResponse)(nil),      // 30: gophkeeper.service.GetPresignedGetUrlResponse
+	(*Revision)(nil),                        // 31: gophkeeper.service.Revision
+	(*ListRevisionsRequest)(nil),            // 32: gophkeeper.service.ListRevisionsRequest
+	(*ListRevisionsResponse)(nil),           // 33: gophkeeper.service.ListRevisionsResponse
+	(*GetRevisionRequest)(nil),              // 34: gophkeeper.service.GetRevisionRequest
+	(*GetRevisionResponse)(nil),             // 35: gophkeeper.service.GetRevisionResponse
+	(*UpdateFileKeyRequest)(nil),            // 36: gophkeeper.service.UpdateFileKeyRequest
+	(*UpdateFileKeyResponse)(nil),           // 37: gophkeeper.service.UpdateFileKeyResponse
+	(*StartMultipartUploadRequest)(nil),     // 38: gophkeeper.service.StartMultipartUploadRequest
+	(*StartMultipartUploadResponse)(nil),    // 39: gophkeeper.service.StartMultipartUploadResponse
+	(*PresignUploadPartsRequest)(nil),       // 40: gophkeeper.service.PresignUploadPartsRequest
+	(*PresignUploadPartsResponse)(nil),      // 41: gophkeeper.service.PresignUploadPartsResponse
+	(*UploadedPart)(nil),                    // 42: gophkeeper.service.UploadedPart
+	(*CompleteMultipartUploadRequest)(nil),  // 43: gophkeeper.service.CompleteMultipartUploadRequest
+	(*CompleteMultipartUploadResponse)(nil), // 44: gophkeeper.service.CompleteMultipartUploadResponse
+	(*AbortMultipartUploadRequest)(nil),     // 45: gophkeeper.service.AbortMultipartUploadRequest
+	(*AbortMultipartUploadResponse)(nil),    // 46: gophkeeper.service.AbortMultipartUploadResponse
+	(*RequestUploadURLRequest)(nil),         // 47: gophkeeper.service.RequestUploadURLRequest
+	(*RequestUploadURLResponse)(nil),        // 48: gophkeeper.service.RequestUploadURLResponse
+	(*Session)(nil),                         // 49: gophkeeper.service.Session
+	(*ListSessionsRequest)(nil),             // 50: gophkeeper.service.ListSessionsRequest
+	(*ListSessionsResponse)(nil),            // 51: gophkeeper.service.ListSessionsResponse
+	(*RevokeSessionRequest)(nil),            // 52: gophkeeper.service.RevokeSessionRequest
+	(*RevokeSessionResponse)(nil),           // 53: gophkeeper.service.RevokeSessionResponse
+	(*RevokeAllOtherSessionsRequest)(nil),   // 54: gophkeeper.service.RevokeAllOtherSessionsRequest
+	(*RevokeAllOtherSessionsResponse)(nil),  // 55: gophkeeper.service.RevokeAllOtherSessionsResponse
+	(*LogoutRequest)(nil),                   // 56: gophkeeper.service.LogoutRequest
+	(*LogoutResponse)(nil),                  // 57: gophkeeper.service.LogoutResponse
+	(*Enable2FARequest)(nil),                // 58: gophkeeper.service.Enable2FARequest
+	(*Enable2FAResponse)(nil),               // 59: gophkeeper.service.Enable2FAResponse
+	(*Confirm2FARequest)(nil),               // 60: gophkeeper.service.Confirm2FARequest
+	(*Confirm2FAResponse)(nil),              // 61: gophkeeper.service.Confirm2FAResponse
+	(*Verify2FARequest)(nil),                // 62: gophkeeper.service.Verify2FARequest
+	(*Verify2FAResponse)(nil),               // 63: gophkeeper.service.Verify2FAResponse
+	nil,                                     // 64: gophkeeper.service.ChangePasswordResponse.VersionsEntry
 }
 var file_internal_proto_gopfkeeper_proto_depIdxs = []int32{
-	8,  // 0: gophkeeper.service.SyncRequest.entries:type_name -> gophkeeper.service.Entry
-	9,  // 1: gophkeeper.service.SyncRequest.files:type_name -> gophkeeper.service.File
-	8,  // 2: gophkeeper.service.SyncResponse.processed_entries:type_name -> gophkeeper.service.Entry
-	8,  // 3: gophkeeper.service.SyncResponse.new_entries:type_name -> gophkeeper.service.Entry
-	9,  // 4: gophkeeper.service.SyncResponse.new_files:type_name -> gophkeeper.service.File
-	10, // 5: gophkeeper.service.SyncResponse.upload_tasks:type_name -> gophkeeper.service.UploadTask
-	0,  // 6: gophkeeper.service.GophKeeperService.RegisterUser:input_type -> gophkeeper.service.RegisterUserRequest
-	2,  // 7: gophkeeper.service.GophKeeperService.GetSalt:input_type -> gophkeeper.service.GetSaltRequest
-	4,  // 8: gophkeeper.service.GophKeeperService.Login:input_type -> gophkeeper.service.LoginRequest
-	6,  // 9: gophkeeper.service.GophKeeperService.Ping:input_type -> gophkeeper.service.PingRequest
-	11, // 10: gophkeeper.service.GophKeeperService.Sync:input_type -> gophkeeper.service.SyncRequest
-	13, // 11: gophkeeper.service.GophKeeperService.RefreshToken:input_type -> gophkeeper.service.RefreshTokenRequest
-	15, // 12: gophkeeper.service.GophKeeperService.MarkUploaded:input_type -> gophkeeper.service.MarkUploadedRequest
-	17, // 13: gophkeeper.service.GophKeeperService.GetPresignedGetUrl:input_type -> gophkeeper.service.GetPresignedGetUrlRequest
-	1,  // 14: gophkeeper.service.GophKeeperService.RegisterUser:output_type -> gophkeeper.service.RegisterUserResponse
-	3,  // 15: gophkeeper.service.GophKeeperService.GetSalt:output_type -> gophkeeper.service.GetSaltResponse
-	5,  // 16: gophkeeper.service.GophKeeperService.Login:output_type -> gophkeeper.service.LoginResponse
-	7,  // 17: gophkeeper.service.GophKeeperService.Ping:output_type -> gophkeeper.service.PingResponse
-	12, // 18: gophkeeper.service.GophKeeperService.Sync:output_type -> gophkeeper.service.SyncResponse
-	14, // 19: gophkeeper.service.GophKeeperService.RefreshToken:output_type -> gophkeeper.service.RefreshTokenResponse
-	16, // 20: gophkeeper.service.GophKeeperService.MarkUploaded:output_type -> gophkeeper.service.MarkUploadedResponse
-	18, // 21: gophkeeper.service.GophKeeperService.GetPresignedGetUrl:output_type -> gophkeeper.service.GetPresignedGetUrlResponse
-	14, // [14:22] is the sub-list for method output_type
-	6,  // [6:14] is the sub-list for method input_type
-	6,  // [6:6] is the sub-list for extension type_name
-	6,  // [6:6] is the sub-list for extension extendee
-	0,  // [0:6] is the sub-list for field type_name
+	0,  // 0: gophkeeper.service.RegisterUserRequest.kdf:type_name -> gophkeeper.service.KDFParams
+	0,  // 1: gophkeeper.service.GetSaltResponse.kdf:type_name -> gophkeeper.service.KDFParams
+	0,  // 2: gophkeeper.service.GetSaltResponse.kdf_upgrade:type_name -> gophkeeper.service.KDFParams
+	5,  // 3: gophkeeper.service.LoginRequest.device:type_name -> gophkeeper.service.DeviceInfo
+	5,  // 4: gophkeeper.service.LoginFinishRequest.device:type_name -> gophkeeper.service.DeviceInfo
+	0,  // 5: gophkeeper.service.ChangePasswordRequest.kdf:type_name -> gophkeeper.service.KDFParams
+	5,  // 6: gophkeeper.service.ChangePasswordRequest.device:type_name -> gophkeeper.service.DeviceInfo
+	20, // 7: gophkeeper.service.ChangePasswordRequest.entries:type_name -> gophkeeper.service.Entry
+	21, // 8: gophkeeper.service.ChangePasswordRequest.files:type_name -> gophkeeper.service.File
+	64, // 9: gophkeeper.service.ChangePasswordResponse.versions:type_name -> gophkeeper.service.ChangePasswordResponse.VersionsEntry
+	0,  // 10: gophkeeper.service.UpgradeKDFRequest.kdf:type_name -> gophkeeper.service.KDFParams
+	20, // 11: gophkeeper.service.SyncRequest.entries:type_name -> gophkeeper.service.Entry
+	21, // 12: gophkeeper.service.SyncRequest.files:type_name -> gophkeeper.service.File
+	20, // 13: gophkeeper.service.SyncResponse.processed_entries:type_name -> gophkeeper.service.Entry
+	20, // 14: gophkeeper.service.SyncResponse.new_entries:type_name -> gophkeeper.service.Entry
+	21, // 15: gophkeeper.service.SyncResponse.new_files:type_name -> gophkeeper.service.File
+	22, // 16: gophkeeper.service.SyncResponse.upload_tasks:type_name -> gophkeeper.service.UploadTask
+	20, // 17: gophkeeper.service.SyncResponse.conflicts:type_name -> gophkeeper.service.Entry
+	31, // 18: gophkeeper.service.ListRevisionsResponse.revisions:type_name -> gophkeeper.service.Revision
+	31, // 19: gophkeeper.service.GetRevisionResponse.revision:type_name -> gophkeeper.service.Revision
+	42, // 20: gophkeeper.service.CompleteMultipartUploadRequest.parts:type_name -> gophkeeper.service.UploadedPart
+	5,  // 21: gophkeeper.service.Session.device:type_name -> gophkeeper.service.DeviceInfo
+	49, // 22: gophkeeper.service.ListSessionsResponse.sessions:type_name -> gophkeeper.service.Session
+	5,  // 23: gophkeeper.service.Verify2FARequest.device:type_name -> gophkeeper.service.DeviceInfo
+	1,  // 24: gophkeeper.service.GophKeeperService.RegisterUser:input_type -> gophkeeper.service.RegisterUserRequest
+	3,  // 25: gophkeeper.service.GophKeeperService.GetSalt:input_type -> gophkeeper.service.GetSaltRequest
+	6,  // 26: gophkeeper.service.GophKeeperService.Login:input_type -> gophkeeper.service.LoginRequest
+	8,  // 27: gophkeeper.service.GophKeeperService.LoginStart:input_type -> gophkeeper.service.LoginStartRequest
+	10, // 28: gophkeeper.service.GophKeeperService.LoginFinish:input_type -> gophkeeper.service.LoginFinishRequest
+	12, // 29: gophkeeper.service.GophKeeperService.UpgradeKeys:input_type -> gophkeeper.service.UpgradeKeysRequest
+	14, // 30: gophkeeper.service.GophKeeperService.ChangePassword:input_type -> gophkeeper.service.ChangePasswordRequest
+	16, // 31: gophkeeper.service.GophKeeperService.UpgradeKDF:input_type -> gophkeeper.service.UpgradeKDFRequest
+	18, // 32: gophkeeper.service.GophKeeperService.Ping:input_type -> gophkeeper.service.PingRequest
+	23, // 33: gophkeeper.service.GophKeeperService.Sync:input_type -> gophkeeper.service.SyncRequest
+	25, // 34: gophkeeper.service.GophKeeperService.RefreshToken:input_type -> gophkeeper.service.RefreshTokenRequest
+	27, // 35: gophkeeper.service.GophKeeperService.MarkUploaded:input_type -> gophkeeper.service.MarkUploadedRequest
+	29, // 36: gophkeeper.service.GophKeeperService.GetPresignedGetUrl:input_type -> gophkeeper.service.GetPresignedGetUrlRequest
+	32, // 37: gophkeeper.service.GophKeeperService.ListRevisions:input_type -> gophkeeper.service.ListRevisionsRequest
+	34, // 38: gophkeeper.service.GophKeeperService.GetRevision:input_type -> gophkeeper.service.GetRevisionRequest
+	36, // 39: gophkeeper.service.GophKeeperService.UpdateFileKey:input_type -> gophkeeper.service.UpdateFileKeyRequest
+	38, // 40: gophkeeper.service.GophKeeperService.StartMultipartUpload:input_type -> gophkeeper.service.StartMultipartUploadRequest
+	40, // 41: gophkeeper.service.GophKeeperService.PresignUploadParts:input_type -> gophkeeper.service.PresignUploadPartsRequest
+	43, // 42: gophkeeper.service.GophKeeperService.CompleteMultipartUpload:input_type -> gophkeeper.service.CompleteMultipartUploadRequest
+	45, // 43: gophkeeper.service.GophKeeperService.AbortMultipartUpload:input_type -> gophkeeper.service.AbortMultipartUploadRequest
+	47, // 44: gophkeeper.service.GophKeeperService.RequestUploadURL:input_type -> gophkeeper.service.RequestUploadURLRequest
+	50, // 45: gophkeeper.service.GophKeeperService.ListSessions:input_type -> gophkeeper.service.ListSessionsRequest
+	52, // 46: gophkeeper.service.GophKeeperService.RevokeSession:input_type -> gophkeeper.service.RevokeSessionRequest
+	54, // 47: gophkeeper.service.GophKeeperService.RevokeAllOtherSessions:input_type -> gophkeeper.service.RevokeAllOtherSessionsRequest
+	56, // 48: gophkeeper.service.GophKeeperService.Logout:input_type -> gophkeeper.service.LogoutRequest
+	58, // 49: gophkeeper.service.GophKeeperService.Enable2FA:input_type -> gophkeeper.service.Enable2FARequest
+	60, // 50: gophkeeper.service.GophKeeperService.Confirm2FA:input_type -> gophkeeper.service.Confirm2FARequest
+	62, // 51: gophkeeper.service.GophKeeperService.Verify2FA:input_type -> gophkeeper.service.Verify2FARequest
+	2,  // 52: gophkeeper.service.GophKeeperService.RegisterUser:output_type -> gophkeeper.service.RegisterUserResponse
+	4,  // 53: gophkeeper.service.GophKeeperService.GetSalt:output_type -> gophkeeper.service.GetSaltResponse
+	7,  // 54: gophkeeper.service.GophKeeperService.Login:output_type -> gophkeeper.service.LoginResponse
+	9,  // 55: gophkeeper.service.GophKeeperService.LoginStart:output_type -> gophkeeper.service.LoginStartResponse
+	11, // 56: gophkeeper.service.GophKeeperService.LoginFinish:output_type -> gophkeeper.service.LoginFinishResponse
+	13, // 57: gophkeeper.service.GophKeeperService.UpgradeKeys:output_type -> gophkeeper.service.UpgradeKeysResponse
+	15, // 58: gophkeeper.service.GophKeeperService.ChangePassword:output_type -> gophkeeper.service.ChangePasswordResponse
+	17, // 59: gophkeeper.service.GophKeeperService.UpgradeKDF:output_type -> gophkeeper.service.UpgradeKDFResponse
+	19, // 60: gophkeeper.service.GophKeeperService.Ping:output_type -> gophkeeper.service.PingResponse
+	24, // 61: gophkeeper.service.GophKeeperService.Sync:output_type -> gophkeeper.service.SyncResponse
+	26, // 62: gophkeeper.service.GophKeeperService.RefreshToken:output_type -> gophkeeper.service.RefreshTokenResponse
+	28, // 63: gophkeeper.service.GophKeeperService.MarkUploaded:output_type -> gophkeeper.service.MarkUploadedResponse
+	30, // 64: gophkeeper.service.GophKeeperService.GetPresignedGetUrl:output_type -> gophkeeper.service.GetPresignedGetUrlResponse
+	33, // 65: gophkeeper.service.GophKeeperService.ListRevisions:output_type -> gophkeeper.service.ListRevisionsResponse
+	35, // 66: gophkeeper.service.GophKeeperService.GetRevision:output_type -> gophkeeper.service.GetRevisionResponse
+	37, // 67: gophkeeper.service.GophKeeperService.UpdateFileKey:output_type -> gophkeeper.service.UpdateFileKeyResponse
+	39, // 68: gophkeeper.service.GophKeeperService.StartMultipartUpload:output_type -> gophkeeper.service.StartMultipartUploadResponse
+	41, // 69: gophkeeper.service.GophKeeperService.PresignUploadParts:output_type -> gophkeeper.service.PresignUploadPartsResponse
+	44, // 70: gophkeeper.service.GophKeeperService.CompleteMultipartUpload:output_type -> gophkeeper.service.CompleteMultipartUploadResponse
+	46, // 71: gophkeeper.service.GophKeeperService.AbortMultipartUpload:output_type -> gophkeeper.service.AbortMultipartUploadResponse
+	48, // 72: gophkeeper.service.GophKeeperService.RequestUploadURL:output_type -> gophkeeper.service.RequestUploadURLResponse
+	51, // 73: gophkeeper.service.GophKeeperService.ListSessions:output_type -> gophkeeper.service.ListSessionsResponse
+	53, // 74: gophkeeper.service.GophKeeperService.RevokeSession:output_type -> gophkeeper.service.RevokeSessionResponse
+	55, // 75: gophkeeper.service.GophKeeperService.RevokeAllOtherSessions:output_type -> gophkeeper.service.RevokeAllOtherSessionsResponse
+	57, // 76: gophkeeper.service.GophKeeperService.Logout:output_type -> gophkeeper.service.LogoutResponse
+	59, // 77: gophkeeper.service.GophKeeperService.Enable2FA:output_type -> gophkeeper.service.Enable2FAResponse
+	61, // 78: gophkeeper.service.GophKeeperService.Confirm2FA:output_type -> gophkeeper.service.Confirm2FAResponse
+	63, // 79: gophkeeper.service.GophKeeperService.Verify2FA:output_type -> gophkeeper.service.Verify2FAResponse
+	52, // [52:80] is the sub-list for method output_type
+	24, // [24:52] is the sub-list for method input_type
+	24, // [24:24] is the sub-list for extension type_name
+	24, // [24:24] is the sub-list for extension extendee
+	0,  // [0:24] is the sub-list for field type_name
 }
 
 func init() { file_internal_proto_gopfkeeper_proto_init() }
@@ -1159,7 +3996,7 @@ func file_internal_proto_gopfkeeper_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_proto_gopfkeeper_proto_rawDesc), len(file_internal_proto_gopfkeeper_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   19,
+			NumMessages:   65,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,14 +19,34 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	GophKeeperService_RegisterUser_FullMethodName       = "/gophkeeper.service.GophKeeperService/RegisterUser"
-	GophKeeperService_GetSalt_FullMethodName            = "/gophkeeper.service.GophKeeperService/GetSalt"
-	GophKeeperService_Login_FullMethodName              = "/gophkeeper.service.GophKeeperService/Login"
-	GophKeeperService_Ping_FullMethodName               = "/gophkeeper.service.GophKeeperService/Ping"
-	GophKeeperService_Sync_FullMethodName               = "/gophkeeper.service.GophKeeperService/Sync"
-	GophKeeperService_RefreshToken_FullMethodName       = "/gophkeeper.service.GophKeeperService/RefreshToken"
-	GophKeeperService_MarkUploaded_FullMethodName       = "/gophkeeper.service.GophKeeperService/MarkUploaded"
-	GophKeeperService_GetPresignedGetUrl_FullMethodName = "/gophkeeper.service.GophKeeperService/GetPresignedGetUrl"
+	GophKeeperService_RegisterUser_FullMethodName            = "/gophkeeper.service.GophKeeperService/RegisterUser"
+	GophKeeperService_GetSalt_FullMethodName                 = "/gophkeeper.service.GophKeeperService/GetSalt"
+	GophKeeperService_Login_FullMethodName                   = "/gophkeeper.service.GophKeeperService/Login"
+	GophKeeperService_LoginStart_FullMethodName              = "/gophkeeper.service.GophKeeperService/LoginStart"
+	GophKeeperService_LoginFinish_FullMethodName             = "/gophkeeper.service.GophKeeperService/LoginFinish"
+	GophKeeperService_UpgradeKeys_FullMethodName             = "/gophkeeper.service.GophKeeperService/UpgradeKeys"
+	GophKeeperService_ChangePassword_FullMethodName          = "/gophkeeper.service.GophKeeperService/ChangePassword"
+	GophKeeperService_UpgradeKDF_FullMethodName              = "/gophkeeper.service.GophKeeperService/UpgradeKDF"
+	GophKeeperService_Ping_FullMethodName                    = "/gophkeeper.service.GophKeeperService/Ping"
+	GophKeeperService_Sync_FullMethodName                    = "/gophkeeper.service.GophKeeperService/Sync"
+	GophKeeperService_RefreshToken_FullMethodName            = "/gophkeeper.service.GophKeeperService/RefreshToken"
+	GophKeeperService_MarkUploaded_FullMethodName            = "/gophkeeper.service.GophKeeperService/MarkUploaded"
+	GophKeeperService_GetPresignedGetUrl_FullMethodName      = "/gophkeeper.service.GophKeeperService/GetPresignedGetUrl"
+	GophKeeperService_ListRevisions_FullMethodName           = "/gophkeeper.service.GophKeeperService/ListRevisions"
+	GophKeeperService_GetRevision_FullMethodName             = "/gophkeeper.service.GophKeeperService/GetRevision"
+	GophKeeperService_UpdateFileKey_FullMethodName           = "/gophkeeper.service.GophKeeperService/UpdateFileKey"
+	GophKeeperService_StartMultipartUpload_FullMethodName    = "/gophkeeper.service.GophKeeperService/StartMultipartUpload"
+	GophKeeperService_PresignUploadParts_FullMethodName      = "/gophkeeper.service.GophKeeperService/PresignUploadParts"
+	GophKeeperService_CompleteMultipartUpload_FullMethodName = "/gophkeeper.service.GophKeeperService/CompleteMultipartUpload"
+	GophKeeperService_AbortMultipartUpload_FullMethodName    = "/gophkeeper.service.GophKeeperService/AbortMultipartUpload"
+	GophKeeperService_RequestUploadURL_FullMethodName        = "/gophkeeper.service.GophKeeperService/RequestUploadURL"
+	GophKeeperService_ListSessions_FullMethodName            = "/gophkeeper.service.GophKeeperService/ListSessions"
+	GophKeeperService_RevokeSession_FullMethodName           = "/gophkeeper.service.GophKeeperService/RevokeSession"
+	GophKeeperService_RevokeAllOtherSessions_FullMethodName  = "/gophkeeper.service.GophKeeperService/RevokeAllOtherSessions"
+	GophKeeperService_Logout_FullMethodName                  = "/gophkeeper.service.GophKeeperService/Logout"
+	GophKeeperService_Enable2FA_FullMethodName               = "/gophkeeper.service.GophKeeperService/Enable2FA"
+	GophKeeperService_Confirm2FA_FullMethodName              = "/gophkeeper.service.GophKeeperService/Confirm2FA"
+	GophKeeperService_Verify2FA_FullMethodName               = "/gophkeeper.service.GophKeeperService/Verify2FA"
 )
 
 // GophKeeperServiceClient is the client API for GophKeeperService service.
@@ -36,11 +56,31 @@ type GophKeeperServiceClient interface {
 	RegisterUser(ctx context.Context, in *RegisterUserRequest, opts ...grpc.CallOption) (*RegisterUserResponse, error)
 	GetSalt(ctx context.Context, in *GetSaltRequest, opts ...grpc.CallOption) (*GetSaltResponse, error)
 	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error)
+	LoginStart(ctx context.Context, in *LoginStartRequest, opts ...grpc.CallOption) (*LoginStartResponse, error)
+	LoginFinish(ctx context.Context, in *LoginFinishRequest, opts ...grpc.CallOption) (*LoginFinishResponse, error)
+	UpgradeKeys(ctx context.Context, in *UpgradeKeysRequest, opts ...grpc.CallOption) (*UpgradeKeysResponse, error)
+	ChangePassword(ctx context.Context, in *ChangePasswordRequest, opts ...grpc.CallOption) (*ChangePasswordResponse, error)
+	UpgradeKDF(ctx context.Context, in *UpgradeKDFRequest, opts ...grpc.CallOption) (*UpgradeKDFResponse, error)
 	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
 	Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (*SyncResponse, error)
 	RefreshToken(ctx context.Context, in *RefreshTokenRequest, opts ...grpc.CallOption) (*RefreshTokenResponse, error)
 	MarkUploaded(ctx context.Context, in *MarkUploadedRequest, opts ...grpc.CallOption) (*MarkUploadedResponse, error)
 	GetPresignedGetUrl(ctx context.Context, in *GetPresignedGetUrlRequest, opts ...grpc.CallOption) (*GetPresignedGetUrlResponse, error)
+	ListRevisions(ctx context.Context, in *ListRevisionsRequest, opts ...grpc.CallOption) (*ListRevisionsResponse, error)
+	GetRevision(ctx context.Context, in *GetRevisionRequest, opts ...grpc.CallOption) (*GetRevisionResponse, error)
+	UpdateFileKey(ctx context.Context, in *UpdateFileKeyRequest, opts ...grpc.CallOption) (*UpdateFileKeyResponse, error)
+	StartMultipartUpload(ctx context.Context, in *StartMultipartUploadRequest, opts ...grpc.CallOption) (*StartMultipartUploadResponse, error)
+	PresignUploadParts(ctx context.Context, in *PresignUploadPartsRequest, opts ...grpc.CallOption) (*PresignUploadPartsResponse, error)
+	CompleteMultipartUpload(ctx context.Context, in *CompleteMultipartUploadRequest, opts ...grpc.CallOption) (*CompleteMultipartUploadResponse, error)
+	AbortMultipartUpload(ctx context.Context, in *AbortMultipartUploadRequest, opts ...grpc.CallOption) (*AbortMultipartUploadResponse, error)
+	RequestUploadURL(ctx context.Context, in *RequestUploadURLRequest, opts ...grpc.CallOption) (*RequestUploadURLResponse, error)
+	ListSessions(ctx context.Context, in *ListSessionsRequest, opts ...grpc.CallOption) (*ListSessionsResponse, error)
+	RevokeSession(ctx context.Context, in *RevokeSessionRequest, opts ...grpc.CallOption) (*RevokeSessionResponse, error)
+	RevokeAllOtherSessions(ctx context.Context, in *RevokeAllOtherSessionsRequest, opts ...grpc.CallOption) (*RevokeAllOtherSessionsResponse, error)
+	Logout(ctx context.Context, in *LogoutRequest, opts ...grpc.CallOption) (*LogoutResponse, error)
+	Enable2FA(ctx context.Context, in *Enable2FARequest, opts ...grpc.CallOption) (*Enable2FAResponse, error)
+	Confirm2FA(ctx context.Context, in *Confirm2FARequest, opts ...grpc.CallOption) (*Confirm2FAResponse, error)
+	Verify2FA(ctx context.Context, in *Verify2FARequest, opts ...grpc.CallOption) (*Verify2FAResponse, error)
 }
 
 type gophKeeperServiceClient struct {
@@ -81,6 +121,56 @@ func (c *gophKeeperServiceClient) Login(ctx context.Context, in *LoginRequest, o
 	return out, nil
 }
 
+func (c *gophKeeperServiceClient) LoginStart(ctx context.Context, in *LoginStartRequest, opts ...grpc.CallOption) (*LoginStartResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LoginStartResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_LoginStart_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophKeeperServiceClient) LoginFinish(ctx context.Context, in *LoginFinishRequest, opts ...grpc.CallOption) (*LoginFinishResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LoginFinishResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_LoginFinish_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophKeeperServiceClient) UpgradeKeys(ctx context.Context, in *UpgradeKeysRequest, opts ...grpc.CallOption) (*UpgradeKeysResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpgradeKeysResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_UpgradeKeys_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophKeeperServiceClient) ChangePassword(ctx context.Context, in *ChangePasswordRequest, opts ...grpc.CallOption) (*ChangePasswordResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChangePasswordResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_ChangePassword_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophKeeperServiceClient) UpgradeKDF(ctx context.Context, in *UpgradeKDFRequest, opts ...grpc.CallOption) (*UpgradeKDFResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpgradeKDFResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_UpgradeKDF_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *gophKeeperServiceClient) Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(PingResponse)
@@ -131,6 +221,156 @@ func (c *gophKeeperServiceClient) GetPresignedGetUrl(ctx context.Context, in *Ge
 	return out, nil
 }
 
+func (c *gophKeeperServiceClient) ListRevisions(ctx context.Context, in *ListRevisionsRequest, opts ...grpc.CallOption) (*ListRevisionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRevisionsResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_ListRevisions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophKeeperServiceClient) GetRevision(ctx context.Context, in *GetRevisionRequest, opts ...grpc.CallOption) (*GetRevisionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetRevisionResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_GetRevision_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophKeeperServiceClient) UpdateFileKey(ctx context.Context, in *UpdateFileKeyRequest, opts ...grpc.CallOption) (*UpdateFileKeyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateFileKeyResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_UpdateFileKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophKeeperServiceClient) StartMultipartUpload(ctx context.Context, in *StartMultipartUploadRequest, opts ...grpc.CallOption) (*StartMultipartUploadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StartMultipartUploadResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_StartMultipartUpload_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophKeeperServiceClient) PresignUploadParts(ctx context.Context, in *PresignUploadPartsRequest, opts ...grpc.CallOption) (*PresignUploadPartsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PresignUploadPartsResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_PresignUploadParts_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophKeeperServiceClient) CompleteMultipartUpload(ctx context.Context, in *CompleteMultipartUploadRequest, opts ...grpc.CallOption) (*CompleteMultipartUploadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CompleteMultipartUploadResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_CompleteMultipartUpload_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophKeeperServiceClient) AbortMultipartUpload(ctx context.Context, in *AbortMultipartUploadRequest, opts ...grpc.CallOption) (*AbortMultipartUploadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AbortMultipartUploadResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_AbortMultipartUpload_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophKeeperServiceClient) RequestUploadURL(ctx context.Context, in *RequestUploadURLRequest, opts ...grpc.CallOption) (*RequestUploadURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RequestUploadURLResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_RequestUploadURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophKeeperServiceClient) ListSessions(ctx context.Context, in *ListSessionsRequest, opts ...grpc.CallOption) (*ListSessionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSessionsResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_ListSessions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophKeeperServiceClient) RevokeSession(ctx context.Context, in *RevokeSessionRequest, opts ...grpc.CallOption) (*RevokeSessionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RevokeSessionResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_RevokeSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophKeeperServiceClient) RevokeAllOtherSessions(ctx context.Context, in *RevokeAllOtherSessionsRequest, opts ...grpc.CallOption) (*RevokeAllOtherSessionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RevokeAllOtherSessionsResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_RevokeAllOtherSessions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophKeeperServiceClient) Logout(ctx context.Context, in *LogoutRequest, opts ...grpc.CallOption) (*LogoutResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LogoutResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_Logout_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophKeeperServiceClient) Enable2FA(ctx context.Context, in *Enable2FARequest, opts ...grpc.CallOption) (*Enable2FAResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Enable2FAResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_Enable2FA_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophKeeperServiceClient) Confirm2FA(ctx context.Context, in *Confirm2FARequest, opts ...grpc.CallOption) (*Confirm2FAResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Confirm2FAResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_Confirm2FA_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophKeeperServiceClient) Verify2FA(ctx context.Context, in *Verify2FARequest, opts ...grpc.CallOption) (*Verify2FAResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Verify2FAResponse)
+	err := c.cc.Invoke(ctx, GophKeeperService_Verify2FA_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // GophKeeperServiceServer is the server API for GophKeeperService service.
 // All implementations must embed UnimplementedGophKeeperServiceServer
 // for forward compatibility.
@@ -138,11 +378,31 @@ type GophKeeperServiceServer interface {
 	RegisterUser(context.Context, *RegisterUserRequest) (*RegisterUserResponse, error)
 	GetSalt(context.Context, *GetSaltRequest) (*GetSaltResponse, error)
 	Login(context.Context, *LoginRequest) (*LoginResponse, error)
+	LoginStart(context.Context, *LoginStartRequest) (*LoginStartResponse, error)
+	LoginFinish(context.Context, *LoginFinishRequest) (*LoginFinishResponse, error)
+	UpgradeKeys(context.Context, *UpgradeKeysRequest) (*UpgradeKeysResponse, error)
+	ChangePassword(context.Context, *ChangePasswordRequest) (*ChangePasswordResponse, error)
+	UpgradeKDF(context.Context, *UpgradeKDFRequest) (*UpgradeKDFResponse, error)
 	Ping(context.Context, *PingRequest) (*PingResponse, error)
 	Sync(context.Context, *SyncRequest) (*SyncResponse, error)
 	RefreshToken(context.Context, *RefreshTokenRequest) (*RefreshTokenResponse, error)
 	MarkUploaded(context.Context, *MarkUploadedRequest) (*MarkUploadedResponse, error)
 	GetPresignedGetUrl(context.Context, *GetPresignedGetUrlRequest) (*GetPresignedGetUrlResponse, error)
+	ListRevisions(context.Context, *ListRevisionsRequest) (*ListRevisionsResponse, error)
+	GetRevision(context.Context, *GetRevisionRequest) (*GetRevisionResponse, error)
+	UpdateFileKey(context.Context, *UpdateFileKeyRequest) (*UpdateFileKeyResponse, error)
+	StartMultipartUpload(context.Context, *StartMultipartUploadRequest) (*StartMultipartUploadResponse, error)
+	PresignUploadParts(context.Context, *PresignUploadPartsRequest) (*PresignUploadPartsResponse, error)
+	CompleteMultipartUpload(context.Context, *CompleteMultipartUploadRequest) (*CompleteMultipartUploadResponse, error)
+	AbortMultipartUpload(context.Context, *AbortMultipartUploadRequest) (*AbortMultipartUploadResponse, error)
+	RequestUploadURL(context.Context, *RequestUploadURLRequest) (*RequestUploadURLResponse, error)
+	ListSessions(context.Context, *ListSessionsRequest) (*ListSessionsResponse, error)
+	RevokeSession(context.Context, *RevokeSessionRequest) (*RevokeSessionResponse, error)
+	RevokeAllOtherSessions(context.Context, *RevokeAllOtherSessionsRequest) (*RevokeAllOtherSessionsResponse, error)
+	Logout(context.Context, *LogoutRequest) (*LogoutResponse, error)
+	Enable2FA(context.Context, *Enable2FARequest) (*Enable2FAResponse, error)
+	Confirm2FA(context.Context, *Confirm2FARequest) (*Confirm2FAResponse, error)
+	Verify2FA(context.Context, *Verify2FARequest) (*Verify2FAResponse, error)
 	mustEmbedUnimplementedGophKeeperServiceServer()
 }
 
@@ -162,6 +422,21 @@ func (UnimplementedGophKeeperServiceServer) GetSalt(context.Context, *GetSaltReq
 func (UnimplementedGophKeeperServiceServer) Login(context.Context, *LoginRequest) (*LoginResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
 }
+func (UnimplementedGophKeeperServiceServer) LoginStart(context.Context, *LoginStartRequest) (*LoginStartResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LoginStart not implemented")
+}
+func (UnimplementedGophKeeperServiceServer) LoginFinish(context.Context, *LoginFinishRequest) (*LoginFinishResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LoginFinish not implemented")
+}
+func (UnimplementedGophKeeperServiceServer) UpgradeKeys(context.Context, *UpgradeKeysRequest) (*UpgradeKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpgradeKeys not implemented")
+}
+func (UnimplementedGophKeeperServiceServer) ChangePassword(context.Context, *ChangePasswordRequest) (*ChangePasswordResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChangePassword not implemented")
+}
+func (UnimplementedGophKeeperServiceServer) UpgradeKDF(context.Context, *UpgradeKDFRequest) (*UpgradeKDFResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpgradeKDF not implemented")
+}
 func (UnimplementedGophKeeperServiceServer) Ping(context.Context, *PingRequest) (*PingResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Ping not implemented")
 }
@@ -177,6 +452,51 @@ func (UnimplementedGophKeeperServiceServer) MarkUploaded(context.Context, *MarkU
 func (UnimplementedGophKeeperServiceServer) GetPresignedGetUrl(context.Context, *GetPresignedGetUrlRequest) (*GetPresignedGetUrlResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetPresignedGetUrl not implemented")
 }
+func (UnimplementedGophKeeperServiceServer) ListRevisions(context.Context, *ListRevisionsRequest) (*ListRevisionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRevisions not implemented")
+}
+func (UnimplementedGophKeeperServiceServer) GetRevision(context.Context, *GetRevisionRequest) (*GetRevisionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRevision not implemented")
+}
+func (UnimplementedGophKeeperServiceServer) UpdateFileKey(context.Context, *UpdateFileKeyRequest) (*UpdateFileKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateFileKey not implemented")
+}
+func (UnimplementedGophKeeperServiceServer) StartMultipartUpload(context.Context, *StartMultipartUploadRequest) (*StartMultipartUploadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartMultipartUpload not implemented")
+}
+func (UnimplementedGophKeeperServiceServer) PresignUploadParts(context.Context, *PresignUploadPartsRequest) (*PresignUploadPartsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PresignUploadParts not implemented")
+}
+func (UnimplementedGophKeeperServiceServer) CompleteMultipartUpload(context.Context, *CompleteMultipartUploadRequest) (*CompleteMultipartUploadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompleteMultipartUpload not implemented")
+}
+func (UnimplementedGophKeeperServiceServer) AbortMultipartUpload(context.Context, *AbortMultipartUploadRequest) (*AbortMultipartUploadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AbortMultipartUpload not implemented")
+}
+func (UnimplementedGophKeeperServiceServer) RequestUploadURL(context.Context, *RequestUploadURLRequest) (*RequestUploadURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RequestUploadURL not implemented")
+}
+func (UnimplementedGophKeeperServiceServer) ListSessions(context.Context, *ListSessionsRequest) (*ListSessionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSessions not implemented")
+}
+func (UnimplementedGophKeeperServiceServer) RevokeSession(context.Context, *RevokeSessionRequest) (*RevokeSessionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeSession not implemented")
+}
+func (UnimplementedGophKeeperServiceServer) RevokeAllOtherSessions(context.Context, *RevokeAllOtherSessionsRequest) (*RevokeAllOtherSessionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeAllOtherSessions not implemented")
+}
+func (UnimplementedGophKeeperServiceServer) Logout(context.Context, *LogoutRequest) (*LogoutResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Logout not implemented")
+}
+func (UnimplementedGophKeeperServiceServer) Enable2FA(context.Context, *Enable2FARequest) (*Enable2FAResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Enable2FA not implemented")
+}
+func (UnimplementedGophKeeperServiceServer) Confirm2FA(context.Context, *Confirm2FARequest) (*Confirm2FAResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Confirm2FA not implemented")
+}
+func (UnimplementedGophKeeperServiceServer) Verify2FA(context.Context, *Verify2FARequest) (*Verify2FAResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Verify2FA not implemented")
+}
 func (UnimplementedGophKeeperServiceServer) mustEmbedUnimplementedGophKeeperServiceServer() {}
 func (UnimplementedGophKeeperServiceServer) testEmbeddedByValue()                           {}
 
@@ -252,6 +572,96 @@ func _GophKeeperService_Login_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _GophKeeperService_LoginStart_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginStartRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).LoginStart(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_LoginStart_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).LoginStart(ctx, req.(*LoginStartRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GophKeeperService_LoginFinish_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginFinishRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).LoginFinish(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_LoginFinish_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).LoginFinish(ctx, req.(*LoginFinishRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GophKeeperService_UpgradeKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpgradeKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).UpgradeKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_UpgradeKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).UpgradeKeys(ctx, req.(*UpgradeKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GophKeeperService_ChangePassword_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChangePasswordRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).ChangePassword(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_ChangePassword_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).ChangePassword(ctx, req.(*ChangePasswordRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GophKeeperService_UpgradeKDF_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpgradeKDFRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).UpgradeKDF(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_UpgradeKDF_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).UpgradeKDF(ctx, req.(*UpgradeKDFRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _GophKeeperService_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(PingRequest)
 	if err := dec(in); err != nil {
@@ -342,6 +752,276 @@ func _GophKeeperService_GetPresignedGetUrl_Handler(srv interface{}, ctx context.
 	return interceptor(ctx, in, info, handler)
 }
 
+func _GophKeeperService_ListRevisions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRevisionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).ListRevisions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_ListRevisions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).ListRevisions(ctx, req.(*ListRevisionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GophKeeperService_GetRevision_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRevisionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).GetRevision(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_GetRevision_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).GetRevision(ctx, req.(*GetRevisionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GophKeeperService_UpdateFileKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateFileKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).UpdateFileKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_UpdateFileKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).UpdateFileKey(ctx, req.(*UpdateFileKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GophKeeperService_StartMultipartUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartMultipartUploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).StartMultipartUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_StartMultipartUpload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).StartMultipartUpload(ctx, req.(*StartMultipartUploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GophKeeperService_PresignUploadParts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PresignUploadPartsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).PresignUploadParts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_PresignUploadParts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).PresignUploadParts(ctx, req.(*PresignUploadPartsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GophKeeperService_CompleteMultipartUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompleteMultipartUploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).CompleteMultipartUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_CompleteMultipartUpload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).CompleteMultipartUpload(ctx, req.(*CompleteMultipartUploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GophKeeperService_AbortMultipartUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AbortMultipartUploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).AbortMultipartUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_AbortMultipartUpload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).AbortMultipartUpload(ctx, req.(*AbortMultipartUploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GophKeeperService_RequestUploadURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestUploadURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).RequestUploadURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_RequestUploadURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).RequestUploadURL(ctx, req.(*RequestUploadURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GophKeeperService_ListSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSessionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).ListSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_ListSessions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).ListSessions(ctx, req.(*ListSessionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GophKeeperService_RevokeSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).RevokeSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_RevokeSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).RevokeSession(ctx, req.(*RevokeSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GophKeeperService_RevokeAllOtherSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeAllOtherSessionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).RevokeAllOtherSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_RevokeAllOtherSessions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).RevokeAllOtherSessions(ctx, req.(*RevokeAllOtherSessionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GophKeeperService_Logout_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LogoutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).Logout(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_Logout_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).Logout(ctx, req.(*LogoutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GophKeeperService_Enable2FA_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Enable2FARequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).Enable2FA(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_Enable2FA_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).Enable2FA(ctx, req.(*Enable2FARequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GophKeeperService_Confirm2FA_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Confirm2FARequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).Confirm2FA(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_Confirm2FA_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).Confirm2FA(ctx, req.(*Confirm2FARequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GophKeeperService_Verify2FA_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Verify2FARequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophKeeperServiceServer).Verify2FA(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GophKeeperService_Verify2FA_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophKeeperServiceServer).Verify2FA(ctx, req.(*Verify2FARequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // GophKeeperService_ServiceDesc is the grpc.ServiceDesc for GophKeeperService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -361,6 +1041,26 @@ var GophKeeperService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Login",
 			Handler:    _GophKeeperService_Login_Handler,
 		},
+		{
+			MethodName: "LoginStart",
+			Handler:    _GophKeeperService_LoginStart_Handler,
+		},
+		{
+			MethodName: "LoginFinish",
+			Handler:    _GophKeeperService_LoginFinish_Handler,
+		},
+		{
+			MethodName: "UpgradeKeys",
+			Handler:    _GophKeeperService_UpgradeKeys_Handler,
+		},
+		{
+			MethodName: "ChangePassword",
+			Handler:    _GophKeeperService_ChangePassword_Handler,
+		},
+		{
+			MethodName: "UpgradeKDF",
+			Handler:    _GophKeeperService_UpgradeKDF_Handler,
+		},
 		{
 			MethodName: "Ping",
 			Handler:    _GophKeeperService_Ping_Handler,
@@ -381,6 +1081,66 @@ var GophKeeperService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetPresignedGetUrl",
 			Handler:    _GophKeeperService_GetPresignedGetUrl_Handler,
 		},
+		{
+			MethodName: "ListRevisions",
+			Handler:    _GophKeeperService_ListRevisions_Handler,
+		},
+		{
+			MethodName: "GetRevision",
+			Handler:    _GophKeeperService_GetRevision_Handler,
+		},
+		{
+			MethodName: "UpdateFileKey",
+			Handler:    _GophKeeperService_UpdateFileKey_Handler,
+		},
+		{
+			MethodName: "StartMultipartUpload",
+			Handler:    _GophKeeperService_StartMultipartUpload_Handler,
+		},
+		{
+			MethodName: "PresignUploadParts",
+			Handler:    _GophKeeperService_PresignUploadParts_Handler,
+		},
+		{
+			MethodName: "CompleteMultipartUpload",
+			Handler:    _GophKeeperService_CompleteMultipartUpload_Handler,
+		},
+		{
+			MethodName: "AbortMultipartUpload",
+			Handler:    _GophKeeperService_AbortMultipartUpload_Handler,
+		},
+		{
+			MethodName: "RequestUploadURL",
+			Handler:    _GophKeeperService_RequestUploadURL_Handler,
+		},
+		{
+			MethodName: "ListSessions",
+			Handler:    _GophKeeperService_ListSessions_Handler,
+		},
+		{
+			MethodName: "RevokeSession",
+			Handler:    _GophKeeperService_RevokeSession_Handler,
+		},
+		{
+			MethodName: "RevokeAllOtherSessions",
+			Handler:    _GophKeeperService_RevokeAllOtherSessions_Handler,
+		},
+		{
+			MethodName: "Logout",
+			Handler:    _GophKeeperService_Logout_Handler,
+		},
+		{
+			MethodName: "Enable2FA",
+			Handler:    _GophKeeperService_Enable2FA_Handler,
+		},
+		{
+			MethodName: "Confirm2FA",
+			Handler:    _GophKeeperService_Confirm2FA_Handler,
+		},
+		{
+			MethodName: "Verify2FA",
+			Handler:    _GophKeeperService_Verify2FA_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "internal/proto/gopfkeeper.proto",
@@ -4,33 +4,52 @@
 //   - Open and ping the database (via DSN) and run schema migrations.
 //   - Construct repository manager and domain services.
 //   - Start the public gRPC server and handle graceful shutdown on OS signals.
+//   - Serve gRPC over TLS, optionally mutual, and reload the certificates on
+//     SIGHUP.
+//   - With the filesystem blob backend, serve the presigned blob URLs over
+//     HTTPS with the same certificates.
+//   - Periodically purge tombstones older than the configured retention.
+//   - Periodically report uploads that have been pending for too long.
+//   - Periodically delete blobs that no file refers to any more.
+//   - Throttle the authentication RPCs and periodically forget idle clients.
 package server
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/logging"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/blobstore"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/config"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/repomanager"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/services"
+	"github.com/dmitrijs2005/gophkeeper/internal/tlsx"
 
 	gs "github.com/dmitrijs2005/gophkeeper/internal/server/grpc"
 )
 
 // App is the composition root of the server process.
-// It owns configuration, logging, and the domain services.
+// It owns configuration, logging, the TLS certificates, the blob store and
+// the domain services.
 type App struct {
 	config       *config.Config
 	logger       logging.Logger
+	tls          *tlsx.Reloader
+	blobStore    blobstore.BlobStore
 	userService  *services.UserService
 	entryService *services.EntryService
+	// rateLimiter is nil when throttling is disabled.
+	rateLimiter *services.RateLimiter
 }
 
 // NewAppFromDSN opens a DB connection using cfg.DatabaseDSN, verifies it with a
@@ -48,8 +67,29 @@ func NewAppFromDSN(cfg *config.Config, logger logging.Logger) (*App, error) {
 	return NewApp(db, cfg, logger)
 }
 
-// NewApp constructs repositories, runs migrations, and builds domain services.
+// NewApp loads the TLS certificates, constructs repositories, runs
+// migrations, and builds the blob store and domain services. Without
+// c.Insecure a certificate is required, and the filesystem blob backend must
+// be reached over https.
 func NewApp(db *sql.DB, c *config.Config, l logging.Logger) (*App, error) {
+	if err := c.KDFParams().Validate(); err != nil {
+		return nil, fmt.Errorf("kdf config: %w", err)
+	}
+	var reloader *tlsx.Reloader
+	if !c.Insecure {
+		r, err := tlsx.NewReloader(c.TLSCertFile, c.TLSKeyFile, c.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls config: %w (use -insecure to serve without TLS)", err)
+		}
+		reloader = r
+		if c.BlobBackend == "fs" && !strings.HasPrefix(c.BlobBaseURL, "https://") {
+			return nil, fmt.Errorf("blob url %q: the fs blob endpoint is served over TLS, use an https URL (or -insecure)", c.BlobBaseURL)
+		}
+	}
+	bs, err := blobstore.New(c)
+	if err != nil {
+		return nil, fmt.Errorf("blob store init error: %w", err)
+	}
 	m, err := repomanager.NewPostgresRepositoryManager(db)
 	if err != nil {
 		return nil, fmt.Errorf("db init error: %w", err)
@@ -58,8 +98,9 @@ func NewApp(db *sql.DB, c *config.Config, l logging.Logger) (*App, error) {
 		return nil, fmt.Errorf("migration error: %w", err)
 	}
 	us := services.NewUserService(db, m, c)
-	es := services.NewEntryService(db, m, c)
-	return &App{config: c, logger: l, userService: us, entryService: es}, nil
+	es := services.NewEntryService(db, m, bs)
+	rl := services.NewRateLimiter(db, m, c)
+	return &App{config: c, logger: l, tls: reloader, blobStore: bs, userService: us, entryService: es, rateLimiter: rl}, nil
 }
 
 // initSignalHandler installs SIGINT/SIGTERM/SIGQUIT handlers that cancel ctx.
@@ -72,9 +113,40 @@ func (app *App) initSignalHandler(cancelFunc context.CancelFunc) {
 	}()
 }
 
+// startTLSReloader reloads the TLS certificates on every SIGHUP until ctx is
+// done. A failed reload is logged and leaves the loaded certificates in use.
+// It does nothing when serving without TLS.
+func (app *App) startTLSReloader(ctx context.Context) {
+	if app.tls == nil {
+		return
+	}
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sighup:
+			if err := app.tls.Reload(); err != nil {
+				app.logger.Error(ctx, "TLS certificate reload failed", "error", err)
+				continue
+			}
+			app.logger.Info(ctx, "TLS certificates reloaded")
+		}
+	}
+}
+
 // startGRPCServer builds and runs the gRPC server; on error it logs and cancels ctx.
 func (app *App) startGRPCServer(ctx context.Context, cancelFunc context.CancelFunc) {
-	s, err := gs.NewgGRPCServer(app.config.EndpointAddrGRPC, app.logger, app.userService, app.entryService, app.config.SecretKey)
+	var tlsConfig *tls.Config
+	if app.tls != nil {
+		tlsConfig = app.tls.TLSConfig()
+	} else {
+		app.logger.Warn(ctx, "serving gRPC without TLS")
+	}
+	s, err := gs.NewgGRPCServer(app.config.EndpointAddrGRPC, app.logger, app.userService, app.entryService, app.config.SecretKey, tlsConfig, app.rateLimiter)
 	if err != nil {
 		app.logger.Error(ctx, err.Error())
 		cancelFunc()
@@ -86,18 +158,251 @@ func (app *App) startGRPCServer(ctx context.Context, cancelFunc context.CancelFu
 	}
 }
 
+// blobShutdownTimeout bounds how long the blob endpoint waits for transfers
+// in progress on shutdown.
+const blobShutdownTimeout = 5 * time.Second
+
+// Timeouts of the blob endpoint. Reading a request, body included, and
+// writing a response are bounded generously, as they move whole parts and
+// objects; a download cut off by blobWriteTimeout is resumed by the client
+// with a Range request.
+const (
+	blobReadHeaderTimeout = 10 * time.Second
+	blobReadTimeout       = 15 * time.Minute
+	blobWriteTimeout      = 15 * time.Minute
+	blobIdleTimeout       = 2 * time.Minute
+)
+
+// startBlobServer serves the presigned URLs of a blob store that handles them
+// itself (the filesystem backend) until ctx is done; on error it logs and
+// cancels ctx. It does nothing for the S3 backend. The endpoint uses the
+// gRPC certificates but asks for no client certificate, as the presigned
+// URLs authorize the requests.
+func (app *App) startBlobServer(ctx context.Context, cancelFunc context.CancelFunc) {
+	handler, ok := app.blobStore.(http.Handler)
+	if !ok {
+		return
+	}
+	srv := &http.Server{
+		Addr:              app.config.EndpointAddrBlob,
+		Handler:           handler,
+		ReadHeaderTimeout: blobReadHeaderTimeout,
+		ReadTimeout:       blobReadTimeout,
+		WriteTimeout:      blobWriteTimeout,
+		IdleTimeout:       blobIdleTimeout,
+	}
+	if app.tls != nil {
+		srv.TLSConfig = app.tls.TLSConfigNoClientAuth()
+	} else {
+		app.logger.Warn(ctx, "serving blobs without TLS")
+	}
+
+	go func() {
+		<-ctx.Done()
+		app.logger.Info(ctx, "Stopping blob server...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), blobShutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	app.logger.Info(ctx, "Starting blob server", "address", app.config.EndpointAddrBlob, "url", app.config.BlobBaseURL)
+	serve := srv.ListenAndServe
+	if srv.TLSConfig != nil {
+		// the certificates come from TLSConfig
+		serve = func() error { return srv.ListenAndServeTLS("", "") }
+	}
+	if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		app.logger.Error(ctx, err.Error())
+		cancelFunc()
+	}
+}
+
+// purgeInterval is how often the tombstone retention job runs.
+const purgeInterval = time.Hour
+
+// startPurgeJob purges tombstones older than the configured retention right
+// away and then every purgeInterval until ctx is done. It does nothing when
+// retention is disabled.
+func (app *App) startPurgeJob(ctx context.Context) {
+	retention := app.config.TombstoneRetention
+	if retention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for {
+		n, err := app.entryService.PurgeTombstones(ctx, time.Now().Add(-retention))
+		if err != nil {
+			app.logger.Error(ctx, "tombstone purge failed", "error", err, "purged", n)
+		} else if n > 0 {
+			app.logger.Info(ctx, "tombstones purged", "purged", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// staleUploadInterval is how often pending uploads are checked.
+const staleUploadInterval = time.Hour
+
+// startStaleUploadJob reports files that have been waiting for their upload
+// for longer than the configured age right away and then every
+// staleUploadInterval until ctx is done, so that they can be cleaned up. It
+// does nothing when the report is disabled.
+func (app *App) startStaleUploadJob(ctx context.Context) {
+	age := app.config.StaleUploadAge
+	if age <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(staleUploadInterval)
+	defer ticker.Stop()
+	for {
+		app.reportStaleUploads(ctx, time.Now().Add(-age))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reportStaleUploads logs every file still waiting for its upload that was
+// registered before the given time.
+func (app *App) reportStaleUploads(ctx context.Context, before time.Time) {
+	files, err := app.entryService.StaleUploads(ctx, before)
+	if err != nil {
+		app.logger.Error(ctx, "stale upload check failed", "error", err)
+		return
+	}
+	if len(files) == 0 {
+		return
+	}
+	app.logger.Warn(ctx, "stale pending uploads", "count", len(files))
+	for _, f := range files {
+		app.logger.Warn(ctx, "stale pending upload", "entry_id", f.EntryID, "user_id", f.UserID,
+			"storage_key", f.StorageKey, "upload_id", f.UploadID, "pending_since", f.UpdatedAt)
+	}
+}
+
+// blobGCInterval is how often blob garbage collection runs.
+const blobGCInterval = 24 * time.Hour
+
+// startBlobGCJob collects blob garbage right away and then every
+// blobGCInterval until ctx is done. It does nothing when garbage collection
+// is disabled.
+func (app *App) startBlobGCJob(ctx context.Context) {
+	if app.config.BlobGCGrace <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(blobGCInterval)
+	defer ticker.Stop()
+	for {
+		// errors are logged by CollectGarbage
+		_, _ = app.CollectGarbage(ctx, false)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CollectGarbage deletes the blobs that no file refers to and those of files
+// deleted before the configured grace period, and logs a summary. On a dry
+// run nothing is deleted and the report lists what would be.
+func (app *App) CollectGarbage(ctx context.Context, dryRun bool) (*services.GarbageReport, error) {
+	grace := app.config.BlobGCGrace
+	if grace <= 0 {
+		return nil, errors.New("blob garbage collection is disabled")
+	}
+
+	r, err := app.entryService.CollectGarbage(ctx, time.Now().Add(-grace), dryRun)
+	if err != nil {
+		app.logger.Error(ctx, "blob garbage collection failed", "error", err)
+	}
+	if r != nil && (r.Deleted > 0 || dryRun) {
+		app.logger.Info(ctx, "blob garbage collected", "dry_run", dryRun, "scanned", r.Scanned,
+			"unreferenced", len(r.Unreferenced), "tombstoned", len(r.Tombstoned), "deleted", r.Deleted, "bytes", r.Size())
+	}
+	return r, err
+}
+
+// rateLimitPurgeInterval is how often the state of idle clients is purged.
+const rateLimitPurgeInterval = time.Hour
+
+// startRateLimitPurgeJob forgets the rate limit state of idle clients right
+// away and then every rateLimitPurgeInterval until ctx is done. It does
+// nothing when throttling is disabled.
+func (app *App) startRateLimitPurgeJob(ctx context.Context) {
+	if app.rateLimiter == nil {
+		return
+	}
+
+	ticker := time.NewTicker(rateLimitPurgeInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := app.rateLimiter.PurgeIdle(ctx); err != nil {
+			app.logger.Error(ctx, "rate limit purge failed", "error", err)
+		} else if n > 0 {
+			app.logger.Info(ctx, "rate limits purged", "purged", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 // Run initializes context/cancellation, installs signal handling, and starts
-// the gRPC server. The call blocks until the server goroutine returns.
+// the gRPC server with its certificate reloader, the blob server, the
+// tombstone purge job, the stale upload report, the blob garbage
+// collection and the rate limit purge job. The call blocks until all
+// of them return.
 func (app *App) Run() {
 	ctx, cancelFunc := context.WithCancel(context.Background())
 	app.logger.Info(ctx, "Starting app...")
 	app.initSignalHandler(cancelFunc)
 
 	var wg sync.WaitGroup
-	wg.Add(1)
+	wg.Add(7)
 	go func() {
 		defer wg.Done()
 		app.startGRPCServer(ctx, cancelFunc)
 	}()
+	go func() {
+		defer wg.Done()
+		app.startTLSReloader(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		app.startBlobServer(ctx, cancelFunc)
+	}()
+	go func() {
+		defer wg.Done()
+		app.startPurgeJob(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		app.startStaleUploadJob(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		app.startBlobGCJob(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		app.startRateLimitPurgeJob(ctx)
+	}()
 	wg.Wait()
 }
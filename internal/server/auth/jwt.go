@@ -10,20 +10,25 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// Claims wraps jwt.RegisteredClaims and adds the application-specific UserID.
+// Claims wraps jwt.RegisteredClaims and adds the application-specific UserID
+// and the SessionID of the device login the token was minted for. Tokens
+// issued before sessions were tracked have no SessionID.
 type Claims struct {
 	jwt.RegisteredClaims
-	UserID string
+	UserID    string
+	SessionID string `json:",omitempty"`
 }
 
-// GenerateToken creates an HS256-signed JWT containing the given userID and an
-// expiration set to now + validityDuration. The token is signed with secretKey.
-func GenerateToken(userID string, secretKey []byte, validityDuration time.Duration) (string, error) {
+// GenerateToken creates an HS256-signed JWT containing the given userID and
+// sessionID and an expiration set to now + validityDuration. The token is
+// signed with secretKey.
+func GenerateToken(userID string, sessionID string, secretKey []byte, validityDuration time.Duration) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(validityDuration)),
 		},
-		UserID: userID,
+		UserID:    userID,
+		SessionID: sessionID,
 	})
 	tokenString, err := token.SignedString(secretKey)
 	if err != nil {
@@ -32,22 +37,32 @@ func GenerateToken(userID string, secretKey []byte, validityDuration time.Durati
 	return tokenString, nil
 }
 
-// GetUserIDFromToken parses and validates a JWT using secretKey and returns the
-// embedded UserID. If the token is expired it returns common.ErrTokenExpired;
-// if the token is otherwise invalid it returns common.ErrInvalidToken.
-func GetUserIDFromToken(tokenString string, secretKey []byte) (string, error) {
+// ParseToken parses and validates a JWT using secretKey and returns its
+// claims. If the token is expired it returns common.ErrTokenExpired; if the
+// token is otherwise invalid it returns common.ErrInvalidToken.
+func ParseToken(tokenString string, secretKey []byte) (*Claims, error) {
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
 		return secretKey, nil
 	})
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
-			return "", common.ErrTokenExpired
+			return nil, common.ErrTokenExpired
 		}
-		return "", err
+		return nil, err
 	}
 	if !token.Valid {
-		return "", common.ErrInvalidToken
+		return nil, common.ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// GetUserIDFromToken parses and validates a JWT like ParseToken and returns
+// the embedded UserID.
+func GetUserIDFromToken(tokenString string, secretKey []byte) (string, error) {
+	claims, err := ParseToken(tokenString, secretKey)
+	if err != nil {
+		return "", err
 	}
 	return claims.UserID, nil
 }
@@ -15,7 +15,7 @@ func TestGenerateAndParse_Success(t *testing.T) {
 	secret := []byte("super-secret")
 	userID := "user-123"
 
-	tok, err := GenerateToken(userID, secret, time.Hour)
+	tok, err := GenerateToken(userID, "s1", secret, time.Hour)
 	if err != nil {
 		t.Fatalf("GenerateToken error: %v", err)
 	}
@@ -35,7 +35,7 @@ func TestGetUserIDFromToken_Expired(t *testing.T) {
 	secret := []byte("secret")
 	userID := "u1"
 
-	tok, err := GenerateToken(userID, secret, -1*time.Second)
+	tok, err := GenerateToken(userID, "s1", secret, -1*time.Second)
 	if err != nil {
 		t.Fatalf("GenerateToken error: %v", err)
 	}
@@ -53,7 +53,7 @@ func TestGetUserIDFromToken_WrongSecret(t *testing.T) {
 	t.Parallel()
 
 	userID := "u2"
-	tok, err := GenerateToken(userID, []byte("right-secret"), time.Hour)
+	tok, err := GenerateToken(userID, "s1", []byte("right-secret"), time.Hour)
 	if err != nil {
 		t.Fatalf("GenerateToken error: %v", err)
 	}
@@ -72,3 +72,27 @@ func TestGetUserIDFromToken_MalformedString(t *testing.T) {
 		t.Fatalf("expected error for malformed token, got nil")
 	}
 }
+
+func TestParseToken_SessionID(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("secret")
+	tok, err := GenerateToken("u1", "s1", secret, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken error: %v", err)
+	}
+	claims, err := ParseToken(tok, secret)
+	if err != nil {
+		t.Fatalf("ParseToken error: %v", err)
+	}
+	if claims.UserID != "u1" || claims.SessionID != "s1" {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	// tokens minted before sessions were tracked carry no session
+	legacy, _ := GenerateToken("u1", "", secret, time.Hour)
+	claims, err = ParseToken(legacy, secret)
+	if err != nil || claims.SessionID != "" {
+		t.Fatalf("legacy token: got (%+v, %v)", claims, err)
+	}
+}
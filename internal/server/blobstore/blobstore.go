@@ -0,0 +1,79 @@
+// Package blobstore abstracts the object storage that holds the encrypted
+// file blobs. Clients never send blobs through the gRPC API: the server hands
+// out short-lived presigned URLs and clients transfer the blobs directly.
+//
+// Two backends are provided:
+//   - S3Store keeps blobs in an S3-compatible bucket (AWS S3, MinIO) and
+//     presigns URLs with the AWS SDK.
+//   - FSStore keeps blobs in a local directory and serves them itself over
+//     HTTP, behind URLs signed with an HMAC and an expiry. Small self-hosted
+//     installs then need nothing but Postgres.
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/server/config"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+)
+
+// presignExpiry is how long a presigned URL stays valid.
+const presignExpiry = 15 * time.Minute
+
+// Backend names accepted in config.Config.BlobBackend.
+const (
+	BackendS3 = "s3"
+	BackendFS = "fs"
+)
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// BlobStore stores encrypted file blobs under storage keys and presigns URLs
+// for clients to transfer them. Stat of a missing object returns
+// common.ErrorNotFound.
+type BlobStore interface {
+	// PresignPut returns a short-lived URL to PUT the object under key.
+	PresignPut(ctx context.Context, key string) (string, error)
+	// PresignGet returns a short-lived URL to GET the object under key.
+	PresignGet(ctx context.Context, key string) (string, error)
+	// Delete removes the object under key. Deleting a missing object is
+	// not an error.
+	Delete(ctx context.Context, key string) error
+	// Stat returns the size and modification time of the object under key.
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// List calls fn for every stored object whose key starts with prefix,
+	// until fn returns an error. Objects stored or deleted while the
+	// listing runs may or may not be seen.
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+
+	// CreateMultipartUpload starts an upload of the object under key in
+	// parts and returns its upload ID.
+	CreateMultipartUpload(ctx context.Context, key string) (string, error)
+	// PresignUploadPart returns a short-lived URL to PUT one part of the
+	// multipart upload uploadID. The response carries the part's ETag.
+	PresignUploadPart(ctx context.Context, key string, uploadID string, part int32) (string, error)
+	// CompleteMultipartUpload assembles the uploaded parts, in any order,
+	// into the object under key.
+	CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []models.UploadedPart) error
+	// AbortMultipartUpload discards the upload uploadID and its parts.
+	AbortMultipartUpload(ctx context.Context, key string, uploadID string) error
+}
+
+// New returns the BlobStore selected by cfg.BlobBackend.
+func New(cfg *config.Config) (BlobStore, error) {
+	switch cfg.BlobBackend {
+	case BackendS3:
+		return NewS3Store(cfg), nil
+	case BackendFS:
+		return NewFSStore(cfg.BlobDir, cfg.BlobBaseURL, cfg.SecretKey, cfg.BlobMaxPartSize)
+	default:
+		return nil, fmt.Errorf("unknown blob backend %q", cfg.BlobBackend)
+	}
+}
@@ -0,0 +1,452 @@
+package blobstore
+
+import (
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+)
+
+// Operations a signed FSStore URL can grant.
+const (
+	opGet  = "get"
+	opPut  = "put"
+	opPart = "part"
+)
+
+// urlSigningLabel separates the URL signing key from the other uses of the
+// server secret (JWTs).
+const urlSigningLabel = "gophkeeper blob url"
+
+// FSStore is a BlobStore that keeps objects as files below a local directory
+// and serves them itself: it is also the http.Handler behind the URLs it
+// presigns. A URL carries its operation, expiry and, for a part, the upload
+// ID and part number, all signed with an HMAC of the server secret, so it
+// grants exactly one operation on one object until it expires.
+//
+// Layout below the root directory:
+//
+//	objects/<storage key>        stored objects
+//	uploads/<upload ID>/<part>   parts of multipart uploads in progress
+//	tmp/                         files being written
+//
+// Objects and parts are written to tmp first and renamed into place, so a
+// broken transfer never leaves a partial object behind. A single PUT, of an
+// object or of a part, takes at most maxPartSize bytes.
+type FSStore struct {
+	root        string
+	baseURL     *url.URL
+	basePath    string
+	secret      []byte
+	maxPartSize int64
+	now         func() time.Time
+}
+
+// NewFSStore returns an FSStore keeping its objects below root and handing
+// out URLs below baseURL, the address at which clients reach the store's
+// HTTP handler. URLs are signed with a key derived from secretKey. Uploads
+// larger than maxPartSize bytes per PUT are rejected.
+func NewFSStore(root string, baseURL string, secretKey string, maxPartSize int64) (*FSStore, error) {
+	if maxPartSize <= 0 {
+		return nil, fmt.Errorf("blob max part size %d is not positive", maxPartSize)
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("blob base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("blob base URL %q is not an absolute http(s) URL", baseURL)
+	}
+	for _, dir := range []string{"objects", "uploads", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o700); err != nil {
+			return nil, err
+		}
+	}
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(urlSigningLabel))
+	return &FSStore{
+		root:        root,
+		baseURL:     u,
+		basePath:    strings.TrimSuffix(u.Path, "/"),
+		secret:      mac.Sum(nil),
+		maxPartSize: maxPartSize,
+		now:         time.Now,
+	}, nil
+}
+
+// objectPath returns the file of the object under key. Keys must be relative
+// slash-separated paths that stay below the objects directory.
+func (s *FSStore) objectPath(key string) (string, error) {
+	p := filepath.FromSlash(key)
+	if key == "" || strings.Contains(key, `\`) || !filepath.IsLocal(p) {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(s.root, "objects", p), nil
+}
+
+// uploadDir returns the directory holding the parts of upload uploadID.
+func (s *FSStore) uploadDir(uploadID string) (string, error) {
+	if _, err := hex.DecodeString(uploadID); err != nil || uploadID == "" {
+		return "", fmt.Errorf("invalid upload id %q", uploadID)
+	}
+	return filepath.Join(s.root, "uploads", uploadID), nil
+}
+
+// sign returns the signature of a URL granting op on key until exp.
+func (s *FSStore) sign(op, key, uploadID string, part int32, exp int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%d\n%d", op, key, uploadID, part, exp)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// presign returns a URL granting op on key for presignExpiry.
+func (s *FSStore) presign(op, key, uploadID string, part int32) (string, error) {
+	if _, err := s.objectPath(key); err != nil {
+		return "", err
+	}
+	exp := s.now().Add(presignExpiry).Unix()
+	q := url.Values{}
+	q.Set("op", op)
+	q.Set("exp", strconv.FormatInt(exp, 10))
+	if op == opPart {
+		q.Set("upload", uploadID)
+		q.Set("part", strconv.Itoa(int(part)))
+	}
+	q.Set("sig", s.sign(op, key, uploadID, part, exp))
+
+	u := s.baseURL.JoinPath(key)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// PresignPut returns a short-lived URL to PUT an object under key.
+func (s *FSStore) PresignPut(ctx context.Context, key string) (string, error) {
+	return s.presign(opPut, key, "", 0)
+}
+
+// PresignGet returns a short-lived URL to GET an object under key. The
+// handler honors Range requests.
+func (s *FSStore) PresignGet(ctx context.Context, key string) (string, error) {
+	return s.presign(opGet, key, "", 0)
+}
+
+// Delete removes the object under key.
+func (s *FSStore) Delete(ctx context.Context, key string) error {
+	p, err := s.objectPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Stat returns the size and modification time of the object under key.
+func (s *FSStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	p, err := s.objectPath(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	fi, err := os.Stat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return ObjectInfo{}, common.ErrorNotFound
+	}
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{Key: key, Size: fi.Size(), ModTime: fi.ModTime()}, nil
+}
+
+// List walks the objects directory and calls fn for every object whose key
+// starts with prefix, in lexical order.
+func (s *FSStore) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	objects := filepath.Join(s.root, "objects")
+	return filepath.WalkDir(objects, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// an object deleted while walking
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(objects, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if d.IsDir() {
+			// skip directories that cannot hold a matching key
+			if path != objects && !strings.HasPrefix(key+"/", prefix) && !strings.HasPrefix(prefix, key+"/") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		fi, err := d.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fn(ObjectInfo{Key: key, Size: fi.Size(), ModTime: fi.ModTime()})
+	})
+}
+
+// CreateMultipartUpload starts an upload in parts and returns its upload ID.
+func (s *FSStore) CreateMultipartUpload(ctx context.Context, key string) (string, error) {
+	if _, err := s.objectPath(key); err != nil {
+		return "", err
+	}
+	uploadID, err := common.MakeRandHexString(16)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Mkdir(filepath.Join(s.root, "uploads", uploadID), 0o700); err != nil {
+		return "", err
+	}
+	return uploadID, nil
+}
+
+// PresignUploadPart returns a short-lived URL to PUT one part of the
+// multipart upload uploadID.
+func (s *FSStore) PresignUploadPart(ctx context.Context, key string, uploadID string, part int32) (string, error) {
+	if _, err := s.uploadDir(uploadID); err != nil {
+		return "", err
+	}
+	if part < 1 {
+		return "", fmt.Errorf("invalid part number %d", part)
+	}
+	return s.presign(opPart, key, uploadID, part)
+}
+
+// CompleteMultipartUpload concatenates the given parts in ascending order
+// into the object under key and removes the upload. Every part must still
+// have the ETag its upload returned.
+func (s *FSStore) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []models.UploadedPart) error {
+	dst, err := s.objectPath(key)
+	if err != nil {
+		return err
+	}
+	dir, err := s.uploadDir(uploadID)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("upload %s: %w", uploadID, err)
+	}
+
+	parts = slices.Clone(parts)
+	slices.SortFunc(parts, func(a, b models.UploadedPart) int {
+		return cmp.Compare(a.PartNumber, b.PartNumber)
+	})
+	if err := s.writeObject(dst, func(w io.Writer) error {
+		for _, p := range parts {
+			if err := appendPart(w, filepath.Join(dir, strconv.Itoa(int(p.PartNumber))), p.ETag); err != nil {
+				return fmt.Errorf("part %d: %w", p.PartNumber, err)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// appendPart copies the part file at path to w and checks its ETag.
+func appendPart(w io.Writer, path string, etag string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, h), f); err != nil {
+		return err
+	}
+	if strings.Trim(etag, `"`) != hex.EncodeToString(h.Sum(nil)) {
+		return errors.New("ETag mismatch")
+	}
+	return nil
+}
+
+// AbortMultipartUpload discards the upload uploadID and its parts.
+func (s *FSStore) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	dir, err := s.uploadDir(uploadID)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// writeObject writes a file through fill to the tmp directory and renames it
+// to dst once fill succeeds.
+func (s *FSStore) writeObject(dst string, fill func(io.Writer) error) (err error) {
+	tmp, err := os.CreateTemp(filepath.Join(s.root, "tmp"), "blob-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if err = fill(tmp); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// ServeHTTP serves the URLs presigned by the store: GET (and HEAD) of an
+// object and PUT of an object or of a part. Requests whose signature does not
+// match or has expired are rejected with 403 Forbidden.
+func (s *FSStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key, ok := strings.CutPrefix(r.URL.Path, s.basePath+"/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	q := r.URL.Query()
+	op, uploadID := q.Get("op"), q.Get("upload")
+	exp, err := strconv.ParseInt(q.Get("exp"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid expiry", http.StatusForbidden)
+		return
+	}
+	var part int32
+	if op == opPart {
+		n, err := strconv.ParseInt(q.Get("part"), 10, 32)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid part number", http.StatusBadRequest)
+			return
+		}
+		part = int32(n)
+	}
+	if !hmac.Equal([]byte(q.Get("sig")), []byte(s.sign(op, key, uploadID, part, exp))) {
+		http.Error(w, "signature does not match", http.StatusForbidden)
+		return
+	}
+	if s.now().Unix() > exp {
+		http.Error(w, "request has expired", http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case op == opGet && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		s.serveGet(w, r, key)
+	case op == opPut && r.Method == http.MethodPut:
+		s.servePut(w, r, key)
+	case op == opPart && r.Method == http.MethodPut:
+		s.servePart(w, r, uploadID, part)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// serveGet sends the object under key, honoring Range requests.
+func (s *FSStore) serveGet(w http.ResponseWriter, r *http.Request, key string) {
+	p, err := s.objectPath(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", fi.ModTime(), f)
+}
+
+// servePut stores the request body as the object under key.
+func (s *FSStore) servePut(w http.ResponseWriter, r *http.Request, key string) {
+	p, err := s.objectPath(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.store(w, r, p)
+}
+
+// servePart stores the request body as one part of upload uploadID.
+func (s *FSStore) servePart(w http.ResponseWriter, r *http.Request, uploadID string, part int32) {
+	dir, err := s.uploadDir(uploadID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// an aborted or completed upload takes no more parts
+	if _, err := os.Stat(dir); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	s.store(w, r, filepath.Join(dir, strconv.Itoa(int(part))))
+}
+
+// store writes the request body to dst and returns its SHA-256 digest as the
+// ETag. Bodies larger than the maximum part size are rejected with 413
+// Request Entity Too Large, before reading them if they say so up front.
+func (s *FSStore) store(w http.ResponseWriter, r *http.Request, dst string) {
+	if r.ContentLength > s.maxPartSize {
+		http.Error(w, "upload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	body := http.MaxBytesReader(w, r.Body, s.maxPartSize)
+	h := sha256.New()
+	if err := s.writeObject(dst, func(f io.Writer) error {
+		_, err := io.Copy(io.MultiWriter(f, h), body)
+		return err
+	}); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "upload too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "upload failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", `"`+hex.EncodeToString(h.Sum(nil))+`"`)
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,303 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	"github.com/dmitrijs2005/gophkeeper/internal/netx"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+)
+
+// newFSStoreForTest serves a new FSStore in a temporary directory below
+// /blobs of a test server.
+func newFSStoreForTest(t *testing.T) (*FSStore, *httptest.Server) {
+	t.Helper()
+	srv := httptest.NewServer(nil)
+	t.Cleanup(srv.Close)
+
+	store, err := NewFSStore(t.TempDir(), srv.URL+"/blobs/", "secret", 1<<20)
+	if err != nil {
+		t.Fatalf("NewFSStore: %v", err)
+	}
+	srv.Config.Handler = store
+	return store, srv
+}
+
+func TestFSStore_PutGetStatDelete(t *testing.T) {
+	store, _ := newFSStoreForTest(t)
+	ctx := context.Background()
+	blob := []byte("0123456789 ciphertext")
+
+	put, err := store.PresignPut(ctx, "users/2025/10/1/k1")
+	if err != nil {
+		t.Fatalf("PresignPut: %v", err)
+	}
+	if err := netx.UploadToS3PresignedURL(put, bytes.NewReader(blob), int64(len(blob))); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+
+	info, err := store.Stat(ctx, "users/2025/10/1/k1")
+	if err != nil || info.Size != int64(len(blob)) {
+		t.Fatalf("Stat: %+v, %v", info, err)
+	}
+
+	get, err := store.PresignGet(ctx, "users/2025/10/1/k1")
+	if err != nil {
+		t.Fatalf("PresignGet: %v", err)
+	}
+	// a download resumes from a partial file with a Range request
+	path := filepath.Join(t.TempDir(), "blob")
+	if err := os.WriteFile(path, blob[:10], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := netx.DownloadToFile(get, path); err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, blob) {
+		t.Fatalf("downloaded %q", got)
+	}
+
+	if err := store.Delete(ctx, "users/2025/10/1/k1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Stat(ctx, "users/2025/10/1/k1"); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want ErrorNotFound after delete, got %v", err)
+	}
+	if err := store.Delete(ctx, "users/2025/10/1/k1"); err != nil {
+		t.Fatalf("deleting a missing object: %v", err)
+	}
+	if _, err := netx.DownloadFromS3PresignedURL(get); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("want 404 for a deleted object, got %v", err)
+	}
+}
+
+func TestFSStore_RejectsForgedAndExpiredURLs(t *testing.T) {
+	store, _ := newFSStoreForTest(t)
+	ctx := context.Background()
+
+	put, _ := store.PresignPut(ctx, "users/k1")
+	get, _ := store.PresignGet(ctx, "users/k1")
+
+	status := func(method, rawURL string) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, rawURL, strings.NewReader("x"))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, rawURL, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	tamper := func(rawURL string, f func(u *url.URL, q url.Values)) string {
+		u, _ := url.Parse(rawURL)
+		q := u.Query()
+		f(u, q)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+
+	cases := map[string]string{
+		"other key":      tamper(put, func(u *url.URL, q url.Values) { u.Path = "/blobs/users/k2" }),
+		"longer expiry":  tamper(put, func(u *url.URL, q url.Values) { q.Set("exp", "99999999999") }),
+		"get as put":     tamper(get, func(u *url.URL, q url.Values) { q.Set("op", opPut) }),
+		"no signature":   tamper(put, func(u *url.URL, q url.Values) { q.Del("sig") }),
+		"other secret":   strings.Replace(put, "sig=", "sig=00", 1),
+		"missing expiry": tamper(put, func(u *url.URL, q url.Values) { q.Del("exp") }),
+	}
+	for name, u := range cases {
+		if got := status(http.MethodPut, u); got != http.StatusForbidden {
+			t.Fatalf("%s: want 403, got %d", name, got)
+		}
+	}
+	// a valid URL grants its own method only
+	if got := status(http.MethodPut, get); got != http.StatusMethodNotAllowed {
+		t.Fatalf("PUT with a GET URL: want 405, got %d", got)
+	}
+
+	store.now = func() time.Time { return time.Now().Add(-time.Hour) }
+	expired, _ := store.PresignPut(ctx, "users/k1")
+	store.now = time.Now
+	if got := status(http.MethodPut, expired); got != http.StatusForbidden {
+		t.Fatalf("expired URL: want 403, got %d", got)
+	}
+	if got := status(http.MethodPut, put); got != http.StatusOK {
+		t.Fatalf("valid URL: want 200, got %d", got)
+	}
+}
+
+func TestFSStore_RejectsKeysOutsideRoot(t *testing.T) {
+	store, _ := newFSStoreForTest(t)
+	for _, key := range []string{"", "../etc/passwd", "/abs", "users/../../x", `users\x`} {
+		if _, err := store.PresignPut(context.Background(), key); err == nil {
+			t.Fatalf("key %q accepted", key)
+		}
+	}
+}
+
+func TestFSStore_RejectsOversizedUploads(t *testing.T) {
+	store, _ := newFSStoreForTest(t)
+	store.maxPartSize = 16
+	ctx := context.Background()
+	put, _ := store.PresignPut(ctx, "users/k1")
+
+	// one request declares its length, the other streams it in chunks
+	for name, body := range map[string]io.Reader{
+		"content length": strings.NewReader(strings.Repeat("x", 17)),
+		"chunked":        io.MultiReader(strings.NewReader(strings.Repeat("x", 17))),
+	} {
+		req, _ := http.NewRequest(http.MethodPut, put, body)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Fatalf("%s: want 413, got %d", name, resp.StatusCode)
+		}
+		if _, err := store.Stat(ctx, "users/k1"); !errors.Is(err, common.ErrorNotFound) {
+			t.Fatalf("%s: oversized object stored: %v", name, err)
+		}
+	}
+
+	if err := netx.UploadToS3PresignedURL(put, strings.NewReader("exactly 16 bytes"), 16); err != nil {
+		t.Fatalf("upload at the limit: %v", err)
+	}
+}
+
+func TestFSStore_MultipartUpload(t *testing.T) {
+	store, _ := newFSStoreForTest(t)
+	ctx := context.Background()
+	key := "users/k1"
+	chunks := [][]byte{[]byte("first part "), []byte("second part "), []byte("third")}
+
+	id, err := store.CreateMultipartUpload(ctx, key)
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+
+	// parts may arrive in any order and be uploaded again
+	var parts []models.UploadedPart
+	for _, n := range []int32{3, 1, 2, 1} {
+		u, err := store.PresignUploadPart(ctx, key, id, n)
+		if err != nil {
+			t.Fatalf("PresignUploadPart: %v", err)
+		}
+		etag, err := netx.UploadPartToS3PresignedURL(u, bytes.NewReader(chunks[n-1]), int64(len(chunks[n-1])))
+		if err != nil {
+			t.Fatalf("upload part %d: %v", n, err)
+		}
+		parts = append(parts, models.UploadedPart{PartNumber: n, ETag: etag})
+	}
+	parts = parts[:3]
+
+	// a wrong ETag fails the upload and leaves no object behind
+	bad := append([]models.UploadedPart{}, parts...)
+	bad[0].ETag = `"00"`
+	if err := store.CompleteMultipartUpload(ctx, key, id, bad); err == nil {
+		t.Fatalf("completed with a wrong ETag")
+	}
+	if _, err := store.Stat(ctx, key); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("object stored after failed completion: %v", err)
+	}
+
+	if err := store.CompleteMultipartUpload(ctx, key, id, parts); err != nil {
+		t.Fatalf("CompleteMultipartUpload: %v", err)
+	}
+	get, _ := store.PresignGet(ctx, key)
+	body, err := netx.DownloadFromS3PresignedURL(get)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	defer body.Close()
+	if got, _ := io.ReadAll(body); string(got) != "first part second part third" {
+		t.Fatalf("assembled %q", got)
+	}
+
+	// a completed upload takes no more parts
+	u, _ := store.PresignUploadPart(ctx, key, id, 4)
+	if _, err := netx.UploadPartToS3PresignedURL(u, strings.NewReader("x"), 1); err == nil {
+		t.Fatalf("part accepted after completion")
+	}
+	if err := store.CompleteMultipartUpload(ctx, key, id, parts); err == nil {
+		t.Fatalf("completed twice")
+	}
+}
+
+func TestFSStore_AbortMultipartUpload(t *testing.T) {
+	store, _ := newFSStoreForTest(t)
+	ctx := context.Background()
+
+	id, err := store.CreateMultipartUpload(ctx, "users/k1")
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	u, _ := store.PresignUploadPart(ctx, "users/k1", id, 1)
+	if _, err := netx.UploadPartToS3PresignedURL(u, strings.NewReader("part"), 4); err != nil {
+		t.Fatalf("upload part: %v", err)
+	}
+
+	if err := store.AbortMultipartUpload(ctx, "users/k1", id); err != nil {
+		t.Fatalf("AbortMultipartUpload: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(store.root, "uploads", id)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("parts not removed: %v", err)
+	}
+	if _, err := store.PresignUploadPart(ctx, "users/k1", "../x", 1); err == nil {
+		t.Fatalf("invalid upload id accepted")
+	}
+}
+
+func TestFSStore_List(t *testing.T) {
+	store, _ := newFSStoreForTest(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"users/2025/10/1/a", "users/2025/10/2/b", "users/2025/11/1/c", "other/d"} {
+		path, err := store.objectPath(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fill := func(w io.Writer) error { _, err := io.WriteString(w, key); return err }
+		if err := store.writeObject(path, fill); err != nil {
+			t.Fatalf("writeObject %s: %v", key, err)
+		}
+	}
+
+	var got []string
+	err := store.List(ctx, "users/2025/10/", func(o ObjectInfo) error {
+		if o.Size != int64(len(o.Key)) || o.ModTime.IsZero() {
+			t.Fatalf("unexpected info %+v", o)
+		}
+		got = append(got, o.Key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if strings.Join(got, ",") != "users/2025/10/1/a,users/2025/10/2/b" {
+		t.Fatalf("listed %v", got)
+	}
+
+	// fn stops the listing
+	stop := errors.New("stop")
+	n := 0
+	err = store.List(ctx, "", func(ObjectInfo) error { n++; return stop })
+	if !errors.Is(err, stop) || n != 1 {
+		t.Fatalf("want stop after one object, got %v after %d", err, n)
+	}
+
+	empty, _ := newFSStoreForTest(t)
+	if err := empty.List(ctx, "users/", func(ObjectInfo) error { t.Fatalf("listed an object"); return nil }); err != nil {
+		t.Fatalf("List of an empty store: %v", err)
+	}
+}
@@ -0,0 +1,283 @@
+package blobstore
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	sc "github.com/dmitrijs2005/gophkeeper/internal/server/config"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+var (
+	// seams for unit tests
+	loadDefaultAWSConfig = config.LoadDefaultConfig
+
+	newS3ClientFromConfig = func(cfg aws.Config, optFns ...func(*s3.Options)) *s3.Client {
+		return s3.NewFromConfig(cfg, optFns...)
+	}
+	newS3PresignClient = func(c *s3.Client) *s3.PresignClient {
+		return s3.NewPresignClient(c)
+	}
+	presignPutObject = func(pc *s3.PresignClient, ctx context.Context, in *s3.PutObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+		return pc.PresignPutObject(ctx, in, optFns...)
+	}
+	presignGetObject = func(pc *s3.PresignClient, ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+		return pc.PresignGetObject(ctx, in, optFns...)
+	}
+	deleteS3Object = func(c *s3.Client, ctx context.Context, in *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		return c.DeleteObject(ctx, in, optFns...)
+	}
+	headS3Object = func(c *s3.Client, ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return c.HeadObject(ctx, in, optFns...)
+	}
+	listObjectsV2 = func(c *s3.Client, ctx context.Context, in *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+		return c.ListObjectsV2(ctx, in, optFns...)
+	}
+	createMultipartUpload = func(c *s3.Client, ctx context.Context, in *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+		return c.CreateMultipartUpload(ctx, in, optFns...)
+	}
+	presignUploadPart = func(pc *s3.PresignClient, ctx context.Context, in *s3.UploadPartInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+		return pc.PresignUploadPart(ctx, in, optFns...)
+	}
+	completeMultipartUpload = func(c *s3.Client, ctx context.Context, in *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+		return c.CompleteMultipartUpload(ctx, in, optFns...)
+	}
+	abortMultipartUpload = func(c *s3.Client, ctx context.Context, in *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+		return c.AbortMultipartUpload(ctx, in, optFns...)
+	}
+)
+
+// S3Store is a BlobStore backed by a bucket of an S3-compatible service.
+type S3Store struct {
+	config *sc.Config
+}
+
+// NewS3Store returns an S3Store for the bucket, region, endpoint and static
+// credentials (e.g., MinIO) of the given config.
+func NewS3Store(config *sc.Config) *S3Store {
+	return &S3Store{config: config}
+}
+
+// getS3Client builds an S3 client using config-provided endpoint, region,
+// and static credentials.
+func (s *S3Store) getS3Client() (*s3.Client, error) {
+	cfg, err := loadDefaultAWSConfig(context.Background(),
+		config.WithRegion(s.config.S3Region),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			s.config.S3RootUser, s.config.S3RootPassword, "",
+		)))
+	if err != nil {
+		return nil, err
+	}
+	return newS3ClientFromConfig(cfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(s.config.S3BaseEndpoint)
+	}), nil
+}
+
+// getPresignClient builds an S3 presign client on top of getS3Client.
+func (s *S3Store) getPresignClient() (*s3.PresignClient, error) {
+	client, err := s.getS3Client()
+	if err != nil {
+		return nil, err
+	}
+	return newS3PresignClient(client), nil
+}
+
+// PresignPut returns a short-lived URL to PUT an object under key.
+func (s *S3Store) PresignPut(ctx context.Context, key string) (string, error) {
+	presignClient, err := s.getPresignClient()
+	if err != nil {
+		return "", err
+	}
+	bucket := s.config.S3Bucket
+
+	req, err := presignPutObject(presignClient, ctx, &s3.PutObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	}, s3.WithPresignExpires(presignExpiry))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+// PresignGet returns a short-lived URL to GET an object by storage key.
+func (s *S3Store) PresignGet(ctx context.Context, key string) (string, error) {
+	presignClient, err := s.getPresignClient()
+	if err != nil {
+		return "", err
+	}
+	bucket := s.config.S3Bucket
+	req, err := presignGetObject(presignClient, ctx, &s3.GetObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	}, s3.WithPresignExpires(presignExpiry))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+// Delete removes the object stored under key from the bucket.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	client, err := s.getS3Client()
+	if err != nil {
+		return err
+	}
+	bucket := s.config.S3Bucket
+	_, err = deleteS3Object(client, ctx, &s3.DeleteObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	})
+	return err
+}
+
+// Stat returns the size and modification time of the object under key.
+func (s *S3Store) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	client, err := s.getS3Client()
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	bucket := s.config.S3Bucket
+	out, err := headS3Object(client, ctx, &s3.HeadObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return ObjectInfo{}, notFound(err)
+	}
+	return ObjectInfo{Key: key, Size: aws.ToInt64(out.ContentLength), ModTime: aws.ToTime(out.LastModified)}, nil
+}
+
+// List calls fn for every object of the bucket under prefix, a page of
+// listing at a time.
+func (s *S3Store) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	client, err := s.getS3Client()
+	if err != nil {
+		return err
+	}
+	bucket := s.config.S3Bucket
+	in := &s3.ListObjectsV2Input{Bucket: &bucket, Prefix: &prefix}
+	for {
+		out, err := listObjectsV2(client, ctx, in)
+		if err != nil {
+			return err
+		}
+		for _, o := range out.Contents {
+			info := ObjectInfo{Key: aws.ToString(o.Key), Size: aws.ToInt64(o.Size), ModTime: aws.ToTime(o.LastModified)}
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+		if !aws.ToBool(out.IsTruncated) || out.NextContinuationToken == nil {
+			return nil
+		}
+		in.ContinuationToken = out.NextContinuationToken
+	}
+}
+
+// CreateMultipartUpload starts an S3 multipart upload of the object under
+// key and returns its upload ID.
+func (s *S3Store) CreateMultipartUpload(ctx context.Context, key string) (string, error) {
+	client, err := s.getS3Client()
+	if err != nil {
+		return "", err
+	}
+	bucket := s.config.S3Bucket
+	out, err := createMultipartUpload(client, ctx, &s3.CreateMultipartUploadInput{
+		Bucket: &bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.UploadId), nil
+}
+
+// PresignUploadPart returns a short-lived URL to PUT one part of the
+// multipart upload uploadID.
+func (s *S3Store) PresignUploadPart(ctx context.Context, key string, uploadID string, part int32) (string, error) {
+	presignClient, err := s.getPresignClient()
+	if err != nil {
+		return "", err
+	}
+	bucket := s.config.S3Bucket
+	req, err := presignUploadPart(presignClient, ctx, &s3.UploadPartInput{
+		Bucket:     &bucket,
+		Key:        &key,
+		UploadId:   &uploadID,
+		PartNumber: aws.Int32(part),
+	}, s3.WithPresignExpires(presignExpiry))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+// CompleteMultipartUpload assembles the uploaded parts into the object under
+// key.
+func (s *S3Store) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []models.UploadedPart) error {
+	// S3 wants the parts in ascending order
+	completed := make([]types.CompletedPart, 0, len(parts))
+	for _, p := range parts {
+		completed = append(completed, types.CompletedPart{
+			ETag:       aws.String(p.ETag),
+			PartNumber: aws.Int32(p.PartNumber),
+		})
+	}
+	slices.SortFunc(completed, func(a, b types.CompletedPart) int {
+		return cmp.Compare(*a.PartNumber, *b.PartNumber)
+	})
+
+	client, err := s.getS3Client()
+	if err != nil {
+		return err
+	}
+	bucket := s.config.S3Bucket
+	_, err = completeMultipartUpload(client, ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          &bucket,
+		Key:             &key,
+		UploadId:        &uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	return err
+}
+
+// AbortMultipartUpload aborts the multipart upload uploadID of the object
+// under key.
+func (s *S3Store) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	client, err := s.getS3Client()
+	if err != nil {
+		return err
+	}
+	bucket := s.config.S3Bucket
+	_, err = abortMultipartUpload(client, ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   &bucket,
+		Key:      &key,
+		UploadId: &uploadID,
+	})
+	return err
+}
+
+// notFound reports S3's errors for a missing object as common.ErrorNotFound.
+func notFound(err error) error {
+	var (
+		noObject *types.NotFound
+		noKey    *types.NoSuchKey
+	)
+	if errors.As(err, &noObject) || errors.As(err, &noKey) {
+		return fmt.Errorf("%w: %v", common.ErrorNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,265 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	sc "github.com/dmitrijs2005/gophkeeper/internal/server/config"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+)
+
+func newS3StoreForTest() *S3Store {
+	return NewS3Store(&sc.Config{
+		S3Region:       "us-east-1",
+		S3RootUser:     "minioadmin",
+		S3RootPassword: "minioadmin",
+		S3BaseEndpoint: "http://127.0.0.1:9000",
+		S3Bucket:       "gophkeeper",
+	})
+}
+
+func Test_getPresignClient_SuccessAndError(t *testing.T) {
+	store := newS3StoreForTest()
+
+	origLoad := loadDefaultAWSConfig
+	origNewS3 := newS3ClientFromConfig
+	origNewPre := newS3PresignClient
+	t.Cleanup(func() {
+		loadDefaultAWSConfig = origLoad
+		newS3ClientFromConfig = origNewS3
+		newS3PresignClient = origNewPre
+	})
+
+	loadDefaultAWSConfig = func(ctx context.Context, optFns ...func(*awsconfig.LoadOptions) error) (aws.Config, error) {
+		if len(optFns) == 0 {
+			t.Fatalf("expected config options")
+		}
+		var lo awsconfig.LoadOptions
+		for _, fn := range optFns {
+			if err := fn(&lo); err != nil {
+				t.Fatalf("load options fn error: %v", err)
+			}
+		}
+		if lo.Region != "us-east-1" {
+			t.Fatalf("region not applied: %q", lo.Region)
+		}
+		return aws.Config{}, nil
+	}
+
+	var capturedBaseEndpoint string
+	newS3ClientFromConfig = func(cfg aws.Config, optFns ...func(*s3.Options)) *s3.Client {
+		var opts s3.Options
+		for _, fn := range optFns {
+			fn(&opts)
+		}
+		if opts.BaseEndpoint == nil {
+			t.Fatalf("BaseEndpoint not set")
+		}
+		capturedBaseEndpoint = *opts.BaseEndpoint
+		return &s3.Client{}
+	}
+
+	newS3PresignClient = func(c *s3.Client) *s3.PresignClient {
+		if c == nil {
+			t.Fatalf("nil client passed to presign")
+		}
+		return &s3.PresignClient{}
+	}
+
+	pc, err := store.getPresignClient()
+	if err != nil {
+		t.Fatalf("getPresignClient err: %v", err)
+	}
+	if pc == nil {
+		t.Fatalf("nil presign client")
+	}
+	if capturedBaseEndpoint != "http://127.0.0.1:9000" {
+		t.Fatalf("BaseEndpoint mismatch: %q", capturedBaseEndpoint)
+	}
+
+	loadDefaultAWSConfig = func(ctx context.Context, optFns ...func(*awsconfig.LoadOptions) error) (aws.Config, error) {
+		return aws.Config{}, errors.New("load-fail")
+	}
+
+	pc, err = store.getPresignClient()
+	if err == nil || err.Error() != "load-fail" {
+		t.Fatalf("expected load-fail, got %v (pc=%v)", err, pc)
+	}
+}
+
+func TestS3Store_PresignErrors(t *testing.T) {
+	store := newS3StoreForTest()
+
+	orig := loadDefaultAWSConfig
+	defer func() { loadDefaultAWSConfig = orig }()
+	loadDefaultAWSConfig = func(ctx context.Context, optFns ...func(*awsconfig.LoadOptions) error) (aws.Config, error) {
+		return aws.Config{}, errors.New("load-fail")
+	}
+
+	if _, err := store.PresignPut(context.Background(), "k"); err == nil || err.Error() != "load-fail" {
+		t.Fatalf("PresignPut: want load-fail, got %v", err)
+	}
+	if _, err := store.PresignGet(context.Background(), "k"); err == nil || err.Error() != "load-fail" {
+		t.Fatalf("PresignGet: want load-fail, got %v", err)
+	}
+
+	loadDefaultAWSConfig = orig
+	origPut := presignPutObject
+	defer func() { presignPutObject = origPut }()
+	presignPutObject = func(pc *s3.PresignClient, ctx context.Context, in *s3.PutObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+		return nil, errors.New("presign-put-fail")
+	}
+	if _, err := store.PresignPut(context.Background(), "k"); err == nil || err.Error() != "presign-put-fail" {
+		t.Fatalf("want presign-put-fail, got %v", err)
+	}
+}
+
+func TestS3Store_PresignsWithoutNetwork(t *testing.T) {
+	store := newS3StoreForTest()
+	ctx := context.Background()
+
+	put, err := store.PresignPut(ctx, "users/k1")
+	if err != nil {
+		t.Fatalf("PresignPut: %v", err)
+	}
+	get, err := store.PresignGet(ctx, "users/k1")
+	if err != nil {
+		t.Fatalf("PresignGet: %v", err)
+	}
+	part, err := store.PresignUploadPart(ctx, "users/k1", "up1", 2)
+	if err != nil {
+		t.Fatalf("PresignUploadPart: %v", err)
+	}
+	for _, u := range []string{put, get, part} {
+		if !strings.HasPrefix(u, "http://127.0.0.1:9000/gophkeeper/users/k1?") || !strings.Contains(u, "X-Amz-Signature=") {
+			t.Fatalf("unexpected URL %q", u)
+		}
+	}
+	if !strings.Contains(part, "uploadId=up1") || !strings.Contains(part, "partNumber=2") {
+		t.Fatalf("part URL lacks the upload: %q", part)
+	}
+}
+
+func TestS3Store_StatAndDelete(t *testing.T) {
+	store := newS3StoreForTest()
+	ctx := context.Background()
+
+	origHead, origDelete := headS3Object, deleteS3Object
+	defer func() { headS3Object, deleteS3Object = origHead, origDelete }()
+	modTime := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	headS3Object = func(c *s3.Client, ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		if *in.Key == "gone" {
+			return nil, &types.NotFound{}
+		}
+		return &s3.HeadObjectOutput{ContentLength: aws.Int64(42), LastModified: &modTime}, nil
+	}
+	var deleted []string
+	deleteS3Object = func(c *s3.Client, ctx context.Context, in *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		deleted = append(deleted, *in.Bucket+"/"+*in.Key)
+		return &s3.DeleteObjectOutput{}, nil
+	}
+
+	info, err := store.Stat(ctx, "k1")
+	if err != nil || info.Size != 42 || !info.ModTime.Equal(modTime) {
+		t.Fatalf("Stat: %+v, %v", info, err)
+	}
+	if _, err := store.Stat(ctx, "gone"); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want ErrorNotFound, got %v", err)
+	}
+	if err := store.Delete(ctx, "k1"); err != nil || len(deleted) != 1 || deleted[0] != "gophkeeper/k1" {
+		t.Fatalf("Delete: %v, %v", deleted, err)
+	}
+}
+
+func TestS3Store_Multipart(t *testing.T) {
+	store := newS3StoreForTest()
+	ctx := context.Background()
+
+	origCreate, origComplete, origAbort := createMultipartUpload, completeMultipartUpload, abortMultipartUpload
+	defer func() {
+		createMultipartUpload, completeMultipartUpload, abortMultipartUpload = origCreate, origComplete, origAbort
+	}()
+	createMultipartUpload = func(c *s3.Client, ctx context.Context, in *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+		return &s3.CreateMultipartUploadOutput{UploadId: aws.String("up-" + *in.Key)}, nil
+	}
+	var completed []types.CompletedPart
+	completeMultipartUpload = func(c *s3.Client, ctx context.Context, in *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+		completed = in.MultipartUpload.Parts
+		return &s3.CompleteMultipartUploadOutput{}, nil
+	}
+	var aborted []string
+	abortMultipartUpload = func(c *s3.Client, ctx context.Context, in *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+		aborted = append(aborted, *in.UploadId)
+		return &s3.AbortMultipartUploadOutput{}, nil
+	}
+
+	id, err := store.CreateMultipartUpload(ctx, "k1")
+	if err != nil || id != "up-k1" {
+		t.Fatalf("CreateMultipartUpload: %q, %v", id, err)
+	}
+
+	parts := []models.UploadedPart{{PartNumber: 2, ETag: "b"}, {PartNumber: 1, ETag: "a"}}
+	if err := store.CompleteMultipartUpload(ctx, "k1", id, parts); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	// S3 wants the parts in ascending order
+	if len(completed) != 2 || *completed[0].PartNumber != 1 || *completed[0].ETag != "a" || *completed[1].PartNumber != 2 {
+		t.Fatalf("parts not completed in order: %+v", completed)
+	}
+
+	if err := store.AbortMultipartUpload(ctx, "k1", id); err != nil || len(aborted) != 1 || aborted[0] != id {
+		t.Fatalf("abort: %v, %v", aborted, err)
+	}
+}
+
+func TestS3Store_ListPages(t *testing.T) {
+	store := newS3StoreForTest()
+
+	orig := listObjectsV2
+	defer func() { listObjectsV2 = orig }()
+	modTime := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	pages := map[string]*s3.ListObjectsV2Output{
+		"": {
+			Contents:              []types.Object{{Key: aws.String("users/a"), Size: aws.Int64(1), LastModified: &modTime}},
+			IsTruncated:           aws.Bool(true),
+			NextContinuationToken: aws.String("p2"),
+		},
+		"p2": {
+			Contents: []types.Object{{Key: aws.String("users/b"), Size: aws.Int64(2), LastModified: &modTime}},
+		},
+	}
+	listObjectsV2 = func(c *s3.Client, ctx context.Context, in *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+		if *in.Bucket != "gophkeeper" || *in.Prefix != "users/" {
+			t.Fatalf("unexpected input %s %s", *in.Bucket, *in.Prefix)
+		}
+		return pages[aws.ToString(in.ContinuationToken)], nil
+	}
+
+	var got []ObjectInfo
+	err := store.List(context.Background(), "users/", func(o ObjectInfo) error {
+		got = append(got, o)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(got) != 2 || got[0].Key != "users/a" || got[1].Key != "users/b" || got[1].Size != 2 || !got[1].ModTime.Equal(modTime) {
+		t.Fatalf("listed %+v", got)
+	}
+
+	listObjectsV2 = func(c *s3.Client, ctx context.Context, in *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+		return nil, errors.New("list-fail")
+	}
+	if err := store.List(context.Background(), "users/", func(ObjectInfo) error { return nil }); err == nil || err.Error() != "list-fail" {
+		t.Fatalf("want list-fail, got %v", err)
+	}
+}
@@ -2,7 +2,11 @@
 // including defaults, JSON overlay, and command-line flags.
 package config
 
-import "time"
+import (
+	"time"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
+)
 
 // Config holds runtime settings for the GophKeeper server.
 //
@@ -13,6 +17,34 @@ import "time"
 //   - AccessTokenValidityDuration / RefreshTokenValidityDuration: token lifetimes.
 //   - S3RootUser / S3RootPassword: credentials for the S3-compatible backend.
 //   - S3Bucket / S3Region / S3BaseEndpoint: object storage settings.
+//   - BlobBackend: where file blobs are stored, "s3" or "fs" (a local
+//     directory served by the server itself).
+//   - BlobDir: root directory of the "fs" backend.
+//   - EndpointAddrBlob: bind address of the HTTP endpoint of the "fs" backend.
+//   - BlobBaseURL: URL at which clients reach that endpoint; presigned URLs
+//     are built on it.
+//   - BlobMaxPartSize: largest body in bytes that endpoint accepts with one
+//     PUT, of a whole object or of one part of a multipart upload.
+//   - TombstoneRetention: how long deleted entries are kept before they are
+//     purged for good; 0 disables purging.
+//   - StaleUploadAge: how long a file may wait for its upload before it is
+//     reported as stale; 0 disables the report.
+//   - BlobGCGrace: how long stored objects and deleted files are left alone
+//     before blob garbage collection may delete them; 0 disables the job.
+//   - TLSCertFile / TLSKeyFile: PEM certificate and key of the gRPC endpoint
+//     and of the "fs" blob endpoint; reloaded on SIGHUP.
+//   - TLSClientCAFile: optional PEM bundle of CAs that client certificates
+//     must chain to; with it, clients must present one (mutual TLS).
+//   - Insecure: serve gRPC and the "fs" blob endpoint without TLS, for
+//     development only.
+//   - KDFTime / KDFMemory / KDFThreads: Argon2id cost of new accounts (memory
+//     in KiB). Accounts below it are upgraded on their next login.
+//   - AuthIPRate / AuthUserRate: authentication requests per minute allowed
+//     per client IP and per username; 0 disables the limit.
+//   - AuthLockoutThreshold: failed logins in a row after which a client IP
+//     or username is locked out; 0 disables lockouts.
+//   - AuthLockoutBase / AuthLockoutMax: the first lockout, doubled with every
+//     further failure up to the maximum.
 type Config struct {
 	EndpointAddrGRPC             string
 	DatabaseDSN                  string
@@ -24,6 +56,26 @@ type Config struct {
 	S3Bucket                     string
 	S3Region                     string
 	S3BaseEndpoint               string
+	BlobBackend                  string
+	BlobDir                      string
+	EndpointAddrBlob             string
+	BlobBaseURL                  string
+	BlobMaxPartSize              int64
+	TombstoneRetention           time.Duration
+	StaleUploadAge               time.Duration
+	BlobGCGrace                  time.Duration
+	TLSCertFile                  string
+	TLSKeyFile                   string
+	TLSClientCAFile              string
+	Insecure                     bool
+	KDFTime                      int
+	KDFMemory                    int
+	KDFThreads                   int
+	AuthIPRate                   int
+	AuthUserRate                 int
+	AuthLockoutThreshold         int
+	AuthLockoutBase              time.Duration
+	AuthLockoutMax               time.Duration
 }
 
 // LoadDefaults populates Config with sensible development defaults.
@@ -39,6 +91,36 @@ func (c *Config) LoadDefaults() {
 	c.S3Bucket = "vault"
 	c.S3Region = "us-east-1"
 	c.S3BaseEndpoint = "http://127.0.0.1:9000/"
+	c.BlobBackend = "s3"
+	c.BlobDir = "blobs"
+	c.EndpointAddrBlob = ":8081"
+	c.BlobBaseURL = "http://127.0.0.1:8081/"
+	c.BlobMaxPartSize = 256 << 20
+	c.TombstoneRetention = 30 * 24 * time.Hour
+	c.StaleUploadAge = 24 * time.Hour
+	c.BlobGCGrace = 7 * 24 * time.Hour
+	c.KDFTime = int(cryptox.DefaultKDFParams.Time)
+	c.KDFMemory = int(cryptox.DefaultKDFParams.Memory)
+	c.KDFThreads = int(cryptox.DefaultKDFParams.Threads)
+	c.AuthIPRate = 60
+	c.AuthUserRate = 10
+	c.AuthLockoutThreshold = 5
+	c.AuthLockoutBase = time.Minute
+	c.AuthLockoutMax = time.Hour
+}
+
+// KDFParams returns the configured KDF policy. Out of range values are
+// reported by its Validate method.
+func (c *Config) KDFParams() cryptox.KDFParams {
+	if c.KDFTime < 0 || c.KDFMemory < 0 || c.KDFThreads < 0 || c.KDFThreads > 255 {
+		return cryptox.KDFParams{}
+	}
+	return cryptox.KDFParams{
+		Algorithm: cryptox.KDFArgon2id,
+		Time:      uint32(c.KDFTime),
+		Memory:    uint32(c.KDFMemory),
+		Threads:   uint8(c.KDFThreads),
+	}
 }
 
 // LoadConfig builds a Config by applying defaults, then overlaying values
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -22,6 +23,24 @@ func TestLoadDefaults(t *testing.T) {
 	assert.Equal(t, c.S3Bucket, "vault")
 	assert.Equal(t, c.S3Region, "us-east-1")
 	assert.Equal(t, c.S3BaseEndpoint, "http://127.0.0.1:9000/")
+	assert.Equal(t, c.BlobBackend, "s3")
+	assert.Equal(t, c.BlobDir, "blobs")
+	assert.Equal(t, c.EndpointAddrBlob, ":8081")
+	assert.Equal(t, c.BlobBaseURL, "http://127.0.0.1:8081/")
+	assert.Equal(t, c.BlobMaxPartSize, int64(256<<20))
+	assert.Equal(t, c.TombstoneRetention, 30*24*time.Hour)
+	assert.Equal(t, c.StaleUploadAge, 24*time.Hour)
+	assert.Equal(t, c.BlobGCGrace, 7*24*time.Hour)
+	assert.Empty(t, c.TLSCertFile)
+	assert.False(t, c.Insecure)
+	assert.Equal(t, c.KDFTime, 3)
+	assert.Equal(t, c.KDFMemory, 64*1024)
+	assert.Equal(t, c.KDFThreads, 4)
+	assert.Equal(t, c.AuthIPRate, 60)
+	assert.Equal(t, c.AuthUserRate, 10)
+	assert.Equal(t, c.AuthLockoutThreshold, 5)
+	assert.Equal(t, c.AuthLockoutBase, time.Minute)
+	assert.Equal(t, c.AuthLockoutMax, time.Hour)
 }
 
 func TestLoadConfig_UsesDefaultsBeforeParsing(t *testing.T) {
@@ -39,4 +58,35 @@ func TestLoadConfig_UsesDefaultsBeforeParsing(t *testing.T) {
 	assert.Equal(t, c.S3Bucket, "vault")
 	assert.Equal(t, c.S3Region, "us-east-1")
 	assert.Equal(t, c.S3BaseEndpoint, "http://127.0.0.1:9000/")
+	assert.Equal(t, c.BlobBackend, "s3")
+	assert.Equal(t, c.BlobDir, "blobs")
+	assert.Equal(t, c.EndpointAddrBlob, ":8081")
+	assert.Equal(t, c.BlobBaseURL, "http://127.0.0.1:8081/")
+	assert.Equal(t, c.BlobMaxPartSize, int64(256<<20))
+	assert.Equal(t, c.TombstoneRetention, 30*24*time.Hour)
+	assert.Equal(t, c.StaleUploadAge, 24*time.Hour)
+	assert.Equal(t, c.BlobGCGrace, 7*24*time.Hour)
+	assert.Empty(t, c.TLSCertFile)
+	assert.False(t, c.Insecure)
+	assert.Equal(t, c.KDFTime, 3)
+	assert.Equal(t, c.KDFMemory, 64*1024)
+	assert.Equal(t, c.KDFThreads, 4)
+	assert.Equal(t, c.AuthIPRate, 60)
+	assert.Equal(t, c.AuthUserRate, 10)
+	assert.Equal(t, c.AuthLockoutThreshold, 5)
+	assert.Equal(t, c.AuthLockoutBase, time.Minute)
+	assert.Equal(t, c.AuthLockoutMax, time.Hour)
+}
+
+func TestKDFParams(t *testing.T) {
+	var c Config
+	c.LoadDefaults()
+	assert.Equal(t, cryptox.DefaultKDFParams, c.KDFParams())
+	require.NoError(t, c.KDFParams().Validate())
+
+	c.KDFThreads = 256
+	assert.ErrorIs(t, c.KDFParams().Validate(), cryptox.ErrUnsupportedKDF)
+	c.KDFThreads = 4
+	c.KDFMemory = -1
+	assert.ErrorIs(t, c.KDFParams().Validate(), cryptox.ErrUnsupportedKDF)
 }
@@ -11,7 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-// args := flagx.FilterArgs(os.Args[1:], []string{"-a", "-d", "-s", "-t", "-r", "-u", "-p", "-b", "-g", "-e"})
+// args := flagx.FilterArgs(os.Args[1:], []string{"-a", "-d", "-s", "-t", "-r", "-u", "-p", "-b", "-g", "-e", "-blob-backend", "-blob-dir", "-blob-addr", "-blob-url", "-blob-max-part", "-k", "-stale-upload-age", "-blob-gc-grace", "-tls-cert", "-tls-key", "-tls-client-ca", "-insecure", "-kdf-time", "-kdf-memory", "-kdf-threads", "-auth-ip-rate", "-auth-user-rate", "-auth-lockout-after", "-auth-lockout", "-auth-lockout-max"})
 
 func TestParseFlags(t *testing.T) {
 
@@ -25,6 +25,11 @@ func TestParseFlags(t *testing.T) {
 		{name: "Test1 OK", args: []string{"cmd",
 			"-a", "127.0.0.1:9090", "-d", "db", "-s", "secret",
 			"-t", "1", "-r", "3", "-u", "user", "-p", "password", "-b", "bucket", "-g", "us-west-1", "-e", "http://endpoint",
+			"-blob-backend", "fs", "-blob-dir", "/srv/blobs", "-blob-addr", ":9001", "-blob-url", "https://blobs.example", "-blob-max-part", "64",
+			"-k", "1440", "-stale-upload-age", "120", "-blob-gc-grace", "60",
+			"-tls-cert", "/etc/keeper/cert.pem", "-tls-key", "/etc/keeper/key.pem", "-tls-client-ca", "/etc/keeper/clients.pem", "-insecure",
+			"-kdf-time", "4", "-kdf-memory", "131072", "-kdf-threads", "2",
+			"-auth-ip-rate", "120", "-auth-user-rate", "5", "-auth-lockout-after", "3", "-auth-lockout", "2", "-auth-lockout-max", "30",
 		}, expectPanic: false,
 			expected: &Config{
 				EndpointAddrGRPC:             "127.0.0.1:9090",
@@ -37,6 +42,26 @@ func TestParseFlags(t *testing.T) {
 				S3Bucket:                     "bucket",
 				S3Region:                     "us-west-1",
 				S3BaseEndpoint:               "http://endpoint",
+				BlobBackend:                  "fs",
+				BlobDir:                      "/srv/blobs",
+				EndpointAddrBlob:             ":9001",
+				BlobBaseURL:                  "https://blobs.example",
+				BlobMaxPartSize:              64 << 20,
+				TombstoneRetention:           24 * time.Hour,
+				StaleUploadAge:               2 * time.Hour,
+				BlobGCGrace:                  time.Hour,
+				TLSCertFile:                  "/etc/keeper/cert.pem",
+				TLSKeyFile:                   "/etc/keeper/key.pem",
+				TLSClientCAFile:              "/etc/keeper/clients.pem",
+				Insecure:                     true,
+				KDFTime:                      4,
+				KDFMemory:                    128 * 1024,
+				KDFThreads:                   2,
+				AuthIPRate:                   120,
+				AuthUserRate:                 5,
+				AuthLockoutThreshold:         3,
+				AuthLockoutBase:              2 * time.Minute,
+				AuthLockoutMax:               30 * time.Minute,
 			}},
 	}
 
@@ -22,15 +22,35 @@ import (
 //	-b string   S3 bucket name
 //	-g string   S3 region
 //	-e string   S3 base endpoint (e.g., "http://127.0.0.1:9000/")
+//	-blob-backend string  blob storage backend, "s3" or "fs"
+//	-blob-dir string      root directory of the "fs" backend
+//	-blob-addr string     bind address of the "fs" backend's HTTP endpoint
+//	-blob-url string      public URL of that endpoint
+//	-blob-max-part int    largest PUT body that endpoint accepts, MiB
+//	-k int      tombstone retention, minutes (0 disables purging)
+//	-stale-upload-age int  age of pending uploads reported as stale, minutes (0 disables)
+//	-blob-gc-grace int     grace period of blob garbage collection, minutes (0 disables)
+//	-tls-cert string       PEM certificate of the gRPC and blob endpoints
+//	-tls-key string        PEM private key of that certificate
+//	-tls-client-ca string  PEM CA bundle for client certificates (enables mTLS)
+//	-insecure              serve gRPC and blobs without TLS (development only)
+//	-kdf-time int     Argon2id passes for new accounts
+//	-kdf-memory int   Argon2id memory for new accounts, KiB
+//	-kdf-threads int  Argon2id parallelism for new accounts
+//	-auth-ip-rate int          authentication requests per minute per IP (0 disables)
+//	-auth-user-rate int        authentication requests per minute per username (0 disables)
+//	-auth-lockout-after int    failed logins before a lockout (0 disables)
+//	-auth-lockout int          first lockout, minutes
+//	-auth-lockout-max int      longest lockout, minutes
 //
 // Notes:
 //   - The function first filters os.Args to only the flags it recognizes using
 //     flagx.FilterArgs, avoiding collisions with other components.
 //   - Duration flags are accepted as integers in minutes and then converted
-//     to time.Duration values.
+//     to time.Duration values; sizes are accepted in MiB.
 func parseFlags(config *Config) {
 	// Filter args to include only the flags handled here.
-	args := flagx.FilterArgs(os.Args[1:], []string{"-a", "-d", "-s", "-t", "-r", "-u", "-p", "-b", "-g", "-e"})
+	args := flagx.FilterArgs(os.Args[1:], []string{"-a", "-d", "-s", "-t", "-r", "-u", "-p", "-b", "-g", "-e", "-blob-backend", "-blob-dir", "-blob-addr", "-blob-url", "-blob-max-part", "-k", "-stale-upload-age", "-blob-gc-grace", "-tls-cert", "-tls-key", "-tls-client-ca", "-insecure", "-kdf-time", "-kdf-memory", "-kdf-threads", "-auth-ip-rate", "-auth-user-rate", "-auth-lockout-after", "-auth-lockout", "-auth-lockout-max"})
 
 	fs := flag.NewFlagSet("main", flag.ContinueOnError)
 
@@ -47,10 +67,41 @@ func parseFlags(config *Config) {
 	fs.StringVar(&config.S3Region, "g", config.S3Region, "S3 root region")
 	fs.StringVar(&config.S3BaseEndpoint, "e", config.S3BaseEndpoint, "S3 base endpoint")
 
+	fs.StringVar(&config.BlobBackend, "blob-backend", config.BlobBackend, "blob storage backend (s3 or fs)")
+	fs.StringVar(&config.BlobDir, "blob-dir", config.BlobDir, "root directory of the fs blob backend")
+	fs.StringVar(&config.EndpointAddrBlob, "blob-addr", config.EndpointAddrBlob, "address and port of the fs blob backend's HTTP endpoint")
+	fs.StringVar(&config.BlobBaseURL, "blob-url", config.BlobBaseURL, "public URL of the fs blob backend's HTTP endpoint")
+	blobMaxPart := fs.Int64("blob-max-part", config.BlobMaxPartSize>>20, "largest object or part the fs blob backend's HTTP endpoint accepts with one PUT (in MiB)")
+
+	tombstoneRetention := fs.Int("k", int(config.TombstoneRetention.Minutes()), "tombstone_retention (in minutes, 0 disables purging)")
+	staleUploadAge := fs.Int("stale-upload-age", int(config.StaleUploadAge.Minutes()), "age of pending uploads reported as stale (in minutes, 0 disables the report)")
+	blobGCGrace := fs.Int("blob-gc-grace", int(config.BlobGCGrace.Minutes()), "grace period of blob garbage collection (in minutes, 0 disables it)")
+
+	fs.StringVar(&config.TLSCertFile, "tls-cert", config.TLSCertFile, "PEM certificate of the gRPC and blob endpoints")
+	fs.StringVar(&config.TLSKeyFile, "tls-key", config.TLSKeyFile, "PEM private key of the gRPC endpoint's certificate")
+	fs.StringVar(&config.TLSClientCAFile, "tls-client-ca", config.TLSClientCAFile, "PEM CA bundle that client certificates must chain to (enables mutual TLS)")
+	fs.BoolVar(&config.Insecure, "insecure", config.Insecure, "serve gRPC and blobs without TLS (development only)")
+
+	fs.IntVar(&config.KDFTime, "kdf-time", config.KDFTime, "Argon2id time cost of new accounts")
+	fs.IntVar(&config.KDFMemory, "kdf-memory", config.KDFMemory, "Argon2id memory cost of new accounts (in KiB)")
+	fs.IntVar(&config.KDFThreads, "kdf-threads", config.KDFThreads, "Argon2id parallelism of new accounts")
+
+	fs.IntVar(&config.AuthIPRate, "auth-ip-rate", config.AuthIPRate, "authentication requests per minute per client IP (0 disables the limit)")
+	fs.IntVar(&config.AuthUserRate, "auth-user-rate", config.AuthUserRate, "authentication requests per minute per username (0 disables the limit)")
+	fs.IntVar(&config.AuthLockoutThreshold, "auth-lockout-after", config.AuthLockoutThreshold, "failed logins in a row before a lockout (0 disables lockouts)")
+	authLockoutBase := fs.Int("auth-lockout", int(config.AuthLockoutBase.Minutes()), "first lockout after failed logins (in minutes), doubled with every further failure")
+	authLockoutMax := fs.Int("auth-lockout-max", int(config.AuthLockoutMax.Minutes()), "longest lockout after failed logins (in minutes)")
+
 	if err := fs.Parse(args); err != nil {
 		panic(err)
 	}
 
 	config.AccessTokenValidityDuration = time.Duration(*accessTokenValidityDuration) * time.Minute
 	config.RefreshTokenValidityDuration = time.Duration(*refreshTokenValidityDuration) * time.Minute
+	config.TombstoneRetention = time.Duration(*tombstoneRetention) * time.Minute
+	config.StaleUploadAge = time.Duration(*staleUploadAge) * time.Minute
+	config.BlobGCGrace = time.Duration(*blobGCGrace) * time.Minute
+	config.BlobMaxPartSize = *blobMaxPart << 20
+	config.AuthLockoutBase = time.Duration(*authLockoutBase) * time.Minute
+	config.AuthLockoutMax = time.Duration(*authLockoutMax) * time.Minute
 }
@@ -27,6 +27,26 @@ type JsonConfig struct {
 	S3Bucket                     string         `json:"s3_bucket"`
 	S3Region                     string         `json:"s3_region"`
 	S3BaseEndpoint               string         `json:"s3_base_endpoint"`
+	BlobBackend                  string         `json:"blob_backend"`
+	BlobDir                      string         `json:"blob_dir"`
+	EndpointAddrBlob             string         `json:"endpoint_addr_blob"`
+	BlobBaseURL                  string         `json:"blob_base_url"`
+	BlobMaxPartMiB               int64          `json:"blob_max_part_mib"`
+	TombstoneRetention           timex.Duration `json:"tombstone_retention"`
+	StaleUploadAge               timex.Duration `json:"stale_upload_age"`
+	BlobGCGrace                  timex.Duration `json:"blob_gc_grace"`
+	TLSCertFile                  string         `json:"tls_cert_file"`
+	TLSKeyFile                   string         `json:"tls_key_file"`
+	TLSClientCAFile              string         `json:"tls_client_ca_file"`
+	Insecure                     bool           `json:"insecure"`
+	KDFTime                      int            `json:"kdf_time"`
+	KDFMemory                    int            `json:"kdf_memory"`
+	KDFThreads                   int            `json:"kdf_threads"`
+	AuthIPRate                   int            `json:"auth_ip_rate"`
+	AuthUserRate                 int            `json:"auth_user_rate"`
+	AuthLockoutThreshold         int            `json:"auth_lockout_threshold"`
+	AuthLockoutBase              timex.Duration `json:"auth_lockout_base"`
+	AuthLockoutMax               timex.Duration `json:"auth_lockout_max"`
 }
 
 // parseJson loads configuration values from a JSON file into the provided
@@ -78,4 +98,67 @@ func parseJson(config *Config) {
 	config.S3Bucket = c.S3Bucket
 	config.S3Region = c.S3Region
 	config.S3BaseEndpoint = c.S3BaseEndpoint
+	config.TombstoneRetention = time.Duration(c.TombstoneRetention.Duration)
+	// the blob backend settings are optional too; S3 stays the default
+	if c.BlobBackend != "" {
+		config.BlobBackend = c.BlobBackend
+	}
+	if c.BlobDir != "" {
+		config.BlobDir = c.BlobDir
+	}
+	if c.EndpointAddrBlob != "" {
+		config.EndpointAddrBlob = c.EndpointAddrBlob
+	}
+	if c.BlobBaseURL != "" {
+		config.BlobBaseURL = c.BlobBaseURL
+	}
+	if c.BlobMaxPartMiB != 0 {
+		config.BlobMaxPartSize = c.BlobMaxPartMiB << 20
+	}
+	// optional, like the KDF costs below
+	if c.StaleUploadAge.Duration != 0 {
+		config.StaleUploadAge = time.Duration(c.StaleUploadAge.Duration)
+	}
+	if c.BlobGCGrace.Duration != 0 {
+		config.BlobGCGrace = time.Duration(c.BlobGCGrace.Duration)
+	}
+	if c.TLSCertFile != "" {
+		config.TLSCertFile = c.TLSCertFile
+	}
+	if c.TLSKeyFile != "" {
+		config.TLSKeyFile = c.TLSKeyFile
+	}
+	if c.TLSClientCAFile != "" {
+		config.TLSClientCAFile = c.TLSClientCAFile
+	}
+	if c.Insecure {
+		config.Insecure = true
+	}
+	// KDF costs are optional, so that config files written before they
+	// existed keep the defaults.
+	if c.KDFTime != 0 {
+		config.KDFTime = c.KDFTime
+	}
+	if c.KDFMemory != 0 {
+		config.KDFMemory = c.KDFMemory
+	}
+	if c.KDFThreads != 0 {
+		config.KDFThreads = c.KDFThreads
+	}
+	// so are the rate limits; they stay on unless configured otherwise
+	if c.AuthIPRate != 0 {
+		config.AuthIPRate = c.AuthIPRate
+	}
+	if c.AuthUserRate != 0 {
+		config.AuthUserRate = c.AuthUserRate
+	}
+	if c.AuthLockoutThreshold != 0 {
+		config.AuthLockoutThreshold = c.AuthLockoutThreshold
+	}
+	if c.AuthLockoutBase.Duration != 0 {
+		config.AuthLockoutBase = time.Duration(c.AuthLockoutBase.Duration)
+	}
+	if c.AuthLockoutMax.Duration != 0 {
+		config.AuthLockoutMax = time.Duration(c.AuthLockoutMax.Duration)
+	}
 }
@@ -42,6 +42,26 @@ func Test_parseJson_SourcesAndPrecedence(t *testing.T) {
 		"s3_bucket":                       "bucket",
 		"s3_region":                       "region",
 		"s3_base_endpoint":                "base_endpoint",
+		"blob_backend":                    "fs",
+		"blob_dir":                        "/var/lib/blobs",
+		"endpoint_addr_blob":              ":9001",
+		"blob_base_url":                   "https://blobs.example",
+		"blob_max_part_mib":               32,
+		"tombstone_retention":             "720h",
+		"stale_upload_age":                "6h",
+		"blob_gc_grace":                   "48h",
+		"tls_cert_file":                   "cert.pem",
+		"tls_key_file":                    "key.pem",
+		"tls_client_ca_file":              "clients.pem",
+		"insecure":                        true,
+		"kdf_time":                        2,
+		"kdf_memory":                      262144,
+		"kdf_threads":                     1,
+		"auth_ip_rate":                    100,
+		"auth_user_rate":                  3,
+		"auth_lockout_threshold":          4,
+		"auth_lockout_base":               "30s",
+		"auth_lockout_max":                "2h",
 	})
 
 	t.Run("loads from json", func(t *testing.T) {
@@ -60,6 +80,48 @@ func Test_parseJson_SourcesAndPrecedence(t *testing.T) {
 		assert.Equal(t, "bucket", cfg.S3Bucket)
 		assert.Equal(t, "region", cfg.S3Region)
 		assert.Equal(t, "base_endpoint", cfg.S3BaseEndpoint)
+		assert.Equal(t, "fs", cfg.BlobBackend)
+		assert.Equal(t, "/var/lib/blobs", cfg.BlobDir)
+		assert.Equal(t, ":9001", cfg.EndpointAddrBlob)
+		assert.Equal(t, "https://blobs.example", cfg.BlobBaseURL)
+		assert.Equal(t, int64(32<<20), cfg.BlobMaxPartSize)
+		assert.Equal(t, 720*time.Hour, cfg.TombstoneRetention)
+		assert.Equal(t, 6*time.Hour, cfg.StaleUploadAge)
+		assert.Equal(t, 48*time.Hour, cfg.BlobGCGrace)
+		assert.Equal(t, "cert.pem", cfg.TLSCertFile)
+		assert.Equal(t, "key.pem", cfg.TLSKeyFile)
+		assert.Equal(t, "clients.pem", cfg.TLSClientCAFile)
+		assert.True(t, cfg.Insecure)
+		assert.Equal(t, 2, cfg.KDFTime)
+		assert.Equal(t, 256*1024, cfg.KDFMemory)
+		assert.Equal(t, 1, cfg.KDFThreads)
+		assert.Equal(t, 100, cfg.AuthIPRate)
+		assert.Equal(t, 3, cfg.AuthUserRate)
+		assert.Equal(t, 4, cfg.AuthLockoutThreshold)
+		assert.Equal(t, 30*time.Second, cfg.AuthLockoutBase)
+		assert.Equal(t, 2*time.Hour, cfg.AuthLockoutMax)
+	})
+
+	t.Run("kdf costs are optional", func(t *testing.T) {
+		path := writeTempJSON(t, dir, "nokdf.json", map[string]any{"endpoint_addr_grpc": ":1"})
+		os.Args = []string{"testbin", "-config", path}
+
+		cfg := &Config{}
+		cfg.LoadDefaults()
+		parseJson(cfg)
+
+		assert.Equal(t, 3, cfg.KDFTime)
+		assert.Equal(t, 64*1024, cfg.KDFMemory)
+		assert.Equal(t, 4, cfg.KDFThreads)
+		assert.Equal(t, 24*time.Hour, cfg.StaleUploadAge)
+		assert.Equal(t, 7*24*time.Hour, cfg.BlobGCGrace)
+		assert.False(t, cfg.Insecure)
+		assert.Equal(t, "s3", cfg.BlobBackend)
+		assert.Equal(t, "blobs", cfg.BlobDir)
+		assert.Equal(t, int64(256<<20), cfg.BlobMaxPartSize)
+		assert.Equal(t, 60, cfg.AuthIPRate)
+		assert.Equal(t, 5, cfg.AuthLockoutThreshold)
+		assert.Equal(t, time.Hour, cfg.AuthLockoutMax)
 	})
 
 	t.Run("no CONFIG and no flags → no changes", func(t *testing.T) {
@@ -6,11 +6,15 @@ package grpc
 import (
 	"context"
 	"errors"
+	"net"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
 	pb "github.com/dmitrijs2005/gophkeeper/internal/proto"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/services"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
@@ -19,11 +23,17 @@ func (s *GRPCServer) Ping(ctx context.Context, req *pb.PingRequest) (*pb.PingRes
 	return &pb.PingResponse{Status: "OK"}, nil
 }
 
-// RefreshToken exchanges a valid refresh token for a new (access, refresh) pair.
-// Returns codes.Internal on service errors.
+// RefreshToken exchanges a valid refresh token for a new (access, refresh)
+// pair of the same session. Returns codes.Internal on service errors.
 func (s *GRPCServer) RefreshToken(ctx context.Context, req *pb.RefreshTokenRequest) (*pb.RefreshTokenResponse, error) {
-	tokenPair, err := s.users.RefreshToken(ctx, req.RefreshToken)
+	tokenPair, err := s.users.RefreshToken(ctx, req.RefreshToken, peerIP(ctx))
 	if err != nil {
+		var reuse *services.TokenReuseError
+		if errors.As(err, &reuse) {
+			s.logger.Warn(ctx, "Security event: refresh token reused, session revoked",
+				"user_id", reuse.UserID, "session_id", reuse.SessionID, "ip", peerIP(ctx), "error", reuse.Err)
+			return nil, status.Error(codes.Unauthenticated, common.ErrRefreshTokenReused.Error())
+		}
 		s.logger.Error(ctx, err.Error())
 		return nil, status.Error(codes.Internal, err.Error())
 	}
@@ -31,49 +41,374 @@ func (s *GRPCServer) RefreshToken(ctx context.Context, req *pb.RefreshTokenReque
 	return &pb.RefreshTokenResponse{AccessToken: tokenPair.AccessToken, RefreshToken: tokenPair.RefreshToken}, nil
 }
 
-// RegisterUser creates a new user with the provided username, salt, and verifier.
-// Returns codes.Internal on service errors.
+// RegisterUser creates a new user with the provided username, salt, verifier
+// and wrapped vault key. Returns codes.Internal on service errors.
 func (s *GRPCServer) RegisterUser(ctx context.Context, req *pb.RegisterUserRequest) (*pb.RegisterUserResponse, error) {
-	result, err := s.users.Register(ctx, req.Username, req.Salt, req.Verifier)
+	result, err := s.users.Register(ctx, req.Username, models.Credentials{
+		Salt:            req.Salt,
+		KDF:             kdfFromPB(req.Kdf),
+		SRPVerifier:     req.Verifier,
+		WrappedVaultKey: req.WrappedVaultKey,
+	})
 	if err != nil {
 		s.logger.Error(ctx, err.Error())
+		if errors.Is(err, common.ErrorForbidden) {
+			return nil, status.Error(codes.InvalidArgument, "kdf parameters below policy")
+		}
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 	s.logger.Info(ctx, "Registered", "username", req.Username)
 	return &pb.RegisterUserResponse{Username: "registered id=" + result.ID}, nil
 }
 
-// GetSalt fetches the server-stored salt for the given username.
-// Returns codes.Internal on service errors.
+// GetSalt fetches the server-stored salt for the given username and whether
+// the account predates the key hierarchy. Returns codes.Internal on service
+// errors.
 func (s *GRPCServer) GetSalt(ctx context.Context, req *pb.GetSaltRequest) (*pb.GetSaltResponse, error) {
 	result, err := s.users.GetSalt(ctx, req.Username)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 	s.logger.Info(ctx, "Salt returned", "username", req.Username)
-	return &pb.GetSaltResponse{Salt: result}, nil
+	resp := &pb.GetSaltResponse{Salt: result.Salt, LegacyKeys: result.LegacyKeys, Kdf: kdfToPB(result.KDF)}
+	if result.KDFUpgrade != nil {
+		resp.KdfUpgrade = kdfToPB(*result.KDFUpgrade)
+	}
+	return resp, nil
 }
 
-// Login validates the verifier candidate and returns new access/refresh tokens.
+// Login validates the legacy verifier candidate of an account that has not
+// been upgraded to SRP, upgrades it, and returns new access/refresh tokens.
 // Returns codes.Unauthenticated for invalid credentials, codes.Internal otherwise.
 func (s *GRPCServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
-	tokens, err := s.users.Login(ctx, req.Username, req.VerifierCandidate)
+	tokens, err := s.users.Login(ctx, req.Username, req.VerifierCandidate, req.SrpVerifier, req.WrappedVaultKey, deviceFromPB(ctx, req.Device))
 	if err != nil {
-		if errors.Is(err, common.ErrorUnauthorized) {
-			return nil, status.Error(codes.Unauthenticated, "unauthorized")
+		return nil, authError(err)
+	}
+	s.logger.Info(ctx, "Logged in, upgraded to SRP", "username", req.Username)
+	return &pb.LoginResponse{AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, nil
+}
+
+// LoginStart begins an SRP login and returns the server's public value and
+// the session to finish it with. Returns codes.Unauthenticated for a
+// malformed client public value, codes.Internal on other service errors.
+func (s *GRPCServer) LoginStart(ctx context.Context, req *pb.LoginStartRequest) (*pb.LoginStartResponse, error) {
+	c, err := s.users.LoginStart(ctx, req.Username, req.ClientPublic)
+	if err != nil {
+		return nil, authError(err)
+	}
+	return &pb.LoginStartResponse{SessionId: c.SessionID, ServerPublic: c.ServerPublic}, nil
+}
+
+// LoginFinish checks the client's SRP proof and returns the server proof
+// and the wrapped vault key along with new access/refresh tokens, or, for
+// accounts with two-factor authentication, a challenge for Verify2FA
+// instead. Returns codes.Unauthenticated for invalid proofs or sessions,
+// codes.Internal otherwise.
+func (s *GRPCServer) LoginFinish(ctx context.Context, req *pb.LoginFinishRequest) (*pb.LoginFinishResponse, error) {
+	res, err := s.users.LoginFinish(ctx, req.SessionId, req.ClientProof, deviceFromPB(ctx, req.Device))
+	if err != nil {
+		var loginErr *services.LoginError
+		if errors.As(err, &loginErr) {
+			setLoginSubject(ctx, loginErr.UserName)
 		}
+		return nil, authError(err)
+	}
+	setLoginSubject(ctx, res.UserName)
+	if res.TwoFactorChallenge != "" {
+		s.logger.Info(ctx, "Password verified, awaiting second factor")
+		return &pb.LoginFinishResponse{ServerProof: res.ServerProof, TwoFactorChallenge: res.TwoFactorChallenge}, nil
+	}
+	s.logger.Info(ctx, "Logged in")
+	return &pb.LoginFinishResponse{
+		ServerProof:     res.ServerProof,
+		AccessToken:     res.Tokens.AccessToken,
+		RefreshToken:    res.Tokens.RefreshToken,
+		WrappedVaultKey: res.WrappedVaultKey,
+	}, nil
+}
+
+// Verify2FA completes a login started with LoginFinish with a TOTP or
+// recovery code and returns new access/refresh tokens and the wrapped vault
+// key. Returns codes.Unauthenticated for wrong codes and unknown, expired or
+// exhausted challenges, codes.Internal otherwise.
+func (s *GRPCServer) Verify2FA(ctx context.Context, req *pb.Verify2FARequest) (*pb.Verify2FAResponse, error) {
+	res, err := s.users.Verify2FA(ctx, req.ChallengeId, req.Code, deviceFromPB(ctx, req.Device))
+	if err != nil {
+		var loginErr *services.LoginError
+		if errors.As(err, &loginErr) {
+			setLoginSubject(ctx, loginErr.UserName)
+		}
+		return nil, authError(err)
+	}
+	setLoginSubject(ctx, res.UserName)
+	s.logger.Info(ctx, "Logged in with second factor")
+	return &pb.Verify2FAResponse{
+		AccessToken:     res.Tokens.AccessToken,
+		RefreshToken:    res.Tokens.RefreshToken,
+		WrappedVaultKey: res.WrappedVaultKey,
+	}, nil
+}
+
+// UpgradeKeys moves the caller's account to the key hierarchy. Returns
+// codes.PermissionDenied if the account already has a vault key and
+// codes.Internal on other errors.
+func (s *GRPCServer) UpgradeKeys(ctx context.Context, req *pb.UpgradeKeysRequest) (*pb.UpgradeKeysResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
 		return nil, status.Error(codes.Internal, "internal error")
 	}
-	s.logger.Info(ctx, "Logged in", "username", req.Username)
-	return &pb.LoginResponse{AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, nil
+
+	if err := s.users.UpgradeKeys(ctx, userID, req.SrpVerifier, req.WrappedVaultKey); err != nil {
+		s.logger.Error(ctx, err.Error())
+		if errors.Is(err, common.ErrorForbidden) {
+			return nil, status.Error(codes.PermissionDenied, "permission denied")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	s.logger.Info(ctx, "Upgraded account keys")
+	return &pb.UpgradeKeysResponse{}, nil
+}
+
+// ChangePassword replaces the caller's credentials after checking the SRP
+// proof of the current password and returns the server proof with new
+// access/refresh tokens. With rekey set, the entries and file keys of the
+// request replace the account's content in the same transaction. Returns
+// codes.Unauthenticated for invalid proofs or sessions, codes.PermissionDenied
+// for accounts that cannot change their password yet, codes.Aborted if the
+// re-encrypted content is not the account's current one, and codes.Internal
+// otherwise.
+func (s *GRPCServer) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*pb.ChangePasswordResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	var rekey *models.VaultRekey
+	if req.Rekey {
+		rekey = &models.VaultRekey{}
+		for _, e := range req.Entries {
+			rekey.Entries = append(rekey.Entries, &models.Entry{
+				ID:            e.Id,
+				Deleted:       e.Deleted,
+				Overview:      e.Overview,
+				NonceOverview: e.NonceOverview,
+				Details:       e.Details,
+				NonceDetails:  e.NonceDetails,
+				BaseVersion:   e.BaseVersion,
+			})
+		}
+		for _, f := range req.Files {
+			rekey.Files = append(rekey.Files, &models.File{
+				EntryID:          f.EntryId,
+				EncryptedFileKey: f.FileKey,
+				Nonce:            f.Nonce,
+			})
+		}
+	}
+
+	res, err := s.users.ChangePassword(ctx, userID, req.SessionId, req.ClientProof, models.Credentials{
+		Salt:            req.Salt,
+		KDF:             kdfFromPB(req.Kdf),
+		SRPVerifier:     req.SrpVerifier,
+		WrappedVaultKey: req.WrappedVaultKey,
+	}, rekey, deviceFromPB(ctx, req.Device))
+	if err != nil {
+		s.logger.Error(ctx, err.Error())
+		switch {
+		case errors.Is(err, common.ErrorForbidden):
+			return nil, status.Error(codes.PermissionDenied, "permission denied")
+		case errors.Is(err, common.ErrVersionConflict):
+			return nil, status.Error(codes.Aborted, "vault changed, retry")
+		}
+		return nil, authError(err)
+	}
+	if rekey != nil {
+		s.logger.Info(ctx, "Password and vault key changed, sessions revoked")
+	} else {
+		s.logger.Info(ctx, "Password changed, sessions revoked")
+	}
+	return &pb.ChangePasswordResponse{
+		ServerProof:  res.ServerProof,
+		AccessToken:  res.Tokens.AccessToken,
+		RefreshToken: res.Tokens.RefreshToken,
+		Versions:     res.Versions,
+	}, nil
+}
+
+// UpgradeKDF re-keys the caller's account with KDF parameters that meet the
+// server's policy. The current password is proven with an SRP session like
+// in ChangePassword; refresh tokens stay valid.
+func (s *GRPCServer) UpgradeKDF(ctx context.Context, req *pb.UpgradeKDFRequest) (*pb.UpgradeKDFResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	proof, err := s.users.UpgradeKDF(ctx, userID, req.SessionId, req.ClientProof, models.Credentials{
+		Salt:            req.Salt,
+		KDF:             kdfFromPB(req.Kdf),
+		SRPVerifier:     req.SrpVerifier,
+		WrappedVaultKey: req.WrappedVaultKey,
+	})
+	if err != nil {
+		s.logger.Error(ctx, err.Error())
+		if errors.Is(err, common.ErrorForbidden) {
+			return nil, status.Error(codes.PermissionDenied, "permission denied")
+		}
+		return nil, authError(err)
+	}
+	s.logger.Info(ctx, "KDF parameters upgraded")
+	return &pb.UpgradeKDFResponse{ServerProof: proof}, nil
+}
+
+// ListSessions returns the sessions of the caller's account, most recently
+// used first, with the caller's own marked as current. Returns codes.Internal
+// on service errors.
+func (s *GRPCServer) ListSessions(ctx context.Context, req *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	sessionID, _ := sessionIDFromContext(ctx)
+
+	sessions, err := s.users.ListSessions(ctx, userID, sessionID)
+	if err != nil {
+		s.logger.Error(ctx, err.Error())
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	var out []*pb.Session
+	for _, session := range sessions {
+		out = append(out, sessionToPB(session))
+	}
+	return &pb.ListSessionsResponse{Sessions: out}, nil
+}
+
+// RevokeSession logs one of the caller's devices out; its access tokens are
+// rejected from then on. Returns codes.NotFound if the caller has no such
+// session and codes.Internal on other errors.
+func (s *GRPCServer) RevokeSession(ctx context.Context, req *pb.RevokeSessionRequest) (*pb.RevokeSessionResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	if err := s.users.RevokeSession(ctx, userID, req.SessionId); err != nil {
+		s.logger.Error(ctx, err.Error(), "session_id", req.SessionId)
+		if errors.Is(err, common.ErrorNotFound) {
+			return nil, status.Error(codes.NotFound, "not found")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	s.logger.Info(ctx, "Session revoked", "session_id", req.SessionId)
+	return &pb.RevokeSessionResponse{}, nil
+}
+
+// RevokeAllOtherSessions logs every device of the caller's account out
+// except the calling one and returns how many sessions were revoked.
+// Returns codes.Internal on service errors.
+func (s *GRPCServer) RevokeAllOtherSessions(ctx context.Context, req *pb.RevokeAllOtherSessionsRequest) (*pb.RevokeAllOtherSessionsResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	sessionID, ok := sessionIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	n, err := s.users.RevokeOtherSessions(ctx, userID, sessionID)
+	if err != nil {
+		s.logger.Error(ctx, err.Error())
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	s.logger.Info(ctx, "Other sessions revoked", "count", n)
+	return &pb.RevokeAllOtherSessionsResponse{Revoked: n}, nil
+}
+
+// Logout ends the caller's session: its refresh token is deleted and the
+// access token the call was made with is rejected from then on. Returns
+// codes.Internal on service errors.
+func (s *GRPCServer) Logout(ctx context.Context, req *pb.LogoutRequest) (*pb.LogoutResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	sessionID, ok := sessionIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	if err := s.users.Logout(ctx, userID, sessionID); err != nil {
+		s.logger.Error(ctx, err.Error())
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	s.logger.Info(ctx, "Logged out", "session_id", sessionID)
+	return &pb.LogoutResponse{}, nil
+}
+
+// Enable2FA starts a TOTP enrollment of the caller's account and returns the
+// secret and its otpauth URI. An account with two-factor authentication has
+// to send a current code of it: codes.FailedPrecondition is returned without
+// one and codes.Unauthenticated for a wrong one, which counts towards the
+// lockout of the account. Returns codes.Internal on other service errors.
+func (s *GRPCServer) Enable2FA(ctx context.Context, req *pb.Enable2FARequest) (*pb.Enable2FAResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	setup, err := s.users.Enable2FA(ctx, userID, req.Code)
+	if err != nil {
+		s.logger.Error(ctx, err.Error())
+		var loginErr *services.LoginError
+		switch {
+		case errors.Is(err, common.ErrSecondFactorRequired):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		case errors.As(err, &loginErr):
+			setLoginSubject(ctx, loginErr.UserName)
+			return nil, status.Error(codes.Unauthenticated, common.ErrInvalidCode.Error())
+		}
+		return nil, authError(err)
+	}
+	s.logger.Info(ctx, "Two-factor enrollment started")
+	return &pb.Enable2FAResponse{Secret: setup.Secret, OtpauthUri: setup.URI}, nil
+}
+
+// Confirm2FA completes the TOTP enrollment of the caller's account with a
+// code of the new secret and returns the recovery codes. Returns
+// codes.InvalidArgument for a wrong code, codes.PermissionDenied if there is
+// no enrollment to confirm and codes.Internal otherwise.
+func (s *GRPCServer) Confirm2FA(ctx context.Context, req *pb.Confirm2FARequest) (*pb.Confirm2FAResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	recovery, err := s.users.Confirm2FA(ctx, userID, req.Code)
+	if err != nil {
+		s.logger.Error(ctx, err.Error())
+		switch {
+		case errors.Is(err, common.ErrorUnauthorized):
+			return nil, status.Error(codes.InvalidArgument, "invalid code")
+		case errors.Is(err, common.ErrorForbidden):
+			return nil, status.Error(codes.PermissionDenied, "permission denied")
+		default:
+			return nil, status.Error(codes.Internal, "internal error")
+		}
+	}
+	s.logger.Info(ctx, "Two-factor authentication enabled")
+	return &pb.Confirm2FAResponse{RecoveryCodes: recovery}, nil
 }
 
 // Sync reconciles client-submitted pending entries/files with the server state,
-// returns merged updates, server-side new items, upload tasks, and the new
-// global max version. Authentication is inferred from context.
+// returns merged updates, conflicting server copies, server-side new items,
+// upload tasks, and the new global max version. Authentication is inferred
+// from context. A client too far behind to sync incrementally gets
+// FailedPrecondition and must sync again from version 0.
 func (s *GRPCServer) Sync(ctx context.Context, req *pb.SyncRequest) (*pb.SyncResponse, error) {
-	v := ctx.Value(UserIDKey)
-	userID, ok := v.(string)
+	userID, ok := userIDFromContext(ctx)
 	if !ok {
 		return nil, status.Error(codes.Internal, "internal error")
 	}
@@ -88,6 +423,7 @@ func (s *GRPCServer) Sync(ctx context.Context, req *pb.SyncRequest) (*pb.SyncRes
 			NonceOverview: e.NonceOverview,
 			Details:       e.Details,
 			NonceDetails:  e.NonceDetails,
+			BaseVersion:   e.BaseVersion,
 		})
 	}
 
@@ -98,19 +434,23 @@ func (s *GRPCServer) Sync(ctx context.Context, req *pb.SyncRequest) (*pb.SyncRes
 			EntryID:          f.EntryId,
 			EncryptedFileKey: f.FileKey,
 			Nonce:            f.Nonce,
+			Digest:           f.Digest,
 		})
 	}
 
-	processedEntries, newEntries, newFiles, uploadTasks, maxVersion, err := s.entries.Sync(ctx, userID, pendingEntries, pendingFiles, req.MaxVersion)
+	processedEntries, conflicts, newEntries, newFiles, uploadTasks, maxVersion, err := s.entries.Sync(ctx, userID, pendingEntries, pendingFiles, req.MaxVersion)
 	if err != nil {
 		s.logger.Error(ctx, err.Error())
-		if errors.Is(err, common.ErrorUnauthorized) {
+		switch {
+		case errors.Is(err, common.ErrorUnauthorized):
 			return nil, status.Error(codes.Unauthenticated, "unauthorized")
+		case errors.Is(err, common.ErrResyncRequired):
+			return nil, status.Error(codes.FailedPrecondition, "full resync required")
 		}
 		return nil, status.Error(codes.Internal, "internal error")
 	}
 
-	var pe, ne []*pb.Entry
+	var pe, ce, ne []*pb.Entry
 	for _, e := range processedEntries {
 		pe = append(pe, &pb.Entry{
 			Id:            e.ID,
@@ -122,6 +462,17 @@ func (s *GRPCServer) Sync(ctx context.Context, req *pb.SyncRequest) (*pb.SyncRes
 			Deleted:       e.Deleted,
 		})
 	}
+	for _, e := range conflicts {
+		ce = append(ce, &pb.Entry{
+			Id:            e.ID,
+			Version:       e.Version,
+			Overview:      e.Overview,
+			NonceOverview: e.NonceOverview,
+			Details:       e.Details,
+			NonceDetails:  e.NonceDetails,
+			Deleted:       e.Deleted,
+		})
+	}
 	for _, e := range newEntries {
 		ne = append(ne, &pb.Entry{
 			Id:            e.ID,
@@ -140,6 +491,7 @@ func (s *GRPCServer) Sync(ctx context.Context, req *pb.SyncRequest) (*pb.SyncRes
 			EntryId: f.EntryID,
 			FileKey: f.EncryptedFileKey,
 			Nonce:   f.Nonce,
+			Digest:  f.Digest,
 		})
 	}
 
@@ -156,27 +508,318 @@ func (s *GRPCServer) Sync(ctx context.Context, req *pb.SyncRequest) (*pb.SyncRes
 		NewEntries:       ne,
 		NewFiles:         nf,
 		UploadTasks:      ut,
+		Conflicts:        ce,
 		GlobalMaxVersion: maxVersion,
 	}, nil
 }
 
 // MarkUploaded acknowledges that the client finished uploading a file
-// for the given entry. Returns codes.Internal on errors.
+// for the given entry. Returns codes.NotFound for unknown entries,
+// codes.PermissionDenied when the caller does not own the entry, and
+// codes.Internal on other errors.
 func (s *GRPCServer) MarkUploaded(ctx context.Context, req *pb.MarkUploadedRequest) (*pb.MarkUploadedResponse, error) {
-	entryID := req.EntryId
-	if err := s.entries.MarkUploaded(ctx, entryID); err != nil {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
 		return nil, status.Error(codes.Internal, "internal error")
 	}
+
+	if err := s.entries.MarkUploaded(ctx, userID, req.EntryId); err != nil {
+		s.logger.Error(ctx, err.Error(), "entry_id", req.EntryId)
+		return nil, fileAccessError(err)
+	}
 	return &pb.MarkUploadedResponse{}, nil
 }
 
 // GetPresignedGetUrl returns a presigned GET URL for downloading the encrypted
-// file associated with the given entry. Returns codes.Internal on errors.
+// file associated with the given entry. Returns codes.NotFound for unknown
+// entries, codes.PermissionDenied when the caller does not own the entry, and
+// codes.Internal on other errors.
 func (s *GRPCServer) GetPresignedGetUrl(ctx context.Context, req *pb.GetPresignedGetUrlRequest) (*pb.GetPresignedGetUrlResponse, error) {
-	entryID := req.EntryId
-	url, err := s.entries.GetPresignedGetURL(ctx, entryID)
-	if err != nil {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
 		return nil, status.Error(codes.Internal, "internal error")
 	}
+
+	url, err := s.entries.GetPresignedGetURL(ctx, userID, req.EntryId)
+	if err != nil {
+		s.logger.Error(ctx, err.Error(), "entry_id", req.EntryId)
+		return nil, fileAccessError(err)
+	}
 	return &pb.GetPresignedGetUrlResponse{Url: url}, nil
 }
+
+// UpdateFileKey replaces the wrapped key of the file of the given entry.
+// Returns codes.NotFound for unknown entries, codes.PermissionDenied when the
+// caller does not own the entry, and codes.Internal on other errors.
+func (s *GRPCServer) UpdateFileKey(ctx context.Context, req *pb.UpdateFileKeyRequest) (*pb.UpdateFileKeyResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	if err := s.entries.UpdateFileKey(ctx, userID, req.EntryId, req.FileKey); err != nil {
+		s.logger.Error(ctx, err.Error(), "entry_id", req.EntryId)
+		return nil, fileAccessError(err)
+	}
+	return &pb.UpdateFileKeyResponse{}, nil
+}
+
+// maxUploadParts is the largest part number S3 accepts in a multipart upload.
+const maxUploadParts = 10000
+
+// validPartNumber reports whether n is a valid multipart upload part number.
+func validPartNumber(n int32) bool {
+	return n >= 1 && n <= maxUploadParts
+}
+
+// StartMultipartUpload starts a multipart upload of the pending file of the
+// given entry, or returns the one already in progress so that an interrupted
+// upload can be resumed. Returns codes.NotFound when the entry has no pending
+// file, codes.PermissionDenied when the caller does not own the entry, and
+// codes.Internal on other errors.
+func (s *GRPCServer) StartMultipartUpload(ctx context.Context, req *pb.StartMultipartUploadRequest) (*pb.StartMultipartUploadResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	uploadID, err := s.entries.StartMultipartUpload(ctx, userID, req.EntryId)
+	if err != nil {
+		s.logger.Error(ctx, err.Error(), "entry_id", req.EntryId)
+		return nil, fileAccessError(err)
+	}
+	return &pb.StartMultipartUploadResponse{UploadId: uploadID}, nil
+}
+
+// PresignUploadParts returns presigned PUT URLs for the requested parts of a
+// multipart upload, in the same order. Returns codes.InvalidArgument for part
+// numbers outside 1..10000, codes.NotFound when the upload is not in
+// progress, codes.PermissionDenied when the caller does not own the entry,
+// and codes.Internal on other errors.
+func (s *GRPCServer) PresignUploadParts(ctx context.Context, req *pb.PresignUploadPartsRequest) (*pb.PresignUploadPartsResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	for _, n := range req.PartNumbers {
+		if !validPartNumber(n) {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid part number %d", n)
+		}
+	}
+
+	urls, err := s.entries.PresignUploadParts(ctx, userID, req.EntryId, req.UploadId, req.PartNumbers)
+	if err != nil {
+		s.logger.Error(ctx, err.Error(), "entry_id", req.EntryId)
+		return nil, fileAccessError(err)
+	}
+	return &pb.PresignUploadPartsResponse{Urls: urls}, nil
+}
+
+// CompleteMultipartUpload assembles the uploaded parts of a multipart upload
+// into the file of the given entry. Returns codes.InvalidArgument for an
+// empty or invalid part list, codes.NotFound when the upload is not in
+// progress, codes.PermissionDenied when the caller does not own the entry,
+// and codes.Internal on other errors.
+func (s *GRPCServer) CompleteMultipartUpload(ctx context.Context, req *pb.CompleteMultipartUploadRequest) (*pb.CompleteMultipartUploadResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	if len(req.Parts) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no parts")
+	}
+	parts := make([]models.UploadedPart, 0, len(req.Parts))
+	for _, p := range req.Parts {
+		if !validPartNumber(p.PartNumber) || p.Etag == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid part %d", p.PartNumber)
+		}
+		parts = append(parts, models.UploadedPart{PartNumber: p.PartNumber, ETag: p.Etag})
+	}
+
+	if err := s.entries.CompleteMultipartUpload(ctx, userID, req.EntryId, req.UploadId, parts); err != nil {
+		s.logger.Error(ctx, err.Error(), "entry_id", req.EntryId)
+		return nil, fileAccessError(err)
+	}
+	return &pb.CompleteMultipartUploadResponse{}, nil
+}
+
+// AbortMultipartUpload discards a multipart upload of the file of the given
+// entry. Returns codes.NotFound when the upload is not in progress,
+// codes.PermissionDenied when the caller does not own the entry, and
+// codes.Internal on other errors.
+func (s *GRPCServer) AbortMultipartUpload(ctx context.Context, req *pb.AbortMultipartUploadRequest) (*pb.AbortMultipartUploadResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	if err := s.entries.AbortMultipartUpload(ctx, userID, req.EntryId, req.UploadId); err != nil {
+		s.logger.Error(ctx, err.Error(), "entry_id", req.EntryId)
+		return nil, fileAccessError(err)
+	}
+	return &pb.AbortMultipartUploadResponse{}, nil
+}
+
+// RequestUploadURL returns a fresh presigned PUT URL for the pending file of
+// the given entry. Returns codes.NotFound when the entry has no pending file,
+// codes.PermissionDenied when the caller does not own the entry, and
+// codes.Internal on other errors.
+func (s *GRPCServer) RequestUploadURL(ctx context.Context, req *pb.RequestUploadURLRequest) (*pb.RequestUploadURLResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	url, err := s.entries.RequestUploadURL(ctx, userID, req.EntryId)
+	if err != nil {
+		s.logger.Error(ctx, err.Error(), "entry_id", req.EntryId)
+		return nil, fileAccessError(err)
+	}
+	return &pb.RequestUploadURLResponse{Url: url}, nil
+}
+
+// ListRevisions returns the stored versions of the caller's entry, newest
+// first. Returns codes.Internal on service errors.
+func (s *GRPCServer) ListRevisions(ctx context.Context, req *pb.ListRevisionsRequest) (*pb.ListRevisionsResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	revs, err := s.entries.ListRevisions(ctx, userID, req.EntryId)
+	if err != nil {
+		s.logger.Error(ctx, err.Error(), "entry_id", req.EntryId)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	var out []*pb.Revision
+	for _, r := range revs {
+		out = append(out, revisionToPB(r))
+	}
+	return &pb.ListRevisionsResponse{Revisions: out}, nil
+}
+
+// GetRevision returns a single stored version of the caller's entry. Returns
+// codes.NotFound for unknown revisions and codes.Internal on other errors.
+func (s *GRPCServer) GetRevision(ctx context.Context, req *pb.GetRevisionRequest) (*pb.GetRevisionResponse, error) {
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	rev, err := s.entries.GetRevision(ctx, userID, req.EntryId, req.Version)
+	if err != nil {
+		s.logger.Error(ctx, err.Error(), "entry_id", req.EntryId, "version", req.Version)
+		return nil, fileAccessError(err)
+	}
+	return &pb.GetRevisionResponse{Revision: revisionToPB(rev)}, nil
+}
+
+// revisionToPB maps a stored entry version to its protobuf form.
+func revisionToPB(r *models.Entry) *pb.Revision {
+	return &pb.Revision{
+		EntryId:       r.ID,
+		Version:       r.Version,
+		Overview:      r.Overview,
+		NonceOverview: r.NonceOverview,
+		Details:       r.Details,
+		NonceDetails:  r.NonceDetails,
+		Deleted:       r.Deleted,
+		CreatedAt:     r.CreatedAt.Unix(),
+	}
+}
+
+// sessionToPB maps a session to its protobuf form.
+func sessionToPB(s *models.Session) *pb.Session {
+	return &pb.Session{
+		Id: s.ID,
+		Device: &pb.DeviceInfo{
+			Name:          s.Device.Name,
+			Platform:      s.Device.Platform,
+			ClientVersion: s.Device.ClientVersion,
+		},
+		Ip:         s.Device.IP,
+		CreatedAt:  s.CreatedAt.Unix(),
+		LastUsedAt: s.LastUsedAt.Unix(),
+		ExpiresAt:  s.Expires.Unix(),
+		Current:    s.Current,
+	}
+}
+
+// deviceFromPB maps the device a client reported, if any, and adds the
+// address it connects from.
+func deviceFromPB(ctx context.Context, d *pb.DeviceInfo) models.Device {
+	return models.Device{
+		Name:          d.GetName(),
+		Platform:      d.GetPlatform(),
+		ClientVersion: d.GetClientVersion(),
+		IP:            peerIP(ctx),
+	}
+}
+
+// peerIP returns the IP address of the caller, or "" if it is unknown.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// kdfToPB maps KDF parameters to their protobuf form.
+func kdfToPB(p cryptox.KDFParams) *pb.KDFParams {
+	return &pb.KDFParams{Algorithm: p.Algorithm, Time: p.Time, Memory: p.Memory, Threads: uint32(p.Threads)}
+}
+
+// kdfFromPB maps protobuf KDF parameters back. Requests of clients that do
+// not send them used the legacy parameters; out of range thread counts are
+// mapped to 0, which fails validation.
+func kdfFromPB(p *pb.KDFParams) cryptox.KDFParams {
+	if p == nil {
+		return cryptox.LegacyKDFParams
+	}
+	threads := uint8(p.Threads)
+	if p.Threads > 255 {
+		threads = 0
+	}
+	return cryptox.KDFParams{Algorithm: p.Algorithm, Time: p.Time, Memory: p.Memory, Threads: threads}
+}
+
+// userIDFromContext returns the authenticated user ID injected by
+// accessTokenInterceptor.
+func userIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(UserIDKey).(string)
+	return userID, ok
+}
+
+// sessionIDFromContext returns the session of the caller's access token
+// injected by accessTokenInterceptor.
+func sessionIDFromContext(ctx context.Context) (string, bool) {
+	sessionID, ok := ctx.Value(SessionIDKey).(string)
+	return sessionID, ok
+}
+
+// authError maps login errors to gRPC statuses without revealing details.
+func authError(err error) error {
+	if errors.Is(err, common.ErrorUnauthorized) {
+		return status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
+
+// fileAccessError maps errors of owner-scoped file and revision operations to
+// gRPC statuses.
+func fileAccessError(err error) error {
+	switch {
+	case errors.Is(err, common.ErrorNotFound):
+		return status.Error(codes.NotFound, "not found")
+	case errors.Is(err, common.ErrorForbidden):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
@@ -4,14 +4,19 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"net"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
 	pb "github.com/dmitrijs2005/gophkeeper/internal/proto"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/services"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
@@ -34,30 +39,151 @@ type fakeUser struct {
 
 	regResp *models.User
 	regErr  error
+	regVK   []byte
+	regKDF  cryptox.KDFParams
 
-	saltResp []byte
+	saltResp *services.SaltInfo
 	saltErr  error
 
 	loginResp *services.TokenPair
 	loginErr  error
+	loginSRP  []byte
+	loginVK   []byte
+
+	challenge   *services.LoginChallenge
+	serverProof []byte
+	wrappedVK   []byte
+	loginUser   string
+
+	upgradeUser string
+	upgradeErr  error
+
+	changeUser string
+	changeVK   []byte
+	changeKDF  cryptox.KDFParams
+	changeErr  error
+	// changeRekey is the re-encrypted content passed to ChangePassword
+	changeRekey *models.VaultRekey
+
+	upgradeKDFUser string
+	upgradeKDF     cryptox.KDFParams
+	upgradeKDFErr  error
+
+	device    models.Device
+	refreshIP string
+
+	sessions       []*models.Session
+	currentSession string
+	revokedUser    string
+	revokedSession string
+	revokedOthers  int64
+	sessionErr     error
+	checkErr       error
+
+	twoFactorChallenge string
+	twoFactorSetup     *services.TwoFactorSetup
+	twoFactorCode      string
+	twoFactorUser      string
+	recoveryCodes      []string
+	twoFactorErr       error
 }
 
-func (f *fakeUser) RefreshToken(ctx context.Context, refresh string) (*services.TokenPair, error) {
+func (f *fakeUser) RefreshToken(ctx context.Context, refresh string, ip string) (*services.TokenPair, error) {
+	f.refreshIP = ip
 	return f.refreshResp, f.refreshErr
 }
-func (f *fakeUser) Register(ctx context.Context, username string, salt []byte, verifier []byte) (*models.User, error) {
+func (f *fakeUser) Register(ctx context.Context, username string, c models.Credentials) (*models.User, error) {
+	f.regVK, f.regKDF = c.WrappedVaultKey, c.KDF
 	return f.regResp, f.regErr
 }
-func (f *fakeUser) GetSalt(ctx context.Context, username string) ([]byte, error) {
+func (f *fakeUser) GetSalt(ctx context.Context, username string) (*services.SaltInfo, error) {
 	return f.saltResp, f.saltErr
 }
-func (f *fakeUser) Login(ctx context.Context, username string, verifierCandidate []byte) (*services.TokenPair, error) {
+func (f *fakeUser) Login(ctx context.Context, username string, verifierCandidate, srpVerifier, wrappedVaultKey []byte, device models.Device) (*services.TokenPair, error) {
+	f.device = device
+	f.loginSRP = srpVerifier
+	f.loginVK = wrappedVaultKey
 	return f.loginResp, f.loginErr
 }
+func (f *fakeUser) LoginStart(ctx context.Context, username string, clientPublic []byte) (*services.LoginChallenge, error) {
+	return f.challenge, f.loginErr
+}
+func (f *fakeUser) LoginFinish(ctx context.Context, sessionID string, clientProof []byte, device models.Device) (*services.LoginResult, error) {
+	f.device = device
+	if f.loginErr != nil {
+		return nil, f.loginErr
+	}
+	if f.twoFactorChallenge != "" {
+		return &services.LoginResult{ServerProof: f.serverProof, UserName: f.loginUser, TwoFactorChallenge: f.twoFactorChallenge}, nil
+	}
+	return &services.LoginResult{Tokens: f.loginResp, ServerProof: f.serverProof, WrappedVaultKey: f.wrappedVK, UserName: f.loginUser}, nil
+}
+func (f *fakeUser) Verify2FA(ctx context.Context, challengeID string, code string, device models.Device) (*services.LoginResult, error) {
+	f.device, f.twoFactorCode = device, code
+	if f.twoFactorErr != nil {
+		return nil, f.twoFactorErr
+	}
+	return &services.LoginResult{Tokens: f.loginResp, WrappedVaultKey: f.wrappedVK, UserName: f.loginUser}, nil
+}
+func (f *fakeUser) Enable2FA(ctx context.Context, userID string, code string) (*services.TwoFactorSetup, error) {
+	f.twoFactorUser, f.twoFactorCode = userID, code
+	return f.twoFactorSetup, f.twoFactorErr
+}
+func (f *fakeUser) Confirm2FA(ctx context.Context, userID string, code string) ([]string, error) {
+	f.twoFactorUser, f.twoFactorCode = userID, code
+	return f.recoveryCodes, f.twoFactorErr
+}
+func (f *fakeUser) UpgradeKeys(ctx context.Context, userID string, srpVerifier, wrappedVaultKey []byte) error {
+	f.upgradeUser = userID
+	return f.upgradeErr
+}
+func (f *fakeUser) ChangePassword(ctx context.Context, userID string, sessionID string, clientProof []byte, c models.Credentials, rekey *models.VaultRekey, device models.Device) (*services.LoginResult, error) {
+	f.device = device
+	f.changeUser, f.changeVK, f.changeKDF, f.changeRekey = userID, c.WrappedVaultKey, c.KDF, rekey
+	if f.changeErr != nil {
+		return nil, f.changeErr
+	}
+	var versions map[string]int64
+	if rekey != nil {
+		versions = make(map[string]int64, len(rekey.Entries))
+		for i, e := range rekey.Entries {
+			versions[e.ID] = e.BaseVersion + int64(i) + 1
+		}
+	}
+	return &services.LoginResult{Tokens: f.loginResp, ServerProof: f.serverProof, Versions: versions}, nil
+}
+func (f *fakeUser) UpgradeKDF(ctx context.Context, userID string, sessionID string, clientProof []byte, c models.Credentials) ([]byte, error) {
+	f.upgradeKDFUser, f.upgradeKDF = userID, c.KDF
+	if f.upgradeKDFErr != nil {
+		return nil, f.upgradeKDFErr
+	}
+	return f.serverProof, nil
+}
+func (f *fakeUser) ListSessions(ctx context.Context, userID string, currentSessionID string) ([]*models.Session, error) {
+	f.currentSession = currentSessionID
+	return f.sessions, f.sessionErr
+}
+func (f *fakeUser) RevokeSession(ctx context.Context, userID string, id string) error {
+	f.revokedUser, f.revokedSession = userID, id
+	return f.sessionErr
+}
+func (f *fakeUser) RevokeOtherSessions(ctx context.Context, userID string, currentSessionID string) (int64, error) {
+	f.revokedUser, f.currentSession = userID, currentSessionID
+	return f.revokedOthers, f.sessionErr
+}
+func (f *fakeUser) Logout(ctx context.Context, userID string, sessionID string) error {
+	f.revokedUser, f.revokedSession = userID, sessionID
+	return f.sessionErr
+}
+func (f *fakeUser) CheckSession(ctx context.Context, sessionID string) error {
+	return f.checkErr
+}
 
 type fakeEntry struct {
+	syncIn  []*models.Entry
 	syncOut struct {
 		processed   []*models.Entry
+		conflicts   []*models.Entry
 		newEntries  []*models.Entry
 		newFiles    []*models.File
 		uploadTasks []*models.FileUploadTask
@@ -67,16 +193,75 @@ type fakeEntry struct {
 	markErr error
 	url     string
 	urlErr  error
+
+	revs   []*models.Entry
+	revErr error
+
+	keyIn  []byte
+	keyErr error
+
+	uploadID     string
+	partsIn      []int32
+	completedIn  []models.UploadedPart
+	multipartErr error
+
+	uploadURL    string
+	uploadURLErr error
+}
+
+func (f *fakeEntry) StartMultipartUpload(ctx context.Context, userID string, entryID string) (string, error) {
+	return f.uploadID, f.multipartErr
+}
+func (f *fakeEntry) PresignUploadParts(ctx context.Context, userID string, entryID string, uploadID string, parts []int32) ([]string, error) {
+	f.partsIn = parts
+	var urls []string
+	for _, p := range parts {
+		urls = append(urls, fmt.Sprintf("u%d", p))
+	}
+	return urls, f.multipartErr
+}
+func (f *fakeEntry) CompleteMultipartUpload(ctx context.Context, userID string, entryID string, uploadID string, parts []models.UploadedPart) error {
+	f.completedIn = parts
+	return f.multipartErr
+}
+func (f *fakeEntry) AbortMultipartUpload(ctx context.Context, userID string, entryID string, uploadID string) error {
+	return f.multipartErr
+}
+
+func (f *fakeEntry) RequestUploadURL(ctx context.Context, userID string, entryID string) (string, error) {
+	return f.uploadURL, f.uploadURLErr
+}
+
+func (f *fakeEntry) UpdateFileKey(ctx context.Context, userID string, entryID string, key []byte) error {
+	f.keyIn = key
+	return f.keyErr
 }
 
 func (f *fakeEntry) Sync(ctx context.Context, userID string, pendingEntries []*models.Entry, pendingFiles []*models.File,
-	clientMaxVersion int64) ([]*models.Entry, []*models.Entry, []*models.File, []*models.FileUploadTask, int64, error) {
-	return f.syncOut.processed, f.syncOut.newEntries, f.syncOut.newFiles, f.syncOut.uploadTasks, f.syncOut.maxVersion, f.syncOut.err
+	clientMaxVersion int64) ([]*models.Entry, []*models.Entry, []*models.Entry, []*models.File, []*models.FileUploadTask, int64, error) {
+	f.syncIn = pendingEntries
+	return f.syncOut.processed, f.syncOut.conflicts, f.syncOut.newEntries, f.syncOut.newFiles, f.syncOut.uploadTasks, f.syncOut.maxVersion, f.syncOut.err
 }
-func (f *fakeEntry) MarkUploaded(ctx context.Context, entryID string) error { return f.markErr }
-func (f *fakeEntry) GetPresignedGetURL(ctx context.Context, entryID string) (string, error) {
+func (f *fakeEntry) MarkUploaded(ctx context.Context, userID string, entryID string) error {
+	return f.markErr
+}
+func (f *fakeEntry) GetPresignedGetURL(ctx context.Context, userID string, entryID string) (string, error) {
 	return f.url, f.urlErr
 }
+func (f *fakeEntry) ListRevisions(ctx context.Context, userID string, entryID string) ([]*models.Entry, error) {
+	return f.revs, f.revErr
+}
+func (f *fakeEntry) GetRevision(ctx context.Context, userID string, entryID string, version int64) (*models.Entry, error) {
+	if f.revErr != nil {
+		return nil, f.revErr
+	}
+	for _, r := range f.revs {
+		if r.Version == version {
+			return r, nil
+		}
+	}
+	return nil, common.ErrorNotFound
+}
 
 // ---- helpers ----
 
@@ -108,13 +293,17 @@ func TestRefreshToken_OK(t *testing.T) {
 		refreshResp: &services.TokenPair{AccessToken: "a", RefreshToken: "r"},
 	}
 	s := newServer(u, &fakeEntry{})
-	resp, err := s.RefreshToken(context.Background(), &pb.RefreshTokenRequest{RefreshToken: "r0"})
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5555}})
+	resp, err := s.RefreshToken(ctx, &pb.RefreshTokenRequest{RefreshToken: "r0"})
 	if err != nil {
 		t.Fatalf("RefreshToken error: %v", err)
 	}
 	if resp.GetAccessToken() != "a" || resp.GetRefreshToken() != "r" {
 		t.Fatalf("unexpected tokens: %+v", resp)
 	}
+	if u.refreshIP != "10.0.0.1" {
+		t.Fatalf("caller address not passed: %q", u.refreshIP)
+	}
 }
 
 func TestRefreshToken_InternalOnError(t *testing.T) {
@@ -126,11 +315,40 @@ func TestRefreshToken_InternalOnError(t *testing.T) {
 	}
 }
 
+func TestRefreshToken_ReuseIsUnauthenticated(t *testing.T) {
+	u := &fakeUser{refreshErr: fmt.Errorf("error searching refresh token: %w", &services.TokenReuseError{UserID: "u1", SessionID: "s1"})}
+	s := newServer(u, &fakeEntry{})
+	_, err := s.RefreshToken(context.Background(), &pb.RefreshTokenRequest{RefreshToken: "r0"})
+	if status.Code(err) != codes.Unauthenticated || status.Convert(err).Message() != common.ErrRefreshTokenReused.Error() {
+		t.Fatalf("want Unauthenticated reuse, got %v", err)
+	}
+}
+
+func TestLogout_EndsCallerSession(t *testing.T) {
+	ctx := context.WithValue(context.Background(), UserIDKey, "user-1")
+	ctx = context.WithValue(ctx, SessionIDKey, "s1")
+
+	u := &fakeUser{}
+	s := newServer(u, &fakeEntry{})
+	if _, err := s.Logout(ctx, &pb.LogoutRequest{}); err != nil || u.revokedUser != "user-1" || u.revokedSession != "s1" {
+		t.Fatalf("Logout: %v, %q/%q", err, u.revokedUser, u.revokedSession)
+	}
+
+	boom := newServer(&fakeUser{sessionErr: errors.New("boom")}, &fakeEntry{})
+	if _, err := boom.Logout(ctx, &pb.LogoutRequest{}); status.Code(err) != codes.Internal {
+		t.Fatalf("want Internal, got %v", status.Code(err))
+	}
+	if _, err := s.Logout(context.Background(), &pb.LogoutRequest{}); status.Code(err) != codes.Internal {
+		t.Fatalf("missing caller: want Internal, got %v", status.Code(err))
+	}
+}
+
 func TestRegisterUser_OK(t *testing.T) {
 	u := &fakeUser{regResp: &models.User{ID: "42"}}
 	s := newServer(u, &fakeEntry{})
 	resp, err := s.RegisterUser(context.Background(), &pb.RegisterUserRequest{
-		Username: "u", Salt: []byte("s"), Verifier: []byte("v"),
+		Username: "u", Salt: []byte("s"), Verifier: []byte("v"), WrappedVaultKey: []byte("wvk"),
+		Kdf: &pb.KDFParams{Algorithm: "argon2id", Time: 3, Memory: 65536, Threads: 4},
 	})
 	if err != nil {
 		t.Fatalf("RegisterUser error: %v", err)
@@ -138,6 +356,28 @@ func TestRegisterUser_OK(t *testing.T) {
 	if resp.GetUsername() == "" {
 		t.Fatalf("empty response")
 	}
+	if string(u.regVK) != "wvk" || u.regKDF != cryptox.DefaultKDFParams {
+		t.Fatalf("credentials not passed through: %q, %+v", u.regVK, u.regKDF)
+	}
+
+	// clients that do not send parameters used the legacy ones
+	if _, err := s.RegisterUser(context.Background(), &pb.RegisterUserRequest{Username: "u"}); err != nil || u.regKDF != cryptox.LegacyKDFParams {
+		t.Fatalf("missing kdf: %+v, %v", u.regKDF, err)
+	}
+}
+
+func TestRegisterUser_WeakKDF(t *testing.T) {
+	u := &fakeUser{regErr: common.ErrorForbidden}
+	s := newServer(u, &fakeEntry{})
+	_, err := s.RegisterUser(context.Background(), &pb.RegisterUserRequest{
+		Username: "u", Kdf: &pb.KDFParams{Algorithm: "argon2id", Time: 1, Memory: 65536, Threads: 1024},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("want InvalidArgument, got %v", status.Code(err))
+	}
+	if u.regKDF.Threads != 0 {
+		t.Fatalf("out of range threads must not be truncated: %+v", u.regKDF)
+	}
 }
 
 func TestRegisterUser_InternalOnError(t *testing.T) {
@@ -152,14 +392,22 @@ func TestRegisterUser_InternalOnError(t *testing.T) {
 }
 
 func TestGetSalt_OK(t *testing.T) {
-	u := &fakeUser{saltResp: []byte("SALT123")}
+	u := &fakeUser{saltResp: &services.SaltInfo{Salt: []byte("SALT123"), KDF: cryptox.LegacyKDFParams, LegacyKeys: true}}
 	s := newServer(u, &fakeEntry{})
 	resp, err := s.GetSalt(context.Background(), &pb.GetSaltRequest{Username: "u"})
 	if err != nil {
 		t.Fatalf("GetSalt error: %v", err)
 	}
-	if !bytes.Equal(resp.GetSalt(), []byte("SALT123")) {
-		t.Fatalf("unexpected salt: %q", resp.GetSalt())
+	if !bytes.Equal(resp.GetSalt(), []byte("SALT123")) || !resp.GetLegacyKeys() ||
+		resp.GetKdf().GetTime() != 1 || resp.GetKdf().GetThreads() != 4 || resp.GetKdfUpgrade() != nil {
+		t.Fatalf("unexpected salt response: %+v", resp)
+	}
+
+	policy := cryptox.DefaultKDFParams
+	u.saltResp = &services.SaltInfo{Salt: []byte("SALT123"), KDF: cryptox.LegacyKDFParams, KDFUpgrade: &policy}
+	resp, err = s.GetSalt(context.Background(), &pb.GetSaltRequest{Username: "u"})
+	if err != nil || resp.GetKdfUpgrade().GetTime() != 3 || resp.GetKdfUpgrade().GetAlgorithm() != "argon2id" {
+		t.Fatalf("upgrade not returned: %+v, %v", resp, err)
 	}
 }
 
@@ -176,7 +424,7 @@ func TestLogin_OK(t *testing.T) {
 	u := &fakeUser{loginResp: &services.TokenPair{AccessToken: "A", RefreshToken: "R"}}
 	s := newServer(u, &fakeEntry{})
 	resp, err := s.Login(context.Background(), &pb.LoginRequest{
-		Username: "u", VerifierCandidate: []byte("vv"),
+		Username: "u", VerifierCandidate: []byte("vv"), SrpVerifier: []byte("srp"), WrappedVaultKey: []byte("wvk"),
 	})
 	if err != nil {
 		t.Fatalf("Login error: %v", err)
@@ -184,6 +432,116 @@ func TestLogin_OK(t *testing.T) {
 	if resp.GetAccessToken() != "A" || resp.GetRefreshToken() != "R" {
 		t.Fatalf("unexpected tokens: %+v", resp)
 	}
+	if string(u.loginSRP) != "srp" || string(u.loginVK) != "wvk" {
+		t.Fatalf("new keys not passed through: %q, %q", u.loginSRP, u.loginVK)
+	}
+}
+
+func TestLoginStartFinish_OK(t *testing.T) {
+	u := &fakeUser{
+		challenge:   &services.LoginChallenge{SessionID: "s1", ServerPublic: []byte("B")},
+		loginResp:   &services.TokenPair{AccessToken: "A", RefreshToken: "R"},
+		serverProof: []byte("M2"),
+		wrappedVK:   []byte("wvk"),
+	}
+	s := newServer(u, &fakeEntry{})
+
+	start, err := s.LoginStart(context.Background(), &pb.LoginStartRequest{Username: "u", ClientPublic: []byte("A")})
+	if err != nil || start.GetSessionId() != "s1" || string(start.GetServerPublic()) != "B" {
+		t.Fatalf("LoginStart: %+v, %v", start, err)
+	}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5555}})
+	fin, err := s.LoginFinish(ctx, &pb.LoginFinishRequest{
+		SessionId: "s1", ClientProof: []byte("M1"),
+		Device: &pb.DeviceInfo{Name: "laptop", Platform: "linux/amd64", ClientVersion: "1.2.0"},
+	})
+	if err != nil || string(fin.GetServerProof()) != "M2" || fin.GetAccessToken() != "A" || fin.GetRefreshToken() != "R" || string(fin.GetWrappedVaultKey()) != "wvk" {
+		t.Fatalf("LoginFinish: %+v, %v", fin, err)
+	}
+	if want := (models.Device{Name: "laptop", Platform: "linux/amd64", ClientVersion: "1.2.0", IP: "10.0.0.1"}); u.device != want {
+		t.Fatalf("device: got %+v, want %+v", u.device, want)
+	}
+
+	s2 := newServer(&fakeUser{loginErr: common.ErrorUnauthorized}, &fakeEntry{})
+	if _, err := s2.LoginFinish(context.Background(), &pb.LoginFinishRequest{SessionId: "s1"}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("want Unauthenticated, got %v", status.Code(err))
+	}
+	s3 := newServer(&fakeUser{loginErr: errors.New("boom")}, &fakeEntry{})
+	if _, err := s3.LoginStart(context.Background(), &pb.LoginStartRequest{Username: "u"}); status.Code(err) != codes.Internal {
+		t.Fatalf("want Internal, got %v", status.Code(err))
+	}
+}
+
+func TestLoginFinish_TwoFactor(t *testing.T) {
+	u := &fakeUser{
+		serverProof:        []byte("M2"),
+		twoFactorChallenge: "c1",
+		loginResp:          &services.TokenPair{AccessToken: "A", RefreshToken: "R"},
+		wrappedVK:          []byte("wvk"),
+	}
+	s := newServer(u, &fakeEntry{})
+
+	// the tokens and the key wait for the second factor
+	fin, err := s.LoginFinish(context.Background(), &pb.LoginFinishRequest{SessionId: "s1"})
+	if err != nil || fin.GetTwoFactorChallenge() != "c1" || string(fin.GetServerProof()) != "M2" || fin.GetAccessToken() != "" || fin.GetWrappedVaultKey() != nil {
+		t.Fatalf("LoginFinish: %+v, %v", fin, err)
+	}
+
+	resp, err := s.Verify2FA(context.Background(), &pb.Verify2FARequest{ChallengeId: "c1", Code: "123456", Device: &pb.DeviceInfo{Name: "laptop"}})
+	if err != nil || resp.GetAccessToken() != "A" || resp.GetRefreshToken() != "R" || string(resp.GetWrappedVaultKey()) != "wvk" {
+		t.Fatalf("Verify2FA: %+v, %v", resp, err)
+	}
+	if u.twoFactorCode != "123456" || u.device.Name != "laptop" {
+		t.Fatalf("code %q, device %+v", u.twoFactorCode, u.device)
+	}
+
+	u.twoFactorErr = &services.LoginError{UserName: "alice"}
+	if _, err := s.Verify2FA(context.Background(), &pb.Verify2FARequest{ChallengeId: "c1"}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("wrong code: want Unauthenticated, got %v", status.Code(err))
+	}
+}
+
+func TestEnableConfirm2FA(t *testing.T) {
+	ctx := context.WithValue(context.Background(), UserIDKey, "user-1")
+	u := &fakeUser{
+		twoFactorSetup: &services.TwoFactorSetup{Secret: "SECRET", URI: "otpauth://totp/x"},
+		recoveryCodes:  []string{"AAAA-BBBB"},
+	}
+	s := newServer(u, &fakeEntry{})
+
+	setup, err := s.Enable2FA(ctx, &pb.Enable2FARequest{Code: "654321"})
+	if err != nil || setup.GetSecret() != "SECRET" || setup.GetOtpauthUri() != "otpauth://totp/x" || u.twoFactorUser != "user-1" || u.twoFactorCode != "654321" {
+		t.Fatalf("Enable2FA: %+v, %v", setup, err)
+	}
+	confirm, err := s.Confirm2FA(ctx, &pb.Confirm2FARequest{Code: "123456"})
+	if err != nil || len(confirm.GetRecoveryCodes()) != 1 || u.twoFactorCode != "123456" {
+		t.Fatalf("Confirm2FA: %+v, %v", confirm, err)
+	}
+
+	for err, want := range map[error]codes.Code{
+		common.ErrorUnauthorized: codes.InvalidArgument,
+		common.ErrorForbidden:    codes.PermissionDenied,
+		errors.New("boom"):       codes.Internal,
+	} {
+		u.twoFactorErr = err
+		if _, got := s.Confirm2FA(ctx, &pb.Confirm2FARequest{Code: "1"}); status.Code(got) != want {
+			t.Fatalf("%v: want %v, got %v", err, want, status.Code(got))
+		}
+	}
+	for err, want := range map[error]codes.Code{
+		common.ErrSecondFactorRequired:          codes.FailedPrecondition,
+		&services.LoginError{UserName: "alice"}: codes.Unauthenticated,
+		common.ErrorUnauthorized:                codes.Unauthenticated,
+		errors.New("boom"):                      codes.Internal,
+	} {
+		u.twoFactorErr = err
+		if _, got := s.Enable2FA(ctx, &pb.Enable2FARequest{Code: "1"}); status.Code(got) != want {
+			t.Fatalf("Enable2FA %v: want %v, got %v", err, want, status.Code(got))
+		}
+	}
+	if _, err := s.Enable2FA(context.Background(), &pb.Enable2FARequest{}); status.Code(err) != codes.Internal {
+		t.Fatalf("missing caller: want Internal, got %v", status.Code(err))
+	}
 }
 
 func TestLogin_UnauthorizedAndInternal(t *testing.T) {
@@ -205,6 +563,9 @@ func TestSync_OK(t *testing.T) {
 	e.syncOut.processed = []*models.Entry{
 		{ID: "e1", Version: 1, Overview: []byte("o1"), NonceOverview: []byte("n1"), Details: []byte("d1"), NonceDetails: []byte("m1"), Deleted: false},
 	}
+	e.syncOut.conflicts = []*models.Entry{
+		{ID: "e5", Version: 9, Overview: []byte("o5"), NonceOverview: []byte("n5"), Details: []byte("d5"), NonceDetails: []byte("m5")},
+	}
 	e.syncOut.newEntries = []*models.Entry{
 		{ID: "e2", Version: 2, Overview: []byte("o2"), NonceOverview: []byte("n2"), Details: []byte("d2"), NonceDetails: []byte("m2"), Deleted: true},
 	}
@@ -222,7 +583,7 @@ func TestSync_OK(t *testing.T) {
 	req := &pb.SyncRequest{
 		MaxVersion: 1,
 		Entries: []*pb.Entry{
-			{Id: "e1", Deleted: false, Overview: []byte("O"), NonceOverview: []byte("N"), Details: []byte("D"), NonceDetails: []byte("M")},
+			{Id: "e1", BaseVersion: 3, Deleted: false, Overview: []byte("O"), NonceOverview: []byte("N"), Details: []byte("D"), NonceDetails: []byte("M")},
 		},
 		Files: []*pb.File{
 			{EntryId: "e1", FileKey: []byte("K"), Nonce: []byte("Z")},
@@ -239,6 +600,12 @@ func TestSync_OK(t *testing.T) {
 	if len(resp.ProcessedEntries) != 1 || resp.ProcessedEntries[0].GetId() != "e1" {
 		t.Fatalf("mapped processed entries unexpected: %+v", resp.ProcessedEntries)
 	}
+	if len(e.syncIn) != 1 || e.syncIn[0].BaseVersion != 3 {
+		t.Fatalf("base version not passed to service: %+v", e.syncIn)
+	}
+	if len(resp.Conflicts) != 1 || resp.Conflicts[0].GetId() != "e5" || resp.Conflicts[0].GetVersion() != 9 {
+		t.Fatalf("mapped conflicts unexpected: %+v", resp.Conflicts)
+	}
 	if len(resp.NewEntries) != 1 || resp.NewEntries[0].GetId() != "e2" || !resp.NewEntries[0].GetDeleted() {
 		t.Fatalf("mapped new entries unexpected: %+v", resp.NewEntries)
 	}
@@ -276,27 +643,243 @@ func TestSync_PropagatesErrors(t *testing.T) {
 	if status.Code(err) != codes.Internal {
 		t.Fatalf("want Internal, got %v", status.Code(err))
 	}
+
+	e3 := &fakeEntry{}
+	e3.syncOut.err = common.ErrResyncRequired
+	s3 := newServer(&fakeUser{}, e3)
+	_, err = s3.Sync(ctx, &pb.SyncRequest{MaxVersion: 4})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("want FailedPrecondition, got %v", status.Code(err))
+	}
 }
 
 func TestMarkUploaded_OK_and_Error(t *testing.T) {
+	ctx := context.WithValue(context.Background(), UserIDKey, "user-1")
+
 	e := &fakeEntry{}
 	s := newServer(&fakeUser{}, e)
-	if _, err := s.MarkUploaded(context.Background(), &pb.MarkUploadedRequest{EntryId: "e"}); err != nil {
+	if _, err := s.MarkUploaded(ctx, &pb.MarkUploadedRequest{EntryId: "e"}); err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
 
 	e2 := &fakeEntry{markErr: errors.New("boom")}
 	s2 := newServer(&fakeUser{}, e2)
-	_, err := s2.MarkUploaded(context.Background(), &pb.MarkUploadedRequest{EntryId: "e"})
+	_, err := s2.MarkUploaded(ctx, &pb.MarkUploadedRequest{EntryId: "e"})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("want Internal, got %v", status.Code(err))
+	}
+}
+
+func TestMarkUploaded_ContextMissingUserID(t *testing.T) {
+	s := newServer(&fakeUser{}, &fakeEntry{})
+	_, err := s.MarkUploaded(context.Background(), &pb.MarkUploadedRequest{EntryId: "e"})
 	if status.Code(err) != codes.Internal {
 		t.Fatalf("want Internal, got %v", status.Code(err))
 	}
 }
 
+func TestUpdateFileKey_PassesKeyAndMapsErrors(t *testing.T) {
+	ctx := context.WithValue(context.Background(), UserIDKey, "user-1")
+
+	e := &fakeEntry{}
+	s := newServer(&fakeUser{}, e)
+	if _, err := s.UpdateFileKey(ctx, &pb.UpdateFileKeyRequest{EntryId: "e", FileKey: []byte("wk")}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if string(e.keyIn) != "wk" {
+		t.Fatalf("key not passed through: %q", e.keyIn)
+	}
+
+	s2 := newServer(&fakeUser{}, &fakeEntry{keyErr: common.ErrorForbidden})
+	_, err := s2.UpdateFileKey(ctx, &pb.UpdateFileKeyRequest{EntryId: "e"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("want PermissionDenied, got %v", status.Code(err))
+	}
+}
+
+func TestMultipartUpload_ValidatesPartsAndMapsErrors(t *testing.T) {
+	ctx := context.WithValue(context.Background(), UserIDKey, "user-1")
+
+	e := &fakeEntry{uploadID: "up1"}
+	s := newServer(&fakeUser{}, e)
+	start, err := s.StartMultipartUpload(ctx, &pb.StartMultipartUploadRequest{EntryId: "e"})
+	if err != nil || start.GetUploadId() != "up1" {
+		t.Fatalf("start: %v, %v", start, err)
+	}
+
+	presigned, err := s.PresignUploadParts(ctx, &pb.PresignUploadPartsRequest{EntryId: "e", UploadId: "up1", PartNumbers: []int32{2, 1}})
+	if err != nil || strings.Join(presigned.GetUrls(), ",") != "u2,u1" {
+		t.Fatalf("presign: %v, %v", presigned, err)
+	}
+	for _, parts := range [][]int32{{0}, {10001}, {1, -1}} {
+		_, err := s.PresignUploadParts(ctx, &pb.PresignUploadPartsRequest{EntryId: "e", UploadId: "up1", PartNumbers: parts})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("parts %v: want InvalidArgument, got %v", parts, status.Code(err))
+		}
+	}
+
+	if _, err := s.CompleteMultipartUpload(ctx, &pb.CompleteMultipartUploadRequest{
+		EntryId: "e", UploadId: "up1",
+		Parts: []*pb.UploadedPart{{PartNumber: 1, Etag: "a"}, {PartNumber: 2, Etag: "b"}},
+	}); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if len(e.completedIn) != 2 || e.completedIn[1] != (models.UploadedPart{PartNumber: 2, ETag: "b"}) {
+		t.Fatalf("parts not passed through: %+v", e.completedIn)
+	}
+	for _, parts := range [][]*pb.UploadedPart{nil, {{PartNumber: 1}}, {{PartNumber: 0, Etag: "a"}}} {
+		_, err := s.CompleteMultipartUpload(ctx, &pb.CompleteMultipartUploadRequest{EntryId: "e", UploadId: "up1", Parts: parts})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("parts %v: want InvalidArgument, got %v", parts, status.Code(err))
+		}
+	}
+
+	s2 := newServer(&fakeUser{}, &fakeEntry{multipartErr: common.ErrorNotFound})
+	if _, err := s2.StartMultipartUpload(ctx, &pb.StartMultipartUploadRequest{EntryId: "e"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("want NotFound, got %v", status.Code(err))
+	}
+	s3 := newServer(&fakeUser{}, &fakeEntry{multipartErr: common.ErrorForbidden})
+	if _, err := s3.AbortMultipartUpload(ctx, &pb.AbortMultipartUploadRequest{EntryId: "e", UploadId: "up1"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("want PermissionDenied, got %v", status.Code(err))
+	}
+}
+
+func TestRequestUploadURL_MapsErrors(t *testing.T) {
+	ctx := context.WithValue(context.Background(), UserIDKey, "user-1")
+
+	s := newServer(&fakeUser{}, &fakeEntry{uploadURL: "http://put"})
+	resp, err := s.RequestUploadURL(ctx, &pb.RequestUploadURLRequest{EntryId: "e"})
+	if err != nil || resp.GetUrl() != "http://put" {
+		t.Fatalf("got %v, %v", resp, err)
+	}
+
+	for want, svcErr := range map[codes.Code]error{
+		codes.NotFound:         common.ErrorNotFound,
+		codes.PermissionDenied: common.ErrorForbidden,
+		codes.Internal:         errors.New("boom"),
+	} {
+		s := newServer(&fakeUser{}, &fakeEntry{uploadURLErr: svcErr})
+		if _, err := s.RequestUploadURL(ctx, &pb.RequestUploadURLRequest{EntryId: "e"}); status.Code(err) != want {
+			t.Fatalf("%v: want %v, got %v", svcErr, want, status.Code(err))
+		}
+	}
+	if _, err := s.RequestUploadURL(context.Background(), &pb.RequestUploadURLRequest{EntryId: "e"}); status.Code(err) != codes.Internal {
+		t.Fatalf("no user: want Internal, got %v", status.Code(err))
+	}
+}
+
+func TestUpgradeKeys_UsesCallerAndMapsErrors(t *testing.T) {
+	ctx := context.WithValue(context.Background(), UserIDKey, "user-1")
+
+	u := &fakeUser{}
+	if _, err := newServer(u, &fakeEntry{}).UpgradeKeys(ctx, &pb.UpgradeKeysRequest{SrpVerifier: []byte("v"), WrappedVaultKey: []byte("k")}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if u.upgradeUser != "user-1" {
+		t.Fatalf("upgrade not scoped to caller: %q", u.upgradeUser)
+	}
+
+	_, err := newServer(&fakeUser{upgradeErr: common.ErrorForbidden}, &fakeEntry{}).UpgradeKeys(ctx, &pb.UpgradeKeysRequest{})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("want PermissionDenied, got %v", status.Code(err))
+	}
+	_, err = newServer(&fakeUser{upgradeErr: errors.New("boom")}, &fakeEntry{}).UpgradeKeys(ctx, &pb.UpgradeKeysRequest{})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("want Internal, got %v", status.Code(err))
+	}
+}
+
+func TestChangePassword_UsesCallerAndMapsErrors(t *testing.T) {
+	ctx := context.WithValue(context.Background(), UserIDKey, "user-1")
+
+	u := &fakeUser{loginResp: &services.TokenPair{AccessToken: "a", RefreshToken: "r"}, serverProof: []byte("m2")}
+	resp, err := newServer(u, &fakeEntry{}).ChangePassword(ctx, &pb.ChangePasswordRequest{
+		SessionId: "s", WrappedVaultKey: []byte("k"),
+		Kdf: &pb.KDFParams{Algorithm: "argon2id", Time: 3, Memory: 65536, Threads: 4},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if u.changeUser != "user-1" || string(u.changeVK) != "k" || u.changeKDF != cryptox.DefaultKDFParams {
+		t.Fatalf("change not scoped to caller: %q, %q, %+v", u.changeUser, u.changeVK, u.changeKDF)
+	}
+	if resp.AccessToken != "a" || resp.RefreshToken != "r" || string(resp.ServerProof) != "m2" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if u.changeRekey != nil {
+		t.Fatalf("password change without rekey re-keyed: %+v", u.changeRekey)
+	}
+
+	resp, err = newServer(u, &fakeEntry{}).ChangePassword(ctx, &pb.ChangePasswordRequest{
+		SessionId: "s", WrappedVaultKey: []byte("k2"), Rekey: true,
+		Entries: []*pb.Entry{{Id: "e1", Details: []byte("d"), BaseVersion: 4}},
+		Files:   []*pb.File{{EntryId: "e1", FileKey: []byte("fk"), Nonce: []byte("n")}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	rk := u.changeRekey
+	if rk == nil || len(rk.Entries) != 1 || rk.Entries[0].BaseVersion != 4 || string(rk.Entries[0].Details) != "d" ||
+		len(rk.Files) != 1 || string(rk.Files[0].EncryptedFileKey) != "fk" || string(rk.Files[0].Nonce) != "n" {
+		t.Fatalf("rekey not passed on: %+v", rk)
+	}
+	if resp.Versions["e1"] != 5 {
+		t.Fatalf("versions not returned: %v", resp.Versions)
+	}
+
+	cases := []struct {
+		err  error
+		want codes.Code
+	}{
+		{common.ErrorUnauthorized, codes.Unauthenticated},
+		{common.ErrorForbidden, codes.PermissionDenied},
+		{common.ErrVersionConflict, codes.Aborted},
+		{errors.New("boom"), codes.Internal},
+	}
+	for _, c := range cases {
+		_, err := newServer(&fakeUser{changeErr: c.err}, &fakeEntry{}).ChangePassword(ctx, &pb.ChangePasswordRequest{})
+		if status.Code(err) != c.want {
+			t.Fatalf("%v: want %v, got %v", c.err, c.want, status.Code(err))
+		}
+	}
+}
+
+func TestUpgradeKDF_UsesCallerAndMapsErrors(t *testing.T) {
+	ctx := context.WithValue(context.Background(), UserIDKey, "user-1")
+
+	u := &fakeUser{serverProof: []byte("m2")}
+	resp, err := newServer(u, &fakeEntry{}).UpgradeKDF(ctx, &pb.UpgradeKDFRequest{
+		SessionId: "s", Kdf: &pb.KDFParams{Algorithm: "argon2id", Time: 3, Memory: 65536, Threads: 4},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if u.upgradeKDFUser != "user-1" || u.upgradeKDF != cryptox.DefaultKDFParams || string(resp.ServerProof) != "m2" {
+		t.Fatalf("unexpected upgrade: %q, %+v, %+v", u.upgradeKDFUser, u.upgradeKDF, resp)
+	}
+
+	cases := []struct {
+		err  error
+		want codes.Code
+	}{
+		{common.ErrorUnauthorized, codes.Unauthenticated},
+		{common.ErrorForbidden, codes.PermissionDenied},
+		{errors.New("boom"), codes.Internal},
+	}
+	for _, c := range cases {
+		_, err := newServer(&fakeUser{upgradeKDFErr: c.err}, &fakeEntry{}).UpgradeKDF(ctx, &pb.UpgradeKDFRequest{})
+		if status.Code(err) != c.want {
+			t.Fatalf("%v: want %v, got %v", c.err, c.want, status.Code(err))
+		}
+	}
+}
+
 func TestGetPresignedGetUrl_OK_and_Error(t *testing.T) {
+	ctx := context.WithValue(context.Background(), UserIDKey, "user-1")
+
 	e := &fakeEntry{url: "http://ok"}
 	s := newServer(&fakeUser{}, e)
-	resp, err := s.GetPresignedGetUrl(context.Background(), &pb.GetPresignedGetUrlRequest{EntryId: "e"})
+	resp, err := s.GetPresignedGetUrl(ctx, &pb.GetPresignedGetUrlRequest{EntryId: "e"})
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -306,10 +889,73 @@ func TestGetPresignedGetUrl_OK_and_Error(t *testing.T) {
 
 	e2 := &fakeEntry{urlErr: errors.New("x")}
 	s2 := newServer(&fakeUser{}, e2)
-	_, err = s2.GetPresignedGetUrl(context.Background(), &pb.GetPresignedGetUrlRequest{EntryId: "e"})
+	_, err = s2.GetPresignedGetUrl(ctx, &pb.GetPresignedGetUrlRequest{EntryId: "e"})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("want Internal, got %v", status.Code(err))
+	}
+}
+
+func TestFileAccess_MapsOwnershipErrors(t *testing.T) {
+	ctx := context.WithValue(context.Background(), UserIDKey, "user-1")
+
+	cases := []struct {
+		err  error
+		want codes.Code
+	}{
+		{common.ErrorNotFound, codes.NotFound},
+		{common.ErrorForbidden, codes.PermissionDenied},
+	}
+	for _, c := range cases {
+		s := newServer(&fakeUser{}, &fakeEntry{markErr: c.err, urlErr: c.err})
+
+		_, err := s.MarkUploaded(ctx, &pb.MarkUploadedRequest{EntryId: "e"})
+		if status.Code(err) != c.want {
+			t.Fatalf("MarkUploaded: want %v, got %v", c.want, status.Code(err))
+		}
+		_, err = s.GetPresignedGetUrl(ctx, &pb.GetPresignedGetUrlRequest{EntryId: "e"})
+		if status.Code(err) != c.want {
+			t.Fatalf("GetPresignedGetUrl: want %v, got %v", c.want, status.Code(err))
+		}
+	}
+}
+
+func TestRevisions_MapsEntries(t *testing.T) {
+	ctx := context.WithValue(context.Background(), UserIDKey, "user-1")
+	at := time.Unix(1700000000, 0)
+
+	e := &fakeEntry{revs: []*models.Entry{
+		{ID: "e", Version: 3, Details: []byte("d3"), CreatedAt: at},
+		{ID: "e", Version: 1, Deleted: true, CreatedAt: at},
+	}}
+	s := newServer(&fakeUser{}, e)
+
+	list, err := s.ListRevisions(ctx, &pb.ListRevisionsRequest{EntryId: "e"})
+	if err != nil {
+		t.Fatalf("ListRevisions error: %v", err)
+	}
+	if len(list.Revisions) != 2 || list.Revisions[0].Version != 3 || string(list.Revisions[0].Details) != "d3" ||
+		list.Revisions[0].CreatedAt != at.Unix() || !list.Revisions[1].Deleted {
+		t.Fatalf("unexpected revisions: %+v", list.Revisions)
+	}
+
+	one, err := s.GetRevision(ctx, &pb.GetRevisionRequest{EntryId: "e", Version: 1})
+	if err != nil || one.Revision.Version != 1 || one.Revision.EntryId != "e" {
+		t.Fatalf("GetRevision: %v %+v", err, one)
+	}
+
+	_, err = s.GetRevision(ctx, &pb.GetRevisionRequest{EntryId: "e", Version: 2})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("want NotFound, got %v", status.Code(err))
+	}
+
+	_, err = newServer(&fakeUser{}, &fakeEntry{revErr: errors.New("x")}).ListRevisions(ctx, &pb.ListRevisionsRequest{EntryId: "e"})
 	if status.Code(err) != codes.Internal {
 		t.Fatalf("want Internal, got %v", status.Code(err))
 	}
+	_, err = s.ListRevisions(context.Background(), &pb.ListRevisionsRequest{EntryId: "e"})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("missing user: want Internal, got %v", status.Code(err))
+	}
 }
 
 func TestTimeoutGuard(t *testing.T) {
@@ -318,3 +964,55 @@ func TestTimeoutGuard(t *testing.T) {
 	defer cancel()
 	_ = ctx
 }
+
+func TestSessions_UseCallerAndMapErrors(t *testing.T) {
+	ctx := context.WithValue(context.Background(), UserIDKey, "user-1")
+	ctx = context.WithValue(ctx, SessionIDKey, "s1")
+
+	now := time.Unix(1700000000, 0)
+	u := &fakeUser{
+		sessions: []*models.Session{{
+			ID:         "s1",
+			Device:     models.Device{Name: "laptop", Platform: "linux/amd64", ClientVersion: "1.2.0", IP: "10.0.0.1"},
+			CreatedAt:  now.Add(-time.Hour),
+			LastUsedAt: now,
+			Expires:    now.Add(time.Hour),
+			Current:    true,
+		}},
+		revokedOthers: 2,
+	}
+	s := newServer(u, &fakeEntry{})
+
+	list, err := s.ListSessions(ctx, &pb.ListSessionsRequest{})
+	if err != nil || len(list.Sessions) != 1 || u.currentSession != "s1" {
+		t.Fatalf("ListSessions: %+v, %v", list, err)
+	}
+	got := list.Sessions[0]
+	if got.Id != "s1" || got.Device.GetName() != "laptop" || got.Device.GetPlatform() != "linux/amd64" || got.Device.GetClientVersion() != "1.2.0" ||
+		got.Ip != "10.0.0.1" || got.LastUsedAt != now.Unix() || got.CreatedAt != now.Add(-time.Hour).Unix() || !got.Current {
+		t.Fatalf("unexpected session: %+v", got)
+	}
+
+	if _, err := s.RevokeSession(ctx, &pb.RevokeSessionRequest{SessionId: "s2"}); err != nil || u.revokedUser != "user-1" || u.revokedSession != "s2" {
+		t.Fatalf("RevokeSession: %v, %q/%q", err, u.revokedUser, u.revokedSession)
+	}
+	others, err := s.RevokeAllOtherSessions(ctx, &pb.RevokeAllOtherSessionsRequest{})
+	if err != nil || others.Revoked != 2 || u.currentSession != "s1" {
+		t.Fatalf("RevokeAllOtherSessions: %+v, %v", others, err)
+	}
+
+	nf := newServer(&fakeUser{sessionErr: common.ErrorNotFound}, &fakeEntry{})
+	if _, err := nf.RevokeSession(ctx, &pb.RevokeSessionRequest{SessionId: "x"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("want NotFound, got %v", status.Code(err))
+	}
+	boom := newServer(&fakeUser{sessionErr: errors.New("boom")}, &fakeEntry{})
+	if _, err := boom.ListSessions(ctx, &pb.ListSessionsRequest{}); status.Code(err) != codes.Internal {
+		t.Fatalf("want Internal, got %v", status.Code(err))
+	}
+	if _, err := boom.RevokeSession(ctx, &pb.RevokeSessionRequest{SessionId: "x"}); status.Code(err) != codes.Internal {
+		t.Fatalf("want Internal, got %v", status.Code(err))
+	}
+	if _, err := boom.RevokeAllOtherSessions(ctx, &pb.RevokeAllOtherSessionsRequest{}); status.Code(err) != codes.Internal {
+		t.Fatalf("want Internal, got %v", status.Code(err))
+	}
+}
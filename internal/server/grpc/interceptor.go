@@ -4,8 +4,13 @@ package grpc
 
 import (
 	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	pb "github.com/dmitrijs2005/gophkeeper/internal/proto"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/auth"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -13,42 +18,224 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+// authPolicy describes how a single RPC must be authenticated.
+type authPolicy int
+
+const (
+	// policyAuthenticated requires a valid access token of a session that has
+	// not been revoked; the caller's user ID and session ID are injected into
+	// the request context under UserIDKey and SessionIDKey.
+	policyAuthenticated authPolicy = iota
+	// policyPublic allows the call without an access token.
+	policyPublic
+)
+
+// serviceMethodPrefix is the full-method prefix shared by all GophKeeper RPCs.
+const serviceMethodPrefix = "/gophkeeper.service.GophKeeperService/"
+
+// methodPolicies declares the authentication policy of every GophKeeper RPC.
+//
+// Methods of the GophKeeper service that are missing from this table are
+// treated as policyAuthenticated, so a newly added RPC is protected unless it
+// is explicitly listed as public here.
+var methodPolicies = map[string]authPolicy{
+	pb.GophKeeperService_RegisterUser_FullMethodName:            policyPublic,
+	pb.GophKeeperService_GetSalt_FullMethodName:                 policyPublic,
+	pb.GophKeeperService_Login_FullMethodName:                   policyPublic,
+	pb.GophKeeperService_LoginStart_FullMethodName:              policyPublic,
+	pb.GophKeeperService_LoginFinish_FullMethodName:             policyPublic,
+	pb.GophKeeperService_Verify2FA_FullMethodName:               policyPublic,
+	pb.GophKeeperService_Ping_FullMethodName:                    policyPublic,
+	pb.GophKeeperService_RefreshToken_FullMethodName:            policyPublic,
+	pb.GophKeeperService_Sync_FullMethodName:                    policyAuthenticated,
+	pb.GophKeeperService_MarkUploaded_FullMethodName:            policyAuthenticated,
+	pb.GophKeeperService_GetPresignedGetUrl_FullMethodName:      policyAuthenticated,
+	pb.GophKeeperService_ListRevisions_FullMethodName:           policyAuthenticated,
+	pb.GophKeeperService_GetRevision_FullMethodName:             policyAuthenticated,
+	pb.GophKeeperService_UpdateFileKey_FullMethodName:           policyAuthenticated,
+	pb.GophKeeperService_UpgradeKeys_FullMethodName:             policyAuthenticated,
+	pb.GophKeeperService_ChangePassword_FullMethodName:          policyAuthenticated,
+	pb.GophKeeperService_UpgradeKDF_FullMethodName:              policyAuthenticated,
+	pb.GophKeeperService_StartMultipartUpload_FullMethodName:    policyAuthenticated,
+	pb.GophKeeperService_PresignUploadParts_FullMethodName:      policyAuthenticated,
+	pb.GophKeeperService_CompleteMultipartUpload_FullMethodName: policyAuthenticated,
+	pb.GophKeeperService_AbortMultipartUpload_FullMethodName:    policyAuthenticated,
+	pb.GophKeeperService_RequestUploadURL_FullMethodName:        policyAuthenticated,
+	pb.GophKeeperService_ListSessions_FullMethodName:            policyAuthenticated,
+	pb.GophKeeperService_RevokeSession_FullMethodName:           policyAuthenticated,
+	pb.GophKeeperService_RevokeAllOtherSessions_FullMethodName:  policyAuthenticated,
+	pb.GophKeeperService_Logout_FullMethodName:                  policyAuthenticated,
+	pb.GophKeeperService_Enable2FA_FullMethodName:               policyAuthenticated,
+	pb.GophKeeperService_Confirm2FA_FullMethodName:              policyAuthenticated,
+}
+
+// policyFor returns the authentication policy for the given full method name.
+// Methods outside of the GophKeeper service (e.g., health checks) are public.
+func policyFor(fullMethod string) authPolicy {
+	if p, ok := methodPolicies[fullMethod]; ok {
+		return p
+	}
+	if strings.HasPrefix(fullMethod, serviceMethodPrefix) {
+		return policyAuthenticated
+	}
+	return policyPublic
+}
+
 // accessTokenInterceptor is a unary server interceptor that enforces access-token
-// authentication for selected methods and injects the authenticated user ID into
-// the request context.
+// authentication according to methodPolicies and injects the authenticated user
+// ID into the request context.
 //
-// Current behavior:
-//   - Only the Sync RPC ("/gophkeeper.service.GophKeeperService/Sync") is protected.
+// Behavior for authenticated methods:
 //   - The interceptor looks for the access token in gRPC metadata under
 //     common.AccessTokenHeaderName.
-//   - On success, it parses the token, extracts the user ID, and stores it in
-//     the context under UserIDKey, then calls the handler.
-//   - On failure, it returns codes.Unauthenticated.
-//
-// Note: If you add more authenticated methods, extend the method check accordingly.
+//   - On success, it parses the token, checks that its session has not been
+//     revoked, stores the user ID and session ID in the context under
+//     UserIDKey and SessionIDKey, then calls the handler.
+//   - On failure, it returns codes.Unauthenticated. Tokens minted before
+//     sessions were tracked are reported as expired, so that clients refresh
+//     them into ones bound to a session.
 func (s *GRPCServer) accessTokenInterceptor(
 	ctx context.Context,
 	req any,
 	info *grpc.UnaryServerInfo,
 	handler grpc.UnaryHandler,
 ) (any, error) {
-	if info.FullMethod == "/gophkeeper.service.GophKeeperService/Sync" {
-		var accessToken string
-		if md, ok := metadata.FromIncomingContext(ctx); ok {
-			values := md.Get(common.AccessTokenHeaderName)
-			if len(values) > 0 {
-				accessToken = values[0]
-			}
-		}
-		if len(accessToken) == 0 {
-			return nil, status.Error(codes.Unauthenticated, "missing token")
+	if policyFor(info.FullMethod) == policyPublic {
+		return handler(ctx, req)
+	}
+
+	var accessToken string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		values := md.Get(common.AccessTokenHeaderName)
+		if len(values) > 0 {
+			accessToken = values[0]
 		}
+	}
 
-		userID, err := auth.GetUserIDFromToken(accessToken, s.jwtSecret)
-		if err != nil {
+	if len(accessToken) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing token")
+	}
+
+	claims, err := auth.ParseToken(accessToken, s.jwtSecret)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if claims.SessionID == "" {
+		return nil, status.Error(codes.Unauthenticated, common.ErrTokenExpired.Error())
+	}
+	if err := s.users.CheckSession(ctx, claims.SessionID); err != nil {
+		if errors.Is(err, common.ErrSessionRevoked) {
 			return nil, status.Error(codes.Unauthenticated, err.Error())
 		}
-		ctx = context.WithValue(ctx, UserIDKey, userID)
+		return nil, status.Error(codes.Internal, "internal error")
 	}
+
+	ctx = context.WithValue(ctx, UserIDKey, claims.UserID)
+	ctx = context.WithValue(ctx, SessionIDKey, claims.SessionID)
 	return handler(ctx, req)
 }
+
+// rateLimiter throttles the RPCs in rateLimitedMethods, see
+// services.RateLimiter.
+type rateLimiter interface {
+	Allow(ctx context.Context, ip string, username string) (time.Duration, error)
+	Fail(ctx context.Context, ip string, username string) error
+	Succeed(ctx context.Context, username string) error
+}
+
+// rateLimitedMethods are the RPCs that take a password or code guess or
+// probe an account, which rateLimitInterceptor throttles.
+var rateLimitedMethods = map[string]bool{
+	pb.GophKeeperService_RegisterUser_FullMethodName: true,
+	pb.GophKeeperService_GetSalt_FullMethodName:      true,
+	pb.GophKeeperService_Login_FullMethodName:        true,
+	pb.GophKeeperService_LoginStart_FullMethodName:   true,
+	pb.GophKeeperService_LoginFinish_FullMethodName:  true,
+	pb.GophKeeperService_Verify2FA_FullMethodName:    true,
+	pb.GophKeeperService_Enable2FA_FullMethodName:    true,
+}
+
+// loginSubjectKey holds the *string the LoginFinish, Verify2FA and Enable2FA
+// handlers set to the account of the login or code, as their requests have
+// no username.
+const loginSubjectKey ctxKey = "loginSubject"
+
+// setLoginSubject records the account of a login for rateLimitInterceptor.
+func setLoginSubject(ctx context.Context, username string) {
+	if p, ok := ctx.Value(loginSubjectKey).(*string); ok {
+		*p = username
+	}
+}
+
+// rateLimitInterceptor is a unary server interceptor that throttles the
+// methods in rateLimitedMethods per client IP and per username, if the
+// request has one.
+//
+//   - A throttled or locked out call fails with codes.ResourceExhausted and
+//     the seconds to wait in the common.RetryAfterTrailerName trailer,
+//     without reaching the handler.
+//   - A call that fails with codes.Unauthenticated counts towards the lockout
+//     of the IP and of the account. For authenticated methods only failures
+//     the handler attributed to an account count, not rejected access
+//     tokens, which the next interceptor reports the same way.
+//   - A successful login resets the lockout of the account; a correct
+//     password of an account with two-factor authentication does not.
+func (s *GRPCServer) rateLimitInterceptor(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	if s.limiter == nil || !rateLimitedMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	ip := peerIP(ctx)
+	var username string
+	if r, ok := req.(interface{ GetUsername() string }); ok {
+		username = r.GetUsername()
+	}
+
+	wait, err := s.limiter.Allow(ctx, ip, username)
+	if err != nil {
+		s.logger.Error(ctx, "Rate limit check failed", "error", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	if wait > 0 {
+		seconds := strconv.Itoa(int(wait / time.Second))
+		_ = grpc.SetTrailer(ctx, metadata.Pairs(common.RetryAfterTrailerName, seconds))
+		s.logger.Warn(ctx, "Rate limited", "method", info.FullMethod, "ip", ip, "username", username, "retry_after", seconds)
+		return nil, status.Errorf(codes.ResourceExhausted, "too many attempts, retry in %s seconds", seconds)
+	}
+
+	if username == "" {
+		ctx = context.WithValue(ctx, loginSubjectKey, &username)
+	}
+	resp, err := handler(ctx, req)
+
+	switch {
+	case status.Code(err) == codes.Unauthenticated && (policyFor(info.FullMethod) == policyPublic || username != ""):
+		if ferr := s.limiter.Fail(ctx, ip, username); ferr != nil {
+			s.logger.Error(ctx, "Recording a failed login failed", "error", ferr)
+		}
+	case err == nil && loggedIn(resp):
+		if serr := s.limiter.Succeed(ctx, username); serr != nil {
+			s.logger.Error(ctx, "Recording a successful login failed", "error", serr)
+		}
+	}
+	return resp, err
+}
+
+// loggedIn reports whether resp is that of a completed login. A
+// LoginFinish that asks for a second factor is not: resetting the lockout
+// there would let whoever knows the password guess codes indefinitely.
+func loggedIn(resp any) bool {
+	switch r := resp.(type) {
+	case *pb.LoginResponse, *pb.Verify2FAResponse:
+		return true
+	case *pb.LoginFinishResponse:
+		return r.TwoFactorChallenge == ""
+	default:
+		return false
+	}
+}
@@ -2,15 +2,19 @@ package grpc
 
 import (
 	"context"
+	"errors"
+	"net"
 	"testing"
 	"time"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	pb "github.com/dmitrijs2005/gophkeeper/internal/proto"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/auth"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/services"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
@@ -21,11 +25,19 @@ func newTestServer(secret string) *GRPCServer {
 	return &GRPCServer{
 		logger:    nopLogger{},
 		jwtSecret: []byte(secret),
-		users:     (*services.UserService)(nil),
+		users:     &fakeUser{},
 		entries:   (*services.EntryService)(nil),
 	}
 }
 
+// withToken returns a context carrying token as the access token.
+func withToken(token string) context.Context {
+	md := metadata.New(map[string]string{
+		common.AccessTokenHeaderName: token,
+	})
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
 func TestInterceptor_NonSync_AllowsWithoutToken(t *testing.T) {
 	s := newTestServer("secret")
 
@@ -101,7 +113,7 @@ func TestInterceptor_Sync_ValidToken_SetsUserID(t *testing.T) {
 	s := newTestServer(secret)
 
 	userID := "user-123"
-	token, err := auth.GenerateToken(userID, []byte(secret), time.Hour)
+	token, err := auth.GenerateToken(userID, "s1", []byte(secret), time.Hour)
 	if err != nil {
 		t.Fatalf("GenerateToken error: %v", err)
 	}
@@ -112,9 +124,10 @@ func TestInterceptor_Sync_ValidToken_SetsUserID(t *testing.T) {
 	ctx := metadata.NewIncomingContext(context.Background(), md)
 	info := &grpc.UnaryServerInfo{FullMethod: "/gophkeeper.service.GophKeeperService/Sync"}
 
-	var gotFromCtx any
+	var gotFromCtx, gotSession any
 	h := func(ctx context.Context, req interface{}) (interface{}, error) {
 		gotFromCtx = ctx.Value(UserIDKey)
+		gotSession = ctx.Value(SessionIDKey)
 		return "ok", nil
 	}
 
@@ -128,4 +141,294 @@ func TestInterceptor_Sync_ValidToken_SetsUserID(t *testing.T) {
 	if gotFromCtx != userID {
 		t.Fatalf("user id not propagated in context: got %v want %v", gotFromCtx, userID)
 	}
+	if gotSession != "s1" {
+		t.Fatalf("session id not propagated in context: got %v", gotSession)
+	}
+}
+
+func TestInterceptor_Sync_Sessions(t *testing.T) {
+	secret := "super-secret"
+	info := &grpc.UnaryServerInfo{FullMethod: "/gophkeeper.service.GophKeeperService/Sync"}
+	h := func(ctx context.Context, req interface{}) (interface{}, error) {
+		t.Fatal("handler should not be called")
+		return nil, nil
+	}
+
+	// a revoked session rejects its access tokens before they expire
+	s := newTestServer(secret)
+	s.users = &fakeUser{checkErr: common.ErrSessionRevoked}
+	token, _ := auth.GenerateToken("u1", "s1", []byte(secret), time.Hour)
+	_, err := s.accessTokenInterceptor(withToken(token), nil, info, h)
+	if status.Code(err) != codes.Unauthenticated || status.Convert(err).Message() != common.ErrSessionRevoked.Error() {
+		t.Fatalf("revoked session: got %v", err)
+	}
+
+	s.users = &fakeUser{checkErr: common.ErrorInternal}
+	if _, err := s.accessTokenInterceptor(withToken(token), nil, info, h); status.Code(err) != codes.Internal {
+		t.Fatalf("failed check: want Internal, got %v", status.Code(err))
+	}
+
+	// tokens minted before sessions were tracked have to be refreshed
+	legacy, _ := auth.GenerateToken("u1", "", []byte(secret), time.Hour)
+	_, err = s.accessTokenInterceptor(withToken(legacy), nil, info, h)
+	if status.Code(err) != codes.Unauthenticated || status.Convert(err).Message() != common.ErrTokenExpired.Error() {
+		t.Fatalf("token without session: got %v", err)
+	}
+}
+
+func TestInterceptor_ProtectedMethods_RequireToken(t *testing.T) {
+	s := newTestServer("secret")
+
+	for _, m := range []string{
+		"/gophkeeper.service.GophKeeperService/MarkUploaded",
+		"/gophkeeper.service.GophKeeperService/GetPresignedGetUrl",
+		"/gophkeeper.service.GophKeeperService/ListRevisions",
+		"/gophkeeper.service.GophKeeperService/GetRevision",
+		"/gophkeeper.service.GophKeeperService/UpdateFileKey",
+		"/gophkeeper.service.GophKeeperService/UpgradeKeys",
+		"/gophkeeper.service.GophKeeperService/ChangePassword",
+		"/gophkeeper.service.GophKeeperService/UpgradeKDF",
+		"/gophkeeper.service.GophKeeperService/StartMultipartUpload",
+		"/gophkeeper.service.GophKeeperService/PresignUploadParts",
+		"/gophkeeper.service.GophKeeperService/CompleteMultipartUpload",
+		"/gophkeeper.service.GophKeeperService/AbortMultipartUpload",
+		"/gophkeeper.service.GophKeeperService/RequestUploadURL",
+		"/gophkeeper.service.GophKeeperService/ListSessions",
+		"/gophkeeper.service.GophKeeperService/RevokeSession",
+		"/gophkeeper.service.GophKeeperService/RevokeAllOtherSessions",
+		"/gophkeeper.service.GophKeeperService/Logout",
+		"/gophkeeper.service.GophKeeperService/Enable2FA",
+		"/gophkeeper.service.GophKeeperService/Confirm2FA",
+		"/gophkeeper.service.GophKeeperService/SomeFutureMethod",
+	} {
+		info := &grpc.UnaryServerInfo{FullMethod: m}
+		h := func(ctx context.Context, req interface{}) (interface{}, error) {
+			t.Fatalf("handler should not be called without token for %s", m)
+			return nil, nil
+		}
+
+		_, err := s.accessTokenInterceptor(context.Background(), nil, info, h)
+		if status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("%s: expected Unauthenticated, got %v", m, status.Code(err))
+		}
+	}
+}
+
+func TestInterceptor_PublicMethods_AllowWithoutToken(t *testing.T) {
+	s := newTestServer("secret")
+
+	for _, m := range []string{
+		"/gophkeeper.service.GophKeeperService/RegisterUser",
+		"/gophkeeper.service.GophKeeperService/GetSalt",
+		"/gophkeeper.service.GophKeeperService/Login",
+		"/gophkeeper.service.GophKeeperService/LoginStart",
+		"/gophkeeper.service.GophKeeperService/LoginFinish",
+		"/gophkeeper.service.GophKeeperService/Verify2FA",
+		"/gophkeeper.service.GophKeeperService/Ping",
+		"/gophkeeper.service.GophKeeperService/RefreshToken",
+	} {
+		info := &grpc.UnaryServerInfo{FullMethod: m}
+		called := false
+		h := func(ctx context.Context, req interface{}) (interface{}, error) {
+			called = true
+			return "ok", nil
+		}
+
+		if _, err := s.accessTokenInterceptor(context.Background(), nil, info, h); err != nil {
+			t.Fatalf("%s: unexpected error: %v", m, err)
+		}
+		if !called {
+			t.Fatalf("%s: handler was not called", m)
+		}
+	}
+}
+
+// fakeLimiter records the calls of rateLimitInterceptor.
+type fakeLimiter struct {
+	wait     time.Duration
+	allowErr error
+
+	allowed   []string
+	failed    []string
+	succeeded []string
+}
+
+func (f *fakeLimiter) Allow(ctx context.Context, ip string, username string) (time.Duration, error) {
+	f.allowed = append(f.allowed, ip+"/"+username)
+	return f.wait, f.allowErr
+}
+func (f *fakeLimiter) Fail(ctx context.Context, ip string, username string) error {
+	f.failed = append(f.failed, ip+"/"+username)
+	return nil
+}
+func (f *fakeLimiter) Succeed(ctx context.Context, username string) error {
+	f.succeeded = append(f.succeeded, username)
+	return nil
+}
+
+// trailerStream captures the trailer a handler sets.
+type trailerStream struct {
+	trailer metadata.MD
+}
+
+func (s *trailerStream) Method() string                  { return "" }
+func (s *trailerStream) SetHeader(metadata.MD) error     { return nil }
+func (s *trailerStream) SendHeader(metadata.MD) error    { return nil }
+func (s *trailerStream) SetTrailer(md metadata.MD) error { s.trailer = md; return nil }
+
+// fromPeer returns a context of a call from 10.0.0.1.
+func fromPeer() context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5555}})
+}
+
+func TestRateLimitInterceptor_Throttled(t *testing.T) {
+	l := &fakeLimiter{wait: 42 * time.Second}
+	s := newTestServer("secret")
+	s.limiter = l
+
+	stream := &trailerStream{}
+	ctx := grpc.NewContextWithServerTransportStream(fromPeer(), stream)
+	info := &grpc.UnaryServerInfo{FullMethod: pb.GophKeeperService_GetSalt_FullMethodName}
+	h := func(ctx context.Context, req interface{}) (interface{}, error) {
+		t.Fatal("handler should not be called when throttled")
+		return nil, nil
+	}
+
+	_, err := s.rateLimitInterceptor(ctx, &pb.GetSaltRequest{Username: "alice"}, info, h)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+	if got := stream.trailer.Get(common.RetryAfterTrailerName); len(got) != 1 || got[0] != "42" {
+		t.Fatalf("retry-after trailer: %v", stream.trailer)
+	}
+	if len(l.allowed) != 1 || l.allowed[0] != "10.0.0.1/alice" {
+		t.Fatalf("allowed: %v", l.allowed)
+	}
+
+	l.wait, l.allowErr = 0, errors.New("db down")
+	if _, err := s.rateLimitInterceptor(ctx, &pb.GetSaltRequest{Username: "alice"}, info, h); status.Code(err) != codes.Internal {
+		t.Fatalf("expected Internal, got %v", err)
+	}
+}
+
+func TestRateLimitInterceptor_FailAndSucceed(t *testing.T) {
+	l := &fakeLimiter{}
+	s := newServer(&fakeUser{loginErr: &services.LoginError{UserName: "alice"}}, &fakeEntry{})
+	s.limiter = l
+
+	// LoginFinish has no username: the failure is attributed to the account
+	// of the session
+	info := &grpc.UnaryServerInfo{FullMethod: pb.GophKeeperService_LoginFinish_FullMethodName}
+	h := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return s.LoginFinish(ctx, req.(*pb.LoginFinishRequest))
+	}
+	if _, err := s.rateLimitInterceptor(fromPeer(), &pb.LoginFinishRequest{SessionId: "s1"}, info, h); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+	if len(l.allowed) != 1 || l.allowed[0] != "10.0.0.1/" || len(l.failed) != 1 || l.failed[0] != "10.0.0.1/alice" {
+		t.Fatalf("allowed %v, failed %v", l.allowed, l.failed)
+	}
+
+	s.users = &fakeUser{loginResp: &services.TokenPair{AccessToken: "A"}, loginUser: "alice"}
+	if _, err := s.rateLimitInterceptor(fromPeer(), &pb.LoginFinishRequest{SessionId: "s2"}, info, h); err != nil {
+		t.Fatalf("LoginFinish: %v", err)
+	}
+	if len(l.succeeded) != 1 || l.succeeded[0] != "alice" {
+		t.Fatalf("succeeded: %v", l.succeeded)
+	}
+
+	// a correct password does not reset the lockout of an account with
+	// two-factor authentication, the second factor does
+	s.users = &fakeUser{loginResp: &services.TokenPair{AccessToken: "A"}, loginUser: "bob", twoFactorChallenge: "c1"}
+	if _, err := s.rateLimitInterceptor(fromPeer(), &pb.LoginFinishRequest{SessionId: "s3"}, info, h); err != nil {
+		t.Fatalf("LoginFinish: %v", err)
+	}
+	if len(l.succeeded) != 1 {
+		t.Fatalf("succeeded before the second factor: %v", l.succeeded)
+	}
+	info = &grpc.UnaryServerInfo{FullMethod: pb.GophKeeperService_Verify2FA_FullMethodName}
+	verify := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return s.Verify2FA(ctx, req.(*pb.Verify2FARequest))
+	}
+	s.users = &fakeUser{twoFactorErr: &services.LoginError{UserName: "bob"}}
+	if _, err := s.rateLimitInterceptor(fromPeer(), &pb.Verify2FARequest{ChallengeId: "c1"}, info, verify); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+	if len(l.failed) != 2 || l.failed[1] != "10.0.0.1/bob" {
+		t.Fatalf("failed: %v", l.failed)
+	}
+	s.users = &fakeUser{loginResp: &services.TokenPair{AccessToken: "A"}, loginUser: "bob"}
+	if _, err := s.rateLimitInterceptor(fromPeer(), &pb.Verify2FARequest{ChallengeId: "c1"}, info, verify); err != nil {
+		t.Fatalf("Verify2FA: %v", err)
+	}
+	if len(l.succeeded) != 2 || l.succeeded[1] != "bob" {
+		t.Fatalf("succeeded: %v", l.succeeded)
+	}
+
+	// other calls are neither attributed nor counted as logins
+	info = &grpc.UnaryServerInfo{FullMethod: pb.GophKeeperService_GetSalt_FullMethodName}
+	ok := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+	if _, err := s.rateLimitInterceptor(fromPeer(), &pb.GetSaltRequest{Username: "bob"}, info, ok); err != nil {
+		t.Fatalf("GetSalt: %v", err)
+	}
+	if len(l.failed) != 2 || len(l.succeeded) != 2 {
+		t.Fatalf("failed %v, succeeded %v", l.failed, l.succeeded)
+	}
+}
+
+func TestRateLimitInterceptor_Enable2FA(t *testing.T) {
+	l := &fakeLimiter{}
+	s := newServer(&fakeUser{twoFactorErr: &services.LoginError{UserName: "alice"}}, &fakeEntry{})
+	s.limiter = l
+	info := &grpc.UnaryServerInfo{FullMethod: pb.GophKeeperService_Enable2FA_FullMethodName}
+
+	// a rejected access token is not a guess
+	rejected := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.Unauthenticated, common.ErrTokenExpired.Error())
+	}
+	if _, err := s.rateLimitInterceptor(fromPeer(), &pb.Enable2FARequest{}, info, rejected); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+	if len(l.allowed) != 1 || len(l.failed) != 0 {
+		t.Fatalf("allowed %v, failed %v", l.allowed, l.failed)
+	}
+
+	// a wrong code of the second factor is, and is attributed to the account
+	ctx := context.WithValue(fromPeer(), UserIDKey, "user-1")
+	enable := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return s.Enable2FA(ctx, req.(*pb.Enable2FARequest))
+	}
+	if _, err := s.rateLimitInterceptor(ctx, &pb.Enable2FARequest{Code: "123456"}, info, enable); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+	if len(l.failed) != 1 || l.failed[0] != "10.0.0.1/alice" {
+		t.Fatalf("failed: %v", l.failed)
+	}
+}
+
+func TestRateLimitInterceptor_Unlimited(t *testing.T) {
+	l := &fakeLimiter{wait: time.Minute}
+	s := newTestServer("secret")
+	s.limiter = l
+
+	ok := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+	for _, m := range []string{
+		pb.GophKeeperService_Ping_FullMethodName,
+		pb.GophKeeperService_RefreshToken_FullMethodName,
+		pb.GophKeeperService_Sync_FullMethodName,
+	} {
+		if _, err := s.rateLimitInterceptor(fromPeer(), nil, &grpc.UnaryServerInfo{FullMethod: m}, ok); err != nil {
+			t.Fatalf("%s: unexpected error: %v", m, err)
+		}
+	}
+	if len(l.allowed) != 0 {
+		t.Fatalf("unlimited methods checked: %v", l.allowed)
+	}
+
+	// without a limiter nothing is throttled
+	s.limiter = nil
+	info := &grpc.UnaryServerInfo{FullMethod: pb.GophKeeperService_Login_FullMethodName}
+	if _, err := s.rateLimitInterceptor(fromPeer(), &pb.LoginRequest{Username: "alice"}, info, ok); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 }
@@ -5,6 +5,7 @@ package grpc
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/logging"
@@ -12,29 +13,70 @@ import (
 	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/services"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 type ctxKey string
 
 const UserIDKey ctxKey = "userID"
 
+// SessionIDKey holds the session of the caller's access token.
+const SessionIDKey ctxKey = "sessionID"
+
 // userSvc is the subset of user service methods required by the transport.
 type userSvc interface {
-	RefreshToken(ctx context.Context, refresh string) (*services.TokenPair, error)
-	Register(ctx context.Context, username string, salt []byte, verifier []byte) (*models.User, error)
-	GetSalt(ctx context.Context, username string) ([]byte, error)
-	Login(ctx context.Context, username string, verifierCandidate []byte) (*services.TokenPair, error)
+	RefreshToken(ctx context.Context, refresh string, ip string) (*services.TokenPair, error)
+	Register(ctx context.Context, username string, c models.Credentials) (*models.User, error)
+	GetSalt(ctx context.Context, username string) (*services.SaltInfo, error)
+	Login(ctx context.Context, username string, verifierCandidate, srpVerifier, wrappedVaultKey []byte, device models.Device) (*services.TokenPair, error)
+	LoginStart(ctx context.Context, username string, clientPublic []byte) (*services.LoginChallenge, error)
+	LoginFinish(ctx context.Context, sessionID string, clientProof []byte, device models.Device) (*services.LoginResult, error)
+	Verify2FA(ctx context.Context, challengeID string, code string, device models.Device) (*services.LoginResult, error)
+	Enable2FA(ctx context.Context, userID string, code string) (*services.TwoFactorSetup, error)
+	Confirm2FA(ctx context.Context, userID string, code string) ([]string, error)
+	UpgradeKeys(ctx context.Context, userID string, srpVerifier, wrappedVaultKey []byte) error
+	ChangePassword(ctx context.Context, userID string, sessionID string, clientProof []byte, c models.Credentials, rekey *models.VaultRekey, device models.Device) (*services.LoginResult, error)
+	UpgradeKDF(ctx context.Context, userID string, sessionID string, clientProof []byte, c models.Credentials) ([]byte, error)
+	ListSessions(ctx context.Context, userID string, currentSessionID string) ([]*models.Session, error)
+	RevokeSession(ctx context.Context, userID string, id string) error
+	RevokeOtherSessions(ctx context.Context, userID string, currentSessionID string) (int64, error)
+	Logout(ctx context.Context, userID string, sessionID string) error
+	CheckSession(ctx context.Context, sessionID string) error
 }
 
 // entrySvc is the subset of entry service methods required by the transport.
 type entrySvc interface {
 	// Sync reconciles client changes and returns merged updates plus upload tasks.
+	// Pushes made against a stale base version come back as conflicts.
 	Sync(ctx context.Context, userID string, pendingEntries []*models.Entry, pendingFiles []*models.File,
-		clientMaxVersion int64) (processed []*models.Entry, newEntries []*models.Entry, newFiles []*models.File, uploadTasks []*models.FileUploadTask, globalMaxVersion int64, err error)
-	// MarkUploaded acknowledges completion of a client-side file upload.
-	MarkUploaded(ctx context.Context, entryID string) error
-	// GetPresignedGetURL returns a temporary URL to fetch an encrypted file.
-	GetPresignedGetURL(ctx context.Context, entryID string) (string, error)
+		clientMaxVersion int64) (processed []*models.Entry, conflicts []*models.Entry, newEntries []*models.Entry, newFiles []*models.File, uploadTasks []*models.FileUploadTask, globalMaxVersion int64, err error)
+	// MarkUploaded acknowledges completion of a client-side file upload
+	// for an entry owned by userID.
+	MarkUploaded(ctx context.Context, userID string, entryID string) error
+	// GetPresignedGetURL returns a temporary URL to fetch an encrypted file
+	// of an entry owned by userID.
+	GetPresignedGetURL(ctx context.Context, userID string, entryID string) (string, error)
+	// ListRevisions returns the stored versions of an entry owned by userID,
+	// newest first.
+	ListRevisions(ctx context.Context, userID string, entryID string) ([]*models.Entry, error)
+	// GetRevision returns one stored version of an entry owned by userID.
+	GetRevision(ctx context.Context, userID string, entryID string, version int64) (*models.Entry, error)
+	// UpdateFileKey replaces the wrapped key of the file of an entry owned
+	// by userID.
+	UpdateFileKey(ctx context.Context, userID string, entryID string, key []byte) error
+	// StartMultipartUpload starts, or returns the upload in progress of, a
+	// multipart upload of the pending file of an entry owned by userID.
+	StartMultipartUpload(ctx context.Context, userID string, entryID string) (string, error)
+	// PresignUploadParts returns upload URLs for the given part numbers of a
+	// multipart upload.
+	PresignUploadParts(ctx context.Context, userID string, entryID string, uploadID string, parts []int32) ([]string, error)
+	// CompleteMultipartUpload assembles the uploaded parts into the file.
+	CompleteMultipartUpload(ctx context.Context, userID string, entryID string, uploadID string, parts []models.UploadedPart) error
+	// AbortMultipartUpload discards a multipart upload and its parts.
+	AbortMultipartUpload(ctx context.Context, userID string, entryID string, uploadID string) error
+	// RequestUploadURL returns a fresh upload URL for the pending file of an
+	// entry owned by userID.
+	RequestUploadURL(ctx context.Context, userID string, entryID string) (string, error)
 }
 
 // GRPCServer hosts the GophKeeper gRPC API and delegates to domain services.
@@ -45,18 +87,28 @@ type GRPCServer struct {
 	entries   entrySvc
 	logger    logging.Logger
 	jwtSecret []byte
+	tlsConfig *tls.Config
+	// limiter throttles the authentication RPCs; nil disables throttling.
+	limiter rateLimiter
 }
 
 // NewgGRPCServer constructs a GRPCServer bound to the given address, logger,
-// services, and JWT secret. The name is preserved for compatibility.
-func NewgGRPCServer(a string, l logging.Logger, us *services.UserService, es *services.EntryService, secretKey string) (*GRPCServer, error) {
-	return &GRPCServer{
+// services, JWT secret, TLS configuration and rate limiter; a nil tlsConfig
+// serves plaintext and a nil rl does not throttle. The name is preserved for
+// compatibility.
+func NewgGRPCServer(a string, l logging.Logger, us *services.UserService, es *services.EntryService, secretKey string, tlsConfig *tls.Config, rl *services.RateLimiter) (*GRPCServer, error) {
+	s := &GRPCServer{
 		address:   a,
 		logger:    l.With("module", "grpc_server"),
 		users:     us,
 		entries:   es,
 		jwtSecret: []byte(secretKey),
-	}, nil
+		tlsConfig: tlsConfig,
+	}
+	if rl != nil {
+		s.limiter = rl
+	}
+	return s, nil
 }
 
 // Run starts the gRPC server on the configured address and blocks until the
@@ -68,8 +120,12 @@ func (s *GRPCServer) Run(ctx context.Context) error {
 		return err
 	}
 
-	// Create gRPC server with interceptors (auth, etc.).
-	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(s.accessTokenInterceptor))
+	// Create gRPC server with interceptors (rate limiting, auth).
+	opts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(s.rateLimitInterceptor, s.accessTokenInterceptor)}
+	if s.tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.tlsConfig)))
+	}
+	srv := grpc.NewServer(opts...)
 
 	// Register service implementation.
 	pb.RegisterGophKeeperServiceServer(srv, s)
@@ -81,7 +137,7 @@ func (s *GRPCServer) Run(ctx context.Context) error {
 		srv.GracefulStop()
 	}()
 
-	s.logger.Info(ctx, "Starting gRPC server", "address", s.address)
+	s.logger.Info(ctx, "Starting gRPC server", "address", s.address, "tls", s.tlsConfig != nil)
 
 	// Serve incoming connections.
 	if err := srv.Serve(listen); err != nil {
@@ -20,7 +20,7 @@ func (n nopLogger) With(...any) logging.Logger            { return n }
 func TestRun_StopsOnContextCancel(t *testing.T) {
 	t.Parallel()
 
-	srv, err := NewgGRPCServer("127.0.0.1:0", nopLogger{}, (*services.UserService)(nil), (*services.EntryService)(nil), "secret")
+	srv, err := NewgGRPCServer("127.0.0.1:0", nopLogger{}, (*services.UserService)(nil), (*services.EntryService)(nil), "secret", nil, nil)
 	if err != nil {
 		t.Fatalf("NewgGRPCServer error: %v", err)
 	}
@@ -54,7 +54,7 @@ func TestRun_StopsOnContextCancel(t *testing.T) {
 func TestRun_ReturnsErrorOnBadAddress(t *testing.T) {
 	t.Parallel()
 
-	srv, err := NewgGRPCServer("127.0.0.1:99999", nopLogger{}, (*services.UserService)(nil), (*services.EntryService)(nil), "secret")
+	srv, err := NewgGRPCServer("127.0.0.1:99999", nopLogger{}, (*services.UserService)(nil), (*services.EntryService)(nil), "secret", nil, nil)
 	if err != nil {
 		t.Fatalf("NewgGRPCServer error (constructor should not fail here): %v", err)
 	}
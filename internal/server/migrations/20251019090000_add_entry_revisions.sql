@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE entry_revisions (
+    entry_id        UUID NOT NULL REFERENCES entries(id) ON DELETE CASCADE,
+    user_id         UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    version         BIGINT NOT NULL,
+    overview        BYTEA NOT NULL,
+    nonce_overview  BYTEA NOT NULL,
+    details         BYTEA NOT NULL,
+    nonce_details   BYTEA NOT NULL,
+    deleted         BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (entry_id, version)
+);
+
+INSERT INTO entry_revisions (entry_id, user_id, version, overview, nonce_overview, details, nonce_details, deleted, created_at)
+SELECT id, user_id, version, overview, nonce_overview, details, nonce_details, deleted, COALESCE(updated_at, CURRENT_TIMESTAMP)
+FROM entries;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE entry_revisions;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE entries ADD COLUMN deleted_at TIMESTAMP;
+UPDATE entries SET deleted_at = now() WHERE deleted;
+CREATE INDEX entries_deleted_at_idx ON entries (deleted_at) WHERE deleted;
+ALTER TABLE users ADD COLUMN purged_version BIGINT NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN purged_version;
+DROP INDEX entries_deleted_at_idx;
+ALTER TABLE entries DROP COLUMN deleted_at;
+-- +goose StatementEnd
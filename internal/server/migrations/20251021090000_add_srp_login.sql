@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN srp_verifier BYTEA;
+ALTER TABLE users ALTER COLUMN master_key_verifier DROP NOT NULL;
+CREATE TABLE IF NOT EXISTS login_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_public BYTEA NOT NULL,
+    server_secret BYTEA NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE login_sessions;
+-- master_key_verifier stays nullable: upgraded accounts no longer have one.
+ALTER TABLE users DROP COLUMN srp_verifier;
+-- +goose StatementEnd
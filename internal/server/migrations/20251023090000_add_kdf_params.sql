@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN kdf_algorithm TEXT NOT NULL DEFAULT 'argon2id',
+    ADD COLUMN kdf_time INTEGER NOT NULL DEFAULT 1,
+    ADD COLUMN kdf_memory INTEGER NOT NULL DEFAULT 65536,
+    ADD COLUMN kdf_threads SMALLINT NOT NULL DEFAULT 4;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+    DROP COLUMN kdf_algorithm,
+    DROP COLUMN kdf_time,
+    DROP COLUMN kdf_memory,
+    DROP COLUMN kdf_threads;
+-- +goose StatementEnd
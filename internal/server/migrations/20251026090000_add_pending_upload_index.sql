@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX files_pending_updated_at_idx ON files (updated_at) WHERE upload_status = 'pending' AND NOT deleted;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX files_pending_updated_at_idx;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE refresh_tokens
+    ADD COLUMN device_name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN platform TEXT NOT NULL DEFAULT '',
+    ADD COLUMN client_version TEXT NOT NULL DEFAULT '',
+    ADD COLUMN ip TEXT NOT NULL DEFAULT '',
+    ADD COLUMN last_used_at TIMESTAMP NOT NULL DEFAULT now();
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX refresh_tokens_user_id_idx;
+ALTER TABLE refresh_tokens
+    DROP COLUMN device_name,
+    DROP COLUMN platform,
+    DROP COLUMN client_version,
+    DROP COLUMN ip,
+    DROP COLUMN last_used_at;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS rotated_refresh_tokens (
+    token TEXT PRIMARY KEY,
+    session_id UUID NOT NULL REFERENCES refresh_tokens(id) ON DELETE CASCADE,
+    rotated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+CREATE INDEX rotated_refresh_tokens_session_id_idx ON rotated_refresh_tokens (session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE rotated_refresh_tokens;
+-- +goose StatementEnd
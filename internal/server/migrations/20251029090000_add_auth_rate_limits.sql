@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS auth_rate_limits (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    refilled_at TIMESTAMP NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    failed_at TIMESTAMP,
+    locked_until TIMESTAMP
+);
+CREATE INDEX auth_rate_limits_refilled_at_idx ON auth_rate_limits (refilled_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE auth_rate_limits;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN totp_secret BYTEA,
+    ADD COLUMN totp_pending_secret BYTEA,
+    ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash BYTEA NOT NULL,
+    PRIMARY KEY (user_id, code_hash)
+);
+CREATE TABLE IF NOT EXISTS two_factor_challenges (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE two_factor_challenges;
+DROP TABLE recovery_codes;
+ALTER TABLE users
+    DROP COLUMN totp_secret,
+    DROP COLUMN totp_pending_secret,
+    DROP COLUMN totp_last_step;
+-- +goose StatementEnd
@@ -30,4 +30,7 @@ type Entry struct {
 	Deleted bool
 	// Version is the server-assigned, monotonically increasing version used for sync.
 	Version int64
+	// BaseVersion is the server version a pushed client edit was made against.
+	// It is not persisted; Sync compares it with Version to detect conflicts.
+	BaseVersion int64
 }
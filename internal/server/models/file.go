@@ -1,6 +1,8 @@
 // Package models defines server-side data models persisted in the database.
 package models
 
+import "time"
+
 // File describes server-side metadata for a binary payload associated
 // with an entry. The encrypted content itself is stored in object storage.
 type File struct {
@@ -13,13 +15,32 @@ type File struct {
 
 	// StorageKey is the object-storage key (path) of the ciphertext blob.
 	StorageKey string
-	// EncryptedFileKey is the per-file symmetric key (itself encrypted, if applicable).
+	// EncryptedFileKey is the per-file symmetric key, wrapped by the client
+	// with the user's vault key; the server cannot unwrap it.
 	EncryptedFileKey []byte
 	// Nonce is the AEAD nonce used to encrypt the file contents.
 	Nonce []byte
+	// Digest is the SHA-256 of the ciphertext blob as computed by the
+	// client; empty for files uploaded before digests were recorded.
+	Digest []byte
 
 	// UploadStatus tracks server-side upload state (e.g., "pending", "completed").
 	UploadStatus string
+	// UploadID identifies the S3 multipart upload in progress for
+	// StorageKey, if any.
+	UploadID string
+	// Deleted marks the file as a tombstone of a deleted entry.
+	Deleted bool
+	// UpdatedAt is when the file row last changed; only loaded where the
+	// age of a row matters.
+	UpdatedAt time.Time
+}
+
+// UploadedPart is a part of a multipart upload that the client has stored,
+// identified by its 1-based number and the ETag S3 returned for it.
+type UploadedPart struct {
+	PartNumber int32
+	ETag       string
 }
 
 // FileUploadTask instructs the client to upload a file using a presigned URL.
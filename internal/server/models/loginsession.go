@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// LoginSession is the server side of an SRP login between LoginStart and
+// LoginFinish. It is short-lived and consumed by the first LoginFinish.
+type LoginSession struct {
+	// ID is the opaque session identifier handed to the client.
+	ID string
+	// UserID is the account being logged into.
+	UserID string
+	// ClientPublic is the client's ephemeral SRP public value A.
+	ClientPublic []byte
+	// ServerSecret is the server's ephemeral SRP private value b.
+	ServerSecret []byte
+	// Expires is the time after which the session can no longer be finished.
+	Expires time.Time
+}
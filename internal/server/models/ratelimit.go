@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// RateLimit is the throttling state of one client of the authentication
+// RPCs, identified by Key (an IP address or a username): a token bucket and
+// a count of failed logins that locks the client out once it grows too
+// large.
+type RateLimit struct {
+	// Key identifies the client, e.g. "ip:203.0.113.7" or "user:alice".
+	Key string
+	// Tokens is the number of requests left in the bucket at RefilledAt.
+	Tokens float64
+	// RefilledAt is when Tokens was last brought up to date (UTC).
+	RefilledAt time.Time
+	// Failures counts failed logins since the last successful one.
+	Failures int
+	// FailedAt is when the last failed login happened; zero if there was
+	// none.
+	FailedAt time.Time
+	// LockedUntil is when the current lockout ends; zero if there is none.
+	LockedUntil time.Time
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Device describes the client a refresh token was issued to, as reported by
+// the client itself (Name, Platform, ClientVersion) and seen by the server
+// (IP).
+type Device struct {
+	// Name is a human-readable device name, e.g. the host name.
+	Name string
+	// Platform is the operating system and architecture of the client.
+	Platform string
+	// ClientVersion is the version of the client application.
+	ClientVersion string
+	// IP is the address the client connected from when it last used the
+	// session.
+	IP string
+}
+
+// Session is a device login: a refresh token chain and the access tokens
+// minted from it. Its ID stays the same while the refresh token is rotated.
+type Session struct {
+	// ID identifies the session; access tokens carry it as a claim.
+	ID string
+	// Device is the client the session belongs to.
+	Device Device
+	// CreatedAt is when the device logged in (UTC).
+	CreatedAt time.Time
+	// LastUsedAt is when the session's refresh token was last used (UTC).
+	LastUsedAt time.Time
+	// Expires is the time the current refresh token expires (UTC).
+	Expires time.Time
+	// Current marks the session of the caller.
+	Current bool
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// TwoFactorChallenge is a login that passed the SRP proof of an account with
+// two-factor authentication and waits for a TOTP or recovery code.
+type TwoFactorChallenge struct {
+	// ID is the opaque challenge identifier handed to the client.
+	ID string
+	// UserID is the account being logged into.
+	UserID string
+	// Attempts is the number of codes tried so far.
+	Attempts int
+	// Expires is the time after which the challenge can no longer be
+	// answered.
+	Expires time.Time
+}
@@ -1,10 +1,14 @@
 // Package models defines server-side data models persisted in the database.
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
+)
 
 // User represents an account registered in the system.
-// Passwords are never stored; instead, a salt and verifier are kept.
+// Passwords are never stored; instead, a salt and an SRP verifier are kept.
 type User struct {
 	// ID is the unique identifier of the user.
 	ID string
@@ -12,8 +16,47 @@ type User struct {
 	UserName string
 	// Salt is the per-user random salt used for deriving the master key.
 	Salt []byte
-	// Verifier is a one-way value derived from the master key (not the password).
+	// KDF are the password KDF parameters the master key is derived with.
+	KDF cryptox.KDFParams
+	// Verifier is the legacy login credential, a SHA-256 hash of the master
+	// key. It is nil once the account has been upgraded to SRP.
 	Verifier []byte
+	// SRPVerifier is the SRP-6a verifier g^x derived from the auth key (from
+	// the master key itself for accounts without WrappedVaultKey); nil for
+	// accounts that have not logged in since SRP was introduced.
+	SRPVerifier []byte
+	// WrappedVaultKey is the client's vault key wrapped with its
+	// key-encryption key; the server cannot unwrap it. It is nil for
+	// accounts that predate the key hierarchy.
+	WrappedVaultKey []byte
+	// TOTPSecret is the secret of the account's second factor; nil if
+	// two-factor authentication is off.
+	TOTPSecret []byte
+	// TOTPPendingSecret is the secret of an enrollment that has not been
+	// confirmed with a code yet.
+	TOTPPendingSecret []byte
+	// TOTPLastStep is the time step of the last TOTP code accepted, so that
+	// a code cannot be used twice.
+	TOTPLastStep int64
 	// CreatedAt is the account creation timestamp (UTC).
 	CreatedAt time.Time
 }
+
+// Credentials are the password-derived values of an account that a password
+// change or a KDF upgrade replaces together.
+type Credentials struct {
+	Salt            []byte
+	KDF             cryptox.KDFParams
+	SRPVerifier     []byte
+	WrappedVaultKey []byte
+}
+
+// VaultRekey is the content of an account re-encrypted under a new vault
+// key, which is stored together with the Credentials wrapping that key.
+// Entries carry the version they were re-encrypted from in BaseVersion;
+// Files carry the EntryID, the new EncryptedFileKey and the Nonce of the
+// file the key belongs to.
+type VaultRekey struct {
+	Entries []*Entry
+	Files   []*File
+}
@@ -4,7 +4,10 @@ package entries
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/common"
 	"github.com/dmitrijs2005/gophkeeper/internal/dbx"
@@ -24,21 +27,26 @@ func NewPostgresRepository(db dbx.DBTX) *PostgresRepository {
 // CreateOrUpdate upserts an entry by ID for a specific user. If a conflicting
 // row exists for another user, no row is updated and ErrVersionConflict is returned.
 // Returns an error for DB failures or unexpected rows affected.
+//
+// deleted_at is stamped when the entry becomes a tombstone and cleared when it
+// is restored; it drives the retention of tombstones (see SelectPurgeable).
 func (r *PostgresRepository) CreateOrUpdate(ctx context.Context, entry *models.Entry) error {
 	query := `
-		INSERT INTO entries (id, user_id, overview, nonce_overview, details, nonce_details, version)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO entries (id, user_id, overview, nonce_overview, details, nonce_details, version, deleted, deleted_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, CASE WHEN $8 THEN now() END)
 		ON CONFLICT (id)
 		DO UPDATE SET 
 			overview = EXCLUDED.overview, 
 			nonce_overview = EXCLUDED.nonce_overview, 
 			details = EXCLUDED.details, 
 			nonce_details = EXCLUDED.nonce_details, 
-			version = EXCLUDED.version
+			version = EXCLUDED.version,
+			deleted = EXCLUDED.deleted,
+			deleted_at = CASE WHEN EXCLUDED.deleted THEN COALESCE(entries.deleted_at, EXCLUDED.deleted_at) END
 			WHERE entries.user_id = EXCLUDED.user_id;
 	`
 	res, err := r.db.ExecContext(ctx, query,
-		entry.ID, entry.UserID, entry.Overview, entry.NonceOverview, entry.Details, entry.NonceDetails, entry.Version)
+		entry.ID, entry.UserID, entry.Overview, entry.NonceOverview, entry.Details, entry.NonceDetails, entry.Version, entry.Deleted)
 	if err != nil {
 		return fmt.Errorf("db error: %w", err)
 	}
@@ -84,3 +92,75 @@ func (r *PostgresRepository) SelectUpdated(ctx context.Context, userID string, m
 	}
 	return result, nil
 }
+
+// GetForUpdate returns the entry id owned by userID, locking the row
+// (SELECT ... FOR UPDATE) so that concurrent syncs cannot both write on top
+// of the same version. Returns common.ErrorNotFound when there is no such row.
+func (r *PostgresRepository) GetForUpdate(ctx context.Context, userID string, id string) (*models.Entry, error) {
+	query := ` SELECT id, user_id, overview, nonce_overview, details, nonce_details, deleted, version from entries 
+		WHERE id=$1 and user_id=$2 FOR UPDATE
+		`
+	item := &models.Entry{}
+	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(
+		&item.ID, &item.UserID, &item.Overview, &item.NonceOverview, &item.Details, &item.NonceDetails,
+		&item.Deleted, &item.Version,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.ErrorNotFound
+		}
+		return nil, fmt.Errorf("failed to select entry: %w", err)
+	}
+	return item, nil
+}
+
+// SelectPurgeable returns the oldest tombstones deleted before the given
+// time, at most limit of them, across all users.
+func (r *PostgresRepository) SelectPurgeable(ctx context.Context, before time.Time, limit int) ([]*models.Entry, error) {
+	query := ` SELECT id, user_id, version from entries
+		WHERE deleted AND deleted_at < $1
+		ORDER BY deleted_at
+		LIMIT $2
+		`
+	rows, err := r.db.QueryContext(ctx, query, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select tombstones: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*models.Entry
+	for rows.Next() {
+		item := &models.Entry{Deleted: true}
+		if err := rows.Scan(&item.ID, &item.UserID, &item.Version); err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Purge hard-deletes the tombstone id owned by userID; its revisions go with
+// it (ON DELETE CASCADE). The file row must be removed first. Returns
+// common.ErrorNotFound when there is no such tombstone.
+func (r *PostgresRepository) Purge(ctx context.Context, userID string, id string) error {
+	query := `DELETE FROM entries WHERE id=$1 AND user_id=$2 AND deleted`
+	res, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to purge entry: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected error: %w", err)
+	}
+	switch n {
+	case 1:
+		return nil
+	case 0:
+		return common.ErrorNotFound
+	default:
+		return fmt.Errorf("unexpected rows affected: %d", n)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/dmitrijs2005/gophkeeper/internal/common"
@@ -32,7 +33,7 @@ func TestCreateOrUpdate_SuccessRowsAffected1(t *testing.T) {
 			"e1", "u1",
 			[]byte("ov"), []byte("no"),
 			[]byte("det"), []byte("nd"),
-			int64(3),
+			int64(3), false,
 		).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
@@ -53,6 +54,34 @@ func TestCreateOrUpdate_SuccessRowsAffected1(t *testing.T) {
 	}
 }
 
+func TestCreateOrUpdate_WritesTombstone(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := regexp.MustCompile(`INSERT INTO entries .* deleted, deleted_at\) VALUES .* CASE WHEN \$8 THEN now\(\) END\) .* DO UPDATE SET .* deleted = EXCLUDED\.deleted, deleted_at = CASE WHEN EXCLUDED\.deleted THEN COALESCE\(entries\.deleted_at, EXCLUDED\.deleted_at\) END WHERE entries\.user_id = EXCLUDED\.user_id;`)
+
+	mock.ExpectExec(q.String()).
+		WithArgs("e1", "u1", []byte("o"), []byte("no"), []byte("d"), []byte("nd"), int64(4), true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.CreateOrUpdate(context.Background(), &models.Entry{
+		ID:            "e1",
+		UserID:        "u1",
+		Overview:      []byte("o"),
+		NonceOverview: []byte("no"),
+		Details:       []byte("d"),
+		NonceDetails:  []byte("nd"),
+		Version:       4,
+		Deleted:       true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
 func TestCreateOrUpdate_VersionConflictRowsAffected0(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
@@ -60,7 +89,7 @@ func TestCreateOrUpdate_VersionConflictRowsAffected0(t *testing.T) {
 	q := regexp.MustCompile(`INSERT INTO entries .* ON CONFLICT .* DO UPDATE SET .* WHERE entries\.user_id = EXCLUDED\.user_id;`)
 
 	mock.ExpectExec(q.String()).
-		WithArgs("e1", "u1", []byte("o"), []byte("no"), []byte("d"), []byte("nd"), int64(1)).
+		WithArgs("e1", "u1", []byte("o"), []byte("no"), []byte("d"), []byte("nd"), int64(1), false).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
 	err := repo.CreateOrUpdate(context.Background(), &models.Entry{
@@ -84,7 +113,7 @@ func TestCreateOrUpdate_DBExecError(t *testing.T) {
 	q := regexp.MustCompile(`INSERT INTO entries .* ON CONFLICT .* DO UPDATE SET .* WHERE entries\.user_id = EXCLUDED\.user_id;`)
 
 	mock.ExpectExec(q.String()).
-		WithArgs("e1", "u1", []byte("o"), []byte("no"), []byte("d"), []byte("nd"), int64(1)).
+		WithArgs("e1", "u1", []byte("o"), []byte("no"), []byte("d"), []byte("nd"), int64(1), false).
 		WillReturnError(errors.New("db is down"))
 
 	err := repo.CreateOrUpdate(context.Background(), &models.Entry{
@@ -108,7 +137,7 @@ func TestCreateOrUpdate_RowsAffectedError(t *testing.T) {
 	q := regexp.MustCompile(`INSERT INTO entries .* ON CONFLICT .* DO UPDATE SET .* WHERE entries\.user_id = EXCLUDED\.user_id;`)
 
 	mock.ExpectExec(q.String()).
-		WithArgs("e1", "u1", []byte("o"), []byte("no"), []byte("d"), []byte("nd"), int64(1)).
+		WithArgs("e1", "u1", []byte("o"), []byte("no"), []byte("d"), []byte("nd"), int64(1), false).
 		WillReturnResult(sqlmock.NewErrorResult(errors.New("rows-err")))
 
 	err := repo.CreateOrUpdate(context.Background(), &models.Entry{
@@ -132,7 +161,7 @@ func TestCreateOrUpdate_UnexpectedRowsAffectedGt1(t *testing.T) {
 	q := regexp.MustCompile(`INSERT INTO entries .* ON CONFLICT .* DO UPDATE SET .* WHERE entries\.user_id = EXCLUDED\.user_id;`)
 
 	mock.ExpectExec(q.String()).
-		WithArgs("e1", "u1", []byte("o"), []byte("no"), []byte("d"), []byte("nd"), int64(1)).
+		WithArgs("e1", "u1", []byte("o"), []byte("no"), []byte("d"), []byte("nd"), int64(1), false).
 		WillReturnResult(sqlmock.NewResult(0, 2))
 
 	err := repo.CreateOrUpdate(context.Background(), &models.Entry{
@@ -242,3 +271,80 @@ func TestSelectUpdated_RowsErr(t *testing.T) {
 		t.Fatalf("expected rows.Err 'row-err', got %v", err)
 	}
 }
+
+func TestGetForUpdate_FoundAndNotFound(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := regexp.MustCompile(`SELECT id, user_id, overview, nonce_overview, details, nonce_details, deleted, version from entries\s+WHERE id=\$1 and user_id=\$2 FOR UPDATE`)
+	rows := sqlmock.NewRows([]string{"id", "user_id", "overview", "nonce_overview", "details", "nonce_details", "deleted", "version"}).
+		AddRow("e1", "u1", []byte("o"), []byte("no"), []byte("d"), []byte("nd"), false, int64(7))
+	mock.ExpectQuery(q.String()).WithArgs("e1", "u1").WillReturnRows(rows)
+	mock.ExpectQuery(q.String()).WithArgs("e2", "u1").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(q.String()).WithArgs("e3", "u1").WillReturnError(errors.New("db err"))
+
+	got, err := repo.GetForUpdate(context.Background(), "u1", "e1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.ID != "e1" || got.Version != 7 || string(got.Details) != "d" {
+		t.Fatalf("bad row: %+v", got)
+	}
+
+	if _, err := repo.GetForUpdate(context.Background(), "u1", "e2"); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want common.ErrorNotFound, got %v", err)
+	}
+	if _, err := repo.GetForUpdate(context.Background(), "u1", "e3"); err == nil || !regexp.MustCompile(`failed to select entry: .*db err`).MatchString(err.Error()) {
+		t.Fatalf("expected wrapped select error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestSelectPurgeable_Success(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	before := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	q := regexp.MustCompile(`SELECT id, user_id, version from entries WHERE deleted AND deleted_at < \$1 ORDER BY deleted_at LIMIT \$2`)
+	mock.ExpectQuery(q.String()).
+		WithArgs(before, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "version"}).
+			AddRow("e1", "u1", int64(3)).
+			AddRow("e2", "u2", int64(8)))
+
+	got, err := repo.SelectPurgeable(context.Background(), before, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != "e1" || got[0].UserID != "u1" || got[1].Version != 8 || !got[1].Deleted {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPurge_TombstoneOnly(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := regexp.MustCompile(`DELETE FROM entries WHERE id=\$1 AND user_id=\$2 AND deleted`)
+	mock.ExpectExec(q.String()).
+		WithArgs("e1", "u1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(q.String()).
+		WithArgs("live", "u1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.Purge(context.Background(), "u1", "e1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := repo.Purge(context.Background(), "u1", "live"); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("expected ErrorNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
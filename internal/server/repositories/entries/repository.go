@@ -4,6 +4,7 @@ package entries
 
 import (
 	"context"
+	"time"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
 )
@@ -17,4 +18,18 @@ type Repository interface {
 	// SelectUpdated returns all entries for the given user whose version is
 	// strictly greater than minVersion (used for incremental sync).
 	SelectUpdated(ctx context.Context, userID string, minVersion int64) ([]*models.Entry, error)
+
+	// GetForUpdate returns the user's entry by ID and locks its row until the
+	// surrounding transaction ends. Returns common.ErrorNotFound if the user
+	// has no such entry.
+	GetForUpdate(ctx context.Context, userID string, id string) (*models.Entry, error)
+
+	// SelectPurgeable returns up to limit tombstones of any user that were
+	// deleted before the given time. Only ID, UserID and Version are set.
+	SelectPurgeable(ctx context.Context, before time.Time, limit int) ([]*models.Entry, error)
+
+	// Purge permanently removes the user's tombstoned entry together with its
+	// revision history. Live entries are left alone; Purge returns
+	// common.ErrorNotFound if the user has no such tombstone.
+	Purge(ctx context.Context, userID string, id string) error
 }
@@ -2,7 +2,11 @@ package files
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/common"
 	"github.com/dmitrijs2005/gophkeeper/internal/dbx"
@@ -20,23 +24,29 @@ func NewPostgresRepository(db dbx.DBTX) *PostgresRepository {
 }
 
 // CreateOrUpdate upserts a file record by entry_id. On conflict, server-side
-// fields are updated. Returns ErrVersionConflict when no row is affected
-// due to a version or ownership constraint.
+// fields are updated and updated_at is stamped, so that a re-pushed pending
+// file is not reported as stale (see SelectStalePending); a re-uploaded
+// attachment replaces the storage key of the previous one and drops its
+// multipart upload. A row owned by another user is never updated: no row is
+// affected and ErrVersionConflict is returned.
 func (r *PostgresRepository) CreateOrUpdate(ctx context.Context, file *models.File) error {
 	query := `
-		INSERT INTO files (entry_id, user_id, version, encrypted_file_key, nonce, upload_status, storage_key)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO files (entry_id, user_id, version, encrypted_file_key, nonce, upload_status, storage_key, digest)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		ON CONFLICT (entry_id)
 		DO UPDATE SET 
-			user_id = EXCLUDED.user_id, 
 			version = EXCLUDED.version,
 			encrypted_file_key = EXCLUDED.encrypted_file_key, 
 			nonce = EXCLUDED.nonce, 
-			upload_status = EXCLUDED.upload_status 
-			WHERE files.entry_id = EXCLUDED.entry_id;
+			upload_status = EXCLUDED.upload_status,
+			storage_key = EXCLUDED.storage_key,
+			digest = EXCLUDED.digest,
+			updated_at = now(),
+			upload_id = CASE WHEN files.storage_key = EXCLUDED.storage_key THEN files.upload_id END
+			WHERE files.user_id = EXCLUDED.user_id;
 	`
 	res, err := r.db.ExecContext(ctx, query,
-		file.EntryID, file.UserID, file.Version, file.EncryptedFileKey, file.Nonce, file.UploadStatus, file.StorageKey)
+		file.EntryID, file.UserID, file.Version, file.EncryptedFileKey, file.Nonce, file.UploadStatus, file.StorageKey, file.Digest)
 	if err != nil {
 		return fmt.Errorf("db error: %w", err)
 	}
@@ -56,8 +66,8 @@ func (r *PostgresRepository) CreateOrUpdate(ctx context.Context, file *models.Fi
 
 // SelectUpdated returns all files for userID with version > minVersion.
 func (r *PostgresRepository) SelectUpdated(ctx context.Context, userID string, minVersion int64) ([]*models.File, error) {
-	query := ` SELECT entry_id, user_id, version, encrypted_file_key, nonce, upload_status from files 
-		WHERE user_id=$1 and version>$2
+	query := ` SELECT entry_id, user_id, version, encrypted_file_key, nonce, digest, upload_status from files 
+		WHERE user_id=$1 and version>$2 and deleted=false
 		`
 	rows, err := r.db.QueryContext(ctx, query, userID, minVersion)
 	if err != nil {
@@ -68,7 +78,7 @@ func (r *PostgresRepository) SelectUpdated(ctx context.Context, userID string, m
 	var result []*models.File
 	for rows.Next() {
 		var item models.File
-		if err := rows.Scan(&item.EntryID, &item.UserID, &item.Version, &item.EncryptedFileKey, &item.Nonce, &item.UploadStatus); err != nil {
+		if err := rows.Scan(&item.EntryID, &item.UserID, &item.Version, &item.EncryptedFileKey, &item.Nonce, &item.Digest, &item.UploadStatus); err != nil {
 			return nil, err
 		}
 		result = append(result, &item)
@@ -79,34 +89,219 @@ func (r *PostgresRepository) SelectUpdated(ctx context.Context, userID string, m
 	return result, nil
 }
 
-// MarkUploaded marks the file for entry id as uploaded (upload_status='completed').
-// Exactly one row must be affected.
-func (r *PostgresRepository) MarkUploaded(ctx context.Context, id string) error {
-	query := `update files set upload_status='completed' where entry_id=$1`
-	result, err := r.db.ExecContext(ctx, query, id)
+// MarkUploaded marks the file for entry id owned by userID as uploaded
+// (upload_status='completed'). Returns common.ErrorNotFound when no such
+// row exists; exactly one row must be affected otherwise.
+func (r *PostgresRepository) MarkUploaded(ctx context.Context, userID string, id string) error {
+	query := `update files set upload_status='completed' where entry_id=$1 and user_id=$2`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
 	if err != nil {
 		return fmt.Errorf("failed to mark uploaded: %w", err)
 	}
+
+	ra, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	switch ra {
+	case 1:
+		return nil
+	case 0:
+		return common.ErrorNotFound
+	default:
+		return fmt.Errorf("wrong rows affected count: %d", ra)
+	}
+}
+
+// UpdateKey replaces the encrypted_file_key of the live file of entry id
+// owned by userID and stamps it with version so that other devices pull the
+// new key. Returns common.ErrorNotFound when no such row exists.
+func (r *PostgresRepository) UpdateKey(ctx context.Context, userID string, id string, key []byte, version int64) error {
+	query := `update files set encrypted_file_key=$3, version=$4, updated_at=now() where entry_id=$1 and user_id=$2 and deleted=false`
+	result, err := r.db.ExecContext(ctx, query, id, userID, key, version)
+	if err != nil {
+		return fmt.Errorf("failed to update file key: %w", err)
+	}
+
 	ra, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
-	if ra != 1 {
+
+	switch ra {
+	case 1:
+		return nil
+	case 0:
+		return common.ErrorNotFound
+	default:
 		return fmt.Errorf("wrong rows affected count: %d", ra)
 	}
+}
+
+// MarkDeleted tombstones the file of entry id owned by userID. An entry
+// without a (live) file is not an error.
+func (r *PostgresRepository) MarkDeleted(ctx context.Context, userID string, id string) error {
+	query := `update files set deleted=true, updated_at=now() where entry_id=$1 and user_id=$2 and deleted=false`
+	if _, err := r.db.ExecContext(ctx, query, id, userID); err != nil {
+		return fmt.Errorf("failed to mark deleted: %w", err)
+	}
+	return nil
+}
+
+// Restore clears the tombstone of the file of entry id owned by userID and
+// stamps it with version so that other devices pull it again. An entry
+// without a deleted file is not an error.
+func (r *PostgresRepository) Restore(ctx context.Context, userID string, id string, version int64) error {
+	query := `update files set deleted=false, version=$3, updated_at=now() where entry_id=$1 and user_id=$2 and deleted=true`
+	if _, err := r.db.ExecContext(ctx, query, id, userID, version); err != nil {
+		return fmt.Errorf("failed to restore file: %w", err)
+	}
+	return nil
+}
+
+// Purge deletes the file row of entry id owned by userID and returns the
+// storage key of the removed object. An entry without a file yields "".
+func (r *PostgresRepository) Purge(ctx context.Context, userID string, id string) (string, error) {
+	query := `DELETE FROM files WHERE entry_id=$1 AND user_id=$2 RETURNING storage_key`
+	var key string
+	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(&key)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("failed to purge file: %w", err)
+	}
+	return key, nil
+}
+
+// PurgeDeletedByUser deletes every tombstoned file row of userID.
+func (r *PostgresRepository) PurgeDeletedByUser(ctx context.Context, userID string) error {
+	query := `DELETE FROM files WHERE user_id=$1 AND deleted=true`
+	if _, err := r.db.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to purge files: %w", err)
+	}
 	return nil
 }
 
-// GetByEntryID returns a minimal file row (entry_id, user_id, storage_key)
-// used to authorize and build presigned URLs.
+// GetByEntryID returns a minimal file row (entry_id, user_id, storage_key,
+// nonce, upload_status, upload_id) used to authorize, build presigned URLs
+// and drive multipart uploads. Returns common.ErrorNotFound when the entry
+// has no live file.
 func (r *PostgresRepository) GetByEntryID(ctx context.Context, id string) (*models.File, error) {
-	query := ` SELECT entry_id, user_id, storage_key from files 
-		WHERE entry_id=$1
+	query := ` SELECT entry_id, user_id, storage_key, nonce, upload_status, coalesce(upload_id, '') from files 
+		WHERE entry_id=$1 and deleted=false
 		`
-
 	result := &models.File{}
-	if err := r.db.QueryRowContext(ctx, query, id).Scan(&result.EntryID, &result.UserID, &result.StorageKey); err != nil {
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&result.EntryID, &result.UserID, &result.StorageKey,
+		&result.Nonce, &result.UploadStatus, &result.UploadID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.ErrorNotFound
+		}
 		return nil, fmt.Errorf("failed to select files: %w", err)
 	}
 	return result, nil
 }
+
+// SetUploadID stores uploadID as the multipart upload of the live file of
+// entry id owned by userID, or clears it when uploadID is empty. The update
+// only applies while the file is stored under storageKey, so that the upload
+// of a replaced attachment is never recorded. Returns common.ErrorNotFound
+// when no such row exists.
+func (r *PostgresRepository) SetUploadID(ctx context.Context, userID string, id string, storageKey string, uploadID string) error {
+	query := `update files set upload_id=NULLIF($4, '') where entry_id=$1 and user_id=$2 and storage_key=$3 and deleted=false`
+	result, err := r.db.ExecContext(ctx, query, id, userID, storageKey, uploadID)
+	if err != nil {
+		return fmt.Errorf("failed to set upload id: %w", err)
+	}
+
+	ra, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	switch ra {
+	case 1:
+		return nil
+	case 0:
+		return common.ErrorNotFound
+	default:
+		return fmt.Errorf("wrong rows affected count: %d", ra)
+	}
+}
+
+// SelectStalePending returns the live files, across all users, that are
+// still waiting for their upload and were last registered before the given
+// time, oldest first.
+func (r *PostgresRepository) SelectStalePending(ctx context.Context, before time.Time) ([]*models.File, error) {
+	query := ` SELECT entry_id, user_id, storage_key, coalesce(upload_id, ''), updated_at from files
+		WHERE upload_status='pending' AND NOT deleted AND updated_at < $1
+		ORDER BY updated_at
+		`
+	rows, err := r.db.QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select pending files: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*models.File
+	for rows.Next() {
+		item := &models.File{UploadStatus: "pending"}
+		if err := rows.Scan(&item.EntryID, &item.UserID, &item.StorageKey, &item.UploadID, &item.UpdatedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SelectByStorageKeys returns the file rows, live or tombstoned, of all users
+// that are stored under one of the given keys. Keys without a row are
+// omitted. Every key is a query parameter, so callers pass them in batches.
+func (r *PostgresRepository) SelectByStorageKeys(ctx context.Context, keys []string) ([]*models.File, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	placeholders := make([]string, len(keys))
+	args := make([]any, len(keys))
+	for i, k := range keys {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = k
+	}
+	query := ` SELECT entry_id, user_id, storage_key, deleted, updated_at from files
+		WHERE storage_key IN (` + strings.Join(placeholders, ", ") + `)`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select files: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*models.File
+	for rows.Next() {
+		item := &models.File{}
+		if err := rows.Scan(&item.EntryID, &item.UserID, &item.StorageKey, &item.Deleted, &item.UpdatedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// PurgeDeletedByStorageKey deletes the tombstoned file row stored under key
+// if it was deleted before the given time, and reports whether it did. A
+// live, recently deleted or missing row is left alone.
+func (r *PostgresRepository) PurgeDeletedByStorageKey(ctx context.Context, key string, before time.Time) (bool, error) {
+	query := `DELETE FROM files WHERE storage_key=$1 AND deleted AND updated_at < $2`
+	result, err := r.db.ExecContext(ctx, query, key, before)
+	if err != nil {
+		return false, fmt.Errorf("failed to purge file: %w", err)
+	}
+	ra, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return ra == 1, nil
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/dmitrijs2005/gophkeeper/internal/common"
@@ -25,10 +26,10 @@ func TestCreateOrUpdate_Success(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := `(?s)^INSERT\s+INTO\s+files\b.*ON\s+CONFLICT\s*\(entry_id\)\s*DO\s+UPDATE\s+SET\b.*WHERE\s+files\.entry_id\s*=\s*EXCLUDED\.entry_id;?$`
+	q := `(?s)^INSERT\s+INTO\s+files\b.*ON\s+CONFLICT\s*\(entry_id\)\s*DO\s+UPDATE\s+SET\b.*WHERE\s+files\.user_id\s*=\s*EXCLUDED\.user_id;?$`
 
 	mock.ExpectExec(q).
-		WithArgs("e1", "u1", int64(3), []byte("fk"), []byte("n"), "pending", "skey").
+		WithArgs("e1", "u1", int64(3), []byte("fk"), []byte("n"), "pending", "skey", []byte("d")).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	err := repo.CreateOrUpdate(context.Background(), &models.File{
@@ -37,6 +38,7 @@ func TestCreateOrUpdate_Success(t *testing.T) {
 		Version:          3,
 		EncryptedFileKey: []byte("fk"),
 		Nonce:            []byte("n"),
+		Digest:           []byte("d"),
 		UploadStatus:     "pending",
 		StorageKey:       "skey",
 	})
@@ -52,10 +54,10 @@ func TestCreateOrUpdate_VersionConflict(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := `(?s)^INSERT\s+INTO\s+files\b.*ON\s+CONFLICT\s*\(entry_id\)\s*DO\s+UPDATE\s+SET\b.*WHERE\s+files\.entry_id\s*=\s*EXCLUDED\.entry_id;?$`
+	q := `(?s)^INSERT\s+INTO\s+files\b.*ON\s+CONFLICT\s*\(entry_id\)\s*DO\s+UPDATE\s+SET\b.*WHERE\s+files\.user_id\s*=\s*EXCLUDED\.user_id;?$`
 
 	mock.ExpectExec(q).
-		WithArgs("e1", "u1", int64(1), []byte("fk"), []byte("n"), "pending", "skey").
+		WithArgs("e1", "u1", int64(1), []byte("fk"), []byte("n"), "pending", "skey", []byte(nil)).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
 	err := repo.CreateOrUpdate(context.Background(), &models.File{
@@ -76,10 +78,10 @@ func TestCreateOrUpdate_DBError(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := `(?s)^INSERT\s+INTO\s+files\b.*ON\s+CONFLICT\s*\(entry_id\)\s*DO\s+UPDATE\s+SET\b.*WHERE\s+files\.entry_id\s*=\s*EXCLUDED\.entry_id;?$`
+	q := `(?s)^INSERT\s+INTO\s+files\b.*ON\s+CONFLICT\s*\(entry_id\)\s*DO\s+UPDATE\s+SET\b.*WHERE\s+files\.user_id\s*=\s*EXCLUDED\.user_id;?$`
 
 	mock.ExpectExec(q).
-		WithArgs("e1", "u1", int64(1), []byte("fk"), []byte("n"), "pending", "skey").
+		WithArgs("e1", "u1", int64(1), []byte("fk"), []byte("n"), "pending", "skey", []byte(nil)).
 		WillReturnError(errors.New("db down"))
 
 	err := repo.CreateOrUpdate(context.Background(), &models.File{
@@ -100,10 +102,10 @@ func TestCreateOrUpdate_RowsAffectedErr(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := `(?s)^INSERT\s+INTO\s+files\b.*ON\s+CONFLICT\s*\(entry_id\)\s*DO\s+UPDATE\s+SET\b.*WHERE\s+files\.entry_id\s*=\s*EXCLUDED\.entry_id;?$`
+	q := `(?s)^INSERT\s+INTO\s+files\b.*ON\s+CONFLICT\s*\(entry_id\)\s*DO\s+UPDATE\s+SET\b.*WHERE\s+files\.user_id\s*=\s*EXCLUDED\.user_id;?$`
 
 	mock.ExpectExec(q).
-		WithArgs("e1", "u1", int64(1), []byte("fk"), []byte("n"), "pending", "skey").
+		WithArgs("e1", "u1", int64(1), []byte("fk"), []byte("n"), "pending", "skey", []byte(nil)).
 		WillReturnResult(sqlmock.NewErrorResult(errors.New("rows-err")))
 
 	err := repo.CreateOrUpdate(context.Background(), &models.File{
@@ -124,10 +126,10 @@ func TestCreateOrUpdate_UnexpectedRowsAffected(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := `(?s)^INSERT\s+INTO\s+files\b.*ON\s+CONFLICT\s*\(entry_id\)\s*DO\s+UPDATE\s+SET\b.*WHERE\s+files\.entry_id\s*=\s*EXCLUDED\.entry_id;?$`
+	q := `(?s)^INSERT\s+INTO\s+files\b.*ON\s+CONFLICT\s*\(entry_id\)\s*DO\s+UPDATE\s+SET\b.*WHERE\s+files\.user_id\s*=\s*EXCLUDED\.user_id;?$`
 
 	mock.ExpectExec(q).
-		WithArgs("e1", "u1", int64(1), []byte("fk"), []byte("n"), "pending", "skey").
+		WithArgs("e1", "u1", int64(1), []byte("fk"), []byte("n"), "pending", "skey", []byte(nil)).
 		WillReturnResult(sqlmock.NewResult(0, 2))
 
 	err := repo.CreateOrUpdate(context.Background(), &models.File{
@@ -148,10 +150,10 @@ func TestSelectUpdated_Success(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := regexp.MustCompile(`SELECT entry_id, user_id, version, encrypted_file_key, nonce, upload_status from files\s+WHERE user_id=\$1 and version>\$2`)
-	rows := sqlmock.NewRows([]string{"entry_id", "user_id", "version", "encrypted_file_key", "nonce", "upload_status"}).
-		AddRow("e1", "u1", int64(2), []byte("fk1"), []byte("n1"), "pending").
-		AddRow("e2", "u1", int64(5), []byte("fk2"), []byte("n2"), "completed")
+	q := regexp.MustCompile(`SELECT entry_id, user_id, version, encrypted_file_key, nonce, digest, upload_status from files\s+WHERE user_id=\$1 and version>\$2`)
+	rows := sqlmock.NewRows([]string{"entry_id", "user_id", "version", "encrypted_file_key", "nonce", "digest", "upload_status"}).
+		AddRow("e1", "u1", int64(2), []byte("fk1"), []byte("n1"), []byte("d1"), "pending").
+		AddRow("e2", "u1", int64(5), []byte("fk2"), []byte("n2"), nil, "completed")
 
 	mock.ExpectQuery(q.String()).
 		WithArgs("u1", int64(1)).
@@ -164,7 +166,7 @@ func TestSelectUpdated_Success(t *testing.T) {
 	if len(got) != 2 {
 		t.Fatalf("want 2 rows, got %d", len(got))
 	}
-	if got[0].EntryID != "e1" || got[0].UploadStatus != "pending" || got[0].Version != 2 {
+	if got[0].EntryID != "e1" || got[0].UploadStatus != "pending" || got[0].Version != 2 || string(got[0].Digest) != "d1" {
 		t.Fatalf("bad row[0]: %+v", got[0])
 	}
 	if got[1].EntryID != "e2" || got[1].UploadStatus != "completed" || got[1].Version != 5 {
@@ -176,7 +178,7 @@ func TestSelectUpdated_QueryErr(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := regexp.MustCompile(`SELECT entry_id, user_id, version, encrypted_file_key, nonce, upload_status from files\s+WHERE user_id=\$1 and version>\$2`)
+	q := regexp.MustCompile(`SELECT entry_id, user_id, version, encrypted_file_key, nonce, digest, upload_status from files\s+WHERE user_id=\$1 and version>\$2`)
 	mock.ExpectQuery(q.String()).
 		WithArgs("u1", int64(9)).
 		WillReturnError(errors.New("db err"))
@@ -191,9 +193,9 @@ func TestSelectUpdated_ScanErr(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := regexp.MustCompile(`SELECT entry_id, user_id, version, encrypted_file_key, nonce, upload_status from files\s+WHERE user_id=\$1 and version>\$2`)
-	rows := sqlmock.NewRows([]string{"entry_id", "user_id", "version", "encrypted_file_key", "nonce", "upload_status"}).
-		AddRow("e1", "u1", "not-int", []byte("fk"), []byte("n"), "pending")
+	q := regexp.MustCompile(`SELECT entry_id, user_id, version, encrypted_file_key, nonce, digest, upload_status from files\s+WHERE user_id=\$1 and version>\$2`)
+	rows := sqlmock.NewRows([]string{"entry_id", "user_id", "version", "encrypted_file_key", "nonce", "digest", "upload_status"}).
+		AddRow("e1", "u1", "not-int", []byte("fk"), []byte("n"), nil, "pending")
 
 	mock.ExpectQuery(q.String()).
 		WithArgs("u1", int64(1)).
@@ -209,10 +211,10 @@ func TestSelectUpdated_RowsErr(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := regexp.MustCompile(`SELECT entry_id, user_id, version, encrypted_file_key, nonce, upload_status from files\s+WHERE user_id=\$1 and version>\$2`)
-	rows := sqlmock.NewRows([]string{"entry_id", "user_id", "version", "encrypted_file_key", "nonce", "upload_status"}).
-		AddRow("e1", "u1", int64(2), []byte("fk1"), []byte("n1"), "pending").
-		AddRow("e2", "u1", int64(3), []byte("fk2"), []byte("n2"), "completed").
+	q := regexp.MustCompile(`SELECT entry_id, user_id, version, encrypted_file_key, nonce, digest, upload_status from files\s+WHERE user_id=\$1 and version>\$2`)
+	rows := sqlmock.NewRows([]string{"entry_id", "user_id", "version", "encrypted_file_key", "nonce", "digest", "upload_status"}).
+		AddRow("e1", "u1", int64(2), []byte("fk1"), []byte("n1"), []byte("d1"), "pending").
+		AddRow("e2", "u1", int64(3), []byte("fk2"), []byte("n2"), nil, "completed").
 		RowError(1, errors.New("row-err"))
 
 	mock.ExpectQuery(q.String()).
@@ -229,12 +231,12 @@ func TestMarkUploaded_OK(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := regexp.MustCompile(`update files set upload_status='completed' where entry_id=\$1`)
+	q := regexp.MustCompile(`update files set upload_status='completed' where entry_id=\$1 and user_id=\$2`)
 	mock.ExpectExec(q.String()).
-		WithArgs("e1").
+		WithArgs("e1", "u1").
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	if err := repo.MarkUploaded(context.Background(), "e1"); err != nil {
+	if err := repo.MarkUploaded(context.Background(), "u1", "e1"); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
@@ -243,12 +245,12 @@ func TestMarkUploaded_DBErr(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := regexp.MustCompile(`update files set upload_status='completed' where entry_id=\$1`)
+	q := regexp.MustCompile(`update files set upload_status='completed' where entry_id=\$1 and user_id=\$2`)
 	mock.ExpectExec(q.String()).
-		WithArgs("e1").
+		WithArgs("e1", "u1").
 		WillReturnError(errors.New("db err"))
 
-	err := repo.MarkUploaded(context.Background(), "e1")
+	err := repo.MarkUploaded(context.Background(), "u1", "e1")
 	if err == nil || !regexp.MustCompile(`failed to mark uploaded: .*db err`).MatchString(err.Error()) {
 		t.Fatalf("expected wrapped db error, got %v", err)
 	}
@@ -258,12 +260,12 @@ func TestMarkUploaded_RowsAffectedErr(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := regexp.MustCompile(`update files set upload_status='completed' where entry_id=\$1`)
+	q := regexp.MustCompile(`update files set upload_status='completed' where entry_id=\$1 and user_id=\$2`)
 	mock.ExpectExec(q.String()).
-		WithArgs("e1").
+		WithArgs("e1", "u1").
 		WillReturnResult(sqlmock.NewErrorResult(errors.New("rows-err")))
 
-	err := repo.MarkUploaded(context.Background(), "e1")
+	err := repo.MarkUploaded(context.Background(), "u1", "e1")
 	if err == nil || !regexp.MustCompile(`failed to get rows affected: .*rows-err`).MatchString(err.Error()) {
 		t.Fatalf("expected rows affected error, got %v", err)
 	}
@@ -273,24 +275,39 @@ func TestMarkUploaded_WrongRowsAffectedCount(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := regexp.MustCompile(`update files set upload_status='completed' where entry_id=\$1`)
+	q := regexp.MustCompile(`update files set upload_status='completed' where entry_id=\$1 and user_id=\$2`)
 	mock.ExpectExec(q.String()).
-		WithArgs("no-such-id").
-		WillReturnResult(sqlmock.NewResult(0, 0))
+		WithArgs("e1", "u1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
 
-	err := repo.MarkUploaded(context.Background(), "no-such-id")
+	err := repo.MarkUploaded(context.Background(), "u1", "e1")
 	if err == nil || !regexp.MustCompile(`wrong rows affected count`).MatchString(err.Error()) {
 		t.Fatalf("expected wrong rows affected count error, got %v", err)
 	}
 }
 
+func TestMarkUploaded_NotOwnedOrMissing(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := regexp.MustCompile(`update files set upload_status='completed' where entry_id=\$1 and user_id=\$2`)
+	mock.ExpectExec(q.String()).
+		WithArgs("no-such-id", "u1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := repo.MarkUploaded(context.Background(), "u1", "no-such-id")
+	if !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want common.ErrorNotFound, got %v", err)
+	}
+}
+
 func TestGetByEntryID_OK(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := regexp.MustCompile(`SELECT entry_id, user_id, storage_key from files\s+WHERE entry_id=\$1`)
-	rows := sqlmock.NewRows([]string{"entry_id", "user_id", "storage_key"}).
-		AddRow("e1", "u1", "skey")
+	q := regexp.MustCompile(`SELECT entry_id, user_id, storage_key, nonce, upload_status, coalesce\(upload_id, ''\) from files\s+WHERE entry_id=\$1`)
+	rows := sqlmock.NewRows([]string{"entry_id", "user_id", "storage_key", "nonce", "upload_status", "upload_id"}).
+		AddRow("e1", "u1", "skey", []byte("n"), "pending", "up1")
 
 	mock.ExpectQuery(q.String()).
 		WithArgs("e1").
@@ -300,7 +317,8 @@ func TestGetByEntryID_OK(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if got.EntryID != "e1" || got.UserID != "u1" || got.StorageKey != "skey" {
+	if got.EntryID != "e1" || got.UserID != "u1" || got.StorageKey != "skey" ||
+		string(got.Nonce) != "n" || got.UploadStatus != "pending" || got.UploadID != "up1" {
 		t.Fatalf("unexpected row: %+v", got)
 	}
 }
@@ -309,7 +327,7 @@ func TestGetByEntryID_QueryErr(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := regexp.MustCompile(`SELECT entry_id, user_id, storage_key from files\s+WHERE entry_id=\$1`)
+	q := regexp.MustCompile(`SELECT entry_id, user_id, storage_key, nonce, upload_status, coalesce\(upload_id, ''\) from files\s+WHERE entry_id=\$1`)
 	mock.ExpectQuery(q.String()).
 		WithArgs("bad").
 		WillReturnError(errors.New("db err"))
@@ -319,3 +337,259 @@ func TestGetByEntryID_QueryErr(t *testing.T) {
 		t.Fatalf("expected wrapped select error, got %v", err)
 	}
 }
+
+func TestGetByEntryID_NotFound(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := regexp.MustCompile(`SELECT entry_id, user_id, storage_key, nonce, upload_status, coalesce\(upload_id, ''\) from files\s+WHERE entry_id=\$1`)
+	mock.ExpectQuery(q.String()).
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := repo.GetByEntryID(context.Background(), "missing")
+	if !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want common.ErrorNotFound, got %v", err)
+	}
+}
+
+func TestSetUploadID(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := regexp.MustCompile(`update files set upload_id=NULLIF\(\$4, ''\) where entry_id=\$1 and user_id=\$2 and storage_key=\$3 and deleted=false`)
+	mock.ExpectExec(q.String()).
+		WithArgs("e1", "u1", "skey", "up1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(q.String()).
+		WithArgs("e1", "u1", "old-key", "").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.SetUploadID(context.Background(), "u1", "e1", "skey", "up1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the file was replaced meanwhile
+	if err := repo.SetUploadID(context.Background(), "u1", "e1", "old-key", ""); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want common.ErrorNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestMarkDeleted_OKAndNoFile(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := regexp.MustCompile(`update files set deleted=true, updated_at=now\(\) where entry_id=\$1 and user_id=\$2 and deleted=false`)
+	mock.ExpectExec(q.String()).
+		WithArgs("e1", "u1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(q.String()).
+		WithArgs("nofile", "u1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.MarkDeleted(context.Background(), "u1", "e1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := repo.MarkDeleted(context.Background(), "u1", "nofile"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestMarkDeleted_DBErr(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := regexp.MustCompile(`update files set deleted=true`)
+	mock.ExpectExec(q.String()).
+		WithArgs("e1", "u1").
+		WillReturnError(errors.New("db err"))
+
+	err := repo.MarkDeleted(context.Background(), "u1", "e1")
+	if err == nil || !regexp.MustCompile(`failed to mark deleted: .*db err`).MatchString(err.Error()) {
+		t.Fatalf("expected wrapped db error, got %v", err)
+	}
+}
+
+func TestRestore_RevivesTombstone(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := regexp.MustCompile(`update files set deleted=false, version=\$3, updated_at=now\(\) where entry_id=\$1 and user_id=\$2 and deleted=true`)
+	mock.ExpectExec(q.String()).
+		WithArgs("e1", "u1", int64(9)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.Restore(context.Background(), "u1", "e1", 9); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPurge_ReturnsStorageKey(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := regexp.MustCompile(`DELETE FROM files WHERE entry_id=\$1 AND user_id=\$2 RETURNING storage_key`)
+	mock.ExpectQuery(q.String()).
+		WithArgs("e1", "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"storage_key"}).AddRow("users/k"))
+	mock.ExpectQuery(q.String()).
+		WithArgs("nofile", "u1").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(q.String()).
+		WithArgs("e2", "u1").
+		WillReturnError(errors.New("db err"))
+
+	key, err := repo.Purge(context.Background(), "u1", "e1")
+	if err != nil || key != "users/k" {
+		t.Fatalf("got %q, %v", key, err)
+	}
+	key, err = repo.Purge(context.Background(), "u1", "nofile")
+	if err != nil || key != "" {
+		t.Fatalf("got %q, %v", key, err)
+	}
+	if _, err := repo.Purge(context.Background(), "u1", "e2"); err == nil || !regexp.MustCompile(`failed to purge file: .*db err`).MatchString(err.Error()) {
+		t.Fatalf("expected wrapped db error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPurgeDeletedByUser_RemovesTombstonesOnly(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := regexp.QuoteMeta(`DELETE FROM files WHERE user_id=$1 AND deleted=true`)
+	mock.ExpectExec(q).WithArgs("u1").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(q).WithArgs("u2").WillReturnError(errors.New("db err"))
+
+	if err := repo.PurgeDeletedByUser(context.Background(), "u1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := repo.PurgeDeletedByUser(context.Background(), "u2"); err == nil || !regexp.MustCompile(`failed to purge files: .*db err`).MatchString(err.Error()) {
+		t.Fatalf("expected wrapped db error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestUpdateKey_OKAndNotFound(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := regexp.MustCompile(`update files set encrypted_file_key=\$3, version=\$4, updated_at=now\(\) where entry_id=\$1 and user_id=\$2 and deleted=false`)
+	mock.ExpectExec(q.String()).
+		WithArgs("e1", "u1", []byte("wk"), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(q.String()).
+		WithArgs("e2", "u1", []byte("wk"), int64(8)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.UpdateKey(context.Background(), "u1", "e1", []byte("wk"), 7); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := repo.UpdateKey(context.Background(), "u1", "e2", []byte("wk"), 8); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("expected ErrorNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestSelectStalePending(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	before := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	since := before.Add(-time.Hour)
+	q := regexp.MustCompile(`SELECT entry_id, user_id, storage_key, coalesce\(upload_id, ''\), updated_at from files\s+WHERE upload_status='pending' AND NOT deleted AND updated_at < \$1\s+ORDER BY updated_at`)
+	mock.ExpectQuery(q.String()).
+		WithArgs(before).
+		WillReturnRows(sqlmock.NewRows([]string{"entry_id", "user_id", "storage_key", "upload_id", "updated_at"}).
+			AddRow("e1", "u1", "k1", "", since).
+			AddRow("e2", "u2", "k2", "up2", since))
+	mock.ExpectQuery(q.String()).
+		WithArgs(before).
+		WillReturnError(errors.New("boom"))
+
+	got, err := repo.SelectStalePending(context.Background(), before)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0].EntryID != "e1" || got[1].UploadID != "up2" || !got[1].UpdatedAt.Equal(since) || got[0].UploadStatus != "pending" {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+	if _, err := repo.SelectStalePending(context.Background(), before); err == nil {
+		t.Fatalf("expected error")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestSelectByStorageKeys(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	deletedAt := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	q := regexp.MustCompile(`SELECT entry_id, user_id, storage_key, deleted, updated_at from files\s+WHERE storage_key IN \(\$1, \$2, \$3\)`)
+	mock.ExpectQuery(q.String()).
+		WithArgs("k1", "k2", "k3").
+		WillReturnRows(sqlmock.NewRows([]string{"entry_id", "user_id", "storage_key", "deleted", "updated_at"}).
+			AddRow("e1", "u1", "k1", false, deletedAt).
+			AddRow("e2", "u1", "k2", true, deletedAt))
+	mock.ExpectQuery(`WHERE storage_key IN \(\$1\)`).
+		WithArgs("k1").
+		WillReturnError(errors.New("boom"))
+
+	got, err := repo.SelectByStorageKeys(context.Background(), []string{"k1", "k2", "k3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0].StorageKey != "k1" || got[0].Deleted || !got[1].Deleted || !got[1].UpdatedAt.Equal(deletedAt) {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+	if _, err := repo.SelectByStorageKeys(context.Background(), []string{"k1"}); err == nil {
+		t.Fatalf("expected error")
+	}
+	if got, err := repo.SelectByStorageKeys(context.Background(), nil); err != nil || got != nil {
+		t.Fatalf("no keys: %v, %v", got, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPurgeDeletedByStorageKey(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	before := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	q := regexp.MustCompile(`DELETE FROM files WHERE storage_key=\$1 AND deleted AND updated_at < \$2`)
+	mock.ExpectExec(q.String()).WithArgs("k1", before).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(q.String()).WithArgs("k2", before).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(q.String()).WithArgs("k3", before).WillReturnError(errors.New("boom"))
+
+	if ok, err := repo.PurgeDeletedByStorageKey(context.Background(), "k1", before); err != nil || !ok {
+		t.Fatalf("k1: %v, %v", ok, err)
+	}
+	// restored or deleted too recently
+	if ok, err := repo.PurgeDeletedByStorageKey(context.Background(), "k2", before); err != nil || ok {
+		t.Fatalf("k2: %v, %v", ok, err)
+	}
+	if _, err := repo.PurgeDeletedByStorageKey(context.Background(), "k3", before); err == nil {
+		t.Fatalf("expected error")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
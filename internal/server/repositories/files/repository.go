@@ -4,6 +4,7 @@ package files
 
 import (
 	"context"
+	"time"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
 )
@@ -13,14 +14,58 @@ type Repository interface {
 	// CreateOrUpdate inserts a new file row or updates an existing one by entry_id.
 	CreateOrUpdate(ctx context.Context, file *models.File) error
 
-	// SelectUpdated returns files for the given user with version strictly greater
-	// than minVersion (used for incremental synchronization).
+	// SelectUpdated returns non-deleted files for the given user with version
+	// strictly greater than minVersion (used for incremental synchronization).
 	SelectUpdated(ctx context.Context, userID string, minVersion int64) ([]*models.File, error)
 
-	// MarkUploaded marks the file for the given entry as uploaded (e.g., sets status
-	// to "completed").
-	MarkUploaded(ctx context.Context, id string) error
+	// MarkUploaded marks the file for the given entry owned by userID as uploaded
+	// (e.g., sets status to "completed"). Returns common.ErrorNotFound if the
+	// user has no such file.
+	MarkUploaded(ctx context.Context, userID string, id string) error
 
-	// GetByEntryID returns minimal file metadata for authorization and URL generation.
+	// UpdateKey replaces the wrapped key of the live file of the given entry
+	// owned by userID and stamps it with version. Returns
+	// common.ErrorNotFound if the user has no such file.
+	UpdateKey(ctx context.Context, userID string, id string, key []byte, version int64) error
+
+	// MarkDeleted tombstones the file of the given entry owned by userID.
+	// It is a no-op if the entry has no live file.
+	MarkDeleted(ctx context.Context, userID string, id string) error
+
+	// Restore revives the tombstoned file of the given entry owned by userID
+	// under a new version. It is a no-op if the entry has no deleted file.
+	Restore(ctx context.Context, userID string, id string, version int64) error
+
+	// Purge permanently removes the file row of the given entry owned by
+	// userID and returns the storage key of its object ("" if there was none).
+	Purge(ctx context.Context, userID string, id string) (string, error)
+
+	// GetByEntryID returns the file metadata used for authorization, URL
+	// generation and multipart uploads. Returns common.ErrorNotFound if the
+	// entry has no live file.
 	GetByEntryID(ctx context.Context, id string) (*models.File, error)
+
+	// SetUploadID records the multipart upload in progress for the live file
+	// of the given entry owned by userID, or clears it if uploadID is empty.
+	// The file must still be stored under storageKey. Returns
+	// common.ErrorNotFound otherwise.
+	SetUploadID(ctx context.Context, userID string, id string, storageKey string, uploadID string) error
+
+	// SelectStalePending returns the live files of all users that have been
+	// waiting for their upload since before the given time, oldest first.
+	SelectStalePending(ctx context.Context, before time.Time) ([]*models.File, error)
+
+	// SelectByStorageKeys returns the rows, live or tombstoned, of all users
+	// stored under the given keys, with their Deleted flag and UpdatedAt.
+	SelectByStorageKeys(ctx context.Context, keys []string) ([]*models.File, error)
+
+	// PurgeDeletedByUser permanently removes the tombstoned rows of userID.
+	// Their objects are left to the garbage collection of unreferenced
+	// objects.
+	PurgeDeletedByUser(ctx context.Context, userID string) error
+
+	// PurgeDeletedByStorageKey permanently removes the tombstoned row stored
+	// under key if it was deleted before the given time, and reports whether
+	// a row was removed.
+	PurgeDeletedByStorageKey(ctx context.Context, key string, before time.Time) (bool, error)
 }
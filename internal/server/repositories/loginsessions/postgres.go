@@ -0,0 +1,69 @@
+// Package loginsessions provides a PostgreSQL-backed repository for the
+// server-side state of SRP logins.
+package loginsessions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	"github.com/dmitrijs2005/gophkeeper/internal/dbx"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+)
+
+// PostgresRepository implements login session storage over dbx.DBTX
+// (satisfied by *sql.DB or *sql.Tx).
+type PostgresRepository struct {
+	db dbx.DBTX
+}
+
+// NewPostgresRepository constructs a repository bound to the given DBTX.
+func NewPostgresRepository(db dbx.DBTX) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+// Create inserts a new login session expiring at now+validity and returns
+// its generated ID.
+func (r *PostgresRepository) Create(ctx context.Context, session *models.LoginSession, validity time.Duration) (string, error) {
+	query := `
+		INSERT INTO login_sessions (user_id, client_public, server_secret, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+	var id string
+	if err := r.db.QueryRowContext(ctx, query,
+		session.UserID, session.ClientPublic, session.ServerSecret, time.Now().Add(validity)).Scan(&id); err != nil {
+		return "", fmt.Errorf("db error: %w", err)
+	}
+	return id, nil
+}
+
+// Take deletes the unexpired session with the given ID and returns it.
+// Returns common.ErrorNotFound if there is no such session.
+func (r *PostgresRepository) Take(ctx context.Context, id string) (*models.LoginSession, error) {
+	query := `
+		DELETE FROM login_sessions
+		WHERE id = $1 AND expires_at > now()
+		RETURNING id, user_id, client_public, server_secret, expires_at
+	`
+	s := &models.LoginSession{}
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&s.ID, &s.UserID, &s.ClientPublic, &s.ServerSecret, &s.Expires); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.ErrorNotFound
+		}
+		return nil, fmt.Errorf("db error: %w", err)
+	}
+	return s, nil
+}
+
+// DeleteExpired removes sessions whose expiry has passed.
+func (r *PostgresRepository) DeleteExpired(ctx context.Context) error {
+	query := `DELETE FROM login_sessions WHERE expires_at <= now()`
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	return nil
+}
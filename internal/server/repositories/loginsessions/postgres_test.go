@@ -0,0 +1,96 @@
+package loginsessions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+)
+
+func newRepoWithMock(t *testing.T) (*PostgresRepository, sqlmock.Sqlmock, *sql.DB) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	return NewPostgresRepository(db), mock, db
+}
+
+func TestCreate_ReturnsID(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `(?s)^INSERT\s+INTO\s+login_sessions\s*\(user_id,\s*client_public,\s*server_secret,\s*expires_at\)\s*VALUES\s*\(\$1,\s*\$2,\s*\$3,\s*\$4\)\s*RETURNING\s+id\s*$`
+	mock.ExpectQuery(q).
+		WithArgs("u1", []byte("A"), []byte("b"), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("s1"))
+	mock.ExpectQuery(q).
+		WithArgs("u1", []byte("A"), []byte("b"), sqlmock.AnyArg()).
+		WillReturnError(errors.New("db down"))
+
+	s := &models.LoginSession{UserID: "u1", ClientPublic: []byte("A"), ServerSecret: []byte("b")}
+	id, err := repo.Create(context.Background(), s, time.Minute)
+	if err != nil || id != "s1" {
+		t.Fatalf("got %q, %v", id, err)
+	}
+	if _, err := repo.Create(context.Background(), s, time.Minute); err == nil || !regexp.MustCompile(`db error: .*db down`).MatchString(err.Error()) {
+		t.Fatalf("expected wrapped db error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestTake_DeletesOnceAndMapsNotFound(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `(?s)^DELETE\s+FROM\s+login_sessions\s+WHERE\s+id\s*=\s*\$1\s+AND\s+expires_at\s*>\s*now\(\)\s+RETURNING\s+id,\s*user_id,\s*client_public,\s*server_secret,\s*expires_at\s*$`
+	expires := time.Now().Add(time.Minute)
+	mock.ExpectQuery(q).
+		WithArgs("s1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "client_public", "server_secret", "expires_at"}).
+			AddRow("s1", "u1", []byte("A"), []byte("b"), expires))
+	mock.ExpectQuery(q).
+		WithArgs("s1").
+		WillReturnError(sql.ErrNoRows)
+
+	got, err := repo.Take(context.Background(), "s1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.UserID != "u1" || string(got.ClientPublic) != "A" || string(got.ServerSecret) != "b" {
+		t.Fatalf("unexpected session: %+v", got)
+	}
+	if _, err := repo.Take(context.Background(), "s1"); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("expected ErrorNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `(?s)^DELETE\s+FROM\s+login_sessions\s+WHERE\s+expires_at\s*<=\s*now\(\)$`
+	mock.ExpectExec(q).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(q).WillReturnError(errors.New("db down"))
+
+	if err := repo.DeleteExpired(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := repo.DeleteExpired(context.Background()); err == nil {
+		t.Fatalf("expected error")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+// Package loginsessions declares the server-side repository contract for the
+// short-lived state of SRP logins between LoginStart and LoginFinish.
+package loginsessions
+
+import (
+	"context"
+	"time"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+)
+
+// Repository defines operations for storing and consuming login sessions.
+type Repository interface {
+	// Create stores a new session with an expiry of now+validity and returns
+	// its ID.
+	Create(ctx context.Context, session *models.LoginSession, validity time.Duration) (string, error)
+
+	// Take removes the session with the given ID and returns it, so that a
+	// session can be finished at most once. Implementations should return a
+	// not-found error when the session is absent or expired.
+	Take(ctx context.Context, id string) (*models.LoginSession, error)
+
+	// DeleteExpired removes all expired sessions.
+	DeleteExpired(ctx context.Context) error
+}
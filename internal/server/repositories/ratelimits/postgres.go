@@ -0,0 +1,87 @@
+// Package ratelimits provides a PostgreSQL-backed repository for the
+// throttling state of the authentication RPCs.
+package ratelimits
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/dbx"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+)
+
+// PostgresRepository implements rate limit storage over dbx.DBTX (satisfied
+// by *sql.DB or *sql.Tx). Lock only locks within a transaction.
+type PostgresRepository struct {
+	db dbx.DBTX
+}
+
+// NewPostgresRepository constructs a repository bound to the given DBTX.
+func NewPostgresRepository(db dbx.DBTX) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+// Lock creates the state of key with burst tokens unless it exists and
+// selects it FOR UPDATE.
+func (r *PostgresRepository) Lock(ctx context.Context, key string, burst float64) (*models.RateLimit, error) {
+	insert := `
+		INSERT INTO auth_rate_limits (key, tokens, refilled_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO NOTHING
+	`
+	if _, err := r.db.ExecContext(ctx, insert, key, burst, time.Now().UTC()); err != nil {
+		return nil, fmt.Errorf("db error: %w", err)
+	}
+
+	query := `
+		SELECT key, tokens, refilled_at, failures, failed_at, locked_until
+		FROM auth_rate_limits
+		WHERE key = $1
+		FOR UPDATE
+	`
+	l := &models.RateLimit{}
+	var failedAt, lockedUntil sql.NullTime
+	if err := r.db.QueryRowContext(ctx, query, key).Scan(&l.Key, &l.Tokens, &l.RefilledAt, &l.Failures, &failedAt, &lockedUntil); err != nil {
+		return nil, fmt.Errorf("db error: %w", err)
+	}
+	l.FailedAt, l.LockedUntil = failedAt.Time, lockedUntil.Time
+	return l, nil
+}
+
+// Save updates the bucket, failures and lockout of l.Key.
+func (r *PostgresRepository) Save(ctx context.Context, l *models.RateLimit) error {
+	query := `
+		UPDATE auth_rate_limits
+		SET tokens = $2, refilled_at = $3, failures = $4, failed_at = $5, locked_until = $6
+		WHERE key = $1
+	`
+	if _, err := r.db.ExecContext(ctx, query, l.Key, l.Tokens, l.RefilledAt, l.Failures, nullTime(l.FailedAt), nullTime(l.LockedUntil)); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	return nil
+}
+
+// nullTime stores the zero time as NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// DeleteIdle removes the state of keys refilled before the given time whose
+// lockout, if any, ended before then as well.
+func (r *PostgresRepository) DeleteIdle(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		DELETE FROM auth_rate_limits
+		WHERE refilled_at < $1 AND (locked_until IS NULL OR locked_until < $1)
+	`
+	res, err := r.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("db error: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("db error: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,103 @@
+package ratelimits
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+)
+
+func newRepoWithMock(t *testing.T) (*PostgresRepository, sqlmock.Sqlmock, *sql.DB) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	return NewPostgresRepository(db), mock, db
+}
+
+func TestLock(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	insert := `(?s)^INSERT\s+INTO\s+auth_rate_limits\s+\(key,\s*tokens,\s*refilled_at\)\s+VALUES\s+\(\$1,\s*\$2,\s*\$3\)\s+ON\s+CONFLICT\s+\(key\)\s+DO\s+NOTHING\s*$`
+	sel := `(?s)^SELECT\s+key,\s*tokens,\s*refilled_at,\s*failures,\s*failed_at,\s*locked_until\s+FROM\s+auth_rate_limits\s+WHERE\s+key\s*=\s*\$1\s+FOR\s+UPDATE\s*$`
+	cols := []string{"key", "tokens", "refilled_at", "failures", "failed_at", "locked_until"}
+
+	now := time.Now().UTC()
+	mock.ExpectExec(insert).WithArgs("ip:10.0.0.1", 10.0, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(sel).WithArgs("ip:10.0.0.1").
+		WillReturnRows(sqlmock.NewRows(cols).AddRow("ip:10.0.0.1", 10.0, now, 0, nil, nil))
+	mock.ExpectExec(insert).WithArgs("user:alice", 5.0, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(sel).WithArgs("user:alice").
+		WillReturnRows(sqlmock.NewRows(cols).AddRow("user:alice", 2.5, now, 6, now, now.Add(time.Minute)))
+	mock.ExpectExec(insert).WithArgs("user:bob", 5.0, sqlmock.AnyArg()).WillReturnError(errors.New("db down"))
+
+	l, err := repo.Lock(context.Background(), "ip:10.0.0.1", 10)
+	if err != nil || l.Tokens != 10 || l.Failures != 0 || !l.FailedAt.IsZero() || !l.LockedUntil.IsZero() {
+		t.Fatalf("new key: %+v, %v", l, err)
+	}
+	l, err = repo.Lock(context.Background(), "user:alice", 5)
+	if err != nil || l.Tokens != 2.5 || l.Failures != 6 || !l.FailedAt.Equal(now) || !l.LockedUntil.Equal(now.Add(time.Minute)) {
+		t.Fatalf("existing key: %+v, %v", l, err)
+	}
+	if _, err := repo.Lock(context.Background(), "user:bob", 5); err == nil || !regexp.MustCompile(`db error: .*db down`).MatchString(err.Error()) {
+		t.Fatalf("expected wrapped db error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestSave(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `(?s)^UPDATE\s+auth_rate_limits\s+SET\s+tokens\s*=\s*\$2,\s*refilled_at\s*=\s*\$3,\s*failures\s*=\s*\$4,\s*failed_at\s*=\s*\$5,\s*locked_until\s*=\s*\$6\s+WHERE\s+key\s*=\s*\$1\s*$`
+
+	now := time.Now().UTC()
+	mock.ExpectExec(q).
+		WithArgs("user:alice", 4.0, now, 0, sql.NullTime{}, sql.NullTime{}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(q).
+		WithArgs("user:alice", 4.0, now, 5, sql.NullTime{Time: now, Valid: true}, sql.NullTime{Time: now.Add(time.Minute), Valid: true}).
+		WillReturnError(errors.New("db down"))
+
+	if err := repo.Save(context.Background(), &models.RateLimit{Key: "user:alice", Tokens: 4, RefilledAt: now}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := repo.Save(context.Background(), &models.RateLimit{Key: "user:alice", Tokens: 4, RefilledAt: now, Failures: 5, FailedAt: now, LockedUntil: now.Add(time.Minute)})
+	if err == nil || !regexp.MustCompile(`db error: .*db down`).MatchString(err.Error()) {
+		t.Fatalf("expected wrapped db error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestDeleteIdle(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `(?s)^DELETE\s+FROM\s+auth_rate_limits\s+WHERE\s+refilled_at\s*<\s*\$1\s+AND\s+\(locked_until\s+IS\s+NULL\s+OR\s+locked_until\s*<\s*\$1\)\s*$`
+
+	before := time.Now().UTC().Add(-time.Hour)
+	mock.ExpectExec(q).WithArgs(before).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(q).WithArgs(before).WillReturnError(errors.New("db down"))
+
+	n, err := repo.DeleteIdle(context.Background(), before)
+	if err != nil || n != 3 {
+		t.Fatalf("got %d, %v", n, err)
+	}
+	if _, err := repo.DeleteIdle(context.Background(), before); err == nil || !regexp.MustCompile(`db error: .*db down`).MatchString(err.Error()) {
+		t.Fatalf("expected wrapped db error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
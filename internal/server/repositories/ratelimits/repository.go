@@ -0,0 +1,26 @@
+// Package ratelimits declares the server-side repository contract for the
+// throttling state of the authentication RPCs, which is shared by all server
+// instances.
+package ratelimits
+
+import (
+	"context"
+	"time"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+)
+
+// Repository defines operations for reading and updating rate limit state.
+type Repository interface {
+	// Lock returns the state of key, creating it with a full bucket of
+	// burst tokens if it does not exist yet, and locks it until the end of
+	// the transaction the repository is bound to.
+	Lock(ctx context.Context, key string, burst float64) (*models.RateLimit, error)
+
+	// Save stores the state of l.Key.
+	Save(ctx context.Context, l *models.RateLimit) error
+
+	// DeleteIdle removes the state of keys not used since before and not
+	// locked out any more, and returns how many were removed.
+	DeleteIdle(ctx context.Context, before time.Time) (int64, error)
+}
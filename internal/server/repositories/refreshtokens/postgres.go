@@ -25,28 +25,81 @@ func NewPostgresRepository(db dbx.DBTX) *PostgresRepository {
 	return &PostgresRepository{db: db}
 }
 
-// Create inserts a new refresh token for userID with an expiry time of now+validity.
-func (r *PostgresRepository) Create(ctx context.Context, userID string, token string, validity time.Duration) error {
+// Create inserts a new refresh token for userID issued to device with an
+// expiry time of now+validity and returns the ID of the session it starts.
+func (r *PostgresRepository) Create(ctx context.Context, userID string, token string, validity time.Duration, device models.Device) (string, error) {
 	query := `
-		INSERT INTO refresh_tokens (user_id, token, expires_at)
-		VALUES ($1, $2, $3)
+		INSERT INTO refresh_tokens (user_id, token, expires_at, device_name, platform, client_version, ip)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
 	`
-	if _, err := r.db.ExecContext(ctx, query, userID, token, time.Now().Add(validity)); err != nil {
-		return fmt.Errorf("error performing sql request: %v", err)
+	var id string
+	if err := r.db.QueryRowContext(ctx, query, userID, token, time.Now().Add(validity),
+		device.Name, device.Platform, device.ClientVersion, device.IP).Scan(&id); err != nil {
+		return "", fmt.Errorf("error performing sql request: %v", err)
 	}
-	return nil
+	return id, nil
 }
 
 // Find returns the refresh token row for the given token string.
 // If not found, it returns common.ErrorNotFound.
 func (r *PostgresRepository) Find(ctx context.Context, token string) (*models.RefreshToken, error) {
 	query := `
-		SELECT user_id, expires_at
+		SELECT id, user_id, expires_at
 		FROM refresh_tokens
 		WHERE token = $1
 	`
 	refreshToken := &models.RefreshToken{}
-	if err := r.db.QueryRowContext(ctx, query, token).Scan(&refreshToken.UserID, &refreshToken.Expires); err != nil {
+	if err := r.db.QueryRowContext(ctx, query, token).Scan(&refreshToken.ID, &refreshToken.UserID, &refreshToken.Expires); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.ErrorNotFound
+		}
+		return nil, fmt.Errorf("db error: %w", err)
+	}
+	return refreshToken, nil
+}
+
+// Rotate replaces oldToken by newToken expiring at now+validity within the
+// same session, records the use from ip and keeps oldToken in
+// rotated_refresh_tokens, all in one statement. Returns common.ErrorNotFound
+// if oldToken does not exist (anymore).
+func (r *PostgresRepository) Rotate(ctx context.Context, oldToken string, newToken string, validity time.Duration, ip string) error {
+	query := `
+		WITH rotated AS (
+			UPDATE refresh_tokens
+			SET token = $2, expires_at = $3, ip = $4, last_used_at = now()
+			WHERE token = $1
+			RETURNING id
+		)
+		INSERT INTO rotated_refresh_tokens (token, session_id)
+		SELECT $1, id FROM rotated
+	`
+	res, err := r.db.ExecContext(ctx, query, oldToken, newToken, time.Now().Add(validity), ip)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if n == 0 {
+		return common.ErrorNotFound
+	}
+	return nil
+}
+
+// FindRotated returns the session that token was rotated out of, with the
+// session's ID, owner and expiry. If token was never rotated or the session
+// is gone, it returns common.ErrorNotFound.
+func (r *PostgresRepository) FindRotated(ctx context.Context, token string) (*models.RefreshToken, error) {
+	query := `
+		SELECT t.id, t.user_id, t.expires_at
+		FROM rotated_refresh_tokens r
+		JOIN refresh_tokens t ON t.id = r.session_id
+		WHERE r.token = $1
+	`
+	refreshToken := &models.RefreshToken{}
+	if err := r.db.QueryRowContext(ctx, query, token).Scan(&refreshToken.ID, &refreshToken.UserID, &refreshToken.Expires); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, common.ErrorNotFound
 		}
@@ -66,3 +119,96 @@ func (r *PostgresRepository) Delete(ctx context.Context, token string) error {
 	}
 	return nil
 }
+
+// DeleteByUser removes all refresh tokens issued to userID.
+func (r *PostgresRepository) DeleteByUser(ctx context.Context, userID string) error {
+	query := `
+		DELETE FROM refresh_tokens
+		WHERE user_id = $1
+	`
+	if _, err := r.db.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	return nil
+}
+
+// ListByUser returns the unexpired sessions of userID, most recently used
+// first.
+func (r *PostgresRepository) ListByUser(ctx context.Context, userID string) ([]*models.Session, error) {
+	query := `
+		SELECT id, device_name, platform, client_version, ip, COALESCE(created_at, last_used_at), last_used_at, expires_at
+		FROM refresh_tokens
+		WHERE user_id = $1 AND expires_at > now()
+		ORDER BY last_used_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("db error: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*models.Session
+	for rows.Next() {
+		s := &models.Session{}
+		if err := rows.Scan(&s.ID, &s.Device.Name, &s.Device.Platform, &s.Device.ClientVersion, &s.Device.IP,
+			&s.CreatedAt, &s.LastUsedAt, &s.Expires); err != nil {
+			return nil, fmt.Errorf("db error: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("db error: %w", err)
+	}
+	return sessions, nil
+}
+
+// Exists reports whether the session id exists and has not expired.
+func (r *PostgresRepository) Exists(ctx context.Context, id string) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM refresh_tokens WHERE id = $1 AND expires_at > now())
+	`
+	var ok bool
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&ok); err != nil {
+		return false, fmt.Errorf("db error: %w", err)
+	}
+	return ok, nil
+}
+
+// DeleteSession removes the session id of userID. Returns
+// common.ErrorNotFound if userID has no such session.
+func (r *PostgresRepository) DeleteSession(ctx context.Context, userID string, id string) error {
+	query := `
+		DELETE FROM refresh_tokens
+		WHERE user_id = $1 AND id = $2
+	`
+	res, err := r.db.ExecContext(ctx, query, userID, id)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if n == 0 {
+		return common.ErrorNotFound
+	}
+	return nil
+}
+
+// DeleteOtherSessions removes every session of userID except keepID and
+// returns how many were removed.
+func (r *PostgresRepository) DeleteOtherSessions(ctx context.Context, userID string, keepID string) (int64, error) {
+	query := `
+		DELETE FROM refresh_tokens
+		WHERE user_id = $1 AND id <> $2
+	`
+	res, err := r.db.ExecContext(ctx, query, userID, keepID)
+	if err != nil {
+		return 0, fmt.Errorf("db error: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("db error: %w", err)
+	}
+	return n, nil
+}
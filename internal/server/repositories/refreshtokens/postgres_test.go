@@ -10,6 +10,7 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
 )
 
 func newRepoWithMock(t *testing.T) (*PostgresRepository, sqlmock.Sqlmock, *sql.DB) {
@@ -25,16 +26,20 @@ func TestCreate_Success(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := `(?s)^INSERT\s+INTO\s+refresh_tokens\b.*VALUES\s*\(\$1,\s*\$2,\s*\$3\)\s*$`
+	q := `(?s)^INSERT\s+INTO\s+refresh_tokens\b.*VALUES\s*\(\$1,\s*\$2,\s*\$3,\s*\$4,\s*\$5,\s*\$6,\s*\$7\)\s+RETURNING\s+id\s*$`
 
-	mock.ExpectExec(q).
-		WithArgs("u1", "tok123", sqlmock.AnyArg()). // expires_at = time.Now().Add(validity)
-		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(q).
+		WithArgs("u1", "tok123", sqlmock.AnyArg(), "laptop", "linux/amd64", "1.2.0", "10.0.0.1"). // expires_at = time.Now().Add(validity)
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("s1"))
 
-	err := repo.Create(context.Background(), "u1", "tok123", 30*time.Minute)
+	id, err := repo.Create(context.Background(), "u1", "tok123", 30*time.Minute,
+		models.Device{Name: "laptop", Platform: "linux/amd64", ClientVersion: "1.2.0", IP: "10.0.0.1"})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	if id != "s1" {
+		t.Fatalf("session id: got %q", id)
+	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("unmet expectations: %v", err)
 	}
@@ -44,13 +49,12 @@ func TestCreate_DBError(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := `(?s)^INSERT\s+INTO\s+refresh_tokens\b.*VALUES\s*\(\$1,\s*\$2,\s*\$3\)\s*$`
+	q := `(?s)^INSERT\s+INTO\s+refresh_tokens\b.*RETURNING\s+id\s*$`
 
-	mock.ExpectExec(q).
-		WithArgs("u1", "tok123", sqlmock.AnyArg()).
+	mock.ExpectQuery(q).
 		WillReturnError(errors.New("db down"))
 
-	err := repo.Create(context.Background(), "u1", "tok123", time.Hour)
+	_, err := repo.Create(context.Background(), "u1", "tok123", time.Hour, models.Device{})
 	if err == nil || !regexp.MustCompile(`error performing sql request: .*db down`).MatchString(err.Error()) {
 		t.Fatalf("expected wrapped db error, got %v", err)
 	}
@@ -60,11 +64,11 @@ func TestFind_Found(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := `(?s)^SELECT\s+user_id,\s*expires_at\s+FROM\s+refresh_tokens\s+WHERE\s+token\s*=\s*\$1\s*$`
+	q := `(?s)^SELECT\s+id,\s*user_id,\s*expires_at\s+FROM\s+refresh_tokens\s+WHERE\s+token\s*=\s*\$1\s*$`
 
 	expires := time.Now().Add(10 * time.Minute)
-	rows := sqlmock.NewRows([]string{"user_id", "expires_at"}).
-		AddRow("u1", expires)
+	rows := sqlmock.NewRows([]string{"id", "user_id", "expires_at"}).
+		AddRow("s1", "u1", expires)
 
 	mock.ExpectQuery(q).
 		WithArgs("tok123").
@@ -74,7 +78,7 @@ func TestFind_Found(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if got.UserID != "u1" || !got.Expires.Equal(expires) {
+	if got.ID != "s1" || got.UserID != "u1" || !got.Expires.Equal(expires) {
 		t.Fatalf("unexpected row: %+v", got)
 	}
 }
@@ -83,7 +87,7 @@ func TestFind_NotFound(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := `(?s)^SELECT\s+user_id,\s*expires_at\s+FROM\s+refresh_tokens\s+WHERE\s+token\s*=\s*\$1\s*$`
+	q := `(?s)^SELECT\s+id,\s*user_id,\s*expires_at\s+FROM\s+refresh_tokens\s+WHERE\s+token\s*=\s*\$1\s*$`
 
 	mock.ExpectQuery(q).
 		WithArgs("missing").
@@ -99,7 +103,7 @@ func TestFind_DBError(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := `(?s)^SELECT\s+user_id,\s*expires_at\s+FROM\s+refresh_tokens\s+WHERE\s+token\s*=\s*\$1\s*$`
+	q := `(?s)^SELECT\s+id,\s*user_id,\s*expires_at\s+FROM\s+refresh_tokens\s+WHERE\s+token\s*=\s*\$1\s*$`
 
 	mock.ExpectQuery(q).
 		WithArgs("tok123").
@@ -141,3 +145,180 @@ func TestDelete_DBError(t *testing.T) {
 		t.Fatalf("expected wrapped db error, got %v", err)
 	}
 }
+
+func TestDeleteByUser(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `(?s)^DELETE\s+FROM\s+refresh_tokens\s+WHERE\s+user_id\s*=\s*\$1\s*$`
+
+	mock.ExpectExec(q).
+		WithArgs("u1").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(q).
+		WithArgs("u2").
+		WillReturnError(errors.New("db err"))
+
+	if err := repo.DeleteByUser(context.Background(), "u1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := repo.DeleteByUser(context.Background(), "u2")
+	if err == nil || !regexp.MustCompile(`db error: .*db err`).MatchString(err.Error()) {
+		t.Fatalf("expected wrapped db error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `(?s)^WITH\s+rotated\s+AS\s+\(\s*UPDATE\s+refresh_tokens\s+SET\s+token\s*=\s*\$2,\s*expires_at\s*=\s*\$3,\s*ip\s*=\s*\$4,\s*last_used_at\s*=\s*now\(\)\s+WHERE\s+token\s*=\s*\$1\s+RETURNING\s+id\s*\)\s*` +
+		`INSERT\s+INTO\s+rotated_refresh_tokens\s+\(token,\s*session_id\)\s+SELECT\s+\$1,\s*id\s+FROM\s+rotated\s*$`
+
+	mock.ExpectExec(q).
+		WithArgs("old", "new", sqlmock.AnyArg(), "10.0.0.2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(q).
+		WithArgs("gone", "new", sqlmock.AnyArg(), "10.0.0.2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(q).
+		WithArgs("old", "new", sqlmock.AnyArg(), "10.0.0.2").
+		WillReturnError(errors.New("db err"))
+
+	if err := repo.Rotate(context.Background(), "old", "new", time.Hour, "10.0.0.2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := repo.Rotate(context.Background(), "gone", "new", time.Hour, "10.0.0.2"); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want common.ErrorNotFound, got %v", err)
+	}
+	err := repo.Rotate(context.Background(), "old", "new", time.Hour, "10.0.0.2")
+	if err == nil || !regexp.MustCompile(`db error: .*db err`).MatchString(err.Error()) {
+		t.Fatalf("expected wrapped db error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestFindRotated(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `(?s)^SELECT\s+t\.id,\s*t\.user_id,\s*t\.expires_at\s+FROM\s+rotated_refresh_tokens\s+r\s+JOIN\s+refresh_tokens\s+t\s+ON\s+t\.id\s*=\s*r\.session_id\s+WHERE\s+r\.token\s*=\s*\$1\s*$`
+
+	exp := time.Now().Add(time.Hour)
+	mock.ExpectQuery(q).WithArgs("old").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "expires_at"}).AddRow("s1", "u1", exp))
+	mock.ExpectQuery(q).WithArgs("never").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(q).WithArgs("old").WillReturnError(errors.New("db err"))
+
+	got, err := repo.FindRotated(context.Background(), "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.ID != "s1" || got.UserID != "u1" || !got.Expires.Equal(exp) {
+		t.Fatalf("unexpected session: %+v", got)
+	}
+	if _, err := repo.FindRotated(context.Background(), "never"); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want common.ErrorNotFound, got %v", err)
+	}
+	if _, err := repo.FindRotated(context.Background(), "old"); err == nil || !regexp.MustCompile(`db error: .*db err`).MatchString(err.Error()) {
+		t.Fatalf("expected wrapped db error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestListByUser(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `(?s)^SELECT\s+id,\s*device_name,.*FROM\s+refresh_tokens\s+WHERE\s+user_id\s*=\s*\$1\s+AND\s+expires_at\s*>\s*now\(\)\s+ORDER\s+BY\s+last_used_at\s+DESC\s*$`
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "device_name", "platform", "client_version", "ip", "created_at", "last_used_at", "expires_at"}).
+		AddRow("s2", "phone", "android/arm64", "1.2.0", "10.0.0.3", now.Add(-time.Hour), now, now.Add(time.Hour)).
+		AddRow("s1", "laptop", "linux/amd64", "1.1.0", "10.0.0.1", now.Add(-2*time.Hour), now.Add(-time.Hour), now.Add(time.Hour))
+	mock.ExpectQuery(q).WithArgs("u1").WillReturnRows(rows)
+	mock.ExpectQuery(q).WithArgs("u2").WillReturnError(errors.New("db err"))
+
+	got, err := repo.ListByUser(context.Background(), "u1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != "s2" || got[1].Device.Name != "laptop" || got[1].Device.Platform != "linux/amd64" ||
+		got[1].Device.ClientVersion != "1.1.0" || got[1].Device.IP != "10.0.0.1" || !got[0].LastUsedAt.Equal(now) {
+		t.Fatalf("unexpected sessions: %+v", got)
+	}
+
+	_, err = repo.ListByUser(context.Background(), "u2")
+	if err == nil || !regexp.MustCompile(`db error: .*db err`).MatchString(err.Error()) {
+		t.Fatalf("expected wrapped db error, got %v", err)
+	}
+}
+
+func TestExists(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `(?s)^SELECT\s+EXISTS\s*\(SELECT\s+1\s+FROM\s+refresh_tokens\s+WHERE\s+id\s*=\s*\$1\s+AND\s+expires_at\s*>\s*now\(\)\)\s*$`
+
+	mock.ExpectQuery(q).WithArgs("s1").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(q).WithArgs("s2").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(q).WithArgs("s3").WillReturnError(errors.New("db err"))
+
+	if ok, err := repo.Exists(context.Background(), "s1"); err != nil || !ok {
+		t.Fatalf("s1: got (%v, %v)", ok, err)
+	}
+	if ok, err := repo.Exists(context.Background(), "s2"); err != nil || ok {
+		t.Fatalf("s2: got (%v, %v)", ok, err)
+	}
+	if _, err := repo.Exists(context.Background(), "s3"); err == nil || !regexp.MustCompile(`db error: .*db err`).MatchString(err.Error()) {
+		t.Fatalf("expected wrapped db error, got %v", err)
+	}
+}
+
+func TestDeleteSession(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `(?s)^DELETE\s+FROM\s+refresh_tokens\s+WHERE\s+user_id\s*=\s*\$1\s+AND\s+id\s*=\s*\$2\s*$`
+
+	mock.ExpectExec(q).WithArgs("u1", "s1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(q).WithArgs("u1", "s2").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(q).WithArgs("u1", "s3").WillReturnError(errors.New("db err"))
+
+	if err := repo.DeleteSession(context.Background(), "u1", "s1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := repo.DeleteSession(context.Background(), "u1", "s2"); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want common.ErrorNotFound, got %v", err)
+	}
+	err := repo.DeleteSession(context.Background(), "u1", "s3")
+	if err == nil || !regexp.MustCompile(`db error: .*db err`).MatchString(err.Error()) {
+		t.Fatalf("expected wrapped db error, got %v", err)
+	}
+}
+
+func TestDeleteOtherSessions(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `(?s)^DELETE\s+FROM\s+refresh_tokens\s+WHERE\s+user_id\s*=\s*\$1\s+AND\s+id\s*<>\s*\$2\s*$`
+
+	mock.ExpectExec(q).WithArgs("u1", "s1").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(q).WithArgs("u2", "s1").WillReturnError(errors.New("db err"))
+
+	n, err := repo.DeleteOtherSessions(context.Background(), "u1", "s1")
+	if err != nil || n != 2 {
+		t.Fatalf("got (%d, %v), want 2 removed", n, err)
+	}
+	_, err = repo.DeleteOtherSessions(context.Background(), "u2", "s1")
+	if err == nil || !regexp.MustCompile(`db error: .*db err`).MatchString(err.Error()) {
+		t.Fatalf("expected wrapped db error, got %v", err)
+	}
+}
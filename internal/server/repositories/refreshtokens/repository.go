@@ -11,14 +11,46 @@ import (
 
 // Repository defines operations for issuing, retrieving, and revoking refresh tokens.
 type Repository interface {
-	// Create stores a new refresh token for userID with an expiry of now+validity.
-	Create(ctx context.Context, userID string, token string, validity time.Duration) error
+	// Create stores a new refresh token for userID issued to device with an
+	// expiry of now+validity. The token starts a session whose ID is returned.
+	Create(ctx context.Context, userID string, token string, validity time.Duration, device models.Device) (string, error)
 
 	// Find looks up a refresh token by its opaque token string and returns its metadata.
 	// Implementations should return a not-found error when the token is absent.
 	Find(ctx context.Context, token string) (*models.RefreshToken, error)
 
+	// Rotate replaces oldToken by newToken with an expiry of now+validity,
+	// keeping the session, and records its use from ip. oldToken is
+	// remembered as rotated so that FindRotated can recognize it being
+	// replayed. Implementations should return a not-found error when
+	// oldToken is absent.
+	Rotate(ctx context.Context, oldToken string, newToken string, validity time.Duration, ip string) error
+
+	// FindRotated returns the session (as a refresh token of its ID) that
+	// token was rotated out of. Implementations should return a not-found
+	// error when token was never rotated or its session is gone.
+	FindRotated(ctx context.Context, token string) (*models.RefreshToken, error)
+
 	// Delete removes a refresh token by its token string. Deleting a non-existent
 	// token should not be considered an error.
 	Delete(ctx context.Context, token string) error
+
+	// DeleteByUser removes all refresh tokens of userID, e.g. after a
+	// password change.
+	DeleteByUser(ctx context.Context, userID string) error
+
+	// ListByUser returns the unexpired sessions of userID, most recently
+	// used first.
+	ListByUser(ctx context.Context, userID string) ([]*models.Session, error)
+
+	// Exists reports whether the session id exists and has not expired.
+	Exists(ctx context.Context, id string) (bool, error)
+
+	// DeleteSession removes the session id of userID. Implementations should
+	// return a not-found error when userID has no such session.
+	DeleteSession(ctx context.Context, userID string, id string) error
+
+	// DeleteOtherSessions removes every session of userID except keepID and
+	// returns how many were removed.
+	DeleteOtherSessions(ctx context.Context, userID string, keepID string) (int64, error)
 }
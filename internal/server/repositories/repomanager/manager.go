@@ -1,6 +1,6 @@
 // Package repomanager defines an abstraction over concrete repository sets
 // used by the server. It centralizes construction of per-boundary repositories
-// (users, refresh tokens, entries, files) and exposes a migrations hook.
+// (users, refresh tokens, login sessions, entries, files, revisions, rate limits, two-factor state) and exposes a migrations hook.
 package repomanager
 
 import (
@@ -10,7 +10,11 @@ import (
 	"github.com/dmitrijs2005/gophkeeper/internal/dbx"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/entries"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/files"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/loginsessions"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/ratelimits"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/refreshtokens"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/revisions"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/twofactor"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/users"
 )
 
@@ -28,8 +32,16 @@ type RepositoryManager interface {
 	Users(db dbx.DBTX) users.Repository
 	// RefreshTokens returns a refreshtokens.Repository bound to the provided DBTX.
 	RefreshTokens(db dbx.DBTX) refreshtokens.Repository
+	// LoginSessions returns a loginsessions.Repository bound to the provided DBTX.
+	LoginSessions(db dbx.DBTX) loginsessions.Repository
 	// Entries returns an entries.Repository bound to the provided DBTX.
 	Entries(db dbx.DBTX) entries.Repository
 	// Files returns a files.Repository bound to the provided DBTX.
 	Files(db dbx.DBTX) files.Repository
+	// Revisions returns a revisions.Repository bound to the provided DBTX.
+	Revisions(db dbx.DBTX) revisions.Repository
+	// RateLimits returns a ratelimits.Repository bound to the provided DBTX.
+	RateLimits(db dbx.DBTX) ratelimits.Repository
+	// TwoFactor returns a twofactor.Repository bound to the provided DBTX.
+	TwoFactor(db dbx.DBTX) twofactor.Repository
 }
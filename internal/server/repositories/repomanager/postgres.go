@@ -10,7 +10,11 @@ import (
 	"github.com/dmitrijs2005/gophkeeper/internal/server/migrations"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/entries"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/files"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/loginsessions"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/ratelimits"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/refreshtokens"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/revisions"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/twofactor"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/users"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/pressly/goose/v3"
@@ -30,6 +34,11 @@ func (m *PostgresRepositoryManager) RefreshTokens(db dbx.DBTX) refreshtokens.Rep
 	return refreshtokens.NewPostgresRepository(db)
 }
 
+// LoginSessions returns a loginsessions.Repository bound to the provided DBTX.
+func (m *PostgresRepositoryManager) LoginSessions(db dbx.DBTX) loginsessions.Repository {
+	return loginsessions.NewPostgresRepository(db)
+}
+
 // Entries returns an entries.Repository bound to the provided DBTX.
 func (m *PostgresRepositoryManager) Entries(db dbx.DBTX) entries.Repository {
 	return entries.NewPostgresRepository(db)
@@ -40,6 +49,21 @@ func (m *PostgresRepositoryManager) Files(db dbx.DBTX) files.Repository {
 	return files.NewPostgresRepository(db)
 }
 
+// Revisions returns a revisions.Repository bound to the provided DBTX.
+func (m *PostgresRepositoryManager) Revisions(db dbx.DBTX) revisions.Repository {
+	return revisions.NewPostgresRepository(db)
+}
+
+// RateLimits returns a ratelimits.Repository bound to the provided DBTX.
+func (m *PostgresRepositoryManager) RateLimits(db dbx.DBTX) ratelimits.Repository {
+	return ratelimits.NewPostgresRepository(db)
+}
+
+// TwoFactor returns a twofactor.Repository bound to the provided DBTX.
+func (m *PostgresRepositoryManager) TwoFactor(db dbx.DBTX) twofactor.Repository {
+	return twofactor.NewPostgresRepository(db)
+}
+
 // gooseUpContext is a seam for testing goose.UpContext.
 var gooseUpContext = func(ctx context.Context, db *sql.DB, dir string, opts ...goose.OptionsFunc) error {
 	return goose.UpContext(ctx, db, dir, opts...)
@@ -9,7 +9,11 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/entries"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/files"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/loginsessions"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/ratelimits"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/refreshtokens"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/revisions"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/twofactor"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/users"
 	"github.com/pressly/goose/v3"
 )
@@ -46,17 +50,33 @@ func TestFactories_ReturnConcreteRepos(t *testing.T) {
 	if rt := m.RefreshTokens(db); rt == nil {
 		t.Fatal("RefreshTokens() nil")
 	}
+	if ls := m.LoginSessions(db); ls == nil {
+		t.Fatal("LoginSessions() nil")
+	}
 	if en := m.Entries(db); en == nil {
 		t.Fatal("Entries() nil")
 	}
 	if f := m.Files(db); f == nil {
 		t.Fatal("Files() nil")
 	}
+	if r := m.Revisions(db); r == nil {
+		t.Fatal("Revisions() nil")
+	}
+	if rl := m.RateLimits(db); rl == nil {
+		t.Fatal("RateLimits() nil")
+	}
+	if tf := m.TwoFactor(db); tf == nil {
+		t.Fatal("TwoFactor() nil")
+	}
 
 	var _ users.Repository = m.Users(db)
 	var _ refreshtokens.Repository = m.RefreshTokens(db)
+	var _ loginsessions.Repository = m.LoginSessions(db)
 	var _ entries.Repository = m.Entries(db)
 	var _ files.Repository = m.Files(db)
+	var _ revisions.Repository = m.Revisions(db)
+	var _ ratelimits.Repository = m.RateLimits(db)
+	var _ twofactor.Repository = m.TwoFactor(db)
 }
 
 func TestRunMigrations_Success(t *testing.T) {
@@ -0,0 +1,103 @@
+// Package revisions provides a PostgreSQL-backed repository for entry
+// revisions written during sync.
+package revisions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	"github.com/dmitrijs2005/gophkeeper/internal/dbx"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+)
+
+// PostgresRepository implements revision storage over a dbx.DBTX (*sql.DB or *sql.Tx).
+type PostgresRepository struct {
+	db dbx.DBTX
+}
+
+// NewPostgresRepository constructs a repository bound to the given DBTX.
+func NewPostgresRepository(db dbx.DBTX) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+// Create inserts a snapshot of entry at its current version. A version is
+// written once; storing it again is a no-op.
+func (r *PostgresRepository) Create(ctx context.Context, entry *models.Entry) error {
+	query := `
+		INSERT INTO entry_revisions (entry_id, user_id, version, overview, nonce_overview, details, nonce_details, deleted)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (entry_id, version) DO NOTHING
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		entry.ID, entry.UserID, entry.Version, entry.Overview, entry.NonceOverview, entry.Details, entry.NonceDetails, entry.Deleted)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	return nil
+}
+
+// List returns the revisions of entryID owned by userID, newest first.
+func (r *PostgresRepository) List(ctx context.Context, userID string, entryID string) ([]*models.Entry, error) {
+	query := ` SELECT entry_id, user_id, version, overview, nonce_overview, details, nonce_details, deleted, created_at
+		FROM entry_revisions WHERE entry_id=$1 and user_id=$2 ORDER BY version DESC
+		`
+	rows, err := r.db.QueryContext(ctx, query, entryID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select revisions: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*models.Entry
+	for rows.Next() {
+		item, err := scanRevision(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan revision: %w", err)
+		}
+		result = append(result, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return result, nil
+}
+
+// Get returns revision version of entryID owned by userID. Returns
+// common.ErrorNotFound when there is no such revision.
+func (r *PostgresRepository) Get(ctx context.Context, userID string, entryID string, version int64) (*models.Entry, error) {
+	query := ` SELECT entry_id, user_id, version, overview, nonce_overview, details, nonce_details, deleted, created_at
+		FROM entry_revisions WHERE entry_id=$1 and user_id=$2 and version=$3
+		`
+	item, err := scanRevision(r.db.QueryRowContext(ctx, query, entryID, userID, version))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.ErrorNotFound
+		}
+		return nil, fmt.Errorf("failed to select revision: %w", err)
+	}
+	return item, nil
+}
+
+// DeleteByUser deletes every revision owned by userID.
+func (r *PostgresRepository) DeleteByUser(ctx context.Context, userID string) error {
+	query := `DELETE FROM entry_revisions WHERE user_id=$1`
+	if _, err := r.db.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to delete revisions: %w", err)
+	}
+	return nil
+}
+
+// scanRevision reads one revision row selected by List or Get.
+func scanRevision(row interface{ Scan(dest ...any) error }) (*models.Entry, error) {
+	item := &models.Entry{}
+	err := row.Scan(
+		&item.ID, &item.UserID, &item.Version, &item.Overview, &item.NonceOverview, &item.Details, &item.NonceDetails,
+		&item.Deleted, &item.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
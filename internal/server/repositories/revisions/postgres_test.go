@@ -0,0 +1,138 @@
+package revisions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+)
+
+func newRepoWithMock(t *testing.T) (*PostgresRepository, sqlmock.Sqlmock, *sql.DB) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	return NewPostgresRepository(db), mock, db
+}
+
+var revisionColumns = []string{"entry_id", "user_id", "version", "overview", "nonce_overview", "details", "nonce_details", "deleted", "created_at"}
+
+func TestCreate_InsertsSnapshot(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `(?s)^\s*INSERT\s+INTO\s+entry_revisions\b.*ON\s+CONFLICT\s*\(entry_id,\s*version\)\s*DO\s+NOTHING\s*$`
+	mock.ExpectExec(q).
+		WithArgs("e1", "u1", int64(4), []byte("o"), []byte("no"), []byte("d"), []byte("nd"), true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.Create(context.Background(), &models.Entry{
+		ID: "e1", UserID: "u1", Version: 4,
+		Overview: []byte("o"), NonceOverview: []byte("no"), Details: []byte("d"), NonceDetails: []byte("nd"),
+		Deleted: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCreate_DBError(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	mock.ExpectExec(`INSERT\s+INTO\s+entry_revisions`).WillReturnError(errors.New("boom"))
+
+	if err := repo.Create(context.Background(), &models.Entry{ID: "e1"}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestList_NewestFirstScopedToUser(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	now := time.Now()
+	rows := sqlmock.NewRows(revisionColumns).
+		AddRow("e1", "u1", int64(5), []byte("o5"), []byte("n"), []byte("d5"), []byte("n"), false, now).
+		AddRow("e1", "u1", int64(2), []byte("o2"), []byte("n"), []byte("d2"), []byte("n"), false, now.Add(-time.Hour))
+
+	q := `(?s)FROM\s+entry_revisions\s+WHERE\s+entry_id=\$1\s+and\s+user_id=\$2\s+ORDER\s+BY\s+version\s+DESC`
+	mock.ExpectQuery(q).WithArgs("e1", "u1").WillReturnRows(rows)
+
+	got, err := repo.List(context.Background(), "u1", "e1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0].Version != 5 || got[1].Version != 2 || string(got[1].Details) != "d2" {
+		t.Fatalf("unexpected revisions: %+v", got)
+	}
+	if !got[0].CreatedAt.Equal(now) {
+		t.Fatalf("unexpected created_at: %v", got[0].CreatedAt)
+	}
+}
+
+func TestList_QueryError(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	mock.ExpectQuery(`FROM\s+entry_revisions`).WillReturnError(errors.New("boom"))
+
+	if _, err := repo.List(context.Background(), "u1", "e1"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestGet_FoundAndNotFound(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `(?s)FROM\s+entry_revisions\s+WHERE\s+entry_id=\$1\s+and\s+user_id=\$2\s+and\s+version=\$3`
+	mock.ExpectQuery(q).WithArgs("e1", "u1", int64(3)).
+		WillReturnRows(sqlmock.NewRows(revisionColumns).
+			AddRow("e1", "u1", int64(3), []byte("o"), []byte("n"), []byte("d"), []byte("n"), true, time.Now()))
+	mock.ExpectQuery(q).WithArgs("e1", "u2", int64(3)).
+		WillReturnRows(sqlmock.NewRows(revisionColumns))
+
+	got, err := repo.Get(context.Background(), "u1", "e1", 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Version != 3 || !got.Deleted || string(got.Details) != "d" {
+		t.Fatalf("unexpected revision: %+v", got)
+	}
+
+	if _, err := repo.Get(context.Background(), "u2", "e1", 3); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want ErrorNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestDeleteByUser_ScopedToUser(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `DELETE FROM entry_revisions WHERE user_id=\$1`
+	mock.ExpectExec(q).WithArgs("u1").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(q).WithArgs("u2").WillReturnError(errors.New("db err"))
+
+	if err := repo.DeleteByUser(context.Background(), "u1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := repo.DeleteByUser(context.Background(), "u2"); err == nil {
+		t.Fatalf("expected error")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+// Package revisions declares the server-side repository contract for the
+// version history of entries.
+package revisions
+
+import (
+	"context"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+)
+
+// Repository stores immutable snapshots of entries, one per accepted version.
+// Snapshots reuse models.Entry: Version identifies the revision and CreatedAt
+// is the time it was stored.
+type Repository interface {
+	// Create stores the given entry version as a new revision.
+	Create(ctx context.Context, entry *models.Entry) error
+
+	// List returns all revisions of the user's entry, newest first. An entry
+	// without revisions yields an empty list.
+	List(ctx context.Context, userID string, entryID string) ([]*models.Entry, error)
+
+	// Get returns a single revision of the user's entry. Returns
+	// common.ErrorNotFound if the user has no such revision.
+	Get(ctx context.Context, userID string, entryID string, version int64) (*models.Entry, error)
+
+	// DeleteByUser removes the revisions of all entries of the user.
+	DeleteByUser(ctx context.Context, userID string) error
+}
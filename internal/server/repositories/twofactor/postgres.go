@@ -0,0 +1,120 @@
+// Package twofactor provides a PostgreSQL-backed repository for two-factor
+// challenges and recovery codes.
+package twofactor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	"github.com/dmitrijs2005/gophkeeper/internal/dbx"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+)
+
+// PostgresRepository implements two-factor storage over dbx.DBTX (satisfied
+// by *sql.DB or *sql.Tx).
+type PostgresRepository struct {
+	db dbx.DBTX
+}
+
+// NewPostgresRepository constructs a repository bound to the given DBTX.
+func NewPostgresRepository(db dbx.DBTX) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+// CreateChallenge inserts a challenge of userID expiring at now+validity and
+// returns its generated ID.
+func (r *PostgresRepository) CreateChallenge(ctx context.Context, userID string, validity time.Duration) (string, error) {
+	query := `
+		INSERT INTO two_factor_challenges (user_id, expires_at)
+		VALUES ($1, $2)
+		RETURNING id
+	`
+	var id string
+	if err := r.db.QueryRowContext(ctx, query, userID, time.Now().Add(validity)).Scan(&id); err != nil {
+		return "", fmt.Errorf("db error: %w", err)
+	}
+	return id, nil
+}
+
+// AttemptChallenge increments the attempts of the unexpired challenge with
+// the given ID unless it already had maxAttempts, and returns it. Counting
+// before the code is checked keeps concurrent guesses within the limit.
+// Returns common.ErrorNotFound if there is no such challenge.
+func (r *PostgresRepository) AttemptChallenge(ctx context.Context, id string, maxAttempts int) (*models.TwoFactorChallenge, error) {
+	query := `
+		UPDATE two_factor_challenges SET attempts = attempts + 1
+		WHERE id = $1 AND expires_at > now() AND attempts < $2
+		RETURNING id, user_id, attempts, expires_at
+	`
+	c := &models.TwoFactorChallenge{}
+	if err := r.db.QueryRowContext(ctx, query, id, maxAttempts).Scan(&c.ID, &c.UserID, &c.Attempts, &c.Expires); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.ErrorNotFound
+		}
+		return nil, fmt.Errorf("db error: %w", err)
+	}
+	return c, nil
+}
+
+// DeleteChallenge removes the challenge with the given ID. Returns
+// common.ErrorNotFound if there is no such challenge.
+func (r *PostgresRepository) DeleteChallenge(ctx context.Context, id string) error {
+	query := `DELETE FROM two_factor_challenges WHERE id = $1`
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	return oneRow(res)
+}
+
+// DeleteExpiredChallenges removes challenges whose expiry has passed.
+func (r *PostgresRepository) DeleteExpiredChallenges(ctx context.Context) error {
+	query := `DELETE FROM two_factor_challenges WHERE expires_at <= now()`
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes deletes the recovery codes of userID and inserts the
+// given hashes. It should run in a transaction, so that the old codes stay
+// valid if inserting the new ones fails.
+func (r *PostgresRepository) ReplaceRecoveryCodes(ctx context.Context, userID string, hashes [][]byte) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	for _, h := range hashes {
+		query := `INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`
+		if _, err := r.db.ExecContext(ctx, query, userID, h); err != nil {
+			return fmt.Errorf("db error: %w", err)
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode deletes the recovery code of userID with the given hash.
+// Returns common.ErrorNotFound if there is no such code.
+func (r *PostgresRepository) UseRecoveryCode(ctx context.Context, userID string, hash []byte) error {
+	query := `DELETE FROM recovery_codes WHERE user_id = $1 AND code_hash = $2`
+	res, err := r.db.ExecContext(ctx, query, userID, hash)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	return oneRow(res)
+}
+
+// oneRow returns common.ErrorNotFound if a statement affected no rows.
+func oneRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if n == 0 {
+		return common.ErrorNotFound
+	}
+	return nil
+}
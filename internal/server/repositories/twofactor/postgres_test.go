@@ -0,0 +1,115 @@
+package twofactor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dmitrijs2005/gophkeeper/internal/common"
+)
+
+func newRepoWithMock(t *testing.T) (*PostgresRepository, sqlmock.Sqlmock, *sql.DB) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	return NewPostgresRepository(db), mock, db
+}
+
+func TestCreateChallenge(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `(?s)^INSERT\s+INTO\s+two_factor_challenges\s*\(user_id,\s*expires_at\)\s*VALUES\s*\(\$1,\s*\$2\)\s*RETURNING\s+id\s*$`
+	mock.ExpectQuery(q).
+		WithArgs("u1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("c1"))
+	mock.ExpectQuery(q).
+		WithArgs("u1", sqlmock.AnyArg()).
+		WillReturnError(errors.New("db down"))
+
+	id, err := repo.CreateChallenge(context.Background(), "u1", time.Minute)
+	if err != nil || id != "c1" {
+		t.Fatalf("got %q, %v", id, err)
+	}
+	if _, err := repo.CreateChallenge(context.Background(), "u1", time.Minute); err == nil || !regexp.MustCompile(`db error: .*db down`).MatchString(err.Error()) {
+		t.Fatalf("expected wrapped db error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAttemptAndDeleteChallenge(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `(?s)^UPDATE\s+two_factor_challenges\s+SET\s+attempts\s*=\s*attempts\s*\+\s*1\s+WHERE\s+id\s*=\s*\$1\s+AND\s+expires_at\s*>\s*now\(\)\s+AND\s+attempts\s*<\s*\$2\s+RETURNING\s+id,\s*user_id,\s*attempts,\s*expires_at\s*$`
+	expires := time.Now().Add(time.Minute)
+	mock.ExpectQuery(q).
+		WithArgs("c1", 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "attempts", "expires_at"}).AddRow("c1", "u1", 2, expires))
+	mock.ExpectQuery(q).
+		WithArgs("c1", 5).
+		WillReturnError(sql.ErrNoRows)
+	del := `DELETE FROM two_factor_challenges WHERE id = \$1`
+	mock.ExpectExec(del).WithArgs("c1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(del).WithArgs("c1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM two_factor_challenges WHERE expires_at <= now\(\)`).WillReturnResult(sqlmock.NewResult(0, 3))
+
+	ctx := context.Background()
+	c, err := repo.AttemptChallenge(ctx, "c1", 5)
+	if err != nil || c.UserID != "u1" || c.Attempts != 2 || !c.Expires.Equal(expires) {
+		t.Fatalf("AttemptChallenge: %+v, %v", c, err)
+	}
+	if _, err := repo.AttemptChallenge(ctx, "c1", 5); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want ErrorNotFound, got %v", err)
+	}
+	if err := repo.DeleteChallenge(ctx, "c1"); err != nil {
+		t.Fatalf("DeleteChallenge: %v", err)
+	}
+	if err := repo.DeleteChallenge(ctx, "c1"); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("deleted twice: want ErrorNotFound, got %v", err)
+	}
+	if err := repo.DeleteExpiredChallenges(ctx); err != nil {
+		t.Fatalf("DeleteExpiredChallenges: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	mock.ExpectExec(`DELETE FROM recovery_codes WHERE user_id = \$1`).
+		WithArgs("u1").
+		WillReturnResult(sqlmock.NewResult(0, 10))
+	ins := `INSERT INTO recovery_codes \(user_id, code_hash\) VALUES \(\$1, \$2\)`
+	mock.ExpectExec(ins).WithArgs("u1", []byte("h1")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(ins).WithArgs("u1", []byte("h2")).WillReturnError(errors.New("db down"))
+	use := `DELETE FROM recovery_codes WHERE user_id = \$1 AND code_hash = \$2`
+	mock.ExpectExec(use).WithArgs("u1", []byte("h1")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(use).WithArgs("u1", []byte("h1")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx := context.Background()
+	err := repo.ReplaceRecoveryCodes(ctx, "u1", [][]byte{[]byte("h1"), []byte("h2")})
+	if err == nil || !regexp.MustCompile(`db error: .*db down`).MatchString(err.Error()) {
+		t.Fatalf("expected wrapped db error, got %v", err)
+	}
+	if err := repo.UseRecoveryCode(ctx, "u1", []byte("h1")); err != nil {
+		t.Fatalf("UseRecoveryCode: %v", err)
+	}
+	if err := repo.UseRecoveryCode(ctx, "u1", []byte("h1")); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("used twice: want ErrorNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
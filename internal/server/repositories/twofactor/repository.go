@@ -0,0 +1,41 @@
+// Package twofactor declares the server-side repository contract for the
+// state of two-factor logins: the challenges between LoginFinish and
+// Verify2FA and the hashed one-time recovery codes.
+package twofactor
+
+import (
+	"context"
+	"time"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+)
+
+// Repository defines operations for two-factor challenges and recovery
+// codes.
+type Repository interface {
+	// CreateChallenge stores a new challenge of userID with an expiry of
+	// now+validity and returns its ID.
+	CreateChallenge(ctx context.Context, userID string, validity time.Duration) (string, error)
+
+	// AttemptChallenge counts an attempt at the challenge with the given ID
+	// and returns it. Implementations should return a not-found error when
+	// the challenge is absent, expired or already had maxAttempts attempts.
+	AttemptChallenge(ctx context.Context, id string, maxAttempts int) (*models.TwoFactorChallenge, error)
+
+	// DeleteChallenge removes the challenge with the given ID, so that it is
+	// answered at most once. Implementations should return a not-found error
+	// when it is absent.
+	DeleteChallenge(ctx context.Context, id string) error
+
+	// DeleteExpiredChallenges removes all expired challenges.
+	DeleteExpiredChallenges(ctx context.Context) error
+
+	// ReplaceRecoveryCodes replaces the recovery codes of userID with the
+	// given hashes.
+	ReplaceRecoveryCodes(ctx context.Context, userID string, hashes [][]byte) error
+
+	// UseRecoveryCode removes the recovery code of userID with the given
+	// hash. Implementations should return a not-found error when there is no
+	// such code.
+	UseRecoveryCode(ctx context.Context, userID string, hash []byte) error
+}
@@ -24,13 +24,16 @@ func NewPostgresRepository(db dbx.DBTX) *PostgresRepository {
 }
 
 // Create inserts a new user row and returns the populated user (with ID).
+// New accounts only get an SRP verifier and a wrapped vault key.
 func (r *PostgresRepository) Create(ctx context.Context, user *models.User) (*models.User, error) {
 	query := `
-		INSERT INTO users (username, salt, master_key_verifier)
-		VALUES ($1, $2, $3)
+		INSERT INTO users (username, salt, srp_verifier, wrapped_vault_key, kdf_algorithm, kdf_time, kdf_memory, kdf_threads)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id
 	`
-	if err := r.db.QueryRowContext(ctx, query, user.UserName, user.Salt, user.Verifier).Scan(&user.ID); err != nil {
+	kdf := user.KDF
+	if err := r.db.QueryRowContext(ctx, query, user.UserName, user.Salt, user.SRPVerifier, user.WrappedVaultKey,
+		kdf.Algorithm, kdf.Time, kdf.Memory, kdf.Threads).Scan(&user.ID); err != nil {
 		return nil, fmt.Errorf("db error: %w", err)
 	}
 	return user, nil
@@ -39,12 +42,31 @@ func (r *PostgresRepository) Create(ctx context.Context, user *models.User) (*mo
 // GetUserByLogin fetches a user by username. Returns common.ErrorNotFound if missing.
 func (r *PostgresRepository) GetUserByLogin(ctx context.Context, userName string) (*models.User, error) {
 	query :=
-		`SELECT ID, username, master_key_verifier, salt FROM users
+		`SELECT ID, username, master_key_verifier, srp_verifier, wrapped_vault_key, salt,
+		 kdf_algorithm, kdf_time, kdf_memory, kdf_threads,
+		 totp_secret, totp_pending_secret, totp_last_step FROM users
 		 WHERE username = $1
 		 `
+	return r.getUser(ctx, query, userName)
+}
+
+// GetUserByID fetches a user by ID. Returns common.ErrorNotFound if missing.
+func (r *PostgresRepository) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
+	query :=
+		`SELECT ID, username, master_key_verifier, srp_verifier, wrapped_vault_key, salt,
+		 kdf_algorithm, kdf_time, kdf_memory, kdf_threads,
+		 totp_secret, totp_pending_secret, totp_last_step FROM users
+		 WHERE id = $1
+		 `
+	return r.getUser(ctx, query, userID)
+}
 
+// getUser runs a single-user query selecting the columns of GetUserByLogin.
+func (r *PostgresRepository) getUser(ctx context.Context, query string, arg string) (*models.User, error) {
 	u := &models.User{}
-	if err := r.db.QueryRowContext(ctx, query, userName).Scan(&u.ID, &u.UserName, &u.Verifier, &u.Salt); err != nil {
+	if err := r.db.QueryRowContext(ctx, query, arg).Scan(&u.ID, &u.UserName, &u.Verifier, &u.SRPVerifier, &u.WrappedVaultKey, &u.Salt,
+		&u.KDF.Algorithm, &u.KDF.Time, &u.KDF.Memory, &u.KDF.Threads,
+		&u.TOTPSecret, &u.TOTPPendingSecret, &u.TOTPLastStep); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, common.ErrorNotFound
 		}
@@ -53,6 +75,96 @@ func (r *PostgresRepository) GetUserByLogin(ctx context.Context, userName string
 	return u, nil
 }
 
+// UpgradeKeys stores the SRP verifier and wrapped vault key of userID and
+// drops its legacy verifier. Returns common.ErrorNotFound if the user does not
+// exist or already has a vault key, which is then left untouched.
+func (r *PostgresRepository) UpgradeKeys(ctx context.Context, userID string, srpVerifier, wrappedVaultKey []byte) error {
+	query :=
+		`UPDATE users SET srp_verifier = $2, wrapped_vault_key = $3, master_key_verifier = NULL
+		 WHERE id = $1 AND wrapped_vault_key IS NULL`
+	res, err := r.db.ExecContext(ctx, query, userID, srpVerifier, wrappedVaultKey)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if n == 0 {
+		return common.ErrorNotFound
+	}
+	return nil
+}
+
+// ChangeCredentials replaces the salt, KDF parameters, SRP verifier and
+// wrapped vault key of userID. The update only applies while the stored verifier is still
+// oldSRPVerifier, so of two concurrent password changes proven with the same
+// password only one succeeds. Returns common.ErrorNotFound otherwise, and for
+// unknown users and accounts without a vault key.
+func (r *PostgresRepository) ChangeCredentials(ctx context.Context, userID string, oldSRPVerifier []byte, c models.Credentials) error {
+	query :=
+		`UPDATE users SET salt = $3, srp_verifier = $4, wrapped_vault_key = $5,
+		 kdf_algorithm = $6, kdf_time = $7, kdf_memory = $8, kdf_threads = $9
+		 WHERE id = $1 AND srp_verifier = $2 AND wrapped_vault_key IS NOT NULL`
+	res, err := r.db.ExecContext(ctx, query, userID, oldSRPVerifier, c.Salt, c.SRPVerifier, c.WrappedVaultKey,
+		c.KDF.Algorithm, c.KDF.Time, c.KDF.Memory, c.KDF.Threads)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if n == 0 {
+		return common.ErrorNotFound
+	}
+	return nil
+}
+
+// SetPendingTOTPSecret stores the secret of a two-factor enrollment of
+// userID, replacing one in progress. Returns common.ErrorNotFound if the user
+// does not exist.
+func (r *PostgresRepository) SetPendingTOTPSecret(ctx context.Context, userID string, secret []byte) error {
+	query := `UPDATE users SET totp_pending_secret = $2 WHERE id = $1`
+	return r.execOne(ctx, query, userID, secret)
+}
+
+// EnableTOTP makes the pending secret of userID its second factor, with step
+// as the last TOTP step used. The update only applies while the pending
+// secret is still secret, so a newer enrollment is not confirmed with the
+// code of an older one. Returns common.ErrorNotFound otherwise.
+func (r *PostgresRepository) EnableTOTP(ctx context.Context, userID string, secret []byte, step int64) error {
+	query :=
+		`UPDATE users SET totp_secret = totp_pending_secret, totp_pending_secret = NULL, totp_last_step = $3
+		 WHERE id = $1 AND totp_pending_secret = $2`
+	return r.execOne(ctx, query, userID, secret, step)
+}
+
+// UseTOTPStep records that a TOTP code of step was accepted for userID.
+// Returns common.ErrorNotFound if a code of that step or a later one was
+// already accepted, i.e. the code is replayed.
+func (r *PostgresRepository) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	query := `UPDATE users SET totp_last_step = $2 WHERE id = $1 AND totp_last_step < $2`
+	return r.execOne(ctx, query, userID, step)
+}
+
+// execOne runs an update that is expected to affect one row and returns
+// common.ErrorNotFound if it affected none.
+func (r *PostgresRepository) execOne(ctx context.Context, query string, args ...any) error {
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	if n == 0 {
+		return common.ErrorNotFound
+	}
+	return nil
+}
+
 // IncrementCurrentVersion atomically increments and returns the user's current_version.
 // This is used to produce a new global version for sync operations.
 func (r *PostgresRepository) IncrementCurrentVersion(ctx context.Context, userID string) (int64, error) {
@@ -71,3 +183,28 @@ func (r *PostgresRepository) IncrementCurrentVersion(ctx context.Context, userID
 
 	return maxVersion, nil
 }
+
+// GetPurgedVersion returns users.purged_version for userID. Returns
+// common.ErrorNotFound if the user does not exist.
+func (r *PostgresRepository) GetPurgedVersion(ctx context.Context, userID string) (int64, error) {
+	query := `SELECT purged_version FROM users WHERE id = $1`
+
+	var version int64
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, common.ErrorNotFound
+		}
+		return 0, fmt.Errorf("db error: %w", err)
+	}
+	return version, nil
+}
+
+// RaisePurgedVersion sets users.purged_version to version unless it is
+// already higher.
+func (r *PostgresRepository) RaisePurgedVersion(ctx context.Context, userID string, version int64) error {
+	query := `UPDATE users SET purged_version = GREATEST(purged_version, $2) WHERE id = $1`
+	if _, err := r.db.ExecContext(ctx, query, userID, version); err != nil {
+		return fmt.Errorf("db error: %w", err)
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
 )
 
@@ -25,14 +26,14 @@ func TestCreate_Success(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := `(?s)^INSERT\s+INTO\s+users\s*\(username,\s*salt,\s*master_key_verifier\)\s*VALUES\s*\(\$1,\s*\$2,\s*\$3\)\s*RETURNING\s+id\s*$`
+	q := `(?s)^INSERT\s+INTO\s+users\s*\(username,\s*salt,\s*srp_verifier,\s*wrapped_vault_key,\s*kdf_algorithm,\s*kdf_time,\s*kdf_memory,\s*kdf_threads\)\s*VALUES\s*\(\$1,\s*\$2,\s*\$3,\s*\$4,\s*\$5,\s*\$6,\s*\$7,\s*\$8\)\s*RETURNING\s+id\s*$`
 
 	rows := sqlmock.NewRows([]string{"id"}).AddRow("42")
 	mock.ExpectQuery(q).
-		WithArgs("alice", []byte("salt"), []byte("verifier")).
+		WithArgs("alice", []byte("salt"), []byte("verifier"), []byte("wvk"), "argon2id", uint32(3), uint32(65536), uint8(4)).
 		WillReturnRows(rows)
 
-	u := &models.User{UserName: "alice", Salt: []byte("salt"), Verifier: []byte("verifier")}
+	u := &models.User{UserName: "alice", Salt: []byte("salt"), KDF: cryptox.DefaultKDFParams, SRPVerifier: []byte("verifier"), WrappedVaultKey: []byte("wvk")}
 	got, err := repo.Create(context.Background(), u)
 	if err != nil {
 		t.Fatalf("Create error: %v", err)
@@ -46,13 +47,13 @@ func TestCreate_DBError(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := `(?s)^INSERT\s+INTO\s+users\s*\(username,\s*salt,\s*master_key_verifier\)\s*VALUES\s*\(\$1,\s*\$2,\s*\$3\)\s*RETURNING\s+id\s*$`
+	q := `(?s)^INSERT\s+INTO\s+users\s*\(username,\s*salt,\s*srp_verifier,\s*wrapped_vault_key,\s*kdf_algorithm,\s*kdf_time,\s*kdf_memory,\s*kdf_threads\)\s*VALUES\s*\(\$1,\s*\$2,\s*\$3,\s*\$4,\s*\$5,\s*\$6,\s*\$7,\s*\$8\)\s*RETURNING\s+id\s*$`
 
 	mock.ExpectQuery(q).
-		WithArgs("alice", []byte("salt"), []byte("verifier")).
+		WithArgs("alice", []byte("salt"), []byte("verifier"), []byte("wvk"), "argon2id", uint32(3), uint32(65536), uint8(4)).
 		WillReturnError(errors.New("db down"))
 
-	_, err := repo.Create(context.Background(), &models.User{UserName: "alice", Salt: []byte("salt"), Verifier: []byte("verifier")})
+	_, err := repo.Create(context.Background(), &models.User{UserName: "alice", Salt: []byte("salt"), KDF: cryptox.DefaultKDFParams, SRPVerifier: []byte("verifier"), WrappedVaultKey: []byte("wvk")})
 	if err == nil || !regexp.MustCompile(`db error: .*db down`).MatchString(err.Error()) {
 		t.Fatalf("expected wrapped db error, got %v", err)
 	}
@@ -62,10 +63,10 @@ func TestGetUserByLogin_Found(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := `(?s)^SELECT\s+ID,\s*username,\s*master_key_verifier,\s*salt\s+FROM\s+users\s+WHERE\s+username\s*=\s*\$1\s*$`
+	q := `(?s)^SELECT\s+ID,\s*username,\s*master_key_verifier,\s*srp_verifier,\s*wrapped_vault_key,\s*salt,\s*kdf_algorithm,\s*kdf_time,\s*kdf_memory,\s*kdf_threads,\s*totp_secret,\s*totp_pending_secret,\s*totp_last_step\s+FROM\s+users\s+WHERE\s+username\s*=\s*\$1\s*$`
 
-	rows := sqlmock.NewRows([]string{"id", "username", "master_key_verifier", "salt"}).
-		AddRow("u-1", "alice", []byte("ver"), []byte("salt"))
+	rows := sqlmock.NewRows([]string{"id", "username", "master_key_verifier", "srp_verifier", "wrapped_vault_key", "salt", "kdf_algorithm", "kdf_time", "kdf_memory", "kdf_threads", "totp_secret", "totp_pending_secret", "totp_last_step"}).
+		AddRow("u-1", "alice", nil, []byte("srp"), []byte("wvk"), []byte("salt"), "argon2id", 3, 65536, 4, []byte("totp"), nil, int64(7))
 	mock.ExpectQuery(q).
 		WithArgs("alice").
 		WillReturnRows(rows)
@@ -74,7 +75,8 @@ func TestGetUserByLogin_Found(t *testing.T) {
 	if err != nil {
 		t.Fatalf("GetUserByLogin error: %v", err)
 	}
-	if got.ID != "u-1" || got.UserName != "alice" {
+	if got.ID != "u-1" || got.UserName != "alice" || got.Verifier != nil || string(got.SRPVerifier) != "srp" || string(got.WrappedVaultKey) != "wvk" ||
+		string(got.TOTPSecret) != "totp" || got.TOTPPendingSecret != nil || got.TOTPLastStep != 7 {
 		t.Fatalf("unexpected user: %+v", got)
 	}
 }
@@ -83,7 +85,7 @@ func TestGetUserByLogin_NotFound(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := `(?s)^SELECT\s+ID,\s*username,\s*master_key_verifier,\s*salt\s+FROM\s+users\s+WHERE\s+username\s*=\s*\$1\s*$`
+	q := `(?s)^SELECT\s+ID,\s*username,\s*master_key_verifier,\s*srp_verifier,\s*wrapped_vault_key,\s*salt,\s*kdf_algorithm,\s*kdf_time,\s*kdf_memory,\s*kdf_threads,\s*totp_secret,\s*totp_pending_secret,\s*totp_last_step\s+FROM\s+users\s+WHERE\s+username\s*=\s*\$1\s*$`
 
 	mock.ExpectQuery(q).
 		WithArgs("ghost").
@@ -99,7 +101,7 @@ func TestGetUserByLogin_DBError(t *testing.T) {
 	repo, mock, db := newRepoWithMock(t)
 	defer db.Close()
 
-	q := `(?s)^SELECT\s+ID,\s*username,\s*master_key_verifier,\s*salt\s+FROM\s+users\s+WHERE\s+username\s*=\s*\$1\s*$`
+	q := `(?s)^SELECT\s+ID,\s*username,\s*master_key_verifier,\s*srp_verifier,\s*wrapped_vault_key,\s*salt,\s*kdf_algorithm,\s*kdf_time,\s*kdf_memory,\s*kdf_threads,\s*totp_secret,\s*totp_pending_secret,\s*totp_last_step\s+FROM\s+users\s+WHERE\s+username\s*=\s*\$1\s*$`
 
 	mock.ExpectQuery(q).
 		WithArgs("alice").
@@ -146,3 +148,163 @@ func TestIncrementCurrentVersion_DBError(t *testing.T) {
 		t.Fatalf("expected wrapped db error, got %v", err)
 	}
 }
+
+func TestPurgedVersion_GetAndRaise(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT purged_version FROM users WHERE id = \$1`).
+		WithArgs("u-1").
+		WillReturnRows(sqlmock.NewRows([]string{"purged_version"}).AddRow(int64(12)))
+	mock.ExpectQuery(`SELECT purged_version FROM users WHERE id = \$1`).
+		WithArgs("ghost").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec(`UPDATE users SET purged_version = GREATEST\(purged_version, \$2\) WHERE id = \$1`).
+		WithArgs("u-1", int64(15)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	got, err := repo.GetPurgedVersion(context.Background(), "u-1")
+	if err != nil || got != 12 {
+		t.Fatalf("got %d, %v", got, err)
+	}
+	if _, err := repo.GetPurgedVersion(context.Background(), "ghost"); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("expected ErrorNotFound, got %v", err)
+	}
+	if err := repo.RaisePurgedVersion(context.Background(), "u-1", 15); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetUserByID(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `(?s)^SELECT\s+ID,\s*username,\s*master_key_verifier,\s*srp_verifier,\s*wrapped_vault_key,\s*salt,\s*kdf_algorithm,\s*kdf_time,\s*kdf_memory,\s*kdf_threads,\s*totp_secret,\s*totp_pending_secret,\s*totp_last_step\s+FROM\s+users\s+WHERE\s+id\s*=\s*\$1\s*$`
+	mock.ExpectQuery(q).
+		WithArgs("u-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "master_key_verifier", "srp_verifier", "wrapped_vault_key", "salt", "kdf_algorithm", "kdf_time", "kdf_memory", "kdf_threads", "totp_secret", "totp_pending_secret", "totp_last_step"}).
+			AddRow("u-1", "alice", []byte("legacy"), nil, nil, []byte("salt"), "argon2id", 1, 65536, 4, nil, nil, int64(0)))
+	mock.ExpectQuery(q).
+		WithArgs("ghost").
+		WillReturnError(sql.ErrNoRows)
+
+	got, err := repo.GetUserByID(context.Background(), "u-1")
+	if err != nil {
+		t.Fatalf("GetUserByID error: %v", err)
+	}
+	if got.UserName != "alice" || string(got.Verifier) != "legacy" || got.SRPVerifier != nil || got.WrappedVaultKey != nil ||
+		got.KDF != cryptox.LegacyKDFParams {
+		t.Fatalf("unexpected user: %+v", got)
+	}
+	if _, err := repo.GetUserByID(context.Background(), "ghost"); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want common.ErrorNotFound, got %v", err)
+	}
+}
+
+func TestUpgradeKeys(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `(?s)^UPDATE\s+users\s+SET\s+srp_verifier\s*=\s*\$2,\s*wrapped_vault_key\s*=\s*\$3,\s*master_key_verifier\s*=\s*NULL\s+WHERE\s+id\s*=\s*\$1\s+AND\s+wrapped_vault_key\s+IS\s+NULL$`
+	mock.ExpectExec(q).
+		WithArgs("u-1", []byte("srp"), []byte("wvk")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(q).
+		WithArgs("u-2", []byte("srp"), []byte("wvk")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.UpgradeKeys(context.Background(), "u-1", []byte("srp"), []byte("wvk")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// unknown or already upgraded
+	if err := repo.UpgradeKeys(context.Background(), "u-2", []byte("srp"), []byte("wvk")); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want common.ErrorNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestChangeCredentials(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	q := `(?s)^UPDATE\s+users\s+SET\s+salt\s*=\s*\$3,\s*srp_verifier\s*=\s*\$4,\s*wrapped_vault_key\s*=\s*\$5,\s*kdf_algorithm\s*=\s*\$6,\s*kdf_time\s*=\s*\$7,\s*kdf_memory\s*=\s*\$8,\s*kdf_threads\s*=\s*\$9\s+WHERE\s+id\s*=\s*\$1\s+AND\s+srp_verifier\s*=\s*\$2\s+AND\s+wrapped_vault_key\s+IS\s+NOT\s+NULL$`
+	mock.ExpectExec(q).
+		WithArgs("u-1", []byte("old"), []byte("salt"), []byte("srp"), []byte("wvk"), "argon2id", uint32(3), uint32(65536), uint8(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(q).
+		WithArgs("u-1", []byte("old"), []byte("salt"), []byte("srp"), []byte("wvk"), "argon2id", uint32(3), uint32(65536), uint8(4)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(q).
+		WithArgs("u-1", []byte("old"), []byte("salt"), []byte("srp"), []byte("wvk"), "argon2id", uint32(3), uint32(65536), uint8(4)).
+		WillReturnError(errors.New("boom"))
+
+	ctx := context.Background()
+	creds := models.Credentials{Salt: []byte("salt"), KDF: cryptox.DefaultKDFParams, SRPVerifier: []byte("srp"), WrappedVaultKey: []byte("wvk")}
+	if err := repo.ChangeCredentials(ctx, "u-1", []byte("old"), creds); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// verifier changed meanwhile
+	if err := repo.ChangeCredentials(ctx, "u-1", []byte("old"), creds); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want common.ErrorNotFound, got %v", err)
+	}
+	if err := repo.ChangeCredentials(ctx, "u-1", []byte("old"), creds); err == nil || errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want db error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	repo, mock, db := newRepoWithMock(t)
+	defer db.Close()
+
+	mock.ExpectExec(`UPDATE users SET totp_pending_secret = \$2 WHERE id = \$1`).
+		WithArgs("u-1", []byte("s")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	enable := `(?s)^UPDATE\s+users\s+SET\s+totp_secret\s*=\s*totp_pending_secret,\s*totp_pending_secret\s*=\s*NULL,\s*totp_last_step\s*=\s*\$3\s+WHERE\s+id\s*=\s*\$1\s+AND\s+totp_pending_secret\s*=\s*\$2$`
+	mock.ExpectExec(enable).
+		WithArgs("u-1", []byte("s"), int64(100)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(enable).
+		WithArgs("u-1", []byte("old"), int64(100)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	use := `UPDATE users SET totp_last_step = \$2 WHERE id = \$1 AND totp_last_step < \$2`
+	mock.ExpectExec(use).
+		WithArgs("u-1", int64(101)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(use).
+		WithArgs("u-1", int64(101)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(use).
+		WithArgs("u-1", int64(102)).
+		WillReturnError(errors.New("db down"))
+
+	ctx := context.Background()
+	if err := repo.SetPendingTOTPSecret(ctx, "u-1", []byte("s")); err != nil {
+		t.Fatalf("SetPendingTOTPSecret: %v", err)
+	}
+	if err := repo.EnableTOTP(ctx, "u-1", []byte("s"), 100); err != nil {
+		t.Fatalf("EnableTOTP: %v", err)
+	}
+	if err := repo.EnableTOTP(ctx, "u-1", []byte("old"), 100); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("superseded enrollment: want ErrorNotFound, got %v", err)
+	}
+	if err := repo.UseTOTPStep(ctx, "u-1", 101); err != nil {
+		t.Fatalf("UseTOTPStep: %v", err)
+	}
+	if err := repo.UseTOTPStep(ctx, "u-1", 101); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("replayed step: want ErrorNotFound, got %v", err)
+	}
+	if err := repo.UseTOTPStep(ctx, "u-1", 102); err == nil || !regexp.MustCompile(`db error: .*db down`).MatchString(err.Error()) {
+		t.Fatalf("expected wrapped db error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -17,7 +17,46 @@ type Repository interface {
 	// not-found error when the user does not exist.
 	GetUserByLogin(ctx context.Context, login string) (*models.User, error)
 
+	// GetUserByID fetches a user by ID. Should return a not-found error when
+	// the user does not exist.
+	GetUserByID(ctx context.Context, userID string) (*models.User, error)
+
+	// UpgradeKeys moves an account that predates the key hierarchy to it: it
+	// stores the SRP verifier and the wrapped vault key and drops the legacy
+	// verifier. Should return a not-found error when the user does not exist
+	// or already has a vault key.
+	UpgradeKeys(ctx context.Context, userID string, srpVerifier, wrappedVaultKey []byte) error
+
+	// ChangeCredentials replaces the salt, KDF parameters, SRP verifier and
+	// wrapped vault key of an account whose SRP verifier is still
+	// oldSRPVerifier. Should return a not-found error when the user does not
+	// exist, has no vault key yet or its verifier has changed meanwhile.
+	ChangeCredentials(ctx context.Context, userID string, oldSRPVerifier []byte, c models.Credentials) error
+
+	// SetPendingTOTPSecret stores the secret of a two-factor enrollment that
+	// still has to be confirmed. Should return a not-found error when the
+	// user does not exist.
+	SetPendingTOTPSecret(ctx context.Context, userID string, secret []byte) error
+
+	// EnableTOTP turns the pending secret, if it is still secret, into the
+	// account's second factor and records step as the last TOTP step used.
+	// Should return a not-found error otherwise.
+	EnableTOTP(ctx context.Context, userID string, secret []byte, step int64) error
+
+	// UseTOTPStep records step as the last TOTP step used. Should return a
+	// not-found error when it is not after the last one, so that codes
+	// cannot be replayed.
+	UseTOTPStep(ctx context.Context, userID string, step int64) error
+
 	// IncrementCurrentVersion atomically increments and returns the user's
 	// current_version counter used for synchronization.
 	IncrementCurrentVersion(ctx context.Context, userID string) (int64, error)
+
+	// GetPurgedVersion returns the highest version of the user's tombstones
+	// that were purged for good (0 if none).
+	GetPurgedVersion(ctx context.Context, userID string) (int64, error)
+
+	// RaisePurgedVersion records that a tombstone with the given version was
+	// purged; the stored value never decreases.
+	RaisePurgedVersion(ctx context.Context, userID string, version int64) error
 }
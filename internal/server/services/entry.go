@@ -1,163 +1,167 @@
 // Package services contains server-side business logic. This file implements
 // EntryService, which coordinates sync of entries/files with the database and
-// generates presigned S3 URLs for client uploads/downloads.
+// the blob store that issues presigned URLs for client uploads/downloads.
 package services
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/dmitrijs2005/gophkeeper/internal/common"
 	"github.com/dmitrijs2005/gophkeeper/internal/dbx"
-	sc "github.com/dmitrijs2005/gophkeeper/internal/server/config"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/blobstore"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/repomanager"
 	"github.com/google/uuid"
-
-	"github.com/aws/aws-sdk-go-v2/aws"
-	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
-var (
-	// seams for unit tests
-	loadDefaultAWSConfig = config.LoadDefaultConfig
+// purgeBatchSize bounds how many tombstones PurgeTombstones loads at once.
+const purgeBatchSize = 100
 
-	newS3ClientFromConfig = func(cfg aws.Config, optFns ...func(*s3.Options)) *s3.Client {
-		return s3.NewFromConfig(cfg, optFns...)
-	}
-	newS3PresignClient = func(c *s3.Client) *s3.PresignClient {
-		return s3.NewPresignClient(c)
-	}
-	presignPutObject = func(pc *s3.PresignClient, ctx context.Context, in *s3.PutObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
-		return pc.PresignPutObject(ctx, in, optFns...)
-	}
-	presignGetObject = func(pc *s3.PresignClient, ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
-		return pc.PresignGetObject(ctx, in, optFns...)
-	}
-)
+// gcBatchSize bounds how many stored objects CollectGarbage looks up at once.
+const gcBatchSize = 500
+
+// storageKeyPrefix is the common prefix of all storage keys (see
+// GetRandomStorageKey).
+const storageKeyPrefix = "users/"
 
 // EntryService implements server-side entry/file synchronization and presigned
-// URL generation against an S3-compatible backend.
+// URL generation against the configured blob store.
 type EntryService struct {
 	db          *sql.DB
 	repomanager repomanager.RepositoryManager
-	config      *sc.Config
+	store       blobstore.BlobStore
 }
 
-// NewEntryService wires the service with a DB handle, repository manager, and config.
-func NewEntryService(db *sql.DB, repomanager repomanager.RepositoryManager, config *sc.Config) *EntryService {
-	return &EntryService{db: db, repomanager: repomanager, config: config}
+// NewEntryService wires the service with a DB handle, repository manager, and
+// the blob store holding the encrypted files.
+func NewEntryService(db *sql.DB, repomanager repomanager.RepositoryManager, store blobstore.BlobStore) *EntryService {
+	return &EntryService{db: db, repomanager: repomanager, store: store}
 }
 
 // GetRandomStorageKey produces a time-bucketed object-storage key for new uploads.
 func GetRandomStorageKey() string {
 	d := time.Now()
-	return fmt.Sprintf("users/%d/%d/%d/%v", d.Year(), d.Month(), d.Day(), uuid.New())
+	return fmt.Sprintf("%s%d/%d/%d/%v", storageKeyPrefix, d.Year(), d.Month(), d.Day(), uuid.New())
 }
 
-// getPresignClient builds an S3 presign client using config-provided endpoint,
-// region, and static credentials (e.g., MinIO).
-func (s *EntryService) getPresignClient() (*s3.PresignClient, error) {
-	cfg, err := loadDefaultAWSConfig(context.Background(),
-		config.WithRegion(s.config.S3Region),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-			s.config.S3RootUser, s.config.S3RootPassword, "",
-		)))
-	if err != nil {
-		return nil, err
-	}
-	client := newS3ClientFromConfig(cfg, func(o *s3.Options) {
-		o.BaseEndpoint = aws.String(s.config.S3BaseEndpoint)
-	})
-	return newS3PresignClient(client), nil
+// GarbageReport describes the outcome of a blob garbage collection: the
+// objects found to be garbage and how many of them were deleted.
+type GarbageReport struct {
+	// Scanned counts all listed objects.
+	Scanned int
+	// Unreferenced lists objects that no file row refers to, e.g. those of
+	// replaced attachments and abandoned uploads.
+	Unreferenced []blobstore.ObjectInfo
+	// Tombstoned lists objects of files deleted before the grace period.
+	Tombstoned []blobstore.ObjectInfo
+	// Deleted counts the objects actually deleted; 0 on a dry run.
+	Deleted int
 }
 
-// GetPresignedPutUrl returns (storageKey, url) for a client to PUT an encrypted file.
-// The URL is short-lived and suitable for direct upload from the client.
-func (s *EntryService) GetPresignedPutUrl(ctx context.Context) (string, string, error) {
-	presignClient, err := s.getPresignClient()
-	if err != nil {
-		return "", "", err
+// Size returns the total size of the garbage objects found.
+func (r *GarbageReport) Size() int64 {
+	var n int64
+	for _, o := range r.Unreferenced {
+		n += o.Size
 	}
-	bucket := s.config.S3Bucket
-	key := GetRandomStorageKey()
-
-	req, err := presignPutObject(presignClient, ctx, &s3.PutObjectInput{
-		Bucket: &bucket,
-		Key:    &key,
-	}, s3.WithPresignExpires(15*time.Minute))
-	if err != nil {
-		return "", "", err
+	for _, o := range r.Tombstoned {
+		n += o.Size
 	}
-	return key, req.URL, nil
-}
-
-// GetPresignedGetUrl returns a short-lived URL to GET an object by storage key.
-func (s *EntryService) GetPresignedGetUrl(ctx context.Context, key string) (string, error) {
-	presignClient, err := s.getPresignClient()
-	if err != nil {
-		return "", err
-	}
-	bucket := s.config.S3Bucket
-	reg, err := presignGetObject(presignClient, ctx, &s3.GetObjectInput{
-		Bucket: &bucket,
-		Key:    &key,
-	}, s3.WithPresignExpires(15*time.Minute))
-	if err != nil {
-		return "", err
-	}
-	return reg.URL, nil
+	return n
 }
 
 // Sync merges client-submitted pending entries/files with server state,
-// returns processed (server-accepted) entries, server-side updates since
-// client maxVersion, new files created on the server, upload tasks for
-// the client, and the new global max version.
+// returns processed (server-accepted) entries, conflicting server copies,
+// server-side updates since client maxVersion, new files created on the
+// server, upload tasks for the client, and the new global max version.
+//
+// A client whose maxVersion predates tombstones that were already purged (see
+// PurgeTombstones) would never learn about those deletions; Sync returns
+// common.ErrResyncRequired and the client has to start over from version 0.
+//
+// Every pushed entry carries the BaseVersion it was edited from. A push whose
+// base is stale is not applied; the current server copy is returned as a
+// conflict instead and the entry's pending file, if any, is not registered.
 //
 // Workflow (simplified):
-//  1. Fetch server updates (entries/files) newer than client's maxVersion.
-//  2. For each pending file, generate a storage key + presigned PUT URL.
+//  1. Fetch server updates (entries/files) newer than client's maxVersion and
+//     check it against the user's purged version.
+//  2. For each pending file, generate a storage key + presigned PUT URL. A
+//     file that is re-pushed while its upload is still pending keeps its
+//     storage key, so that a multipart upload in progress can be resumed.
 //  3. In a transaction:
-//     - For each pending entry, increment user's global version and upsert.
+//     - Lock each pushed entry's server row and compare versions.
+//     - For each accepted entry, increment user's global version and upsert.
+//     - Record every accepted version in the entry's revision history.
+//     - Tombstone the file of every deleted entry and revive the file of
+//     every restored one.
 //     - Upsert new file metadata (pending state).
-//  4. Return processed entries, server updates, file upload tasks, and max version.
+//  4. Return processed entries, conflicts, server updates, file upload tasks,
+//     and max version.
 func (s *EntryService) Sync(
 	ctx context.Context,
 	userID string,
 	pendingEntries []*models.Entry,
 	pendingFiles []*models.File,
 	maxVersion int64,
-) ([]*models.Entry, []*models.Entry, []*models.File, []*models.FileUploadTask, int64, error) {
+) ([]*models.Entry, []*models.Entry, []*models.Entry, []*models.File, []*models.FileUploadTask, int64, error) {
 
-	userRepo := s.repomanager.Users(s.db)
 	entryRepo := s.repomanager.Entries(s.db)
 	fileRepo := s.repomanager.Files(s.db)
 
 	otherUpdatedEntries, err := entryRepo.SelectUpdated(ctx, userID, maxVersion)
 	if err != nil {
-		return nil, nil, nil, nil, 0, err
+		return nil, nil, nil, nil, nil, 0, err
 	}
 	otherUpdatedFiles, err := fileRepo.SelectUpdated(ctx, userID, maxVersion)
 	if err != nil {
-		return nil, nil, nil, nil, 0, err
+		return nil, nil, nil, nil, nil, 0, err
+	}
+
+	// Checked after the selects: a tombstone purged meanwhile was either
+	// already returned above or is reflected in the purged version.
+	if maxVersion > 0 {
+		purgedVersion, err := s.repomanager.Users(s.db).GetPurgedVersion(ctx, userID)
+		if err != nil {
+			return nil, nil, nil, nil, nil, 0, err
+		}
+		if maxVersion < purgedVersion {
+			return nil, nil, nil, nil, nil, 0, common.ErrResyncRequired
+		}
 	}
 
 	var (
 		processedEntries []*models.Entry
+		conflicts        []*models.Entry
 		uploadTasks      []*models.FileUploadTask
 		newFiles         []models.File
-		maxServerVersion int64
+		fileTasks        []*models.FileUploadTask
+		maxServerVersion = maxVersion
 	)
 
+	// The reported version must cover everything returned to the client, even
+	// when nothing is pushed.
+	for _, e := range otherUpdatedEntries {
+		maxServerVersion = max(maxServerVersion, e.Version)
+	}
+	for _, f := range otherUpdatedFiles {
+		maxServerVersion = max(maxServerVersion, f.Version)
+	}
+
 	// Prepare file records + presigned PUTs
 	for _, f := range pendingFiles {
-		storageKey, url, err := s.GetPresignedPutUrl(ctx)
+		storageKey, err := s.pendingStorageKey(ctx, userID, f)
 		if err != nil {
-			return nil, nil, nil, nil, 0, err
+			return nil, nil, nil, nil, nil, 0, err
+		}
+		url, err := s.store.PresignPut(ctx, storageKey)
+		if err != nil {
+			return nil, nil, nil, nil, nil, 0, err
 		}
 		newFiles = append(newFiles, models.File{
 			EntryID:          f.EntryID,
@@ -165,15 +169,40 @@ func (s *EntryService) Sync(
 			Version:          f.Version,
 			EncryptedFileKey: f.EncryptedFileKey,
 			Nonce:            f.Nonce,
+			Digest:           f.Digest,
 			StorageKey:       storageKey,
 			UploadStatus:     "pending",
 		})
-		uploadTasks = append(uploadTasks, &models.FileUploadTask{URL: url, EntryID: f.EntryID})
+		fileTasks = append(fileTasks, &models.FileUploadTask{URL: url, EntryID: f.EntryID})
 	}
 
 	// Persist entries and file rows transactionally.
 	if err := dbx.WithTx(ctx, s.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+		userRepo := s.repomanager.Users(tx)
+		entryRepo := s.repomanager.Entries(tx)
+		fileRepo := s.repomanager.Files(tx)
+		revisionRepo := s.repomanager.Revisions(tx)
+
+		entryVersions := make(map[string]int64, len(pendingEntries))
+		conflicted := make(map[string]bool)
 		for _, e := range pendingEntries {
+			current, err := entryRepo.GetForUpdate(ctx, userID, e.ID)
+			if err != nil && !errors.Is(err, common.ErrorNotFound) {
+				return err
+			}
+			if current != nil && current.Version != e.BaseVersion {
+				if sameContent(current, e) {
+					// Already stored, e.g. a retried push whose response
+					// was lost: acknowledge it with the server version.
+					entryVersions[e.ID] = current.Version
+					processedEntries = append(processedEntries, current)
+					continue
+				}
+				conflicted[e.ID] = true
+				conflicts = append(conflicts, current)
+				continue
+			}
+
 			version, err := userRepo.IncrementCurrentVersion(ctx, userID)
 			if err != nil {
 				return err
@@ -184,38 +213,449 @@ func (s *EntryService) Sync(
 			if err := entryRepo.CreateOrUpdate(ctx, e); err != nil {
 				return err
 			}
+			if err := revisionRepo.Create(ctx, e); err != nil {
+				return err
+			}
+			if e.Deleted {
+				if err := fileRepo.MarkDeleted(ctx, userID, e.ID); err != nil {
+					return err
+				}
+			} else if current != nil && current.Deleted {
+				if err := fileRepo.Restore(ctx, userID, e.ID, version); err != nil {
+					return err
+				}
+			}
+			entryVersions[e.ID] = version
 			processedEntries = append(processedEntries, e)
 		}
-		for _, f := range newFiles {
-			if err := fileRepo.CreateOrUpdate(ctx, &f); err != nil {
+		// File rows share the version of their entry so that other devices
+		// pull them together; a file pushed without its entry gets its own,
+		// provided the entry belongs to the user. Files of conflicting
+		// entries wait until the conflict is resolved.
+		for i := range newFiles {
+			if conflicted[newFiles[i].EntryID] {
+				continue
+			}
+			version, ok := entryVersions[newFiles[i].EntryID]
+			if !ok {
+				if _, err := entryRepo.GetForUpdate(ctx, userID, newFiles[i].EntryID); err != nil {
+					if errors.Is(err, common.ErrorNotFound) {
+						return common.ErrorForbidden
+					}
+					return err
+				}
+				v, err := userRepo.IncrementCurrentVersion(ctx, userID)
+				if err != nil {
+					return err
+				}
+				version = v
+				maxServerVersion = v
+			}
+			newFiles[i].Version = version
+			if err := fileRepo.CreateOrUpdate(ctx, &newFiles[i]); err != nil {
 				return err
 			}
+			uploadTasks = append(uploadTasks, fileTasks[i])
 		}
 		return nil
 	}); err != nil {
-		return nil, nil, nil, nil, 0, fmt.Errorf("error creating entries: %v", err)
+		return nil, nil, nil, nil, nil, 0, fmt.Errorf("error creating entries: %v", err)
+	}
+
+	return processedEntries, conflicts, otherUpdatedEntries, otherUpdatedFiles, uploadTasks, maxServerVersion, nil
+}
+
+// pendingStorageKey returns the storage key for a pushed file: the key of the
+// stored row if that is the same file still waiting for its upload, a new
+// random key otherwise.
+func (s *EntryService) pendingStorageKey(ctx context.Context, userID string, f *models.File) (string, error) {
+	stored, err := s.repomanager.Files(s.db).GetByEntryID(ctx, f.EntryID)
+	if err != nil && !errors.Is(err, common.ErrorNotFound) {
+		return "", err
+	}
+	if stored != nil && stored.UserID == userID && stored.UploadStatus == "pending" &&
+		stored.StorageKey != "" && bytes.Equal(stored.Nonce, f.Nonce) {
+		return stored.StorageKey, nil
+	}
+	return GetRandomStorageKey(), nil
+}
+
+// sameContent reports whether a pushed entry is byte-identical to the stored
+// one. Nonces are random per encryption, so equal ciphertexts mean the client
+// is resending what the server already has.
+func sameContent(stored, pushed *models.Entry) bool {
+	return stored.Deleted == pushed.Deleted &&
+		bytes.Equal(stored.NonceDetails, pushed.NonceDetails) &&
+		bytes.Equal(stored.Details, pushed.Details) &&
+		bytes.Equal(stored.NonceOverview, pushed.NonceOverview) &&
+		bytes.Equal(stored.Overview, pushed.Overview)
+}
+
+// PurgeTombstones permanently removes entries that were deleted before the
+// given time, together with their revisions, file rows and stored objects,
+// and returns how many entries were purged. Each entry is purged in its own
+// transaction that also raises the owner's purged version, so that clients
+// which have not seen the tombstone yet are told to resync.
+//
+// An entry restored while the purge runs is skipped. Objects are deleted only
+// after their rows are gone; a failed object deletion does not stop the purge
+// and is reported in the returned error.
+func (s *EntryService) PurgeTombstones(ctx context.Context, before time.Time) (int, error) {
+	var (
+		purged  int
+		objErrs []error
+	)
+	for {
+		batch, err := s.repomanager.Entries(s.db).SelectPurgeable(ctx, before, purgeBatchSize)
+		if err != nil {
+			return purged, fmt.Errorf("error selecting tombstones: %w", err)
+		}
+
+		for _, e := range batch {
+			var key string
+			err := dbx.WithTx(ctx, s.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+				var err error
+				if key, err = s.repomanager.Files(tx).Purge(ctx, e.UserID, e.ID); err != nil {
+					return err
+				}
+				if err := s.repomanager.Entries(tx).Purge(ctx, e.UserID, e.ID); err != nil {
+					return err
+				}
+				return s.repomanager.Users(tx).RaisePurgedVersion(ctx, e.UserID, e.Version)
+			})
+			if errors.Is(err, common.ErrorNotFound) {
+				continue
+			}
+			if err != nil {
+				return purged, fmt.Errorf("error purging entry %s: %w", e.ID, err)
+			}
+			purged++
+
+			if key != "" {
+				if err := s.store.Delete(ctx, key); err != nil {
+					objErrs = append(objErrs, fmt.Errorf("error deleting object %s: %w", key, err))
+				}
+			}
+		}
+
+		if len(batch) < purgeBatchSize {
+			return purged, errors.Join(objErrs...)
+		}
+	}
+}
+
+// CollectGarbage lists the objects in the blob store under the storage key
+// prefix, reconciles them with the file rows and deletes those that are
+// garbage: objects no row refers to and objects of files deleted before the
+// given time. Objects stored after that time are always kept, so that the
+// grace period also covers uploads in progress. On a dry run nothing is
+// deleted and the report shows what would be.
+//
+// The row of a collected tombstoned file is purged before its object is
+// deleted, so that a later undelete of its entry does not revive a file
+// without content; a file restored meanwhile is kept. A failed object
+// deletion does not stop the collection and is reported in the returned
+// error.
+func (s *EntryService) CollectGarbage(ctx context.Context, before time.Time, dryRun bool) (*GarbageReport, error) {
+	report := &GarbageReport{}
+	var (
+		batch   []blobstore.ObjectInfo
+		objErrs []error
+	)
+	collect := func() error {
+		errs, err := s.collectBatch(ctx, batch, before, dryRun, report)
+		objErrs = append(objErrs, errs...)
+		batch = batch[:0]
+		return err
+	}
+
+	err := s.store.List(ctx, storageKeyPrefix, func(o blobstore.ObjectInfo) error {
+		report.Scanned++
+		if !o.ModTime.Before(before) {
+			return nil
+		}
+		batch = append(batch, o)
+		if len(batch) == gcBatchSize {
+			return collect()
+		}
+		return nil
+	})
+	if err == nil && len(batch) > 0 {
+		err = collect()
+	}
+	if err != nil {
+		return report, fmt.Errorf("error collecting garbage: %w", err)
+	}
+	return report, errors.Join(objErrs...)
+}
+
+// collectBatch looks up the file rows of a batch of objects and deletes the
+// garbage among them. It returns the errors of failed object deletions
+// separately from errors that stop the collection.
+func (s *EntryService) collectBatch(ctx context.Context, batch []blobstore.ObjectInfo, before time.Time, dryRun bool, report *GarbageReport) ([]error, error) {
+	fileRepo := s.repomanager.Files(s.db)
+
+	keys := make([]string, len(batch))
+	for i, o := range batch {
+		keys[i] = o.Key
+	}
+	rows, err := fileRepo.SelectByStorageKeys(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]*models.File, len(rows))
+	for _, f := range rows {
+		byKey[f.StorageKey] = f
 	}
 
-	return processedEntries, otherUpdatedEntries, otherUpdatedFiles, uploadTasks, maxServerVersion, nil
+	var objErrs []error
+	for _, o := range batch {
+		f, referenced := byKey[o.Key]
+		switch {
+		case !referenced:
+			report.Unreferenced = append(report.Unreferenced, o)
+		case f.Deleted && f.UpdatedAt.Before(before):
+			report.Tombstoned = append(report.Tombstoned, o)
+		default:
+			continue
+		}
+		if dryRun {
+			continue
+		}
+
+		if referenced {
+			purged, err := fileRepo.PurgeDeletedByStorageKey(ctx, o.Key, before)
+			if err != nil {
+				return objErrs, err
+			}
+			if !purged {
+				// restored meanwhile
+				continue
+			}
+		}
+		if err := s.store.Delete(ctx, o.Key); err != nil {
+			objErrs = append(objErrs, fmt.Errorf("error deleting object %s: %w", o.Key, err))
+			continue
+		}
+		report.Deleted++
+	}
+	return objErrs, nil
+}
+
+// ListRevisions returns the stored versions of the user's entry, newest
+// first. Unknown entries have no revisions.
+func (s *EntryService) ListRevisions(ctx context.Context, userID string, entryID string) ([]*models.Entry, error) {
+	revs, err := s.repomanager.Revisions(s.db).List(ctx, userID, entryID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing revisions: %w", err)
+	}
+	return revs, nil
+}
+
+// GetRevision returns one stored version of the user's entry. Returns
+// common.ErrorNotFound if the user has no such revision.
+func (s *EntryService) GetRevision(ctx context.Context, userID string, entryID string, version int64) (*models.Entry, error) {
+	rev, err := s.repomanager.Revisions(s.db).Get(ctx, userID, entryID, version)
+	if err != nil {
+		return nil, fmt.Errorf("error getting revision: %w", err)
+	}
+	return rev, nil
 }
 
 // MarkUploaded marks the file for the given entry as uploaded (completed).
-func (s *EntryService) MarkUploaded(ctx context.Context, id string) error {
+// Returns common.ErrorNotFound if the entry has no file or its object is not
+// in the blob store, and common.ErrorForbidden if it belongs to another user.
+func (s *EntryService) MarkUploaded(ctx context.Context, userID string, id string) error {
 	fileRepo := s.repomanager.Files(s.db)
-	if err := fileRepo.MarkUploaded(ctx, id); err != nil {
-		return fmt.Errorf("error updating file: %v", err)
+
+	f, err := s.getOwnedFile(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if _, err := s.store.Stat(ctx, f.StorageKey); err != nil {
+		return fmt.Errorf("error checking uploaded object: %w", err)
+	}
+	if err := fileRepo.MarkUploaded(ctx, userID, id); err != nil {
+		return fmt.Errorf("error updating file: %w", err)
+	}
+	return nil
+}
+
+// UpdateFileKey replaces the wrapped key of the file of the given entry, e.g.
+// when a client re-wraps a legacy plaintext key, and gives the file a new
+// version so that other devices pull the new key on their next sync.
+// Returns common.ErrorNotFound if the entry has no file and
+// common.ErrorForbidden if it belongs to another user.
+func (s *EntryService) UpdateFileKey(ctx context.Context, userID string, id string, key []byte) error {
+	if _, err := s.getOwnedFile(ctx, userID, id); err != nil {
+		return err
+	}
+	if err := dbx.WithTx(ctx, s.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+		version, err := s.repomanager.Users(tx).IncrementCurrentVersion(ctx, userID)
+		if err != nil {
+			return err
+		}
+		return s.repomanager.Files(tx).UpdateKey(ctx, userID, id, key, version)
+	}); err != nil {
+		return fmt.Errorf("error updating file key: %w", err)
 	}
 	return nil
 }
 
 // GetPresignedGetURL returns a presigned GET URL for the file associated
 // with the given entry ID after verifying ownership and loading storage key.
-func (s *EntryService) GetPresignedGetURL(ctx context.Context, id string) (string, error) {
+func (s *EntryService) GetPresignedGetURL(ctx context.Context, userID string, id string) (string, error) {
+	f, err := s.getOwnedFile(ctx, userID, id)
+	if err != nil {
+		return "", err
+	}
+
+	return s.store.PresignGet(ctx, f.StorageKey)
+}
+
+// getOwnedFile loads the file row of the given entry and verifies that it
+// belongs to userID.
+func (s *EntryService) getOwnedFile(ctx context.Context, userID string, id string) (*models.File, error) {
 	fileRepo := s.repomanager.Files(s.db)
+
 	f, err := fileRepo.GetByEntryID(ctx, id)
 	if err != nil {
-		return "", fmt.Errorf("error getting file: %v", err)
+		return nil, fmt.Errorf("error getting file: %w", err)
+	}
+	if f.UserID != userID {
+		return nil, common.ErrorForbidden
+	}
+	return f, nil
+}
+
+// RequestUploadURL returns a fresh presigned PUT URL for the pending file of
+// the given entry, for a client whose upload task from an earlier sync was
+// lost or has expired. The file keeps its storage key, so that a multipart
+// upload in progress stays valid. Returns common.ErrorNotFound if the entry
+// has no pending file and common.ErrorForbidden if it belongs to another
+// user.
+func (s *EntryService) RequestUploadURL(ctx context.Context, userID string, id string) (string, error) {
+	f, err := s.getOwnedFile(ctx, userID, id)
+	if err != nil {
+		return "", err
+	}
+	if f.UploadStatus != "pending" {
+		return "", common.ErrorNotFound
+	}
+	return s.store.PresignPut(ctx, f.StorageKey)
+}
+
+// StaleUploads returns the files of all users that were registered by a sync
+// before the given time and are still waiting for their upload, oldest
+// first. Their clients may have given up on them, and whatever they stored
+// in the meantime is never referenced.
+func (s *EntryService) StaleUploads(ctx context.Context, before time.Time) ([]*models.File, error) {
+	files, err := s.repomanager.Files(s.db).SelectStalePending(ctx, before)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting pending files: %w", err)
+	}
+	return files, nil
+}
+
+// StartMultipartUpload starts a multipart upload for the pending file of
+// the given entry and returns its upload ID. A file already being uploaded in
+// parts returns the upload in progress, so that a client that restarts can
+// resume it. Returns common.ErrorNotFound if the entry has no pending file
+// and common.ErrorForbidden if it belongs to another user.
+func (s *EntryService) StartMultipartUpload(ctx context.Context, userID string, id string) (string, error) {
+	f, err := s.getOwnedFile(ctx, userID, id)
+	if err != nil {
+		return "", err
+	}
+	if f.UploadStatus != "pending" {
+		return "", common.ErrorNotFound
+	}
+	if f.UploadID != "" {
+		return f.UploadID, nil
+	}
+
+	uploadID, err := s.store.CreateMultipartUpload(ctx, f.StorageKey)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.repomanager.Files(s.db).SetUploadID(ctx, userID, id, f.StorageKey, uploadID); err != nil {
+		// the file was replaced meanwhile
+		_ = s.store.AbortMultipartUpload(ctx, f.StorageKey, uploadID)
+		return "", fmt.Errorf("error updating file: %w", err)
+	}
+	return uploadID, nil
+}
+
+// PresignUploadParts returns a presigned PUT URL for every part number of the
+// multipart upload uploadID of the given entry's file, in the same order.
+// Returns common.ErrorNotFound if uploadID is not the upload in progress and
+// common.ErrorForbidden if the file belongs to another user.
+func (s *EntryService) PresignUploadParts(ctx context.Context, userID string, id string, uploadID string, parts []int32) ([]string, error) {
+	f, err := s.getMultipartFile(ctx, userID, id, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, 0, len(parts))
+	for _, part := range parts {
+		url, err := s.store.PresignUploadPart(ctx, f.StorageKey, uploadID, part)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, url)
+	}
+	return urls, nil
+}
+
+// CompleteMultipartUpload assembles the uploaded parts into the file's object
+// and forgets the upload. The client still marks the file uploaded afterwards
+// (see MarkUploaded), as after a single PUT. Returns common.ErrorNotFound if
+// uploadID is not the upload in progress and common.ErrorForbidden if the
+// file belongs to another user.
+func (s *EntryService) CompleteMultipartUpload(ctx context.Context, userID string, id string, uploadID string, parts []models.UploadedPart) error {
+	f, err := s.getMultipartFile(ctx, userID, id, uploadID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.CompleteMultipartUpload(ctx, f.StorageKey, uploadID, parts); err != nil {
+		return err
+	}
+
+	if err := s.repomanager.Files(s.db).SetUploadID(ctx, userID, id, f.StorageKey, ""); err != nil {
+		return fmt.Errorf("error updating file: %w", err)
+	}
+	return nil
+}
+
+// AbortMultipartUpload discards the multipart upload uploadID of the given
+// entry's file and its stored parts, so that the next upload starts over.
+// Returns common.ErrorNotFound if uploadID is not the upload in progress and
+// common.ErrorForbidden if the file belongs to another user.
+func (s *EntryService) AbortMultipartUpload(ctx context.Context, userID string, id string, uploadID string) error {
+	f, err := s.getMultipartFile(ctx, userID, id, uploadID)
+	if err != nil {
+		return err
+	}
+	if err := s.store.AbortMultipartUpload(ctx, f.StorageKey, uploadID); err != nil {
+		return err
+	}
+	if err := s.repomanager.Files(s.db).SetUploadID(ctx, userID, id, f.StorageKey, ""); err != nil {
+		return fmt.Errorf("error updating file: %w", err)
+	}
+	return nil
+}
+
+// getMultipartFile loads the file of the given entry owned by userID and
+// verifies that uploadID is its multipart upload in progress.
+func (s *EntryService) getMultipartFile(ctx context.Context, userID string, id string, uploadID string) (*models.File, error) {
+	f, err := s.getOwnedFile(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if f.UploadStatus != "pending" || f.UploadID == "" || f.UploadID != uploadID {
+		return nil, common.ErrorNotFound
 	}
-	url, err := s.GetPresignedGetUrl(ctx, f.StorageKey)
-	return url, err
+	return f, nil
 }
@@ -5,16 +5,19 @@ import (
 	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/dmitrijs2005/gophkeeper/internal/common"
 	"github.com/dmitrijs2005/gophkeeper/internal/dbx"
-	sc "github.com/dmitrijs2005/gophkeeper/internal/server/config"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
 	entriesrepo "github.com/dmitrijs2005/gophkeeper/internal/server/repositories/entries"
 	filesrepo "github.com/dmitrijs2005/gophkeeper/internal/server/repositories/files"
+	loginsessionsrepo "github.com/dmitrijs2005/gophkeeper/internal/server/repositories/loginsessions"
+	ratelimitsrepo "github.com/dmitrijs2005/gophkeeper/internal/server/repositories/ratelimits"
 	refreshtokensrepo "github.com/dmitrijs2005/gophkeeper/internal/server/repositories/refreshtokens"
+	revisionsrepo "github.com/dmitrijs2005/gophkeeper/internal/server/repositories/revisions"
+	twofactorrepo "github.com/dmitrijs2005/gophkeeper/internal/server/repositories/twofactor"
 	usersrepo "github.com/dmitrijs2005/gophkeeper/internal/server/repositories/users"
 )
 
@@ -29,6 +32,18 @@ func (f *fakeUsersRepoSE) Create(context.Context, *models.User) (*models.User, e
 func (f *fakeUsersRepoSE) GetUserByLogin(context.Context, string) (*models.User, error) {
 	return nil, nil
 }
+func (f *fakeUsersRepoSE) GetUserByID(context.Context, string) (*models.User, error) {
+	return nil, nil
+}
+func (f *fakeUsersRepoSE) UpgradeKeys(context.Context, string, []byte, []byte) error { return nil }
+func (f *fakeUsersRepoSE) ChangeCredentials(context.Context, string, []byte, models.Credentials) error {
+	return nil
+}
+func (f *fakeUsersRepoSE) GetPurgedVersion(context.Context, string) (int64, error)    { return 0, nil }
+func (f *fakeUsersRepoSE) RaisePurgedVersion(context.Context, string, int64) error    { return nil }
+func (f *fakeUsersRepoSE) SetPendingTOTPSecret(context.Context, string, []byte) error { return nil }
+func (f *fakeUsersRepoSE) EnableTOTP(context.Context, string, []byte, int64) error    { return nil }
+func (f *fakeUsersRepoSE) UseTOTPStep(context.Context, string, int64) error           { return nil }
 
 type fakeEntriesRepoSE struct{}
 
@@ -36,6 +51,13 @@ func (f *fakeEntriesRepoSE) SelectUpdated(ctx context.Context, userID string, mi
 	return nil, nil
 }
 func (f *fakeEntriesRepoSE) CreateOrUpdate(context.Context, *models.Entry) error { return nil }
+func (f *fakeEntriesRepoSE) GetForUpdate(context.Context, string, string) (*models.Entry, error) {
+	return nil, common.ErrorNotFound
+}
+func (f *fakeEntriesRepoSE) SelectPurgeable(context.Context, time.Time, int) ([]*models.Entry, error) {
+	return nil, nil
+}
+func (f *fakeEntriesRepoSE) Purge(context.Context, string, string) error { return nil }
 
 type fakeFilesRepoSE struct{}
 
@@ -43,10 +65,33 @@ func (f *fakeFilesRepoSE) SelectUpdated(ctx context.Context, userID string, minV
 	return nil, nil
 }
 func (f *fakeFilesRepoSE) CreateOrUpdate(context.Context, *models.File) error { return nil }
-func (f *fakeFilesRepoSE) MarkUploaded(context.Context, string) error         { return nil }
+func (f *fakeFilesRepoSE) MarkUploaded(context.Context, string, string) error { return nil }
+func (f *fakeFilesRepoSE) MarkDeleted(context.Context, string, string) error  { return nil }
 func (f *fakeFilesRepoSE) GetByEntryID(context.Context, string) (*models.File, error) {
 	return nil, nil
 }
+func (f *fakeFilesRepoSE) UpdateKey(context.Context, string, string, []byte, int64) error {
+	return nil
+}
+func (f *fakeFilesRepoSE) Restore(context.Context, string, string, int64) error { return nil }
+func (f *fakeFilesRepoSE) SetUploadID(context.Context, string, string, string, string) error {
+	return nil
+}
+func (f *fakeFilesRepoSE) SelectStalePending(context.Context, time.Time) ([]*models.File, error) {
+	return nil, nil
+}
+func (f *fakeFilesRepoSE) Purge(context.Context, string, string) (string, error) {
+	return "", nil
+}
+func (f *fakeFilesRepoSE) SelectByStorageKeys(context.Context, []string) ([]*models.File, error) {
+	return nil, nil
+}
+func (f *fakeFilesRepoSE) PurgeDeletedByUser(context.Context, string) error {
+	return nil
+}
+func (f *fakeFilesRepoSE) PurgeDeletedByStorageKey(context.Context, string, time.Time) (bool, error) {
+	return false, nil
+}
 
 type fakeRepoMgrSE struct {
 	u *fakeUsersRepoSE
@@ -59,6 +104,10 @@ func (m *fakeRepoMgrSE) Users(db dbx.DBTX) usersrepo.Repository
 func (m *fakeRepoMgrSE) Entries(db dbx.DBTX) entriesrepo.Repository             { return m.e }
 func (m *fakeRepoMgrSE) Files(db dbx.DBTX) filesrepo.Repository                 { return m.f }
 func (m *fakeRepoMgrSE) RefreshTokens(db dbx.DBTX) refreshtokensrepo.Repository { return nil }
+func (m *fakeRepoMgrSE) Revisions(db dbx.DBTX) revisionsrepo.Repository         { return nil }
+func (m *fakeRepoMgrSE) LoginSessions(db dbx.DBTX) loginsessionsrepo.Repository { return nil }
+func (m *fakeRepoMgrSE) RateLimits(db dbx.DBTX) ratelimitsrepo.Repository       { return nil }
+func (m *fakeRepoMgrSE) TwoFactor(db dbx.DBTX) twofactorrepo.Repository         { return nil }
 
 func TestSync_PresignPutError_NoTransaction(t *testing.T) {
 	db, mock, err := sqlmock.New()
@@ -67,25 +116,11 @@ func TestSync_PresignPutError_NoTransaction(t *testing.T) {
 	}
 	defer db.Close()
 
-	cfg := &sc.Config{
-		S3Region:       "us-east-1",
-		S3RootUser:     "minioadmin",
-		S3RootPassword: "minioadmin",
-		S3BaseEndpoint: "http://127.0.0.1:9000",
-		S3Bucket:       "gophkeeper",
-		SecretKey:      "k",
-	}
 	svc := NewEntryService(db, &fakeRepoMgrSE{
 		u: &fakeUsersRepoSE{}, e: &fakeEntriesRepoSE{}, f: &fakeFilesRepoSE{},
-	}, cfg)
-
-	orig := loadDefaultAWSConfig
-	defer func() { loadDefaultAWSConfig = orig }()
-	loadDefaultAWSConfig = func(ctx context.Context, optFns ...func(*awsconfig.LoadOptions) error) (aws.Config, error) {
-		return aws.Config{}, errors.New("presign-fail")
-	}
+	}, &fakeBlobStore{putErr: errors.New("presign-fail")})
 
-	_, _, _, _, _, err = svc.Sync(context.Background(), "u1",
+	_, _, _, _, _, _, err = svc.Sync(context.Background(), "u1",
 		[]*models.Entry{{ID: "e1"}},
 		[]*models.File{{EntryID: "e1", Version: 1}},
 		0,
@@ -3,17 +3,22 @@ package services
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dmitrijs2005/gophkeeper/internal/common"
 	"github.com/dmitrijs2005/gophkeeper/internal/dbx"
-	"github.com/dmitrijs2005/gophkeeper/internal/server/config"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/blobstore"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/entries"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/files"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/repomanager"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/revisions"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/users"
 )
 
@@ -23,6 +28,21 @@ type fakeUsersRepo struct {
 	users.Repository
 	incVer int64
 	err    error
+
+	purgedVersion int64
+	raised        map[string]int64
+}
+
+func (f *fakeUsersRepo) GetPurgedVersion(ctx context.Context, userID string) (int64, error) {
+	return f.purgedVersion, nil
+}
+
+func (f *fakeUsersRepo) RaisePurgedVersion(ctx context.Context, userID string, version int64) error {
+	if f.raised == nil {
+		f.raised = map[string]int64{}
+	}
+	f.raised[userID] = max(f.raised[userID], version)
+	return nil
 }
 
 func (f *fakeUsersRepo) IncrementCurrentVersion(ctx context.Context, userID string) (int64, error) {
@@ -41,6 +61,33 @@ type fakeEntriesRepo struct {
 	createErr error
 
 	created []*models.Entry
+
+	current map[string]*models.Entry
+
+	purgeable []*models.Entry
+	purged    []string
+	restored  map[string]bool
+}
+
+func (f *fakeEntriesRepo) SelectPurgeable(ctx context.Context, before time.Time, limit int) ([]*models.Entry, error) {
+	batch := f.purgeable
+	f.purgeable = nil
+	return batch, nil
+}
+
+func (f *fakeEntriesRepo) Purge(ctx context.Context, userID string, id string) error {
+	if f.restored[id] {
+		return common.ErrorNotFound
+	}
+	f.purged = append(f.purged, id)
+	return nil
+}
+
+func (f *fakeEntriesRepo) GetForUpdate(ctx context.Context, userID string, id string) (*models.Entry, error) {
+	if e, ok := f.current[id]; ok {
+		return e, nil
+	}
+	return nil, common.ErrorNotFound
 }
 
 func (f *fakeEntriesRepo) SelectUpdated(ctx context.Context, userID string, minVersion int64) ([]*models.Entry, error) {
@@ -62,17 +109,100 @@ type fakeFilesRepo struct {
 
 	markErr error
 
+	deleted []string
+
 	getByID *models.File
 	getErr  error
+
+	restored []string
+	keys     map[string]string
+
+	updatedKeys map[string][]byte
+
+	uploadIDs   map[string]string
+	uploadIDErr error
+
+	upserted []*models.File
+
+	stale       []*models.File
+	staleBefore time.Time
+
+	byKey        map[string]*models.File
+	restoredKeys map[string]bool
+	purgedKeys   []string
+	purgedUsers  []string
+}
+
+func (f *fakeFilesRepo) PurgeDeletedByUser(ctx context.Context, userID string) error {
+	f.purgedUsers = append(f.purgedUsers, userID)
+	return nil
+}
+
+func (f *fakeFilesRepo) SelectByStorageKeys(ctx context.Context, keys []string) ([]*models.File, error) {
+	if f.selErr != nil {
+		return nil, f.selErr
+	}
+	var res []*models.File
+	for _, k := range keys {
+		if file, ok := f.byKey[k]; ok {
+			res = append(res, file)
+		}
+	}
+	return res, nil
+}
+
+func (f *fakeFilesRepo) PurgeDeletedByStorageKey(ctx context.Context, key string, before time.Time) (bool, error) {
+	if f.restoredKeys[key] {
+		return false, nil
+	}
+	f.purgedKeys = append(f.purgedKeys, key)
+	return true, nil
+}
+
+func (f *fakeFilesRepo) SelectStalePending(ctx context.Context, before time.Time) ([]*models.File, error) {
+	f.staleBefore = before
+	return f.stale, f.selErr
+}
+
+func (f *fakeFilesRepo) SetUploadID(ctx context.Context, userID string, id string, storageKey string, uploadID string) error {
+	if f.uploadIDErr != nil {
+		return f.uploadIDErr
+	}
+	if f.uploadIDs == nil {
+		f.uploadIDs = map[string]string{}
+	}
+	f.uploadIDs[id] = uploadID
+	return nil
+}
+
+func (f *fakeFilesRepo) UpdateKey(ctx context.Context, userID string, id string, key []byte, version int64) error {
+	if f.updatedKeys == nil {
+		f.updatedKeys = map[string][]byte{}
+	}
+	f.updatedKeys[id] = key
+	return nil
+}
+
+func (f *fakeFilesRepo) Restore(ctx context.Context, userID string, id string, version int64) error {
+	f.restored = append(f.restored, id)
+	return nil
+}
+func (f *fakeFilesRepo) Purge(ctx context.Context, userID string, id string) (string, error) {
+	return f.keys[id], nil
 }
 
 func (f *fakeFilesRepo) SelectUpdated(ctx context.Context, userID string, minVersion int64) ([]*models.File, error) {
 	return f.selUpdated, f.selErr
 }
 func (f *fakeFilesRepo) CreateOrUpdate(ctx context.Context, file *models.File) error {
+	f.upserted = append(f.upserted, file)
+	return nil
+}
+func (f *fakeFilesRepo) MarkDeleted(ctx context.Context, userID string, id string) error {
+	f.deleted = append(f.deleted, id)
 	return nil
 }
-func (f *fakeFilesRepo) MarkUploaded(ctx context.Context, id string) error {
+func (f *fakeFilesRepo) MarkUploaded(ctx context.Context, userID string, id string) error {
 	return f.markErr
 }
 func (f *fakeFilesRepo) GetByEntryID(ctx context.Context, id string) (*models.File, error) {
@@ -82,16 +212,115 @@ func (f *fakeFilesRepo) GetByEntryID(ctx context.Context, id string) (*models.Fi
 	return f.getByID, nil
 }
 
+type fakeRevisionsRepo struct {
+	revisions.Repository
+	created      []*models.Entry
+	list         []*models.Entry
+	get          *models.Entry
+	err          error
+	deletedUsers []string
+}
+
+func (f *fakeRevisionsRepo) DeleteByUser(ctx context.Context, userID string) error {
+	f.deletedUsers = append(f.deletedUsers, userID)
+	return nil
+}
+
+func (f *fakeRevisionsRepo) Create(ctx context.Context, e *models.Entry) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.created = append(f.created, e)
+	return nil
+}
+func (f *fakeRevisionsRepo) List(ctx context.Context, userID string, entryID string) ([]*models.Entry, error) {
+	return f.list, f.err
+}
+func (f *fakeRevisionsRepo) Get(ctx context.Context, userID string, entryID string, version int64) (*models.Entry, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.get, nil
+}
+
 type fakeRepoManager struct {
 	repomanager.RepositoryManager
 	u *fakeUsersRepo
 	e *fakeEntriesRepo
 	f *fakeFilesRepo
+	r *fakeRevisionsRepo
 }
 
 func (m *fakeRepoManager) Users(dbx dbx.DBTX) users.Repository     { return m.u }
 func (m *fakeRepoManager) Entries(dbx dbx.DBTX) entries.Repository { return m.e }
 func (m *fakeRepoManager) Files(dbx dbx.DBTX) files.Repository     { return m.f }
+func (m *fakeRepoManager) Revisions(dbx dbx.DBTX) revisions.Repository {
+	if m.r == nil {
+		m.r = &fakeRevisionsRepo{}
+	}
+	return m.r
+}
+
+// fakeBlobStore presigns URLs that spell out their operation and records
+// what is done to the stored objects.
+type fakeBlobStore struct {
+	putErr    error
+	missing   map[string]bool
+	deleted   []string
+	deleteErr map[string]error
+	created   int
+	completed []models.UploadedPart
+	aborted   []string
+	objects   []blobstore.ObjectInfo
+}
+
+var _ blobstore.BlobStore = (*fakeBlobStore)(nil)
+
+func (b *fakeBlobStore) PresignPut(ctx context.Context, key string) (string, error) {
+	if b.putErr != nil {
+		return "", b.putErr
+	}
+	return "https://blobs/put/" + key, nil
+}
+func (b *fakeBlobStore) PresignGet(ctx context.Context, key string) (string, error) {
+	return "https://blobs/get/" + key, nil
+}
+func (b *fakeBlobStore) Delete(ctx context.Context, key string) error {
+	b.deleted = append(b.deleted, key)
+	return b.deleteErr[key]
+}
+func (b *fakeBlobStore) Stat(ctx context.Context, key string) (blobstore.ObjectInfo, error) {
+	if b.missing[key] {
+		return blobstore.ObjectInfo{}, common.ErrorNotFound
+	}
+	return blobstore.ObjectInfo{Size: 1}, nil
+}
+func (b *fakeBlobStore) List(ctx context.Context, prefix string, fn func(blobstore.ObjectInfo) error) error {
+	for _, o := range b.objects {
+		if !strings.HasPrefix(o.Key, prefix) {
+			continue
+		}
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (b *fakeBlobStore) CreateMultipartUpload(ctx context.Context, key string) (string, error) {
+	b.created++
+	return "up1", nil
+}
+func (b *fakeBlobStore) PresignUploadPart(ctx context.Context, key string, uploadID string, part int32) (string, error) {
+	return fmt.Sprintf("https://blobs/%s/%s/%d", key, uploadID, part), nil
+}
+func (b *fakeBlobStore) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []models.UploadedPart) error {
+	b.completed = parts
+	return nil
+}
+func (b *fakeBlobStore) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	b.aborted = append(b.aborted, uploadID)
+	return nil
+}
 
 // -------- helpers --------
 
@@ -106,15 +335,7 @@ func newSQLMockDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
 
 func newService(t *testing.T, db *sql.DB, m *fakeRepoManager) *EntryService {
 	t.Helper()
-	cfg := &config.Config{
-		S3Region:       "us-east-1",
-		S3RootUser:     "x",
-		S3RootPassword: "y",
-		S3BaseEndpoint: "http://127.0.0.1:9000",
-		S3Bucket:       "bucket",
-		SecretKey:      "k",
-	}
-	return NewEntryService(db, m, cfg)
+	return NewEntryService(db, m, &fakeBlobStore{})
 }
 
 // -------- tests --------
@@ -147,7 +368,7 @@ func TestSync_Success_NoFiles(t *testing.T) {
 		{ID: "p2"},
 	}
 	pendingFiles := []*models.File{}
-	processed, otherEntries, otherFiles, uploadTasks, maxVer, err := s.Sync(ctx, "user-1", pendingEntries, pendingFiles, 1)
+	processed, conflicts, otherEntries, otherFiles, uploadTasks, maxVer, err := s.Sync(ctx, "user-1", pendingEntries, pendingFiles, 1)
 	if err != nil {
 		t.Fatalf("Sync error: %v", err)
 	}
@@ -161,6 +382,9 @@ func TestSync_Success_NoFiles(t *testing.T) {
 	if processed[0].Version != 1 || processed[1].Version != 2 {
 		t.Fatalf("unexpected versions: %d, %d", processed[0].Version, processed[1].Version)
 	}
+	if len(conflicts) != 0 {
+		t.Fatalf("unexpected conflicts: %+v", conflicts)
+	}
 	if len(otherEntries) != 1 || otherEntries[0].ID != "o1" {
 		t.Fatalf("unexpected other entries: %+v", otherEntries)
 	}
@@ -180,6 +404,178 @@ func TestSync_Success_NoFiles(t *testing.T) {
 	}
 }
 
+func TestSync_StaleBaseVersion_ReturnsConflict(t *testing.T) {
+	db, mock := newSQLMockDB(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	server := &models.Entry{ID: "c1", Version: 8, Details: []byte("server"), NonceDetails: []byte("n-server")}
+	same := &models.Entry{ID: "s1", Version: 4, Details: []byte("same"), NonceDetails: []byte("n-same")}
+	ok := &models.Entry{ID: "k1", Version: 5, Details: []byte("old"), NonceDetails: []byte("n-old")}
+	e := &fakeEntriesRepo{current: map[string]*models.Entry{"c1": server, "s1": same, "k1": ok}}
+	f := &fakeFilesRepo{}
+	u := &fakeUsersRepo{incVer: 10}
+	s := newService(t, db, &fakeRepoManager{u: u, e: e, f: f})
+
+	pending := []*models.Entry{
+		{ID: "c1", BaseVersion: 6, Details: []byte("local"), NonceDetails: []byte("n-local")},
+		{ID: "s1", BaseVersion: 0, Details: []byte("same"), NonceDetails: []byte("n-same")},
+		{ID: "k1", BaseVersion: 5, Details: []byte("new"), NonceDetails: []byte("n-new")},
+	}
+	processed, conflicts, _, _, _, maxVer, err := s.Sync(context.Background(), "u", pending, nil, 0)
+	if err != nil {
+		t.Fatalf("Sync error: %v", err)
+	}
+
+	if len(conflicts) != 1 || conflicts[0] != server {
+		t.Fatalf("want server copy of c1 as conflict, got %+v", conflicts)
+	}
+	if len(e.created) != 1 || e.created[0].ID != "k1" || e.created[0].Version != 11 {
+		t.Fatalf("only k1 must be written: %+v", e.created)
+	}
+	if len(processed) != 2 || processed[0].ID != "s1" || processed[0].Version != 4 || processed[1].ID != "k1" {
+		t.Fatalf("unexpected processed entries: %+v", processed)
+	}
+	if maxVer != 11 {
+		t.Fatalf("unexpected maxVersion: %d", maxVer)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestSync_TombstonedEntry_TombstonesFile(t *testing.T) {
+	db, mock := newSQLMockDB(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	e := &fakeEntriesRepo{}
+	f := &fakeFilesRepo{}
+	s := newService(t, db, &fakeRepoManager{u: &fakeUsersRepo{}, e: e, f: f})
+
+	pending := []*models.Entry{{ID: "gone", Deleted: true}, {ID: "kept"}}
+	processed, _, _, _, _, _, err := s.Sync(context.Background(), "u", pending, nil, 0)
+	if err != nil {
+		t.Fatalf("Sync error: %v", err)
+	}
+	if len(processed) != 2 || !processed[0].Deleted || !e.created[0].Deleted {
+		t.Fatalf("tombstone not persisted: %+v", e.created)
+	}
+	if len(f.deleted) != 1 || f.deleted[0] != "gone" {
+		t.Fatalf("unexpected file tombstones: %v", f.deleted)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestSync_RecordsRevisionsOfAcceptedEntries(t *testing.T) {
+	db, mock := newSQLMockDB(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	e := &fakeEntriesRepo{current: map[string]*models.Entry{
+		"stale": {ID: "stale", Version: 7, Details: []byte("server")},
+	}}
+	r := &fakeRevisionsRepo{}
+	s := newService(t, db, &fakeRepoManager{u: &fakeUsersRepo{incVer: 7}, e: e, f: &fakeFilesRepo{}, r: r})
+
+	pending := []*models.Entry{{ID: "new"}, {ID: "stale", BaseVersion: 3, Details: []byte("local")}}
+	if _, _, _, _, _, _, err := s.Sync(context.Background(), "u", pending, nil, 0); err != nil {
+		t.Fatalf("Sync error: %v", err)
+	}
+	if len(r.created) != 1 || r.created[0].ID != "new" || r.created[0].Version != 8 || r.created[0].UserID != "u" {
+		t.Fatalf("unexpected revisions: %+v", r.created)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestSync_RevisionErrorRollsBack(t *testing.T) {
+	db, mock := newSQLMockDB(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	r := &fakeRevisionsRepo{err: errors.New("rev-fail")}
+	s := newService(t, db, &fakeRepoManager{u: &fakeUsersRepo{}, e: &fakeEntriesRepo{}, f: &fakeFilesRepo{}, r: r})
+
+	_, _, _, _, _, _, err := s.Sync(context.Background(), "u", []*models.Entry{{ID: "p"}}, nil, 0)
+	if err == nil || !strings.Contains(err.Error(), "rev-fail") {
+		t.Fatalf("want revision error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestListAndGetRevision(t *testing.T) {
+	db, _ := newSQLMockDB(t)
+	defer db.Close()
+
+	rev := &models.Entry{ID: "e", Version: 2}
+	r := &fakeRevisionsRepo{list: []*models.Entry{{ID: "e", Version: 3}, rev}, get: rev}
+	s := newService(t, db, &fakeRepoManager{r: r})
+
+	revs, err := s.ListRevisions(context.Background(), "u", "e")
+	if err != nil || len(revs) != 2 {
+		t.Fatalf("ListRevisions: %v %+v", err, revs)
+	}
+	got, err := s.GetRevision(context.Background(), "u", "e", 2)
+	if err != nil || got != rev {
+		t.Fatalf("GetRevision: %v %+v", err, got)
+	}
+
+	r.err = common.ErrorNotFound
+	if _, err := s.GetRevision(context.Background(), "u", "e", 9); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want ErrorNotFound, got %v", err)
+	}
+}
+
+func TestSync_NothingPushed_ReportsPulledVersion(t *testing.T) {
+	db, mock := newSQLMockDB(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	e := &fakeEntriesRepo{selUpdated: []*models.Entry{{ID: "o1", Version: 9}}}
+	f := &fakeFilesRepo{selUpdated: []*models.File{{EntryID: "o1", Version: 9}}}
+	s := newService(t, db, &fakeRepoManager{u: &fakeUsersRepo{}, e: e, f: f})
+
+	_, _, _, _, _, maxVer, err := s.Sync(context.Background(), "u", nil, nil, 5)
+	if err != nil {
+		t.Fatalf("Sync error: %v", err)
+	}
+	if maxVer != 9 {
+		t.Fatalf("want maxVersion 9, got %d", maxVer)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	s2 := newService(t, db, &fakeRepoManager{u: &fakeUsersRepo{}, e: &fakeEntriesRepo{}, f: &fakeFilesRepo{}})
+	_, _, _, _, _, maxVer, err = s2.Sync(context.Background(), "u", nil, nil, 5)
+	if err != nil {
+		t.Fatalf("Sync error: %v", err)
+	}
+	if maxVer != 5 {
+		t.Fatalf("want client maxVersion kept, got %d", maxVer)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
 func TestSync_ErrorsBeforeTx(t *testing.T) {
 	db, _ := newSQLMockDB(t)
 	defer db.Close()
@@ -187,7 +583,7 @@ func TestSync_ErrorsBeforeTx(t *testing.T) {
 	e1 := &fakeEntriesRepo{selErr: errBoom{}}
 	m1 := &fakeRepoManager{u: &fakeUsersRepo{}, e: e1, f: &fakeFilesRepo{}}
 	s1 := newService(t, db, m1)
-	_, _, _, _, _, err := s1.Sync(context.Background(), "u", nil, nil, 0)
+	_, _, _, _, _, _, err := s1.Sync(context.Background(), "u", nil, nil, 0)
 	if err == nil || !strings.Contains(err.Error(), "boom") {
 		t.Fatalf("want entries select error, got %v", err)
 	}
@@ -196,7 +592,7 @@ func TestSync_ErrorsBeforeTx(t *testing.T) {
 	f2 := &fakeFilesRepo{selErr: errBoom{}}
 	m2 := &fakeRepoManager{u: &fakeUsersRepo{}, e: e2, f: f2}
 	s2 := newService(t, db, m2)
-	_, _, _, _, _, err = s2.Sync(context.Background(), "u", nil, nil, 0)
+	_, _, _, _, _, _, err = s2.Sync(context.Background(), "u", nil, nil, 0)
 	if err == nil || !strings.Contains(err.Error(), "boom") {
 		t.Fatalf("want files select error, got %v", err)
 	}
@@ -214,7 +610,7 @@ func TestSync_ErrorsInsideTx(t *testing.T) {
 	m := &fakeRepoManager{u: u, e: e, f: &fakeFilesRepo{}}
 	s := newService(t, db, m)
 
-	_, _, _, _, _, err := s.Sync(context.Background(), "u", []*models.Entry{{ID: "p1"}}, nil, 0)
+	_, _, _, _, _, _, err := s.Sync(context.Background(), "u", []*models.Entry{{ID: "p1"}}, nil, 0)
 	if err == nil || !strings.Contains(err.Error(), "error creating entries:") {
 		t.Fatalf("want wrapped tx error, got %v", err)
 	}
@@ -227,7 +623,7 @@ func TestSync_ErrorsInsideTx(t *testing.T) {
 	m2 := &fakeRepoManager{u: u2, e: e2, f: &fakeFilesRepo{}}
 	s2 := newService(t, db, m2)
 
-	_, _, _, _, _, err = s2.Sync(context.Background(), "u", []*models.Entry{{ID: "p1"}}, nil, 0)
+	_, _, _, _, _, _, err = s2.Sync(context.Background(), "u", []*models.Entry{{ID: "p1"}}, nil, 0)
 	if err == nil || !strings.Contains(err.Error(), "error creating entries:") {
 		t.Fatalf("want wrapped tx error, got %v", err)
 	}
@@ -241,20 +637,72 @@ func TestMarkUploaded_OKAndError(t *testing.T) {
 	db, _ := newSQLMockDB(t)
 	defer db.Close()
 
-	okFiles := &fakeFilesRepo{}
-	errFiles := &fakeFilesRepo{markErr: errBoom{}}
+	owned := &models.File{EntryID: "e1", UserID: "u1"}
+	okFiles := &fakeFilesRepo{getByID: owned}
+	errFiles := &fakeFilesRepo{getByID: owned, markErr: errBoom{}}
 
 	s1 := newService(t, db, &fakeRepoManager{u: &fakeUsersRepo{}, e: &fakeEntriesRepo{}, f: okFiles})
-	if err := s1.MarkUploaded(context.Background(), "e1"); err != nil {
+	if err := s1.MarkUploaded(context.Background(), "u1", "e1"); err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
 
 	s2 := newService(t, db, &fakeRepoManager{u: &fakeUsersRepo{}, e: &fakeEntriesRepo{}, f: errFiles})
-	if err := s2.MarkUploaded(context.Background(), "e1"); err == nil || !strings.Contains(err.Error(), "error updating file:") {
+	if err := s2.MarkUploaded(context.Background(), "u1", "e1"); err == nil || !strings.Contains(err.Error(), "error updating file:") {
 		t.Fatalf("want wrapped error, got %v", err)
 	}
 }
 
+func TestMarkUploaded_RequiresStoredObject(t *testing.T) {
+	db, _ := newSQLMockDB(t)
+	defer db.Close()
+
+	f := &fakeFilesRepo{getByID: &models.File{EntryID: "e1", UserID: "u1", StorageKey: "k1"}}
+	bs := &fakeBlobStore{missing: map[string]bool{"k1": true}}
+	s := NewEntryService(db, &fakeRepoManager{u: &fakeUsersRepo{}, e: &fakeEntriesRepo{}, f: f}, bs)
+
+	if err := s.MarkUploaded(context.Background(), "u1", "e1"); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want ErrorNotFound, got %v", err)
+	}
+}
+
+func TestMarkUploaded_RejectsForeignEntry(t *testing.T) {
+	db, _ := newSQLMockDB(t)
+	defer db.Close()
+
+	filesRepo := &fakeFilesRepo{getByID: &models.File{EntryID: "e1", UserID: "owner"}}
+	s := newService(t, db, &fakeRepoManager{u: &fakeUsersRepo{}, e: &fakeEntriesRepo{}, f: filesRepo})
+
+	if err := s.MarkUploaded(context.Background(), "intruder", "e1"); !errors.Is(err, common.ErrorForbidden) {
+		t.Fatalf("want ErrorForbidden, got %v", err)
+	}
+}
+
+func TestUpdateFileKey_BumpsVersionAndChecksOwner(t *testing.T) {
+	db, mock := newSQLMockDB(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	u := &fakeUsersRepo{incVer: 4}
+	f := &fakeFilesRepo{getByID: &models.File{EntryID: "e1", UserID: "u1"}}
+	s := newService(t, db, &fakeRepoManager{u: u, e: &fakeEntriesRepo{}, f: f})
+
+	if err := s.UpdateFileKey(context.Background(), "u1", "e1", []byte("wrapped")); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if string(f.updatedKeys["e1"]) != "wrapped" || u.incVer != 5 {
+		t.Fatalf("key not updated under a new version: %q, v%d", f.updatedKeys["e1"], u.incVer)
+	}
+
+	if err := s.UpdateFileKey(context.Background(), "intruder", "e1", []byte("x")); !errors.Is(err, common.ErrorForbidden) {
+		t.Fatalf("want ErrorForbidden, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
 func TestGetPresignedGetURL_ErrOnGetByEntryID(t *testing.T) {
 	db, _ := newSQLMockDB(t)
 	defer db.Close()
@@ -262,12 +710,70 @@ func TestGetPresignedGetURL_ErrOnGetByEntryID(t *testing.T) {
 	filesRepo := &fakeFilesRepo{getErr: errBoom{}}
 	s := newService(t, db, &fakeRepoManager{u: &fakeUsersRepo{}, e: &fakeEntriesRepo{}, f: filesRepo})
 
-	_, err := s.GetPresignedGetURL(context.Background(), "e1")
+	_, err := s.GetPresignedGetURL(context.Background(), "u1", "e1")
 	if err == nil || !strings.Contains(err.Error(), "error getting file:") {
 		t.Fatalf("want wrapped error, got %v", err)
 	}
 }
 
+func TestGetPresignedGetURL_OwnershipErrors(t *testing.T) {
+	db, _ := newSQLMockDB(t)
+	defer db.Close()
+
+	notFound := &fakeFilesRepo{getErr: common.ErrorNotFound}
+	s1 := newService(t, db, &fakeRepoManager{u: &fakeUsersRepo{}, e: &fakeEntriesRepo{}, f: notFound})
+	if _, err := s1.GetPresignedGetURL(context.Background(), "u1", "e1"); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want ErrorNotFound, got %v", err)
+	}
+
+	foreign := &fakeFilesRepo{getByID: &models.File{EntryID: "e1", UserID: "owner", StorageKey: "k"}}
+	s2 := newService(t, db, &fakeRepoManager{u: &fakeUsersRepo{}, e: &fakeEntriesRepo{}, f: foreign})
+	if _, err := s2.GetPresignedGetURL(context.Background(), "intruder", "e1"); !errors.Is(err, common.ErrorForbidden) {
+		t.Fatalf("want ErrorForbidden, got %v", err)
+	}
+}
+
+func TestRequestUploadURL_KeepsStorageKey(t *testing.T) {
+	db, _ := newSQLMockDB(t)
+	defer db.Close()
+
+	f := &fakeFilesRepo{getByID: &models.File{EntryID: "e1", UserID: "u1", StorageKey: "k1", UploadStatus: "pending"}}
+	s := newService(t, db, &fakeRepoManager{u: &fakeUsersRepo{}, e: &fakeEntriesRepo{}, f: f})
+
+	url, err := s.RequestUploadURL(context.Background(), "u1", "e1")
+	if err != nil || url != "https://blobs/put/k1" {
+		t.Fatalf("got %q, %v", url, err)
+	}
+	if _, err := s.RequestUploadURL(context.Background(), "u2", "e1"); !errors.Is(err, common.ErrorForbidden) {
+		t.Fatalf("want ErrorForbidden, got %v", err)
+	}
+
+	// an uploaded file needs no upload URL
+	f.getByID.UploadStatus = "completed"
+	if _, err := s.RequestUploadURL(context.Background(), "u1", "e1"); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want ErrorNotFound, got %v", err)
+	}
+}
+
+func TestStaleUploads(t *testing.T) {
+	db, _ := newSQLMockDB(t)
+	defer db.Close()
+
+	before := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	f := &fakeFilesRepo{stale: []*models.File{{EntryID: "e1", UserID: "u1", StorageKey: "k1"}}}
+	s := newService(t, db, &fakeRepoManager{u: &fakeUsersRepo{}, e: &fakeEntriesRepo{}, f: f})
+
+	got, err := s.StaleUploads(context.Background(), before)
+	if err != nil || len(got) != 1 || got[0].StorageKey != "k1" || !f.staleBefore.Equal(before) {
+		t.Fatalf("got %+v, %v", got, err)
+	}
+
+	f.selErr = errBoom{}
+	if _, err := s.StaleUploads(context.Background(), before); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
 func TestGetRandomStorageKey_Format(t *testing.T) {
 	k := GetRandomStorageKey()
 	// users/YYYY/M/D/UUID
@@ -277,6 +783,334 @@ func TestGetRandomStorageKey_Format(t *testing.T) {
 	}
 }
 
+func TestSync_ResyncRequiredAfterPurge(t *testing.T) {
+	db, mock := newSQLMockDB(t)
+	defer db.Close()
+
+	u := &fakeUsersRepo{purgedVersion: 10}
+	s := newService(t, db, &fakeRepoManager{u: u, e: &fakeEntriesRepo{}, f: &fakeFilesRepo{}})
+
+	_, _, _, _, _, _, err := s.Sync(context.Background(), "u", nil, nil, 9)
+	if !errors.Is(err, common.ErrResyncRequired) {
+		t.Fatalf("want ErrResyncRequired, got %v", err)
+	}
+
+	// clients that saw the last purged tombstone, or start from scratch, are fine
+	for _, v := range []int64{10, 0} {
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+		if _, _, _, _, _, _, err := s.Sync(context.Background(), "u", nil, nil, v); err != nil {
+			t.Fatalf("Sync(%d) error: %v", v, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestSync_UndeletedEntryRevivesFile(t *testing.T) {
+	db, mock := newSQLMockDB(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	e := &fakeEntriesRepo{current: map[string]*models.Entry{
+		"gone": {ID: "gone", Version: 3, Deleted: true},
+		"live": {ID: "live", Version: 4},
+	}}
+	f := &fakeFilesRepo{}
+	s := newService(t, db, &fakeRepoManager{u: &fakeUsersRepo{incVer: 4}, e: e, f: f})
+
+	pending := []*models.Entry{
+		{ID: "gone", BaseVersion: 3},
+		{ID: "live", BaseVersion: 4},
+	}
+	if _, _, _, _, _, _, err := s.Sync(context.Background(), "u", pending, nil, 4); err != nil {
+		t.Fatalf("Sync error: %v", err)
+	}
+	if len(f.restored) != 1 || f.restored[0] != "gone" {
+		t.Fatalf("want only the undeleted entry's file restored, got %v", f.restored)
+	}
+}
+
+func TestPurgeTombstones_RemovesRowsAndObjects(t *testing.T) {
+	db, mock := newSQLMockDB(t)
+	defer db.Close()
+
+	u := &fakeUsersRepo{}
+	e := &fakeEntriesRepo{
+		purgeable: []*models.Entry{
+			{ID: "a", UserID: "u1", Version: 3},
+			{ID: "b", UserID: "u1", Version: 7},
+			{ID: "back", UserID: "u2", Version: 2},
+			{ID: "c", UserID: "u2", Version: 5},
+		},
+		restored: map[string]bool{"back": true},
+	}
+	f := &fakeFilesRepo{keys: map[string]string{"a": "k-a", "c": "k-bad"}}
+	bs := &fakeBlobStore{deleteErr: map[string]error{"k-bad": errBoom{}}}
+	s := NewEntryService(db, &fakeRepoManager{u: u, e: e, f: f}, bs)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	n, err := s.PurgeTombstones(context.Background(), time.Now())
+	if n != 3 {
+		t.Fatalf("want 3 purged, got %d", n)
+	}
+	if err == nil || !strings.Contains(err.Error(), "k-bad") {
+		t.Fatalf("want object deletion error, got %v", err)
+	}
+	if strings.Join(e.purged, ",") != "a,b,c" {
+		t.Fatalf("unexpected purged entries: %v", e.purged)
+	}
+	if strings.Join(bs.deleted, ",") != "k-a,k-bad" {
+		t.Fatalf("unexpected deleted objects: %v", bs.deleted)
+	}
+	if u.raised["u1"] != 7 || u.raised["u2"] != 5 {
+		t.Fatalf("unexpected purged versions: %v", u.raised)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestSync_ReusesStorageKeyOfPendingUpload(t *testing.T) {
+	db, mock := newSQLMockDB(t)
+	defer db.Close()
+
+	f := &fakeFilesRepo{getByID: &models.File{EntryID: "e1", UserID: "u1", StorageKey: "k-old", Nonce: []byte("n1"), UploadStatus: "pending"}}
+	e := &fakeEntriesRepo{current: map[string]*models.Entry{"e1": {ID: "e1", UserID: "u1"}}}
+	s := newService(t, db, &fakeRepoManager{u: &fakeUsersRepo{}, e: e, f: f})
+
+	for _, tc := range []struct {
+		nonce  string
+		reused bool
+	}{
+		{"n1", true},
+		{"n2", false}, // the attachment was replaced
+	} {
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+		_, _, _, _, tasks, _, err := s.Sync(context.Background(), "u1", nil, []*models.File{{EntryID: "e1", Nonce: []byte(tc.nonce)}}, 0)
+		if err != nil {
+			t.Fatalf("Sync error: %v", err)
+		}
+		key := f.upserted[len(f.upserted)-1].StorageKey
+		if (key == "k-old") != tc.reused || tasks[0].URL != "https://blobs/put/"+key {
+			t.Fatalf("nonce %s: storage key %q, task %+v", tc.nonce, key, tasks[0])
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestSync_RejectsFileOfForeignEntry(t *testing.T) {
+	db, mock := newSQLMockDB(t)
+	defer db.Close()
+
+	// e1 belongs to another user: the entries repo does not find it for u2
+	f := &fakeFilesRepo{getByID: &models.File{EntryID: "e1", UserID: "u1", StorageKey: "k-old", Nonce: []byte("n1"), UploadStatus: "pending"}}
+	s := newService(t, db, &fakeRepoManager{u: &fakeUsersRepo{}, e: &fakeEntriesRepo{}, f: f})
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	_, _, _, _, tasks, _, err := s.Sync(context.Background(), "u2", nil, []*models.File{{EntryID: "e1", Nonce: []byte("n1")}}, 0)
+	if err == nil || !strings.Contains(err.Error(), common.ErrorForbidden.Error()) {
+		t.Fatalf("want forbidden, got %v", err)
+	}
+	if len(f.upserted) != 0 || tasks != nil {
+		t.Fatalf("foreign file row written: %+v, tasks %+v", f.upserted, tasks)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestStartMultipartUpload(t *testing.T) {
+	db, _ := newSQLMockDB(t)
+	defer db.Close()
+
+	file := &models.File{EntryID: "e1", UserID: "u1", StorageKey: "k1", UploadStatus: "pending"}
+	f := &fakeFilesRepo{getByID: file}
+	bs := &fakeBlobStore{}
+	s := NewEntryService(db, &fakeRepoManager{u: &fakeUsersRepo{}, e: &fakeEntriesRepo{}, f: f}, bs)
+
+	id, err := s.StartMultipartUpload(context.Background(), "u1", "e1")
+	if err != nil || id != "up1" || f.uploadIDs["e1"] != "up1" {
+		t.Fatalf("start: %q, %v, stored %v", id, err, f.uploadIDs)
+	}
+
+	// a restarted client resumes the upload in progress
+	file.UploadID = "up1"
+	if id, err := s.StartMultipartUpload(context.Background(), "u1", "e1"); err != nil || id != "up1" || bs.created != 1 {
+		t.Fatalf("resume: %q, %v, %d uploads created", id, err, bs.created)
+	}
+
+	if _, err := s.StartMultipartUpload(context.Background(), "intruder", "e1"); !errors.Is(err, common.ErrorForbidden) {
+		t.Fatalf("want ErrorForbidden, got %v", err)
+	}
+	file.UploadStatus = "completed"
+	if _, err := s.StartMultipartUpload(context.Background(), "u1", "e1"); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want ErrorNotFound for an uploaded file, got %v", err)
+	}
+}
+
+func TestStartMultipartUpload_AbortsWhenFileReplaced(t *testing.T) {
+	db, _ := newSQLMockDB(t)
+	defer db.Close()
+
+	f := &fakeFilesRepo{
+		getByID:     &models.File{EntryID: "e1", UserID: "u1", StorageKey: "k1", UploadStatus: "pending"},
+		uploadIDErr: common.ErrorNotFound,
+	}
+	bs := &fakeBlobStore{}
+	s := NewEntryService(db, &fakeRepoManager{u: &fakeUsersRepo{}, e: &fakeEntriesRepo{}, f: f}, bs)
+
+	if _, err := s.StartMultipartUpload(context.Background(), "u1", "e1"); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want ErrorNotFound, got %v", err)
+	}
+	if strings.Join(bs.aborted, ",") != "up1" {
+		t.Fatalf("orphaned upload not aborted: %v", bs.aborted)
+	}
+}
+
+func TestMultipartUpload_PresignCompleteAbort(t *testing.T) {
+	db, _ := newSQLMockDB(t)
+	defer db.Close()
+
+	file := &models.File{EntryID: "e1", UserID: "u1", StorageKey: "k1", UploadStatus: "pending", UploadID: "up1"}
+	f := &fakeFilesRepo{getByID: file}
+	bs := &fakeBlobStore{}
+	s := NewEntryService(db, &fakeRepoManager{u: &fakeUsersRepo{}, e: &fakeEntriesRepo{}, f: f}, bs)
+	ctx := context.Background()
+
+	urls, err := s.PresignUploadParts(ctx, "u1", "e1", "up1", []int32{3, 1})
+	if err != nil || strings.Join(urls, ",") != "https://blobs/k1/up1/3,https://blobs/k1/up1/1" {
+		t.Fatalf("presign: %v, %v", urls, err)
+	}
+
+	// a stale or foreign upload id is rejected
+	if _, err := s.PresignUploadParts(ctx, "u1", "e1", "other", []int32{1}); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want ErrorNotFound, got %v", err)
+	}
+	if err := s.CompleteMultipartUpload(ctx, "intruder", "e1", "up1", nil); !errors.Is(err, common.ErrorForbidden) {
+		t.Fatalf("want ErrorForbidden, got %v", err)
+	}
+
+	parts := []models.UploadedPart{{PartNumber: 2, ETag: "b"}, {PartNumber: 1, ETag: "a"}}
+	if err := s.CompleteMultipartUpload(ctx, "u1", "e1", "up1", parts); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if len(bs.completed) != 2 || bs.completed[0].PartNumber != 2 || bs.completed[1].ETag != "a" {
+		t.Fatalf("parts not passed to the store: %+v", bs.completed)
+	}
+	if id, ok := f.uploadIDs["e1"]; !ok || id != "" {
+		t.Fatalf("upload id not cleared: %v", f.uploadIDs)
+	}
+
+	f.uploadIDs = nil
+	if err := s.AbortMultipartUpload(ctx, "u1", "e1", "up1"); err != nil {
+		t.Fatalf("abort: %v", err)
+	}
+	if id, ok := f.uploadIDs["e1"]; strings.Join(bs.aborted, ",") != "up1" || !ok || id != "" {
+		t.Fatalf("upload not aborted: %v, %v", bs.aborted, f.uploadIDs)
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	now := time.Now()
+	before := now.Add(-time.Hour)
+	old, recent := before.Add(-time.Hour), before.Add(time.Minute)
+
+	newFixture := func() (*fakeFilesRepo, *fakeBlobStore) {
+		f := &fakeFilesRepo{
+			byKey: map[string]*models.File{
+				"users/live":     {EntryID: "e1", StorageKey: "users/live", UpdatedAt: old},
+				"users/tomb":     {EntryID: "e2", StorageKey: "users/tomb", Deleted: true, UpdatedAt: old},
+				"users/deleted":  {EntryID: "e3", StorageKey: "users/deleted", Deleted: true, UpdatedAt: recent},
+				"users/restored": {EntryID: "e4", StorageKey: "users/restored", Deleted: true, UpdatedAt: old},
+			},
+			restoredKeys: map[string]bool{"users/restored": true},
+		}
+		bs := &fakeBlobStore{objects: []blobstore.ObjectInfo{
+			{Key: "users/live", Size: 1, ModTime: old},
+			{Key: "users/orphan", Size: 10, ModTime: old},
+			{Key: "users/uploading", Size: 100, ModTime: recent},
+			{Key: "users/tomb", Size: 1000, ModTime: old},
+			{Key: "users/deleted", Size: 1, ModTime: old},
+			{Key: "users/restored", Size: 1, ModTime: old},
+			{Key: "other/orphan", Size: 1, ModTime: old},
+		}}
+		return f, bs
+	}
+	keys := func(objs []blobstore.ObjectInfo) string {
+		var ks []string
+		for _, o := range objs {
+			ks = append(ks, o.Key)
+		}
+		return strings.Join(ks, ",")
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		f, bs := newFixture()
+		s := NewEntryService(nil, &fakeRepoManager{f: f}, bs)
+
+		r, err := s.CollectGarbage(context.Background(), before, true)
+		if err != nil {
+			t.Fatalf("CollectGarbage: %v", err)
+		}
+		if r.Scanned != 6 || keys(r.Unreferenced) != "users/orphan" || keys(r.Tombstoned) != "users/tomb,users/restored" {
+			t.Fatalf("unexpected report %+v", r)
+		}
+		if r.Deleted != 0 || len(bs.deleted) != 0 || len(f.purgedKeys) != 0 {
+			t.Fatalf("dry run deleted %v, purged %v", bs.deleted, f.purgedKeys)
+		}
+		if r.Size() != 1011 {
+			t.Fatalf("want size 1011, got %d", r.Size())
+		}
+	})
+
+	t.Run("deletes garbage", func(t *testing.T) {
+		f, bs := newFixture()
+		bs.deleteErr = map[string]error{"users/orphan": errBoom{}}
+		s := NewEntryService(nil, &fakeRepoManager{f: f}, bs)
+
+		r, err := s.CollectGarbage(context.Background(), before, false)
+		if err == nil || !strings.Contains(err.Error(), "users/orphan") {
+			t.Fatalf("want object deletion error, got %v", err)
+		}
+		// the row of a tombstoned file goes first; a restored file is kept
+		if strings.Join(f.purgedKeys, ",") != "users/tomb" {
+			t.Fatalf("unexpected purged rows: %v", f.purgedKeys)
+		}
+		if strings.Join(bs.deleted, ",") != "users/orphan,users/tomb" || r.Deleted != 1 {
+			t.Fatalf("deleted %v, reported %d", bs.deleted, r.Deleted)
+		}
+	})
+
+	t.Run("lookup error", func(t *testing.T) {
+		f, bs := newFixture()
+		f.selErr = errBoom{}
+		s := NewEntryService(nil, &fakeRepoManager{f: f}, bs)
+
+		if _, err := s.CollectGarbage(context.Background(), before, false); !errors.Is(err, errBoom{}) {
+			t.Fatalf("want boom, got %v", err)
+		}
+		if len(bs.deleted) != 0 {
+			t.Fatalf("deleted %v after failed lookup", bs.deleted)
+		}
+	})
+}
+
 type errBoom struct{}
 
 func (errBoom) Error() string { return "boom" }
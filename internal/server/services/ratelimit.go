@@ -0,0 +1,206 @@
+// Package services contains server-side business logic. This file implements
+// RateLimiter, which throttles the authentication RPCs against password
+// guessing.
+package services
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/dbx"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/config"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/repomanager"
+)
+
+// RateLimiter throttles the authentication RPCs per client IP and per
+// username. Each of them has a token bucket that holds a minute's worth of
+// requests, and a count of failed logins in a row: once it reaches the
+// lockout threshold, the client is locked out for the base lockout, doubled
+// with every further failure up to the maximum. A streak of failures is
+// forgotten once the client has neither failed nor been locked out for the
+// maximum lockout.
+//
+// The state is kept in the database, so all server instances share it.
+type RateLimiter struct {
+	db          *sql.DB
+	repomanager repomanager.RepositoryManager
+	// ipRate and userRate are the requests per minute; 0 disables the
+	// bucket.
+	ipRate   int
+	userRate int
+	// lockoutThreshold is the number of failures that triggers a lockout;
+	// 0 disables lockouts.
+	lockoutThreshold int
+	lockoutBase      time.Duration
+	lockoutMax       time.Duration
+	// now is a seam for tests.
+	now func() time.Time
+}
+
+// rateLimitIdle is how long the state of a client is kept after its last
+// request, see PurgeIdle.
+const rateLimitIdle = 24 * time.Hour
+
+// NewRateLimiter constructs a RateLimiter with the limits of cfg. It returns
+// nil if cfg disables all of them.
+func NewRateLimiter(db *sql.DB, m repomanager.RepositoryManager, cfg *config.Config) *RateLimiter {
+	if cfg.AuthIPRate <= 0 && cfg.AuthUserRate <= 0 && cfg.AuthLockoutThreshold <= 0 {
+		return nil
+	}
+	return &RateLimiter{
+		db:               db,
+		repomanager:      m,
+		ipRate:           max(cfg.AuthIPRate, 0),
+		userRate:         max(cfg.AuthUserRate, 0),
+		lockoutThreshold: max(cfg.AuthLockoutThreshold, 0),
+		lockoutBase:      cfg.AuthLockoutBase,
+		lockoutMax:       max(cfg.AuthLockoutMax, cfg.AuthLockoutBase),
+		now:              time.Now,
+	}
+}
+
+// bucket is the rate limit state of one client together with its rate.
+type bucket struct {
+	key  string
+	rate int
+}
+
+// buckets returns the buckets of ip and username, skipping empty ones.
+func (l *RateLimiter) buckets(ip string, username string) []bucket {
+	var b []bucket
+	if ip != "" {
+		b = append(b, bucket{key: "ip:" + ip, rate: l.ipRate})
+	}
+	if username != "" {
+		b = append(b, bucket{key: "user:" + username, rate: l.userRate})
+	}
+	return b
+}
+
+// Allow takes a request from the buckets of ip and username, either of
+// which may be empty. If one of them is empty or locked out, nothing is
+// taken and Allow returns how long to wait before retrying.
+func (l *RateLimiter) Allow(ctx context.Context, ip string, username string) (time.Duration, error) {
+	now := l.now().UTC()
+	var wait time.Duration
+
+	err := dbx.WithTx(ctx, l.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+		repo := l.repomanager.RateLimits(tx)
+
+		var limits []*models.RateLimit
+		for _, b := range l.buckets(ip, username) {
+			limit, err := repo.Lock(ctx, b.key, float64(b.rate))
+			if err != nil {
+				return err
+			}
+			if limit.LockedUntil.After(now) {
+				wait = max(wait, limit.LockedUntil.Sub(now))
+			}
+			if b.rate > 0 {
+				refill(limit, b.rate, now)
+				if limit.Tokens < 1 {
+					wait = max(wait, time.Duration((1-limit.Tokens)*float64(time.Minute)/float64(b.rate)))
+				}
+				limits = append(limits, limit)
+			}
+		}
+		if wait > 0 {
+			return nil
+		}
+
+		for _, limit := range limits {
+			limit.Tokens--
+			if err := repo.Save(ctx, limit); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	// round up to whole seconds, so that a retry after the wait is allowed
+	if r := wait % time.Second; r > 0 {
+		wait += time.Second - r
+	}
+	return wait, nil
+}
+
+// refill adds the requests earned since the bucket was last refilled, up to
+// a minute's worth.
+func refill(limit *models.RateLimit, rate int, now time.Time) {
+	if elapsed := now.Sub(limit.RefilledAt); elapsed > 0 {
+		limit.Tokens = min(float64(rate), limit.Tokens+elapsed.Minutes()*float64(rate))
+	}
+	limit.RefilledAt = now
+}
+
+// Fail records a failed login from ip for username, either of which may be
+// empty, and locks them out once their failures reach the threshold.
+func (l *RateLimiter) Fail(ctx context.Context, ip string, username string) error {
+	if l.lockoutThreshold == 0 {
+		return nil
+	}
+	now := l.now().UTC()
+
+	return dbx.WithTx(ctx, l.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+		repo := l.repomanager.RateLimits(tx)
+		for _, b := range l.buckets(ip, username) {
+			limit, err := repo.Lock(ctx, b.key, float64(b.rate))
+			if err != nil {
+				return err
+			}
+			if now.Sub(limit.FailedAt) > l.lockoutMax && now.Sub(limit.LockedUntil) > l.lockoutMax {
+				limit.Failures = 0
+			}
+			limit.Failures++
+			limit.FailedAt = now
+			if limit.Failures >= l.lockoutThreshold {
+				limit.LockedUntil = now.Add(l.lockout(limit.Failures - l.lockoutThreshold))
+			}
+			if err := repo.Save(ctx, limit); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// lockout returns the lockout after n failures beyond the threshold.
+func (l *RateLimiter) lockout(n int) time.Duration {
+	d := l.lockoutBase
+	for ; n > 0 && d < l.lockoutMax; n-- {
+		d *= 2
+	}
+	return min(d, l.lockoutMax)
+}
+
+// Succeed forgets the failed logins of username after a successful one.
+// Those of the client IP are kept: an attacker could otherwise reset them by
+// logging into an account of their own.
+func (l *RateLimiter) Succeed(ctx context.Context, username string) error {
+	if l.lockoutThreshold == 0 || username == "" {
+		return nil
+	}
+
+	return dbx.WithTx(ctx, l.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+		repo := l.repomanager.RateLimits(tx)
+		limit, err := repo.Lock(ctx, "user:"+username, float64(l.userRate))
+		if err != nil {
+			return err
+		}
+		if limit.Failures == 0 && limit.LockedUntil.IsZero() {
+			return nil
+		}
+		limit.Failures, limit.FailedAt, limit.LockedUntil = 0, time.Time{}, time.Time{}
+		return repo.Save(ctx, limit)
+	})
+}
+
+// PurgeIdle removes the state of clients that have not made a request for a
+// day and are not locked out, and returns how many were removed.
+func (l *RateLimiter) PurgeIdle(ctx context.Context) (int64, error) {
+	return l.repomanager.RateLimits(l.db).DeleteIdle(ctx, l.now().UTC().Add(-rateLimitIdle))
+}
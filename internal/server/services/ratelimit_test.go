@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/config"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+)
+
+// fakeRateLimitsRepo keeps the rate limit state in memory.
+type fakeRateLimitsRepo struct {
+	limits  map[string]models.RateLimit
+	lockErr error
+	before  time.Time
+}
+
+func (f *fakeRateLimitsRepo) Lock(ctx context.Context, key string, burst float64) (*models.RateLimit, error) {
+	if f.lockErr != nil {
+		return nil, f.lockErr
+	}
+	if f.limits == nil {
+		f.limits = map[string]models.RateLimit{}
+	}
+	l, ok := f.limits[key]
+	if !ok {
+		l = models.RateLimit{Key: key, Tokens: burst}
+	}
+	return &l, nil
+}
+
+func (f *fakeRateLimitsRepo) Save(ctx context.Context, l *models.RateLimit) error {
+	f.limits[l.Key] = *l
+	return nil
+}
+
+func (f *fakeRateLimitsRepo) DeleteIdle(ctx context.Context, before time.Time) (int64, error) {
+	f.before = before
+	return 3, nil
+}
+
+// newTestRateLimiter returns a limiter of 2 requests per minute per IP and
+// per user with lockouts from the third failure, whose clock is *now.
+func newTestRateLimiter(t *testing.T, repo *fakeRateLimitsRepo, now *time.Time) (*RateLimiter, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := newSQLMockDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	l := NewRateLimiter(db, &fakeRepoManager1{rl: repo}, &config.Config{
+		AuthIPRate:           2,
+		AuthUserRate:         2,
+		AuthLockoutThreshold: 3,
+		AuthLockoutBase:      time.Minute,
+		AuthLockoutMax:       3 * time.Minute,
+	})
+	l.now = func() time.Time { return *now }
+	return l, mock
+}
+
+func expectTxs(mock sqlmock.Sqlmock, n int) {
+	for range n {
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+	}
+}
+
+func TestNewRateLimiter_Disabled(t *testing.T) {
+	if l := NewRateLimiter(nil, nil, &config.Config{}); l != nil {
+		t.Fatalf("limiter without limits: %+v", l)
+	}
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	repo := &fakeRateLimitsRepo{}
+	now := time.Date(2025, 10, 29, 12, 0, 0, 0, time.UTC)
+	l, mock := newTestRateLimiter(t, repo, &now)
+	expectTxs(mock, 5)
+	ctx := context.Background()
+
+	for i := range 2 {
+		wait, err := l.Allow(ctx, "10.0.0.1", "alice")
+		if err != nil || wait != 0 {
+			t.Fatalf("request %d: wait %v, err %v", i, wait, err)
+		}
+	}
+	// the bucket is empty and refills a request every 30 seconds
+	now = now.Add(10 * time.Second)
+	wait, err := l.Allow(ctx, "10.0.0.1", "alice")
+	if err != nil || wait != 20*time.Second {
+		t.Fatalf("empty bucket: wait %v, err %v", wait, err)
+	}
+	// another user from the same IP is throttled too
+	if wait, _ := l.Allow(ctx, "10.0.0.1", "bob"); wait != 20*time.Second {
+		t.Fatalf("same IP: wait %v", wait)
+	}
+	now = now.Add(20*time.Second + 500*time.Millisecond)
+	if wait, _ := l.Allow(ctx, "10.0.0.1", "alice"); wait != 0 {
+		t.Fatalf("refilled bucket: wait %v", wait)
+	}
+	if _, ok := repo.limits["user:bob"]; ok {
+		t.Fatalf("throttled request took a token from bob")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestRateLimiter_Allow_RoundsUp(t *testing.T) {
+	now := time.Date(2025, 10, 29, 12, 0, 0, 0, time.UTC)
+	repo := &fakeRateLimitsRepo{limits: map[string]models.RateLimit{
+		"ip:10.0.0.1": {Key: "ip:10.0.0.1", Tokens: 0.5, RefilledAt: now},
+	}}
+	l, mock := newTestRateLimiter(t, repo, &now)
+	expectTxs(mock, 1)
+	l.ipRate = 7
+
+	// half a request at 7 per minute is 4.29 seconds
+	if wait, _ := l.Allow(context.Background(), "10.0.0.1", ""); wait != 5*time.Second {
+		t.Fatalf("wait %v, want 5s", wait)
+	}
+}
+
+func TestRateLimiter_Allow_Error(t *testing.T) {
+	repo := &fakeRateLimitsRepo{lockErr: errBoom{}}
+	now := time.Now()
+	l, mock := newTestRateLimiter(t, repo, &now)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	if _, err := l.Allow(context.Background(), "10.0.0.1", "alice"); !errors.Is(err, errBoom{}) {
+		t.Fatalf("want errBoom, got %v", err)
+	}
+}
+
+func TestRateLimiter_Lockout(t *testing.T) {
+	repo := &fakeRateLimitsRepo{}
+	now := time.Date(2025, 10, 29, 12, 0, 0, 0, time.UTC)
+	l, mock := newTestRateLimiter(t, repo, &now)
+	l.ipRate, l.userRate = 0, 0
+	expectTxs(mock, 11)
+	ctx := context.Background()
+
+	for range 2 {
+		if err := l.Fail(ctx, "10.0.0.1", "alice"); err != nil {
+			t.Fatalf("Fail: %v", err)
+		}
+	}
+	if wait, _ := l.Allow(ctx, "10.0.0.1", "alice"); wait != 0 {
+		t.Fatalf("locked out below the threshold: %v", wait)
+	}
+
+	// the base lockout, doubled with every further failure up to the maximum
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		_ = l.Fail(ctx, "10.0.0.1", "alice")
+		if wait, _ := l.Allow(ctx, "", "alice"); wait != want {
+			t.Fatalf("lockout %v, want %v", wait, want)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestRateLimiter_SucceedAndDecay(t *testing.T) {
+	repo := &fakeRateLimitsRepo{}
+	now := time.Date(2025, 10, 29, 12, 0, 0, 0, time.UTC)
+	l, mock := newTestRateLimiter(t, repo, &now)
+	expectTxs(mock, 5)
+	ctx := context.Background()
+
+	for range 3 {
+		_ = l.Fail(ctx, "10.0.0.1", "alice")
+	}
+	if err := l.Succeed(ctx, "alice"); err != nil {
+		t.Fatalf("Succeed: %v", err)
+	}
+	if u := repo.limits["user:alice"]; u.Failures != 0 || !u.LockedUntil.IsZero() {
+		t.Fatalf("user state not reset: %+v", u)
+	}
+	// the IP keeps its failures
+	if ip := repo.limits["ip:10.0.0.1"]; ip.Failures != 3 || ip.LockedUntil.IsZero() {
+		t.Fatalf("IP state reset: %+v", ip)
+	}
+
+	// a streak is forgotten after the maximum lockout without failures
+	now = now.Add(4*time.Minute + time.Second)
+	_ = l.Fail(ctx, "10.0.0.1", "")
+	if ip := repo.limits["ip:10.0.0.1"]; ip.Failures != 1 {
+		t.Fatalf("failures after decay: %d", ip.Failures)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestRateLimiter_PurgeIdle(t *testing.T) {
+	repo := &fakeRateLimitsRepo{}
+	now := time.Date(2025, 10, 29, 12, 0, 0, 0, time.UTC)
+	l, _ := newTestRateLimiter(t, repo, &now)
+
+	n, err := l.PurgeIdle(context.Background())
+	if err != nil || n != 3 {
+		t.Fatalf("PurgeIdle: %d, %v", n, err)
+	}
+	if !repo.before.Equal(now.Add(-rateLimitIdle)) {
+		t.Fatalf("purged before %v", repo.before)
+	}
+}
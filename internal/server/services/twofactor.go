@@ -0,0 +1,238 @@
+// Package services contains server-side business logic. This file implements
+// the two-factor authentication of UserService: TOTP enrollment and the
+// second step of logging in with a TOTP or recovery code.
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
+	"github.com/dmitrijs2005/gophkeeper/internal/dbx"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+	"github.com/google/uuid"
+)
+
+// twoFactorIssuer names the accounts in authenticator apps.
+const twoFactorIssuer = "GophKeeper"
+
+// twoFactorChallengeValidity bounds the time between LoginFinish and
+// Verify2FA.
+const twoFactorChallengeValidity = 5 * time.Minute
+
+// twoFactorMaxAttempts is how many codes a challenge can be answered with.
+const twoFactorMaxAttempts = 5
+
+// recoveryCodeCount is the number of recovery codes issued on enrollment.
+const recoveryCodeCount = 10
+
+// TwoFactorSetup is the server's answer to Enable2FA.
+type TwoFactorSetup struct {
+	// Secret is the TOTP secret in base32, for manual entry.
+	Secret string
+	// URI is the otpauth URI of the secret, for authenticator apps.
+	URI string
+}
+
+// Enable2FA starts a TOTP enrollment of the caller's account and returns the
+// new secret. It takes effect once confirmed with Confirm2FA; until then a
+// second factor enrolled before keeps working, and a new Enable2FA replaces
+// the enrollment.
+//
+// An account that already has a second factor can only replace it with a
+// current TOTP or recovery code of it, which is used up like in Verify2FA,
+// so that a stolen access token cannot take the second factor over. It
+// returns common.ErrSecondFactorRequired if code is empty and a *LoginError
+// if it is wrong.
+func (s *UserService) Enable2FA(ctx context.Context, userID string, code string) (*TwoFactorSetup, error) {
+	secret := cryptox.NewTOTPSecret()
+	var user *models.User
+	if err := dbx.WithTx(ctx, s.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+		repo := s.repomanager.Users(tx)
+		// Storing the secret first locks the user row, so that the second
+		// factor read below cannot be confirmed concurrently.
+		err := repo.SetPendingTOTPSecret(ctx, userID, secret)
+		if err == nil {
+			user, err = repo.GetUserByID(ctx, userID)
+		}
+		if err != nil {
+			if errors.Is(err, common.ErrorNotFound) {
+				return common.ErrorUnauthorized
+			}
+			return common.ErrorInternal
+		}
+		if user.TOTPSecret == nil {
+			return nil
+		}
+		if strings.TrimSpace(code) == "" {
+			return common.ErrSecondFactorRequired
+		}
+		return s.useSecondFactor(ctx, tx, user, code)
+	}); err != nil {
+		return nil, err
+	}
+	return &TwoFactorSetup{
+		Secret: cryptox.EncodeTOTPSecret(secret),
+		URI:    cryptox.TOTPURI(twoFactorIssuer, user.UserName, secret),
+	}, nil
+}
+
+// Confirm2FA completes the enrollment started by Enable2FA with a code of
+// the new secret, which from then on is required to log in, and returns new
+// one-time recovery codes that replace any issued before. Only their hashes
+// are stored. Enrolling clears the pending secret, so an account with a
+// second factor only has one to confirm if Enable2FA was given a current
+// code of it.
+//
+// It returns common.ErrorForbidden if there is no enrollment to confirm and
+// common.ErrorUnauthorized for a wrong code.
+func (s *UserService) Confirm2FA(ctx context.Context, userID string, code string) ([]string, error) {
+	user, err := s.repomanager.Users(s.db).GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, common.ErrorNotFound) {
+			return nil, common.ErrorUnauthorized
+		}
+		return nil, common.ErrorInternal
+	}
+	if user.TOTPPendingSecret == nil {
+		return nil, common.ErrorForbidden
+	}
+	step, ok := cryptox.VerifyTOTP(user.TOTPPendingSecret, code, time.Now())
+	if !ok {
+		return nil, common.ErrorUnauthorized
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([][]byte, recoveryCodeCount)
+	for i := range codes {
+		codes[i] = cryptox.NewRecoveryCode()
+		hashes[i] = cryptox.HashRecoveryCode(codes[i])
+	}
+
+	if err := dbx.WithTx(ctx, s.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+		if err := s.repomanager.Users(tx).EnableTOTP(ctx, user.ID, user.TOTPPendingSecret, step); err != nil {
+			if errors.Is(err, common.ErrorNotFound) {
+				// replaced by a newer Enable2FA meanwhile
+				return common.ErrorForbidden
+			}
+			return common.ErrorInternal
+		}
+		if err := s.repomanager.TwoFactor(tx).ReplaceRecoveryCodes(ctx, user.ID, hashes); err != nil {
+			return common.ErrorInternal
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify2FA completes a login of an account with two-factor authentication:
+// given a challenge returned by LoginFinish and a current TOTP code or an
+// unused recovery code, it starts a session of device and returns a new
+// TokenPair and the account's wrapped vault key. A challenge can be answered
+// once, with at most twoFactorMaxAttempts codes.
+//
+// It returns common.ErrorUnauthorized for unknown, expired or exhausted
+// challenges, and a *LoginError for a wrong, reused or replayed code.
+func (s *UserService) Verify2FA(ctx context.Context, challengeID string, code string, device models.Device) (*LoginResult, error) {
+	if _, err := uuid.Parse(challengeID); err != nil {
+		return nil, common.ErrorUnauthorized
+	}
+	// The attempt is counted outside of the transaction below, so that a
+	// wrong code does not roll it back.
+	challenge, err := s.repomanager.TwoFactor(s.db).AttemptChallenge(ctx, challengeID, twoFactorMaxAttempts)
+	if err != nil {
+		if errors.Is(err, common.ErrorNotFound) {
+			return nil, common.ErrorUnauthorized
+		}
+		return nil, common.ErrorInternal
+	}
+	user, err := s.repomanager.Users(s.db).GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		if errors.Is(err, common.ErrorNotFound) {
+			return nil, common.ErrorUnauthorized
+		}
+		return nil, common.ErrorInternal
+	}
+	if user.TOTPSecret == nil {
+		return nil, common.ErrorUnauthorized
+	}
+
+	var pair *TokenPair
+	if err := dbx.WithTx(ctx, s.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+		if err := s.useSecondFactor(ctx, tx, user, code); err != nil {
+			return err
+		}
+		if err := s.repomanager.TwoFactor(tx).DeleteChallenge(ctx, challenge.ID); err != nil {
+			if errors.Is(err, common.ErrorNotFound) {
+				// answered concurrently
+				return common.ErrorUnauthorized
+			}
+			return common.ErrorInternal
+		}
+		var genErr error
+		pair, genErr = s.generateTokenPair(ctx, user.ID, device, tx)
+		return genErr
+	}); err != nil {
+		return nil, err
+	}
+	return &LoginResult{Tokens: pair, WrappedVaultKey: user.WrappedVaultKey, UserName: user.UserName}, nil
+}
+
+// createTwoFactorChallenge stores a challenge of userID for Verify2FA and
+// returns its ID.
+func (s *UserService) createTwoFactorChallenge(ctx context.Context, userID string) (string, error) {
+	repo := s.repomanager.TwoFactor(s.db)
+	// A password login that never reaches Verify2FA leaves its challenge
+	// behind, so each new challenge prunes the expired ones. The error is
+	// ignored: AttemptChallenge skips expired rows anyway.
+	_ = repo.DeleteExpiredChallenges(ctx)
+	id, err := repo.CreateChallenge(ctx, userID, twoFactorChallengeValidity)
+	if err != nil {
+		return "", common.ErrorInternal
+	}
+	return id, nil
+}
+
+// useSecondFactor checks code against the TOTP secret of user or, if it is
+// not a TOTP code, its recovery codes, and uses it up: a TOTP code by
+// recording its time step, a recovery code by deleting it. It returns a
+// *LoginError if the code is wrong or was used before.
+func (s *UserService) useSecondFactor(ctx context.Context, tx dbx.DBTX, user *models.User, code string) error {
+	code = strings.TrimSpace(code)
+	var err error
+	if isTOTPCode(code) {
+		step, ok := cryptox.VerifyTOTP(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return &LoginError{UserName: user.UserName}
+		}
+		err = s.repomanager.Users(tx).UseTOTPStep(ctx, user.ID, step)
+	} else {
+		err = s.repomanager.TwoFactor(tx).UseRecoveryCode(ctx, user.ID, cryptox.HashRecoveryCode(code))
+	}
+	if err != nil {
+		if errors.Is(err, common.ErrorNotFound) {
+			return &LoginError{UserName: user.UserName}
+		}
+		return common.ErrorInternal
+	}
+	return nil
+}
+
+// isTOTPCode reports whether code looks like a TOTP code rather than a
+// recovery code.
+func isTOTPCode(code string) bool {
+	if len(code) != 6 {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
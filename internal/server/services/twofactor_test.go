@@ -0,0 +1,268 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
+)
+
+// fakeTwoFactorRepo keeps challenges and recovery code hashes in memory.
+type fakeTwoFactorRepo struct {
+	challenges map[string]*models.TwoFactorChallenge
+	next       int
+	codes      [][]byte
+}
+
+func (f *fakeTwoFactorRepo) CreateChallenge(ctx context.Context, userID string, validity time.Duration) (string, error) {
+	if f.challenges == nil {
+		f.challenges = map[string]*models.TwoFactorChallenge{}
+	}
+	f.next++
+	id := fmt.Sprintf("00000000-0000-0000-0000-%012d", f.next)
+	f.challenges[id] = &models.TwoFactorChallenge{ID: id, UserID: userID, Expires: time.Now().Add(validity)}
+	return id, nil
+}
+
+func (f *fakeTwoFactorRepo) AttemptChallenge(ctx context.Context, id string, maxAttempts int) (*models.TwoFactorChallenge, error) {
+	c, ok := f.challenges[id]
+	if !ok || c.Attempts >= maxAttempts {
+		return nil, common.ErrorNotFound
+	}
+	c.Attempts++
+	return c, nil
+}
+
+func (f *fakeTwoFactorRepo) DeleteChallenge(ctx context.Context, id string) error {
+	if _, ok := f.challenges[id]; !ok {
+		return common.ErrorNotFound
+	}
+	delete(f.challenges, id)
+	return nil
+}
+
+func (f *fakeTwoFactorRepo) DeleteExpiredChallenges(ctx context.Context) error { return nil }
+
+func (f *fakeTwoFactorRepo) ReplaceRecoveryCodes(ctx context.Context, userID string, hashes [][]byte) error {
+	f.codes = hashes
+	return nil
+}
+
+func (f *fakeTwoFactorRepo) UseRecoveryCode(ctx context.Context, userID string, hash []byte) error {
+	for i, h := range f.codes {
+		if bytes.Equal(h, hash) {
+			f.codes = append(f.codes[:i], f.codes[i+1:]...)
+			return nil
+		}
+	}
+	return common.ErrorNotFound
+}
+
+func TestEnableConfirm2FA(t *testing.T) {
+	db, mock := newSQLMockDB(t)
+	defer db.Close()
+
+	user := &models.User{ID: "u1", UserName: "alice"}
+	rm := &fakeRepoManager1{u: &fakeUsersRepo1{getOut: user}}
+	s := newUserService(t, db, rm)
+	ctx := context.Background()
+
+	if _, err := s.Confirm2FA(ctx, "u1", "123456"); !errors.Is(err, common.ErrorForbidden) {
+		t.Fatalf("confirm without enrollment → forbidden, got %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	setup, err := s.Enable2FA(ctx, "u1", "")
+	if err != nil {
+		t.Fatalf("Enable2FA: %v", err)
+	}
+	if setup.Secret != cryptox.EncodeTOTPSecret(user.TOTPPendingSecret) || !strings.HasPrefix(setup.URI, "otpauth://totp/GophKeeper:alice?") {
+		t.Fatalf("setup: %+v", setup)
+	}
+	if user.TOTPSecret != nil {
+		t.Fatalf("2FA enabled before confirmation")
+	}
+
+	secret := user.TOTPPendingSecret
+	code := cryptox.TOTPCode(secret, cryptox.TOTPStep(time.Now()))
+	if _, err := s.Confirm2FA(ctx, "u1", wrongCode(code)); !errors.Is(err, common.ErrorUnauthorized) {
+		t.Fatalf("wrong code → unauthorized, got %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	codes, err := s.Confirm2FA(ctx, "u1", code)
+	if err != nil || len(codes) != recoveryCodeCount {
+		t.Fatalf("Confirm2FA: %v, %v", codes, err)
+	}
+	if !bytes.Equal(user.TOTPSecret, secret) || user.TOTPPendingSecret != nil {
+		t.Fatalf("2FA not enabled: %+v", user)
+	}
+	// only the hashes are stored
+	for i, c := range codes {
+		if !bytes.Equal(rm.tf.codes[i], cryptox.HashRecoveryCode(c)) {
+			t.Fatalf("recovery code %d stored as %x", i, rm.tf.codes[i])
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestEnable2FA_RequiresCurrentSecondFactor(t *testing.T) {
+	db, mock := newSQLMockDB(t)
+	defer db.Close()
+
+	secret := cryptox.NewTOTPSecret()
+	user := &models.User{ID: "u1", UserName: "alice", TOTPSecret: secret}
+	rm := &fakeRepoManager1{
+		u:  &fakeUsersRepo1{getOut: user},
+		tf: &fakeTwoFactorRepo{codes: [][]byte{cryptox.HashRecoveryCode("recovery")}},
+	}
+	s := newUserService(t, db, rm)
+	ctx := context.Background()
+	code := cryptox.TOTPCode(secret, cryptox.TOTPStep(time.Now()))
+
+	// without a current code, or with a wrong one, nothing is enrolled (the
+	// transaction is rolled back) and the recovery codes stay
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	if _, err := s.Enable2FA(ctx, "u1", ""); !errors.Is(err, common.ErrSecondFactorRequired) {
+		t.Fatalf("no code → second factor required, got %v", err)
+	}
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	_, err := s.Enable2FA(ctx, "u1", wrongCode(code))
+	var loginErr *LoginError
+	if !errors.As(err, &loginErr) || loginErr.UserName != "alice" {
+		t.Fatalf("wrong code → login error, got %v", err)
+	}
+
+	// a current TOTP code is used up by the enrollment
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	if _, err := s.Enable2FA(ctx, "u1", code); err != nil {
+		t.Fatalf("Enable2FA with TOTP code: %v", err)
+	}
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	if _, err := s.Enable2FA(ctx, "u1", code); !errors.As(err, &loginErr) {
+		t.Fatalf("replayed code → login error, got %v", err)
+	}
+
+	// so is a recovery code
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	if _, err := s.Enable2FA(ctx, "u1", "recovery"); err != nil {
+		t.Fatalf("Enable2FA with recovery code: %v", err)
+	}
+	if len(rm.tf.codes) != 0 {
+		t.Fatalf("recovery code not used up")
+	}
+	if !bytes.Equal(user.TOTPSecret, secret) {
+		t.Fatalf("second factor replaced before confirmation")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+// wrongCode returns a code that differs from code in the last digit.
+func wrongCode(code string) string {
+	return code[:5] + string(rune('0'+(code[5]-'0'+1)%10))
+}
+
+func TestLoginFinish_TwoFactor(t *testing.T) {
+	db, mock := newSQLMockDB(t)
+	defer db.Close()
+
+	salt := []byte("salt")
+	key := make([]byte, 32)
+	secret := cryptox.NewTOTPSecret()
+	user := &models.User{
+		ID: "u1", UserName: "alice", Salt: salt, SRPVerifier: cryptox.SRPVerifier("alice", salt, key),
+		WrappedVaultKey: []byte("wvk"), TOTPSecret: secret,
+	}
+	rm := &fakeRepoManager1{u: &fakeUsersRepo1{getOut: user}, r: &fakeRefreshRepo{}}
+	s := newUserService(t, db, rm)
+	ctx := context.Background()
+
+	// the password alone yields a challenge instead of tokens and the key
+	c, _ := cryptox.NewSRPClient("alice", salt, key)
+	ch, err := s.LoginStart(ctx, "alice", c.PublicKey())
+	if err != nil {
+		t.Fatalf("LoginStart: %v", err)
+	}
+	m1, _ := c.Proof(ch.ServerPublic)
+	res, err := s.LoginFinish(ctx, ch.SessionID, m1, models.Device{})
+	if err != nil || res.TwoFactorChallenge == "" || res.Tokens != nil || res.WrappedVaultKey != nil {
+		t.Fatalf("LoginFinish: %+v, %v", res, err)
+	}
+	if err := c.VerifyServer(res.ServerProof); err != nil {
+		t.Fatalf("server proof rejected: %v", err)
+	}
+	challenge := res.TwoFactorChallenge
+
+	code := cryptox.TOTPCode(secret, cryptox.TOTPStep(time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	_, err = s.Verify2FA(ctx, challenge, wrongCode(code), models.Device{})
+	var loginErr *LoginError
+	if !errors.As(err, &loginErr) || loginErr.UserName != "alice" {
+		t.Fatalf("wrong code → login error, got %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	res, err = s.Verify2FA(ctx, challenge, code, models.Device{Name: "laptop"})
+	if err != nil || res.Tokens.AccessToken == "" || string(res.WrappedVaultKey) != "wvk" || res.UserName != "alice" {
+		t.Fatalf("Verify2FA: %+v, %v", res, err)
+	}
+
+	// a challenge is answered once
+	if _, err := s.Verify2FA(ctx, challenge, code, models.Device{}); !errors.Is(err, common.ErrorUnauthorized) {
+		t.Fatalf("answered challenge → unauthorized, got %v", err)
+	}
+	if _, err := s.Verify2FA(ctx, "not-a-challenge", code, models.Device{}); !errors.Is(err, common.ErrorUnauthorized) {
+		t.Fatalf("bad challenge id → unauthorized, got %v", err)
+	}
+
+	// a used TOTP code is rejected in a new challenge, a recovery code works once
+	challenge, _ = rm.TwoFactor(db).CreateChallenge(ctx, "u1", time.Minute)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	if _, err := s.Verify2FA(ctx, challenge, code, models.Device{}); !errors.As(err, &loginErr) {
+		t.Fatalf("replayed code → login error, got %v", err)
+	}
+	rm.tf.codes = [][]byte{cryptox.HashRecoveryCode("ABCD-EFGH")}
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	if _, err := s.Verify2FA(ctx, challenge, "abcd-efgh", models.Device{}); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	if len(rm.tf.codes) != 0 {
+		t.Fatalf("recovery code not used up")
+	}
+
+	// attempts per challenge are limited
+	challenge, _ = rm.TwoFactor(db).CreateChallenge(ctx, "u1", time.Minute)
+	for range twoFactorMaxAttempts {
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+		_, _ = s.Verify2FA(ctx, challenge, "ABCD-EFGH", models.Device{})
+	}
+	if _, err := s.Verify2FA(ctx, challenge, "ABCD-EFGH", models.Device{}); !errors.Is(err, common.ErrorUnauthorized) || errors.As(err, &loginErr) {
+		t.Fatalf("exhausted challenge → unauthorized, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -4,6 +4,7 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"crypto/subtle"
 	"database/sql"
@@ -12,11 +13,13 @@ import (
 	"time"
 
 	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
 	"github.com/dmitrijs2005/gophkeeper/internal/dbx"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/auth"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/config"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/repomanager"
+	"github.com/google/uuid"
 )
 
 // TokenPair bundles a short-lived access token and a long-lived refresh token.
@@ -27,16 +30,79 @@ type TokenPair struct {
 
 // UserService provides authentication-related operations:
 // - Register: create users
-// - Login: verify credentials and mint tokens
+// - LoginStart/LoginFinish: SRP-6a login that mints tokens
+// - Login: one-time legacy login that upgrades an account to SRP
+// - UpgradeKeys: move an account to the auth key / vault key hierarchy
+// - ChangePassword: replace the credentials and revoke refresh tokens
+// - UpgradeKDF: re-key an account whose KDF parameters are below policy
 // - RefreshToken: rotate refresh tokens and mint new access tokens
+// - Logout: end the caller's session
+// - ListSessions/RevokeSession/RevokeOtherSessions: manage device logins
+// - CheckSession: tell whether an access token's session is still valid
+//
+// Every login starts a session of the device it was made from. Access tokens
+// carry its ID, so revoking a session rejects them before they expire. The
+// refresh tokens a session rotates through form its family: replaying one
+// that was already rotated revokes the session, see TokenReuseError.
 type UserService struct {
 	db                           *sql.DB
 	repomanager                  repomanager.RepositoryManager
 	jwtSecret                    []byte
 	accessTokenValidityDuration  time.Duration
 	refreshTokenValidityDuration time.Duration
+	// kdfPolicy is the minimum KDF cost of new credentials.
+	kdfPolicy cryptox.KDFParams
 }
 
+// SaltInfo is what a client needs to derive its keys before logging in.
+type SaltInfo struct {
+	Salt []byte
+	// KDF are the parameters the account's master key is derived with.
+	KDF cryptox.KDFParams
+	// KDFUpgrade is the policy the client should re-derive the account's
+	// keys with via UpgradeKDF after logging in; nil if KDF meets it.
+	KDFUpgrade *cryptox.KDFParams
+	// LegacyKeys is set for accounts without a wrapped vault key, whose SRP
+	// verifier (if any) is derived from the master key instead of the auth
+	// key. Their clients upgrade them on the next login.
+	LegacyKeys bool
+}
+
+// LoginResult is the outcome of a successful LoginFinish, Verify2FA or
+// ChangePassword.
+type LoginResult struct {
+	// Tokens is nil if the login still needs the second factor.
+	Tokens *TokenPair
+	// ServerProof is the SRP proof M2 the client checks the server with.
+	ServerProof []byte
+	// WrappedVaultKey is the account's wrapped vault key; nil for accounts
+	// that predate the key hierarchy.
+	WrappedVaultKey []byte
+	// UserName is the account that logged in.
+	UserName string
+	// Versions maps the entries re-encrypted by ChangePassword to the
+	// versions they were stored under.
+	Versions map[string]int64
+	// TwoFactorChallenge is set instead of Tokens and WrappedVaultKey for
+	// accounts with two-factor authentication; the login is completed with
+	// Verify2FA.
+	TwoFactorChallenge string
+}
+
+// LoginChallenge is the server's answer to LoginStart.
+type LoginChallenge struct {
+	// SessionID identifies the login to finish with LoginFinish.
+	SessionID string
+	// ServerPublic is the server's ephemeral SRP public value B.
+	ServerPublic []byte
+}
+
+// loginSessionValidity bounds the time between LoginStart and LoginFinish.
+const loginSessionValidity = 2 * time.Minute
+
+// srpPublicSize is the length of an SRP public value (the group size).
+const srpPublicSize = 256
+
 // NewUserService constructs a UserService using repositories and server config.
 func NewUserService(db *sql.DB, m repomanager.RepositoryManager, cfg *config.Config) *UserService {
 	return &UserService{
@@ -45,15 +111,56 @@ func NewUserService(db *sql.DB, m repomanager.RepositoryManager, cfg *config.Con
 		jwtSecret:                    []byte(cfg.SecretKey),
 		accessTokenValidityDuration:  cfg.AccessTokenValidityDuration,
 		refreshTokenValidityDuration: cfg.RefreshTokenValidityDuration,
+		kdfPolicy:                    cfg.KDFParams(),
+	}
+}
+
+// TokenReuseError is returned by RefreshToken for a refresh token that was
+// already rotated. Only one of the parties holding it can have rotated it, so
+// it has leaked; the session it belongs to is revoked. It matches
+// common.ErrRefreshTokenReused with errors.Is.
+type TokenReuseError struct {
+	UserID    string
+	SessionID string
+	// Err is set if revoking the session failed.
+	Err error
+}
+
+func (e *TokenReuseError) Error() string {
+	msg := fmt.Sprintf("refresh token reused, session %s of user %s revoked", e.SessionID, e.UserID)
+	if e.Err != nil {
+		msg = fmt.Sprintf("refresh token reused, revoking session %s of user %s failed: %v", e.SessionID, e.UserID, e.Err)
 	}
+	return msg
 }
 
-// RefreshToken validates a refresh token, rotates it transactionally, and
-// returns a fresh TokenPair. Expired tokens yield ErrRefreshTokenExpired.
-func (s *UserService) RefreshToken(ctx context.Context, refreshToken string) (*TokenPair, error) {
+func (e *TokenReuseError) Unwrap() error { return common.ErrRefreshTokenReused }
+
+// LoginError is returned for a wrong SRP proof or second factor of a known
+// account, so that the failure can be attributed to it although LoginFinish
+// and Verify2FA are not given the username. It matches
+// common.ErrorUnauthorized with errors.Is.
+type LoginError struct {
+	UserName string
+}
+
+func (e *LoginError) Error() string {
+	return fmt.Sprintf("login of user %s failed", e.UserName)
+}
+
+func (e *LoginError) Unwrap() error { return common.ErrorUnauthorized }
+
+// RefreshToken validates a refresh token, rotates it within its session,
+// recording the use from ip, and returns a fresh TokenPair. Expired tokens
+// yield ErrRefreshTokenExpired; tokens that were already rotated revoke
+// their session and yield a *TokenReuseError.
+func (s *UserService) RefreshToken(ctx context.Context, refreshToken string, ip string) (*TokenPair, error) {
 	repo := s.repomanager.RefreshTokens(s.db)
 
 	token, err := repo.Find(ctx, refreshToken)
+	if errors.Is(err, common.ErrorNotFound) {
+		err = s.checkReuse(ctx, refreshToken)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error searching refresh token: %w", err)
 	}
@@ -61,24 +168,109 @@ func (s *UserService) RefreshToken(ctx context.Context, refreshToken string) (*T
 		return nil, common.ErrRefreshTokenExpired
 	}
 
-	var pair *TokenPair
-	if err := dbx.WithTx(ctx, s.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
-		repoTx := s.repomanager.RefreshTokens(tx)
-		if err := repoTx.Delete(ctx, refreshToken); err != nil {
-			return fmt.Errorf("error deleting refresh token: %w", err)
+	refresh, err := s.generateRefreshToken()
+	if err != nil {
+		return nil, common.ErrorInternal
+	}
+	// a concurrent refresh with the same token rotates it first and
+	// this one finds nothing to rotate
+	if err := repo.Rotate(ctx, refreshToken, refresh, s.refreshTokenValidityDuration, ip); err != nil {
+		return nil, fmt.Errorf("error rotating refresh token: %w", err)
+	}
+	access, err := s.generateAccessToken(token.UserID, token.ID)
+	if err != nil {
+		return nil, common.ErrorInternal
+	}
+	return &TokenPair{AccessToken: access, RefreshToken: refresh}, nil
+}
+
+// checkReuse handles an unknown refresh token: one rotated out of a
+// session that still exists revokes it and yields a *TokenReuseError, any
+// other one common.ErrorNotFound.
+func (s *UserService) checkReuse(ctx context.Context, refreshToken string) error {
+	repo := s.repomanager.RefreshTokens(s.db)
+
+	session, err := repo.FindRotated(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+	reuse := &TokenReuseError{UserID: session.UserID, SessionID: session.ID}
+	if err := repo.DeleteSession(ctx, session.UserID, session.ID); err != nil && !errors.Is(err, common.ErrorNotFound) {
+		reuse.Err = err
+	}
+	return reuse
+}
+
+// Logout ends the session sessionID of userID: its refresh token is deleted
+// and its access tokens are rejected from now on. A session that is already
+// gone is not an error.
+func (s *UserService) Logout(ctx context.Context, userID string, sessionID string) error {
+	err := s.repomanager.RefreshTokens(s.db).DeleteSession(ctx, userID, sessionID)
+	if err != nil && !errors.Is(err, common.ErrorNotFound) {
+		return common.ErrorInternal
+	}
+	return nil
+}
+
+// ListSessions returns the active sessions of userID, most recently used
+// first, with the caller's currentSessionID marked as Current.
+func (s *UserService) ListSessions(ctx context.Context, userID string, currentSessionID string) ([]*models.Session, error) {
+	sessions, err := s.repomanager.RefreshTokens(s.db).ListByUser(ctx, userID)
+	if err != nil {
+		return nil, common.ErrorInternal
+	}
+	for _, session := range sessions {
+		session.Current = session.ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+// RevokeSession logs the device of session id of userID out: its refresh
+// token is deleted and its access tokens are rejected from now on. It
+// returns common.ErrorNotFound if userID has no such session.
+func (s *UserService) RevokeSession(ctx context.Context, userID string, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return common.ErrorNotFound
+	}
+	if err := s.repomanager.RefreshTokens(s.db).DeleteSession(ctx, userID, id); err != nil {
+		if errors.Is(err, common.ErrorNotFound) {
+			return common.ErrorNotFound
 		}
-		var genErr error
-		pair, genErr = s.generateTokenPair(ctx, token.UserID, tx)
-		return genErr
-	}); err != nil {
-		return nil, err
+		return common.ErrorInternal
 	}
-	return pair, nil
+	return nil
+}
+
+// RevokeOtherSessions revokes every session of userID except the caller's
+// currentSessionID and returns how many were revoked.
+func (s *UserService) RevokeOtherSessions(ctx context.Context, userID string, currentSessionID string) (int64, error) {
+	n, err := s.repomanager.RefreshTokens(s.db).DeleteOtherSessions(ctx, userID, currentSessionID)
+	if err != nil {
+		return 0, common.ErrorInternal
+	}
+	return n, nil
+}
+
+// CheckSession returns common.ErrSessionRevoked if the session an access
+// token was minted for has been revoked or has expired.
+func (s *UserService) CheckSession(ctx context.Context, sessionID string) error {
+	ok, err := s.repomanager.RefreshTokens(s.db).Exists(ctx, sessionID)
+	if err != nil {
+		return common.ErrorInternal
+	}
+	if !ok {
+		return common.ErrSessionRevoked
+	}
+	return nil
 }
 
-// Register creates a new user with the given username, salt, and verifier.
-func (s *UserService) Register(ctx context.Context, username string, salt, verifier []byte) (*models.User, error) {
-	user := &models.User{UserName: username, Salt: salt, Verifier: verifier}
+// Register creates a new user with the given credentials. It returns
+// common.ErrorForbidden if their KDF parameters are below policy.
+func (s *UserService) Register(ctx context.Context, username string, c models.Credentials) (*models.User, error) {
+	if !s.meetsKDFPolicy(c.KDF) {
+		return nil, common.ErrorForbidden
+	}
+	user := &models.User{UserName: username, Salt: c.Salt, KDF: c.KDF, SRPVerifier: c.SRPVerifier, WrappedVaultKey: c.WrappedVaultKey}
 	repo := s.repomanager.Users(s.db)
 	u, err := repo.Create(ctx, user)
 	if err != nil {
@@ -87,23 +279,253 @@ func (s *UserService) Register(ctx context.Context, username string, salt, verif
 	return u, nil
 }
 
-// GetSalt returns the user's stored salt or a random salt if the user is absent,
-// to avoid leaking existence through timing.
-func (s *UserService) GetSalt(ctx context.Context, userName string) ([]byte, error) {
+// GetSalt returns the user's stored salt and KDF parameters or a random salt
+// if the user is absent, to avoid leaking existence through timing. Absent
+// users are reported as having current keys and the policy parameters.
+// Accounts on the key hierarchy whose parameters are below policy get the
+// policy as KDFUpgrade; legacy accounts upgrade their keys first.
+func (s *UserService) GetSalt(ctx context.Context, userName string) (*SaltInfo, error) {
 	repo := s.repomanager.Users(s.db)
 	user, err := repo.GetUserByLogin(ctx, userName)
 	if err != nil {
 		if errors.Is(err, common.ErrorNotFound) {
-			return s.getRandomSalt(), nil
+			return &SaltInfo{Salt: s.getRandomSalt(), KDF: s.kdfPolicy}, nil
 		}
 		return nil, common.ErrorInternal
 	}
-	return user.Salt, nil
+	info := &SaltInfo{Salt: user.Salt, KDF: user.KDF, LegacyKeys: user.WrappedVaultKey == nil}
+	if !info.LegacyKeys && user.KDF.Below(s.kdfPolicy) {
+		policy := s.kdfPolicy
+		info.KDFUpgrade = &policy
+	}
+	return info, nil
 }
 
-// Login verifies the provided verifierCandidate against the stored verifier and,
-// on success, returns a new TokenPair.
-func (s *UserService) Login(ctx context.Context, userName string, verifierCandidate []byte) (*TokenPair, error) {
+// LoginStart begins an SRP login with the client's public value and returns
+// the server's public value together with a session to finish the login.
+// Unknown users and accounts that still have a legacy verifier get a
+// challenge that can never be finished, so that the answer reveals neither
+// whether the account exists nor whether it has to log in via Login. A
+// malformed client public value is rejected with common.ErrorUnauthorized
+// before any lookup, and no session is created for it.
+func (s *UserService) LoginStart(ctx context.Context, userName string, clientPublic []byte) (*LoginChallenge, error) {
+	if !cryptox.ValidSRPPublicKey(clientPublic) {
+		return nil, common.ErrorUnauthorized
+	}
+	user, err := s.repomanager.Users(s.db).GetUserByLogin(ctx, userName)
+	if err != nil {
+		if errors.Is(err, common.ErrorNotFound) {
+			return fakeLoginChallenge(), nil
+		}
+		return nil, common.ErrorInternal
+	}
+	if user.SRPVerifier == nil {
+		return fakeLoginChallenge(), nil
+	}
+
+	srv, err := cryptox.NewSRPServer(user.UserName, user.Salt, user.SRPVerifier)
+	if err != nil {
+		return nil, common.ErrorInternal
+	}
+
+	repo := s.repomanager.LoginSessions(s.db)
+	// Abandoned logins are cleaned up here; a failure only delays that.
+	_ = repo.DeleteExpired(ctx)
+	id, err := repo.Create(ctx, &models.LoginSession{
+		UserID:       user.ID,
+		ClientPublic: clientPublic,
+		ServerSecret: srv.Secret(),
+	}, loginSessionValidity)
+	if err != nil {
+		return nil, common.ErrorInternal
+	}
+	return &LoginChallenge{SessionID: id, ServerPublic: srv.PublicKey()}, nil
+}
+
+// LoginFinish checks the client's SRP proof for a session started by
+// LoginStart. On success it starts a session of device and returns a new
+// TokenPair, the server proof the client uses to authenticate the server and
+// the account's wrapped vault key. For accounts with two-factor
+// authentication it returns the server proof and a challenge for Verify2FA
+// instead. A login session can be finished once. A wrong proof yields a
+// *LoginError.
+func (s *UserService) LoginFinish(ctx context.Context, sessionID string, clientProof []byte, device models.Device) (*LoginResult, error) {
+	user, serverProof, err := s.verifyLogin(ctx, sessionID, clientProof)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPSecret != nil {
+		challenge, err := s.createTwoFactorChallenge(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{ServerProof: serverProof, UserName: user.UserName, TwoFactorChallenge: challenge}, nil
+	}
+
+	pair, err := s.generateTokenPair(ctx, user.ID, device, s.db)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{Tokens: pair, ServerProof: serverProof, WrappedVaultKey: user.WrappedVaultKey, UserName: user.UserName}, nil
+}
+
+// ChangePassword replaces the salt, KDF parameters, SRP verifier and
+// wrapped vault key of the caller's account. The current password is proven
+// like in LoginFinish, with an SRP proof for a session started by LoginStart
+// for the same account. Every session of the account is revoked together
+// with the swap, so other devices have to log in with the new password; the
+// caller gets a new TokenPair for a session of device and the server proof.
+//
+// If c wraps a new vault key, rekey is the content of the account
+// re-encrypted under it and is stored in the same transaction (see
+// rekeyVault); the versions it got are returned in LoginResult.Versions.
+//
+// It returns common.ErrorUnauthorized for invalid sessions or proofs and if
+// the credentials were changed meanwhile, common.ErrorForbidden for
+// accounts that predate the key hierarchy, empty credentials and KDF
+// parameters below policy, and common.ErrVersionConflict if rekey does not
+// match the content of the account.
+func (s *UserService) ChangePassword(ctx context.Context, userID string, sessionID string, clientProof []byte, c models.Credentials, rekey *models.VaultRekey, device models.Device) (*LoginResult, error) {
+	user, serverProof, err := s.verifyCredentialsChange(ctx, userID, sessionID, clientProof, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var pair *TokenPair
+	var versions map[string]int64
+	if err := dbx.WithTx(ctx, s.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+		if err := s.changeCredentials(ctx, tx, user, c); err != nil {
+			return err
+		}
+		if rekey != nil {
+			var err error
+			if versions, err = s.rekeyVault(ctx, tx, user.ID, rekey); err != nil {
+				return err
+			}
+		}
+		if err := s.repomanager.RefreshTokens(tx).DeleteByUser(ctx, user.ID); err != nil {
+			return common.ErrorInternal
+		}
+		var genErr error
+		pair, genErr = s.generateTokenPair(ctx, user.ID, device, tx)
+		return genErr
+	}); err != nil {
+		return nil, err
+	}
+	return &LoginResult{Tokens: pair, ServerProof: serverProof, WrappedVaultKey: c.WrappedVaultKey, Versions: versions}, nil
+}
+
+// rekeyVault replaces the content of userID's account with rekey within tx,
+// which already holds the user row (see changeCredentials), so that no sync
+// can change the account meanwhile. rekey has to cover every entry at its
+// current version and every live file with its current nonce, or
+// common.ErrVersionConflict is returned: anything it misses would be left
+// under the old vault key. The entries are stored under new versions, so
+// that other devices pull them, with a new history; the old revisions and
+// the tombstoned files, whose keys rekey cannot carry, are sealed under the
+// old key and are deleted. It returns the new version of every entry.
+func (s *UserService) rekeyVault(ctx context.Context, tx dbx.DBTX, userID string, rekey *models.VaultRekey) (map[string]int64, error) {
+	userRepo := s.repomanager.Users(tx)
+	entryRepo := s.repomanager.Entries(tx)
+	fileRepo := s.repomanager.Files(tx)
+	revisionRepo := s.repomanager.Revisions(tx)
+
+	storedEntries, err := entryRepo.SelectUpdated(ctx, userID, 0)
+	if err != nil {
+		return nil, common.ErrorInternal
+	}
+	if len(rekey.Entries) != len(storedEntries) {
+		return nil, common.ErrVersionConflict
+	}
+	current := make(map[string]int64, len(storedEntries))
+	for _, e := range storedEntries {
+		current[e.ID] = e.Version
+	}
+	for _, e := range rekey.Entries {
+		if v, ok := current[e.ID]; !ok || v != e.BaseVersion {
+			return nil, common.ErrVersionConflict
+		}
+		delete(current, e.ID)
+	}
+
+	storedFiles, err := fileRepo.SelectUpdated(ctx, userID, 0)
+	if err != nil {
+		return nil, common.ErrorInternal
+	}
+	if len(rekey.Files) != len(storedFiles) {
+		return nil, common.ErrVersionConflict
+	}
+	nonces := make(map[string][]byte, len(storedFiles))
+	for _, f := range storedFiles {
+		nonces[f.EntryID] = f.Nonce
+	}
+	for _, f := range rekey.Files {
+		if nonce, ok := nonces[f.EntryID]; !ok || !bytes.Equal(nonce, f.Nonce) {
+			return nil, common.ErrVersionConflict
+		}
+		delete(nonces, f.EntryID)
+	}
+
+	if err := revisionRepo.DeleteByUser(ctx, userID); err != nil {
+		return nil, common.ErrorInternal
+	}
+	if err := fileRepo.PurgeDeletedByUser(ctx, userID); err != nil {
+		return nil, common.ErrorInternal
+	}
+	versions := make(map[string]int64, len(rekey.Entries))
+	for _, e := range rekey.Entries {
+		v, err := userRepo.IncrementCurrentVersion(ctx, userID)
+		if err != nil {
+			return nil, common.ErrorInternal
+		}
+		e.UserID = userID
+		e.Version = v
+		if err := entryRepo.CreateOrUpdate(ctx, e); err != nil {
+			return nil, common.ErrorInternal
+		}
+		if err := revisionRepo.Create(ctx, e); err != nil {
+			return nil, common.ErrorInternal
+		}
+		versions[e.ID] = v
+	}
+	for _, f := range rekey.Files {
+		if err := fileRepo.UpdateKey(ctx, userID, f.EntryID, f.EncryptedFileKey, versions[f.EntryID]); err != nil {
+			return nil, common.ErrorInternal
+		}
+	}
+	return versions, nil
+}
+
+// UpgradeKDF replaces the credentials of the caller's account, whose KDF
+// parameters are below policy, with ones the client re-derived from the same
+// password. The password is proven like in ChangePassword, but refresh
+// tokens stay valid, as other devices keep working with the same password.
+// It returns the server proof.
+//
+// It returns the errors of ChangePassword, and common.ErrorForbidden if the
+// account already meets the policy.
+func (s *UserService) UpgradeKDF(ctx context.Context, userID string, sessionID string, clientProof []byte, c models.Credentials) ([]byte, error) {
+	user, serverProof, err := s.verifyCredentialsChange(ctx, userID, sessionID, clientProof, c)
+	if err != nil {
+		return nil, err
+	}
+	if !user.KDF.Below(s.kdfPolicy) {
+		return nil, common.ErrorForbidden
+	}
+	if err := s.changeCredentials(ctx, s.db, user, c); err != nil {
+		return nil, err
+	}
+	return serverProof, nil
+}
+
+// Login verifies the legacy verifierCandidate of an account that has not
+// been upgraded yet, replaces it with srpVerifier and wrappedVaultKey (see
+// UpgradeKeys) and, on success, starts a session of device and returns a new
+// TokenPair. Upgraded accounts
+// can only log in via LoginStart and LoginFinish, so a captured legacy
+// verifier cannot be replayed. As LoginStart does not tell accounts with a
+// legacy verifier apart, clients find out by this call succeeding.
+func (s *UserService) Login(ctx context.Context, userName string, verifierCandidate, srpVerifier, wrappedVaultKey []byte, device models.Device) (*TokenPair, error) {
 	repo := s.repomanager.Users(s.db)
 	user, err := repo.GetUserByLogin(ctx, userName)
 	if err != nil {
@@ -112,18 +534,127 @@ func (s *UserService) Login(ctx context.Context, userName string, verifierCandid
 		}
 		return nil, common.ErrorInternal
 	}
+	if user.Verifier == nil || len(srpVerifier) == 0 || len(wrappedVaultKey) == 0 {
+		return nil, common.ErrorUnauthorized
+	}
 	if !s.checkVerifier(user.Verifier, verifierCandidate) {
 		return nil, common.ErrorUnauthorized
 	}
-	return s.generateTokenPair(ctx, user.ID, s.db)
+
+	var pair *TokenPair
+	if err := dbx.WithTx(ctx, s.db, nil, func(ctx context.Context, tx dbx.DBTX) error {
+		if err := s.repomanager.Users(tx).UpgradeKeys(ctx, user.ID, srpVerifier, wrappedVaultKey); err != nil {
+			return common.ErrorInternal
+		}
+		var genErr error
+		pair, genErr = s.generateTokenPair(ctx, user.ID, device, tx)
+		return genErr
+	}); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// UpgradeKeys moves an account that predates the key hierarchy to it by
+// storing an SRP verifier derived from the auth key and the wrapped vault
+// key. It returns common.ErrorForbidden if the account already has a vault
+// key (changing it is a password change) or an input is empty.
+func (s *UserService) UpgradeKeys(ctx context.Context, userID string, srpVerifier, wrappedVaultKey []byte) error {
+	if len(srpVerifier) == 0 || len(wrappedVaultKey) == 0 {
+		return common.ErrorForbidden
+	}
+	if err := s.repomanager.Users(s.db).UpgradeKeys(ctx, userID, srpVerifier, wrappedVaultKey); err != nil {
+		if errors.Is(err, common.ErrorNotFound) {
+			return common.ErrorForbidden
+		}
+		return common.ErrorInternal
+	}
+	return nil
 }
 
 // --- helpers below ---
 
+// fakeLoginChallenge returns a LoginChallenge of a session that does not
+// exist, shaped like a real one.
+func fakeLoginChallenge() *LoginChallenge {
+	return &LoginChallenge{
+		SessionID:    uuid.NewString(),
+		ServerPublic: common.GenerateRandByteArray(srpPublicSize),
+	}
+}
+
+// verifyLogin takes the login session started by LoginStart and checks the
+// client's SRP proof for it. It returns the account and the server proof,
+// common.ErrorUnauthorized if the session is unknown, or a *LoginError if the
+// proof is wrong.
+func (s *UserService) verifyLogin(ctx context.Context, sessionID string, clientProof []byte) (*models.User, []byte, error) {
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return nil, nil, common.ErrorUnauthorized
+	}
+	session, err := s.repomanager.LoginSessions(s.db).Take(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, common.ErrorNotFound) {
+			return nil, nil, common.ErrorUnauthorized
+		}
+		return nil, nil, common.ErrorInternal
+	}
+	user, err := s.repomanager.Users(s.db).GetUserByID(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, common.ErrorNotFound) {
+			return nil, nil, common.ErrorUnauthorized
+		}
+		return nil, nil, common.ErrorInternal
+	}
+
+	srv := cryptox.RestoreSRPServer(user.UserName, user.Salt, user.SRPVerifier, session.ServerSecret)
+	serverProof, err := srv.VerifyClient(session.ClientPublic, clientProof)
+	if err != nil {
+		return nil, nil, &LoginError{UserName: user.UserName}
+	}
+	return user, serverProof, nil
+}
+
+// verifyCredentialsChange checks new credentials c of userID and the SRP
+// proof of its current password, and returns the account and server proof.
+func (s *UserService) verifyCredentialsChange(ctx context.Context, userID string, sessionID string, clientProof []byte, c models.Credentials) (*models.User, []byte, error) {
+	if len(c.Salt) == 0 || len(c.SRPVerifier) == 0 || len(c.WrappedVaultKey) == 0 || !s.meetsKDFPolicy(c.KDF) {
+		return nil, nil, common.ErrorForbidden
+	}
+	user, serverProof, err := s.verifyLogin(ctx, sessionID, clientProof)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.ID != userID {
+		return nil, nil, common.ErrorUnauthorized
+	}
+	if user.WrappedVaultKey == nil {
+		return nil, nil, common.ErrorForbidden
+	}
+	return user, serverProof, nil
+}
+
+// changeCredentials swaps the credentials of user for c unless they were
+// changed since user was read, which yields common.ErrorUnauthorized.
+func (s *UserService) changeCredentials(ctx context.Context, db dbx.DBTX, user *models.User, c models.Credentials) error {
+	if err := s.repomanager.Users(db).ChangeCredentials(ctx, user.ID, user.SRPVerifier, c); err != nil {
+		if errors.Is(err, common.ErrorNotFound) {
+			return common.ErrorUnauthorized
+		}
+		return common.ErrorInternal
+	}
+	return nil
+}
+
+// meetsKDFPolicy reports whether p are valid parameters at least as strong
+// as the policy.
+func (s *UserService) meetsKDFPolicy(p cryptox.KDFParams) bool {
+	return p.Validate() == nil && !p.Below(s.kdfPolicy)
+}
+
 func (s *UserService) getRandomSalt() []byte { return common.GenerateRandByteArray(32) }
 
-func (s *UserService) generateAccessToken(userID string) (string, error) {
-	return auth.GenerateToken(userID, s.jwtSecret, s.accessTokenValidityDuration)
+func (s *UserService) generateAccessToken(userID string, sessionID string) (string, error) {
+	return auth.GenerateToken(userID, sessionID, s.jwtSecret, s.accessTokenValidityDuration)
 }
 
 func (s *UserService) generateRefreshToken() (string, error) {
@@ -134,17 +665,20 @@ func (s *UserService) checkVerifier(verifier []byte, candidate []byte) bool {
 	return subtle.ConstantTimeCompare(verifier, candidate) == 1
 }
 
-func (s *UserService) generateTokenPair(ctx context.Context, userID string, tx dbx.DBTX) (*TokenPair, error) {
-	access, err := s.generateAccessToken(userID)
+// generateTokenPair starts a session of device for userID: it stores a new
+// refresh token and mints an access token bound to the session.
+func (s *UserService) generateTokenPair(ctx context.Context, userID string, device models.Device, tx dbx.DBTX) (*TokenPair, error) {
+	refresh, err := s.generateRefreshToken()
 	if err != nil {
 		return nil, common.ErrorInternal
 	}
-	refresh, err := s.generateRefreshToken()
+	refreshRepo := s.repomanager.RefreshTokens(tx)
+	sessionID, err := refreshRepo.Create(ctx, userID, refresh, s.refreshTokenValidityDuration, device)
 	if err != nil {
 		return nil, common.ErrorInternal
 	}
-	refreshRepo := s.repomanager.RefreshTokens(tx)
-	if err := refreshRepo.Create(ctx, userID, refresh, s.refreshTokenValidityDuration); err != nil {
+	access, err := s.generateAccessToken(userID, sessionID)
+	if err != nil {
 		return nil, common.ErrorInternal
 	}
 	return &TokenPair{AccessToken: access, RefreshToken: refresh}, nil
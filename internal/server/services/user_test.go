@@ -1,22 +1,30 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"regexp"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/dmitrijs2005/gophkeeper/internal/common"
+	"github.com/dmitrijs2005/gophkeeper/internal/cryptox"
 	"github.com/dmitrijs2005/gophkeeper/internal/dbx"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/auth"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/config"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/models"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/entries"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/files"
+	loginsessionsrepo "github.com/dmitrijs2005/gophkeeper/internal/server/repositories/loginsessions"
+	ratelimitsrepo "github.com/dmitrijs2005/gophkeeper/internal/server/repositories/ratelimits"
 	refreshtokensrepo "github.com/dmitrijs2005/gophkeeper/internal/server/repositories/refreshtokens"
 	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/repomanager"
+	"github.com/dmitrijs2005/gophkeeper/internal/server/repositories/revisions"
+	twofactorrepo "github.com/dmitrijs2005/gophkeeper/internal/server/repositories/twofactor"
 	usersrepo "github.com/dmitrijs2005/gophkeeper/internal/server/repositories/users"
 )
 
@@ -38,18 +46,68 @@ func newUserService(t *testing.T, db *sql.DB, rm repomanager.RepositoryManager)
 		AccessTokenValidityDuration:  time.Hour,     //
 		RefreshTokenValidityDuration: 2 * time.Hour, //
 	}
+	cfg.KDFTime, cfg.KDFMemory, cfg.KDFThreads = 3, 64*1024, 4
 	return NewUserService(db, rm, cfg)
 }
 
 type fakeUsersRepo1 struct {
 	createOut *models.User
 	createErr error
+	created   *models.User
 
 	getOut *models.User
 	getErr error
+
+	upgraded   []byte
+	upgradedVK []byte
+	upgradeErr error
+
+	changed   *models.User
+	changeErr error
+
+	version int64
+}
+
+// The TOTP methods update getOut, like the database would the user row.
+
+func (f *fakeUsersRepo1) SetPendingTOTPSecret(ctx context.Context, userID string, secret []byte) error {
+	f.getOut.TOTPPendingSecret = secret
+	return nil
+}
+
+func (f *fakeUsersRepo1) EnableTOTP(ctx context.Context, userID string, secret []byte, step int64) error {
+	if !bytes.Equal(f.getOut.TOTPPendingSecret, secret) {
+		return common.ErrorNotFound
+	}
+	f.getOut.TOTPSecret, f.getOut.TOTPPendingSecret, f.getOut.TOTPLastStep = secret, nil, step
+	return nil
+}
+
+func (f *fakeUsersRepo1) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	if step <= f.getOut.TOTPLastStep {
+		return common.ErrorNotFound
+	}
+	f.getOut.TOTPLastStep = step
+	return nil
+}
+
+func (f *fakeUsersRepo1) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
+	return f.GetUserByLogin(ctx, userID)
+}
+func (f *fakeUsersRepo1) UpgradeKeys(ctx context.Context, userID string, srpVerifier, wrappedVaultKey []byte) error {
+	f.upgraded, f.upgradedVK = srpVerifier, wrappedVaultKey
+	return f.upgradeErr
+}
+func (f *fakeUsersRepo1) ChangeCredentials(ctx context.Context, userID string, oldSRPVerifier []byte, c models.Credentials) error {
+	if f.changeErr != nil {
+		return f.changeErr
+	}
+	f.changed = &models.User{ID: userID, Salt: c.Salt, KDF: c.KDF, SRPVerifier: c.SRPVerifier, WrappedVaultKey: c.WrappedVaultKey}
+	return nil
 }
 
 func (f *fakeUsersRepo1) Create(ctx context.Context, u *models.User) (*models.User, error) {
+	f.created = u
 	if f.createErr != nil {
 		return nil, f.createErr
 	}
@@ -63,20 +121,80 @@ func (f *fakeUsersRepo1) GetUserByLogin(ctx context.Context, userName string) (*
 }
 
 func (f *fakeUsersRepo1) IncrementCurrentVersion(context.Context, string) (int64, error) {
+	f.version++
+	return f.version, nil
+}
+
+func (f *fakeUsersRepo1) GetPurgedVersion(context.Context, string) (int64, error) {
 	return 0, nil
 }
 
+func (f *fakeUsersRepo1) RaisePurgedVersion(context.Context, string, int64) error {
+	return nil
+}
+
 type fakeRefreshRepo struct {
 	findOut *models.RefreshToken
 	findErr error
 
+	rotatedOut *models.RefreshToken
+	rotatedErr error
+
 	delErr error
 
 	createErr error
+	device    models.Device
+
+	rotatedIP string
+	rotateErr error
+
+	revokedUser string
+	revokeErr   error
+
+	sessions       []*models.Session
+	listErr        error
+	exists         bool
+	existsErr      error
+	deletedSession string
+	deleteSessErr  error
+	keptSession    string
+	deleteOthersN  int64
+	deleteOthersEr error
 }
 
-func (f *fakeRefreshRepo) Create(ctx context.Context, userID string, token string, validity time.Duration) error {
-	return f.createErr
+func (f *fakeRefreshRepo) Create(ctx context.Context, userID string, token string, validity time.Duration, device models.Device) (string, error) {
+	f.device = device
+	if f.createErr != nil {
+		return "", f.createErr
+	}
+	return "s-new", nil
+}
+func (f *fakeRefreshRepo) Rotate(ctx context.Context, oldToken string, newToken string, validity time.Duration, ip string) error {
+	f.rotatedIP = ip
+	return f.rotateErr
+}
+func (f *fakeRefreshRepo) FindRotated(ctx context.Context, token string) (*models.RefreshToken, error) {
+	if f.rotatedErr != nil {
+		return nil, f.rotatedErr
+	}
+	if f.rotatedOut == nil {
+		return nil, common.ErrorNotFound
+	}
+	return f.rotatedOut, nil
+}
+func (f *fakeRefreshRepo) ListByUser(ctx context.Context, userID string) ([]*models.Session, error) {
+	return f.sessions, f.listErr
+}
+func (f *fakeRefreshRepo) Exists(ctx context.Context, id string) (bool, error) {
+	return f.exists, f.existsErr
+}
+func (f *fakeRefreshRepo) DeleteSession(ctx context.Context, userID string, id string) error {
+	f.deletedSession = id
+	return f.deleteSessErr
+}
+func (f *fakeRefreshRepo) DeleteOtherSessions(ctx context.Context, userID string, keepID string) (int64, error) {
+	f.keptSession = keepID
+	return f.deleteOthersN, f.deleteOthersEr
 }
 func (f *fakeRefreshRepo) Find(ctx context.Context, token string) (*models.RefreshToken, error) {
 	if f.findErr != nil {
@@ -87,39 +205,95 @@ func (f *fakeRefreshRepo) Find(ctx context.Context, token string) (*models.Refre
 func (f *fakeRefreshRepo) Delete(ctx context.Context, token string) error {
 	return f.delErr
 }
+func (f *fakeRefreshRepo) DeleteByUser(ctx context.Context, userID string) error {
+	f.revokedUser = userID
+	return f.revokeErr
+}
+
+type fakeLoginSessionsRepo struct {
+	sessions map[string]*models.LoginSession
+	next     int
+}
+
+func (f *fakeLoginSessionsRepo) Create(ctx context.Context, session *models.LoginSession, validity time.Duration) (string, error) {
+	if f.sessions == nil {
+		f.sessions = map[string]*models.LoginSession{}
+	}
+	f.next++
+	id := fmt.Sprintf("00000000-0000-0000-0000-%012d", f.next)
+	f.sessions[id] = session
+	return id, nil
+}
+func (f *fakeLoginSessionsRepo) Take(ctx context.Context, id string) (*models.LoginSession, error) {
+	s, ok := f.sessions[id]
+	if !ok {
+		return nil, common.ErrorNotFound
+	}
+	delete(f.sessions, id)
+	return s, nil
+}
+func (f *fakeLoginSessionsRepo) DeleteExpired(ctx context.Context) error { return nil }
 
 type fakeRepoManager1 struct {
 	u *fakeUsersRepo1
 	r *fakeRefreshRepo
+	l *fakeLoginSessionsRepo
+	// rl serves the rate limiter tests
+	rl ratelimitsrepo.Repository
+	tf *fakeTwoFactorRepo
+	// e, f and rv serve the vault re-key tests
+	e  *fakeEntriesRepo
+	f  *fakeFilesRepo
+	rv *fakeRevisionsRepo
 }
 
 func (m *fakeRepoManager1) RunMigrations(context.Context, *sql.DB) error           { return nil }
 func (m *fakeRepoManager1) Users(db dbx.DBTX) usersrepo.Repository                 { return m.u }
 func (m *fakeRepoManager1) RefreshTokens(db dbx.DBTX) refreshtokensrepo.Repository { return m.r }
+func (m *fakeRepoManager1) LoginSessions(db dbx.DBTX) loginsessionsrepo.Repository {
+	if m.l == nil {
+		m.l = &fakeLoginSessionsRepo{}
+	}
+	return m.l
+}
 
-func (m *fakeRepoManager1) Entries(db dbx.DBTX) entries.Repository { return nil }
-func (m *fakeRepoManager1) Files(db dbx.DBTX) files.Repository     { return nil }
+func (m *fakeRepoManager1) Entries(db dbx.DBTX) entries.Repository     { return m.e }
+func (m *fakeRepoManager1) Files(db dbx.DBTX) files.Repository         { return m.f }
+func (m *fakeRepoManager1) Revisions(db dbx.DBTX) revisions.Repository { return m.rv }
+func (m *fakeRepoManager1) RateLimits(db dbx.DBTX) ratelimitsrepo.Repository {
+	return m.rl
+}
+func (m *fakeRepoManager1) TwoFactor(db dbx.DBTX) twofactorrepo.Repository {
+	if m.tf == nil {
+		m.tf = &fakeTwoFactorRepo{}
+	}
+	return m.tf
+}
 
 func TestRefreshToken_Success(t *testing.T) {
 	db, mock := newSQLMockDB1(t)
 	defer db.Close()
-	mock.ExpectBegin()
-	mock.ExpectCommit()
 
-	rm := &fakeRepoManager1{
-		r: &fakeRefreshRepo{
-			findOut: &models.RefreshToken{UserID: "u1", Expires: time.Now().Add(10 * time.Minute)},
-		},
+	refresh := &fakeRefreshRepo{
+		findOut: &models.RefreshToken{ID: "s1", UserID: "u1", Expires: time.Now().Add(10 * time.Minute)},
 	}
-	s := newUserService(t, db, rm)
+	s := newUserService(t, db, &fakeRepoManager1{r: refresh})
 
-	pair, err := s.RefreshToken(context.Background(), "refresh-xyz")
+	pair, err := s.RefreshToken(context.Background(), "refresh-xyz", "10.0.0.1")
 	if err != nil {
 		t.Fatalf("RefreshToken error: %v", err)
 	}
 	if pair.AccessToken == "" || pair.RefreshToken == "" {
 		t.Fatalf("empty tokens: %+v", pair)
 	}
+	if refresh.rotatedIP != "10.0.0.1" {
+		t.Fatalf("use not recorded: %q", refresh.rotatedIP)
+	}
+	// the session survives the rotation
+	claims, err := auth.ParseToken(pair.AccessToken, []byte("k"))
+	if err != nil || claims.UserID != "u1" || claims.SessionID != "s1" {
+		t.Fatalf("access token claims: %+v, %v", claims, err)
+	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("sql expectations: %v", err)
 	}
@@ -136,7 +310,7 @@ func TestRefreshToken_Expired(t *testing.T) {
 	}
 	s := newUserService(t, db, rm)
 
-	_, err := s.RefreshToken(context.Background(), "r")
+	_, err := s.RefreshToken(context.Background(), "r", "")
 	if !errors.Is(err, common.ErrRefreshTokenExpired) {
 		t.Fatalf("want ErrRefreshTokenExpired, got %v", err)
 	}
@@ -149,50 +323,150 @@ func TestRefreshToken_FindErr(t *testing.T) {
 	rm := &fakeRepoManager1{r: &fakeRefreshRepo{findErr: errBoom{}}}
 	s := newUserService(t, db, rm)
 
-	_, err := s.RefreshToken(context.Background(), "r")
+	_, err := s.RefreshToken(context.Background(), "r", "")
 	if err == nil || !regexp.MustCompile(`error searching refresh token: .*boom`).MatchString(err.Error()) {
 		t.Fatalf("expected wrapped find error, got %v", err)
 	}
 }
 
-func TestRefreshToken_DeleteErr(t *testing.T) {
-	db, mock := newSQLMockDB(t)
+func TestRefreshToken_RotateErr(t *testing.T) {
+	db, _ := newSQLMockDB(t)
 	defer db.Close()
-	mock.ExpectBegin()
-	mock.ExpectRollback()
 
 	rm := &fakeRepoManager1{
 		r: &fakeRefreshRepo{
-			findOut: &models.RefreshToken{UserID: "u1", Expires: time.Now().Add(10 * time.Minute)},
-			delErr:  errBoom{},
+			findOut:   &models.RefreshToken{UserID: "u1", Expires: time.Now().Add(10 * time.Minute)},
+			rotateErr: errBoom{},
 		},
 	}
 	s := newUserService(t, db, rm)
 
-	_, err := s.RefreshToken(context.Background(), "r")
-	if err == nil || !regexp.MustCompile(`error deleting refresh token: .*boom`).MatchString(err.Error()) {
-		t.Fatalf("expected wrapped delete error, got %v", err)
+	_, err := s.RefreshToken(context.Background(), "r", "")
+	if err == nil || !regexp.MustCompile(`error rotating refresh token: .*boom`).MatchString(err.Error()) {
+		t.Fatalf("expected wrapped rotate error, got %v", err)
+	}
+
+	// revoked meanwhile, or rotated by a concurrent refresh
+	rm.r.rotateErr = common.ErrorNotFound
+	if _, err := s.RefreshToken(context.Background(), "r", ""); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("want common.ErrorNotFound, got %v", err)
 	}
 }
 
-func TestRefreshToken_GeneratePair_CreateErr(t *testing.T) {
-	db, mock := newSQLMockDB(t)
+func TestRefreshToken_Reuse(t *testing.T) {
+	db, _ := newSQLMockDB(t)
 	defer db.Close()
-	mock.ExpectBegin()
-	mock.ExpectRollback()
 
-	rm := &fakeRepoManager1{
-		r: &fakeRefreshRepo{
-			findOut:   &models.RefreshToken{UserID: "u1", Expires: time.Now().Add(10 * time.Minute)},
-			createErr: errBoom{},
-		},
+	refresh := &fakeRefreshRepo{
+		findErr:    common.ErrorNotFound,
+		rotatedOut: &models.RefreshToken{ID: "s1", UserID: "u1"},
 	}
-	s := newUserService(t, db, rm)
+	s := newUserService(t, db, &fakeRepoManager1{r: refresh})
 
-	_, err := s.RefreshToken(context.Background(), "r")
+	// a rotated token revokes its family
+	_, err := s.RefreshToken(context.Background(), "old", "")
+	var reuse *TokenReuseError
+	if !errors.As(err, &reuse) || !errors.Is(err, common.ErrRefreshTokenReused) {
+		t.Fatalf("want TokenReuseError, got %v", err)
+	}
+	if reuse.UserID != "u1" || reuse.SessionID != "s1" || reuse.Err != nil || refresh.deletedSession != "s1" {
+		t.Fatalf("session not revoked: %+v, deleted %q", reuse, refresh.deletedSession)
+	}
+
+	refresh.deleteSessErr = errBoom{}
+	_, err = s.RefreshToken(context.Background(), "old", "")
+	if !errors.As(err, &reuse) || !errors.Is(reuse.Err, errBoom{}) {
+		t.Fatalf("want the failed revocation reported, got %v", err)
+	}
 
-	if !errors.Is(err, common.ErrorInternal) && err != nil && !regexp.MustCompile(`error generating token pair:`).MatchString(err.Error()) {
-		t.Fatalf("expected internal/generate error, got %v", err)
+	// an unknown token is just not found
+	refresh.rotatedOut = nil
+	if _, err := s.RefreshToken(context.Background(), "other", ""); !errors.Is(err, common.ErrorNotFound) || errors.Is(err, common.ErrRefreshTokenReused) {
+		t.Fatalf("want common.ErrorNotFound, got %v", err)
+	}
+	refresh.rotatedErr = errBoom{}
+	if _, err := s.RefreshToken(context.Background(), "other", ""); !errors.Is(err, errBoom{}) {
+		t.Fatalf("want the lookup error, got %v", err)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	db, _ := newSQLMockDB(t)
+	defer db.Close()
+
+	refresh := &fakeRefreshRepo{}
+	s := newUserService(t, db, &fakeRepoManager1{r: refresh})
+	ctx := context.Background()
+
+	if err := s.Logout(ctx, "u1", "s1"); err != nil || refresh.deletedSession != "s1" {
+		t.Fatalf("Logout: %v, deleted %q", err, refresh.deletedSession)
+	}
+	refresh.deleteSessErr = common.ErrorNotFound
+	if err := s.Logout(ctx, "u1", "s1"); err != nil {
+		t.Fatalf("Logout of a revoked session: %v", err)
+	}
+	refresh.deleteSessErr = errBoom{}
+	if err := s.Logout(ctx, "u1", "s1"); !errors.Is(err, common.ErrorInternal) {
+		t.Fatalf("want common.ErrorInternal, got %v", err)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	db, _ := newSQLMockDB(t)
+	defer db.Close()
+
+	refresh := &fakeRefreshRepo{
+		sessions: []*models.Session{{ID: "s1"}, {ID: "s2"}},
+		exists:   true,
+	}
+	s := newUserService(t, db, &fakeRepoManager1{r: refresh})
+	ctx := context.Background()
+
+	got, err := s.ListSessions(ctx, "u1", "s2")
+	if err != nil || len(got) != 2 || got[0].Current || !got[1].Current {
+		t.Fatalf("ListSessions: %+v, %v", got, err)
+	}
+	refresh.listErr = errBoom{}
+	if _, err := s.ListSessions(ctx, "u1", "s2"); !errors.Is(err, common.ErrorInternal) {
+		t.Fatalf("list error → internal, got %v", err)
+	}
+
+	id := "00000000-0000-0000-0000-000000000001"
+	if err := s.RevokeSession(ctx, "u1", id); err != nil || refresh.deletedSession != id {
+		t.Fatalf("RevokeSession: %v, deleted %q", err, refresh.deletedSession)
+	}
+	refresh.deletedSession = ""
+	if err := s.RevokeSession(ctx, "u1", "not-a-uuid"); !errors.Is(err, common.ErrorNotFound) || refresh.deletedSession != "" {
+		t.Fatalf("malformed id → not found without a query, got %v", err)
+	}
+	refresh.deleteSessErr = common.ErrorNotFound
+	if err := s.RevokeSession(ctx, "u1", id); !errors.Is(err, common.ErrorNotFound) {
+		t.Fatalf("foreign session → not found, got %v", err)
+	}
+	refresh.deleteSessErr = errBoom{}
+	if err := s.RevokeSession(ctx, "u1", id); !errors.Is(err, common.ErrorInternal) {
+		t.Fatalf("db error → internal, got %v", err)
+	}
+
+	refresh.deleteOthersN = 3
+	if n, err := s.RevokeOtherSessions(ctx, "u1", "s2"); err != nil || n != 3 || refresh.keptSession != "s2" {
+		t.Fatalf("RevokeOtherSessions: %d, %v, kept %q", n, err, refresh.keptSession)
+	}
+	refresh.deleteOthersEr = errBoom{}
+	if _, err := s.RevokeOtherSessions(ctx, "u1", "s2"); !errors.Is(err, common.ErrorInternal) {
+		t.Fatalf("db error → internal, got %v", err)
+	}
+
+	if err := s.CheckSession(ctx, "s1"); err != nil {
+		t.Fatalf("CheckSession: %v", err)
+	}
+	refresh.exists = false
+	if err := s.CheckSession(ctx, "s1"); !errors.Is(err, common.ErrSessionRevoked) {
+		t.Fatalf("revoked session → ErrSessionRevoked, got %v", err)
+	}
+	refresh.existsErr = errBoom{}
+	if err := s.CheckSession(ctx, "s1"); !errors.Is(err, common.ErrorInternal) {
+		t.Fatalf("db error → internal, got %v", err)
 	}
 }
 
@@ -200,22 +474,33 @@ func TestRegister_SuccessAndError(t *testing.T) {
 	db, _ := newSQLMockDB(t)
 	defer db.Close()
 
+	creds := models.Credentials{Salt: []byte("s"), KDF: cryptox.DefaultKDFParams, SRPVerifier: []byte("v"), WrappedVaultKey: []byte("wvk")}
+	users := &fakeUsersRepo1{createOut: &models.User{ID: "42", UserName: "alice"}}
 	rmOK := &fakeRepoManager1{
-		u: &fakeUsersRepo1{createOut: &models.User{ID: "42", UserName: "alice"}},
+		u: users,
 		r: &fakeRefreshRepo{},
 	}
 	sOK := newUserService(t, db, rmOK)
-	u, err := sOK.Register(context.Background(), "alice", []byte("s"), []byte("v"))
+	u, err := sOK.Register(context.Background(), "alice", creds)
 	if err != nil || u.ID != "42" {
 		t.Fatalf("Register ok: got (%v, %v)", u, err)
 	}
+	if users.created.KDF != cryptox.DefaultKDFParams {
+		t.Fatalf("KDF parameters not stored: %+v", users.created.KDF)
+	}
+
+	weak := creds
+	weak.KDF = cryptox.LegacyKDFParams
+	if _, err := sOK.Register(context.Background(), "alice", weak); !errors.Is(err, common.ErrorForbidden) {
+		t.Fatalf("KDF below policy → forbidden, got %v", err)
+	}
 
 	rmErr := &fakeRepoManager1{
 		u: &fakeUsersRepo1{createErr: errBoom{}},
 		r: &fakeRefreshRepo{},
 	}
 	sErr := newUserService(t, db, rmErr)
-	_, err = sErr.Register(context.Background(), "bob", []byte("s"), []byte("v"))
+	_, err = sErr.Register(context.Background(), "bob", creds)
 	if err == nil || !regexp.MustCompile(`error creating user: .*boom`).MatchString(err.Error()) {
 		t.Fatalf("Register expected wrapped error, got %v", err)
 	}
@@ -226,13 +511,29 @@ func TestGetSalt_Found_NotFound_Internal(t *testing.T) {
 	defer db.Close()
 
 	rmFound := &fakeRepoManager1{
-		u: &fakeUsersRepo1{getOut: &models.User{Salt: []byte("SALT")}},
+		u: &fakeUsersRepo1{getOut: &models.User{Salt: []byte("SALT"), KDF: cryptox.LegacyKDFParams}},
 		r: &fakeRefreshRepo{},
 	}
 	s := newUserService(t, db, rmFound)
 	salt, err := s.GetSalt(context.Background(), "alice")
-	if err != nil || string(salt) != "SALT" {
-		t.Fatalf("GetSalt found: got (%q, %v)", string(salt), err)
+	if err != nil || string(salt.Salt) != "SALT" || !salt.LegacyKeys || salt.KDF != cryptox.LegacyKDFParams || salt.KDFUpgrade != nil {
+		t.Fatalf("GetSalt found: got (%+v, %v)", salt, err)
+	}
+
+	current := &models.User{Salt: []byte("SALT"), KDF: cryptox.DefaultKDFParams, WrappedVaultKey: []byte("wvk")}
+	rmCurrent := &fakeRepoManager1{
+		u: &fakeUsersRepo1{getOut: current},
+		r: &fakeRefreshRepo{},
+	}
+	if salt, err := newUserService(t, db, rmCurrent).GetSalt(context.Background(), "alice"); err != nil || salt.LegacyKeys || salt.KDFUpgrade != nil {
+		t.Fatalf("GetSalt with vault key: got (%+v, %v)", salt, err)
+	}
+
+	// accounts below policy are asked to upgrade
+	current.KDF = cryptox.LegacyKDFParams
+	salt, err = newUserService(t, db, rmCurrent).GetSalt(context.Background(), "alice")
+	if err != nil || salt.KDF != cryptox.LegacyKDFParams || salt.KDFUpgrade == nil || *salt.KDFUpgrade != cryptox.DefaultKDFParams {
+		t.Fatalf("GetSalt below policy: got (%+v, %v)", salt, err)
 	}
 
 	rmNF := &fakeRepoManager1{
@@ -241,8 +542,8 @@ func TestGetSalt_Found_NotFound_Internal(t *testing.T) {
 	}
 	s2 := newUserService(t, db, rmNF)
 	salt2, err := s2.GetSalt(context.Background(), "ghost")
-	if err != nil || len(salt2) != 32 {
-		t.Fatalf("GetSalt not found: len=%d err=%v", len(salt2), err)
+	if err != nil || len(salt2.Salt) != 32 || salt2.LegacyKeys || salt2.KDF != cryptox.DefaultKDFParams || salt2.KDFUpgrade != nil {
+		t.Fatalf("GetSalt not found: %+v err=%v", salt2, err)
 	}
 
 	rmErr := &fakeRepoManager1{
@@ -257,7 +558,7 @@ func TestGetSalt_Found_NotFound_Internal(t *testing.T) {
 }
 
 func TestLogin_Flows(t *testing.T) {
-	db, _ := newSQLMockDB(t)
+	db, mock := newSQLMockDB(t)
 	defer db.Close()
 
 	// not found → unauthorized
@@ -266,7 +567,7 @@ func TestLogin_Flows(t *testing.T) {
 		r: &fakeRefreshRepo{},
 	}
 	sNF := newUserService(t, db, rmNF)
-	if _, err := sNF.Login(context.Background(), "ghost", []byte("x")); !errors.Is(err, common.ErrorUnauthorized) {
+	if _, err := sNF.Login(context.Background(), "ghost", []byte("x"), []byte("srp"), []byte("wvk"), models.Device{}); !errors.Is(err, common.ErrorUnauthorized) {
 		t.Fatalf("notfound → unauthorized, got %v", err)
 	}
 
@@ -276,7 +577,7 @@ func TestLogin_Flows(t *testing.T) {
 		r: &fakeRefreshRepo{},
 	}
 	sIE := newUserService(t, db, rmIE)
-	if _, err := sIE.Login(context.Background(), "u", []byte("x")); !errors.Is(err, common.ErrorInternal) {
+	if _, err := sIE.Login(context.Background(), "u", []byte("x"), []byte("srp"), []byte("wvk"), models.Device{}); !errors.Is(err, common.ErrorInternal) {
 		t.Fatalf("internal → ErrorInternal, got %v", err)
 	}
 
@@ -286,17 +587,430 @@ func TestLogin_Flows(t *testing.T) {
 		r: &fakeRefreshRepo{},
 	}
 	sWV := newUserService(t, db, rmWV)
-	if _, err := sWV.Login(context.Background(), "u", []byte("wrong")); !errors.Is(err, common.ErrorUnauthorized) {
+	if _, err := sWV.Login(context.Background(), "u", []byte("wrong"), []byte("srp"), []byte("wvk"), models.Device{}); !errors.Is(err, common.ErrorUnauthorized) {
 		t.Fatalf("wrong verifier → unauthorized, got %v", err)
 	}
 
-	rmOK := &fakeRepoManager1{
-		u: &fakeUsersRepo1{getOut: &models.User{ID: "u1", Verifier: []byte("right")}},
+	// no SRP verifier or vault key to upgrade to → unauthorized
+	if _, err := sWV.Login(context.Background(), "u", []byte("right"), nil, []byte("wvk"), models.Device{}); !errors.Is(err, common.ErrorUnauthorized) {
+		t.Fatalf("missing srp verifier → unauthorized, got %v", err)
+	}
+	if _, err := sWV.Login(context.Background(), "u", []byte("right"), []byte("srp"), nil, models.Device{}); !errors.Is(err, common.ErrorUnauthorized) {
+		t.Fatalf("missing vault key → unauthorized, got %v", err)
+	}
+
+	// upgraded accounts no longer accept the legacy verifier
+	rmUp := &fakeRepoManager1{
+		u: &fakeUsersRepo1{getOut: &models.User{ID: "u1", SRPVerifier: []byte("v")}},
 		r: &fakeRefreshRepo{},
 	}
-	sOK := newUserService(t, db, rmOK)
-	pair, err := sOK.Login(context.Background(), "u", []byte("right"))
+	sUp := newUserService(t, db, rmUp)
+	if _, err := sUp.Login(context.Background(), "u", nil, []byte("srp"), []byte("wvk"), models.Device{}); !errors.Is(err, common.ErrorUnauthorized) {
+		t.Fatalf("upgraded account → unauthorized, got %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	users := &fakeUsersRepo1{getOut: &models.User{ID: "u1", Verifier: []byte("right")}}
+	sOK := newUserService(t, db, &fakeRepoManager1{u: users, r: &fakeRefreshRepo{}})
+	pair, err := sOK.Login(context.Background(), "u", []byte("right"), []byte("srp"), []byte("wvk"), models.Device{})
 	if err != nil || pair.AccessToken == "" || pair.RefreshToken == "" {
 		t.Fatalf("Login success: pair=%+v err=%v", pair, err)
 	}
+	if string(users.upgraded) != "srp" || string(users.upgradedVK) != "wvk" {
+		t.Fatalf("account not upgraded: %q, %q", users.upgraded, users.upgradedVK)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestLoginStartFinish_SRP(t *testing.T) {
+	db, _ := newSQLMockDB(t)
+	defer db.Close()
+
+	salt := []byte("salt")
+	key := make([]byte, 32)
+	user := &models.User{ID: "u1", UserName: "alice", Salt: salt, SRPVerifier: cryptox.SRPVerifier("alice", salt, key), WrappedVaultKey: []byte("wvk")}
+	refresh := &fakeRefreshRepo{}
+	s := newUserService(t, db, &fakeRepoManager1{u: &fakeUsersRepo1{getOut: user}, r: refresh})
+
+	login := func(key []byte) (string, []byte, *cryptox.SRPClient) {
+		c, err := cryptox.NewSRPClient("alice", salt, key)
+		if err != nil {
+			t.Fatalf("NewSRPClient: %v", err)
+		}
+		ch, err := s.LoginStart(context.Background(), "alice", c.PublicKey())
+		if err != nil {
+			t.Fatalf("LoginStart: %+v, %v", ch, err)
+		}
+		m1, err := c.Proof(ch.ServerPublic)
+		if err != nil {
+			t.Fatalf("Proof: %v", err)
+		}
+		return ch.SessionID, m1, c
+	}
+
+	id, m1, c := login(key)
+	device := models.Device{Name: "laptop", Platform: "linux/amd64", ClientVersion: "1.2.0", IP: "10.0.0.1"}
+	res, err := s.LoginFinish(context.Background(), id, m1, device)
+	if err != nil || res.Tokens.AccessToken == "" || string(res.WrappedVaultKey) != "wvk" || res.UserName != "alice" {
+		t.Fatalf("LoginFinish: %+v, %v", res, err)
+	}
+	if refresh.device != device {
+		t.Fatalf("session device: %+v", refresh.device)
+	}
+	if claims, err := auth.ParseToken(res.Tokens.AccessToken, []byte("k")); err != nil || claims.SessionID != "s-new" {
+		t.Fatalf("access token not bound to the session: %+v, %v", claims, err)
+	}
+	if err := c.VerifyServer(res.ServerProof); err != nil {
+		t.Fatalf("server proof rejected: %v", err)
+	}
+
+	// a session is finished at most once
+	if _, err := s.LoginFinish(context.Background(), id, m1, models.Device{}); !errors.Is(err, common.ErrorUnauthorized) {
+		t.Fatalf("replayed session → unauthorized, got %v", err)
+	}
+
+	wrong := make([]byte, 32)
+	wrong[0] = 1
+	id, m1, _ = login(wrong)
+	_, err = s.LoginFinish(context.Background(), id, m1, models.Device{})
+	if !errors.Is(err, common.ErrorUnauthorized) {
+		t.Fatalf("wrong password → unauthorized, got %v", err)
+	}
+	var loginErr *LoginError
+	if !errors.As(err, &loginErr) || loginErr.UserName != "alice" {
+		t.Fatalf("wrong password not attributed to the account: %v", err)
+	}
+
+	if _, err := s.LoginFinish(context.Background(), "not-a-session", m1, models.Device{}); !errors.Is(err, common.ErrorUnauthorized) {
+		t.Fatalf("bad session id → unauthorized, got %v", err)
+	}
+}
+
+// validClientPublic returns a well-formed SRP client public value.
+func validClientPublic(t *testing.T) []byte {
+	t.Helper()
+	c, err := cryptox.NewSRPClient("ghost", []byte("salt"), make([]byte, 32))
+	if err != nil {
+		t.Fatalf("NewSRPClient: %v", err)
+	}
+	return c.PublicKey()
+}
+
+func TestLoginStart_RejectsMalformedClientPublic(t *testing.T) {
+	db, _ := newSQLMockDB(t)
+	defer db.Close()
+
+	salt := []byte("salt")
+	user := &models.User{ID: "u1", UserName: "alice", Salt: salt, SRPVerifier: cryptox.SRPVerifier("alice", salt, make([]byte, 32))}
+	sessions := &fakeLoginSessionsRepo{}
+	s := newUserService(t, db, &fakeRepoManager1{u: &fakeUsersRepo1{getOut: user}, l: sessions})
+
+	// one byte longer than N used to panic in LoginFinish
+	oversized := append([]byte{1}, make([]byte, 256)...)
+	for name, a := range map[string][]byte{"oversized": oversized, "short": []byte("A"), "zero": make([]byte, 256)} {
+		if _, err := s.LoginStart(context.Background(), "alice", a); !errors.Is(err, common.ErrorUnauthorized) {
+			t.Fatalf("%s: want ErrorUnauthorized, got %v", name, err)
+		}
+	}
+	if len(sessions.sessions) != 0 {
+		t.Fatalf("sessions created for malformed values: %d", len(sessions.sessions))
+	}
+}
+
+func TestLoginStart_LegacyAndUnknownUsers(t *testing.T) {
+	db, _ := newSQLMockDB(t)
+	defer db.Close()
+
+	// legacy accounts and unknown users get the same kind of challenge,
+	// which cannot be finished
+	legacy := newUserService(t, db, &fakeRepoManager1{u: &fakeUsersRepo1{getOut: &models.User{ID: "u1", Verifier: []byte("v")}}})
+	unknown := newUserService(t, db, &fakeRepoManager1{u: &fakeUsersRepo1{getErr: common.ErrorNotFound}})
+	for name, s := range map[string]*UserService{"legacy": legacy, "unknown": unknown} {
+		ch, err := s.LoginStart(context.Background(), "ghost", validClientPublic(t))
+		if err != nil || ch.SessionID == "" || len(ch.ServerPublic) != 256 {
+			t.Fatalf("%s: must get a plausible challenge: %+v, %v", name, ch, err)
+		}
+		if _, err := s.LoginFinish(context.Background(), ch.SessionID, []byte("m1"), models.Device{}); !errors.Is(err, common.ErrorUnauthorized) {
+			t.Fatalf("%s: → unauthorized, got %v", name, err)
+		}
+	}
+
+	ie := newUserService(t, db, &fakeRepoManager1{u: &fakeUsersRepo1{getErr: errBoom{}}})
+	if _, err := ie.LoginStart(context.Background(), "u", validClientPublic(t)); !errors.Is(err, common.ErrorInternal) {
+		t.Fatalf("internal → ErrorInternal, got %v", err)
+	}
+}
+
+func TestUpgradeKeys(t *testing.T) {
+	db, _ := newSQLMockDB(t)
+	defer db.Close()
+
+	users := &fakeUsersRepo1{}
+	s := newUserService(t, db, &fakeRepoManager1{u: users})
+	if err := s.UpgradeKeys(context.Background(), "u1", []byte("srp"), []byte("wvk")); err != nil {
+		t.Fatalf("UpgradeKeys: %v", err)
+	}
+	if string(users.upgraded) != "srp" || string(users.upgradedVK) != "wvk" {
+		t.Fatalf("keys not stored: %q, %q", users.upgraded, users.upgradedVK)
+	}
+
+	if err := s.UpgradeKeys(context.Background(), "u1", []byte("srp"), nil); !errors.Is(err, common.ErrorForbidden) {
+		t.Fatalf("empty vault key → forbidden, got %v", err)
+	}
+
+	users.upgradeErr = common.ErrorNotFound
+	if err := s.UpgradeKeys(context.Background(), "u1", []byte("srp"), []byte("wvk")); !errors.Is(err, common.ErrorForbidden) {
+		t.Fatalf("already upgraded → forbidden, got %v", err)
+	}
+	users.upgradeErr = errBoom{}
+	if err := s.UpgradeKeys(context.Background(), "u1", []byte("srp"), []byte("wvk")); !errors.Is(err, common.ErrorInternal) {
+		t.Fatalf("db error → internal, got %v", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	db, mock := newSQLMockDB(t)
+	defer db.Close()
+
+	salt := []byte("salt")
+	key := make([]byte, 32)
+	user := &models.User{ID: "u1", UserName: "alice", Salt: salt, SRPVerifier: cryptox.SRPVerifier("alice", salt, key), WrappedVaultKey: []byte("wvk")}
+	users := &fakeUsersRepo1{getOut: user}
+	refresh := &fakeRefreshRepo{}
+	s := newUserService(t, db, &fakeRepoManager1{u: users, r: refresh})
+
+	start := func(key []byte) (string, []byte, *cryptox.SRPClient) {
+		c, err := cryptox.NewSRPClient("alice", salt, key)
+		if err != nil {
+			t.Fatalf("NewSRPClient: %v", err)
+		}
+		ch, err := s.LoginStart(context.Background(), "alice", c.PublicKey())
+		if err != nil {
+			t.Fatalf("LoginStart: %v", err)
+		}
+		m1, err := c.Proof(ch.ServerPublic)
+		if err != nil {
+			t.Fatalf("Proof: %v", err)
+		}
+		return ch.SessionID, m1, c
+	}
+	creds := models.Credentials{Salt: []byte("salt2"), KDF: cryptox.DefaultKDFParams, SRPVerifier: []byte("srp2"), WrappedVaultKey: []byte("wvk2")}
+	change := func(userID, id string, m1 []byte) (*LoginResult, error) {
+		return s.ChangePassword(context.Background(), userID, id, m1, creds, nil, models.Device{Name: "laptop"})
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	id, m1, c := start(key)
+	res, err := change("u1", id, m1)
+	if err != nil || res.Tokens.RefreshToken == "" {
+		t.Fatalf("ChangePassword: %+v, %v", res, err)
+	}
+	if err := c.VerifyServer(res.ServerProof); err != nil {
+		t.Fatalf("server proof rejected: %v", err)
+	}
+	if users.changed == nil || string(users.changed.Salt) != "salt2" || string(users.changed.SRPVerifier) != "srp2" || string(users.changed.WrappedVaultKey) != "wvk2" {
+		t.Fatalf("credentials not swapped: %+v", users.changed)
+	}
+	if refresh.revokedUser != "u1" {
+		t.Fatalf("refresh tokens not revoked: %q", refresh.revokedUser)
+	}
+
+	// the session must belong to the caller
+	id, m1, _ = start(key)
+	if _, err := change("u2", id, m1); !errors.Is(err, common.ErrorUnauthorized) {
+		t.Fatalf("foreign session → unauthorized, got %v", err)
+	}
+	wrong := make([]byte, 32)
+	wrong[0] = 1
+	id, m1, _ = start(wrong)
+	if _, err := change("u1", id, m1); !errors.Is(err, common.ErrorUnauthorized) {
+		t.Fatalf("wrong password → unauthorized, got %v", err)
+	}
+	noKey := creds
+	noKey.WrappedVaultKey = nil
+	if _, err := s.ChangePassword(context.Background(), "u1", id, m1, noKey, nil, models.Device{}); !errors.Is(err, common.ErrorForbidden) {
+		t.Fatalf("empty vault key → forbidden, got %v", err)
+	}
+	weak := creds
+	weak.KDF = cryptox.LegacyKDFParams
+	if _, err := s.ChangePassword(context.Background(), "u1", id, m1, weak, nil, models.Device{}); !errors.Is(err, common.ErrorForbidden) {
+		t.Fatalf("KDF below policy → forbidden, got %v", err)
+	}
+
+	// changed meanwhile by another device
+	users.changeErr = common.ErrorNotFound
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	id, m1, _ = start(key)
+	if _, err := change("u1", id, m1); !errors.Is(err, common.ErrorUnauthorized) {
+		t.Fatalf("stale verifier → unauthorized, got %v", err)
+	}
+
+	users.changeErr = nil
+	refresh.revokeErr = errBoom{}
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	id, m1, _ = start(key)
+	if _, err := change("u1", id, m1); !errors.Is(err, common.ErrorInternal) {
+		t.Fatalf("revoke error → internal, got %v", err)
+	}
+
+	user.WrappedVaultKey = nil
+	id, m1, _ = start(key)
+	if _, err := change("u1", id, m1); !errors.Is(err, common.ErrorForbidden) {
+		t.Fatalf("legacy account → forbidden, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestChangePassword_RekeysVault(t *testing.T) {
+	db, mock := newSQLMockDB(t)
+	defer db.Close()
+
+	salt := []byte("salt")
+	key := make([]byte, 32)
+	user := &models.User{ID: "u1", UserName: "alice", Salt: salt, SRPVerifier: cryptox.SRPVerifier("alice", salt, key), WrappedVaultKey: []byte("wvk")}
+	users := &fakeUsersRepo1{getOut: user}
+	entryRepo := &fakeEntriesRepo{selUpdated: []*models.Entry{{ID: "e1", Version: 3}, {ID: "e2", Version: 5, Deleted: true}}}
+	fileRepo := &fakeFilesRepo{selUpdated: []*models.File{{EntryID: "e1", Nonce: []byte("n1")}}}
+	revisionRepo := &fakeRevisionsRepo{}
+	s := newUserService(t, db, &fakeRepoManager1{u: users, r: &fakeRefreshRepo{}, e: entryRepo, f: fileRepo, rv: revisionRepo})
+
+	creds := models.Credentials{Salt: []byte("salt2"), KDF: cryptox.DefaultKDFParams, SRPVerifier: []byte("srp2"), WrappedVaultKey: []byte("wvk2")}
+	change := func(rekey *models.VaultRekey) (*LoginResult, error) {
+		c, err := cryptox.NewSRPClient("alice", salt, key)
+		if err != nil {
+			t.Fatalf("NewSRPClient: %v", err)
+		}
+		ch, err := s.LoginStart(context.Background(), "alice", c.PublicKey())
+		if err != nil {
+			t.Fatalf("LoginStart: %v", err)
+		}
+		m1, err := c.Proof(ch.ServerPublic)
+		if err != nil {
+			t.Fatalf("Proof: %v", err)
+		}
+		return s.ChangePassword(context.Background(), "u1", ch.SessionID, m1, creds, rekey, models.Device{})
+	}
+	entry := func(id string, base int64) *models.Entry {
+		return &models.Entry{ID: id, BaseVersion: base, Details: []byte("new-" + id)}
+	}
+	file := &models.File{EntryID: "e1", EncryptedFileKey: []byte("fk2"), Nonce: []byte("n1")}
+
+	// anything the client missed would stay under the old vault key
+	stale := []*models.VaultRekey{
+		{Entries: []*models.Entry{entry("e1", 2), entry("e2", 5)}, Files: []*models.File{file}},
+		{Entries: []*models.Entry{entry("e1", 3)}, Files: []*models.File{file}},
+		{Entries: []*models.Entry{entry("e1", 3), entry("e1", 3)}, Files: []*models.File{file}},
+		{Entries: []*models.Entry{entry("e1", 3), entry("e2", 5)}},
+		{Entries: []*models.Entry{entry("e1", 3), entry("e2", 5)}, Files: []*models.File{{EntryID: "e1", EncryptedFileKey: []byte("fk2"), Nonce: []byte("n0")}}},
+	}
+	for i, rekey := range stale {
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+		if _, err := change(rekey); !errors.Is(err, common.ErrVersionConflict) {
+			t.Fatalf("case %d: want ErrVersionConflict, got %v", i, err)
+		}
+	}
+	if len(entryRepo.created) != 0 || len(revisionRepo.deletedUsers) != 0 {
+		t.Fatalf("stale re-key stored: %+v, %v", entryRepo.created, revisionRepo.deletedUsers)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	res, err := change(&models.VaultRekey{Entries: []*models.Entry{entry("e2", 5), entry("e1", 3)}, Files: []*models.File{file}})
+	if err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if res.Versions["e2"] != 1 || res.Versions["e1"] != 2 {
+		t.Fatalf("unexpected versions: %v", res.Versions)
+	}
+	if len(entryRepo.created) != 2 || entryRepo.created[1].Version != 2 || string(entryRepo.created[1].Details) != "new-e1" || entryRepo.created[1].UserID != "u1" {
+		t.Fatalf("entries not replaced: %+v", entryRepo.created)
+	}
+	if len(revisionRepo.deletedUsers) != 1 || len(revisionRepo.created) != 2 {
+		t.Fatalf("history not replaced: %v, %+v", revisionRepo.deletedUsers, revisionRepo.created)
+	}
+	if string(fileRepo.updatedKeys["e1"]) != "fk2" || len(fileRepo.purgedUsers) != 1 {
+		t.Fatalf("file keys not replaced: %q, %v", fileRepo.updatedKeys, fileRepo.purgedUsers)
+	}
+	if users.changed == nil || string(users.changed.WrappedVaultKey) != "wvk2" {
+		t.Fatalf("credentials not swapped: %+v", users.changed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestUpgradeKDF(t *testing.T) {
+	db, _ := newSQLMockDB(t)
+	defer db.Close()
+
+	salt := []byte("salt")
+	key := make([]byte, 32)
+	user := &models.User{ID: "u1", UserName: "alice", Salt: salt, KDF: cryptox.LegacyKDFParams,
+		SRPVerifier: cryptox.SRPVerifier("alice", salt, key), WrappedVaultKey: []byte("wvk")}
+	users := &fakeUsersRepo1{getOut: user}
+	refresh := &fakeRefreshRepo{}
+	s := newUserService(t, db, &fakeRepoManager1{u: users, r: refresh})
+
+	start := func() (string, []byte, *cryptox.SRPClient) {
+		c, err := cryptox.NewSRPClient("alice", salt, key)
+		if err != nil {
+			t.Fatalf("NewSRPClient: %v", err)
+		}
+		ch, err := s.LoginStart(context.Background(), "alice", c.PublicKey())
+		if err != nil {
+			t.Fatalf("LoginStart: %v", err)
+		}
+		m1, err := c.Proof(ch.ServerPublic)
+		if err != nil {
+			t.Fatalf("Proof: %v", err)
+		}
+		return ch.SessionID, m1, c
+	}
+	creds := models.Credentials{Salt: []byte("salt2"), KDF: cryptox.DefaultKDFParams, SRPVerifier: []byte("srp2"), WrappedVaultKey: []byte("wvk2")}
+
+	// new parameters must meet the policy
+	id, m1, _ := start()
+	weak := creds
+	weak.KDF = cryptox.LegacyKDFParams
+	if _, err := s.UpgradeKDF(context.Background(), "u1", id, m1, weak); !errors.Is(err, common.ErrorForbidden) {
+		t.Fatalf("KDF below policy → forbidden, got %v", err)
+	}
+
+	id, m1, c := start()
+	proof, err := s.UpgradeKDF(context.Background(), "u1", id, m1, creds)
+	if err != nil {
+		t.Fatalf("UpgradeKDF: %v", err)
+	}
+	if err := c.VerifyServer(proof); err != nil {
+		t.Fatalf("server proof rejected: %v", err)
+	}
+	if users.changed == nil || users.changed.KDF != cryptox.DefaultKDFParams || string(users.changed.Salt) != "salt2" {
+		t.Fatalf("credentials not swapped: %+v", users.changed)
+	}
+	if refresh.revokedUser != "" {
+		t.Fatalf("an upgrade must not revoke refresh tokens")
+	}
+
+	// only accounts below policy are upgraded
+	user.KDF = cryptox.DefaultKDFParams
+	id, m1, _ = start()
+	if _, err := s.UpgradeKDF(context.Background(), "u1", id, m1, creds); !errors.Is(err, common.ErrorForbidden) {
+		t.Fatalf("account at policy → forbidden, got %v", err)
+	}
+
+	user.KDF = cryptox.LegacyKDFParams
+	users.changeErr = common.ErrorNotFound
+	id, m1, _ = start()
+	if _, err := s.UpgradeKDF(context.Background(), "u1", id, m1, creds); !errors.Is(err, common.ErrorUnauthorized) {
+		t.Fatalf("stale verifier → unauthorized, got %v", err)
+	}
 }
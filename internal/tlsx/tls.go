@@ -0,0 +1,175 @@
+// Package tlsx builds the TLS configurations of the gRPC transport and the
+// blob endpoint: a server configuration whose certificate and client CA can
+// be reloaded while the server runs, and a client configuration with a CA bundle, certificate
+// pinning and an optional client certificate for mutual TLS.
+package tlsx
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// pinPrefix optionally prefixes a pin, as in curl's --pinnedpubkey.
+const pinPrefix = "sha256//"
+
+// Reloader holds the server certificate and the client CA pool loaded from
+// files and reloads them on demand, so that renewed certificates take effect
+// without a restart. Connections established before a reload keep theirs.
+type Reloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	mu     sync.RWMutex
+	config *tls.Config
+}
+
+// NewReloader loads the certificate and key and, if clientCAFile is set, the
+// CA bundle that client certificates must chain to. Without a client CA,
+// clients are not asked for a certificate.
+func NewReloader(certFile, keyFile, clientCAFile string) (*Reloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("a TLS certificate and key are required")
+	}
+	r := &Reloader{certFile: certFile, keyFile: keyFile, clientCAFile: clientCAFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads the files again. On error the previous configuration stays in
+// use.
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if r.clientCAFile != "" {
+		pool, err := loadCertPool(r.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("load client CA: %w", err)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	r.mu.Lock()
+	r.config = config
+	r.mu.Unlock()
+	return nil
+}
+
+// TLSConfig returns a server configuration that uses whatever was loaded
+// last for every new connection.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.config, nil
+		},
+	}
+}
+
+// TLSConfigNoClientAuth is TLSConfig without the client CA: clients are
+// not asked for a certificate even with mutual TLS configured. It suits
+// endpoints whose requests carry their own credentials, like presigned URLs.
+func (r *Reloader) TLSConfigNoClientAuth() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return &tls.Config{
+				MinVersion:   r.config.MinVersion,
+				Certificates: r.config.Certificates,
+			}, nil
+		},
+	}
+}
+
+// ClientConfig returns a client configuration that verifies the server
+// against the CA bundle in caFile, or the system roots if it is empty.
+// With pins, the verified chain must also contain a certificate whose public
+// key matches one of them (see Pin). With certFile and keyFile, the client
+// presents that certificate for mutual TLS.
+func ClientConfig(caFile string, pins []string, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("load CA: %w", err)
+		}
+		config.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(pins) > 0 {
+		want := make([][]byte, 0, len(pins))
+		for _, p := range pins {
+			sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(strings.TrimSpace(p), pinPrefix))
+			if err != nil || len(sum) != sha256.Size {
+				return nil, fmt.Errorf("invalid pin %q", p)
+			}
+			want = append(want, sum)
+		}
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			for _, chain := range cs.VerifiedChains {
+				for _, cert := range chain {
+					sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+					for _, w := range want {
+						if subtle.ConstantTimeCompare(sum[:], w) == 1 {
+							return nil
+						}
+					}
+				}
+			}
+			return errors.New("server certificate does not match any pin")
+		}
+	}
+	return config, nil
+}
+
+// Pin returns the pin of a certificate: the base64 SHA-256 digest of its
+// public key, as printed by
+//
+//	openssl x509 -in cert.pem -pubkey -noout | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+func Pin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// loadCertPool reads a PEM bundle of CA certificates.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,246 @@
+package tlsx
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates for the tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string
+}
+
+func newTestCA(t *testing.T, dir, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	file := filepath.Join(dir, name+".pem")
+	writePEM(t, file, "CERTIFICATE", der)
+	return &testCA{cert: cert, key: key, file: file}
+}
+
+// issue writes a leaf certificate and its key and returns their paths and
+// the certificate.
+func (ca *testCA) issue(t *testing.T, dir, name string, usage x509.ExtKeyUsage) (string, string, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+"-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "PRIVATE KEY", keyDER)
+	cert, _ := x509.ParseCertificate(der)
+	return certFile, keyFile, cert
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// handshake connects a client with the given configuration to a server with
+// the other and returns the client's error.
+func handshake(t *testing.T, server, client *tls.Config) error {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		_ = conn.(*tls.Conn).Handshake()
+		conn.Close()
+	}()
+
+	cfg := client.Clone()
+	cfg.ServerName = "localhost"
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: time.Second}, "tcp", ln.Addr().String(), cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// with TLS 1.3 the server rejects a client certificate after the
+	// client's handshake is done, so read its verdict
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+func TestServerAndClientConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	certFile, keyFile, serverCert := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+
+	r, err := NewReloader(certFile, keyFile, "")
+	if err != nil {
+		t.Fatalf("NewReloader: %v", err)
+	}
+
+	client, err := ClientConfig(ca.file, nil, "", "")
+	if err != nil {
+		t.Fatalf("ClientConfig: %v", err)
+	}
+	if err := handshake(t, r.TLSConfig(), client); err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+
+	// the system roots do not know the test CA
+	system, _ := ClientConfig("", nil, "", "")
+	if err := handshake(t, r.TLSConfig(), system); err == nil {
+		t.Fatalf("untrusted server accepted")
+	}
+
+	pinned, err := ClientConfig(ca.file, []string{pinPrefix + Pin(serverCert)}, "", "")
+	if err != nil {
+		t.Fatalf("ClientConfig with pin: %v", err)
+	}
+	if err := handshake(t, r.TLSConfig(), pinned); err != nil {
+		t.Fatalf("pinned handshake: %v", err)
+	}
+	// the CA's key is in the chain too, but another one is not
+	other := newTestCA(t, dir, "other")
+	caPinned, _ := ClientConfig(ca.file, []string{Pin(ca.cert)}, "", "")
+	if err := handshake(t, r.TLSConfig(), caPinned); err != nil {
+		t.Fatalf("handshake pinned to the CA: %v", err)
+	}
+	wrongPin, _ := ClientConfig(ca.file, []string{Pin(other.cert)}, "", "")
+	if err := handshake(t, r.TLSConfig(), wrongPin); err == nil || !strings.Contains(err.Error(), "pin") {
+		t.Fatalf("want pin mismatch, got %v", err)
+	}
+	if _, err := ClientConfig(ca.file, []string{"not-a-pin"}, "", ""); err == nil {
+		t.Fatalf("invalid pin accepted")
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	clientCA := newTestCA(t, dir, "client-ca")
+	certFile, keyFile, _ := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey, _ := clientCA.issue(t, dir, "client", x509.ExtKeyUsageClientAuth)
+	strangerCert, strangerKey, _ := ca.issue(t, dir, "stranger", x509.ExtKeyUsageClientAuth)
+
+	r, err := NewReloader(certFile, keyFile, clientCA.file)
+	if err != nil {
+		t.Fatalf("NewReloader: %v", err)
+	}
+
+	withCert, err := ClientConfig(ca.file, nil, clientCert, clientKey)
+	if err != nil {
+		t.Fatalf("ClientConfig: %v", err)
+	}
+	if err := handshake(t, r.TLSConfig(), withCert); err != nil {
+		t.Fatalf("handshake with client certificate: %v", err)
+	}
+
+	without, _ := ClientConfig(ca.file, nil, "", "")
+	if err := handshake(t, r.TLSConfig(), without); err == nil {
+		t.Fatalf("client without certificate accepted")
+	}
+	stranger, _ := ClientConfig(ca.file, nil, strangerCert, strangerKey)
+	if err := handshake(t, r.TLSConfig(), stranger); err == nil {
+		t.Fatalf("client certificate of another CA accepted")
+	}
+	if err := handshake(t, r.TLSConfigNoClientAuth(), without); err != nil {
+		t.Fatalf("handshake without client authentication: %v", err)
+	}
+}
+
+func TestReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	oldCA := newTestCA(t, dir, "old-ca")
+	certFile, keyFile, _ := oldCA.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+
+	r, err := NewReloader(certFile, keyFile, "")
+	if err != nil {
+		t.Fatalf("NewReloader: %v", err)
+	}
+	server := r.TLSConfig()
+
+	// renew the certificate with another CA in place
+	newCA := newTestCA(t, dir, "new-ca")
+	newCA.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	trustsNew, _ := ClientConfig(newCA.file, nil, "", "")
+	if err := handshake(t, server, trustsNew); err == nil {
+		t.Fatalf("renewed certificate served before reload")
+	}
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if err := handshake(t, server, trustsNew); err != nil {
+		t.Fatalf("handshake after reload: %v", err)
+	}
+
+	// a broken file keeps the loaded certificate
+	if err := os.WriteFile(keyFile, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatalf("broken key reloaded")
+	}
+	if err := handshake(t, server, trustsNew); err != nil {
+		t.Fatalf("handshake after failed reload: %v", err)
+	}
+
+	if _, err := NewReloader("", "", ""); err == nil {
+		t.Fatalf("NewReloader without certificate succeeded")
+	}
+}